@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+type RequestBody struct {
+	Role string `json:"role"`
+}
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type Member struct {
+	PollId  string `json:"pollId"`
+	UserId  string `json:"userId"`
+	Role    string `json:"role"`
+	AddedAt string `json:"addedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var errOwner = errors.New("the poll's owner can't be added as a member")
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// checkMember checks the user can be given the role. The poll's owner already has every role.
+func checkMember(ddbPoll DdbPoll, userId string, role string) error {
+	if err := pollaccess.ValidateRole(role); err != nil {
+		return err
+	}
+	if ddbPoll.Gsi1PkUserId == fmt.Sprintf("user|%s", userId) {
+		return errOwner
+	}
+
+	return nil
+}
+
+// newMemberUpdate adds the user to the poll with the role, or changes the role of a user who's already a
+// member, who keeps the time they were added. The membership is indexed under the user, so exporting or
+// erasing their account finds it.
+func newMemberUpdate(tableName string, ddbPoll DdbPoll, userId string, role string, now time.Time) *dynamodb.UpdateItemInput {
+	currentTime := now.UTC().Format(RFC3339Milli)
+
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: ddbPoll.PkPollId,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: pollaccess.MemberKey(userId),
+			},
+		},
+		UpdateExpression: aws.String(
+			"SET #role = :role, #addedAt = if_not_exists(#addedAt, :now), #updatedAt = :now, #user = :user, #poll = :poll",
+		),
+		ExpressionAttributeNames: map[string]string{
+			"#role":      "Role",
+			"#addedAt":   "AddedAt",
+			"#updatedAt": "UpdatedAt",
+			"#user":      "GSI1PK",
+			"#poll":      "GSI1SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("user|%s", userId),
+			},
+			":poll": &types.AttributeValueMemberS{
+				Value: pollaccess.MembershipKey(stripPrefix(ddbPoll.PkPollId, "poll|")),
+			},
+			":role": &types.AttributeValueMemberS{
+				Value: role,
+			},
+			":now": &types.AttributeValueMemberS{
+				Value: currentTime,
+			},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	}
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollId := request.PathParameters["pollId"]
+	memberId := request.PathParameters["userId"]
+	userId := request.RequestContext.Authorizer["sub"].(string)
+	tableName := os.Getenv("SINGLE_TABLE_NAME")
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	role, err := pollaccess.Authorize(
+		ctx,
+		ddb,
+		tableName,
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		userId,
+		pollaccess.ActionManageMembers,
+	)
+	// A poll the user isn't a member of is reported as not found, so poll ids can't be probed
+	if errors.Is(err, pollaccess.ErrForbidden) && role == "" {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		err := errors.New("only the poll's owner can manage its members")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	if err := checkMember(ddbPoll, memberId, requestBody.Role); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	memberResult, err := ddb.UpdateItem(ctx, newMemberUpdate(tableName, ddbPoll, memberId, requestBody.Role, time.Now()))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbMember pollaccess.DdbMember
+	if err := attributevalue.UnmarshalMap(memberResult.Attributes, &ddbMember); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	member, err := json.Marshal(Member{
+		PollId:  pollId,
+		UserId:  stripPrefix(ddbMember.SkUserId, pollaccess.MemberPrefix),
+		Role:    ddbMember.Role,
+		AddedAt: ddbMember.AddedAt,
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(member),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+func TestCheckMember(t *testing.T) {
+	ddbPoll := DdbPoll{PkPollId: "poll|poll1", Gsi1PkUserId: "user|user1"}
+
+	tests := map[string]struct {
+		userId string
+		role   string
+		err    error
+	}{
+		"co-owner": {userId: "user2", role: pollaccess.RoleCoOwner},
+		"viewer":   {userId: "user2", role: pollaccess.RoleViewer},
+		"owner":    {userId: "user2", role: pollaccess.RoleOwner, err: pollaccess.ErrInvalidRole},
+		"unknown":  {userId: "user2", role: "editor", err: pollaccess.ErrInvalidRole},
+		"itself":   {userId: "user1", role: pollaccess.RoleViewer, err: errOwner},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkMember(ddbPoll, test.userId, test.role)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNewMemberUpdate(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	ddbPoll := DdbPoll{PkPollId: "poll|poll1", Gsi1PkUserId: "user|user1"}
+
+	input := newMemberUpdate("table", ddbPoll, "user2", pollaccess.RoleCoOwner, now)
+
+	if sk := input.Key["SK"].(*types.AttributeValueMemberS).Value; sk != "member|user2" {
+		t.Errorf("expected member|user2, got %s", sk)
+	}
+	if role := input.ExpressionAttributeValues[":role"].(*types.AttributeValueMemberS).Value; role != pollaccess.RoleCoOwner {
+		t.Errorf("expected %s, got %s", pollaccess.RoleCoOwner, role)
+	}
+	if addedAt := input.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberS).Value; addedAt != "2024-01-08T12:00:00Z" {
+		t.Errorf("expected 2024-01-08T12:00:00Z, got %s", addedAt)
+	}
+	if expression := aws.ToString(input.UpdateExpression); expression != "SET #role = :role, #addedAt = if_not_exists(#addedAt, :now), #updatedAt = :now, #user = :user, #poll = :poll" {
+		t.Errorf("expected the member's added time to be kept, got %s", expression)
+	}
+	if user := input.ExpressionAttributeValues[":user"].(*types.AttributeValueMemberS).Value; user != "user|user2" {
+		t.Errorf("expected the membership to be indexed under user|user2, got %s", user)
+	}
+	if poll := input.ExpressionAttributeValues[":poll"].(*types.AttributeValueMemberS).Value; poll != "member|poll1" {
+		t.Errorf("expected the membership to be sorted as member|poll1, got %s", poll)
+	}
+}
@@ -2,45 +2,286 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/MicahParks/keyfunc/v2"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func getToken(request events.APIGatewayCustomAuthorizerRequest) (string, error) {
+type TrustedIssuer struct {
+	Issuer   string `json:"issuer"`
+	JwksUri  string `json:"jwksUri"`
+	Audience string `json:"audience"`
+}
+
+type DdbApiKey struct {
+	PkKeyHash      string   `dynamodbav:"PK"`
+	SkKeyHash      string   `dynamodbav:"SK"`
+	Gsi1PkUserId   string   `dynamodbav:"GSI1PK"`
+	Gsi1SkApiKeyId string   `dynamodbav:"GSI1SK"`
+	Name           string   `dynamodbav:"Name"`
+	CreatedAt      string   `dynamodbav:"CreatedAt"`
+	Roles          []string `dynamodbav:"Roles,omitempty"`
+}
+
+// Principal is the authenticated caller; it is flattened into the authorizer context that
+// downstream handlers read from request.RequestContext.Authorizer. Groups weigh the caller's votes.
+type Principal struct {
+	Sub    string
+	Email  string
+	Roles  []string
+	Groups []string
+}
+
+// Route is an API Gateway method and resource path, where * stands for one path segment
+type Route struct {
+	Method string
+	Path   string
+}
+
+const (
+	BearerScheme = "Bearer"
+	ApiKeyScheme = "ApiKey"
+)
+
+const (
+	RoleViewer  = "viewer"
+	RoleVoter   = "voter"
+	RoleCreator = "creator"
+	RoleAdmin   = "admin"
+)
+
+// routesByRole lists the routes each role may call; roles are cumulative, so a creator may also vote and view
+var routesByRole = map[string][]Route{
+	RoleViewer: {
+		{Method: "GET", Path: "polls"},
+		{Method: "GET", Path: "polls/*"},
+		{Method: "GET", Path: "polls/*/snapshot"},
+		{Method: "GET", Path: "polls/*/subscription-token"},
+		{Method: "GET", Path: "polls/*/timeline"},
+		{Method: "GET", Path: "me/export"},
+		{Method: "DELETE", Path: "me"},
+	},
+	RoleVoter: {
+		{Method: "POST", Path: "polls/*/*"},
+		{Method: "POST", Path: "polls/*/write-ins"},
+		{Method: "POST", Path: "polls/*/snapshot"},
+	},
+	RoleCreator: {
+		{Method: "POST", Path: "polls"},
+		{Method: "PATCH", Path: "polls/*"},
+		{Method: "DELETE", Path: "polls/*"},
+		{Method: "PATCH", Path: "polls/*/archive"},
+		{Method: "PATCH", Path: "polls/*/duration"},
+		{Method: "POST", Path: "api-keys"},
+		{Method: "DELETE", Path: "api-keys/*"},
+		{Method: "POST", Path: "polls/*/webhooks"},
+		{Method: "GET", Path: "polls/*/webhooks"},
+		{Method: "DELETE", Path: "polls/*/webhooks/*"},
+		{Method: "GET", Path: "polls/*/webhooks/*/deliveries"},
+		{Method: "POST", Path: "polls/*/clone"},
+		{Method: "POST", Path: "templates"},
+		{Method: "GET", Path: "templates"},
+		{Method: "PUT", Path: "templates/*"},
+		{Method: "DELETE", Path: "templates/*"},
+		{Method: "POST", Path: "series"},
+		{Method: "PATCH", Path: "series/*"},
+		{Method: "GET", Path: "series/*/results"},
+		{Method: "POST", Path: "option-images"},
+		{Method: "PATCH", Path: "polls/*/write-ins/*"},
+		{Method: "PUT", Path: "polls/*/tie-break"},
+		{Method: "PUT", Path: "polls/*/members/*"},
+		{Method: "DELETE", Path: "polls/*/members/*"},
+	},
+	RoleAdmin: {
+		{Method: "*", Path: "*"},
+	},
+}
+
+var impliedRoles = map[string][]string{
+	RoleViewer:  {RoleViewer},
+	RoleVoter:   {RoleViewer, RoleVoter},
+	RoleCreator: {RoleViewer, RoleVoter, RoleCreator},
+	RoleAdmin:   {RoleViewer, RoleVoter, RoleCreator, RoleAdmin},
+}
+
+func getCredentials(request events.APIGatewayCustomAuthorizerRequest) (string, string, error) {
 	if request.Type != "TOKEN" {
-		return "", errors.New("expected 'event.Type' parameter to have value 'TOKEN'")
+		return "", "", errors.New("expected 'event.Type' parameter to have value 'TOKEN'")
 	}
 
 	tokenString := request.AuthorizationToken
 	if tokenString == "" {
-		return "", errors.New("expected 'event.AuthorizationToken' parameter to be non-empty")
+		return "", "", errors.New("expected 'event.AuthorizationToken' parameter to be non-empty")
 	}
 
 	parts := strings.Split(tokenString, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		return "", errors.New("invalid authorization token format")
+	if len(parts) != 2 || (parts[0] != BearerScheme && parts[0] != ApiKeyScheme) {
+		return "", "", errors.New("invalid authorization token format")
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// getTrustedIssuers reads the issuers whose tokens are accepted from TRUSTED_ISSUERS,
+// falling back to the single TOKEN_ISSUER, JWKS_URI and AUDIENCE configuration.
+func getTrustedIssuers() ([]TrustedIssuer, error) {
+	trustedIssuersJson := os.Getenv("TRUSTED_ISSUERS")
+	if trustedIssuersJson == "" {
+		if os.Getenv("TOKEN_ISSUER") == "" {
+			return nil, errors.New("TRUSTED_ISSUERS or TOKEN_ISSUER environment variable must be set")
+		}
+
+		return []TrustedIssuer{
+			{
+				Issuer:   os.Getenv("TOKEN_ISSUER"),
+				JwksUri:  os.Getenv("JWKS_URI"),
+				Audience: os.Getenv("AUDIENCE"),
+			},
+		}, nil
+	}
+
+	var trustedIssuers []TrustedIssuer
+	if err := json.Unmarshal([]byte(trustedIssuersJson), &trustedIssuers); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_ISSUERS: %w", err)
 	}
 
-	return parts[1], nil
+	for _, trustedIssuer := range trustedIssuers {
+		if trustedIssuer.Issuer == "" || trustedIssuer.JwksUri == "" || trustedIssuer.Audience == "" {
+			return nil, errors.New("invalid TRUSTED_ISSUERS: issuer, jwksUri and audience are required")
+		}
+	}
+
+	return trustedIssuers, nil
 }
 
-func validateToken(tokenString string) (jwt.Claims, error) {
-	jwks, err := keyfunc.Get(os.Getenv("JWKS_URI"), keyfunc.Options{})
+// jwksByUri caches each issuer's key set across warm invocations; they are refreshed in the background
+var (
+	jwksByUri = map[string]*keyfunc.JWKS{}
+	jwksMu    sync.Mutex
+)
+
+const (
+	defaultJwksRefreshInterval  = time.Hour
+	defaultJwksRefreshRateLimit = 5 * time.Minute
+	defaultJwksRefreshTimeout   = 10 * time.Second
+)
+
+func getDurationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return duration, nil
+}
+
+func getJwks(jwksUri string) (*keyfunc.JWKS, error) {
+	jwksMu.Lock()
+	defer jwksMu.Unlock()
+
+	if jwks, ok := jwksByUri[jwksUri]; ok {
+		return jwks, nil
+	}
+
+	if jwksUri == "" {
+		return nil, errors.New("JWKS URI not set")
+	}
+
+	refreshInterval, err := getDurationEnv("JWKS_REFRESH_INTERVAL", defaultJwksRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshRateLimit, err := getDurationEnv("JWKS_REFRESH_RATE_LIMIT", defaultJwksRefreshRateLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTimeout, err := getDurationEnv("JWKS_REFRESH_TIMEOUT", defaultJwksRefreshTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed initial fetch is not cached, so the next invocation retries it
+	jwks, err := keyfunc.Get(jwksUri, keyfunc.Options{
+		RefreshInterval:   refreshInterval,
+		RefreshRateLimit:  refreshRateLimit,
+		RefreshTimeout:    refreshTimeout,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			log.Printf("Error: failed to refresh JWKS %s: %s", jwksUri, err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("JWKS unavailable: %w", err)
+	}
+
+	jwksByUri[jwksUri] = jwks
+
+	return jwks, nil
+}
+
+func validateToken(tokenString string) (jwt.MapClaims, error) {
+	trustedIssuers, err := getTrustedIssuers()
+	if err != nil {
+		return nil, err
+	}
+
+	// The issuer claim is only used to select the key set; it is verified below
+	unverifiedToken, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := unverifiedToken.Claims.GetIssuer()
+	if err != nil {
+		return nil, err
+	}
+
+	var trustedIssuer *TrustedIssuer
+	for i := range trustedIssuers {
+		if trustedIssuers[i].Issuer == issuer {
+			trustedIssuer = &trustedIssuers[i]
+			break
+		}
+	}
+	if trustedIssuer == nil {
+		return nil, fmt.Errorf("untrusted token issuer: %s", issuer)
+	}
+
+	jwks, err := getJwks(trustedIssuer.JwksUri)
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse and verify the token
 	token, err := jwt.Parse(
 		tokenString,
 		jwks.Keyfunc,
-		jwt.WithAudience(os.Getenv("AUDIENCE")),
-		jwt.WithIssuer(os.Getenv("TOKEN_ISSUER")),
+		jwt.WithAudience(trustedIssuer.Audience),
+		jwt.WithIssuer(trustedIssuer.Issuer),
 	)
 	if err != nil {
 		return nil, err
@@ -51,7 +292,249 @@ func validateToken(tokenString string) (jwt.Claims, error) {
 		return nil, errors.New("invalid token")
 	}
 
-	return token.Claims, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if sub, err := claims.GetSubject(); err != nil || sub == "" {
+		return nil, errors.New("token is missing a subject")
+	}
+
+	return claims, nil
+}
+
+// claimStrings reads a claim that may be a list of strings or a single space-separated string
+func claimStrings(claims jwt.MapClaims, key string) []string {
+	switch value := claims[key].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if str, ok := v.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// getGroupsClaim is the claim that lists the caller's groups, which is groups unless GROUPS_CLAIM names
+// another one, like cognito:groups
+func getGroupsClaim() string {
+	groupsClaim := os.Getenv("GROUPS_CLAIM")
+	if groupsClaim == "" {
+		return "groups"
+	}
+
+	return groupsClaim
+}
+
+func getDefaultRoles() []string {
+	defaultRoles := os.Getenv("DEFAULT_ROLES")
+	if defaultRoles == "" {
+		return []string{RoleCreator}
+	}
+
+	return strings.Split(defaultRoles, ",")
+}
+
+// expandRoles drops unknown roles and adds the roles implied by the remaining ones, in a stable order
+func expandRoles(roles []string) []string {
+	granted := map[string]bool{}
+	for _, role := range roles {
+		for _, impliedRole := range impliedRoles[strings.TrimSpace(role)] {
+			granted[impliedRole] = true
+		}
+	}
+
+	var expanded []string
+	for _, role := range []string{RoleViewer, RoleVoter, RoleCreator, RoleAdmin} {
+		if granted[role] {
+			expanded = append(expanded, role)
+		}
+	}
+
+	return expanded
+}
+
+// getPrincipal builds the principal from the token's roles, scope or scp claims,
+// falling back to DEFAULT_ROLES when the token doesn't carry any, and its groups claim
+func getPrincipal(claims jwt.MapClaims) Principal {
+	var roles []string
+	for _, key := range []string{"roles", "scope", "scp"} {
+		roles = append(roles, claimStrings(claims, key)...)
+	}
+	if len(roles) == 0 {
+		roles = getDefaultRoles()
+	}
+
+	sub, _ := claims.GetSubject()
+	email, _ := claims["email"].(string)
+
+	// Groups are passed on as a comma-separated list, so a group can't contain a comma
+	var groups []string
+	for _, group := range claimStrings(claims, getGroupsClaim()) {
+		if !strings.Contains(group, ",") {
+			groups = append(groups, group)
+		}
+	}
+
+	return Principal{
+		Sub:    sub,
+		Email:  email,
+		Roles:  expandRoles(roles),
+		Groups: groups,
+	}
+}
+
+// buildPolicy allows the principal's routes across the whole stage of the method being called,
+// so the result can be cached and reused for every route the principal calls.
+//
+// A * in an execute-api ARN also matches across /, so polls/* would allow polls/{pollId}/webhooks too.
+// Every route the principal's roles don't include is denied explicitly, which keeps each route exact.
+func buildPolicy(methodArn string, roles []string) (events.APIGatewayCustomAuthorizerPolicy, error) {
+	// arn:aws:execute-api:{region}:{accountId}:{apiId}/{stage}/{method}/{resourcePath}
+	arnParts := strings.SplitN(methodArn, "/", 3)
+	if len(arnParts) < 3 {
+		return events.APIGatewayCustomAuthorizerPolicy{}, fmt.Errorf("invalid method ARN: %s", methodArn)
+	}
+	stageArn := fmt.Sprintf("%s/%s", arnParts[0], arnParts[1])
+
+	routeArn := func(route Route) string {
+		return fmt.Sprintf("%s/%s/%s", stageArn, route.Method, route.Path)
+	}
+
+	var resources []string
+	allowed := make(map[Route]bool)
+	for _, role := range roles {
+		for _, route := range routesByRole[role] {
+			resources = append(resources, routeArn(route))
+			allowed[route] = true
+		}
+	}
+
+	if len(resources) == 0 {
+		return events.APIGatewayCustomAuthorizerPolicy{
+			Version: "2012-10-17",
+			Statement: []events.IAMPolicyStatement{
+				{
+					Effect:   "Deny",
+					Resource: []string{fmt.Sprintf("%s/*/*", stageArn)},
+					Action:   []string{"execute-api:Invoke"},
+				},
+			},
+		}, nil
+	}
+
+	statements := []events.IAMPolicyStatement{
+		{
+			Effect:   "Allow",
+			Resource: resources,
+			Action:   []string{"execute-api:Invoke"},
+		},
+	}
+
+	if !allowed[Route{Method: "*", Path: "*"}] {
+		var denied []string
+		for _, role := range []string{RoleViewer, RoleVoter, RoleCreator} {
+			for _, route := range routesByRole[role] {
+				if !allowed[route] {
+					denied = append(denied, routeArn(route))
+				}
+			}
+		}
+
+		if len(denied) > 0 {
+			statements = append(statements, events.IAMPolicyStatement{
+				Effect:   "Deny",
+				Resource: denied,
+				Action:   []string{"execute-api:Invoke"},
+			})
+		}
+	}
+
+	return events.APIGatewayCustomAuthorizerPolicy{
+		Version:   "2012-10-17",
+		Statement: statements,
+	}, nil
+}
+
+func hashApiKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// getApiKey looks up an API key by its hash; it is a variable so tests can stub out DynamoDB
+var getApiKey = func(ctx context.Context, keyHash string) (*DdbApiKey, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("apikey|%s", keyHash),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("apikey|%s", keyHash),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var ddbApiKey DdbApiKey
+	if err = attributevalue.UnmarshalMap(result.Item, &ddbApiKey); err != nil {
+		return nil, err
+	}
+
+	return &ddbApiKey, nil
+}
+
+func validateApiKey(ctx context.Context, apiKey string) (Principal, error) {
+	ddbApiKey, err := getApiKey(ctx, hashApiKey(apiKey))
+	if err != nil {
+		return Principal{}, err
+	}
+	if ddbApiKey == nil {
+		return Principal{}, errors.New("unknown API key")
+	}
+
+	userId := stripPrefix(ddbApiKey.Gsi1PkUserId, "user|")
+	if userId == "" || userId == ddbApiKey.Gsi1PkUserId {
+		return Principal{}, errors.New("API key is not scoped to a user")
+	}
+
+	roles := ddbApiKey.Roles
+	if len(roles) == 0 {
+		roles = getDefaultRoles()
+	}
+
+	return Principal{
+		Sub:   userId,
+		Roles: expandRoles(roles),
+	}, nil
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
 }
 
 func logAndReturn(res events.APIGatewayCustomAuthorizerResponse, err error) events.APIGatewayCustomAuthorizerResponse {
@@ -68,7 +551,7 @@ func handler(
 	ctx context.Context,
 	request events.APIGatewayCustomAuthorizerRequest,
 ) (events.APIGatewayCustomAuthorizerResponse, error) {
-	token, err := getToken(request)
+	scheme, credential, err := getCredentials(request)
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayCustomAuthorizerResponse{},
@@ -76,7 +559,29 @@ func handler(
 		), errors.New("Unauthorized")
 	}
 
-	claims, err := validateToken(token)
+	var principal Principal
+	switch scheme {
+	case BearerScheme:
+		claims, err := validateToken(credential)
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayCustomAuthorizerResponse{},
+				err,
+			), errors.New("Unauthorized")
+		}
+
+		principal = getPrincipal(claims)
+	case ApiKeyScheme:
+		principal, err = validateApiKey(ctx, credential)
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayCustomAuthorizerResponse{},
+				err,
+			), errors.New("Unauthorized")
+		}
+	}
+
+	policyDocument, err := buildPolicy(request.MethodArn, principal.Roles)
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayCustomAuthorizerResponse{},
@@ -84,20 +589,17 @@ func handler(
 		), errors.New("Unauthorized")
 	}
 
+	// API Gateway only accepts string, number and boolean context values
 	return logAndReturn(
 		events.APIGatewayCustomAuthorizerResponse{
-			PrincipalID: fmt.Sprintf("%s", claims.(jwt.MapClaims)["sub"]),
-			PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
-				Version: "2012-10-17",
-				Statement: []events.IAMPolicyStatement{
-					{
-						Effect:   "Allow",
-						Resource: []string{request.MethodArn},
-						Action:   []string{"execute-api:Invoke"},
-					},
-				},
+			PrincipalID:    principal.Sub,
+			PolicyDocument: policyDocument,
+			Context: map[string]interface{}{
+				"sub":    principal.Sub,
+				"email":  principal.Email,
+				"roles":  strings.Join(principal.Roles, ","),
+				"groups": strings.Join(principal.Groups, ","),
 			},
-			Context: claims.(jwt.MapClaims),
 		},
 		nil,
 	), nil
@@ -2,23 +2,577 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"os"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-func TestHandler(t *testing.T) {
-	ctx := context.Background()
+const (
+	testIssuer   = "https://issuer.test"
+	testAudience = "test-audience"
+)
+
+type testKey struct {
+	kid        string
+	privateKey *rsa.PrivateKey
+}
+
+// jwksServer serves a JWKS for a mutable set of locally generated keys
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     []testKey
+	requests int
+	healthy  bool
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKey{kid: kid, privateKey: privateKey}
+}
+
+func newJwksServer(t *testing.T, keys ...testKey) *jwksServer {
+	t.Helper()
+
+	server := &jwksServer{keys: keys, healthy: true}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		server.requests++
+
+		if !server.healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var jwks struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for _, key := range server.keys {
+			jwks.Keys = append(jwks.Keys, map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"kid": key.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.privateKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.privateKey.E)).Bytes()),
+			})
+		}
 
-	res, err := handler(ctx, events.APIGatewayCustomAuthorizerRequest{
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *jwksServer) addKey(key testKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, key)
+}
+
+func (s *jwksServer) setHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.healthy = healthy
+}
+
+func (s *jwksServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// setup points the authorizer at the given JWKS server and clears the cached key set
+func setup(t *testing.T, server *jwksServer) {
+	t.Helper()
+
+	t.Setenv("JWKS_URI", server.URL)
+	t.Setenv("AUDIENCE", testAudience)
+	t.Setenv("TOKEN_ISSUER", testIssuer)
+
+	resetJwks()
+	t.Cleanup(resetJwks)
+}
+
+// resetJwks clears the cached key sets so each test fetches from its own JWKS server
+func resetJwks() {
+	jwksMu.Lock()
+	defer jwksMu.Unlock()
+
+	for jwksUri, jwks := range jwksByUri {
+		jwks.EndBackground()
+		delete(jwksByUri, jwksUri)
+	}
+}
+
+func signToken(t *testing.T, key testKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.kid
+
+	tokenString, err := token.SignedString(key.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenString
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "user123",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func authorizeWithScheme(scheme string, credential string) (events.APIGatewayCustomAuthorizerResponse, error) {
+	return handler(context.Background(), events.APIGatewayCustomAuthorizerRequest{
 		Type:               "TOKEN",
-		AuthorizationToken: fmt.Sprintf("Bearer %s", os.Getenv("AUTH_TOKEN")),
+		AuthorizationToken: fmt.Sprintf("%s %s", scheme, credential),
+		MethodArn:          "arn:aws:execute-api:us-east-2:123456789012:abcdef/v1/GET/polls",
 	})
+}
+
+func authorize(tokenString string) (events.APIGatewayCustomAuthorizerResponse, error) {
+	return authorizeWithScheme(BearerScheme, tokenString)
+}
+
+func TestHandler(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	res, err := authorize(signToken(t, key, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.PrincipalID != "user123" {
+		t.Errorf("expected principal user123, got %s", res.PrincipalID)
+	}
+	if res.PolicyDocument.Statement[0].Effect != "Allow" {
+		t.Errorf("expected Allow, got %s", res.PolicyDocument.Statement[0].Effect)
+	}
+}
+
+func TestHandlerCachesJwks(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	for i := 0; i < 3; i++ {
+		if _, err := authorize(signToken(t, key, validClaims())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count := server.requestCount(); count != 1 {
+		t.Errorf("expected the JWKS to be fetched once, got %d requests", count)
+	}
+}
+
+func TestHandlerRefreshesUnknownKid(t *testing.T) {
+	oldKey := newTestKey(t, "key-1")
+	server := newJwksServer(t, oldKey)
+	setup(t, server)
+
+	if _, err := authorize(signToken(t, oldKey, validClaims())); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate in a new signing key after the key set has been cached
+	newKey := newTestKey(t, "key-2")
+	server.addKey(newKey)
+
+	res, err := authorize(signToken(t, newKey, validClaims()))
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	fmt.Println(res)
+	if res.PrincipalID != "user123" {
+		t.Errorf("expected principal user123, got %s", res.PrincipalID)
+	}
+	if count := server.requestCount(); count != 2 {
+		t.Errorf("expected the JWKS to be refreshed once, got %d requests", count)
+	}
+}
+
+func TestHandlerRejectsUntrustedKey(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	untrustedKey := newTestKey(t, "key-1")
+
+	if _, err := authorize(signToken(t, untrustedKey, validClaims())); err == nil {
+		t.Error("expected a token signed by an untrusted key to be rejected")
+	}
+}
+
+func TestHandlerRejectsInvalidClaims(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	tests := map[string]func(jwt.MapClaims){
+		"wrong audience": func(claims jwt.MapClaims) { claims["aud"] = "other-audience" },
+		"wrong issuer":   func(claims jwt.MapClaims) { claims["iss"] = "https://other.test" },
+		"expired":        func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+
+			if _, err := authorize(signToken(t, key, claims)); err == nil {
+				t.Error("expected token to be rejected")
+			}
+		})
+	}
+}
+
+func TestHandlerFailsClosedWhenJwksUnavailable(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	server.setHealthy(false)
+	setup(t, server)
+
+	if _, err := authorize(signToken(t, key, validClaims())); err == nil {
+		t.Fatal("expected authorization to fail while the JWKS is unavailable")
+	}
+
+	_, err := validateToken(signToken(t, key, validClaims()))
+	if err == nil || !strings.HasPrefix(err.Error(), "JWKS unavailable") {
+		t.Errorf("expected a JWKS unavailable error, got %v", err)
+	}
+
+	// The failure isn't cached, so authorization recovers with the JWKS
+	server.setHealthy(true)
+
+	if _, err := authorize(signToken(t, key, validClaims())); err != nil {
+		t.Errorf("expected authorization to recover, got %s", err)
+	}
+}
+
+func TestHandlerMultipleIssuers(t *testing.T) {
+	googleKey := newTestKey(t, "google-key")
+	googleServer := newJwksServer(t, googleKey)
+	ciKey := newTestKey(t, "ci-key")
+	ciServer := newJwksServer(t, ciKey)
+	setup(t, googleServer)
+
+	trustedIssuers, _ := json.Marshal([]TrustedIssuer{
+		{Issuer: testIssuer, JwksUri: googleServer.URL, Audience: testAudience},
+		{Issuer: "https://ci.test", JwksUri: ciServer.URL, Audience: "ci-audience"},
+	})
+	t.Setenv("TRUSTED_ISSUERS", string(trustedIssuers))
+
+	ciClaims := jwt.MapClaims{
+		"sub": "repo:pseudopoll:ref:refs/heads/main",
+		"iss": "https://ci.test",
+		"aud": "ci-audience",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	res, err := authorize(signToken(t, googleKey, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.PrincipalID != "user123" {
+		t.Errorf("expected principal user123, got %s", res.PrincipalID)
+	}
+
+	res, err = authorize(signToken(t, ciKey, ciClaims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.PrincipalID != "repo:pseudopoll:ref:refs/heads/main" {
+		t.Errorf("expected CI principal, got %s", res.PrincipalID)
+	}
+
+	// Each issuer's tokens must be signed by that issuer's keys and carry its audience
+	if _, err := authorize(signToken(t, googleKey, ciClaims)); err == nil {
+		t.Error("expected a CI token signed by another issuer's key to be rejected")
+	}
+
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "ci-audience"
+	if _, err := authorize(signToken(t, googleKey, wrongAudience)); err == nil {
+		t.Error("expected a token with another issuer's audience to be rejected")
+	}
+
+	untrusted := validClaims()
+	untrusted["iss"] = "https://untrusted.test"
+	if _, err := authorize(signToken(t, googleKey, untrusted)); err == nil {
+		t.Error("expected a token from an untrusted issuer to be rejected")
+	}
+}
+
+func TestHandlerApiKey(t *testing.T) {
+	const apiKey = "pp_test-api-key"
+
+	getApiKeyFromDdb := getApiKey
+	t.Cleanup(func() { getApiKey = getApiKeyFromDdb })
+
+	getApiKey = func(ctx context.Context, keyHash string) (*DdbApiKey, error) {
+		if keyHash != hashApiKey(apiKey) {
+			return nil, nil
+		}
+
+		return &DdbApiKey{
+			PkKeyHash:      fmt.Sprintf("apikey|%s", keyHash),
+			SkKeyHash:      fmt.Sprintf("apikey|%s", keyHash),
+			Gsi1PkUserId:   "user|user123",
+			Gsi1SkApiKeyId: "apikey|key123",
+			Name:           "release bot",
+		}, nil
+	}
+
+	res, err := authorizeWithScheme(ApiKeyScheme, apiKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.PrincipalID != "user123" {
+		t.Errorf("expected principal user123, got %s", res.PrincipalID)
+	}
+	if res.Context["sub"] != "user123" {
+		t.Errorf("expected sub user123 in context, got %v", res.Context["sub"])
+	}
+
+	if _, err := authorizeWithScheme(ApiKeyScheme, "pp_unknown"); err == nil {
+		t.Error("expected an unknown API key to be rejected")
+	}
+
+	if _, err := authorizeWithScheme("Basic", apiKey); err == nil {
+		t.Error("expected an unsupported scheme to be rejected")
+	}
+}
+
+func TestHandlerScopesPolicyToRoles(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	const stageArn = "arn:aws:execute-api:us-east-2:123456789012:abcdef/v1"
+
+	viewerRoutes := []string{
+		stageArn + "/GET/polls",
+		stageArn + "/GET/polls/*",
+		stageArn + "/GET/polls/*/snapshot",
+		stageArn + "/GET/polls/*/subscription-token",
+		stageArn + "/GET/polls/*/timeline",
+		stageArn + "/GET/me/export",
+		stageArn + "/DELETE/me",
+	}
+	voterRoutes := append(viewerRoutes,
+		stageArn+"/POST/polls/*/*",
+		stageArn+"/POST/polls/*/write-ins",
+		stageArn+"/POST/polls/*/snapshot",
+	)
+	creatorRoutes := append(voterRoutes,
+		stageArn+"/POST/polls",
+		stageArn+"/PATCH/polls/*",
+		stageArn+"/DELETE/polls/*",
+		stageArn+"/PATCH/polls/*/archive",
+		stageArn+"/PATCH/polls/*/duration",
+		stageArn+"/POST/api-keys",
+		stageArn+"/DELETE/api-keys/*",
+		stageArn+"/POST/polls/*/webhooks",
+		stageArn+"/GET/polls/*/webhooks",
+		stageArn+"/DELETE/polls/*/webhooks/*",
+		stageArn+"/GET/polls/*/webhooks/*/deliveries",
+		stageArn+"/POST/polls/*/clone",
+		stageArn+"/POST/templates",
+		stageArn+"/GET/templates",
+		stageArn+"/PUT/templates/*",
+		stageArn+"/DELETE/templates/*",
+		stageArn+"/POST/series",
+		stageArn+"/PATCH/series/*",
+		stageArn+"/GET/series/*/results",
+		stageArn+"/POST/option-images",
+		stageArn+"/PATCH/polls/*/write-ins/*",
+		stageArn+"/PUT/polls/*/tie-break",
+		stageArn+"/PUT/polls/*/members/*",
+		stageArn+"/DELETE/polls/*/members/*",
+	)
+	adminRoutes := append(creatorRoutes, stageArn+"/*/*")
+
+	tests := map[string]struct {
+		claim      string
+		claimValue interface{}
+		resources  []string
+		roles      string
+	}{
+		"default roles":           {resources: creatorRoutes, roles: "viewer,voter,creator"},
+		"viewer from roles claim": {claim: "roles", claimValue: []interface{}{"viewer", 42}, resources: viewerRoutes, roles: "viewer"},
+		"voter from scope claim":  {claim: "scope", claimValue: "openid voter", resources: voterRoutes, roles: "viewer,voter"},
+		"admin from scp claim":    {claim: "scp", claimValue: []interface{}{"admin"}, resources: adminRoutes, roles: "viewer,voter,creator,admin"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			claims["email"] = "user123@example.com"
+			if test.claim != "" {
+				claims[test.claim] = test.claimValue
+			}
+
+			res, err := authorize(signToken(t, key, claims))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			statement := res.PolicyDocument.Statement[0]
+			if statement.Effect != "Allow" {
+				t.Errorf("expected Allow, got %s", statement.Effect)
+			}
+			if strings.Join(statement.Resource, " ") != strings.Join(test.resources, " ") {
+				t.Errorf("expected resources %v, got %v", test.resources, statement.Resource)
+			}
+
+			for key, value := range res.Context {
+				if _, ok := value.(string); !ok {
+					t.Errorf("expected %s to be a string, got %T", key, value)
+				}
+			}
+			if res.Context["sub"] != "user123" || res.Context["email"] != "user123@example.com" {
+				t.Errorf("unexpected context %v", res.Context)
+			}
+			if res.Context["roles"] != test.roles {
+				t.Errorf("expected roles %s, got %v", test.roles, res.Context["roles"])
+			}
+		})
+	}
+}
+
+// isAllowed evaluates the policy for the ARN the way API Gateway does, where * matches any characters
+// including / and an explicit deny wins
+func isAllowed(policy events.APIGatewayCustomAuthorizerPolicy, arn string) bool {
+	allowed := false
+	for _, statement := range policy.Statement {
+		for _, resource := range statement.Resource {
+			pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(resource), `\*`, ".*") + "$"
+			if !regexp.MustCompile(pattern).MatchString(arn) {
+				continue
+			}
+
+			if statement.Effect == "Deny" {
+				return false
+			}
+			allowed = true
+		}
+	}
+
+	return allowed
+}
+
+func TestHandlerPolicyMatchesExactRoutes(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	const stageArn = "arn:aws:execute-api:us-east-2:123456789012:abcdef/v1"
+
+	tests := map[string]struct {
+		role     string
+		arn      string
+		expected bool
+	}{
+		"viewer gets a poll":               {role: "viewer", arn: "/GET/polls/x", expected: true},
+		"viewer gets a timeline":           {role: "viewer", arn: "/GET/polls/x/timeline", expected: true},
+		"viewer lists webhooks":            {role: "viewer", arn: "/GET/polls/x/webhooks"},
+		"viewer lists deliveries":          {role: "viewer", arn: "/GET/polls/x/webhooks/y/deliveries"},
+		"viewer votes":                     {role: "viewer", arn: "/POST/polls/x/y"},
+		"voter votes":                      {role: "voter", arn: "/POST/polls/x/y", expected: true},
+		"voter writes in":                  {role: "voter", arn: "/POST/polls/x/write-ins", expected: true},
+		"voter creates a webhook":          {role: "voter", arn: "/POST/polls/x/webhooks"},
+		"voter clones a poll":              {role: "voter", arn: "/POST/polls/x/clone"},
+		"voter removes a member":           {role: "voter", arn: "/DELETE/polls/x/members/y"},
+		"creator creates a webhook":        {role: "creator", arn: "/POST/polls/x/webhooks", expected: true},
+		"creator lists deliveries":         {role: "creator", arn: "/GET/polls/x/webhooks/y/deliveries", expected: true},
+		"creator clones a poll":            {role: "creator", arn: "/POST/polls/x/clone", expected: true},
+		"admin calls an unlisted resource": {role: "admin", arn: "/POST/anything/else", expected: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			claims["roles"] = []interface{}{test.role}
+
+			res, err := authorize(signToken(t, key, claims))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if allowed := isAllowed(res.PolicyDocument, stageArn+test.arn); allowed != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestGetPrincipalGroups(t *testing.T) {
+	claims := validClaims()
+	claims["groups"] = []interface{}{"architects", "a,b", "engineers"}
+
+	principal := getPrincipal(claims)
+	if strings.Join(principal.Groups, " ") != "architects engineers" {
+		t.Errorf("expected the groups without commas, got %v", principal.Groups)
+	}
+
+	t.Setenv("GROUPS_CLAIM", "cognito:groups")
+	claims["cognito:groups"] = []interface{}{"admins"}
+
+	principal = getPrincipal(claims)
+	if strings.Join(principal.Groups, " ") != "admins" {
+		t.Errorf("expected the groups from cognito:groups, got %v", principal.Groups)
+	}
+}
+
+func TestHandlerDeniesUnknownRoles(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	server := newJwksServer(t, key)
+	setup(t, server)
+
+	claims := validClaims()
+	claims["roles"] = []interface{}{"superuser"}
+
+	res, err := authorize(signToken(t, key, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.PolicyDocument.Statement[0].Effect != "Deny" {
+		t.Errorf("expected Deny, got %s", res.PolicyDocument.Statement[0].Effect)
+	}
 }
@@ -3,23 +3,49 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
 )
 
 type RequestBody struct {
 	Value bool `json:"value"`
 }
 
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	CreatedAt    string `dynamodbav:"CreatedAt"`
+	Duration     int    `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+	ClosedAt     string `dynamodbav:"ClosedAt"`
+
+	RetentionDays int `dynamodbav:"RetentionDays"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
 type Error struct {
 	Message string `json:"message"`
 	Cause   string `json:"cause"`
@@ -44,6 +70,131 @@ func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatew
 	return res
 }
 
+// getRetentionDays reads how many days archived polls are kept before they're purged, where 0 keeps them
+func getRetentionDays() (int, error) {
+	retentionDays := os.Getenv("ARCHIVED_POLL_RETENTION_DAYS")
+	if retentionDays == "" {
+		return 0, nil
+	}
+
+	days, err := strconv.Atoi(retentionDays)
+	if err != nil {
+		return 0, err
+	}
+	if days < 0 {
+		return 0, errors.New("ARCHIVED_POLL_RETENTION_DAYS must not be negative")
+	}
+
+	return days, nil
+}
+
+// getOpenUntil returns when the poll expires if unarchiving it opens it again, or the zero time if it was
+// closed already
+func getOpenUntil(ddbPoll DdbPoll) (time.Time, error) {
+	if ddbPoll.ClosedAt != "" {
+		return time.Time{}, nil
+	}
+
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second), nil
+}
+
+// getCloseTtl returns the time to live of a poll that's purged some days after it closes, counted from when it
+// closed or, if unarchiving opens it again, from when it expires. It's 0 for a poll that's kept.
+func getCloseTtl(ddbPoll DdbPoll, openUntil time.Time) (int64, error) {
+	if ddbPoll.RetentionDays == 0 {
+		return 0, nil
+	}
+
+	closesAt := openUntil
+	if ddbPoll.ClosedAt != "" {
+		closedAt, err := time.Parse(RFC3339Milli, ddbPoll.ClosedAt)
+		if err != nil {
+			return 0, err
+		}
+		closesAt = closedAt
+	}
+
+	return pollcreate.CloseTtl(closesAt, ddbPoll.RetentionDays), nil
+}
+
+// newArchivalUpdate archives or unarchives the poll at its next sequence number. Archiving it sets its time to live,
+// after which DynamoDB removes it and the poll deleter cleans up its other items, and unarchiving it keeps it again,
+// unless it's purged after it closes, at closeTtl. Archived polls aren't published or closed, so archiving takes the
+// poll out of the index of open polls, and unarchiving a poll that wasn't closed, openUntil being when it expires,
+// puts it back.
+func newArchivalUpdate(
+	isArchived bool,
+	retentionDays int,
+	openUntil time.Time,
+	closeTtl int64,
+	sequence int64,
+	now time.Time,
+) (string, map[string]string, map[string]types.AttributeValue) {
+	expressionAttributeNames := map[string]string{
+		"#isArchived": "IsArchived",
+		"#version":    "Version",
+	}
+	expressionAttributeValues := map[string]types.AttributeValue{
+		":isArchived": &types.AttributeValueMemberBOOL{
+			Value: isArchived,
+		},
+		":version": &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(sequence, 10),
+		},
+	}
+
+	sets := []string{"#isArchived = :isArchived", "#version = :version"}
+	var removes []string
+
+	if isArchived {
+		expressionAttributeNames["#open"] = "GSI2PK"
+		expressionAttributeNames["#expiresAt"] = "GSI2SK"
+		removes = append(removes, "#open", "#expiresAt")
+
+		if retentionDays > 0 {
+			expressionAttributeNames["#ttl"] = "Ttl"
+			expressionAttributeValues[":ttl"] = &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(now.AddDate(0, 0, retentionDays).Unix(), 10),
+			}
+			sets = append(sets, "#ttl = :ttl")
+		}
+	} else {
+		expressionAttributeNames["#ttl"] = "Ttl"
+		if closeTtl > 0 {
+			expressionAttributeValues[":ttl"] = &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(closeTtl, 10),
+			}
+			sets = append(sets, "#ttl = :ttl")
+		} else {
+			removes = append(removes, "#ttl")
+		}
+
+		if !openUntil.IsZero() {
+			expressionAttributeNames["#open"] = "GSI2PK"
+			expressionAttributeNames["#expiresAt"] = "GSI2SK"
+			expressionAttributeValues[":open"] = &types.AttributeValueMemberS{
+				Value: openpolls.Key,
+			}
+			expressionAttributeValues[":expiresAt"] = &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(openpolls.SortKey(openUntil), 10),
+			}
+			sets = append(sets, "#open = :open", "#expiresAt = :expiresAt")
+		}
+	}
+
+	updateExpression := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	return updateExpression, expressionAttributeNames, expressionAttributeValues
+}
+
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var requestBody RequestBody
 	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
@@ -56,6 +207,17 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
+	retentionDays, err := getRetentionDays()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return logAndReturn(
@@ -69,30 +231,151 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 
 	ddb := dynamodb.NewFromConfig(cfg)
 
-	input := &dynamodb.UpdateItemInput{
+	tableName := os.Getenv("SINGLE_TABLE_NAME")
+	pollId := request.PathParameters["pollId"]
+
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
 		Key: map[string]types.AttributeValue{
 			"PK": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("poll|%s", request.PathParameters["pollId"]),
+				Value: fmt.Sprintf("poll|%s", pollId),
 			},
 			"SK": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("poll|%s", request.PathParameters["pollId"]),
+				Value: fmt.Sprintf("poll|%s", pollId),
 			},
 		},
-		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
-		ConditionExpression: aws.String("#user = :user AND #isArchived <> :isArchived"),
-		UpdateExpression:    aws.String("SET #isArchived = :isArchived"),
-		ExpressionAttributeNames: map[string]string{
-			"#user":       "GSI1PK",
-			"#isArchived": "IsArchived",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":user": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("user|%s", request.RequestContext.Authorizer["sub"].(string)),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
 			},
-			":isArchived": &types.AttributeValueMemberBOOL{
-				Value: requestBody.Value,
+			err,
+		), nil
+	}
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = pollaccess.Authorize(
+		ctx,
+		ddb,
+		tableName,
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		request.RequestContext.Authorizer["sub"].(string),
+		pollaccess.ActionManage,
+	)
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Checked before the poll takes a sequence number, which would otherwise go unpublished
+	if ddbPoll.DeletedAt != "" || ddbPoll.IsArchived == requestBody.Value {
+		err := fmt.Errorf("poll %s is deleted or its archival is already %t", pollId, requestBody.Value)
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	openUntil, err := getOpenUntil(ddbPoll)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	closeTtl, err := getCloseTtl(ddbPoll, openUntil)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	sequence, err := pollsequence.Next(ctx, ddb, tableName, pollId, ddbPoll.Version)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	updateExpression, expressionAttributeNames, expressionAttributeValues := newArchivalUpdate(
+		requestBody.Value,
+		retentionDays,
+		openUntil,
+		closeTtl,
+		sequence,
+		time.Now(),
+	)
+	expressionAttributeNames["#pk"] = "PK"
+	expressionAttributeNames["#deletedAt"] = "DeletedAt"
+
+	input := &dynamodb.UpdateItemInput{
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
 			},
 		},
+		TableName: aws.String(tableName),
+		ConditionExpression: aws.String(
+			"attribute_exists(#pk) AND #isArchived <> :isArchived AND attribute_not_exists(#deletedAt)",
+		),
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
 	}
 
 	_, err = ddb.UpdateItem(ctx, input)
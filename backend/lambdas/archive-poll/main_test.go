@@ -5,15 +5,17 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 func TestHandler(t *testing.T) {
 	ctx := context.Background()
 
 	requestBody, _ := json.Marshal(RequestBody{
-		IsArchived: os.Getenv("TEST_POLL_ARCHIVED") == "true",
+		Value: os.Getenv("TEST_POLL_ARCHIVED") == "true",
 	})
 
 	mockRequest := events.APIGatewayProxyRequest{
@@ -35,3 +37,87 @@ func TestHandler(t *testing.T) {
 
 	t.Log(res)
 }
+
+func TestNewArchivalUpdate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	openUntil := now.Add(time.Hour)
+
+	tests := []struct {
+		isArchived    bool
+		retentionDays int
+		openUntil     time.Time
+		closeTtl      int64
+		expression    string
+		ttl           string
+		expiresAt     string
+	}{
+		{isArchived: true, retentionDays: 30, openUntil: openUntil, expression: "SET #isArchived = :isArchived, #version = :version, #ttl = :ttl REMOVE #open, #expiresAt", ttl: "1706659200"},
+		{isArchived: true, expression: "SET #isArchived = :isArchived, #version = :version REMOVE #open, #expiresAt"},
+		{isArchived: false, retentionDays: 30, expression: "SET #isArchived = :isArchived, #version = :version REMOVE #ttl"},
+		{isArchived: false, openUntil: openUntil, expression: "SET #isArchived = :isArchived, #version = :version, #open = :open, #expiresAt = :expiresAt REMOVE #ttl", expiresAt: "1704070800000"},
+		{isArchived: false, closeTtl: 1704672000, expression: "SET #isArchived = :isArchived, #version = :version, #ttl = :ttl", ttl: "1704672000"},
+	}
+
+	for _, test := range tests {
+		expression, names, values := newArchivalUpdate(test.isArchived, test.retentionDays, test.openUntil, test.closeTtl, 7, now)
+		if expression != test.expression {
+			t.Errorf("expected %q, got %q", test.expression, expression)
+		}
+
+		_, hasTtlName := names["#ttl"]
+		if hasTtlName != (!test.isArchived || test.retentionDays > 0) {
+			t.Errorf("expected #ttl to be named only when it's used, got %v for %q", names, expression)
+		}
+
+		ttl, _ := values[":ttl"].(*types.AttributeValueMemberN)
+		if (ttl == nil && test.ttl != "") || (ttl != nil && ttl.Value != test.ttl) {
+			t.Errorf("expected the time to live %q, got %+v", test.ttl, ttl)
+		}
+
+		expiresAt, _ := values[":expiresAt"].(*types.AttributeValueMemberN)
+		if (expiresAt == nil && test.expiresAt != "") || (expiresAt != nil && expiresAt.Value != test.expiresAt) {
+			t.Errorf("expected the poll to be open until %q, got %+v", test.expiresAt, expiresAt)
+		}
+
+		if version := values[":version"].(*types.AttributeValueMemberN).Value; version != "7" {
+			t.Errorf("expected the poll to move to its sequence number, got %s", version)
+		}
+	}
+}
+
+func TestGetOpenUntil(t *testing.T) {
+	openUntil, err := getOpenUntil(DdbPoll{CreatedAt: "2024-01-01T00:00:00Z", Duration: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openUntil.Equal(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the poll to be open until an hour after it was created, got %s", openUntil)
+	}
+
+	openUntil, err = getOpenUntil(DdbPoll{CreatedAt: "2024-01-01T00:00:00Z", Duration: 3600, ClosedAt: "2024-01-01T01:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openUntil.IsZero() {
+		t.Errorf("expected a closed poll not to be opened again, got %s", openUntil)
+	}
+}
+
+func TestGetCloseTtl(t *testing.T) {
+	openUntil := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	if ttl, err := getCloseTtl(DdbPoll{}, openUntil); err != nil || ttl != 0 {
+		t.Errorf("expected a poll that's kept not to have a time to live, got %d (%v)", ttl, err)
+	}
+
+	// 7 days after the poll expires
+	if ttl, err := getCloseTtl(DdbPoll{RetentionDays: 7}, openUntil); err != nil || ttl != 1704758400 {
+		t.Errorf("expected the time to live to count from when the poll expires, got %d (%v)", ttl, err)
+	}
+
+	// 7 days after the poll closed
+	ttl, err := getCloseTtl(DdbPoll{RetentionDays: 7, ClosedAt: "2024-01-01T00:00:00Z"}, time.Time{})
+	if err != nil || ttl != 1704672000 {
+		t.Errorf("expected the time to live to count from when the poll closed, got %d (%v)", ttl, err)
+	}
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+)
+
+type RequestBody struct {
+	OptionId string `json:"optionId"`
+}
+
+type DdbPoll struct {
+	PkPollId         string `dynamodbav:"PK"`
+	Gsi1PkUserId     string `dynamodbav:"GSI1PK"`
+	CreatedAt        string `dynamodbav:"CreatedAt"`
+	Duration         int    `dynamodbav:"Duration"`
+	Version          int64  `dynamodbav:"Version"`
+	DeletedAt        string `dynamodbav:"DeletedAt"`
+	TieBreak         string `dynamodbav:"TieBreak"`
+	TieBreakOptionId string `dynamodbav:"TieBreakOptionId"`
+
+	Quorum    int                 `dynamodbav:"Quorum"`
+	Threshold *decision.Threshold `dynamodbav:"Threshold"`
+}
+
+type DdbOption struct {
+	PkOptionId    string `dynamodbav:"PK"`
+	Votes         int    `dynamodbav:"Votes"`
+	Ballots       *int   `dynamodbav:"Ballots"`
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+type TieBreak struct {
+	PollId   string           `json:"pollId"`
+	OptionId string           `json:"optionId"`
+	Outcome  decision.Outcome `json:"outcome"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var (
+	errNotOwnerTieBreak = errors.New("poll's ties aren't broken by its owner")
+	errPollOpen         = errors.New("poll is still open")
+	errAlreadyBroken    = errors.New("poll's tie was already broken")
+	errNoTie            = errors.New("poll has no tie to break")
+	errNotTied          = errors.New("option isn't tied for the lead")
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// checkPoll checks the poll's tie can be broken by its owner. Votes can still change the lead while the
+// poll is open, and the owner's pick is final, so a tie is only broken once, after the poll closes.
+func checkPoll(ddbPoll DdbPoll, now time.Time) error {
+	if ddbPoll.TieBreak != decision.TieBreakOwner {
+		return errNotOwnerTieBreak
+	}
+	if ddbPoll.TieBreakOptionId != "" {
+		return errAlreadyBroken
+	}
+
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if now.Before(createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second)) {
+		return errPollOpen
+	}
+
+	return nil
+}
+
+// getBallots returns how many votes the option got, counting each once. Options whose votes were counted
+// before votes were weighed don't have ballots, but each of their votes counted once.
+func getBallots(ddbOption DdbOption) int {
+	if ddbOption.Ballots == nil {
+		return ddbOption.Votes
+	}
+
+	return *ddbOption.Ballots
+}
+
+// getTallies counts the options shown to voters. Options held for moderation or rejected aren't counted,
+// so the tie is the one voters are shown.
+func getTallies(ddbOptions []DdbOption) []decision.Tally {
+	var tallies []decision.Tally
+	for _, ddbOption := range ddbOptions {
+		if !pollcreate.IsShown(ddbOption.WriteInStatus) {
+			continue
+		}
+
+		tallies = append(tallies, decision.Tally{
+			OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+			Votes:    int64(ddbOption.Votes),
+			Ballots:  int64(getBallots(ddbOption)),
+		})
+	}
+
+	return tallies
+}
+
+// checkTie checks the option shares the lead with others
+func checkTie(ddbOptions []DdbOption, optionId string) error {
+	tallies := getTallies(ddbOptions)
+
+	var totalVotes int64
+	for _, tally := range tallies {
+		totalVotes += tally.Votes
+	}
+
+	leading := decision.Leading(tallies)
+	if totalVotes == 0 || len(leading) < 2 {
+		return errNoTie
+	}
+
+	for _, leadingOptionId := range leading {
+		if leadingOptionId == optionId {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errNotTied, optionId)
+}
+
+// decide decides the poll again with the owner's pick, so its outcome names the winner the same way
+// closing the poll would have
+func decide(ddbPoll DdbPoll, ddbOptions []DdbOption, optionId string) decision.Outcome {
+	return decision.Decide(decision.Rules{
+		Quorum:           ddbPoll.Quorum,
+		Threshold:        ddbPoll.Threshold,
+		TieBreak:         decision.TieBreakOwner,
+		TieBreakOptionId: optionId,
+	}, getTallies(ddbOptions))
+}
+
+// newTieBreakUpdate records the pick along with the outcome it decides, and moves the poll to the sequence
+// number taken for it so subscribers learn the winner in order. Votes aren't counted once the poll closes,
+// so the tie can only have changed if the poll was extended, which moves its version.
+func newTieBreakUpdate(
+	tableName string,
+	ddbPoll DdbPoll,
+	optionId string,
+	outcome decision.Outcome,
+	sequence int64,
+) (*dynamodb.UpdateItemInput, error) {
+	outcomeValue, err := attributevalue.Marshal(outcome)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: ddbPoll.PkPollId,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: ddbPoll.PkPollId,
+			},
+		},
+		UpdateExpression: aws.String(
+			"SET #tieBreakOptionId = :optionId, #outcome = :outcome, #version = :version",
+		),
+		ConditionExpression: aws.String(
+			"attribute_exists(#pk) AND attribute_not_exists(#deletedAt) AND " +
+				"attribute_not_exists(#tieBreakOptionId) AND " +
+				"(attribute_not_exists(#version) OR #version = :currentVersion)",
+		),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":               "PK",
+			"#deletedAt":        "DeletedAt",
+			"#tieBreakOptionId": "TieBreakOptionId",
+			"#outcome":          "Outcome",
+			"#version":          "Version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":optionId": &types.AttributeValueMemberS{
+				Value: optionId,
+			},
+			":outcome": outcomeValue,
+			":currentVersion": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(ddbPoll.Version, 10),
+			},
+			":version": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(sequence, 10),
+			},
+		},
+	}, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	now := time.Now()
+	userId := request.RequestContext.Authorizer["sub"].(string)
+	tableName := os.Getenv("SINGLE_TABLE_NAME")
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	pollId := request.PathParameters["pollId"]
+	pollKey := fmt.Sprintf("poll|%s", pollId)
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: pollKey,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: pollKey,
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	role, err := pollaccess.Authorize(
+		ctx,
+		ddb,
+		tableName,
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		userId,
+		pollaccess.ActionManage,
+	)
+	// A poll the user isn't a member of is reported as not found, so poll ids can't be probed
+	if errors.Is(err, pollaccess.ErrForbidden) && role == "" {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	err = checkPoll(ddbPoll, now)
+	if errors.Is(err, errNotOwnerTieBreak) || errors.Is(err, errPollOpen) || errors.Is(err, errAlreadyBroken) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: pollKey,
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	err = checkTie(ddbOptions, requestBody.OptionId)
+	if errors.Is(err, errNoTie) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	outcome := decide(ddbPoll, ddbOptions, requestBody.OptionId)
+
+	sequence, err := pollsequence.Next(ctx, ddb, tableName, pollId, ddbPoll.Version)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	input, err := newTieBreakUpdate(tableName, ddbPoll, requestBody.OptionId, outcome, sequence)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.UpdateItem(ctx, input)
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		err := errors.New("poll was modified, try again")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	tieBreak, err := json.Marshal(TieBreak{
+		PollId:   pollId,
+		OptionId: requestBody.OptionId,
+		Outcome:  outcome,
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(tieBreak),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+)
+
+func TestCheckPoll(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	createdAt := now.Add(-2 * time.Hour).Format(RFC3339Milli)
+
+	tests := map[string]struct {
+		ddbPoll DdbPoll
+		err     error
+	}{
+		"closed": {
+			ddbPoll: DdbPoll{TieBreak: decision.TieBreakOwner, CreatedAt: createdAt, Duration: 3600},
+		},
+		"open": {
+			ddbPoll: DdbPoll{TieBreak: decision.TieBreakOwner, CreatedAt: createdAt, Duration: 3 * 3600},
+			err:     errPollOpen,
+		},
+		"earliest": {
+			ddbPoll: DdbPoll{TieBreak: decision.TieBreakEarliest, CreatedAt: createdAt, Duration: 3600},
+			err:     errNotOwnerTieBreak,
+		},
+		"no policy": {
+			ddbPoll: DdbPoll{CreatedAt: createdAt, Duration: 3600},
+			err:     errNotOwnerTieBreak,
+		},
+		"already broken": {
+			ddbPoll: DdbPoll{TieBreak: decision.TieBreakOwner, TieBreakOptionId: "option1", CreatedAt: createdAt, Duration: 3600},
+			err:     errAlreadyBroken,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkPoll(test.ddbPoll, now)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckTie(t *testing.T) {
+	tied := []DdbOption{
+		{PkOptionId: "option|option1", Votes: 3},
+		{PkOptionId: "option|option2", Votes: 3},
+		{PkOptionId: "option|option3", Votes: 1},
+	}
+
+	tests := map[string]struct {
+		ddbOptions []DdbOption
+		optionId   string
+		err        error
+	}{
+		"tied":     {ddbOptions: tied, optionId: "option2"},
+		"trailing": {ddbOptions: tied, optionId: "option3", err: errNotTied},
+		"unknown":  {ddbOptions: tied, optionId: "option4", err: errNotTied},
+		"no tie": {
+			ddbOptions: []DdbOption{
+				{PkOptionId: "option|option1", Votes: 3},
+				{PkOptionId: "option|option2", Votes: 2},
+			},
+			optionId: "option1",
+			err:      errNoTie,
+		},
+		"no votes": {
+			ddbOptions: []DdbOption{
+				{PkOptionId: "option|option1"},
+				{PkOptionId: "option|option2"},
+			},
+			optionId: "option1",
+			err:      errNoTie,
+		},
+		"pending write-in": {
+			ddbOptions: []DdbOption{
+				{PkOptionId: "option|option1", Votes: 3},
+				{PkOptionId: "option|option2", Votes: 3, WriteInStatus: pollcreate.WriteInStatusPending},
+			},
+			optionId: "option1",
+			err:      errNoTie,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkTie(test.ddbOptions, test.optionId)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNewTieBreakUpdate(t *testing.T) {
+	ddbPoll := DdbPoll{PkPollId: "poll|poll123", TieBreak: decision.TieBreakOwner, Version: 7}
+	ballots := 2
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option1", Votes: 3},
+		{PkOptionId: "option|option2", Votes: 3, Ballots: &ballots},
+		{PkOptionId: "option|option3", Votes: 1},
+	}
+
+	outcome := decide(ddbPoll, ddbOptions, "option2")
+
+	expected := decision.Outcome{
+		Result:        decision.ResultPassed,
+		OptionId:      "option2",
+		TiedOptionIds: []string{"option1", "option2"},
+		Ballots:       6,
+		Votes:         7,
+	}
+	if !reflect.DeepEqual(outcome, expected) {
+		t.Errorf("expected %+v, got %+v", expected, outcome)
+	}
+
+	input, err := newTieBreakUpdate("table", ddbPoll, "option2", outcome, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expression := aws.ToString(input.UpdateExpression); expression != "SET #tieBreakOptionId = :optionId, #outcome = :outcome, #version = :version" {
+		t.Errorf("expected the pick, outcome and version to be set together, got %s", expression)
+	}
+
+	values := input.ExpressionAttributeValues
+	if version := values[":version"].(*types.AttributeValueMemberN).Value; version != "12" {
+		t.Errorf("expected the poll to move to sequence 12, got %s", version)
+	}
+	if version := values[":currentVersion"].(*types.AttributeValueMemberN).Value; version != "7" {
+		t.Errorf("expected the pick to be conditioned on version 7, got %s", version)
+	}
+
+	result := values[":outcome"].(*types.AttributeValueMemberM).Value["Result"].(*types.AttributeValueMemberS).Value
+	if result != decision.ResultPassed {
+		t.Errorf("expected the outcome to pass, got %s", result)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/declanlscott/pseudopoll/backend/shared/chat"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+)
+
+type CommandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// VoteMessageBody is the vote queue's message, as the API's vote integration sends it.
+type VoteMessageBody struct {
+	OptionId         string `json:"optionId"`
+	PollId           string `json:"pollId"`
+	UserId           string `json:"userId"`
+	RequestTimeEpoch string `json:"requestTimeEpoch"`
+	RequestId        string `json:"requestId"`
+}
+
+type DdbChatMessage struct {
+	PkPollId  string `dynamodbav:"PK"`
+	SkMessage string `dynamodbav:"SK"`
+	Channel   string `dynamodbav:"Channel"`
+	Ts        string `dynamodbav:"Ts"`
+	CreatedAt string `dynamodbav:"CreatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	DefaultPollDuration = 24 * 60 * 60
+
+	usage = "Usage: /poll \"Question\" \"Option 1\" \"Option 2\""
+)
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func getPollDuration() (int, error) {
+	duration := os.Getenv("CHAT_POLL_DURATION")
+	if duration == "" {
+		return DefaultPollDuration, nil
+	}
+
+	return strconv.Atoi(duration)
+}
+
+// getRetentionDays reads how many days after they close that chat polls are purged, where 0 keeps them.
+// Chat polls are owned by chat users without an account, so no one could archive or delete them.
+func getRetentionDays() (int, error) {
+	retentionDays := os.Getenv("CHAT_POLL_RETENTION_DAYS")
+	if retentionDays == "" {
+		return 0, nil
+	}
+
+	days, err := strconv.Atoi(retentionDays)
+	if err != nil {
+		return 0, err
+	}
+	if days < 0 {
+		return 0, errors.New("CHAT_POLL_RETENTION_DAYS must not be negative")
+	}
+
+	return days, nil
+}
+
+// getHeader looks up a header case-insensitively, since clients and proxies don't agree on its case.
+func getHeader(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func getBody(request events.APIGatewayProxyRequest) ([]byte, error) {
+	if request.IsBase64Encoded {
+		return base64.StdEncoding.DecodeString(request.Body)
+	}
+
+	return []byte(request.Body), nil
+}
+
+func newChatPoll(poll pollcreate.Poll) chat.Poll {
+	options := make([]chat.Option, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		options = append(options, chat.Option{
+			OptionId: option.OptionId,
+			Text:     option.Text,
+			Votes:    option.Votes,
+		})
+	}
+
+	return chat.Poll{
+		PollId:   poll.PollId,
+		Prompt:   poll.Prompt,
+		Options:  options,
+		IsClosed: poll.IsArchived,
+	}
+}
+
+func newVoteMessageBody(payload chat.InteractionPayload, vote chat.Vote, request events.APIGatewayProxyRequest) VoteMessageBody {
+	return VoteMessageBody{
+		OptionId:         vote.OptionId,
+		PollId:           vote.PollId,
+		UserId:           chat.UserId(payload.Team.Id, payload.User.Id),
+		RequestTimeEpoch: strconv.FormatInt(request.RequestContext.RequestTimeEpoch, 10),
+		RequestId:        request.RequestContext.RequestID,
+	}
+}
+
+// reply answers a slash command with a message that only its user sees.
+func reply(text string) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(CommandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+	})
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}
+}
+
+func handleCommand(ctx context.Context, form url.Values, cfg aws.Config) (events.APIGatewayProxyResponse, error) {
+	args, err := chat.ParseArgs(form.Get("text"))
+	if err != nil {
+		return reply(fmt.Sprintf("%s. %s", err, usage)), nil
+	}
+	if len(args) == 0 {
+		return reply(usage), nil
+	}
+
+	duration, err := getPollDuration()
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	retentionDays, err := getRetentionDays()
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	limits, err := pollcreate.GetLimits()
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	input := pollcreate.Input{
+		UserId:        chat.UserId(form.Get("team_id"), form.Get("user_id")),
+		Prompt:        args[0],
+		Options:       args[1:],
+		Duration:      duration,
+		RetentionDays: retentionDays,
+	}
+	if err := limits.Validate(input); err != nil {
+		return reply(fmt.Sprintf("Sorry, %s. %s", err, usage)), nil
+	}
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	now := time.Now()
+	poll, err := pollcreate.Create(ctx, ddb, os.Getenv("SINGLE_TABLE_NAME"), nanoIdOptions, input, now)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	client := chat.Client{Token: os.Getenv("CHAT_BOT_TOKEN")}
+	message, err := client.PostMessage(ctx, form.Get("channel_id"), newChatPoll(poll))
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return reply(fmt.Sprintf("Created poll %s, but couldn't post it to this channel: %s", poll.PollId, err)), nil
+	}
+
+	// The message is kept with the poll so it can be updated as votes are counted
+	item, err := attributevalue.MarshalMap(DdbChatMessage{
+		PkPollId:  fmt.Sprintf("poll|%s", poll.PollId),
+		SkMessage: fmt.Sprintf("chatmessage|%s|%s", message.Channel, message.Ts),
+		Channel:   message.Channel,
+		Ts:        message.Ts,
+		CreatedAt: now.UTC().Format(pollcreate.RFC3339Milli),
+	})
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:      item,
+	})
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	return reply(fmt.Sprintf("Created poll %s", poll.PollId)), nil
+}
+
+func handleInteraction(
+	ctx context.Context,
+	form url.Values,
+	request events.APIGatewayProxyRequest,
+	cfg aws.Config,
+) (events.APIGatewayProxyResponse, error) {
+	var payload chat.InteractionPayload
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       formatError("Bad request", err),
+		}, nil
+	}
+
+	sqsClient := sqs.NewFromConfig(cfg)
+
+	// Votes are counted by the vote queue's consumer, and the poll's messages are updated once they are
+	for _, vote := range payload.Votes() {
+		messageBody, err := json.Marshal(newVoteMessageBody(payload, vote, request))
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+
+		_, err = sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(os.Getenv("VOTE_QUEUE_URL")),
+			MessageBody: aws.String(string(messageBody)),
+		})
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	body, err := getBody(request)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	err = chat.Verify(
+		os.Getenv("CHAT_SIGNING_SECRET"),
+		getHeader(request.Headers, chat.TimestampHeader),
+		getHeader(request.Headers, chat.SignatureHeader),
+		body,
+		time.Now(),
+	)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusUnauthorized,
+				Body:       formatError("Unauthorized", err),
+			},
+			err,
+		), nil
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var res events.APIGatewayProxyResponse
+	switch request.Resource {
+	case "/chat/commands":
+		res, err = handleCommand(ctx, form, cfg)
+	case "/chat/interactions":
+		res, err = handleInteraction(ctx, form, request, cfg)
+	default:
+		err = errors.New(fmt.Sprintf("unexpected resource %s", request.Resource))
+		res = events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotFound,
+			Body:       formatError("Not found", err),
+		}
+	}
+	if err != nil && res.StatusCode == 0 {
+		res = events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       formatError("Internal server error", err),
+		}
+	}
+
+	return logAndReturn(res, err), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/declanlscott/pseudopoll/backend/shared/chat"
+)
+
+func TestGetHeader(t *testing.T) {
+	headers := map[string]string{"x-slack-signature": "v0=abc"}
+
+	if value := getHeader(headers, chat.SignatureHeader); value != "v0=abc" {
+		t.Errorf("expected the header regardless of case, got %q", value)
+	}
+	if value := getHeader(headers, chat.TimestampHeader); value != "" {
+		t.Errorf("expected a missing header to be empty, got %q", value)
+	}
+}
+
+func TestGetBody(t *testing.T) {
+	body := "text=%22Ship+it%3F%22+Yes+No"
+
+	for name, request := range map[string]events.APIGatewayProxyRequest{
+		"plain":   {Body: body},
+		"encoded": {Body: base64.StdEncoding.EncodeToString([]byte(body)), IsBase64Encoded: true},
+	} {
+		decoded, err := getBody(request)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if string(decoded) != body {
+			t.Errorf("%s: expected %q, got %q", name, body, decoded)
+		}
+	}
+}
+
+func TestNewVoteMessageBody(t *testing.T) {
+	var payload chat.InteractionPayload
+	payload.Team.Id = "T1"
+	payload.User.Id = "U1"
+
+	request := events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:        "request123",
+			RequestTimeEpoch: 1700000000000,
+		},
+	}
+
+	messageBody := newVoteMessageBody(payload, chat.Vote{PollId: "poll123", OptionId: "option1"}, request)
+
+	expected := VoteMessageBody{
+		OptionId:         "option1",
+		PollId:           "poll123",
+		UserId:           "chat:T1:U1",
+		RequestTimeEpoch: "1700000000000",
+		RequestId:        "request123",
+	}
+	if messageBody != expected {
+		t.Errorf("expected %+v, got %+v", expected, messageBody)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/chat"
+)
+
+type VoteCountedDetail struct {
+	DynamoDb struct {
+		NewImage struct {
+			PollId struct {
+				S string `json:"S"`
+			} `json:"GSI1PK"`
+		} `json:"NewImage"`
+	} `json:"dynamodb"`
+}
+
+type DdbPoll struct {
+	PkPollId   string `dynamodbav:"PK"`
+	SkPollId   string `dynamodbav:"SK"`
+	Prompt     string `dynamodbav:"Prompt"`
+	CreatedAt  string `dynamodbav:"CreatedAt"`
+	Duration   int64  `dynamodbav:"Duration"`
+	IsArchived bool   `dynamodbav:"IsArchived"`
+}
+
+type DdbOption struct {
+	PkOptionId string `dynamodbav:"PK"`
+	Index      int    `dynamodbav:"Index"`
+	Text       string `dynamodbav:"Text"`
+	Votes      int    `dynamodbav:"Votes"`
+}
+
+type DdbChatMessage struct {
+	PkPollId  string `dynamodbav:"PK"`
+	SkMessage string `dynamodbav:"SK"`
+	Channel   string `dynamodbav:"Channel"`
+	Ts        string `dynamodbav:"Ts"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var errPollNotFound = errors.New("poll not found")
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func getChatMessages(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]chat.Message, error) {
+	result, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :pk AND begins_with(#sk, :sk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			":sk": &types.AttributeValueMemberS{
+				Value: "chatmessage|",
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ddbChatMessages []DdbChatMessage
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &ddbChatMessages); err != nil {
+		return nil, err
+	}
+
+	messages := make([]chat.Message, 0, len(ddbChatMessages))
+	for _, ddbChatMessage := range ddbChatMessages {
+		messages = append(messages, chat.Message{
+			Channel: ddbChatMessage.Channel,
+			Ts:      ddbChatMessage.Ts,
+		})
+	}
+
+	return messages, nil
+}
+
+// getPoll reads the poll's current state rather than the counted vote's, so an update that arrives
+// late still shows the latest counts
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string, now time.Time) (chat.Poll, error) {
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return chat.Poll{}, err
+	}
+	if pollResult.Item == nil {
+		return chat.Poll{}, errPollNotFound
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return chat.Poll{}, err
+	}
+
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return chat.Poll{}, err
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return chat.Poll{}, err
+	}
+
+	return newChatPoll(ddbPoll, ddbOptions, now)
+}
+
+func newChatPoll(ddbPoll DdbPoll, ddbOptions []DdbOption, now time.Time) (chat.Poll, error) {
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return chat.Poll{}, err
+	}
+
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	options := make([]chat.Option, 0, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		options = append(options, chat.Option{
+			OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:     ddbOption.Text,
+			Votes:    ddbOption.Votes,
+		})
+	}
+
+	return chat.Poll{
+		PollId:   stripPrefix(ddbPoll.PkPollId, "poll|"),
+		Prompt:   ddbPoll.Prompt,
+		Options:  options,
+		IsClosed: ddbPoll.IsArchived || !now.Before(createdAt.Add(time.Duration(ddbPoll.Duration)*time.Second)),
+	}, nil
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) {
+	log.Printf("Processing event: %s\n", event)
+
+	if event.Source != os.Getenv("DDB_STREAM_SOURCE") || event.DetailType != os.Getenv("DDB_STREAM_DETAIL_TYPE") {
+		log.Printf("Unknown event source or detail type: %s, %s\n", event.Source, event.DetailType)
+		return
+	}
+
+	var detail VoteCountedDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	pollId := stripPrefix(detail.DynamoDb.NewImage.PollId.S, "poll|")
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	// Most polls weren't created from chat, so their messages are looked up before the poll is read
+	messages, err := getChatMessages(ctx, ddb, pollId)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	poll, err := getPoll(ctx, ddb, pollId, time.Now())
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	client := chat.Client{Token: os.Getenv("CHAT_BOT_TOKEN")}
+	for _, message := range messages {
+		if err := client.UpdateMessage(ctx, message, poll); err != nil {
+			log.Printf("Error: %s\n", err)
+			continue
+		}
+
+		log.Printf("Updated message %s in channel %s for poll %s\n", message.Ts, message.Channel, pollId)
+	}
+}
+
+func main() {
+	lambda.Start(handler)
+}
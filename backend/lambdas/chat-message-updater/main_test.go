@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestVoteCountedDetail(t *testing.T) {
+	var detail VoteCountedDetail
+	err := json.Unmarshal([]byte(`{
+		"eventName": "MODIFY",
+		"dynamodb": {
+			"Keys": {"PK": {"S": "option|option1"}, "SK": {"S": "option|option1"}},
+			"NewImage": {"PK": {"S": "option|option1"}, "GSI1PK": {"S": "poll|poll123"}, "Votes": {"N": "3"}}
+		}
+	}`), &detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pollId := stripPrefix(detail.DynamoDb.NewImage.PollId.S, "poll|"); pollId != "poll123" {
+		t.Errorf("expected poll123, got %s", pollId)
+	}
+}
+
+func TestNewChatPoll(t *testing.T) {
+	ddbPoll := DdbPoll{
+		PkPollId:  "poll|poll123",
+		SkPollId:  "poll|poll123",
+		Prompt:    "Ship it?",
+		CreatedAt: "2024-01-01T00:00:00.000Z",
+		Duration:  3600,
+	}
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option2", Index: 1, Text: "No", Votes: 1},
+		{PkOptionId: "option|option1", Index: 0, Text: "Yes", Votes: 2},
+	}
+
+	poll, err := newChatPoll(ddbPoll, ddbOptions, time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.PollId != "poll123" || poll.IsClosed {
+		t.Errorf("unexpected poll %+v", poll)
+	}
+	if poll.Options[0].OptionId != "option1" || poll.Options[0].Votes != 2 || poll.Options[1].Text != "No" {
+		t.Errorf("expected options in index order, got %+v", poll.Options)
+	}
+
+	poll, err = newChatPoll(ddbPoll, ddbOptions, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !poll.IsClosed {
+		t.Error("expected an expired poll to be closed")
+	}
+
+	ddbPoll.IsArchived = true
+	poll, err = newChatPoll(ddbPoll, ddbOptions, time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !poll.IsClosed {
+		t.Error("expected an archived poll to be closed")
+	}
+}
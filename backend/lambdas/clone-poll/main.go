@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+)
+
+// RequestBody is optional; a poll that was closed early or shortened can be cloned with a new duration
+type RequestBody struct {
+	Duration int `json:"duration"`
+}
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Prompt       string `dynamodbav:"Prompt"`
+	Duration     int    `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+
+	AllowWriteIns    bool `dynamodbav:"AllowWriteIns"`
+	ModerateWriteIns bool `dynamodbav:"ModerateWriteIns"`
+
+	VoteWeights *pollcreate.VoteWeights `dynamodbav:"VoteWeights"`
+
+	Quorum    int                 `dynamodbav:"Quorum"`
+	Threshold *decision.Threshold `dynamodbav:"Threshold"`
+	TieBreak  string              `dynamodbav:"TieBreak"`
+}
+
+type DdbOption struct {
+	PkOptionId  string `dynamodbav:"PK"`
+	Index       int    `dynamodbav:"Index"`
+	Text        string `dynamodbav:"Text"`
+	Description string `dynamodbav:"Description"`
+	Url         string `dynamodbav:"Url"`
+	ImageUrl    string `dynamodbav:"ImageUrl"`
+
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// isCreator checks the caller's roles, since the voter role's routes also cover cloning a poll
+func isCreator(roles string) bool {
+	return slices.Contains(strings.Split(roles, ","), "creator")
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (*DdbPoll, error) {
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pollResult.Item == nil {
+		return nil, nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return nil, err
+	}
+
+	return &ddbPoll, nil
+}
+
+func getOptions(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbOption, error) {
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return nil, err
+	}
+
+	return ddbOptions, nil
+}
+
+// newInput copies the poll's prompt, options in their order with their content and duration, but none of
+// its votes. The clone's options show the same images as the poll's. Write-ins that voters can see are
+// copied as the clone's own options.
+func newInput(userId string, ddbPoll DdbPoll, ddbOptions []DdbOption, requestBody RequestBody) pollcreate.Input {
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	options := make([]string, 0, len(ddbOptions))
+	optionContents := make([]pollcreate.OptionContent, 0, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		if strings.HasPrefix(ddbOption.PkOptionId, "option|") && pollcreate.IsShown(ddbOption.WriteInStatus) {
+			options = append(options, ddbOption.Text)
+			optionContents = append(optionContents, pollcreate.OptionContent{
+				Description: ddbOption.Description,
+				Url:         ddbOption.Url,
+				ImageUrl:    ddbOption.ImageUrl,
+			})
+		}
+	}
+
+	duration := ddbPoll.Duration
+	if requestBody.Duration > 0 {
+		duration = requestBody.Duration
+	}
+
+	return pollcreate.Input{
+		UserId:         userId,
+		Prompt:         ddbPoll.Prompt,
+		Options:        options,
+		OptionContents: optionContents,
+		Duration:       duration,
+
+		AllowWriteIns:    ddbPoll.AllowWriteIns,
+		ModerateWriteIns: ddbPoll.ModerateWriteIns,
+		VoteWeights:      ddbPoll.VoteWeights,
+		Quorum:           ddbPoll.Quorum,
+		Threshold:        ddbPoll.Threshold,
+		TieBreak:         ddbPoll.TieBreak,
+	}
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollId := request.PathParameters["pollId"]
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	roles, _ := request.RequestContext.Authorizer["roles"].(string)
+	if !isCreator(roles) {
+		err := errors.New("only creators can clone polls")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+
+	var requestBody RequestBody
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Body:       formatError("Bad request", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	limits, err := pollcreate.GetLimits()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbPoll, err := getPoll(ctx, ddb, pollId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if ddbPoll == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	// Archived polls are only visible to their owner and members, so only they can clone them
+	if ddbPoll.IsArchived {
+		_, err := pollaccess.Authorize(
+			ctx,
+			ddb,
+			os.Getenv("SINGLE_TABLE_NAME"),
+			pollId,
+			ddbPoll.Gsi1PkUserId,
+			userId,
+			pollaccess.ActionView,
+		)
+		if errors.Is(err, pollaccess.ErrForbidden) {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusForbidden,
+					Body:       formatError("Forbidden", err),
+				},
+				err,
+			), nil
+		}
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	ddbOptions, err := getOptions(ctx, ddb, pollId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	input := newInput(userId, *ddbPoll, ddbOptions, requestBody)
+
+	// The poll's duration may have been changed since it was created, so the copy is checked again
+	if err := limits.Validate(input); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	createdPoll, err := pollcreate.Create(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		nanoIdOptions,
+		input,
+		time.Now(),
+	)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	poll, err := json.Marshal(createdPoll)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Body:       string(poll),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
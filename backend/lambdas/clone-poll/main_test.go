@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsCreator(t *testing.T) {
+	if !isCreator("viewer,voter,creator") || !isCreator("viewer,voter,creator,admin") {
+		t.Error("expected creators and admins to be able to clone polls")
+	}
+	if isCreator("viewer,voter") || isCreator("") {
+		t.Error("expected voters to be unable to clone polls")
+	}
+}
+
+func TestNewInput(t *testing.T) {
+	ddbPoll := DdbPoll{PkPollId: "poll|poll123", Gsi1PkUserId: "user|owner", Prompt: "Lunch?", Duration: 600}
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option2", Index: 1, Text: "Pho"},
+		{PkOptionId: "option|option1", Index: 0, Text: "Tacos", Description: "Al pastor", ImageUrl: "https://images.example.com/options/image1"},
+	}
+
+	input := newInput("user123", ddbPoll, ddbOptions, RequestBody{})
+	if input.UserId != "user123" || input.Prompt != "Lunch?" || input.Duration != 600 {
+		t.Errorf("expected a copy of the poll owned by the caller, got %+v", input)
+	}
+	if !reflect.DeepEqual(input.Options, []string{"Tacos", "Pho"}) {
+		t.Errorf("expected the options in their order, got %v", input.Options)
+	}
+	if input.OptionContents[0].Description != "Al pastor" || input.OptionContents[0].ImageUrl == "" || input.OptionContents[1].Description != "" {
+		t.Errorf("expected the options' contents to be copied with them, got %+v", input.OptionContents)
+	}
+
+	if input := newInput("user123", ddbPoll, ddbOptions, RequestBody{Duration: 3600}); input.Duration != 3600 {
+		t.Errorf("expected the requested duration, got %d", input.Duration)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+type RequestBody struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+type NanoIdOptions struct {
+	Alphabet string
+	Length   int
+}
+
+type DdbApiKey struct {
+	PkKeyHash      string   `dynamodbav:"PK"`
+	SkKeyHash      string   `dynamodbav:"SK"`
+	Gsi1PkUserId   string   `dynamodbav:"GSI1PK"`
+	Gsi1SkApiKeyId string   `dynamodbav:"GSI1SK"`
+	Name           string   `dynamodbav:"Name"`
+	CreatedAt      string   `dynamodbav:"CreatedAt"`
+	Roles          []string `dynamodbav:"Roles,omitempty"`
+}
+
+type ApiKey struct {
+	ApiKeyId  string   `json:"apiKeyId"`
+	Name      string   `json:"name"`
+	CreatedAt string   `json:"createdAt"`
+	Roles     []string `json:"roles"`
+	Key       string   `json:"key"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+	ApiKeyPrefix = "pp_"
+)
+
+func getNanoIdOptions() (NanoIdOptions, error) {
+	alphabet := os.Getenv("NANOID_ALPHABET")
+	length, err := strconv.Atoi(os.Getenv("NANOID_LENGTH"))
+	if err != nil {
+		return NanoIdOptions{}, err
+	}
+	if !(length > 2 && length < 36) {
+		return NanoIdOptions{}, errors.New("NANOID_LENGTH must be between 2 and 36")
+	}
+	return NanoIdOptions{
+		Alphabet: alphabet,
+		Length:   length,
+	}, nil
+}
+
+// getRoles defaults an API key to the caller's roles, and rejects roles the caller doesn't hold
+func getRoles(requestedRoles []string, callerRoles string) ([]string, error) {
+	if callerRoles == "" {
+		return nil, errors.New("caller has no roles")
+	}
+
+	roles := strings.Split(callerRoles, ",")
+	if len(requestedRoles) == 0 {
+		return roles, nil
+	}
+
+	for _, requestedRole := range requestedRoles {
+		if !slices.Contains(roles, requestedRole) {
+			return nil, fmt.Errorf("role %s exceeds the caller's roles", requestedRole)
+		}
+	}
+
+	return requestedRoles, nil
+}
+
+// generateApiKey returns a random API key and the hash it is stored under; the key itself is never persisted
+func generateApiKey() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	apiKey := ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	hash := sha256.Sum256([]byte(apiKey))
+
+	return apiKey, hex.EncodeToString(hash[:]), nil
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	// The response body holds the plaintext key, so only the status is logged
+	log.Printf("Response: %d", res.StatusCode)
+
+	return res
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	currentTime := time.Now().UTC().Format(RFC3339Milli)
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	if requestBody.Name == "" {
+		err := errors.New("name must not be empty")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	callerRoles, _ := request.RequestContext.Authorizer["roles"].(string)
+	roles, err := getRoles(requestBody.Roles, callerRoles)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	nanoIdOptions, err := getNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	apiKeyId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	apiKey, keyHash, err := generateApiKey()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbApiKey := DdbApiKey{
+		PkKeyHash:      fmt.Sprintf("apikey|%s", keyHash),
+		SkKeyHash:      fmt.Sprintf("apikey|%s", keyHash),
+		Gsi1PkUserId:   fmt.Sprintf("user|%s", request.RequestContext.Authorizer["sub"].(string)),
+		Gsi1SkApiKeyId: fmt.Sprintf("apikey|%s", apiKeyId),
+		Name:           requestBody.Name,
+		CreatedAt:      currentTime,
+		Roles:          roles,
+	}
+
+	item, err := attributevalue.MarshalMap(ddbApiKey)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	responseBody, err := json.Marshal(ApiKey{
+		ApiKeyId:  apiKeyId,
+		Name:      ddbApiKey.Name,
+		CreatedAt: ddbApiKey.CreatedAt,
+		Roles:     ddbApiKey.Roles,
+		Key:       apiKey,
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Body:       string(responseBody),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
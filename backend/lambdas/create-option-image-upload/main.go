@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+// RequestBody describes the image that will be uploaded, which the upload URL is signed for
+type RequestBody struct {
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+// Upload is where to PUT the image, with the same Content-Type and Content-Length that it was requested
+// with. Its image key is given to an option when creating a poll.
+type Upload struct {
+	ImageKey    string `json:"imageKey"`
+	UploadUrl   string `json:"uploadUrl"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+	UploadExpiry = 15 * time.Minute
+)
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// newPutObjectInput signs the upload for exactly the requested content type and size, so S3 rejects any
+// other image uploaded with the URL
+func newPutObjectInput(bucketName string, imageKey string, requestBody RequestBody) *s3.PutObjectInput {
+	return &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(fmt.Sprintf("options/%s", imageKey)),
+		ContentType:   aws.String(requestBody.ContentType),
+		ContentLength: aws.Int64(requestBody.Size),
+	}
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	limits, err := pollcreate.GetLimits()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if err := limits.ValidateImage(requestBody.ContentType, requestBody.Size); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			nil,
+		), nil
+	}
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	imageId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// The key is bound to the uploader, so only they can give the image to their options
+	imageKey := pollcreate.ImageKey(request.RequestContext.Authorizer["sub"].(string), imageId)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	presignClient := s3.NewPresignClient(s3.NewFromConfig(cfg))
+
+	expiresAt := time.Now().UTC().Add(UploadExpiry)
+	presignedRequest, err := presignClient.PresignPutObject(
+		ctx,
+		newPutObjectInput(os.Getenv("OPTION_IMAGES_BUCKET_NAME"), imageKey, requestBody),
+		s3.WithPresignExpires(UploadExpiry),
+	)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	upload, err := json.Marshal(Upload{
+		ImageKey:    imageKey,
+		UploadUrl:   presignedRequest.URL,
+		ContentType: requestBody.ContentType,
+		Size:        requestBody.Size,
+		ExpiresAt:   expiresAt.Format(RFC3339Milli),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Body:       string(upload),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestNewPutObjectInput(t *testing.T) {
+	input := newPutObjectInput("images", "user1/image1", RequestBody{ContentType: "image/png", Size: 2048})
+
+	if aws.ToString(input.Bucket) != "images" || aws.ToString(input.Key) != "options/user1/image1" {
+		t.Errorf("expected the image to be uploaded under options/, got %s/%s", aws.ToString(input.Bucket), aws.ToString(input.Key))
+	}
+	if aws.ToString(input.ContentType) != "image/png" || aws.ToInt64(input.ContentLength) != 2048 {
+		t.Errorf("expected the upload to be signed for a 2048 byte PNG, got %s of %d bytes",
+			aws.ToString(input.ContentType), aws.ToInt64(input.ContentLength))
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -18,58 +17,56 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	nanoid "github.com/matoous/go-nanoid"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
 )
 
+// RequestBody either holds the whole poll, or the id of a template whose fields it overrides
 type RequestBody struct {
-	Prompt   string   `json:"prompt"`
-	Options  []string `json:"options"`
-	Duration int      `json:"duration"`
-}
+	TemplateId string          `json:"templateId"`
+	Prompt     string          `json:"prompt"`
+	Options    []RequestOption `json:"options"`
+	Duration   int             `json:"duration"`
 
-type NanoIdOptions struct {
-	Alphabet string
-	Length   int
-}
+	AllowWriteIns    bool `json:"allowWriteIns"`
+	ModerateWriteIns bool `json:"moderateWriteIns"`
 
-type DdbPoll struct {
-	PkPollId     string `dynamodbav:"PK"`
-	SkPollId     string `dynamodbav:"SK"`
-	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
-	Gsi1SkUserId string `dynamodbav:"GSI1SK"`
-	Prompt       string `dynamodbav:"Prompt"`
-	CreatedAt    string `dynamodbav:"CreatedAt"`
-	Duration     int    `dynamodbav:"Duration"`
-	IsArchived   bool   `dynamodbav:"IsArchived"`
+	VoteWeights *pollcreate.VoteWeights `json:"voteWeights"`
+
+	Quorum    int                 `json:"quorum"`
+	Threshold *decision.Threshold `json:"threshold"`
+	TieBreak  string              `json:"tieBreak"`
 }
 
-type DdbOption struct {
-	PkOptionId   string `dynamodbav:"PK"`
-	SkOptionId   string `dynamodbav:"SK"`
-	Gsi1PkPollId string `dynamodbav:"GSI1PK"`
-	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
-	Index        int    `dynamodbav:"Index"`
-	Text         string `dynamodbav:"Text"`
-	UpdatedAt    string `dynamodbav:"UpdatedAt"`
-	Votes        int    `dynamodbav:"Votes"`
+// RequestOption is either just the option's text, or its text along with its content. ImageKey is the key
+// of an image the user uploaded with an upload URL from the option images endpoint.
+type RequestOption struct {
+	Text        string `json:"text"`
+	Description string `json:"description,omitempty"`
+	Url         string `json:"url,omitempty"`
+	ImageKey    string `json:"imageKey,omitempty"`
 }
 
-type Poll struct {
-	PollId     string   `json:"pollId"`
-	UserId     string   `json:"userId"`
-	Prompt     string   `json:"prompt"`
-	Options    []Option `json:"options"`
-	CreatedAt  string   `json:"createdAt"`
-	Duration   int      `json:"duration"`
-	IsArchived bool     `json:"isArchived"`
+type DdbTemplate struct {
+	PkTemplateId string   `dynamodbav:"PK"`
+	Gsi1PkUserId string   `dynamodbav:"GSI1PK"`
+	Prompt       string   `dynamodbav:"Prompt"`
+	Options      []string `dynamodbav:"Options"`
+	Duration     int      `dynamodbav:"Duration"`
 }
 
-type Option struct {
-	OptionId  string `json:"optionId"`
-	Text      string `json:"text"`
-	UpdatedAt string `json:"updatedAt"`
-	Votes     int    `json:"votes"`
-	IsMyVote  bool   `json:"isMyVote"`
+type requestOption RequestOption
+
+func (o *RequestOption) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*o = RequestOption{Text: text}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*requestOption)(o))
 }
 
 type Error struct {
@@ -77,25 +74,6 @@ type Error struct {
 	Cause   string `json:"cause"`
 }
 
-const (
-	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
-)
-
-func getNanoIdOptions() (NanoIdOptions, error) {
-	alphabet := os.Getenv("NANOID_ALPHABET")
-	length, err := strconv.Atoi(os.Getenv("NANOID_LENGTH"))
-	if err != nil {
-		return NanoIdOptions{}, err
-	}
-	if !(length > 2 && length < 36) {
-		return NanoIdOptions{}, errors.New("NANOID_LENGTH must be between 2 and 36")
-	}
-	return NanoIdOptions{
-		Alphabet: alphabet,
-		Length:   length,
-	}, nil
-}
-
 func formatError(msg string, err error) string {
 	responseBody, _ := json.Marshal(Error{
 		Message: msg,
@@ -115,29 +93,105 @@ func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatew
 	return res
 }
 
-func stripPrefix(s string, prefix string) string {
-	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
-		return s[len(prefix):]
+// getTemplate returns the user's template, or nil if they have no template with the id
+func getTemplate(ctx context.Context, ddb *dynamodb.Client, userId string, templateId string) (*DdbTemplate, error) {
+	templateResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("template|%s", templateId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("template|%s", templateId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if templateResult.Item == nil {
+		return nil, nil
+	}
+
+	var ddbTemplate DdbTemplate
+	if err := attributevalue.UnmarshalMap(templateResult.Item, &ddbTemplate); err != nil {
+		return nil, err
+	}
+
+	if ddbTemplate.Gsi1PkUserId != fmt.Sprintf("user|%s", userId) {
+		return nil, nil
 	}
 
-	return s
+	return &ddbTemplate, nil
 }
 
-func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	currentTime := time.Now().UTC().Format(RFC3339Milli)
+// getImageKey returns where an option's image is stored in the option images bucket
+func getImageKey(imageKey string) string {
+	return fmt.Sprintf("options/%s", imageKey)
+}
 
-	nanoIdOptions, err := getNanoIdOptions()
+// getImage returns the uploaded image's metadata, or nil if no image has been uploaded with the key
+func getImage(ctx context.Context, s3Client *s3.Client, imageKey string) (*s3.HeadObjectOutput, error) {
+	headResult, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(os.Getenv("OPTION_IMAGES_BUCKET_NAME")),
+		Key:    aws.String(getImageKey(imageKey)),
+	})
+	var notFound *s3Types.NotFound
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
 	if err != nil {
-		return logAndReturn(
-			events.APIGatewayProxyResponse{
-				StatusCode: http.StatusInternalServerError,
-				Body:       formatError("Internal server error", err),
-			},
-			err,
-		), nil
+		return nil, err
 	}
 
-	cfg, err := config.LoadDefaultConfig(ctx)
+	return headResult, nil
+}
+
+// newInput fills the fields missing from the request body with the template's. Templates only hold the
+// texts of their options, so options with content have to be given in the request body.
+func newInput(userId string, requestBody RequestBody, ddbTemplate *DdbTemplate, imagesUrl string) pollcreate.Input {
+	input := pollcreate.Input{
+		UserId:           userId,
+		Prompt:           requestBody.Prompt,
+		Duration:         requestBody.Duration,
+		AllowWriteIns:    requestBody.AllowWriteIns,
+		ModerateWriteIns: requestBody.ModerateWriteIns,
+		VoteWeights:      requestBody.VoteWeights,
+		Quorum:           requestBody.Quorum,
+		Threshold:        requestBody.Threshold,
+		TieBreak:         requestBody.TieBreak,
+	}
+
+	for _, requestOption := range requestBody.Options {
+		optionContent := pollcreate.OptionContent{
+			Description: requestOption.Description,
+			Url:         requestOption.Url,
+		}
+		if requestOption.ImageKey != "" {
+			optionContent.ImageUrl = fmt.Sprintf("%s/%s", imagesUrl, getImageKey(requestOption.ImageKey))
+		}
+
+		input.Options = append(input.Options, requestOption.Text)
+		input.OptionContents = append(input.OptionContents, optionContent)
+	}
+
+	if ddbTemplate != nil {
+		if input.Prompt == "" {
+			input.Prompt = ddbTemplate.Prompt
+		}
+		if len(input.Options) == 0 {
+			input.Options = ddbTemplate.Options
+		}
+		if input.Duration == 0 {
+			input.Duration = ddbTemplate.Duration
+		}
+	}
+
+	return input
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayProxyResponse{
@@ -148,9 +202,7 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
-	ddb := dynamodb.NewFromConfig(cfg)
-
-	pollId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayProxyResponse{
@@ -161,6 +213,8 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
+	ddb := dynamodb.NewFromConfig(cfg)
+
 	var requestBody RequestBody
 	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
 		return logAndReturn(
@@ -172,31 +226,35 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
-	if requestBody.Duration < 1 {
-		return logAndReturn(
-			events.APIGatewayProxyResponse{
-				StatusCode: http.StatusBadRequest,
-				Body: formatError(
-					"Bad request",
-					errors.New("duration must be greater than 0"),
-				),
-			},
-			nil,
-		), nil
-	}
+	userId := request.RequestContext.Authorizer["sub"].(string)
 
-	ddbPoll := DdbPoll{
-		PkPollId:     fmt.Sprintf("poll|%s", pollId),
-		SkPollId:     fmt.Sprintf("poll|%s", pollId),
-		Gsi1PkUserId: fmt.Sprintf("user|%s", request.RequestContext.Authorizer["sub"].(string)),
-		Gsi1SkUserId: fmt.Sprintf("user|%s", request.RequestContext.Authorizer["sub"].(string)),
-		Prompt:       requestBody.Prompt,
-		CreatedAt:    currentTime,
-		Duration:     requestBody.Duration,
-		IsArchived:   false,
+	var ddbTemplate *DdbTemplate
+	if requestBody.TemplateId != "" {
+		ddbTemplate, err = getTemplate(ctx, ddb, userId, requestBody.TemplateId)
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+		if ddbTemplate == nil {
+			err := errors.New("template not found")
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusNotFound,
+					Body:       formatError("Not found", err),
+				},
+				err,
+			), nil
+		}
 	}
 
-	item, err := attributevalue.MarshalMap(ddbPoll)
+	input := newInput(userId, requestBody, ddbTemplate, os.Getenv("OPTION_IMAGES_URL"))
+
+	limits, err := pollcreate.GetLimits()
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayProxyResponse{
@@ -207,20 +265,33 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
-	var transactItem types.TransactWriteItem
-	var transactItems []types.TransactWriteItem
-	transactItem = types.TransactWriteItem{
-		Put: &types.Put{
-			TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
-			Item:      item,
-		},
+	if err := limits.ValidateOptionContents(input.OptionContents); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			nil,
+		), nil
 	}
-	transactItems = append(transactItems, transactItem)
 
-	var ddbOption DdbOption
-	var options []Option
-	for index, text := range requestBody.Options {
-		optionId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	s3Client := s3.NewFromConfig(cfg)
+	for _, requestOption := range requestBody.Options {
+		if requestOption.ImageKey == "" {
+			continue
+		}
+
+		if err := pollcreate.ValidateImageKey(userId, requestOption.ImageKey); err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusForbidden,
+					Body:       formatError("Forbidden", err),
+				},
+				err,
+			), nil
+		}
+
+		image, err := getImage(ctx, s3Client, requestOption.ImageKey)
 		if err != nil {
 			return logAndReturn(
 				events.APIGatewayProxyResponse{
@@ -230,48 +301,47 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 				err,
 			), nil
 		}
-
-		ddbOption = DdbOption{
-			PkOptionId:   fmt.Sprintf("option|%s", optionId),
-			SkOptionId:   fmt.Sprintf("option|%s", optionId),
-			Gsi1PkPollId: fmt.Sprintf("poll|%s", pollId),
-			Gsi1SkPollId: fmt.Sprintf("poll|%s", pollId),
-			Index:        index,
-			Text:         text,
-			UpdatedAt:    currentTime,
-			Votes:        0,
+		if image == nil {
+			err := fmt.Errorf("image %s hasn't been uploaded", requestOption.ImageKey)
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusBadRequest,
+					Body:       formatError("Bad request", err),
+				},
+				err,
+			), nil
 		}
 
-		item, err := attributevalue.MarshalMap(ddbOption)
+		// The upload URL is signed for an allowed image, but the limits may have changed since it was issued
+		err = limits.ValidateImage(aws.ToString(image.ContentType), aws.ToInt64(image.ContentLength))
 		if err != nil {
 			return logAndReturn(
 				events.APIGatewayProxyResponse{
-					StatusCode: http.StatusInternalServerError,
-					Body:       formatError("Internal server error", err),
+					StatusCode: http.StatusBadRequest,
+					Body:       formatError("Bad request", err),
 				},
 				err,
 			), nil
 		}
-		options = append(options, Option{
-			OptionId:  stripPrefix(ddbOption.PkOptionId, "option|"),
-			Text:      ddbOption.Text,
-			UpdatedAt: ddbOption.UpdatedAt,
-			Votes:     ddbOption.Votes,
-			IsMyVote:  false,
-		})
-
-		transactItem = types.TransactWriteItem{
-			Put: &types.Put{
-				TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
-				Item:      item,
-			},
-		}
-		transactItems = append(transactItems, transactItem)
 	}
 
-	_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
-		TransactItems: transactItems,
-	})
+	createdPoll, err := pollcreate.Create(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		nanoIdOptions,
+		input,
+		time.Now(),
+	)
+	if errors.Is(err, pollcreate.ErrInvalidDuration) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			nil,
+		), nil
+	}
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayProxyResponse{
@@ -282,15 +352,7 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
-	poll, err := json.Marshal(Poll{
-		PollId:     stripPrefix(ddbPoll.PkPollId, "poll|"),
-		UserId:     stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
-		Prompt:     ddbPoll.Prompt,
-		Options:    options,
-		CreatedAt:  ddbPoll.CreatedAt,
-		Duration:   ddbPoll.Duration,
-		IsArchived: ddbPoll.IsArchived,
-	})
+	poll, err := json.Marshal(createdPoll)
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayProxyResponse{
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
 )
 
 func TestHandler(t *testing.T) {
@@ -13,10 +15,10 @@ func TestHandler(t *testing.T) {
 
 	requestBody, _ := json.Marshal(RequestBody{
 		Prompt: "Test prompt",
-		Options: []string{
-			"Option 1",
-			"Option 2",
-			"Option 3",
+		Options: []RequestOption{
+			{Text: "Option 1"},
+			{Text: "Option 2"},
+			{Text: "Option 3"},
 		},
 		Duration: 300,
 	})
@@ -37,3 +39,69 @@ func TestHandler(t *testing.T) {
 
 	t.Log(res)
 }
+
+func TestNewInput(t *testing.T) {
+	ddbTemplate := &DdbTemplate{
+		Prompt:   "Where to?",
+		Options:  []string{"Tacos", "Pho"},
+		Duration: 600,
+	}
+
+	input := newInput("user123", RequestBody{TemplateId: "template1", Duration: 1200}, ddbTemplate, "")
+	if input.UserId != "user123" || input.Prompt != "Where to?" || !reflect.DeepEqual(input.Options, ddbTemplate.Options) {
+		t.Errorf("expected the template's prompt and options, got %+v", input)
+	}
+	if input.Duration != 1200 {
+		t.Errorf("expected the requested duration to override the template's, got %d", input.Duration)
+	}
+
+	input = newInput(
+		"user123",
+		RequestBody{Prompt: "Ship it?", Options: []RequestOption{{Text: "Yes"}, {Text: "No"}}, Duration: 300},
+		nil,
+		"",
+	)
+	if input.Prompt != "Ship it?" || len(input.Options) != 2 || input.Duration != 300 {
+		t.Errorf("expected the request body without a template, got %+v", input)
+	}
+}
+
+func TestUnmarshalRequestOptions(t *testing.T) {
+	var requestBody RequestBody
+	err := json.Unmarshal(
+		[]byte(`{"options": ["Yes", {"text": "No", "description": "Not yet", "url": "https://example.com", "imageKey": "user1/image1"}]}`),
+		&requestBody,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RequestOption{
+		{Text: "Yes"},
+		{Text: "No", Description: "Not yet", Url: "https://example.com", ImageKey: "user1/image1"},
+	}
+	if !reflect.DeepEqual(requestBody.Options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, requestBody.Options)
+	}
+}
+
+func TestNewInputOptionContents(t *testing.T) {
+	input := newInput(
+		"user123",
+		RequestBody{
+			Prompt:   "Which mockup?",
+			Options:  []RequestOption{{Text: "A", Description: "Dark", ImageKey: "user1/image1"}, {Text: "B"}},
+			Duration: 300,
+		},
+		nil,
+		"https://images.example.com",
+	)
+
+	expected := []pollcreate.OptionContent{
+		{Description: "Dark", ImageUrl: "https://images.example.com/options/user1/image1"},
+		{},
+	}
+	if !reflect.DeepEqual(input.Options, []string{"A", "B"}) || !reflect.DeepEqual(input.OptionContents, expected) {
+		t.Errorf("expected the options' texts and contents, got %+v", input)
+	}
+}
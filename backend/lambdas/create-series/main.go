@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/recurrence"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+type RequestBody struct {
+	Schedule string   `json:"schedule"`
+	TimeZone string   `json:"timeZone"`
+	Prompt   string   `json:"prompt"`
+	Options  []string `json:"options"`
+	Duration int      `json:"duration"`
+}
+
+// DdbSeries is stored under its own key and indexed by its owner in GSI1, like a template. NextRunAt is
+// in epoch seconds so the scheduler can compare it numerically.
+type DdbSeries struct {
+	PkSeriesId     string   `dynamodbav:"PK"`
+	SkSeriesId     string   `dynamodbav:"SK"`
+	Gsi1PkUserId   string   `dynamodbav:"GSI1PK"`
+	Gsi1SkSeriesId string   `dynamodbav:"GSI1SK"`
+	Schedule       string   `dynamodbav:"Schedule"`
+	TimeZone       string   `dynamodbav:"TimeZone"`
+	Prompt         string   `dynamodbav:"Prompt"`
+	Options        []string `dynamodbav:"Options"`
+	Duration       int      `dynamodbav:"Duration"`
+	Status         string   `dynamodbav:"Status"`
+	NextRunAt      int64    `dynamodbav:"NextRunAt"`
+	CreatedAt      string   `dynamodbav:"CreatedAt"`
+	UpdatedAt      string   `dynamodbav:"UpdatedAt"`
+}
+
+type Series struct {
+	SeriesId  string   `json:"seriesId"`
+	Schedule  string   `json:"schedule"`
+	TimeZone  string   `json:"timeZone"`
+	Prompt    string   `json:"prompt"`
+	Options   []string `json:"options"`
+	Duration  int      `json:"duration"`
+	Status    string   `json:"status"`
+	NextRunAt string   `json:"nextRunAt,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var errNeverRuns = errors.New("the schedule never runs")
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// newDdbSeries schedules the first occurrence of the series, defaulting to UTC when no time zone is given
+func newDdbSeries(userId string, seriesId string, requestBody RequestBody, now time.Time) (DdbSeries, error) {
+	if requestBody.TimeZone == "" {
+		requestBody.TimeZone = "UTC"
+	}
+
+	nextRunAt, err := recurrence.Schedule{
+		Expression: requestBody.Schedule,
+		TimeZone:   requestBody.TimeZone,
+	}.Next(now)
+	if err != nil {
+		return DdbSeries{}, err
+	}
+	if nextRunAt.IsZero() {
+		return DdbSeries{}, fmt.Errorf("%w: %s", recurrence.ErrInvalidSchedule, errNeverRuns)
+	}
+
+	currentTime := now.UTC().Format(RFC3339Milli)
+
+	return DdbSeries{
+		PkSeriesId:     fmt.Sprintf("series|%s", seriesId),
+		SkSeriesId:     fmt.Sprintf("series|%s", seriesId),
+		Gsi1PkUserId:   fmt.Sprintf("user|%s", userId),
+		Gsi1SkSeriesId: fmt.Sprintf("series|%s", seriesId),
+		Schedule:       requestBody.Schedule,
+		TimeZone:       requestBody.TimeZone,
+		Prompt:         requestBody.Prompt,
+		Options:        requestBody.Options,
+		Duration:       requestBody.Duration,
+		Status:         recurrence.StatusActive,
+		NextRunAt:      nextRunAt.Unix(),
+		CreatedAt:      currentTime,
+		UpdatedAt:      currentTime,
+	}, nil
+}
+
+func newSeries(ddbSeries DdbSeries) Series {
+	series := Series{
+		SeriesId:  stripPrefix(ddbSeries.PkSeriesId, "series|"),
+		Schedule:  ddbSeries.Schedule,
+		TimeZone:  ddbSeries.TimeZone,
+		Prompt:    ddbSeries.Prompt,
+		Options:   ddbSeries.Options,
+		Duration:  ddbSeries.Duration,
+		Status:    ddbSeries.Status,
+		CreatedAt: ddbSeries.CreatedAt,
+		UpdatedAt: ddbSeries.UpdatedAt,
+	}
+	if ddbSeries.Status == recurrence.StatusActive {
+		series.NextRunAt = time.Unix(ddbSeries.NextRunAt, 0).UTC().Format(RFC3339Milli)
+	}
+
+	return series
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	limits, err := pollcreate.GetLimits()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if err := limits.Validate(pollcreate.Input{
+		Prompt:   requestBody.Prompt,
+		Options:  requestBody.Options,
+		Duration: requestBody.Duration,
+	}); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			nil,
+		), nil
+	}
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	seriesId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddbSeries, err := newDdbSeries(
+		request.RequestContext.Authorizer["sub"].(string),
+		seriesId,
+		requestBody,
+		time.Now(),
+	)
+	if errors.Is(err, recurrence.ErrInvalidSchedule) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			nil,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	item, err := attributevalue.MarshalMap(ddbSeries)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	series, err := json.Marshal(newSeries(ddbSeries))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Body:       string(series),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
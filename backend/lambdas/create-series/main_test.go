@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/recurrence"
+)
+
+func TestNewDdbSeries(t *testing.T) {
+	// Monday 2024-01-01 12:00 UTC
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	requestBody := RequestBody{Schedule: "0 9 * * MON", Prompt: "Standup?", Options: []string{"Yes", "No"}, Duration: 3600}
+
+	ddbSeries, err := newDdbSeries("user123", "series1", requestBody, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ddbSeries.PkSeriesId != "series|series1" || ddbSeries.Gsi1PkUserId != "user|user123" || ddbSeries.Gsi1SkSeriesId != "series|series1" {
+		t.Errorf("expected the series to be indexed by its owner, got %+v", ddbSeries)
+	}
+	if ddbSeries.TimeZone != "UTC" || ddbSeries.Status != recurrence.StatusActive {
+		t.Errorf("expected an active series in UTC, got %+v", ddbSeries)
+	}
+	if expected := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC).Unix(); ddbSeries.NextRunAt != expected {
+		t.Errorf("expected the first run at %d, got %d", expected, ddbSeries.NextRunAt)
+	}
+	if series := newSeries(ddbSeries); series.SeriesId != "series1" || series.NextRunAt != "2024-01-08T09:00:00Z" {
+		t.Errorf("unexpected series %+v", series)
+	}
+
+	requestBody.TimeZone = "Mars/Olympus_Mons"
+	if _, err := newDdbSeries("user123", "series1", requestBody, now); !errors.Is(err, recurrence.ErrInvalidSchedule) {
+		t.Errorf("expected an unknown time zone to be rejected, got %v", err)
+	}
+
+	requestBody.TimeZone = ""
+	requestBody.Schedule = "0 0 30 2 *"
+	if _, err := newDdbSeries("user123", "series1", requestBody, now); !errors.Is(err, recurrence.ErrInvalidSchedule) {
+		t.Errorf("expected a schedule that never runs to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/subscription"
+)
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkPollId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Gsi1SkUserId string `dynamodbav:"GSI1SK"`
+	Prompt       string `dynamodbav:"Prompt"`
+	CreatedAt    string `dynamodbav:"CreatedAt"`
+	Duration     int    `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type SubscriptionToken struct {
+	Token     string   `json:"token"`
+	ExpiresAt string   `json:"expiresAt"`
+	Topics    []string `json:"topics"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli    = "2006-01-02T15:04:05.999Z07:00"
+	DefaultTokenTtl = 5 * time.Minute
+)
+
+func getTokenTtl() (time.Duration, error) {
+	tokenTtl := os.Getenv("SUBSCRIPTION_TOKEN_TTL")
+	if tokenTtl == "" {
+		return DefaultTokenTtl, nil
+	}
+
+	return time.ParseDuration(tokenTtl)
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %d", res.StatusCode)
+
+	return res
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+// authorizeArchived returns pollaccess.ErrForbidden if the poll is archived and the user can't view it.
+// Archived polls are only visible to their owner and members, so the same goes for their updates.
+func authorizeArchived(
+	ctx context.Context,
+	ddb pollaccess.ItemGetter,
+	tableName string,
+	ddbPoll DdbPoll,
+	userId string,
+) error {
+	if !ddbPoll.IsArchived {
+		return nil
+	}
+
+	_, err := pollaccess.Authorize(
+		ctx,
+		ddb,
+		tableName,
+		stripPrefix(ddbPoll.PkPollId, "poll|"),
+		ddbPoll.Gsi1PkUserId,
+		userId,
+		pollaccess.ActionView,
+	)
+
+	return err
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollId := request.PathParameters["pollId"]
+
+	// The vote request ID is only known to the voter, so it doubles as the capability to follow its result
+	requestId := request.QueryStringParameters["requestId"]
+
+	// The ids end up in the token's topics, so a wildcard would let it follow every other poll or vote
+	if err := subscription.ValidateIds(pollId, requestId); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	tokenTtl, err := getTokenTtl()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err = attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.IsArchived {
+		// NOTE: If this lambda is invoked by the `/public/polls/{pollId}/subscription-token` endpoint, the user will not be authenticated.
+		currentUserId, _ := request.RequestContext.Authorizer["sub"].(string)
+		if currentUserId == "" {
+			err := errors.New("user is not authenticated")
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusUnauthorized,
+					Body:       formatError("Unauthorized", err),
+				},
+				err,
+			), nil
+		}
+
+		err := authorizeArchived(ctx, ddb, os.Getenv("SINGLE_TABLE_NAME"), ddbPoll, currentUserId)
+		if errors.Is(err, pollaccess.ErrForbidden) {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusForbidden,
+					Body:       formatError("Forbidden", err),
+				},
+				err,
+			), nil
+		}
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	token, expiresAt, err := subscription.Sign(
+		[]byte(os.Getenv("SUBSCRIPTION_TOKEN_SECRET")),
+		pollId,
+		requestId,
+		tokenTtl,
+	)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	claims := subscription.Claims{PollId: pollId, RequestId: requestId}
+
+	responseBody, err := json.Marshal(SubscriptionToken{
+		Token:     token,
+		ExpiresAt: expiresAt.UTC().Format(RFC3339Milli),
+		Topics:    claims.Topics(),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(responseBody),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
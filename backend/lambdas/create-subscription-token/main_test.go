@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+)
+
+type fakeGetter struct {
+	members map[string]string
+}
+
+func (g *fakeGetter) GetItem(
+	ctx context.Context,
+	params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.GetItemOutput, error) {
+	sk := params.Key["SK"].(*types.AttributeValueMemberS).Value
+	role, ok := g.members[sk]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"PK":   params.Key["PK"],
+			"SK":   params.Key["SK"],
+			"Role": &types.AttributeValueMemberS{Value: role},
+		},
+	}, nil
+}
+
+// unmarshalPoll reads back a poll item the way it's written when the poll is created
+func unmarshalPoll(t *testing.T, isArchived bool) DdbPoll {
+	item, err := attributevalue.MarshalMap(pollcreate.DdbPoll{
+		PkPollId:     "poll|poll123",
+		SkPollId:     "poll|poll123",
+		Gsi1PkUserId: "user|user1",
+		Gsi1SkUserId: "user|user1",
+		Prompt:       "Ship it?",
+		CreatedAt:    "2024-01-01T00:00:00Z",
+		Duration:     3600,
+		IsArchived:   isArchived,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(item, &ddbPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	return ddbPoll
+}
+
+func TestUnmarshalArchivedPoll(t *testing.T) {
+	if !unmarshalPoll(t, true).IsArchived {
+		t.Error("expected the poll to be archived")
+	}
+}
+
+func TestAuthorizeArchived(t *testing.T) {
+	ctx := context.Background()
+	ddb := &fakeGetter{members: map[string]string{pollaccess.MemberKey("user2"): pollaccess.RoleViewer}}
+
+	archived := unmarshalPoll(t, true)
+	if err := authorizeArchived(ctx, ddb, "table", archived, "user3"); !errors.Is(err, pollaccess.ErrForbidden) {
+		t.Errorf("expected a user who isn't a member to be forbidden, got %v", err)
+	}
+	if err := authorizeArchived(ctx, ddb, "table", archived, "user2"); err != nil {
+		t.Errorf("expected a member to be authorized, got %s", err)
+	}
+	if err := authorizeArchived(ctx, ddb, "table", archived, "user1"); err != nil {
+		t.Errorf("expected the owner to be authorized, got %s", err)
+	}
+
+	if err := authorizeArchived(ctx, ddb, "table", unmarshalPoll(t, false), "user3"); err != nil {
+		t.Errorf("expected anyone to be authorized for a poll that isn't archived, got %s", err)
+	}
+}
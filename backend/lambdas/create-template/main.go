@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+type RequestBody struct {
+	Name     string   `json:"name"`
+	Prompt   string   `json:"prompt"`
+	Options  []string `json:"options"`
+	Duration int      `json:"duration"`
+}
+
+type NanoIdOptions struct {
+	Alphabet string
+	Length   int
+}
+
+// DdbTemplate is stored under its own key and indexed by its owner in GSI1, like an API key
+type DdbTemplate struct {
+	PkTemplateId     string   `dynamodbav:"PK"`
+	SkTemplateId     string   `dynamodbav:"SK"`
+	Gsi1PkUserId     string   `dynamodbav:"GSI1PK"`
+	Gsi1SkTemplateId string   `dynamodbav:"GSI1SK"`
+	Name             string   `dynamodbav:"Name"`
+	Prompt           string   `dynamodbav:"Prompt"`
+	Options          []string `dynamodbav:"Options"`
+	Duration         int      `dynamodbav:"Duration"`
+	CreatedAt        string   `dynamodbav:"CreatedAt"`
+	UpdatedAt        string   `dynamodbav:"UpdatedAt"`
+}
+
+type Template struct {
+	TemplateId string   `json:"templateId"`
+	Name       string   `json:"name"`
+	Prompt     string   `json:"prompt"`
+	Options    []string `json:"options"`
+	Duration   int      `json:"duration"`
+	CreatedAt  string   `json:"createdAt"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+func getNanoIdOptions() (NanoIdOptions, error) {
+	alphabet := os.Getenv("NANOID_ALPHABET")
+	length, err := strconv.Atoi(os.Getenv("NANOID_LENGTH"))
+	if err != nil {
+		return NanoIdOptions{}, err
+	}
+	if !(length > 2 && length < 36) {
+		return NanoIdOptions{}, errors.New("NANOID_LENGTH must be between 2 and 36")
+	}
+	return NanoIdOptions{
+		Alphabet: alphabet,
+		Length:   length,
+	}, nil
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func newDdbTemplate(userId string, templateId string, requestBody RequestBody, currentTime string) DdbTemplate {
+	return DdbTemplate{
+		PkTemplateId:     fmt.Sprintf("template|%s", templateId),
+		SkTemplateId:     fmt.Sprintf("template|%s", templateId),
+		Gsi1PkUserId:     fmt.Sprintf("user|%s", userId),
+		Gsi1SkTemplateId: fmt.Sprintf("template|%s", templateId),
+		Name:             requestBody.Name,
+		Prompt:           requestBody.Prompt,
+		Options:          requestBody.Options,
+		Duration:         requestBody.Duration,
+		CreatedAt:        currentTime,
+		UpdatedAt:        currentTime,
+	}
+}
+
+func newTemplate(ddbTemplate DdbTemplate) Template {
+	return Template{
+		TemplateId: stripPrefix(ddbTemplate.PkTemplateId, "template|"),
+		Name:       ddbTemplate.Name,
+		Prompt:     ddbTemplate.Prompt,
+		Options:    ddbTemplate.Options,
+		Duration:   ddbTemplate.Duration,
+		CreatedAt:  ddbTemplate.CreatedAt,
+		UpdatedAt:  ddbTemplate.UpdatedAt,
+	}
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	currentTime := time.Now().UTC().Format(RFC3339Milli)
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	nanoIdOptions, err := getNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	templateId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbTemplate := newDdbTemplate(
+		request.RequestContext.Authorizer["sub"].(string),
+		templateId,
+		requestBody,
+		currentTime,
+	)
+
+	item, err := attributevalue.MarshalMap(ddbTemplate)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	template, err := json.Marshal(newTemplate(ddbTemplate))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Body:       string(template),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
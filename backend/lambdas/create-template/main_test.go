@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewDdbTemplate(t *testing.T) {
+	ddbTemplate := newDdbTemplate(
+		"user123",
+		"template1",
+		RequestBody{Name: "Retro mood", Prompt: "How was the sprint?", Options: []string{"Good", "Bad"}, Duration: 3600},
+		"2024-01-01T00:00:00.000Z",
+	)
+
+	if ddbTemplate.PkTemplateId != "template|template1" || ddbTemplate.SkTemplateId != "template|template1" {
+		t.Errorf("expected the template to be stored under its id, got %s, %s", ddbTemplate.PkTemplateId, ddbTemplate.SkTemplateId)
+	}
+	if ddbTemplate.Gsi1PkUserId != "user|user123" || ddbTemplate.Gsi1SkTemplateId != "template|template1" {
+		t.Errorf("expected the template to be indexed by its owner, got %s, %s", ddbTemplate.Gsi1PkUserId, ddbTemplate.Gsi1SkTemplateId)
+	}
+
+	expected := Template{
+		TemplateId: "template1",
+		Name:       "Retro mood",
+		Prompt:     "How was the sprint?",
+		Options:    []string{"Good", "Bad"},
+		Duration:   3600,
+		CreatedAt:  "2024-01-01T00:00:00.000Z",
+		UpdatedAt:  "2024-01-01T00:00:00.000Z",
+	}
+	if template := newTemplate(ddbTemplate); !reflect.DeepEqual(template, expected) {
+		t.Errorf("expected %+v, got %+v", expected, template)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+type RequestBody struct {
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type NanoIdOptions struct {
+	Alphabet string
+	Length   int
+}
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+}
+
+type DdbWebhook struct {
+	PkPollId    string   `dynamodbav:"PK"`
+	SkWebhookId string   `dynamodbav:"SK"`
+	Url         string   `dynamodbav:"Url"`
+	Secret      string   `dynamodbav:"Secret"`
+	Events      []string `dynamodbav:"Events"`
+	CreatedAt   string   `dynamodbav:"CreatedAt"`
+}
+
+type Webhook struct {
+	WebhookId string   `json:"webhookId"`
+	PollId    string   `json:"pollId"`
+	Url       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"createdAt"`
+	Secret    string   `json:"secret"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli  = "2006-01-02T15:04:05.999Z07:00"
+	SecretPrefix  = "whsec_"
+	MaxUrlLength  = 2048
+	MaxEventTypes = 16
+)
+
+// WebhookEvents are the message types a webhook can subscribe to; they are delivered as protocol envelopes
+var WebhookEvents = []string{
+	protocol.TypeVoteCounted,
+	protocol.TypeVoteFailed,
+	protocol.TypePollArchived,
+	protocol.TypePollUnarchived,
+	protocol.TypePollExtended,
+	protocol.TypePollShortened,
+	protocol.TypePollClosedEarly,
+	protocol.TypePollEdited,
+	protocol.TypeOptionAdded,
+	protocol.TypePollClosed,
+	protocol.TypeTieBroken,
+	protocol.TypePollDeleted,
+	protocol.TypePollExpired,
+}
+
+func getNanoIdOptions() (NanoIdOptions, error) {
+	alphabet := os.Getenv("NANOID_ALPHABET")
+	length, err := strconv.Atoi(os.Getenv("NANOID_LENGTH"))
+	if err != nil {
+		return NanoIdOptions{}, err
+	}
+	if !(length > 2 && length < 36) {
+		return NanoIdOptions{}, errors.New("NANOID_LENGTH must be between 2 and 36")
+	}
+	return NanoIdOptions{
+		Alphabet: alphabet,
+		Length:   length,
+	}, nil
+}
+
+// validateUrl only accepts absolute HTTPS URLs, so deliveries and their signatures are never sent in the clear
+func validateUrl(rawUrl string) error {
+	if len(rawUrl) > MaxUrlLength {
+		return fmt.Errorf("url must be at most %d characters", MaxUrlLength)
+	}
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	if parsedUrl.Scheme != "https" || parsedUrl.Host == "" {
+		return errors.New("url must be an absolute https URL")
+	}
+
+	if parsedUrl.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+
+	return nil
+}
+
+// getEvents defaults a webhook to every event, and rejects events that aren't delivered to webhooks
+func getEvents(requestedEvents []string) ([]string, error) {
+	if len(requestedEvents) == 0 {
+		return WebhookEvents, nil
+	}
+
+	if len(requestedEvents) > MaxEventTypes {
+		return nil, fmt.Errorf("at most %d events can be requested", MaxEventTypes)
+	}
+
+	var events []string
+	for _, requestedEvent := range requestedEvents {
+		if !slices.Contains(WebhookEvents, requestedEvent) {
+			return nil, fmt.Errorf("unknown event %s", requestedEvent)
+		}
+
+		if !slices.Contains(events, requestedEvent) {
+			events = append(events, requestedEvent)
+		}
+	}
+
+	return events, nil
+}
+
+// generateSecret returns a random secret that deliveries are signed with
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return SecretPrefix + hex.EncodeToString(secret), nil
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	// The response body holds the plaintext secret, so only the status is logged
+	log.Printf("Response: %d", res.StatusCode)
+
+	return res
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	currentTime := time.Now().UTC().Format(RFC3339Milli)
+	pollId := request.PathParameters["pollId"]
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	if err := validateUrl(requestBody.Url); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	webhookEvents, err := getEvents(requestBody.Events)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	nanoIdOptions, err := getNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	webhookId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err = attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Co-owners manage the poll's webhooks alongside its owner
+	_, err = pollaccess.Authorize(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		request.RequestContext.Authorizer["sub"].(string),
+		pollaccess.ActionManage,
+	)
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddbWebhook := DdbWebhook{
+		PkPollId:    fmt.Sprintf("poll|%s", pollId),
+		SkWebhookId: fmt.Sprintf("webhook|%s", webhookId),
+		Url:         requestBody.Url,
+		Secret:      secret,
+		Events:      webhookEvents,
+		CreatedAt:   currentTime,
+	}
+
+	item, err := attributevalue.MarshalMap(ddbWebhook)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#sk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#sk": "SK",
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	responseBody, err := json.Marshal(Webhook{
+		WebhookId: webhookId,
+		PollId:    pollId,
+		Url:       ddbWebhook.Url,
+		Events:    ddbWebhook.Events,
+		CreatedAt: ddbWebhook.CreatedAt,
+		Secret:    secret,
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Body:       string(responseBody),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DdbApiKey struct {
+	PkKeyHash      string `dynamodbav:"PK"`
+	SkKeyHash      string `dynamodbav:"SK"`
+	Gsi1PkUserId   string `dynamodbav:"GSI1PK"`
+	Gsi1SkApiKeyId string `dynamodbav:"GSI1SK"`
+	Name           string `dynamodbav:"Name"`
+	CreatedAt      string `dynamodbav:"CreatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	// API keys are stored under their hash, so the key ID is resolved through the user's partition
+	apiKeyResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#user = :user AND #apiKey = :apiKey"),
+		ExpressionAttributeNames: map[string]string{
+			"#user":   "GSI1PK",
+			"#apiKey": "GSI1SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("user|%s", userId),
+			},
+			":apiKey": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("apikey|%s", request.PathParameters["apiKeyId"]),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if len(apiKeyResult.Items) == 0 {
+		err := errors.New("API key not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbApiKey DdbApiKey
+	if err = attributevalue.UnmarshalMap(apiKeyResult.Items[0], &ddbApiKey); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: ddbApiKey.PkKeyHash,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: ddbApiKey.SkKeyHash,
+			},
+		},
+		ConditionExpression: aws.String("#user = :user"),
+		ExpressionAttributeNames: map[string]string{
+			"#user": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("user|%s", userId),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNoContent,
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
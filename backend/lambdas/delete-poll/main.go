@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+)
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkPollId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type DeletionRequestedDetail struct {
+	PollId string `json:"pollId"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var (
+	errPollNotFound = errors.New("poll not found")
+	errPollDeleted  = errors.New("poll was deleted")
+	errNotOwner     = errors.New("only the poll's owner can delete it")
+)
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// markDeleted records when the poll was deleted, which hides it from everyone, takes it out of the index of open
+// polls and announces the deletion through the stream at the poll's next sequence number. A poll that is already
+// marked keeps its original time.
+func markDeleted(ctx context.Context, ddb *dynamodb.Client, ddbPoll DdbPoll, currentTime string) error {
+	sequence, err := pollsequence.Next(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		strings.TrimPrefix(ddbPoll.PkPollId, "poll|"),
+		ddbPoll.Version,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+			"SK": &ddbTypes.AttributeValueMemberS{Value: ddbPoll.SkPollId},
+		},
+		ConditionExpression: aws.String("attribute_exists(#pk) AND attribute_not_exists(#deletedAt)"),
+		UpdateExpression:    aws.String("SET #deletedAt = :deletedAt, #version = :version REMOVE #open, #expiresAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":        "PK",
+			"#deletedAt": "DeletedAt",
+			"#version":   "Version",
+			"#open":      "GSI2PK",
+			"#expiresAt": "GSI2SK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":deletedAt": &ddbTypes.AttributeValueMemberS{Value: currentTime},
+			":version":   &ddbTypes.AttributeValueMemberN{Value: strconv.FormatInt(sequence, 10)},
+		},
+	})
+
+	// A concurrent request marked the poll first, and its items are deleted all the same
+	var conditionalCheckFailed *ddbTypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return nil
+	}
+
+	return err
+}
+
+// requestDeletion asks the poll deleter to delete the poll's items, or to resume deleting them
+func requestDeletion(ctx context.Context, ebClient *eventbridge.Client, pollId string) error {
+	detail, err := json.Marshal(DeletionRequestedDetail{PollId: pollId})
+	if err != nil {
+		return err
+	}
+
+	result, err := ebClient.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebTypes.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(os.Getenv("EVENT_BUS_NAME")),
+				Source:       aws.String(os.Getenv("DELETION_REQUESTED_SOURCE")),
+				DetailType:   aws.String(os.Getenv("DELETION_REQUESTED_DETAIL_TYPE")),
+				Detail:       aws.String(string(detail)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.FailedEntryCount > 0 {
+		return errors.New(aws.ToString(result.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (DdbPoll, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return DdbPoll{}, err
+	}
+	if result.Item == nil {
+		return DdbPoll{}, errPollNotFound
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(result.Item, &ddbPoll); err != nil {
+		return DdbPoll{}, err
+	}
+
+	return ddbPoll, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	currentTime := time.Now().UTC().Format(RFC3339Milli)
+	pollId := request.PathParameters["pollId"]
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+	ebClient := eventbridge.NewFromConfig(cfg)
+
+	ddbPoll, err := getPoll(ctx, ddb, pollId)
+	if errors.Is(err, errPollNotFound) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// The poll loses its owner once all of its items have been deleted, so there's nothing left to resume
+	if ddbPoll.DeletedAt != "" && ddbPoll.Gsi1PkUserId == "" {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", errPollDeleted),
+			},
+			errPollDeleted,
+		), nil
+	}
+
+	// Co-owners manage the poll, but only its owner can delete it
+	_, err = pollaccess.Authorize(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		userId,
+		pollaccess.ActionDelete,
+	)
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", errNotOwner),
+			},
+			errNotOwner,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt == "" {
+		if err := markDeleted(ctx, ddb, ddbPoll, currentTime); err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	// Deleting the poll again resumes a deletion that stopped before all of its items were deleted
+	if err := requestDeletion(ctx, ebClient, pollId); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusAccepted,
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,934 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+// RequestBody edits the prompt and, when options is given, replaces the options with it. Options with an
+// ID keep that option and its content with the given text, options without one are added, and the options
+// left out are removed. Force resets the poll's votes instead of refusing to edit a poll that has any.
+type RequestBody struct {
+	Prompt  *string         `json:"prompt"`
+	Options []RequestOption `json:"options"`
+	Force   bool            `json:"force"`
+}
+
+type RequestOption struct {
+	OptionId string `json:"optionId"`
+	Text     string `json:"text"`
+}
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkPollId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Gsi1SkUserId string `dynamodbav:"GSI1SK"`
+	Prompt       string `dynamodbav:"Prompt"`
+	CreatedAt    string `dynamodbav:"CreatedAt"`
+	Duration     int    `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type DdbOption struct {
+	PkOptionId   string `dynamodbav:"PK"`
+	SkOptionId   string `dynamodbav:"SK"`
+	Gsi1PkPollId string `dynamodbav:"GSI1PK"`
+	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
+	Index        int    `dynamodbav:"Index"`
+	Text         string `dynamodbav:"Text"`
+	Description  string `dynamodbav:"Description,omitempty"`
+	Url          string `dynamodbav:"Url,omitempty"`
+	ImageUrl     string `dynamodbav:"ImageUrl,omitempty"`
+	UpdatedAt    string `dynamodbav:"UpdatedAt"`
+	Votes        int    `dynamodbav:"Votes"`
+	Ballots      *int   `dynamodbav:"Ballots,omitempty"`
+	Version      int64  `dynamodbav:"Version"`
+
+	WriteInStatus string `dynamodbav:"WriteInStatus,omitempty"`
+}
+
+type DdbEditedOption struct {
+	OptionId string `dynamodbav:"OptionId"`
+	Text     string `dynamodbav:"Text"`
+}
+
+type DdbKey struct {
+	PK string `dynamodbav:"PK"`
+	SK string `dynamodbav:"SK"`
+}
+
+// Edit is how the poll's options change: its options in their new order, and the options it removes.
+// New options have no votes, and the votes of the others are kept so the transaction can check them.
+// The write-in keys of written in options that are removed or renamed no longer match them, so they're
+// deleted too.
+type Edit struct {
+	Prompt        string
+	Options       []DdbOption
+	Added         map[string]bool
+	Removed       []DdbOption
+	StaleWriteIns []string
+}
+
+type Poll struct {
+	PollId     string   `json:"pollId"`
+	UserId     string   `json:"userId"`
+	Prompt     string   `json:"prompt"`
+	Options    []Option `json:"options"`
+	CreatedAt  string   `json:"createdAt"`
+	Duration   int      `json:"duration"`
+	IsArchived bool     `json:"isArchived"`
+}
+
+type Option struct {
+	OptionId    string `json:"optionId"`
+	Text        string `json:"text"`
+	Description string `json:"description,omitempty"`
+	Url         string `json:"url,omitempty"`
+	ImageUrl    string `json:"imageUrl,omitempty"`
+	UpdatedAt   string `json:"updatedAt"`
+	Votes       int    `json:"votes"`
+	Ballots     int    `json:"ballots"`
+	IsMyVote    bool   `json:"isMyVote"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+
+	// BatchWriteItem deletes at most 25 items per request
+	MaxBatchWriteItems   = 25
+	MaxBatchWriteRetries = 5
+)
+
+var (
+	errPollNotFound   = errors.New("poll not found")
+	errPollDeleted    = errors.New("poll was deleted")
+	errNotOwner       = errors.New("only the poll's owner and co-owners can edit it")
+	errHasVotes       = errors.New("the poll has votes; edit it with force to reset them")
+	errVersionChanged = errors.New("the poll changed while it was being edited; try again")
+	errVotesChanged   = errors.New("votes were counted while the poll was being edited; try again")
+)
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+// newEdit applies the request to the poll's options, generating IDs for the added ones
+func newEdit(
+	ddbPoll DdbPoll,
+	ddbOptions []DdbOption,
+	requestBody RequestBody,
+	generateId func() (string, error),
+) (Edit, error) {
+	edit := Edit{
+		Prompt:  ddbPoll.Prompt,
+		Options: ddbOptions,
+		Added:   map[string]bool{},
+	}
+	if requestBody.Prompt != nil {
+		edit.Prompt = *requestBody.Prompt
+	}
+	if requestBody.Options == nil {
+		return edit, nil
+	}
+
+	existing := make(map[string]DdbOption, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		existing[stripPrefix(ddbOption.PkOptionId, "option|")] = ddbOption
+	}
+
+	edit.Options = make([]DdbOption, 0, len(requestBody.Options))
+	kept := make(map[string]bool, len(requestBody.Options))
+	for index, requestOption := range requestBody.Options {
+		optionId := requestOption.OptionId
+
+		ddbOption, ok := existing[optionId]
+		switch {
+		case optionId == "":
+			generatedId, err := generateId()
+			if err != nil {
+				return Edit{}, err
+			}
+			optionId = generatedId
+
+			ddbOption = DdbOption{
+				PkOptionId:   fmt.Sprintf("option|%s", optionId),
+				SkOptionId:   fmt.Sprintf("option|%s", optionId),
+				Gsi1PkPollId: ddbPoll.PkPollId,
+				Gsi1SkPollId: ddbPoll.PkPollId,
+			}
+			edit.Added[optionId] = true
+		case !ok:
+			return Edit{}, fmt.Errorf("option %s isn't one of the poll's options", optionId)
+		case kept[optionId]:
+			return Edit{}, fmt.Errorf("option %s is repeated", optionId)
+		}
+
+		if ddbOption.WriteInStatus != "" && pollcreate.WriteInKey(ddbOption.Text) != pollcreate.WriteInKey(requestOption.Text) {
+			edit.StaleWriteIns = append(edit.StaleWriteIns, pollcreate.WriteInKey(ddbOption.Text))
+		}
+
+		kept[optionId] = true
+		ddbOption.Index = index
+		ddbOption.Text = requestOption.Text
+		edit.Options = append(edit.Options, ddbOption)
+	}
+
+	for _, ddbOption := range ddbOptions {
+		if !kept[stripPrefix(ddbOption.PkOptionId, "option|")] {
+			edit.Removed = append(edit.Removed, ddbOption)
+
+			if ddbOption.WriteInStatus != "" {
+				edit.StaleWriteIns = append(edit.StaleWriteIns, pollcreate.WriteInKey(ddbOption.Text))
+			}
+		}
+	}
+
+	return edit, nil
+}
+
+// newTransactItems builds the edit's transaction, which moves the poll and its options to the sequence number.
+// Without force, every option that exists must still have no votes; with force, each must still have the votes
+// that were read along with the poll's votes, so no vote interleaves with this one. Either way the poll's version
+// must be unchanged, so no other edit or write-in does either.
+func newTransactItems(
+	ddbPoll DdbPoll,
+	edit Edit,
+	force bool,
+	sequence int64,
+	currentTime string,
+) ([]types.TransactWriteItem, error) {
+	tableName := aws.String(os.Getenv("SINGLE_TABLE_NAME"))
+	nextVersion := strconv.FormatInt(sequence, 10)
+
+	// The poll counts its options' indexes, so an option written in after the edit goes after the last of them
+	lastOptionIndex := 0
+	editedOptions := make([]DdbEditedOption, 0, len(edit.Options))
+	for _, ddbOption := range edit.Options {
+		editedOptions = append(editedOptions, DdbEditedOption{
+			OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:     ddbOption.Text,
+		})
+
+		if ddbOption.Index > lastOptionIndex {
+			lastOptionIndex = ddbOption.Index
+		}
+	}
+	editedOptionsValue, err := attributevalue.Marshal(editedOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: tableName,
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+					"SK": &types.AttributeValueMemberS{Value: ddbPoll.SkPollId},
+				},
+				ConditionExpression: aws.String(
+					"attribute_exists(#pk) AND (attribute_not_exists(#version) OR #version = :currentVersion)",
+				),
+				UpdateExpression: aws.String(
+					"SET #prompt = :prompt, #editedAt = :editedAt, #editedOptions = :editedOptions, " +
+						"#lastOptionIndex = :lastOptionIndex, #version = :version",
+				),
+				ExpressionAttributeNames: map[string]string{
+					"#pk":              "PK",
+					"#version":         "Version",
+					"#prompt":          "Prompt",
+					"#editedAt":        "EditedAt",
+					"#editedOptions":   "EditedOptions",
+					"#lastOptionIndex": "LastOptionIndex",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":currentVersion":  &types.AttributeValueMemberN{Value: strconv.FormatInt(ddbPoll.Version, 10)},
+					":prompt":          &types.AttributeValueMemberS{Value: edit.Prompt},
+					":editedAt":        &types.AttributeValueMemberS{Value: currentTime},
+					":editedOptions":   editedOptionsValue,
+					":lastOptionIndex": &types.AttributeValueMemberN{Value: strconv.Itoa(lastOptionIndex)},
+					":version":         &types.AttributeValueMemberN{Value: nextVersion},
+				},
+			},
+		},
+	}
+
+	votesCondition := func(ddbOption DdbOption) (string, types.AttributeValue) {
+		votes := 0
+		if force {
+			votes = ddbOption.Votes
+		}
+
+		return "#poll = :poll AND #votes = :votes", &types.AttributeValueMemberN{Value: strconv.Itoa(votes)}
+	}
+
+	for _, ddbOption := range edit.Options {
+		optionId := stripPrefix(ddbOption.PkOptionId, "option|")
+
+		if edit.Added[optionId] {
+			ddbOption.UpdatedAt = currentTime
+			ddbOption.Votes = 0
+			ddbOption.Ballots = aws.Int(0)
+			ddbOption.Version = sequence
+
+			item, err := attributevalue.MarshalMap(ddbOption)
+			if err != nil {
+				return nil, err
+			}
+
+			transactItems = append(transactItems, types.TransactWriteItem{
+				Put: &types.Put{
+					TableName:           tableName,
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+					ExpressionAttributeNames: map[string]string{
+						"#pk": "PK",
+					},
+				},
+			})
+			continue
+		}
+
+		// The option's version moves with the poll's, so a reset count is newer than the counts it replaces
+		conditionExpression, votes := votesCondition(ddbOption)
+		updateExpression := "SET #text = :text, #index = :index, #version = :version"
+		expressionAttributeValues := map[string]types.AttributeValue{
+			":poll":    &types.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+			":votes":   votes,
+			":text":    &types.AttributeValueMemberS{Value: ddbOption.Text},
+			":index":   &types.AttributeValueMemberN{Value: strconv.Itoa(ddbOption.Index)},
+			":version": &types.AttributeValueMemberN{Value: nextVersion},
+		}
+		if force {
+			updateExpression += ", #votes = :zero, #ballots = :zero, #updatedAt = :updatedAt"
+			expressionAttributeValues[":zero"] = &types.AttributeValueMemberN{Value: "0"}
+			expressionAttributeValues[":updatedAt"] = &types.AttributeValueMemberS{Value: currentTime}
+		}
+
+		expressionAttributeNames := map[string]string{
+			"#poll":    "GSI1PK",
+			"#votes":   "Votes",
+			"#text":    "Text",
+			"#index":   "Index",
+			"#version": "Version",
+		}
+		if force {
+			expressionAttributeNames["#ballots"] = "Ballots"
+			expressionAttributeNames["#updatedAt"] = "UpdatedAt"
+		}
+
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: tableName,
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: ddbOption.PkOptionId},
+					"SK": &types.AttributeValueMemberS{Value: ddbOption.SkOptionId},
+				},
+				ConditionExpression:       aws.String(conditionExpression),
+				UpdateExpression:          aws.String(updateExpression),
+				ExpressionAttributeNames:  expressionAttributeNames,
+				ExpressionAttributeValues: expressionAttributeValues,
+			},
+		})
+	}
+
+	for _, ddbOption := range edit.Removed {
+		conditionExpression, votes := votesCondition(ddbOption)
+
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: tableName,
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: ddbOption.PkOptionId},
+					"SK": &types.AttributeValueMemberS{Value: ddbOption.SkOptionId},
+				},
+				ConditionExpression: aws.String(conditionExpression),
+				ExpressionAttributeNames: map[string]string{
+					"#poll":  "GSI1PK",
+					"#votes": "Votes",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":poll":  &types.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+					":votes": votes,
+				},
+			},
+		})
+	}
+
+	for _, sk := range edit.StaleWriteIns {
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: tableName,
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+					"SK": &types.AttributeValueMemberS{Value: sk},
+				},
+			},
+		})
+	}
+
+	return transactItems, nil
+}
+
+// getEditError explains why the edit's transaction was canceled: the poll's condition guards its version,
+// and the options' conditions guard their votes
+func getEditError(err error, force bool) error {
+	var transactionCanceled *types.TransactionCanceledException
+	if !errors.As(err, &transactionCanceled) {
+		return err
+	}
+
+	for index, reason := range transactionCanceled.CancellationReasons {
+		if aws.ToString(reason.Code) != "ConditionalCheckFailed" {
+			continue
+		}
+
+		if index == 0 {
+			return errVersionChanged
+		}
+		if force {
+			return errVotesChanged
+		}
+		return errHasVotes
+	}
+
+	return err
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (DdbPoll, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return DdbPoll{}, err
+	}
+	if result.Item == nil {
+		return DdbPoll{}, errPollNotFound
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(result.Item, &ddbPoll); err != nil {
+		return DdbPoll{}, err
+	}
+
+	return ddbPoll, nil
+}
+
+func getOptions(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbOption, error) {
+	result, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &ddbOptions); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	return ddbOptions, nil
+}
+
+// getVoteKeys finds the poll's votes, which are indexed by poll in GSI1
+func getVoteKeys(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbKey, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ProjectionExpression:   aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+			"#pk":   "PK",
+			"#sk":   "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("votes|%s", pollId),
+			},
+		},
+	})
+
+	var voteKeys []DdbKey
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageKeys []DdbKey
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageKeys); err != nil {
+			return nil, err
+		}
+		voteKeys = append(voteKeys, pageKeys...)
+	}
+
+	return voteKeys, nil
+}
+
+// getTimelineKeys finds the poll's vote timeline buckets, which a forced edit resets with its votes
+func getTimelineKeys(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbKey, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :poll AND begins_with(#sk, :timeline)"),
+		ProjectionExpression:   aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			":timeline": &types.AttributeValueMemberS{
+				Value: "timeline|",
+			},
+		},
+	})
+
+	var timelineKeys []DdbKey
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageKeys []DdbKey
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageKeys); err != nil {
+			return nil, err
+		}
+		timelineKeys = append(timelineKeys, pageKeys...)
+	}
+
+	return timelineKeys, nil
+}
+
+// deleteKeys deletes the votes that a forced edit reset, so their voters can vote again, and the
+// timeline buckets they were counted in
+func deleteKeys(ctx context.Context, ddb *dynamodb.Client, keys []DdbKey) error {
+	for start := 0; start < len(keys); start += MaxBatchWriteItems {
+		end := min(start+MaxBatchWriteItems, len(keys))
+
+		var writeRequests []types.WriteRequest
+		for _, ddbKey := range keys[start:end] {
+			key, err := attributevalue.MarshalMap(ddbKey)
+			if err != nil {
+				return err
+			}
+
+			writeRequests = append(writeRequests, types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: key},
+			})
+		}
+
+		requestItems := map[string][]types.WriteRequest{
+			os.Getenv("SINGLE_TABLE_NAME"): writeRequests,
+		}
+		for attempt := 1; len(requestItems) > 0; attempt++ {
+			if attempt > MaxBatchWriteRetries {
+				return errors.New("items were left unprocessed after retrying")
+			}
+
+			result, err := ddb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return err
+			}
+
+			requestItems = result.UnprocessedItems
+			if len(requestItems) > 0 {
+				time.Sleep(time.Duration(attempt*100) * time.Millisecond)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getBallots returns how many votes the option got, counting each once. Options whose votes were counted
+// before votes were weighed don't have ballots, but each of their votes counted once.
+func getBallots(ddbOption DdbOption) int {
+	if ddbOption.Ballots == nil {
+		return ddbOption.Votes
+	}
+
+	return *ddbOption.Ballots
+}
+
+func newPoll(ddbPoll DdbPoll, edit Edit, force bool, currentTime string) Poll {
+	options := make([]Option, 0, len(edit.Options))
+	for _, ddbOption := range edit.Options {
+		optionId := stripPrefix(ddbOption.PkOptionId, "option|")
+
+		updatedAt := ddbOption.UpdatedAt
+		if force || edit.Added[optionId] {
+			updatedAt = currentTime
+		}
+
+		options = append(options, Option{
+			OptionId:    optionId,
+			Text:        ddbOption.Text,
+			Description: ddbOption.Description,
+			Url:         ddbOption.Url,
+			ImageUrl:    ddbOption.ImageUrl,
+			UpdatedAt:   updatedAt,
+			Votes:       0,
+			Ballots:     0,
+			IsMyVote:    false,
+		})
+	}
+
+	return Poll{
+		PollId:     stripPrefix(ddbPoll.PkPollId, "poll|"),
+		UserId:     stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
+		Prompt:     edit.Prompt,
+		Options:    options,
+		CreatedAt:  ddbPoll.CreatedAt,
+		Duration:   ddbPoll.Duration,
+		IsArchived: ddbPoll.IsArchived,
+	}
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	currentTime := time.Now().UTC().Format(RFC3339Milli)
+	pollId := request.PathParameters["pollId"]
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	limits, err := pollcreate.GetLimits()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbPoll, err := getPoll(ctx, ddb, pollId)
+	if errors.Is(err, errPollNotFound) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", errPollDeleted),
+			},
+			errPollDeleted,
+		), nil
+	}
+
+	_, err = pollaccess.Authorize(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		userId,
+		pollaccess.ActionManage,
+	)
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", errNotOwner),
+			},
+			errNotOwner,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddbOptions, err := getOptions(ctx, ddb, pollId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	edit, err := newEdit(ddbPoll, ddbOptions, requestBody, func() (string, error) {
+		return nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	})
+	if err == nil {
+		err = limits.ValidatePrompt(edit.Prompt)
+	}
+	if err == nil {
+		texts := make([]string, 0, len(edit.Options))
+		for _, ddbOption := range edit.Options {
+			texts = append(texts, ddbOption.Text)
+		}
+		err = limits.ValidateOptions(texts)
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	// A forced edit deletes the votes it resets. Votes are written with their counts, so finding fewer
+	// votes than were counted means the index hasn't caught up with them yet. Votes are weighed, so
+	// they're compared with the options' ballots.
+	var voteKeys []DdbKey
+	if requestBody.Force {
+		voteKeys, err = getVoteKeys(ctx, ddb, pollId)
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+
+		counted := 0
+		for _, ddbOption := range ddbOptions {
+			counted += getBallots(ddbOption)
+		}
+		if len(voteKeys) != counted {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusConflict,
+					Body:       formatError("Conflict", errVotesChanged),
+				},
+				errVotesChanged,
+			), nil
+		}
+	}
+
+	sequence, err := pollsequence.Next(ctx, ddb, os.Getenv("SINGLE_TABLE_NAME"), pollId, ddbPoll.Version)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	transactItems, err := newTransactItems(ddbPoll, edit, requestBody.Force, sequence, currentTime)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		err = getEditError(err, requestBody.Force)
+
+		if errors.Is(err, errHasVotes) || errors.Is(err, errVersionChanged) || errors.Is(err, errVotesChanged) {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusConflict,
+					Body:       formatError("Conflict", err),
+				},
+				err,
+			), nil
+		}
+
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var timelineKeys []DdbKey
+	if requestBody.Force {
+		timelineKeys, err = getTimelineKeys(ctx, ddb, pollId)
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	if err := deleteKeys(ctx, ddb, append(voteKeys, timelineKeys...)); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	poll, err := json.Marshal(newPoll(ddbPoll, edit, requestBody.Force, currentTime))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(poll),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
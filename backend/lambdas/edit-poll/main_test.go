@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func testPoll() (DdbPoll, []DdbOption) {
+	ddbPoll := DdbPoll{
+		PkPollId:     "poll|poll123",
+		SkPollId:     "poll|poll123",
+		Gsi1PkUserId: "user|user1",
+		Prompt:       "Ship it?",
+		Version:      3,
+	}
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option1", SkOptionId: "option|option1", Gsi1PkPollId: "poll|poll123", Index: 0, Text: "Yes", Votes: 2},
+		{PkOptionId: "option|option2", SkOptionId: "option|option2", Gsi1PkPollId: "poll|poll123", Index: 1, Text: "No", Votes: 1},
+		{PkOptionId: "option|option3", SkOptionId: "option|option3", Gsi1PkPollId: "poll|poll123", Index: 2, Text: "Maybe"},
+	}
+
+	return ddbPoll, ddbOptions
+}
+
+func generateIds() func() (string, error) {
+	count := 0
+	return func() (string, error) {
+		count++
+		return fmt.Sprintf("new%d", count), nil
+	}
+}
+
+func TestNewEdit(t *testing.T) {
+	ddbPoll, ddbOptions := testPoll()
+	prompt := "Ship it today?"
+
+	edit, err := newEdit(ddbPoll, ddbOptions, RequestBody{
+		Prompt: &prompt,
+		Options: []RequestOption{
+			{OptionId: "option2", Text: "Not yet"},
+			{Text: "Tomorrow"},
+			{OptionId: "option1", Text: "Yes"},
+		},
+	}, generateIds())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if edit.Prompt != prompt {
+		t.Errorf("expected the prompt %q, got %q", prompt, edit.Prompt)
+	}
+
+	expected := []struct {
+		pk    string
+		text  string
+		votes int
+	}{
+		{pk: "option|option2", text: "Not yet", votes: 1},
+		{pk: "option|new1", text: "Tomorrow"},
+		{pk: "option|option1", text: "Yes", votes: 2},
+	}
+	if len(edit.Options) != len(expected) {
+		t.Fatalf("expected %d options, got %+v", len(expected), edit.Options)
+	}
+	for index, option := range edit.Options {
+		if option.PkOptionId != expected[index].pk || option.Text != expected[index].text ||
+			option.Votes != expected[index].votes || option.Index != index {
+			t.Errorf("unexpected option %d: %+v", index, option)
+		}
+	}
+	if !edit.Added["new1"] || len(edit.Added) != 1 {
+		t.Errorf("expected new1 to be added, got %v", edit.Added)
+	}
+	if edit.Options[1].Gsi1PkPollId != ddbPoll.PkPollId {
+		t.Errorf("expected the added option to belong to the poll, got %+v", edit.Options[1])
+	}
+	if len(edit.Removed) != 1 || edit.Removed[0].PkOptionId != "option|option3" {
+		t.Errorf("expected option3 to be removed, got %+v", edit.Removed)
+	}
+}
+
+func TestNewEditPromptOnly(t *testing.T) {
+	ddbPoll, ddbOptions := testPoll()
+	prompt := "Ship it today?"
+
+	edit, err := newEdit(ddbPoll, ddbOptions, RequestBody{Prompt: &prompt}, generateIds())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(edit.Options) != len(ddbOptions) || len(edit.Added) != 0 || len(edit.Removed) != 0 {
+		t.Errorf("expected the options to be unchanged, got %+v", edit)
+	}
+}
+
+func TestNewEditErrors(t *testing.T) {
+	ddbPoll, ddbOptions := testPoll()
+
+	tests := map[string][]RequestOption{
+		"unknown option":  {{OptionId: "option1", Text: "Yes"}, {OptionId: "other", Text: "No"}},
+		"repeated option": {{OptionId: "option1", Text: "Yes"}, {OptionId: "option1", Text: "No"}},
+	}
+
+	for name, options := range tests {
+		if _, err := newEdit(ddbPoll, ddbOptions, RequestBody{Options: options}, generateIds()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNewTransactItems(t *testing.T) {
+	ddbPoll, ddbOptions := testPoll()
+
+	edit, err := newEdit(ddbPoll, ddbOptions, RequestBody{
+		Options: []RequestOption{
+			{OptionId: "option1", Text: "Yes"},
+			{Text: "No"},
+		},
+	}, generateIds())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, force := range []bool{false, true} {
+		transactItems, err := newTransactItems(ddbPoll, edit, force, 4, "2024-01-01T00:00:00Z")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(transactItems) != 5 {
+			t.Fatalf("expected the poll, 2 options and 2 removals, got %d items", len(transactItems))
+		}
+		version := transactItems[0].Update.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value
+		if version != "4" {
+			t.Errorf("expected the poll's version to be 4, got %s", version)
+		}
+		lastOptionIndex := transactItems[0].Update.ExpressionAttributeValues[":lastOptionIndex"].(*types.AttributeValueMemberN).Value
+		if lastOptionIndex != "1" {
+			t.Errorf("expected the poll's last option to be at index 1, got %s", lastOptionIndex)
+		}
+
+		kept := transactItems[1].Update
+		votes := kept.ExpressionAttributeValues[":votes"].(*types.AttributeValueMemberN).Value
+		if force && (votes != "2" || kept.ExpressionAttributeValues[":zero"] == nil) {
+			t.Errorf("expected a forced edit to reset the counted votes, got %s in %s", votes, aws.ToString(kept.UpdateExpression))
+		}
+		if !force && (votes != "0" || kept.ExpressionAttributeValues[":zero"] != nil) {
+			t.Errorf("expected an edit to require no votes, got %s in %s", votes, aws.ToString(kept.UpdateExpression))
+		}
+
+		if transactItems[2].Put == nil || transactItems[3].Delete == nil || transactItems[4].Delete == nil {
+			t.Errorf("expected the added option to be put and the others deleted, got %+v", transactItems[2:])
+		}
+	}
+}
+
+func TestNewEditWriteIns(t *testing.T) {
+	ddbPoll, ddbOptions := testPoll()
+	ddbOptions = append(ddbOptions,
+		DdbOption{PkOptionId: "option|option4", Gsi1PkPollId: "poll|poll123", Index: 3, Text: "Later", WriteInStatus: "approved"},
+		DdbOption{PkOptionId: "option|option5", Gsi1PkPollId: "poll|poll123", Index: 4, Text: "Never", WriteInStatus: "pending"},
+		DdbOption{PkOptionId: "option|option6", Gsi1PkPollId: "poll|poll123", Index: 5, Text: "Soon", WriteInStatus: "approved"},
+	)
+
+	edit, err := newEdit(ddbPoll, ddbOptions, RequestBody{
+		Options: []RequestOption{
+			{OptionId: "option1", Text: "Yes"},
+			{OptionId: "option4", Text: "Much later"},
+			{OptionId: "option6", Text: "SOON"},
+		},
+	}, generateIds())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"writein|later", "writein|never"}
+	if fmt.Sprint(edit.StaleWriteIns) != fmt.Sprint(expected) {
+		t.Errorf("expected the write-in keys %v to be deleted, got %v", expected, edit.StaleWriteIns)
+	}
+}
+
+func TestGetEditError(t *testing.T) {
+	canceled := func(failed int) error {
+		reasons := make([]types.CancellationReason, 3)
+		for index := range reasons {
+			reasons[index].Code = aws.String("None")
+		}
+		reasons[failed].Code = aws.String("ConditionalCheckFailed")
+
+		return &types.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	tests := []struct {
+		err      error
+		force    bool
+		expected error
+	}{
+		{err: canceled(0), expected: errVersionChanged},
+		{err: canceled(2), expected: errHasVotes},
+		{err: canceled(2), force: true, expected: errVotesChanged},
+	}
+
+	for _, test := range tests {
+		if err := getEditError(test.err, test.force); !errors.Is(err, test.expected) {
+			t.Errorf("expected %v, got %v", test.expected, err)
+		}
+	}
+}
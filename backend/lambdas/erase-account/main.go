@@ -0,0 +1,993 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+type NanoIdOptions struct {
+	Alphabet string
+	Length   int
+}
+
+// DdbUserItem is any item indexed by its user in GSI1, which is either a poll, an API key, a template, a
+// recurring poll series or a membership of someone else's poll
+type DdbUserItem struct {
+	PkId         string `dynamodbav:"PK"`
+	SkId         string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type DdbPoll struct {
+	PkPollId    string          `dynamodbav:"PK"`
+	Version     int64           `dynamodbav:"Version"`
+	DeletedAt   string          `dynamodbav:"DeletedAt"`
+	VoteWeights *DdbVoteWeights `dynamodbav:"VoteWeights"`
+}
+
+// DdbVoteWeights is the part of a poll's vote weights that names its voters
+type DdbVoteWeights struct {
+	Users map[string]int `dynamodbav:"Users"`
+}
+
+// DdbVote is the user's vote. VotedAt is when it was cast, which picked the timeline buckets it was
+// counted in; votes cast before it was recorded don't have it.
+type DdbVote struct {
+	PkVoterId string `dynamodbav:"PK"`
+	SkPollId  string `dynamodbav:"SK"`
+	OptionId  string `dynamodbav:"OptionId"`
+	Weight    int    `dynamodbav:"Weight"`
+	VotedAt   string `dynamodbav:"VotedAt"`
+}
+
+// TimelineBucket is a width of the vote timeline's buckets. Each vote is counted in a bucket of each width.
+type TimelineBucket struct {
+	Name string
+	Size time.Duration
+}
+
+// DdbAnonymousVote keeps a vote counted without its voter. Its partition isn't prefixed with voter|,
+// so it isn't mistaken for a new vote by the stream, but it's still indexed by its poll in GSI1.
+type DdbAnonymousVote struct {
+	PkAnonymousId     string `dynamodbav:"PK"`
+	SkPollId          string `dynamodbav:"SK"`
+	Gsi1PkPollId      string `dynamodbav:"GSI1PK"`
+	Gsi1SkAnonymousId string `dynamodbav:"GSI1SK"`
+	OptionId          string `dynamodbav:"OptionId"`
+	Weight            int    `dynamodbav:"Weight,omitempty"`
+}
+
+// DdbErasure is the audit record of an erasure. It identifies the user only by a hash of their id,
+// and is indexed by that hash in GSI1 so every erasure of a user can be listed.
+type DdbErasure struct {
+	PkErasureId        string `dynamodbav:"PK"`
+	SkErasureId        string `dynamodbav:"SK"`
+	Gsi1PkSubject      string `dynamodbav:"GSI1PK"`
+	Gsi1SkErasedAt     string `dynamodbav:"GSI1SK"`
+	SubjectHash        string `dynamodbav:"SubjectHash"`
+	VoteErasurePolicy  string `dynamodbav:"VoteErasurePolicy"`
+	RequestedAt        string `dynamodbav:"RequestedAt"`
+	FinishedAt         string `dynamodbav:"FinishedAt"`
+	IsComplete         bool   `dynamodbav:"IsComplete"`
+	PollsDeleted       int    `dynamodbav:"PollsDeleted"`
+	ApiKeysDeleted     int    `dynamodbav:"ApiKeysDeleted"`
+	TemplatesDeleted   int    `dynamodbav:"TemplatesDeleted"`
+	SeriesDeleted      int    `dynamodbav:"SeriesDeleted"`
+	MembershipsDeleted int    `dynamodbav:"MembershipsDeleted"`
+	VotesRemoved       int    `dynamodbav:"VotesRemoved"`
+	VotesAnonymized    int    `dynamodbav:"VotesAnonymized"`
+}
+
+type Erasure struct {
+	ErasureId          string `json:"erasureId"`
+	SubjectHash        string `json:"subjectHash"`
+	VoteErasurePolicy  string `json:"voteErasurePolicy"`
+	RequestedAt        string `json:"requestedAt"`
+	FinishedAt         string `json:"finishedAt"`
+	IsComplete         bool   `json:"isComplete"`
+	PollsDeleted       int    `json:"pollsDeleted"`
+	ApiKeysDeleted     int    `json:"apiKeysDeleted"`
+	TemplatesDeleted   int    `json:"templatesDeleted"`
+	SeriesDeleted      int    `json:"seriesDeleted"`
+	MembershipsDeleted int    `json:"membershipsDeleted"`
+	VotesRemoved       int    `json:"votesRemoved"`
+	VotesAnonymized    int    `json:"votesAnonymized"`
+}
+
+type DeletionRequestedDetail struct {
+	PollId string `json:"pollId"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+
+	// ResumeMargin is how long before the invocation times out that the erasure stops, so its record
+	// can still be written. Erasing again continues where it stopped.
+	ResumeMargin = 10 * time.Second
+
+	maxRemoveVoteAttempts = 5
+	removeVoteBackoff     = 25 * time.Millisecond
+	maxRemoveVoteBackoff  = time.Second
+)
+
+// Votes on other users' polls are either removed along with the counts they added, or kept counted
+// under an anonymous id that can't be traced back to the user
+const (
+	VoteErasurePolicyRemove    = "remove"
+	VoteErasurePolicyAnonymize = "anonymize"
+)
+
+var (
+	errOutOfTime      = errors.New("out of time")
+	errPollDeleted    = errors.New("poll was deleted while removing the vote")
+	errConflict       = errors.New("vote erasure conflicted with another write")
+	errVoteNotFound   = errors.New("vote was already erased")
+	errUnknownPolicy  = errors.New("VOTE_ERASURE_POLICY must be remove or anonymize")
+	errOptionNotFound = errors.New("voted option no longer belongs to the poll")
+
+	errTimelineNotFound = errors.New("vote's timeline bucket no longer exists")
+)
+
+var timelineBuckets = []TimelineBucket{
+	{Name: "1m", Size: time.Minute},
+	{Name: "1h", Size: time.Hour},
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func getNanoIdOptions() (NanoIdOptions, error) {
+	alphabet := os.Getenv("NANOID_ALPHABET")
+	length, err := strconv.Atoi(os.Getenv("NANOID_LENGTH"))
+	if err != nil {
+		return NanoIdOptions{}, err
+	}
+	if !(length > 2 && length < 36) {
+		return NanoIdOptions{}, errors.New("NANOID_LENGTH must be between 2 and 36")
+	}
+	return NanoIdOptions{
+		Alphabet: alphabet,
+		Length:   length,
+	}, nil
+}
+
+func getVoteErasurePolicy() (string, error) {
+	switch policy := os.Getenv("VOTE_ERASURE_POLICY"); policy {
+	case "":
+		return VoteErasurePolicyRemove, nil
+	case VoteErasurePolicyRemove, VoteErasurePolicyAnonymize:
+		return policy, nil
+	default:
+		return "", errUnknownPolicy
+	}
+}
+
+func hashSubject(userId string) string {
+	hash := sha256.Sum256([]byte(userId))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func newDdbErasure(erasure Erasure) DdbErasure {
+	return DdbErasure{
+		PkErasureId:        fmt.Sprintf("erasure|%s", erasure.ErasureId),
+		SkErasureId:        fmt.Sprintf("erasure|%s", erasure.ErasureId),
+		Gsi1PkSubject:      fmt.Sprintf("erasure|%s", erasure.SubjectHash),
+		Gsi1SkErasedAt:     fmt.Sprintf("erasure|%s", erasure.RequestedAt),
+		SubjectHash:        erasure.SubjectHash,
+		VoteErasurePolicy:  erasure.VoteErasurePolicy,
+		RequestedAt:        erasure.RequestedAt,
+		FinishedAt:         erasure.FinishedAt,
+		IsComplete:         erasure.IsComplete,
+		PollsDeleted:       erasure.PollsDeleted,
+		ApiKeysDeleted:     erasure.ApiKeysDeleted,
+		TemplatesDeleted:   erasure.TemplatesDeleted,
+		SeriesDeleted:      erasure.SeriesDeleted,
+		MembershipsDeleted: erasure.MembershipsDeleted,
+		VotesRemoved:       erasure.VotesRemoved,
+		VotesAnonymized:    erasure.VotesAnonymized,
+	}
+}
+
+func tableKey(pk string, sk string) map[string]ddbTypes.AttributeValue {
+	return map[string]ddbTypes.AttributeValue{
+		"PK": &ddbTypes.AttributeValueMemberS{Value: pk},
+		"SK": &ddbTypes.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// deleteVoteItem is the part of every vote erasure that deletes the vote itself. Its condition fails
+// if a concurrent erasure already deleted it.
+func deleteVoteItem(ddbVote DdbVote) *ddbTypes.Delete {
+	return &ddbTypes.Delete{
+		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key:                 tableKey(ddbVote.PkVoterId, ddbVote.SkPollId),
+		ConditionExpression: aws.String("attribute_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+		},
+	}
+}
+
+// getWeight is how much the vote counted for. Votes counted before votes were weighed don't have a
+// weight, but each counted once.
+func getWeight(ddbVote DdbVote) int {
+	if ddbVote.Weight == 0 {
+		return 1
+	}
+
+	return ddbVote.Weight
+}
+
+// newPollItem checks that the vote's poll hasn't been deleted. If the poll weighs the voter, it also removes
+// their weight, so the erased user's id isn't left on the poll; a poll's weights don't change once it's
+// created, so the poll read before the erasure tells whether it does.
+func newPollItem(ddbVote DdbVote, ddbPoll DdbPoll) ddbTypes.TransactWriteItem {
+	conditionExpression := aws.String("attribute_exists(#pk) AND attribute_not_exists(#deletedAt)")
+	expressionAttributeNames := map[string]string{
+		"#pk":        "PK",
+		"#deletedAt": "DeletedAt",
+	}
+
+	voterId := stripPrefix(ddbVote.PkVoterId, "voter|")
+
+	var weightedUsers map[string]int
+	if ddbPoll.VoteWeights != nil {
+		weightedUsers = ddbPoll.VoteWeights.Users
+	}
+	if _, ok := weightedUsers[voterId]; !ok {
+		return ddbTypes.TransactWriteItem{
+			ConditionCheck: &ddbTypes.ConditionCheck{
+				TableName:                aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+				Key:                      tableKey(ddbPoll.PkPollId, ddbPoll.PkPollId),
+				ConditionExpression:      conditionExpression,
+				ExpressionAttributeNames: expressionAttributeNames,
+			},
+		}
+	}
+
+	expressionAttributeNames["#voteWeights"] = "VoteWeights"
+	expressionAttributeNames["#users"] = "Users"
+	expressionAttributeNames["#voter"] = voterId
+
+	return ddbTypes.TransactWriteItem{
+		Update: &ddbTypes.Update{
+			TableName:                aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+			Key:                      tableKey(ddbPoll.PkPollId, ddbPoll.PkPollId),
+			ConditionExpression:      conditionExpression,
+			UpdateExpression:         aws.String("REMOVE #voteWeights.#users.#voter"),
+			ExpressionAttributeNames: expressionAttributeNames,
+		},
+	}
+}
+
+// newTimelineUpdates takes the vote off the buckets of its option's timeline that it was counted in. Votes
+// that don't record when they were cast are left in their buckets, which can't be told.
+func newTimelineUpdates(ddbVote DdbVote) ([]ddbTypes.TransactWriteItem, error) {
+	if ddbVote.VotedAt == "" {
+		return nil, nil
+	}
+
+	votedAt, err := time.Parse(time.RFC3339, ddbVote.VotedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	transactItems := make([]ddbTypes.TransactWriteItem, 0, len(timelineBuckets))
+	for _, bucket := range timelineBuckets {
+		bucketStart := votedAt.UTC().Truncate(bucket.Size).Format(RFC3339Milli)
+
+		transactItems = append(transactItems, ddbTypes.TransactWriteItem{
+			Update: &ddbTypes.Update{
+				TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+				Key: tableKey(
+					ddbVote.SkPollId,
+					fmt.Sprintf("timeline|%s|%s|%s", bucket.Name, bucketStart, ddbVote.OptionId),
+				),
+				ConditionExpression: aws.String("#votes >= :vote"),
+				UpdateExpression:    aws.String("SET #votes = #votes - :vote"),
+				ExpressionAttributeNames: map[string]string{
+					"#votes": "Votes",
+				},
+				ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+					":vote": &ddbTypes.AttributeValueMemberN{Value: strconv.Itoa(getWeight(ddbVote))},
+				},
+			},
+		})
+	}
+
+	return transactItems, nil
+}
+
+// newRemoveVoteItems deletes the vote and takes it off its option's count and, unless withoutTimeline is
+// set, its timeline buckets. Like counting a vote, it stamps the option with the poll's next sequence
+// number so the new count is published in order, and only writes the poll to remove the voter's weight.
+func newRemoveVoteItems(
+	ddbVote DdbVote,
+	ddbPoll DdbPoll,
+	sequence int64,
+	currentTime string,
+	withoutTimeline bool,
+) ([]ddbTypes.TransactWriteItem, error) {
+	weight := strconv.Itoa(getWeight(ddbVote))
+
+	transactItems := []ddbTypes.TransactWriteItem{
+		{Delete: deleteVoteItem(ddbVote)},
+		{
+			Update: &ddbTypes.Update{
+				TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+				Key: tableKey(
+					fmt.Sprintf("option|%s", ddbVote.OptionId),
+					fmt.Sprintf("option|%s", ddbVote.OptionId),
+				),
+				ConditionExpression: aws.String("#poll = :poll AND #votes >= :vote"),
+				UpdateExpression: aws.String(
+					"SET #votes = #votes - :vote, #ballots = if_not_exists(#ballots, #votes) - :ballot, #updatedAt = :updatedAt, #version = :version",
+				),
+				ExpressionAttributeNames: map[string]string{
+					"#poll":      "GSI1PK",
+					"#votes":     "Votes",
+					"#ballots":   "Ballots",
+					"#updatedAt": "UpdatedAt",
+					"#version":   "Version",
+				},
+				ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+					":poll":      &ddbTypes.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+					":vote":      &ddbTypes.AttributeValueMemberN{Value: weight},
+					":ballot":    &ddbTypes.AttributeValueMemberN{Value: "1"},
+					":updatedAt": &ddbTypes.AttributeValueMemberS{Value: currentTime},
+					":version":   &ddbTypes.AttributeValueMemberN{Value: strconv.FormatInt(sequence, 10)},
+				},
+			},
+		},
+		newPollItem(ddbVote, ddbPoll),
+	}
+	if withoutTimeline {
+		return transactItems, nil
+	}
+
+	timelineUpdates, err := newTimelineUpdates(ddbVote)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(transactItems, timelineUpdates...), nil
+}
+
+// newAnonymizeVoteItems replaces the vote with an anonymous copy, so its option's count stays the same,
+// and removes the voter's weight from the poll
+func newAnonymizeVoteItems(ddbVote DdbVote, ddbPoll DdbPoll, anonymousId string) ([]ddbTypes.TransactWriteItem, error) {
+	pollId := stripPrefix(ddbVote.SkPollId, "poll|")
+
+	item, err := attributevalue.MarshalMap(DdbAnonymousVote{
+		PkAnonymousId:     fmt.Sprintf("anonvote|%s", anonymousId),
+		SkPollId:          ddbVote.SkPollId,
+		Gsi1PkPollId:      fmt.Sprintf("votes|%s", pollId),
+		Gsi1SkAnonymousId: fmt.Sprintf("anonvote|%s", anonymousId),
+		OptionId:          ddbVote.OptionId,
+		Weight:            ddbVote.Weight,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []ddbTypes.TransactWriteItem{
+		{Delete: deleteVoteItem(ddbVote)},
+		{
+			Put: &ddbTypes.Put{
+				TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+				ExpressionAttributeNames: map[string]string{
+					"#pk": "PK",
+				},
+			},
+		},
+		newPollItem(ddbVote, ddbPoll),
+	}, nil
+}
+
+// getBackoff is how long to wait before retrying the vote's erasure, with full jitter so that it doesn't retry
+// in lockstep with the votes it conflicted with
+func getBackoff(attempt int) time.Duration {
+	backoff := removeVoteBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > maxRemoveVoteBackoff {
+		backoff = maxRemoveVoteBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// getEraseVoteError tells apart which condition canceled a vote's erasure: the vote being gone already,
+// its option no longer counting it, its poll being deleted meanwhile, or its timeline bucket having
+// expired. It also tells a transaction that conflicted with a concurrent one, which can be retried as it is.
+func getEraseVoteError(err error) error {
+	var transactionCanceled *ddbTypes.TransactionCanceledException
+	if !errors.As(err, &transactionCanceled) {
+		return err
+	}
+
+	for index, reason := range transactionCanceled.CancellationReasons {
+		if aws.ToString(reason.Code) == "TransactionConflict" {
+			return errConflict
+		}
+		if aws.ToString(reason.Code) != "ConditionalCheckFailed" {
+			continue
+		}
+
+		switch index {
+		case 0:
+			return errVoteNotFound
+		case 1:
+			return errOptionNotFound
+		case 2:
+			return errPollDeleted
+		default:
+			return errTimelineNotFound
+		}
+	}
+
+	return err
+}
+
+func getUserItems(ctx context.Context, ddb *dynamodb.Client, userId string) ([]DdbUserItem, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#user = :user"),
+		ExpressionAttributeNames: map[string]string{
+			"#user": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":user": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("user|%s", userId),
+			},
+		},
+	})
+
+	var userItems []DdbUserItem
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []DdbUserItem
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageItems); err != nil {
+			return nil, err
+		}
+		userItems = append(userItems, pageItems...)
+	}
+
+	return userItems, nil
+}
+
+func getVotes(ctx context.Context, ddb *dynamodb.Client, userId string) ([]DdbVote, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#voter = :voter AND begins_with(#poll, :poll)"),
+		ExpressionAttributeNames: map[string]string{
+			"#voter": "PK",
+			"#poll":  "SK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":voter": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("voter|%s", userId),
+			},
+			":poll": &ddbTypes.AttributeValueMemberS{
+				Value: "poll|",
+			},
+		},
+	})
+
+	var ddbVotes []DdbVote
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageVotes []DdbVote
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageVotes); err != nil {
+			return nil, err
+		}
+		ddbVotes = append(ddbVotes, pageVotes...)
+	}
+
+	return ddbVotes, nil
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pk string) (*DdbPoll, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key:            tableKey(pk, pk),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(result.Item, &ddbPoll); err != nil {
+		return nil, err
+	}
+
+	return &ddbPoll, nil
+}
+
+// markDeleted marks the user's poll as deleted, just as deleting it would
+func markDeleted(ctx context.Context, ddb *dynamodb.Client, userItem DdbUserItem, currentTime string) error {
+	tableName := os.Getenv("SINGLE_TABLE_NAME")
+
+	sequence, err := pollsequence.Next(ctx, ddb, tableName, stripPrefix(userItem.PkId, "poll|"), userItem.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 tableKey(userItem.PkId, userItem.SkId),
+		ConditionExpression: aws.String("#user = :user AND attribute_not_exists(#deletedAt)"),
+		UpdateExpression:    aws.String("SET #deletedAt = :deletedAt, #version = :version REMOVE #open, #expiresAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#user":      "GSI1PK",
+			"#deletedAt": "DeletedAt",
+			"#version":   "Version",
+			"#open":      "GSI2PK",
+			"#expiresAt": "GSI2SK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":user":      &ddbTypes.AttributeValueMemberS{Value: userItem.Gsi1PkUserId},
+			":deletedAt": &ddbTypes.AttributeValueMemberS{Value: currentTime},
+			":version":   &ddbTypes.AttributeValueMemberN{Value: strconv.FormatInt(sequence, 10)},
+		},
+	})
+
+	var conditionalCheckFailed *ddbTypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return nil
+	}
+
+	return err
+}
+
+// requestDeletion hands the poll to the poll deleter, which deletes its options, votes, webhooks and
+// chat messages before removing its owner
+func requestDeletion(ctx context.Context, ebClient *eventbridge.Client, pollId string) error {
+	detail, err := json.Marshal(DeletionRequestedDetail{PollId: pollId})
+	if err != nil {
+		return err
+	}
+
+	result, err := ebClient.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebTypes.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(os.Getenv("EVENT_BUS_NAME")),
+				Source:       aws.String(os.Getenv("DELETION_REQUESTED_SOURCE")),
+				DetailType:   aws.String(os.Getenv("DELETION_REQUESTED_DETAIL_TYPE")),
+				Detail:       aws.String(string(detail)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.FailedEntryCount > 0 {
+		return errors.New(aws.ToString(result.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+// deleteUserItem deletes an item that only belongs to the user, like an API key, a template or their
+// membership of someone else's poll
+func deleteUserItem(ctx context.Context, ddb *dynamodb.Client, userItem DdbUserItem) error {
+	_, err := ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key:       tableKey(userItem.PkId, userItem.SkId),
+	})
+
+	return err
+}
+
+// deleteSeries deletes a recurring poll series' occurrences and then the series itself, so an erasure
+// that stops part way through finds the series again when it resumes. The polls it created are the
+// user's, so they're deleted with the rest of their polls.
+func deleteSeries(ctx context.Context, ddb *dynamodb.Client, userItem DdbUserItem) error {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :series AND begins_with(#sk, :occurrence)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":series": &ddbTypes.AttributeValueMemberS{
+				Value: userItem.PkId,
+			},
+			":occurrence": &ddbTypes.AttributeValueMemberS{
+				Value: "occurrence|",
+			},
+		},
+		ProjectionExpression: aws.String("#pk, #sk"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var occurrences []DdbUserItem
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &occurrences); err != nil {
+			return err
+		}
+
+		for _, occurrence := range occurrences {
+			if err := deleteUserItem(ctx, ddb, occurrence); err != nil {
+				return err
+			}
+		}
+	}
+
+	return deleteUserItem(ctx, ddb, userItem)
+}
+
+// eraseVote erases one of the user's votes by the policy, and returns the policy it was erased by, or
+// nothing if it was already erased. Votes on deleted polls are removed without touching their counts,
+// which are being deleted too.
+func eraseVote(
+	ctx context.Context,
+	ddb *dynamodb.Client,
+	ddbVote DdbVote,
+	policy string,
+	nanoIdOptions NanoIdOptions,
+	currentTime string,
+) (string, error) {
+	withoutTimeline := false
+	for attempt := 1; ; attempt++ {
+		ddbPoll, err := getPoll(ctx, ddb, ddbVote.SkPollId)
+		if err != nil {
+			return "", err
+		}
+
+		applied := policy
+		var transactItems []ddbTypes.TransactWriteItem
+		switch {
+		case ddbPoll == nil || ddbPoll.DeletedAt != "":
+			applied = VoteErasurePolicyRemove
+			transactItems = []ddbTypes.TransactWriteItem{{Delete: deleteVoteItem(ddbVote)}}
+		case policy == VoteErasurePolicyRemove:
+			sequence, err := pollsequence.Next(
+				ctx,
+				ddb,
+				os.Getenv("SINGLE_TABLE_NAME"),
+				stripPrefix(ddbPoll.PkPollId, "poll|"),
+				ddbPoll.Version,
+			)
+			if err != nil {
+				return "", err
+			}
+
+			transactItems, err = newRemoveVoteItems(ddbVote, *ddbPoll, sequence, currentTime, withoutTimeline)
+			if err != nil {
+				return "", err
+			}
+		default:
+			anonymousId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+			if err != nil {
+				return "", err
+			}
+
+			transactItems, err = newAnonymizeVoteItems(ddbVote, *ddbPoll, anonymousId)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
+		})
+		if err == nil {
+			return applied, nil
+		}
+
+		err = getEraseVoteError(err)
+		if errors.Is(err, errVoteNotFound) {
+			return "", nil
+		}
+
+		// A bucket that expired has nothing left to take the vote off, so the vote is removed without it
+		if errors.Is(err, errTimelineNotFound) {
+			withoutTimeline = true
+		}
+		isRetryable := errors.Is(err, errPollDeleted) || errors.Is(err, errTimelineNotFound) || errors.Is(err, errConflict)
+		if !isRetryable || attempt == maxRemoveVoteAttempts {
+			return "", err
+		}
+
+		backoff := getBackoff(attempt)
+		log.Printf("Retrying vote erasure on %s in %s after attempt %d: %s\n", ddbVote.SkPollId, backoff, attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// erase deletes the user's polls, API keys, templates, series and memberships of other users' polls, then erases
+// their votes, counting what it erased in the record. It returns errOutOfTime if the deadline is near before
+// everything has been erased.
+func erase(
+	ctx context.Context,
+	ddb *dynamodb.Client,
+	ebClient *eventbridge.Client,
+	userId string,
+	nanoIdOptions NanoIdOptions,
+	erasure *Erasure,
+	deadline time.Time,
+) error {
+	userItems, err := getUserItems(ctx, ddb, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, userItem := range userItems {
+		if time.Until(deadline) < ResumeMargin {
+			return errOutOfTime
+		}
+
+		switch {
+		// Memberships share their poll's partition, so they're told apart from it by their sort key
+		case strings.HasPrefix(userItem.SkId, pollaccess.MemberPrefix):
+			if err := deleteUserItem(ctx, ddb, userItem); err != nil {
+				return err
+			}
+			erasure.MembershipsDeleted++
+		case strings.HasPrefix(userItem.PkId, "poll|"):
+			if userItem.DeletedAt == "" {
+				if err := markDeleted(ctx, ddb, userItem, erasure.RequestedAt); err != nil {
+					return err
+				}
+			}
+
+			// Polls that were already being deleted are requested again, in case their deletion stopped
+			if err := requestDeletion(ctx, ebClient, stripPrefix(userItem.PkId, "poll|")); err != nil {
+				return err
+			}
+			erasure.PollsDeleted++
+		case strings.HasPrefix(userItem.PkId, "apikey|"):
+			if err := deleteUserItem(ctx, ddb, userItem); err != nil {
+				return err
+			}
+			erasure.ApiKeysDeleted++
+		case strings.HasPrefix(userItem.PkId, "template|"):
+			if err := deleteUserItem(ctx, ddb, userItem); err != nil {
+				return err
+			}
+			erasure.TemplatesDeleted++
+		case strings.HasPrefix(userItem.PkId, "series|"):
+			if err := deleteSeries(ctx, ddb, userItem); err != nil {
+				return err
+			}
+			erasure.SeriesDeleted++
+		}
+	}
+
+	ddbVotes, err := getVotes(ctx, ddb, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, ddbVote := range ddbVotes {
+		if time.Until(deadline) < ResumeMargin {
+			return errOutOfTime
+		}
+
+		applied, err := eraseVote(ctx, ddb, ddbVote, erasure.VoteErasurePolicy, nanoIdOptions, erasure.RequestedAt)
+		if err != nil {
+			return err
+		}
+
+		switch applied {
+		case VoteErasurePolicyRemove:
+			erasure.VotesRemoved++
+		case VoteErasurePolicyAnonymize:
+			erasure.VotesAnonymized++
+		}
+	}
+
+	return nil
+}
+
+func putErasure(ctx context.Context, ddb *dynamodb.Client, erasure Erasure) error {
+	item, err := attributevalue.MarshalMap(newDdbErasure(erasure))
+	if err != nil {
+		return err
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:      item,
+	})
+
+	return err
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	requestedAt := time.Now().UTC().Format(RFC3339Milli)
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+
+	policy, err := getVoteErasurePolicy()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	nanoIdOptions, err := getNanoIdOptions()
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	erasureId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+	ebClient := eventbridge.NewFromConfig(cfg)
+
+	erasure := Erasure{
+		ErasureId:         erasureId,
+		SubjectHash:       hashSubject(userId),
+		VoteErasurePolicy: policy,
+		RequestedAt:       requestedAt,
+	}
+
+	// Whatever was erased before an error or the deadline is recorded all the same
+	eraseErr := erase(ctx, ddb, ebClient, userId, nanoIdOptions, &erasure, deadline)
+	erasure.FinishedAt = time.Now().UTC().Format(RFC3339Milli)
+	erasure.IsComplete = eraseErr == nil
+
+	if err := putErasure(ctx, ddb, erasure); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", errors.Join(eraseErr, err)),
+			},
+			errors.Join(eraseErr, err),
+		), nil
+	}
+
+	if eraseErr != nil && !errors.Is(eraseErr, errOutOfTime) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", eraseErr),
+			},
+			eraseErr,
+		), nil
+	}
+
+	body, err := json.Marshal(erasure)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// An erasure that ran out of time is accepted, and erasing again continues it
+	statusCode := http.StatusOK
+	if !erasure.IsComplete {
+		statusCode = http.StatusAccepted
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       string(body),
+		},
+		eraseErr,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func testVote() DdbVote {
+	return DdbVote{PkVoterId: "voter|user123", SkPollId: "poll|poll123", OptionId: "option1"}
+}
+
+func TestGetVoteErasurePolicy(t *testing.T) {
+	tests := map[string]string{
+		"":          VoteErasurePolicyRemove,
+		"remove":    VoteErasurePolicyRemove,
+		"anonymize": VoteErasurePolicyAnonymize,
+	}
+
+	for value, expected := range tests {
+		t.Setenv("VOTE_ERASURE_POLICY", value)
+		if policy, err := getVoteErasurePolicy(); err != nil || policy != expected {
+			t.Errorf("%q: expected %s, got %s (%v)", value, expected, policy, err)
+		}
+	}
+
+	t.Setenv("VOTE_ERASURE_POLICY", "keep")
+	if _, err := getVoteErasurePolicy(); !errors.Is(err, errUnknownPolicy) {
+		t.Errorf("expected an unknown policy to be rejected, got %v", err)
+	}
+}
+
+func TestNewDdbErasure(t *testing.T) {
+	subjectHash := hashSubject("user123")
+	if subjectHash == "user123" || len(subjectHash) != 64 {
+		t.Fatalf("expected a SHA-256 hash of the user's id, got %s", subjectHash)
+	}
+
+	ddbErasure := newDdbErasure(Erasure{
+		ErasureId:          "erasure1",
+		SubjectHash:        subjectHash,
+		RequestedAt:        "2024-01-01T00:00:00.000Z",
+		PollsDeleted:       2,
+		MembershipsDeleted: 1,
+	})
+
+	if ddbErasure.PkErasureId != "erasure|erasure1" || ddbErasure.Gsi1PkSubject != "erasure|"+subjectHash ||
+		ddbErasure.Gsi1SkErasedAt != "erasure|2024-01-01T00:00:00.000Z" || ddbErasure.PollsDeleted != 2 ||
+		ddbErasure.MembershipsDeleted != 1 {
+		t.Errorf("unexpected erasure record %+v", ddbErasure)
+	}
+}
+
+func TestNewRemoveVoteItems(t *testing.T) {
+	transactItems, err := newRemoveVoteItems(
+		testVote(),
+		DdbPoll{PkPollId: "poll|poll123", Version: 7},
+		8,
+		"2024-01-01T00:00:00.000Z",
+		false,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactItems) != 3 || transactItems[0].Delete == nil || transactItems[2].ConditionCheck == nil {
+		t.Fatalf("expected the vote's deletion, its option's update and a check of its poll, got %+v", transactItems)
+	}
+
+	option := transactItems[1].Update
+	if key := option.Key["PK"].(*ddbTypes.AttributeValueMemberS).Value; key != "option|option1" {
+		t.Errorf("expected the voted option to be updated, got %s", key)
+	}
+	if aws.ToString(option.UpdateExpression) != "SET #votes = #votes - :vote, #ballots = if_not_exists(#ballots, #votes) - :ballot, #updatedAt = :updatedAt, #version = :version" {
+		t.Errorf("expected the option's votes to be decremented, got %s", aws.ToString(option.UpdateExpression))
+	}
+	if vote := option.ExpressionAttributeValues[":vote"].(*ddbTypes.AttributeValueMemberN).Value; vote != "1" {
+		t.Errorf("expected an unweighed vote to count once, got %s", vote)
+	}
+
+	if version := option.ExpressionAttributeValues[":version"].(*ddbTypes.AttributeValueMemberN).Value; version != "8" {
+		t.Errorf("expected the option to be stamped with sequence 8, got %s", version)
+	}
+}
+
+func TestNewRemoveWeightedVoteItems(t *testing.T) {
+	ddbVote := testVote()
+	ddbVote.Weight = 3
+
+	transactItems, err := newRemoveVoteItems(ddbVote, DdbPoll{PkPollId: "poll|poll123"}, 1, "2024-01-01T00:00:00.000Z", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := transactItems[1].Update.ExpressionAttributeValues
+	if vote := values[":vote"].(*ddbTypes.AttributeValueMemberN).Value; vote != "3" {
+		t.Errorf("expected the vote's weight to be taken off its option's votes, got %s", vote)
+	}
+	if ballot := values[":ballot"].(*ddbTypes.AttributeValueMemberN).Value; ballot != "1" {
+		t.Errorf("expected a single ballot to be taken off its option, got %s", ballot)
+	}
+}
+
+func TestNewRemoveVoteItemsTimeline(t *testing.T) {
+	ddbVote := testVote()
+	ddbVote.Weight = 2
+	ddbVote.VotedAt = "2024-01-01T09:41:27.5Z"
+
+	transactItems, err := newRemoveVoteItems(ddbVote, DdbPoll{PkPollId: "poll|poll123"}, 1, "2024-01-01T10:00:00.000Z", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactItems) != 3+len(timelineBuckets) {
+		t.Fatalf("expected the vote to be taken off a bucket of each width, got %d items", len(transactItems))
+	}
+
+	expected := []string{
+		"timeline|1m|2024-01-01T09:41:00Z|option1",
+		"timeline|1h|2024-01-01T09:00:00Z|option1",
+	}
+	for index, sk := range expected {
+		update := transactItems[3+index].Update
+		if key := update.Key["SK"].(*ddbTypes.AttributeValueMemberS).Value; key != sk {
+			t.Errorf("expected the bucket %s, got %s", sk, key)
+		}
+		if key := update.Key["PK"].(*ddbTypes.AttributeValueMemberS).Value; key != "poll|poll123" {
+			t.Errorf("expected the bucket to be in the poll's partition, got %s", key)
+		}
+		if vote := update.ExpressionAttributeValues[":vote"].(*ddbTypes.AttributeValueMemberN).Value; vote != "2" {
+			t.Errorf("expected the vote's weight to be taken off the bucket, got %s", vote)
+		}
+	}
+
+	transactItems, err = newRemoveVoteItems(ddbVote, DdbPoll{PkPollId: "poll|poll123"}, 1, "2024-01-01T10:00:00.000Z", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactItems) != 3 {
+		t.Errorf("expected the vote to be removed without its expired buckets, got %d items", len(transactItems))
+	}
+
+	ddbVote.VotedAt = ""
+	transactItems, err = newRemoveVoteItems(ddbVote, DdbPoll{PkPollId: "poll|poll123"}, 1, "2024-01-01T10:00:00.000Z", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactItems) != 3 {
+		t.Errorf("expected a vote that doesn't record when it was cast to be left in its buckets, got %d items", len(transactItems))
+	}
+}
+
+func TestNewPollItem(t *testing.T) {
+	ddbPoll := DdbPoll{
+		PkPollId:    "poll|poll123",
+		VoteWeights: &DdbVoteWeights{Users: map[string]int{"user123": 3, "user456": 2}},
+	}
+
+	update := newPollItem(testVote(), ddbPoll).Update
+	if update == nil {
+		t.Fatal("expected the poll to be updated to remove the voter's weight")
+	}
+	if aws.ToString(update.UpdateExpression) != "REMOVE #voteWeights.#users.#voter" || update.ExpressionAttributeNames["#voter"] != "user123" {
+		t.Errorf("expected the voter's weight to be removed, got %s with %v", aws.ToString(update.UpdateExpression), update.ExpressionAttributeNames)
+	}
+	if aws.ToString(update.ConditionExpression) != "attribute_exists(#pk) AND attribute_not_exists(#deletedAt)" {
+		t.Errorf("expected the poll to still be checked, got %s", aws.ToString(update.ConditionExpression))
+	}
+
+	ddbPoll.VoteWeights.Users = map[string]int{"user456": 2}
+	if item := newPollItem(testVote(), ddbPoll); item.ConditionCheck == nil {
+		t.Error("expected a poll that doesn't weigh the voter only to be checked")
+	}
+	if item := newPollItem(testVote(), DdbPoll{PkPollId: "poll|poll123"}); item.ConditionCheck == nil {
+		t.Error("expected an unweighted poll only to be checked")
+	}
+}
+
+func TestNewAnonymizeVoteItems(t *testing.T) {
+	transactItems, err := newAnonymizeVoteItems(testVote(), DdbPoll{PkPollId: "poll|poll123"}, "anon1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactItems) != 3 || transactItems[0].Delete == nil || transactItems[1].Put == nil || transactItems[2].ConditionCheck == nil {
+		t.Fatalf("expected the vote's deletion, its anonymous copy and a check of its poll, got %+v", transactItems)
+	}
+
+	item := transactItems[1].Put.Item
+	expected := map[string]string{
+		"PK":       "anonvote|anon1",
+		"SK":       "poll|poll123",
+		"GSI1PK":   "votes|poll123",
+		"GSI1SK":   "anonvote|anon1",
+		"OptionId": "option1",
+	}
+	if len(item) != len(expected) {
+		t.Errorf("expected only %d attributes on the anonymous vote, got %+v", len(expected), item)
+	}
+	for name, value := range expected {
+		if attribute, ok := item[name].(*ddbTypes.AttributeValueMemberS); !ok || attribute.Value != value {
+			t.Errorf("expected %s to be %s, got %+v", name, value, item[name])
+		}
+	}
+}
+
+func TestGetEraseVoteError(t *testing.T) {
+	canceled := func(failed int) error {
+		reasons := make([]ddbTypes.CancellationReason, 5)
+		for index := range reasons {
+			reasons[index].Code = aws.String("None")
+		}
+		reasons[failed].Code = aws.String("ConditionalCheckFailed")
+
+		return &ddbTypes.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	tests := []struct {
+		err      error
+		expected error
+	}{
+		{err: canceled(0), expected: errVoteNotFound},
+		{err: canceled(1), expected: errOptionNotFound},
+		{err: canceled(2), expected: errPollDeleted},
+		{err: canceled(3), expected: errTimelineNotFound},
+		{
+			err: &ddbTypes.TransactionCanceledException{CancellationReasons: []ddbTypes.CancellationReason{
+				{Code: aws.String("None")},
+				{Code: aws.String("TransactionConflict")},
+				{Code: aws.String("None")},
+			}},
+			expected: errConflict,
+		},
+	}
+
+	for _, test := range tests {
+		if err := getEraseVoteError(test.err); !errors.Is(err, test.expected) {
+			t.Errorf("expected %v, got %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+// DdbUserItem is any item indexed by its user in GSI1, which is either a poll, an API key, a template, a
+// recurring poll series or a membership of someone else's poll
+type DdbUserItem struct {
+	PkId         string   `dynamodbav:"PK"`
+	SkId         string   `dynamodbav:"SK"`
+	Gsi1PkUserId string   `dynamodbav:"GSI1PK"`
+	Gsi1SkId     string   `dynamodbav:"GSI1SK"`
+	Prompt       string   `dynamodbav:"Prompt"`
+	CreatedAt    string   `dynamodbav:"CreatedAt"`
+	Duration     int      `dynamodbav:"Duration"`
+	IsArchived   bool     `dynamodbav:"IsArchived"`
+	EditedAt     string   `dynamodbav:"EditedAt"`
+	DeletedAt    string   `dynamodbav:"DeletedAt"`
+	Name         string   `dynamodbav:"Name"`
+	Roles        []string `dynamodbav:"Roles"`
+	Options      []string `dynamodbav:"Options"`
+	UpdatedAt    string   `dynamodbav:"UpdatedAt"`
+	Schedule     string   `dynamodbav:"Schedule"`
+	TimeZone     string   `dynamodbav:"TimeZone"`
+	Status       string   `dynamodbav:"Status"`
+	Role         string   `dynamodbav:"Role"`
+	AddedAt      string   `dynamodbav:"AddedAt"`
+}
+
+type DdbOption struct {
+	PkOptionId string `dynamodbav:"PK"`
+	Index      int    `dynamodbav:"Index"`
+	Text       string `dynamodbav:"Text"`
+	Votes      int    `dynamodbav:"Votes"`
+}
+
+type DdbVote struct {
+	PkVoterId string `dynamodbav:"PK"`
+	SkPollId  string `dynamodbav:"SK"`
+	OptionId  string `dynamodbav:"OptionId"`
+	VoteId    string `dynamodbav:"VoteId"`
+	Weight    int    `dynamodbav:"Weight"`
+}
+
+type DdbWebhook struct {
+	SkWebhookId string   `dynamodbav:"SK"`
+	Url         string   `dynamodbav:"Url"`
+	Events      []string `dynamodbav:"Events"`
+	CreatedAt   string   `dynamodbav:"CreatedAt"`
+}
+
+type Option struct {
+	OptionId string `json:"optionId"`
+	Text     string `json:"text"`
+	Votes    int    `json:"votes"`
+}
+
+// Webhook leaves out the signing secret, which is a credential rather than data about the user
+type Webhook struct {
+	WebhookId string   `json:"webhookId"`
+	Url       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+type Poll struct {
+	PollId     string    `json:"pollId"`
+	Prompt     string    `json:"prompt"`
+	CreatedAt  string    `json:"createdAt"`
+	Duration   int       `json:"duration"`
+	IsArchived bool      `json:"isArchived"`
+	EditedAt   string    `json:"editedAt,omitempty"`
+	DeletedAt  string    `json:"deletedAt,omitempty"`
+	Options    []Option  `json:"options"`
+	Webhooks   []Webhook `json:"webhooks"`
+}
+
+type Vote struct {
+	PollId   string `json:"pollId"`
+	OptionId string `json:"optionId"`
+	VoteId   string `json:"voteId"`
+	Weight   int    `json:"weight"`
+}
+
+type ApiKey struct {
+	ApiKeyId  string   `json:"apiKeyId"`
+	Name      string   `json:"name"`
+	CreatedAt string   `json:"createdAt"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+type Template struct {
+	TemplateId string   `json:"templateId"`
+	Name       string   `json:"name"`
+	Prompt     string   `json:"prompt"`
+	Options    []string `json:"options"`
+	Duration   int      `json:"duration"`
+	CreatedAt  string   `json:"createdAt"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+type Series struct {
+	SeriesId  string   `json:"seriesId"`
+	Schedule  string   `json:"schedule"`
+	TimeZone  string   `json:"timeZone"`
+	Prompt    string   `json:"prompt"`
+	Options   []string `json:"options"`
+	Duration  int      `json:"duration"`
+	Status    string   `json:"status"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+// Membership is the user's role on a poll someone else owns
+type Membership struct {
+	PollId  string `json:"pollId"`
+	Role    string `json:"role"`
+	AddedAt string `json:"addedAt"`
+}
+
+type Export struct {
+	UserId      string       `json:"userId"`
+	ExportedAt  string       `json:"exportedAt"`
+	Polls       []Poll       `json:"polls"`
+	Votes       []Vote       `json:"votes"`
+	ApiKeys     []ApiKey     `json:"apiKeys"`
+	Templates   []Template   `json:"templates"`
+	Series      []Series     `json:"series"`
+	Memberships []Membership `json:"memberships"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	// The body is the user's whole archive, so only its size is logged
+	log.Printf("Response: %d (%d bytes)", res.StatusCode, len(res.Body))
+
+	return res
+}
+
+func queryAll[T any](ctx context.Context, ddb *dynamodb.Client, query *dynamodb.QueryInput) ([]T, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, query)
+
+	var items []T
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []T
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageItems); err != nil {
+			return nil, err
+		}
+		items = append(items, pageItems...)
+	}
+
+	return items, nil
+}
+
+// getUserItems finds the user's polls, API keys, templates, series and memberships, including the polls that
+// are still being deleted
+func getUserItems(ctx context.Context, ddb *dynamodb.Client, userId string) ([]DdbUserItem, error) {
+	return queryAll[DdbUserItem](ctx, ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#user = :user"),
+		ExpressionAttributeNames: map[string]string{
+			"#user": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("user|%s", userId),
+			},
+		},
+	})
+}
+
+func getOptions(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbOption, error) {
+	return queryAll[DdbOption](ctx, ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+}
+
+func getWebhooks(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbWebhook, error) {
+	return queryAll[DdbWebhook](ctx, ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#poll = :poll AND begins_with(#webhook, :webhook)"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll":    "PK",
+			"#webhook": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			":webhook": &types.AttributeValueMemberS{
+				Value: "webhook|",
+			},
+		},
+	})
+}
+
+func getVotes(ctx context.Context, ddb *dynamodb.Client, userId string) ([]DdbVote, error) {
+	return queryAll[DdbVote](ctx, ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#voter = :voter AND begins_with(#poll, :poll)"),
+		ExpressionAttributeNames: map[string]string{
+			"#voter": "PK",
+			"#poll":  "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":voter": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("voter|%s", userId),
+			},
+			":poll": &types.AttributeValueMemberS{
+				Value: "poll|",
+			},
+		},
+	})
+}
+
+func newPoll(ddbPoll DdbUserItem, ddbOptions []DdbOption, ddbWebhooks []DdbWebhook) Poll {
+	sort.SliceStable(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	options := make([]Option, 0, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		options = append(options, Option{
+			OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:     ddbOption.Text,
+			Votes:    ddbOption.Votes,
+		})
+	}
+
+	webhooks := make([]Webhook, 0, len(ddbWebhooks))
+	for _, ddbWebhook := range ddbWebhooks {
+		webhooks = append(webhooks, Webhook{
+			WebhookId: stripPrefix(ddbWebhook.SkWebhookId, "webhook|"),
+			Url:       ddbWebhook.Url,
+			Events:    ddbWebhook.Events,
+			CreatedAt: ddbWebhook.CreatedAt,
+		})
+	}
+
+	return Poll{
+		PollId:     stripPrefix(ddbPoll.PkId, "poll|"),
+		Prompt:     ddbPoll.Prompt,
+		CreatedAt:  ddbPoll.CreatedAt,
+		Duration:   ddbPoll.Duration,
+		IsArchived: ddbPoll.IsArchived,
+		EditedAt:   ddbPoll.EditedAt,
+		DeletedAt:  ddbPoll.DeletedAt,
+		Options:    options,
+		Webhooks:   webhooks,
+	}
+}
+
+func newApiKey(ddbApiKey DdbUserItem) ApiKey {
+	return ApiKey{
+		ApiKeyId:  stripPrefix(ddbApiKey.Gsi1SkId, "apikey|"),
+		Name:      ddbApiKey.Name,
+		CreatedAt: ddbApiKey.CreatedAt,
+		Roles:     ddbApiKey.Roles,
+	}
+}
+
+func newTemplate(ddbTemplate DdbUserItem) Template {
+	return Template{
+		TemplateId: stripPrefix(ddbTemplate.PkId, "template|"),
+		Name:       ddbTemplate.Name,
+		Prompt:     ddbTemplate.Prompt,
+		Options:    ddbTemplate.Options,
+		Duration:   ddbTemplate.Duration,
+		CreatedAt:  ddbTemplate.CreatedAt,
+		UpdatedAt:  ddbTemplate.UpdatedAt,
+	}
+}
+
+func newSeries(ddbSeries DdbUserItem) Series {
+	return Series{
+		SeriesId:  stripPrefix(ddbSeries.PkId, "series|"),
+		Schedule:  ddbSeries.Schedule,
+		TimeZone:  ddbSeries.TimeZone,
+		Prompt:    ddbSeries.Prompt,
+		Options:   ddbSeries.Options,
+		Duration:  ddbSeries.Duration,
+		Status:    ddbSeries.Status,
+		CreatedAt: ddbSeries.CreatedAt,
+		UpdatedAt: ddbSeries.UpdatedAt,
+	}
+}
+
+func newMembership(ddbMember DdbUserItem) Membership {
+	return Membership{
+		PollId:  stripPrefix(ddbMember.PkId, "poll|"),
+		Role:    ddbMember.Role,
+		AddedAt: ddbMember.AddedAt,
+	}
+}
+
+// newVotes exports the user's votes with how much each counted for. Votes counted before votes were
+// weighed don't have a weight, but each counted once.
+func newVotes(ddbVotes []DdbVote) []Vote {
+	votes := make([]Vote, 0, len(ddbVotes))
+	for _, ddbVote := range ddbVotes {
+		weight := ddbVote.Weight
+		if weight == 0 {
+			weight = 1
+		}
+
+		votes = append(votes, Vote{
+			PollId:   stripPrefix(ddbVote.SkPollId, "poll|"),
+			OptionId: ddbVote.OptionId,
+			VoteId:   ddbVote.VoteId,
+			Weight:   weight,
+		})
+	}
+
+	return votes
+}
+
+func export(ctx context.Context, ddb *dynamodb.Client, userId string, exportedAt string) (Export, error) {
+	userItems, err := getUserItems(ctx, ddb, userId)
+	if err != nil {
+		return Export{}, err
+	}
+
+	archive := Export{
+		UserId:      userId,
+		ExportedAt:  exportedAt,
+		Polls:       []Poll{},
+		ApiKeys:     []ApiKey{},
+		Templates:   []Template{},
+		Series:      []Series{},
+		Memberships: []Membership{},
+	}
+
+	for _, userItem := range userItems {
+		switch {
+		// Memberships share their poll's partition, so they're told apart from it by their sort key
+		case strings.HasPrefix(userItem.SkId, pollaccess.MemberPrefix):
+			archive.Memberships = append(archive.Memberships, newMembership(userItem))
+		case strings.HasPrefix(userItem.PkId, "poll|"):
+			pollId := stripPrefix(userItem.PkId, "poll|")
+
+			ddbOptions, err := getOptions(ctx, ddb, pollId)
+			if err != nil {
+				return Export{}, err
+			}
+
+			ddbWebhooks, err := getWebhooks(ctx, ddb, pollId)
+			if err != nil {
+				return Export{}, err
+			}
+
+			archive.Polls = append(archive.Polls, newPoll(userItem, ddbOptions, ddbWebhooks))
+		case strings.HasPrefix(userItem.PkId, "apikey|"):
+			archive.ApiKeys = append(archive.ApiKeys, newApiKey(userItem))
+		case strings.HasPrefix(userItem.PkId, "template|"):
+			archive.Templates = append(archive.Templates, newTemplate(userItem))
+		case strings.HasPrefix(userItem.PkId, "series|"):
+			archive.Series = append(archive.Series, newSeries(userItem))
+		}
+	}
+
+	ddbVotes, err := getVotes(ctx, ddb, userId)
+	if err != nil {
+		return Export{}, err
+	}
+	archive.Votes = newVotes(ddbVotes)
+
+	return archive, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	exportedAt := time.Now().UTC().Format(RFC3339Milli)
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	archive, err := export(ctx, ddb, userId, exportedAt)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	body, err := json.Marshal(archive)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers: map[string]string{
+				"Content-Disposition": `attachment; filename="pseudopoll-export.json"`,
+			},
+			Body: string(body),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
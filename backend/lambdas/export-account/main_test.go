@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewPoll(t *testing.T) {
+	poll := newPoll(
+		DdbUserItem{
+			PkId:      "poll|poll123",
+			Prompt:    "Ship it?",
+			Duration:  3600,
+			DeletedAt: "2024-01-01T00:30:00.000Z",
+		},
+		[]DdbOption{
+			{PkOptionId: "option|option2", Index: 1, Text: "No", Votes: 1},
+			{PkOptionId: "option|option1", Index: 0, Text: "Yes", Votes: 2},
+		},
+		[]DdbWebhook{
+			{SkWebhookId: "webhook|webhook1", Url: "https://example.com/hook", Events: []string{"voteCounted"}},
+		},
+	)
+
+	expected := Poll{
+		PollId:    "poll123",
+		Prompt:    "Ship it?",
+		Duration:  3600,
+		DeletedAt: "2024-01-01T00:30:00.000Z",
+		Options: []Option{
+			{OptionId: "option1", Text: "Yes", Votes: 2},
+			{OptionId: "option2", Text: "No", Votes: 1},
+		},
+		Webhooks: []Webhook{
+			{WebhookId: "webhook1", Url: "https://example.com/hook", Events: []string{"voteCounted"}},
+		},
+	}
+	if !reflect.DeepEqual(poll, expected) {
+		t.Errorf("expected %+v, got %+v", expected, poll)
+	}
+}
+
+func TestNewMembership(t *testing.T) {
+	membership := newMembership(DdbUserItem{
+		PkId:         "poll|poll123",
+		SkId:         "member|user2",
+		Gsi1PkUserId: "user|user2",
+		Gsi1SkId:     "member|poll123",
+		Role:         "viewer",
+		AddedAt:      "2024-01-01T00:00:00.000Z",
+	})
+
+	expected := Membership{PollId: "poll123", Role: "viewer", AddedAt: "2024-01-01T00:00:00.000Z"}
+	if membership != expected {
+		t.Errorf("expected %+v, got %+v", expected, membership)
+	}
+}
+
+func TestNewApiKey(t *testing.T) {
+	apiKey := newApiKey(DdbUserItem{
+		PkId:     "apikey|hash",
+		Gsi1SkId: "apikey|key123",
+		Name:     "CI",
+		Roles:    []string{"voter"},
+	})
+
+	if apiKey.ApiKeyId != "key123" || apiKey.Name != "CI" || !reflect.DeepEqual(apiKey.Roles, []string{"voter"}) {
+		t.Errorf("unexpected API key %+v", apiKey)
+	}
+}
+
+func TestNewTemplate(t *testing.T) {
+	template := newTemplate(DdbUserItem{
+		PkId:     "template|template1",
+		Gsi1SkId: "template|template1",
+		Name:     "Lunch",
+		Prompt:   "Where to?",
+		Options:  []string{"Tacos", "Pho"},
+		Duration: 600,
+	})
+
+	expected := Template{TemplateId: "template1", Name: "Lunch", Prompt: "Where to?", Options: []string{"Tacos", "Pho"}, Duration: 600}
+	if !reflect.DeepEqual(template, expected) {
+		t.Errorf("expected %+v, got %+v", expected, template)
+	}
+}
+
+func TestNewSeries(t *testing.T) {
+	series := newSeries(DdbUserItem{
+		PkId:     "series|series1",
+		Gsi1SkId: "series|series1",
+		Schedule: "0 9 * * MON",
+		TimeZone: "Europe/London",
+		Prompt:   "Standup?",
+		Options:  []string{"Yes", "No"},
+		Duration: 3600,
+		Status:   "paused",
+	})
+
+	expected := Series{
+		SeriesId: "series1",
+		Schedule: "0 9 * * MON",
+		TimeZone: "Europe/London",
+		Prompt:   "Standup?",
+		Options:  []string{"Yes", "No"},
+		Duration: 3600,
+		Status:   "paused",
+	}
+	if !reflect.DeepEqual(series, expected) {
+		t.Errorf("expected %+v, got %+v", expected, series)
+	}
+}
+
+func TestNewVotes(t *testing.T) {
+	votes := newVotes([]DdbVote{
+		{PkVoterId: "voter|user123", SkPollId: "poll|poll123", OptionId: "option1", VoteId: "vote1"},
+		{PkVoterId: "voter|user123", SkPollId: "poll|poll456", OptionId: "option2", VoteId: "vote2", Weight: 3},
+	})
+
+	expected := []Vote{
+		{PollId: "poll123", OptionId: "option1", VoteId: "vote1", Weight: 1},
+		{PollId: "poll456", OptionId: "option2", VoteId: "vote2", Weight: 3},
+	}
+	if !reflect.DeepEqual(votes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, votes)
+	}
+
+	if votes := newVotes(nil); votes == nil || len(votes) != 0 {
+		t.Errorf("expected an empty list of votes, got %#v", votes)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkPollId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Gsi1SkUserId string `dynamodbav:"GSI1SK"`
+	Prompt       string `dynamodbav:"Prompt"`
+	CreatedAt    string `dynamodbav:"CreatedAt"`
+	Duration     int64  `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+
+	AllowWriteIns bool `dynamodbav:"AllowWriteIns"`
+
+	Outcome *decision.Outcome `dynamodbav:"Outcome"`
+}
+
+type DdbOption struct {
+	PkOptionId   string `dynamodbav:"PK"`
+	SkOptionId   string `dynamodbav:"SK"`
+	Gsi1PkPollId string `dynamodbav:"GSI1PK"`
+	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
+	Index        int    `dynamodbav:"Index"`
+	Text         string `dynamodbav:"Text"`
+	Description  string `dynamodbav:"Description"`
+	Url          string `dynamodbav:"Url"`
+	ImageUrl     string `dynamodbav:"ImageUrl"`
+	UpdatedAt    string `dynamodbav:"UpdatedAt"`
+	Votes        int64  `dynamodbav:"Votes"`
+	Version      int64  `dynamodbav:"Version"`
+
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollId := request.PathParameters["pollId"]
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	// The poll and its sequence are read before its options, so the options are at least as recent as the snapshot's sequence
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err = attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	// An archived poll's snapshot is only shown to its owner and members, like the poll itself
+	if ddbPoll.IsArchived {
+		// NOTE: If this lambda is invoked by the `/public/polls/{pollId}/snapshot` endpoint, the user will not be authenticated.
+		currentUserId, _ := request.RequestContext.Authorizer["sub"].(string)
+		if currentUserId == "" {
+			err := errors.New("user is not authenticated")
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusUnauthorized,
+					Body:       formatError("Unauthorized", err),
+				},
+				err,
+			), nil
+		}
+
+		_, err := pollaccess.Authorize(
+			ctx,
+			ddb,
+			os.Getenv("SINGLE_TABLE_NAME"),
+			pollId,
+			ddbPoll.Gsi1PkUserId,
+			currentUserId,
+			pollaccess.ActionView,
+		)
+		if errors.Is(err, pollaccess.ErrForbidden) {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusForbidden,
+					Body:       formatError("Forbidden", err),
+				},
+				err,
+			), nil
+		}
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	sequence, err := pollsequence.Current(ctx, ddb, os.Getenv("SINGLE_TABLE_NAME"), pollId, ddbPoll.Version)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbOptions []DdbOption
+	if err = attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	options := make([]protocol.OptionSnapshot, 0, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		if !pollcreate.IsShown(ddbOption.WriteInStatus) {
+			continue
+		}
+
+		options = append(options, protocol.OptionSnapshot{
+			OptionId:    stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:        ddbOption.Text,
+			Description: ddbOption.Description,
+			Url:         ddbOption.Url,
+			ImageUrl:    ddbOption.ImageUrl,
+			UpdatedAt:   ddbOption.UpdatedAt,
+			Votes:       ddbOption.Votes,
+			Sequence:    ddbOption.Version,
+		})
+	}
+
+	// The same snapshot is published to the poll's topic as a pollSnapshot message
+	snapshot := protocol.PollSnapshotData{
+		PollId:     stripPrefix(ddbPoll.PkPollId, "poll|"),
+		UserId:     stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
+		Prompt:     ddbPoll.Prompt,
+		Options:    options,
+		CreatedAt:  ddbPoll.CreatedAt,
+		Duration:   ddbPoll.Duration,
+		IsArchived: ddbPoll.IsArchived,
+		Sequence:   sequence,
+
+		AllowWriteIns: ddbPoll.AllowWriteIns,
+	}
+	if outcome := ddbPoll.Outcome; outcome != nil {
+		snapshot.Outcome = &protocol.Outcome{
+			Result:        outcome.Result,
+			Reason:        outcome.Reason,
+			OptionId:      outcome.OptionId,
+			TiedOptionIds: outcome.TiedOptionIds,
+			Ballots:       outcome.Ballots,
+			Votes:         outcome.Votes,
+		}
+	}
+
+	response, err := json.Marshal(snapshot)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(response),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type DdbOption struct {
+	PkOptionId string `dynamodbav:"PK"`
+	Index      int    `dynamodbav:"Index"`
+	Text       string `dynamodbav:"Text"`
+
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+// DdbTimelineBucket counts the votes for an option that were cast in a bucket, keyed by the bucket's
+// width, start and option under the poll's partition
+type DdbTimelineBucket struct {
+	OptionId    string `dynamodbav:"OptionId"`
+	BucketStart string `dynamodbav:"BucketStart"`
+	Votes       int    `dynamodbav:"Votes"`
+}
+
+type Option struct {
+	OptionId string `json:"optionId"`
+	Text     string `json:"text"`
+}
+
+// Point is a bucket that votes were cast in, with the votes cast in it and the running totals up to
+// the end of it. Buckets without votes are left out.
+type Point struct {
+	BucketStart     string         `json:"bucketStart"`
+	Votes           map[string]int `json:"votes"`
+	TotalVotes      int            `json:"totalVotes"`
+	CumulativeVotes map[string]int `json:"cumulativeVotes"`
+	CumulativeTotal int            `json:"cumulativeTotal"`
+}
+
+type Timeline struct {
+	PollId  string   `json:"pollId"`
+	Bucket  string   `json:"bucket"`
+	Options []Option `json:"options"`
+	Points  []Point  `json:"points"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	DefaultBucket         = "1m"
+	WriteInStatusApproved = "approved"
+)
+
+var buckets = []string{"1m", "1h"}
+
+var errUnknownBucket = errors.New("bucket must be 1m or 1h")
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func getBucket(queryStringParameters map[string]string) (string, error) {
+	bucket, ok := queryStringParameters["bucket"]
+	if !ok || bucket == "" {
+		return DefaultBucket, nil
+	}
+
+	for _, known := range buckets {
+		if bucket == known {
+			return bucket, nil
+		}
+	}
+
+	return "", errUnknownBucket
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (*DdbPoll, error) {
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pollResult.Item == nil {
+		return nil, nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return nil, err
+	}
+
+	return &ddbPoll, nil
+}
+
+func getOptions(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbOption, error) {
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return nil, err
+	}
+
+	return ddbOptions, nil
+}
+
+// getTimelineBuckets returns the poll's buckets of the width in the order they start
+func getTimelineBuckets(ctx context.Context, ddb *dynamodb.Client, pollId string, bucket string) ([]DdbTimelineBucket, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :poll AND begins_with(#sk, :timeline)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			":timeline": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("timeline|%s|", bucket),
+			},
+		},
+	})
+
+	var ddbTimelineBuckets []DdbTimelineBucket
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageBuckets []DdbTimelineBucket
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageBuckets); err != nil {
+			return nil, err
+		}
+
+		ddbTimelineBuckets = append(ddbTimelineBuckets, pageBuckets...)
+	}
+
+	return ddbTimelineBuckets, nil
+}
+
+func newOptions(ddbOptions []DdbOption) []Option {
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	options := make([]Option, 0, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		// Write-ins that are held for moderation, or were rejected, aren't shown to voters
+		isShown := ddbOption.WriteInStatus == "" || ddbOption.WriteInStatus == WriteInStatusApproved
+		if strings.HasPrefix(ddbOption.PkOptionId, "option|") && isShown {
+			options = append(options, Option{
+				OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+				Text:     ddbOption.Text,
+			})
+		}
+	}
+
+	return options
+}
+
+// newPoints groups the buckets, which are sorted by when they start, into a point per bucket start.
+// Every option is in every point, so each of their lines can be drawn from the first point, and the
+// buckets of options that have since been removed are left out.
+func newPoints(options []Option, ddbTimelineBuckets []DdbTimelineBucket) []Point {
+	cumulativeVotes := make(map[string]int, len(options))
+	for _, option := range options {
+		cumulativeVotes[option.OptionId] = 0
+	}
+	cumulativeTotal := 0
+
+	points := []Point{}
+	for _, ddbTimelineBucket := range ddbTimelineBuckets {
+		if _, ok := cumulativeVotes[ddbTimelineBucket.OptionId]; !ok {
+			continue
+		}
+
+		if len(points) == 0 || points[len(points)-1].BucketStart != ddbTimelineBucket.BucketStart {
+			votes := make(map[string]int, len(options))
+			for _, option := range options {
+				votes[option.OptionId] = 0
+			}
+
+			points = append(points, Point{
+				BucketStart: ddbTimelineBucket.BucketStart,
+				Votes:       votes,
+			})
+		}
+
+		cumulativeVotes[ddbTimelineBucket.OptionId] += ddbTimelineBucket.Votes
+		cumulativeTotal += ddbTimelineBucket.Votes
+
+		point := &points[len(points)-1]
+		point.Votes[ddbTimelineBucket.OptionId] += ddbTimelineBucket.Votes
+		point.TotalVotes += ddbTimelineBucket.Votes
+		point.CumulativeTotal = cumulativeTotal
+		point.CumulativeVotes = make(map[string]int, len(cumulativeVotes))
+		for optionId, votes := range cumulativeVotes {
+			point.CumulativeVotes[optionId] = votes
+		}
+	}
+
+	return points
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollId := request.PathParameters["pollId"]
+
+	bucket, err := getBucket(request.QueryStringParameters)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbPoll, err := getPoll(ctx, ddb, pollId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if ddbPoll == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	// An archived poll's timeline is only shown to its owner and members, like the poll itself
+	if ddbPoll.IsArchived {
+		currentUserId, _ := request.RequestContext.Authorizer["sub"].(string)
+		if currentUserId == "" {
+			err := errors.New("user is not authenticated")
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusUnauthorized,
+					Body:       formatError("Unauthorized", err),
+				},
+				err,
+			), nil
+		}
+
+		_, err := pollaccess.Authorize(
+			ctx,
+			ddb,
+			os.Getenv("SINGLE_TABLE_NAME"),
+			pollId,
+			ddbPoll.Gsi1PkUserId,
+			currentUserId,
+			pollaccess.ActionView,
+		)
+		if errors.Is(err, pollaccess.ErrForbidden) {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusForbidden,
+					Body:       formatError("Forbidden", err),
+				},
+				err,
+			), nil
+		}
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	ddbOptions, err := getOptions(ctx, ddb, pollId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddbTimelineBuckets, err := getTimelineBuckets(ctx, ddb, pollId, bucket)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	options := newOptions(ddbOptions)
+
+	timeline, err := json.Marshal(Timeline{
+		PollId:  pollId,
+		Bucket:  bucket,
+		Options: options,
+		Points:  newPoints(options, ddbTimelineBuckets),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(timeline),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGetBucket(t *testing.T) {
+	tests := map[string]string{"": DefaultBucket, "1m": "1m", "1h": "1h"}
+
+	for value, expected := range tests {
+		if bucket, err := getBucket(map[string]string{"bucket": value}); err != nil || bucket != expected {
+			t.Errorf("%q: expected %s, got %s (%v)", value, expected, bucket, err)
+		}
+	}
+
+	if bucket, err := getBucket(nil); err != nil || bucket != DefaultBucket {
+		t.Errorf("expected the default bucket without a query string, got %s (%v)", bucket, err)
+	}
+
+	if _, err := getBucket(map[string]string{"bucket": "1d"}); !errors.Is(err, errUnknownBucket) {
+		t.Errorf("expected an unknown bucket to be rejected, got %v", err)
+	}
+}
+
+func TestNewPoints(t *testing.T) {
+	options := newOptions([]DdbOption{
+		{PkOptionId: "option|no", Index: 1, Text: "No"},
+		{PkOptionId: "option|yes", Index: 0, Text: "Yes"},
+	})
+	if !reflect.DeepEqual(options, []Option{{OptionId: "yes", Text: "Yes"}, {OptionId: "no", Text: "No"}}) {
+		t.Fatalf("expected the options in their order, got %+v", options)
+	}
+
+	points := newPoints(options, []DdbTimelineBucket{
+		{OptionId: "no", BucketStart: "2024-01-01T09:00:00Z", Votes: 1},
+		{OptionId: "yes", BucketStart: "2024-01-01T09:00:00Z", Votes: 2},
+		{OptionId: "removed", BucketStart: "2024-01-01T09:01:00Z", Votes: 4},
+		{OptionId: "yes", BucketStart: "2024-01-01T09:05:00Z", Votes: 3},
+	})
+
+	expected := []Point{
+		{
+			BucketStart:     "2024-01-01T09:00:00Z",
+			Votes:           map[string]int{"yes": 2, "no": 1},
+			TotalVotes:      3,
+			CumulativeVotes: map[string]int{"yes": 2, "no": 1},
+			CumulativeTotal: 3,
+		},
+		{
+			BucketStart:     "2024-01-01T09:05:00Z",
+			Votes:           map[string]int{"yes": 3, "no": 0},
+			TotalVotes:      3,
+			CumulativeVotes: map[string]int{"yes": 5, "no": 1},
+			CumulativeTotal: 6,
+		},
+	}
+	if !reflect.DeepEqual(points, expected) {
+		t.Errorf("expected %+v, got %+v", expected, points)
+	}
+
+	if points := newPoints(options, nil); points == nil || len(points) != 0 {
+		t.Errorf("expected an empty timeline, got %+v", points)
+	}
+}
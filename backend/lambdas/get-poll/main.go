@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"sort"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -17,6 +18,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
 )
 
 type DdbPoll struct {
@@ -27,7 +30,28 @@ type DdbPoll struct {
 	Prompt       string `dynamodbav:"Prompt"`
 	CreatedAt    string `dynamodbav:"CreatedAt"`
 	Duration     int    `dynamodbav:"Duration"`
-	IsArchived   bool   `dynamodbav:"isArchived"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+	SeriesId     string `dynamodbav:"SeriesId"`
+
+	AllowWriteIns    bool `dynamodbav:"AllowWriteIns"`
+	ModerateWriteIns bool `dynamodbav:"ModerateWriteIns"`
+
+	VoteWeights *VoteWeights `dynamodbav:"VoteWeights"`
+
+	Quorum    int                 `dynamodbav:"Quorum"`
+	Threshold *decision.Threshold `dynamodbav:"Threshold"`
+	ClosedAt  string              `dynamodbav:"ClosedAt"`
+	Outcome   *decision.Outcome   `dynamodbav:"Outcome"`
+
+	TieBreak         string `dynamodbav:"TieBreak"`
+	TieBreakOptionId string `dynamodbav:"TieBreakOptionId"`
+}
+
+type VoteWeights struct {
+	Users   map[string]int `dynamodbav:"Users,omitempty" json:"users,omitempty"`
+	Groups  map[string]int `dynamodbav:"Groups,omitempty" json:"groups,omitempty"`
+	Default *int           `dynamodbav:"Default,omitempty" json:"default,omitempty"`
 }
 
 type DdbOption struct {
@@ -35,10 +59,16 @@ type DdbOption struct {
 	SkOptionId   string `dynamodbav:"SK"`
 	Gsi1PkPollId string `dynamodbav:"GSI1PK"`
 	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
-	ArrayIndex   int    `dynamodbav:"ArrayIndex"`
+	Index        int    `dynamodbav:"Index"`
 	Text         string `dynamodbav:"Text"`
+	Description  string `dynamodbav:"Description"`
+	Url          string `dynamodbav:"Url"`
+	ImageUrl     string `dynamodbav:"ImageUrl"`
 	UpdatedAt    string `dynamodbav:"UpdatedAt"`
 	Votes        int    `dynamodbav:"Votes"`
+	Ballots      *int   `dynamodbav:"Ballots"`
+
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
 }
 
 type DdbMyVote struct {
@@ -56,16 +86,57 @@ type Poll struct {
 	CreatedAt  string   `json:"createdAt"`
 	Duration   int      `json:"duration"`
 	IsArchived bool     `json:"isArchived"`
+	SeriesId   string   `json:"seriesId,omitempty"`
+
+	AllowWriteIns    bool `json:"allowWriteIns"`
+	ModerateWriteIns bool `json:"moderateWriteIns"`
+
+	VoteWeights *VoteWeights `json:"voteWeights,omitempty"`
+
+	Quorum    int                 `json:"quorum,omitempty"`
+	Threshold *decision.Threshold `json:"threshold,omitempty"`
+	Outcome   *decision.Outcome   `json:"outcome,omitempty"`
+
+	TotalVotes       int64    `json:"totalVotes"`
+	WinningOptionIds []string `json:"winningOptionIds"`
+	TieBreak         string   `json:"tieBreak"`
+	TieBreakOptionId string   `json:"tieBreakOptionId,omitempty"`
+
+	// The poll's members are only listed to the users who manage it
+	Members []Member `json:"members,omitempty"`
+}
+
+type Member struct {
+	UserId  string `json:"userId"`
+	Role    string `json:"role"`
+	AddedAt string `json:"addedAt"`
 }
 
 type Option struct {
-	OptionId  string `json:"optionId"`
-	Text      string `json:"text"`
-	UpdatedAt string `json:"updatedAt"`
-	Votes     int    `json:"votes"`
-	IsMyVote  bool   `json:"isMyVote"`
+	OptionId    string `json:"optionId"`
+	Text        string `json:"text"`
+	Description string `json:"description,omitempty"`
+	Url         string `json:"url,omitempty"`
+	ImageUrl    string `json:"imageUrl,omitempty"`
+	UpdatedAt   string `json:"updatedAt"`
+	Votes       int    `json:"votes"`
+	Ballots     int    `json:"ballots"`
+	IsMyVote    bool   `json:"isMyVote"`
+
+	// Write-ins that are only shown to the poll's owner and members aren't ranked
+	Rank       int     `json:"rank,omitempty"`
+	Percentage float64 `json:"percentage"`
+	IsWinner   bool    `json:"isWinner"`
+	IsTied     bool    `json:"isTied"`
+
+	WriteInStatus string `json:"writeInStatus,omitempty"`
 }
 
+const (
+	RFC3339Milli          = "2006-01-02T15:04:05.999Z07:00"
+	WriteInStatusApproved = "approved"
+)
+
 type Error struct {
 	Message string `json:"message"`
 	Cause   string `json:"cause"`
@@ -90,6 +161,154 @@ func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatew
 	return res
 }
 
+// isHidden reports whether the poll is hidden from a user with the role. An archived poll is only shown to
+// its owner and members.
+func isHidden(ddbPoll DdbPoll, role string) bool {
+	return ddbPoll.IsArchived && !pollaccess.Can(role, pollaccess.ActionView)
+}
+
+// isShown reports whether the option is shown to the user. Write-ins that are held for moderation, or
+// were rejected, are only shown to the poll's owner and members.
+func isShown(ddbOption DdbOption, canView bool) bool {
+	return ddbOption.WriteInStatus == "" || ddbOption.WriteInStatus == WriteInStatusApproved || canView
+}
+
+// unmarshalOptions returns the poll's options in the order of their indexes, which edits of the poll reorder
+func unmarshalOptions(items []map[string]types.AttributeValue) ([]DdbOption, error) {
+	ddbOptions := make([]DdbOption, 0, len(items))
+	for _, item := range items {
+		var ddbOption DdbOption
+		if err := attributevalue.UnmarshalMap(item, &ddbOption); err != nil {
+			return nil, err
+		}
+
+		ddbOptions = append(ddbOptions, ddbOption)
+	}
+
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	return ddbOptions, nil
+}
+
+// getBallots returns how many votes the option got, counting each once. Options whose votes were counted
+// before votes were weighed don't have ballots, but each of their votes counted once.
+func getBallots(ddbOption DdbOption) int {
+	if ddbOption.Ballots == nil {
+		return ddbOption.Votes
+	}
+
+	return *ddbOption.Ballots
+}
+
+// getTallies counts the options shown to voters, so every user sees the same standings and outcome
+func getTallies(ddbOptions []DdbOption) ([]decision.Tally, error) {
+	var tallies []decision.Tally
+	for _, ddbOption := range ddbOptions {
+		if !isShown(ddbOption, false) {
+			continue
+		}
+
+		reachedAt, err := time.Parse(RFC3339Milli, ddbOption.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		tallies = append(tallies, decision.Tally{
+			OptionId:  stripPrefix(ddbOption.PkOptionId, "option|"),
+			Votes:     int64(ddbOption.Votes),
+			Ballots:   int64(getBallots(ddbOption)),
+			ReachedAt: reachedAt,
+		})
+	}
+
+	return tallies, nil
+}
+
+// getOutcome returns how the poll was decided once it closed. Polls are closed on a schedule, so a poll
+// that expired before it was closed, or too long ago to be, is decided from its tallies as it's read.
+func getOutcome(ddbPoll DdbPoll, tallies []decision.Tally, now time.Time) (*decision.Outcome, error) {
+	if ddbPoll.Outcome != nil {
+		return ddbPoll.Outcome, nil
+	}
+
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second)) {
+		return nil, nil
+	}
+
+	outcome := decision.Decide(decision.Rules{
+		Quorum:           ddbPoll.Quorum,
+		Threshold:        ddbPoll.Threshold,
+		TieBreak:         getTieBreak(ddbPoll),
+		TieBreakOptionId: ddbPoll.TieBreakOptionId,
+	}, tallies)
+
+	return &outcome, nil
+}
+
+// getTieBreak returns the poll's tie-break policy, where polls created without one have none
+func getTieBreak(ddbPoll DdbPoll) string {
+	if ddbPoll.TieBreak == "" {
+		return decision.TieBreakNone
+	}
+
+	return ddbPoll.TieBreak
+}
+
+// getMembers lists the poll's owner, who isn't stored as a member, followed by its members
+func getMembers(ctx context.Context, ddb *dynamodb.Client, ddbPoll DdbPoll) ([]Member, error) {
+	members := []Member{
+		{
+			UserId:  stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
+			Role:    pollaccess.RoleOwner,
+			AddedAt: ddbPoll.CreatedAt,
+		},
+	}
+
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :pk AND begins_with(#sk, :member)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{
+				Value: ddbPoll.PkPollId,
+			},
+			":member": &types.AttributeValueMemberS{
+				Value: pollaccess.MemberPrefix,
+			},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var ddbMembers []pollaccess.DdbMember
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &ddbMembers); err != nil {
+			return nil, err
+		}
+
+		for _, ddbMember := range ddbMembers {
+			members = append(members, Member{
+				UserId:  stripPrefix(ddbMember.SkUserId, pollaccess.MemberPrefix),
+				Role:    ddbMember.Role,
+				AddedAt: ddbMember.AddedAt,
+			})
+		}
+	}
+
+	return members, nil
+}
+
 func stripPrefix(s string, prefix string) string {
 	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
 		return s[len(prefix):]
@@ -155,31 +374,61 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
 	currentUserId := request.RequestContext.Authorizer["sub"]
 
-	if ddbPoll.IsArchived {
-		// NOTE: If this lambda is invoked by the `/public/polls/{pollId}` endpoint, the user will not be authenticated.
-		if currentUserId == nil {
-			err := errors.New("user is not authenticated")
+	var role string
+	if currentUserId != nil {
+		role, err = pollaccess.GetRole(
+			ctx,
+			ddb,
+			os.Getenv("SINGLE_TABLE_NAME"),
+			pollId,
+			ddbPoll.Gsi1PkUserId,
+			currentUserId.(string),
+		)
+		if err != nil {
 			return logAndReturn(
 				events.APIGatewayProxyResponse{
-					StatusCode: http.StatusUnauthorized,
-					Body:       formatError("Unauthorized", err),
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
 				},
 				err,
 			), nil
 		}
+	}
 
-		if stripPrefix(ddbPoll.Gsi1PkUserId, "user|") != currentUserId {
-			err := errors.New("user is not authorized to access this poll")
-			return logAndReturn(
-				events.APIGatewayProxyResponse{
-					StatusCode: http.StatusForbidden,
-					Body:       formatError("Forbidden", err),
-				},
-				err,
-			), nil
-		}
+	// NOTE: If this lambda is invoked by the `/public/polls/{pollId}` endpoint, the user will not be authenticated.
+	if ddbPoll.IsArchived && currentUserId == nil {
+		err := errors.New("user is not authenticated")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusUnauthorized,
+				Body:       formatError("Unauthorized", err),
+			},
+			err,
+		), nil
+	}
+
+	if isHidden(ddbPoll, role) {
+		err := errors.New("user is not authorized to access this poll")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
 	}
 
 	userHasVoted := false
@@ -243,35 +492,73 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
-	var ddbOption DdbOption
-	var ddbOptions []DdbOption
-	for _, item := range optionsResult.Items {
-		err = attributevalue.UnmarshalMap(item, &ddbOption)
-		if err != nil {
-			return logAndReturn(
-				events.APIGatewayProxyResponse{
-					StatusCode: http.StatusInternalServerError,
-					Body:       formatError("Internal server error", err),
-				},
-				err,
-			), nil
-		}
+	ddbOptions, err := unmarshalOptions(optionsResult.Items)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
 
-		ddbOptions = append(ddbOptions, ddbOption)
+	tallies, err := getTallies(ddbOptions)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
 	}
 
-	sort.Slice(ddbOptions, func(i, j int) bool {
-		return ddbOptions[i].ArrayIndex < ddbOptions[j].ArrayIndex
-	})
+	outcome, err := getOutcome(ddbPoll, tallies, time.Now())
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// The winner is the option the poll passed with, so the standings never contradict the outcome
+	standings := decision.Rank(tallies, outcome)
+	standingsByOptionId := make(map[string]decision.Standing, len(standings.Options))
+	for _, standing := range standings.Options {
+		standingsByOptionId[standing.OptionId] = standing
+	}
+
+	canView := pollaccess.Can(role, pollaccess.ActionView)
 
 	var options []Option
 	for _, ddbOption := range ddbOptions {
+		if !isShown(ddbOption, canView) {
+			continue
+		}
+
 		option := Option{
-			OptionId:  stripPrefix(ddbOption.PkOptionId, "option|"),
-			Text:      ddbOption.Text,
-			UpdatedAt: ddbOption.UpdatedAt,
-			Votes:     ddbOption.Votes,
-			IsMyVote:  false,
+			OptionId:    stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:        ddbOption.Text,
+			Description: ddbOption.Description,
+			Url:         ddbOption.Url,
+			ImageUrl:    ddbOption.ImageUrl,
+			UpdatedAt:   ddbOption.UpdatedAt,
+			Votes:       ddbOption.Votes,
+			Ballots:     getBallots(ddbOption),
+			IsMyVote:    false,
+		}
+		if canView {
+			option.WriteInStatus = ddbOption.WriteInStatus
+		}
+		if standing, ok := standingsByOptionId[option.OptionId]; ok {
+			option.Rank = standing.Rank
+			option.Percentage = standing.Percentage
+			option.IsWinner = standing.IsWinner
+			option.IsTied = standing.IsTied
 		}
 
 		if userHasVoted && stripPrefix(ddbOption.PkOptionId, "option|") == myVote.OptionId {
@@ -281,7 +568,7 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		options = append(options, option)
 	}
 
-	poll, err := json.Marshal(Poll{
+	poll := Poll{
 		PollId:     stripPrefix(ddbPoll.PkPollId, "poll|"),
 		UserId:     stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
 		Prompt:     ddbPoll.Prompt,
@@ -289,7 +576,43 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		CreatedAt:  ddbPoll.CreatedAt,
 		Duration:   ddbPoll.Duration,
 		IsArchived: ddbPoll.IsArchived,
-	})
+		SeriesId:   ddbPoll.SeriesId,
+
+		AllowWriteIns:    ddbPoll.AllowWriteIns,
+		ModerateWriteIns: ddbPoll.ModerateWriteIns,
+
+		Quorum:    ddbPoll.Quorum,
+		Threshold: ddbPoll.Threshold,
+		Outcome:   outcome,
+
+		TotalVotes:       standings.TotalVotes,
+		WinningOptionIds: standings.WinningOptionIds,
+		TieBreak:         getTieBreak(ddbPoll),
+		TieBreakOptionId: ddbPoll.TieBreakOptionId,
+	}
+	if poll.WinningOptionIds == nil {
+		poll.WinningOptionIds = []string{}
+	}
+
+	// The weights of the poll's voters are only shown to its owner and members
+	if canView {
+		poll.VoteWeights = ddbPoll.VoteWeights
+	}
+
+	if pollaccess.Can(role, pollaccess.ActionManage) {
+		poll.Members, err = getMembers(ctx, ddb, ddbPoll)
+		if err != nil {
+			return logAndReturn(
+				events.APIGatewayProxyResponse{
+					StatusCode: http.StatusInternalServerError,
+					Body:       formatError("Internal server error", err),
+				},
+				err,
+			), nil
+		}
+	}
+
+	response, err := json.Marshal(poll)
 	if err != nil {
 		return logAndReturn(
 			events.APIGatewayProxyResponse{
@@ -303,7 +626,7 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	return logAndReturn(
 		events.APIGatewayProxyResponse{
 			StatusCode: http.StatusOK,
-			Body:       string(poll),
+			Body:       string(response),
 		},
 		nil,
 	), nil
@@ -3,9 +3,14 @@ package main
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
 )
 
 func TestHandler(t *testing.T) {
@@ -30,3 +35,76 @@ func TestHandler(t *testing.T) {
 
 	t.Log(res)
 }
+
+// unmarshalPoll reads back a poll item the way it's written when the poll is created
+func unmarshalPoll(t *testing.T, isArchived bool) DdbPoll {
+	item, err := attributevalue.MarshalMap(pollcreate.DdbPoll{
+		PkPollId:     "poll|poll123",
+		SkPollId:     "poll|poll123",
+		Gsi1PkUserId: "user|user1",
+		Gsi1SkUserId: "user|user1",
+		Prompt:       "Ship it?",
+		CreatedAt:    "2024-01-01T00:00:00Z",
+		Duration:     3600,
+		IsArchived:   isArchived,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(item, &ddbPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	return ddbPoll
+}
+
+func TestIsHidden(t *testing.T) {
+	archived := unmarshalPoll(t, true)
+	if !archived.IsArchived {
+		t.Fatal("expected the poll to be archived")
+	}
+
+	if !isHidden(archived, "") {
+		t.Error("expected an archived poll to be hidden from a user who isn't a member")
+	}
+	if isHidden(archived, pollaccess.RoleViewer) {
+		t.Error("expected an archived poll to be shown to a member")
+	}
+	if isHidden(archived, pollaccess.RoleOwner) {
+		t.Error("expected an archived poll to be shown to its owner")
+	}
+	if isHidden(unmarshalPoll(t, false), "") {
+		t.Error("expected a poll that isn't archived to be shown to anyone")
+	}
+}
+
+func TestUnmarshalOptions(t *testing.T) {
+	var items []map[string]types.AttributeValue
+	for _, ddbOption := range []pollcreate.DdbOption{
+		{PkOptionId: "option|option3", Index: 2, Text: "Ramen"},
+		{PkOptionId: "option|option1", Index: 0, Text: "Tacos"},
+		{PkOptionId: "option|option2", Index: 1, Text: "Pho"},
+	} {
+		item, err := attributevalue.MarshalMap(ddbOption)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items = append(items, item)
+	}
+
+	ddbOptions, err := unmarshalOptions(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	for _, ddbOption := range ddbOptions {
+		texts = append(texts, ddbOption.Text)
+	}
+	if strings.Join(texts, ",") != "Tacos,Pho,Ramen" {
+		t.Errorf("expected the options in the order of their indexes, got %v", texts)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DdbSeries struct {
+	PkSeriesId   string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Schedule     string `dynamodbav:"Schedule"`
+	TimeZone     string `dynamodbav:"TimeZone"`
+	Prompt       string `dynamodbav:"Prompt"`
+	Status       string `dynamodbav:"Status"`
+}
+
+type DdbOccurrence struct {
+	PollId string `dynamodbav:"PollId"`
+	RunAt  string `dynamodbav:"RunAt"`
+}
+
+type DdbPoll struct {
+	Prompt     string `dynamodbav:"Prompt"`
+	CreatedAt  string `dynamodbav:"CreatedAt"`
+	Duration   int    `dynamodbav:"Duration"`
+	IsArchived bool   `dynamodbav:"IsArchived"`
+	DeletedAt  string `dynamodbav:"DeletedAt"`
+}
+
+type DdbOption struct {
+	PkOptionId string `dynamodbav:"PK"`
+	Index      int    `dynamodbav:"Index"`
+	Text       string `dynamodbav:"Text"`
+	Votes      int    `dynamodbav:"Votes"`
+}
+
+type Option struct {
+	OptionId string `json:"optionId"`
+	Text     string `json:"text"`
+	Votes    int    `json:"votes"`
+}
+
+type Occurrence struct {
+	PollId     string   `json:"pollId"`
+	RunAt      string   `json:"runAt"`
+	Prompt     string   `json:"prompt"`
+	CreatedAt  string   `json:"createdAt"`
+	Duration   int      `json:"duration"`
+	IsArchived bool     `json:"isArchived"`
+	TotalVotes int      `json:"totalVotes"`
+	Options    []Option `json:"options"`
+}
+
+type SeriesResults struct {
+	SeriesId    string       `json:"seriesId"`
+	Schedule    string       `json:"schedule"`
+	TimeZone    string       `json:"timeZone"`
+	Prompt      string       `json:"prompt"`
+	Status      string       `json:"status"`
+	Occurrences []Occurrence `json:"occurrences"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func getSeries(ctx context.Context, ddb *dynamodb.Client, seriesId string) (*DdbSeries, error) {
+	seriesResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("series|%s", seriesId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("series|%s", seriesId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if seriesResult.Item == nil {
+		return nil, nil
+	}
+
+	var ddbSeries DdbSeries
+	if err := attributevalue.UnmarshalMap(seriesResult.Item, &ddbSeries); err != nil {
+		return nil, err
+	}
+
+	return &ddbSeries, nil
+}
+
+// getOccurrences returns the series' occurrences in the order they ran
+func getOccurrences(ctx context.Context, ddb *dynamodb.Client, seriesId string) ([]DdbOccurrence, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :series AND begins_with(#sk, :occurrence)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":series": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("series|%s", seriesId),
+			},
+			":occurrence": &types.AttributeValueMemberS{
+				Value: "occurrence|",
+			},
+		},
+	})
+
+	var ddbOccurrences []DdbOccurrence
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageOccurrences []DdbOccurrence
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageOccurrences); err != nil {
+			return nil, err
+		}
+
+		ddbOccurrences = append(ddbOccurrences, pageOccurrences...)
+	}
+
+	return ddbOccurrences, nil
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (*DdbPoll, error) {
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pollResult.Item == nil {
+		return nil, nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return nil, err
+	}
+
+	return &ddbPoll, nil
+}
+
+func getOptions(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbOption, error) {
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return nil, err
+	}
+
+	return ddbOptions, nil
+}
+
+// newOccurrence totals the poll's votes, with its options in their order so they line up across occurrences
+func newOccurrence(ddbOccurrence DdbOccurrence, ddbPoll DdbPoll, ddbOptions []DdbOption) Occurrence {
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+
+	occurrence := Occurrence{
+		PollId:     ddbOccurrence.PollId,
+		RunAt:      ddbOccurrence.RunAt,
+		Prompt:     ddbPoll.Prompt,
+		CreatedAt:  ddbPoll.CreatedAt,
+		Duration:   ddbPoll.Duration,
+		IsArchived: ddbPoll.IsArchived,
+		Options:    []Option{},
+	}
+	for _, ddbOption := range ddbOptions {
+		if !strings.HasPrefix(ddbOption.PkOptionId, "option|") {
+			continue
+		}
+
+		occurrence.TotalVotes += ddbOption.Votes
+		occurrence.Options = append(occurrence.Options, Option{
+			OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:     ddbOption.Text,
+			Votes:    ddbOption.Votes,
+		})
+	}
+
+	return occurrence
+}
+
+// getSeriesResults skips the occurrences whose polls have since been deleted or purged
+func getSeriesResults(ctx context.Context, ddb *dynamodb.Client, seriesId string, ddbSeries DdbSeries) (SeriesResults, error) {
+	ddbOccurrences, err := getOccurrences(ctx, ddb, seriesId)
+	if err != nil {
+		return SeriesResults{}, err
+	}
+
+	seriesResults := SeriesResults{
+		SeriesId:    seriesId,
+		Schedule:    ddbSeries.Schedule,
+		TimeZone:    ddbSeries.TimeZone,
+		Prompt:      ddbSeries.Prompt,
+		Status:      ddbSeries.Status,
+		Occurrences: []Occurrence{},
+	}
+	for _, ddbOccurrence := range ddbOccurrences {
+		ddbPoll, err := getPoll(ctx, ddb, ddbOccurrence.PollId)
+		if err != nil {
+			return SeriesResults{}, err
+		}
+		if ddbPoll == nil || ddbPoll.DeletedAt != "" {
+			continue
+		}
+
+		ddbOptions, err := getOptions(ctx, ddb, ddbOccurrence.PollId)
+		if err != nil {
+			return SeriesResults{}, err
+		}
+
+		seriesResults.Occurrences = append(seriesResults.Occurrences, newOccurrence(ddbOccurrence, *ddbPoll, ddbOptions))
+	}
+
+	return seriesResults, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	seriesId := request.PathParameters["seriesId"]
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbSeries, err := getSeries(ctx, ddb, seriesId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Another user's series is reported as not found, so series ids can't be probed
+	if ddbSeries == nil || ddbSeries.Gsi1PkUserId != fmt.Sprintf("user|%s", userId) {
+		err := errors.New("series not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	seriesResults, err := getSeriesResults(ctx, ddb, seriesId, *ddbSeries)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	body, err := json.Marshal(seriesResults)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(body),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewOccurrence(t *testing.T) {
+	occurrence := newOccurrence(
+		DdbOccurrence{PollId: "poll1", RunAt: "2024-01-01T09:00:00Z"},
+		DdbPoll{Prompt: "Standup?", CreatedAt: "2024-01-01T09:00:12.345Z", Duration: 3600},
+		[]DdbOption{
+			{PkOptionId: "option|no", Index: 1, Text: "No", Votes: 2},
+			{PkOptionId: "webhook|hook1"},
+			{PkOptionId: "option|yes", Index: 0, Text: "Yes", Votes: 5},
+		},
+	)
+
+	expected := Occurrence{
+		PollId:     "poll1",
+		RunAt:      "2024-01-01T09:00:00Z",
+		Prompt:     "Standup?",
+		CreatedAt:  "2024-01-01T09:00:12.345Z",
+		Duration:   3600,
+		TotalVotes: 7,
+		Options: []Option{
+			{OptionId: "yes", Text: "Yes", Votes: 5},
+			{OptionId: "no", Text: "No", Votes: 2},
+		},
+	}
+	if !reflect.DeepEqual(occurrence, expected) {
+		t.Errorf("expected %+v, got %+v", expected, occurrence)
+	}
+}
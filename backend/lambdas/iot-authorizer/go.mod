@@ -2,4 +2,11 @@ module iot-authorizer
 
 go 1.21.5
 
-require github.com/aws/aws-lambda-go v1.42.0 // indirect
+require (
+	github.com/aws/aws-lambda-go v1.42.0
+	github.com/declanlscott/pseudopoll/backend/shared v0.0.0
+)
+
+require github.com/golang-jwt/jwt/v5 v5.2.0 // indirect
+
+replace github.com/declanlscott/pseudopoll/backend/shared => ../../shared
@@ -5,12 +5,58 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/declanlscott/pseudopoll/backend/shared/subscription"
 )
 
+const (
+	TokenQueryParameter = "token"
+
+	// Subscription tokens are short-lived and only checked when connecting, so the connection
+	// is dropped before the authorizer would refresh it with the then expired token
+	DisconnectAfterInSeconds = 3600
+	RefreshAfterInSeconds    = 86400
+)
+
+// getToken reads the subscription token from the authorizer token, the MQTT username or the WebSocket query string
+func getToken(request events.IoTCoreCustomAuthorizerRequest) (string, error) {
+	if request.Token != "" {
+		return request.Token, nil
+	}
+
+	if request.ProtocolData != nil && request.ProtocolData.MQTT != nil {
+		// IoT Core appends the authorizer name to the username as query parameters
+		username, _, _ := strings.Cut(request.ProtocolData.MQTT.Username, "?")
+		if username != "" {
+			return username, nil
+		}
+	}
+
+	if request.ProtocolData != nil && request.ProtocolData.HTTP != nil {
+		query, err := url.ParseQuery(request.ProtocolData.HTTP.QueryString)
+		if err != nil {
+			return "", err
+		}
+
+		if token := query.Get(TokenQueryParameter); token != "" {
+			return token, nil
+		}
+	}
+
+	return "", errors.New("subscription token not found")
+}
+
+func unauthenticated(err error) (events.IoTCoreCustomAuthorizerResponse, error) {
+	log.Printf("Error: %s", err)
+
+	return events.IoTCoreCustomAuthorizerResponse{IsAuthenticated: false}, nil
+}
+
 func handler(
 	ctx context.Context,
 	request events.IoTCoreCustomAuthorizerRequest,
@@ -29,11 +75,27 @@ func handler(
 		return events.IoTCoreCustomAuthorizerResponse{}, err
 	}
 
+	token, err := getToken(request)
+	if err != nil {
+		return unauthenticated(err)
+	}
+
+	claims, err := subscription.Verify([]byte(os.Getenv("SUBSCRIPTION_TOKEN_SECRET")), token)
+	if err != nil {
+		return unauthenticated(err)
+	}
+
+	var topicFilterArns, topicArns []string
+	for _, topic := range claims.Topics() {
+		topicFilterArns = append(topicFilterArns, fmt.Sprintf("arn:aws:iot:%s:%s:topicfilter/%s", region, accountId, topic))
+		topicArns = append(topicArns, fmt.Sprintf("arn:aws:iot:%s:%s:topic/%s", region, accountId, topic))
+	}
+
 	return events.IoTCoreCustomAuthorizerResponse{
 		IsAuthenticated:          true,
-		PrincipalID:              "Unauthenticated",
-		DisconnectAfterInSeconds: 3600,
-		RefreshAfterInSeconds:    300,
+		PrincipalID:              "Subscriber",
+		DisconnectAfterInSeconds: DisconnectAfterInSeconds,
+		RefreshAfterInSeconds:    RefreshAfterInSeconds,
 		PolicyDocuments: []*events.IAMPolicyDocument{
 			{
 				Version: "2012-10-17",
@@ -46,11 +108,16 @@ func handler(
 					{
 						Effect:   "Allow",
 						Action:   []string{"iot:Subscribe"},
-						Resource: []string{fmt.Sprintf("arn:aws:iot:%s:%s:topicfilter/*", region, accountId)},
+						Resource: topicFilterArns,
 					},
 					{
 						Effect:   "Allow",
 						Action:   []string{"iot:Receive"},
+						Resource: topicArns,
+					},
+					{
+						Effect:   "Deny",
+						Action:   []string{"iot:Publish", "iot:RetainPublish"},
 						Resource: []string{fmt.Sprintf("arn:aws:iot:%s:%s:topic/*", region, accountId)},
 					},
 				},
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/declanlscott/pseudopoll/backend/shared/subscription"
+)
+
+const testSecret = "test-secret"
+
+func setup(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("AWS_REGION", "us-east-2")
+	t.Setenv("AWS_ACCOUNT_ID", "123456789012")
+	t.Setenv("SUBSCRIPTION_TOKEN_SECRET", testSecret)
+}
+
+func sign(t *testing.T, pollId string, requestId string) string {
+	t.Helper()
+
+	token, _, err := subscription.Sign([]byte(testSecret), pollId, requestId, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestHandler(t *testing.T) {
+	setup(t)
+
+	res, err := handler(context.Background(), events.IoTCoreCustomAuthorizerRequest{
+		ProtocolData: &events.IoTCoreProtocolData{
+			MQTT: &events.IoTCoreMQTTContext{
+				Username: sign(t, "poll123", "request123") + "?x-amz-customauthorizer-name=pseudopoll-iot-authorizer",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.IsAuthenticated {
+		t.Fatal("expected the connection to be authenticated")
+	}
+
+	statements := res.PolicyDocuments[0].Statement
+	subscribe := strings.Join(statements[1].Resource, " ")
+	if subscribe != "arn:aws:iot:us-east-2:123456789012:topicfilter/poll/poll123 arn:aws:iot:us-east-2:123456789012:topicfilter/vote/request123" {
+		t.Errorf("unexpected subscribe resources %s", subscribe)
+	}
+	receive := strings.Join(statements[2].Resource, " ")
+	if receive != "arn:aws:iot:us-east-2:123456789012:topic/poll/poll123 arn:aws:iot:us-east-2:123456789012:topic/vote/request123" {
+		t.Errorf("unexpected receive resources %s", receive)
+	}
+	if statements[3].Effect != "Deny" || statements[3].Action[0] != "iot:Publish" {
+		t.Errorf("expected publish to be denied, got %v", statements[3])
+	}
+}
+
+func TestHandlerReadsQueryStringToken(t *testing.T) {
+	setup(t)
+
+	res, err := handler(context.Background(), events.IoTCoreCustomAuthorizerRequest{
+		ProtocolData: &events.IoTCoreProtocolData{
+			HTTP: &events.IoTCoreHTTPContext{
+				QueryString: "x-amz-customauthorizer-name=pseudopoll-iot-authorizer&token=" + sign(t, "poll123", ""),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.IsAuthenticated {
+		t.Fatal("expected the connection to be authenticated")
+	}
+	if len(res.PolicyDocuments[0].Statement[1].Resource) != 1 {
+		t.Errorf("expected only the poll topic, got %v", res.PolicyDocuments[0].Statement[1].Resource)
+	}
+}
+
+func TestHandlerRejectsInvalidTokens(t *testing.T) {
+	setup(t)
+
+	tests := map[string]events.IoTCoreCustomAuthorizerRequest{
+		"missing": {},
+		"invalid": {Token: "not-a-token"},
+	}
+
+	for name, request := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := handler(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.IsAuthenticated {
+				t.Error("expected the connection to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type DdbTemplate struct {
+	PkTemplateId string   `dynamodbav:"PK"`
+	Name         string   `dynamodbav:"Name"`
+	Prompt       string   `dynamodbav:"Prompt"`
+	Options      []string `dynamodbav:"Options"`
+	Duration     int      `dynamodbav:"Duration"`
+	CreatedAt    string   `dynamodbav:"CreatedAt"`
+	UpdatedAt    string   `dynamodbav:"UpdatedAt"`
+}
+
+type Template struct {
+	TemplateId string   `json:"templateId"`
+	Name       string   `json:"name"`
+	Prompt     string   `json:"prompt"`
+	Options    []string `json:"options"`
+	Duration   int      `json:"duration"`
+	CreatedAt  string   `json:"createdAt"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func getTemplates(ctx context.Context, ddb *dynamodb.Client, userId string) ([]DdbTemplate, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#user = :user AND begins_with(#template, :template)"),
+		ExpressionAttributeNames: map[string]string{
+			"#user":     "GSI1PK",
+			"#template": "GSI1SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("user|%s", userId),
+			},
+			":template": &types.AttributeValueMemberS{
+				Value: "template|",
+			},
+		},
+	})
+
+	var ddbTemplates []DdbTemplate
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageTemplates []DdbTemplate
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageTemplates); err != nil {
+			return nil, err
+		}
+
+		ddbTemplates = append(ddbTemplates, pageTemplates...)
+	}
+
+	return ddbTemplates, nil
+}
+
+func newTemplates(ddbTemplates []DdbTemplate) []Template {
+	templates := make([]Template, 0, len(ddbTemplates))
+	for _, ddbTemplate := range ddbTemplates {
+		templates = append(templates, Template{
+			TemplateId: stripPrefix(ddbTemplate.PkTemplateId, "template|"),
+			Name:       ddbTemplate.Name,
+			Prompt:     ddbTemplate.Prompt,
+			Options:    ddbTemplate.Options,
+			Duration:   ddbTemplate.Duration,
+			CreatedAt:  ddbTemplate.CreatedAt,
+			UpdatedAt:  ddbTemplate.UpdatedAt,
+		})
+	}
+
+	return templates
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	ddbTemplates, err := getTemplates(ctx, ddb, userId)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	responseBody, err := json.Marshal(newTemplates(ddbTemplates))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(responseBody),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTemplates(t *testing.T) {
+	templates := newTemplates([]DdbTemplate{
+		{PkTemplateId: "template|template1", Name: "Lunch", Prompt: "Where to?", Options: []string{"Tacos", "Pho"}, Duration: 600},
+	})
+
+	expected := []Template{
+		{TemplateId: "template1", Name: "Lunch", Prompt: "Where to?", Options: []string{"Tacos", "Pho"}, Duration: 600},
+	}
+	if !reflect.DeepEqual(templates, expected) {
+		t.Errorf("expected %+v, got %+v", expected, templates)
+	}
+
+	if templates := newTemplates(nil); templates == nil || len(templates) != 0 {
+		t.Errorf("expected an empty list of templates, got %#v", templates)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+}
+
+type DdbDelivery struct {
+	PkWebhookId  string `dynamodbav:"PK"`
+	SkDeliveryId string `dynamodbav:"SK"`
+	PollId       string `dynamodbav:"PollId"`
+	DeliveryId   string `dynamodbav:"DeliveryId"`
+	Event        string `dynamodbav:"Event"`
+	MessageId    string `dynamodbav:"MessageId"`
+	Attempts     int    `dynamodbav:"Attempts"`
+	StatusCode   int    `dynamodbav:"StatusCode"`
+	Succeeded    bool   `dynamodbav:"Succeeded"`
+	Error        string `dynamodbav:"Error"`
+	DeliveredAt  string `dynamodbav:"DeliveredAt"`
+}
+
+type Delivery struct {
+	DeliveryId  string `json:"deliveryId"`
+	WebhookId   string `json:"webhookId"`
+	Event       string `json:"event"`
+	MessageId   string `json:"messageId"`
+	Attempts    int    `json:"attempts"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	Succeeded   bool   `json:"succeeded"`
+	Error       string `json:"error,omitempty"`
+	DeliveredAt string `json:"deliveredAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	DefaultLimit = 25
+	MaxLimit     = 100
+)
+
+// getLimit reads how many of the most recent deliveries to return from the query string
+func getLimit(queryStringParameters map[string]string) (int32, error) {
+	rawLimit := queryStringParameters["limit"]
+	if rawLimit == "" {
+		return DefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > MaxLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+
+	return int32(limit), nil
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	pollId := request.PathParameters["pollId"]
+	webhookId := request.PathParameters["webhookId"]
+
+	limit, err := getLimit(request.QueryStringParameters)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err = attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Co-owners manage the poll's webhooks alongside its owner
+	_, err = pollaccess.Authorize(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		pollId,
+		ddbPoll.Gsi1PkUserId,
+		request.RequestContext.Authorizer["sub"].(string),
+		pollaccess.ActionManage,
+	)
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Deliveries are kept after their webhook is deleted, so they're matched to the poll by attribute
+	deliveriesResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :webhook AND begins_with(#sk, :delivery)"),
+		FilterExpression:       aws.String("#pollId = :pollId"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":     "PK",
+			"#sk":     "SK",
+			"#pollId": "PollId",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":webhook": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("webhook|%s", webhookId),
+			},
+			":delivery": &types.AttributeValueMemberS{
+				Value: "delivery|",
+			},
+			":pollId": &types.AttributeValueMemberS{
+				Value: pollId,
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(limit),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbDeliveries []DdbDelivery
+	if err = attributevalue.UnmarshalListOfMaps(deliveriesResult.Items, &ddbDeliveries); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	deliveries := make([]Delivery, 0, len(ddbDeliveries))
+	for _, ddbDelivery := range ddbDeliveries {
+		deliveries = append(deliveries, Delivery{
+			DeliveryId:  ddbDelivery.DeliveryId,
+			WebhookId:   stripPrefix(ddbDelivery.PkWebhookId, "webhook|"),
+			Event:       ddbDelivery.Event,
+			MessageId:   ddbDelivery.MessageId,
+			Attempts:    ddbDelivery.Attempts,
+			StatusCode:  ddbDelivery.StatusCode,
+			Succeeded:   ddbDelivery.Succeeded,
+			Error:       ddbDelivery.Error,
+			DeliveredAt: ddbDelivery.DeliveredAt,
+		})
+	}
+
+	responseBody, err := json.Marshal(deliveries)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(responseBody),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+)
+
+type RequestBody struct {
+	Status string `json:"status"`
+}
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+}
+
+type DdbOption struct {
+	PkOptionId    string `dynamodbav:"PK"`
+	Gsi1PkPollId  string `dynamodbav:"GSI1PK"`
+	Text          string `dynamodbav:"Text"`
+	Votes         int    `dynamodbav:"Votes"`
+	Version       int64  `dynamodbav:"Version"`
+	UpdatedAt     string `dynamodbav:"UpdatedAt"`
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+// DdbAddedOption is recorded on the poll when a written in option is approved, so it's announced to voters
+type DdbAddedOption struct {
+	OptionId string `dynamodbav:"OptionId"`
+	Text     string `dynamodbav:"Text"`
+	Votes    int    `dynamodbav:"Votes"`
+	AddedAt  string `dynamodbav:"AddedAt"`
+}
+
+type WriteIn struct {
+	OptionId      string `json:"optionId"`
+	Text          string `json:"text"`
+	Votes         int    `json:"votes"`
+	WriteInStatus string `json:"writeInStatus"`
+	UpdatedAt     string `json:"updatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var (
+	errUnknownStatus = errors.New("unknown status")
+	errNotPending    = errors.New("write-in was already moderated")
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// checkStatus checks the write-in can be moderated to the status. Moderation is final, so only pending
+// write-ins can be approved or rejected.
+func checkStatus(ddbOption DdbOption, status string) error {
+	if status != pollcreate.WriteInStatusApproved && status != pollcreate.WriteInStatusRejected {
+		return fmt.Errorf("%w: %q", errUnknownStatus, status)
+	}
+	if ddbOption.WriteInStatus != pollcreate.WriteInStatusPending {
+		return errNotPending
+	}
+
+	return nil
+}
+
+// newTransactItems moderates the option and moves it and the poll to the sequence number. Approving a write-in
+// also records it as added to the poll, with the votes it was held with, which announces it to voters.
+func newTransactItems(
+	tableName string,
+	ddbPoll DdbPoll,
+	ddbOption DdbOption,
+	status string,
+	sequence int64,
+	now time.Time,
+) ([]types.TransactWriteItem, error) {
+	updatedAt := now.UTC().Format(RFC3339Milli)
+
+	pollUpdateExpression := "SET #version = :version"
+	pollExpressionAttributeNames := map[string]string{
+		"#pk":        "PK",
+		"#version":   "Version",
+		"#deletedAt": "DeletedAt",
+	}
+	pollExpressionAttributeValues := map[string]types.AttributeValue{
+		":currentVersion": &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(ddbPoll.Version, 10),
+		},
+		":version": &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(sequence, 10),
+		},
+	}
+	if status == pollcreate.WriteInStatusApproved {
+		addedOption, err := attributevalue.Marshal(DdbAddedOption{
+			OptionId: stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:     ddbOption.Text,
+			Votes:    ddbOption.Votes,
+			AddedAt:  updatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		pollUpdateExpression += ", #addedOption = :addedOption"
+		pollExpressionAttributeNames["#addedOption"] = "AddedOption"
+		pollExpressionAttributeValues[":addedOption"] = addedOption
+	}
+
+	return []types.TransactWriteItem{
+		{
+			// Votes counted since the option was read would be announced with the wrong count, so the
+			// option is conditioned on them too
+			Update: &types.Update{
+				TableName: aws.String(tableName),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{
+						Value: ddbOption.PkOptionId,
+					},
+					"SK": &types.AttributeValueMemberS{
+						Value: ddbOption.PkOptionId,
+					},
+				},
+				UpdateExpression:    aws.String("SET #writeInStatus = :status, #version = :version, #updatedAt = :updatedAt"),
+				ConditionExpression: aws.String("#writeInStatus = :pending AND #votes = :votes"),
+				ExpressionAttributeNames: map[string]string{
+					"#writeInStatus": "WriteInStatus",
+					"#version":       "Version",
+					"#updatedAt":     "UpdatedAt",
+					"#votes":         "Votes",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":status": &types.AttributeValueMemberS{
+						Value: status,
+					},
+					":version": &types.AttributeValueMemberN{
+						Value: strconv.FormatInt(sequence, 10),
+					},
+					":updatedAt": &types.AttributeValueMemberS{
+						Value: updatedAt,
+					},
+					":pending": &types.AttributeValueMemberS{
+						Value: pollcreate.WriteInStatusPending,
+					},
+					":votes": &types.AttributeValueMemberN{
+						Value: strconv.Itoa(ddbOption.Votes),
+					},
+				},
+			},
+		},
+		{
+			Update: &types.Update{
+				TableName: aws.String(tableName),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{
+						Value: ddbPoll.PkPollId,
+					},
+					"SK": &types.AttributeValueMemberS{
+						Value: ddbPoll.PkPollId,
+					},
+				},
+				UpdateExpression: aws.String(pollUpdateExpression),
+				ConditionExpression: aws.String(
+					"attribute_exists(#pk) AND attribute_not_exists(#deletedAt) AND " +
+						"(attribute_not_exists(#version) OR #version = :currentVersion)",
+				),
+				ExpressionAttributeNames:  pollExpressionAttributeNames,
+				ExpressionAttributeValues: pollExpressionAttributeValues,
+			},
+		},
+	}, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	now := time.Now()
+	userId := request.RequestContext.Authorizer["sub"].(string)
+	tableName := os.Getenv("SINGLE_TABLE_NAME")
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	pollKey := fmt.Sprintf("poll|%s", request.PathParameters["pollId"])
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: pollKey,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: pollKey,
+			},
+		},
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if pollResult.Item == nil {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	role, err := pollaccess.Authorize(
+		ctx,
+		ddb,
+		tableName,
+		request.PathParameters["pollId"],
+		ddbPoll.Gsi1PkUserId,
+		userId,
+		pollaccess.ActionManage,
+	)
+	// A poll the user isn't a member of is reported as not found, so poll ids can't be probed
+	if errors.Is(err, pollaccess.ErrForbidden) && role == "" {
+		err := errors.New("poll not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if ddbPoll.DeletedAt != "" {
+		err := errors.New("poll was deleted")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusGone,
+				Body:       formatError("Gone", err),
+			},
+			err,
+		), nil
+	}
+
+	optionKey := fmt.Sprintf("option|%s", request.PathParameters["optionId"])
+	optionResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: optionKey,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: optionKey,
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbOption DdbOption
+	if err := attributevalue.UnmarshalMap(optionResult.Item, &ddbOption); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Options that weren't written in, or are another poll's, aren't write-ins of this poll
+	if optionResult.Item == nil || ddbOption.Gsi1PkPollId != pollKey || ddbOption.WriteInStatus == "" {
+		err := errors.New("write-in not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	err = checkStatus(ddbOption, requestBody.Status)
+	if errors.Is(err, errNotPending) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	sequence, err := pollsequence.Next(ctx, ddb, tableName, stripPrefix(ddbPoll.PkPollId, "poll|"), ddbPoll.Version)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	transactItems, err := newTransactItems(tableName, ddbPoll, ddbOption, requestBody.Status, sequence, now)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	var transactionCanceled *types.TransactionCanceledException
+	if errors.As(err, &transactionCanceled) {
+		err := errors.New("write-in was modified, try again")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	writeIn, err := json.Marshal(WriteIn{
+		OptionId:      stripPrefix(ddbOption.PkOptionId, "option|"),
+		Text:          ddbOption.Text,
+		Votes:         ddbOption.Votes,
+		WriteInStatus: requestBody.Status,
+		UpdatedAt:     now.UTC().Format(RFC3339Milli),
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(writeIn),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
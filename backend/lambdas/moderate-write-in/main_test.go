@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+)
+
+func TestCheckStatus(t *testing.T) {
+	tests := map[string]struct {
+		writeInStatus string
+		status        string
+		err           error
+	}{
+		"approve":  {writeInStatus: pollcreate.WriteInStatusPending, status: pollcreate.WriteInStatusApproved},
+		"reject":   {writeInStatus: pollcreate.WriteInStatusPending, status: pollcreate.WriteInStatusRejected},
+		"approved": {writeInStatus: pollcreate.WriteInStatusApproved, status: pollcreate.WriteInStatusRejected, err: errNotPending},
+		"rejected": {writeInStatus: pollcreate.WriteInStatusRejected, status: pollcreate.WriteInStatusApproved, err: errNotPending},
+		"pending":  {writeInStatus: pollcreate.WriteInStatusPending, status: pollcreate.WriteInStatusPending, err: errUnknownStatus},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkStatus(DdbOption{WriteInStatus: test.writeInStatus}, test.status)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNewTransactItems(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	ddbPoll := DdbPoll{PkPollId: "poll|poll1", Version: 4}
+	ddbOption := DdbOption{
+		PkOptionId:    "option|option1",
+		Text:          "Other",
+		Votes:         3,
+		Version:       3,
+		WriteInStatus: pollcreate.WriteInStatusPending,
+	}
+
+	approved, err := newTransactItems("table", ddbPoll, ddbOption, pollcreate.WriteInStatusApproved, 9, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approved) != 2 {
+		t.Fatalf("expected the option and poll to be updated, got %d items", len(approved))
+	}
+	for _, item := range approved {
+		if version := item.Update.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value; version != "9" {
+			t.Errorf("expected the option and poll to move to the sequence number, got %s", version)
+		}
+	}
+	if votes := approved[0].Update.ExpressionAttributeValues[":votes"].(*types.AttributeValueMemberN).Value; votes != "3" {
+		t.Errorf("expected the option to be conditioned on its 3 votes, got %s", votes)
+	}
+	addedOption, ok := approved[1].Update.ExpressionAttributeValues[":addedOption"].(*types.AttributeValueMemberM)
+	if !ok {
+		t.Fatal("expected approving the write-in to add it to the poll")
+	}
+	if optionId := addedOption.Value["OptionId"].(*types.AttributeValueMemberS).Value; optionId != "option1" {
+		t.Errorf("expected option1 to be added, got %s", optionId)
+	}
+
+	rejected, err := newTransactItems("table", ddbPoll, ddbOption, pollcreate.WriteInStatusRejected, 9, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rejected[1].Update.ExpressionAttributeValues[":addedOption"]; ok {
+		t.Error("expected rejecting the write-in not to add it to the poll")
+	}
+	if expression := aws.ToString(rejected[1].Update.UpdateExpression); expression != "SET #version = :version" {
+		t.Errorf("expected only the poll's version to be bumped, got %s", expression)
+	}
+}
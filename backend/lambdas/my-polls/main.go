@@ -86,9 +86,12 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
 		IndexName:              aws.String("GSI1"),
 		KeyConditionExpression: aws.String("#GSI1PK = :userId AND #GSI1SK = :userId"),
+		// Deleted polls keep their owner until every item of theirs has been deleted
+		FilterExpression: aws.String("attribute_not_exists(#deletedAt)"),
 		ExpressionAttributeNames: map[string]string{
-			"#GSI1PK": "GSI1PK",
-			"#GSI1SK": "GSI1SK",
+			"#GSI1PK":    "GSI1PK",
+			"#GSI1SK":    "GSI1SK",
+			"#deletedAt": "DeletedAt",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":userId": &types.AttributeValueMemberS{
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// DeletionRequestedDetail asks for a poll's items to be deleted. An expired poll was already removed
+// by its time to live, so only its other items are left to delete.
+type DeletionRequestedDetail struct {
+	PollId    string `json:"pollId"`
+	IsExpired bool   `json:"isExpired,omitempty"`
+}
+
+// StreamDetail is the part of a DynamoDB stream record that tells a removal by time to live apart
+type StreamDetail struct {
+	EventName    string `json:"eventName"`
+	UserIdentity struct {
+		Type        string `json:"type"`
+		PrincipalId string `json:"principalId"`
+	} `json:"userIdentity"`
+	DynamoDb struct {
+		Keys struct {
+			PK struct {
+				S string `json:"S"`
+			} `json:"PK"`
+		} `json:"Keys"`
+	} `json:"dynamodb"`
+}
+
+// DdbPoll is the poll being deleted. Attributes are the names of all of its attributes that are removed
+// once its items are deleted.
+type DdbPoll struct {
+	PkPollId   string   `dynamodbav:"PK"`
+	SkPollId   string   `dynamodbav:"SK"`
+	DeletedAt  string   `dynamodbav:"DeletedAt"`
+	Attributes []string `dynamodbav:"-"`
+}
+
+type DdbKey struct {
+	PK string `dynamodbav:"PK"`
+	SK string `dynamodbav:"SK"`
+}
+
+// Step deletes one kind of the poll's items. BeforeDelete deletes the items that belong to a page of
+// them, so nothing is left behind that can no longer be found.
+type Step struct {
+	Name         string
+	Query        *dynamodb.QueryInput
+	BeforeDelete func(ctx context.Context, keys []DdbKey) error
+}
+
+const (
+	// BatchWriteItem deletes at most 25 items per request
+	MaxBatchWriteItems   = 25
+	MaxBatchWriteRetries = 5
+
+	// ResumeMargin is how long before the invocation times out that the deletion stops and is resumed
+	// by another invocation
+	ResumeMargin = 10 * time.Second
+)
+
+var errOutOfTime = errors.New("out of time")
+
+// KeptAttributes are all that's left of the poll once its items are deleted, a record that it was. Every
+// other attribute is removed, whichever the poll had.
+var KeptAttributes = []string{"PK", "SK", "Version", "DeletedAt"}
+
+func keysQuery(indexName string, partitionKey string, partition string, sortKeyPrefix string) *dynamodb.QueryInput {
+	query := &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#partition = :partition"),
+		ProjectionExpression:   aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]string{
+			"#partition": partitionKey,
+			"#pk":        "PK",
+			"#sk":        "SK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":partition": &ddbTypes.AttributeValueMemberS{Value: partition},
+		},
+		Limit: aws.Int32(MaxBatchWriteItems),
+	}
+
+	if indexName != "" {
+		query.IndexName = aws.String(indexName)
+	}
+
+	if sortKeyPrefix != "" {
+		query.KeyConditionExpression = aws.String("#partition = :partition AND begins_with(#sk, :prefix)")
+		query.ExpressionAttributeValues[":prefix"] = &ddbTypes.AttributeValueMemberS{Value: sortKeyPrefix}
+	}
+
+	return query
+}
+
+// newSteps lists the deletion's steps in order. Webhooks are deleted last, so they're the likeliest to
+// still exist when the pollDeleted message is delivered to them. Votes cast before votes were indexed
+// by their poll are found once the table backfill has indexed them.
+func newSteps(ddb *dynamodb.Client, pollId string, deadline time.Time) []Step {
+	return []Step{
+		{
+			Name:  "votes",
+			Query: keysQuery("GSI1", "GSI1PK", fmt.Sprintf("votes|%s", pollId), ""),
+		},
+		{
+			Name:  "options",
+			Query: keysQuery("GSI1", "GSI1PK", fmt.Sprintf("poll|%s", pollId), ""),
+		},
+		{
+			Name:  "chat messages",
+			Query: keysQuery("", "PK", fmt.Sprintf("poll|%s", pollId), "chatmessage|"),
+		},
+		{
+			Name:  "timeline",
+			Query: keysQuery("", "PK", fmt.Sprintf("poll|%s", pollId), "timeline|"),
+		},
+		{
+			Name:  "write-ins",
+			Query: keysQuery("", "PK", fmt.Sprintf("poll|%s", pollId), "writein|"),
+		},
+		{
+			Name:  "members",
+			Query: keysQuery("", "PK", fmt.Sprintf("poll|%s", pollId), "member|"),
+		},
+		{
+			Name:  "webhooks",
+			Query: keysQuery("", "PK", fmt.Sprintf("poll|%s", pollId), "webhook|"),
+			BeforeDelete: func(ctx context.Context, keys []DdbKey) error {
+				for _, key := range keys {
+					deliveries := keysQuery("", "PK", key.SK, "delivery|")
+					if err := deleteAll(ctx, ddb, deliveries, nil, deadline); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:  "sequence",
+			Query: keysQuery("", "PK", fmt.Sprintf("poll|%s", pollId), "sequence"),
+		},
+	}
+}
+
+// deleteAll deletes the items found by the query a page at a time. It returns errOutOfTime if the
+// deadline is near before they've all been deleted.
+func deleteAll(
+	ctx context.Context,
+	ddb *dynamodb.Client,
+	query *dynamodb.QueryInput,
+	beforeDelete func(ctx context.Context, keys []DdbKey) error,
+	deadline time.Time,
+) error {
+	paginator := dynamodb.NewQueryPaginator(ddb, query)
+	for paginator.HasMorePages() {
+		if time.Until(deadline) < ResumeMargin {
+			return errOutOfTime
+		}
+
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var keys []DdbKey
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &keys); err != nil {
+			return err
+		}
+
+		if beforeDelete != nil {
+			if err := beforeDelete(ctx, keys); err != nil {
+				return err
+			}
+		}
+
+		if err := deleteKeys(ctx, ddb, keys); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteKeys(ctx context.Context, ddb *dynamodb.Client, keys []DdbKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var writeRequests []ddbTypes.WriteRequest
+	for _, key := range keys {
+		item, err := attributevalue.MarshalMap(key)
+		if err != nil {
+			return err
+		}
+
+		writeRequests = append(writeRequests, ddbTypes.WriteRequest{
+			DeleteRequest: &ddbTypes.DeleteRequest{Key: item},
+		})
+	}
+
+	requestItems := map[string][]ddbTypes.WriteRequest{
+		os.Getenv("SINGLE_TABLE_NAME"): writeRequests,
+	}
+	for attempt := 1; len(requestItems) > 0; attempt++ {
+		if attempt > MaxBatchWriteRetries {
+			return errors.New("items were left unprocessed after retrying")
+		}
+
+		result, err := ddb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return err
+		}
+
+		requestItems = result.UnprocessedItems
+		if len(requestItems) > 0 {
+			time.Sleep(time.Duration(attempt*100) * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+// getRemovedAttributes returns the names of the item's attributes that aren't kept, in order
+func getRemovedAttributes(item map[string]ddbTypes.AttributeValue) []string {
+	attributes := make([]string, 0, len(item))
+	for attribute := range item {
+		if !slices.Contains(KeptAttributes, attribute) {
+			attributes = append(attributes, attribute)
+		}
+	}
+	slices.Sort(attributes)
+
+	return attributes
+}
+
+func removeExpression(attributes []string) (string, map[string]string) {
+	names := make([]string, 0, len(attributes))
+	expressionAttributeNames := make(map[string]string, len(attributes))
+	for index, attribute := range attributes {
+		name := fmt.Sprintf("#attribute%d", index)
+		names = append(names, name)
+		expressionAttributeNames[name] = attribute
+	}
+
+	return "REMOVE " + strings.Join(names, ", "), expressionAttributeNames
+}
+
+// emptyPoll removes everything but the poll's keys, version and deletion time, so the poll is known to have
+// been deleted rather than never existing
+func emptyPoll(ctx context.Context, ddb *dynamodb.Client, ddbPoll DdbPoll) error {
+	if len(ddbPoll.Attributes) == 0 {
+		return nil
+	}
+
+	updateExpression, expressionAttributeNames := removeExpression(ddbPoll.Attributes)
+	expressionAttributeNames["#deletedAt"] = "DeletedAt"
+
+	_, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+			"SK": &ddbTypes.AttributeValueMemberS{Value: ddbPoll.SkPollId},
+		},
+		ConditionExpression:      aws.String("attribute_exists(#deletedAt)"),
+		UpdateExpression:         aws.String(updateExpression),
+		ExpressionAttributeNames: expressionAttributeNames,
+	})
+
+	return err
+}
+
+// newExpiredDetail returns the deletion of the items of a poll that DynamoDB removed by its time to live.
+// It reports false for any other removal, such as of a poll that's being deleted item by item.
+func newExpiredDetail(streamDetail StreamDetail) (DeletionRequestedDetail, bool) {
+	pk := streamDetail.DynamoDb.Keys.PK.S
+	if streamDetail.EventName != "REMOVE" || !strings.HasPrefix(pk, "poll|") ||
+		streamDetail.UserIdentity.Type != "Service" || streamDetail.UserIdentity.PrincipalId != "dynamodb.amazonaws.com" {
+		return DeletionRequestedDetail{}, false
+	}
+
+	return DeletionRequestedDetail{
+		PollId:    strings.TrimPrefix(pk, "poll|"),
+		IsExpired: true,
+	}, true
+}
+
+// resume requests another invocation to continue the deletion where this one stopped. It is requested
+// like any deletion, even when this one started from the stream, so the stream's other consumers
+// don't see the removal again.
+func resume(ctx context.Context, ebClient *eventbridge.Client, detail DeletionRequestedDetail) error {
+	detailJson, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+
+	result, err := ebClient.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebTypes.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(os.Getenv("EVENT_BUS_NAME")),
+				Source:       aws.String(os.Getenv("DELETION_REQUESTED_SOURCE")),
+				DetailType:   aws.String(os.Getenv("DELETION_REQUESTED_DETAIL_TYPE")),
+				Detail:       aws.String(string(detailJson)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.FailedEntryCount > 0 {
+		return errors.New(aws.ToString(result.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (*DdbPoll, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(result.Item, &ddbPoll); err != nil {
+		return nil, err
+	}
+	ddbPoll.Attributes = getRemovedAttributes(result.Item)
+
+	return &ddbPoll, nil
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) {
+	log.Printf("Processing event: %s\n", event)
+
+	var detail DeletionRequestedDetail
+	switch {
+	case event.Source == os.Getenv("DELETION_REQUESTED_SOURCE") && event.DetailType == os.Getenv("DELETION_REQUESTED_DETAIL_TYPE"):
+		if err := json.Unmarshal(event.Detail, &detail); err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+	case event.Source == os.Getenv("DDB_STREAM_SOURCE") && event.DetailType == os.Getenv("DDB_STREAM_DETAIL_TYPE"):
+		var streamDetail StreamDetail
+		if err := json.Unmarshal(event.Detail, &streamDetail); err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		expiredDetail, ok := newExpiredDetail(streamDetail)
+		if !ok {
+			log.Printf("Skipping removal of %s, which didn't expire\n", streamDetail.DynamoDb.Keys.PK.S)
+			return
+		}
+		detail = expiredDetail
+	default:
+		log.Printf("Unknown event source or detail type: %s, %s\n", event.Source, event.DetailType)
+		return
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+	ebClient := eventbridge.NewFromConfig(cfg)
+
+	// Only polls marked as deleted by their owner, or removed by their time to live, have their items deleted
+	ddbPoll, err := getPoll(ctx, ddb, detail.PollId)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	if !detail.IsExpired && (ddbPoll == nil || ddbPoll.DeletedAt == "") {
+		log.Printf("Skipping poll %s, which isn't marked as deleted\n", detail.PollId)
+		return
+	}
+
+	for _, step := range newSteps(ddb, detail.PollId, deadline) {
+		err := deleteAll(ctx, ddb, step.Query, step.BeforeDelete, deadline)
+		if errors.Is(err, errOutOfTime) {
+			log.Printf("Resuming deletion of poll %s at its %s\n", detail.PollId, step.Name)
+
+			if err := resume(ctx, ebClient, detail); err != nil {
+				log.Printf("Error: %s\n", err)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		log.Printf("Deleted the %s of poll %s\n", step.Name, detail.PollId)
+	}
+
+	// An expired poll is already gone, so there is nothing left of it to empty
+	if ddbPoll == nil {
+		log.Printf("Deleted the items of expired poll %s\n", detail.PollId)
+		return
+	}
+
+	if err := emptyPoll(ctx, ddb, *ddbPoll); err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	log.Printf("Deleted poll %s\n", detail.PollId)
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestKeysQuery(t *testing.T) {
+	query := keysQuery("", "PK", "poll|poll123", "webhook|")
+	if query.IndexName != nil {
+		t.Errorf("expected the table to be queried, got index %s", aws.ToString(query.IndexName))
+	}
+	if aws.ToString(query.KeyConditionExpression) != "#partition = :partition AND begins_with(#sk, :prefix)" {
+		t.Errorf("unexpected key condition %s", aws.ToString(query.KeyConditionExpression))
+	}
+	if prefix := query.ExpressionAttributeValues[":prefix"].(*ddbTypes.AttributeValueMemberS).Value; prefix != "webhook|" {
+		t.Errorf("expected the webhook prefix, got %s", prefix)
+	}
+
+	query = keysQuery("GSI1", "GSI1PK", "votes|poll123", "")
+	if aws.ToString(query.IndexName) != "GSI1" || aws.ToString(query.KeyConditionExpression) != "#partition = :partition" {
+		t.Errorf("unexpected votes query %+v", query)
+	}
+	if query.ExpressionAttributeNames["#partition"] != "GSI1PK" || aws.ToInt32(query.Limit) != MaxBatchWriteItems {
+		t.Errorf("expected a page of votes at a time, got %+v", query)
+	}
+}
+
+func TestNewSteps(t *testing.T) {
+	var names []string
+	for _, step := range newSteps(nil, "poll123", time.Now()) {
+		names = append(names, step.Name)
+	}
+
+	expected := []string{"votes", "options", "chat messages", "timeline", "write-ins", "members", "webhooks", "sequence"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestGetRemovedAttributes(t *testing.T) {
+	item := map[string]ddbTypes.AttributeValue{
+		"PK":          &ddbTypes.AttributeValueMemberS{Value: "poll|poll123"},
+		"SK":          &ddbTypes.AttributeValueMemberS{Value: "poll|poll123"},
+		"Version":     &ddbTypes.AttributeValueMemberN{Value: "7"},
+		"DeletedAt":   &ddbTypes.AttributeValueMemberS{Value: "2024-01-01T00:00:00.000Z"},
+		"Prompt":      &ddbTypes.AttributeValueMemberS{Value: "Ship it?"},
+		"GSI2PK":      &ddbTypes.AttributeValueMemberS{Value: "open"},
+		"VoteWeights": &ddbTypes.AttributeValueMemberM{},
+		"Outcome":     &ddbTypes.AttributeValueMemberM{},
+	}
+
+	expected := []string{"GSI2PK", "Outcome", "Prompt", "VoteWeights"}
+	if attributes := getRemovedAttributes(item); !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("expected everything but the keys, version and deletion time to be removed, got %v", attributes)
+	}
+}
+
+func TestRemoveExpression(t *testing.T) {
+	updateExpression, expressionAttributeNames := removeExpression([]string{"GSI1PK", "Prompt"})
+
+	if updateExpression != "REMOVE #attribute0, #attribute1" {
+		t.Errorf("unexpected update expression %s", updateExpression)
+	}
+
+	expected := map[string]string{"#attribute0": "GSI1PK", "#attribute1": "Prompt"}
+	if !reflect.DeepEqual(expressionAttributeNames, expected) {
+		t.Errorf("expected %v, got %v", expected, expressionAttributeNames)
+	}
+}
+
+func TestNewExpiredDetail(t *testing.T) {
+	removal := func(pk string, principalId string) StreamDetail {
+		var streamDetail StreamDetail
+		streamDetail.EventName = "REMOVE"
+		streamDetail.UserIdentity.Type = "Service"
+		streamDetail.UserIdentity.PrincipalId = principalId
+		streamDetail.DynamoDb.Keys.PK.S = pk
+
+		return streamDetail
+	}
+
+	detail, ok := newExpiredDetail(removal("poll|poll123", "dynamodb.amazonaws.com"))
+	if !ok || detail != (DeletionRequestedDetail{PollId: "poll123", IsExpired: true}) {
+		t.Errorf("expected the poll's expiry, got %+v (%v)", detail, ok)
+	}
+
+	tests := map[string]StreamDetail{
+		"removed by a user": removal("poll|poll123", ""),
+		"option removed":    removal("option|option1", "dynamodb.amazonaws.com"),
+	}
+	for name, streamDetail := range tests {
+		if _, ok := newExpiredDetail(streamDetail); ok {
+			t.Errorf("%s: expected no expiry", name)
+		}
+	}
+}
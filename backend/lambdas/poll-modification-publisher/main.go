@@ -6,57 +6,39 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollchange"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
 )
 
-type DdbPoll struct {
-	PollId struct {
-		S string `json:"S"`
-	} `json:"PK"`
-	UserId struct {
-		S string `json:"S"`
-	} `json:"GSI1PK"`
-	Prompt struct {
-		S string `json:"S"`
-	} `json:"Prompt"`
-	CreatedAt struct {
-		S string `json:"S"`
-	} `json:"CreatedAt"`
-	Duration struct {
-		N string `json:"N"`
-	} `json:"Duration"`
-	IsArchived struct {
-		BOOL bool `json:"BOOL"`
-	} `json:"IsArchived"`
+type DdbCurrentPoll struct {
+	Prompt     string `dynamodbav:"Prompt"`
+	Duration   string `dynamodbav:"Duration"`
+	IsArchived bool   `dynamodbav:"IsArchived"`
 }
 
 type PollModifiedDetail struct {
-	DynamoDb struct {
-		NewImage DdbPoll `json:"NewImage"`
-		OldImage DdbPoll `json:"OldImage"`
+	EventName string `json:"eventName"`
+	DynamoDb  struct {
+		Keys struct {
+			PK struct {
+				S string `json:"S"`
+			} `json:"PK"`
+		} `json:"Keys"`
+		NewImage pollchange.Image `json:"NewImage"`
+		OldImage pollchange.Image `json:"OldImage"`
 	} `json:"dynamodb"`
 }
 
-type Payload struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
-}
-
-type PollModifiedPayloadData struct {
-	PollId     string `json:"pollId"`
-	UserId     string `json:"userId"`
-	Prompt     string `json:"prompt"`
-	CreatedAt  string `json:"createdAt"`
-	Duration   int64  `json:"duration"`
-	IsArchived bool   `json:"isArchived"`
-}
-
 func stripPrefix(s string, prefix string) string {
 	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
 		return s[len(prefix):]
@@ -65,6 +47,80 @@ func stripPrefix(s string, prefix string) string {
 	return s
 }
 
+// isSuperseded reports whether the poll has been modified again since the new image was written.
+// Events are delivered out of order, and the newer modification is published by its own event.
+func isSuperseded(ctx context.Context, ddb *dynamodb.Client, pollModifiedDetail PollModifiedDetail) (bool, error) {
+	newImage := pollModifiedDetail.DynamoDb.NewImage
+
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: newImage.PollId.S,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: newImage.PollId.S,
+			},
+		},
+		ProjectionExpression: aws.String("#prompt, #duration, #isArchived"),
+		ExpressionAttributeNames: map[string]string{
+			"#prompt":     "Prompt",
+			"#duration":   "Duration",
+			"#isArchived": "IsArchived",
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var current DdbCurrentPoll
+	if err := attributevalue.UnmarshalMap(result.Item, &current); err != nil {
+		return false, err
+	}
+
+	return current != DdbCurrentPoll{
+		Prompt:     newImage.Prompt.S,
+		Duration:   newImage.Duration.N,
+		IsArchived: newImage.IsArchived.BOOL,
+	}, nil
+}
+
+// newPayload builds the message for a change, ordered by the poll's version after the modification
+func newPayload(version int64, change pollchange.Change) ([]byte, error) {
+	envelope, err := protocol.NewEnvelope(change.Type, version, change.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope)
+}
+
+// getChanges returns the changes of the modification or removal, and the poll's version after it. A poll
+// is only removed by its time to live, which follows its last modification.
+func getChanges(pollModifiedDetail PollModifiedDetail, modifiedAt time.Time) ([]pollchange.Change, int64, error) {
+	if pollModifiedDetail.EventName == "REMOVE" {
+		version, err := pollchange.Version(pollModifiedDetail.DynamoDb.OldImage)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return pollchange.Expired(pollModifiedDetail.DynamoDb.OldImage, modifiedAt), version + 1, nil
+	}
+
+	changes, err := pollchange.Diff(pollModifiedDetail.DynamoDb.NewImage, pollModifiedDetail.DynamoDb.OldImage, modifiedAt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	version, err := pollchange.Version(pollModifiedDetail.DynamoDb.NewImage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return changes, version, nil
+}
+
 func handler(ctx context.Context, event events.CloudWatchEvent) {
 	log.Printf("Processing event: %s\n", event)
 
@@ -79,6 +135,7 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		return
 	}
 
+	ddb := dynamodb.NewFromConfig(cfg)
 	iot := iotdataplane.NewFromConfig(cfg)
 
 	var pollModifiedDetail PollModifiedDetail
@@ -86,42 +143,57 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		log.Printf("Error: %s\n", err)
 		return
 	}
-	log.Printf("Poll modified: %v\n", pollModifiedDetail.DynamoDb.NewImage.PollId)
+	log.Printf("Poll modified: %v\n", pollModifiedDetail.DynamoDb.Keys.PK.S)
+
+	pollId := stripPrefix(pollModifiedDetail.DynamoDb.Keys.PK.S, "poll|")
 
-	duration, err := strconv.ParseInt(pollModifiedDetail.DynamoDb.NewImage.Duration.N, 10, 64)
+	// The event is put on the bus after the modification was written, so a poll whose new expiry
+	// is no later than the event's time was closed by the modification
+	changes, version, err := getChanges(pollModifiedDetail, event.Time)
 	if err != nil {
 		log.Printf("Error: %s\n", err)
 		return
 	}
-
-	pollId := stripPrefix(pollModifiedDetail.DynamoDb.NewImage.PollId.S, "poll|")
-
-	payload, err := json.Marshal(Payload{
-		Type: "pollModified",
-		Data: PollModifiedPayloadData{
-			PollId:     pollId,
-			UserId:     stripPrefix(pollModifiedDetail.DynamoDb.NewImage.UserId.S, "user|"),
-			Prompt:     pollModifiedDetail.DynamoDb.NewImage.Prompt.S,
-			CreatedAt:  pollModifiedDetail.DynamoDb.NewImage.CreatedAt.S,
-			Duration:   duration,
-			IsArchived: pollModifiedDetail.DynamoDb.NewImage.IsArchived.BOOL,
-		},
-	})
-	if err != nil {
-		log.Printf("Error: %s\n", err)
+	if len(changes) == 0 {
+		log.Printf("Skipping modification of poll %s with no user-visible changes\n", pollId)
 		return
 	}
 
-	_, err = iot.Publish(ctx, &iotdataplane.PublishInput{
-		Topic:       aws.String(fmt.Sprintf("poll/%s", pollId)),
-		ContentType: aws.String("application/json"),
-		Payload:     payload,
-	})
-	if err != nil {
-		log.Printf("Error: %s\n", err)
-		return
+	// A deletion or expiry is final, so it can't be superseded even though the deleted poll is emptied afterwards
+	isFinal := pollModifiedDetail.EventName == "REMOVE" || pollModifiedDetail.DynamoDb.NewImage.DeletedAt.S != ""
+	if !isFinal {
+		superseded, err := isSuperseded(ctx, ddb, pollModifiedDetail)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+		if superseded {
+			log.Printf("Dropping stale modification of poll %s\n", pollId)
+			return
+		}
 	}
 
+	for _, change := range changes {
+		payload, err := newPayload(version, change)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		// The deletion or expiry replaces the poll's retained snapshot, so later subscribers learn the poll is gone
+		_, err = iot.Publish(ctx, &iotdataplane.PublishInput{
+			Topic:       aws.String(fmt.Sprintf("poll/%s", pollId)),
+			ContentType: aws.String("application/json"),
+			Payload:     payload,
+			Retain:      change.Type == protocol.TypePollDeleted || change.Type == protocol.TypePollExpired,
+		})
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		log.Printf("Published %s for poll %s\n", change.Type, pollId)
+	}
 }
 
 func main() {
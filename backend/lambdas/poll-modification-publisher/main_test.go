@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol/protocoltest"
+)
+
+const pollModifiedDetail = `{
+	"dynamodb": {
+		"NewImage": {
+			"PK": {"S": "poll|poll123"},
+			"SK": {"S": "poll|poll123"},
+			"GSI1PK": {"S": "user|user123"},
+			"GSI1SK": {"S": "user|user123"},
+			"Prompt": {"S": "Ship it?"},
+			"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Duration": {"N": "3600"},
+			"IsArchived": {"BOOL": true},
+			"Version": {"N": "8"}
+		},
+		"OldImage": {
+			"PK": {"S": "poll|poll123"},
+			"SK": {"S": "poll|poll123"},
+			"GSI1PK": {"S": "user|user123"},
+			"GSI1SK": {"S": "user|user123"},
+			"Prompt": {"S": "Ship it?"},
+			"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Duration": {"N": "3600"},
+			"IsArchived": {"BOOL": false},
+			"Version": {"N": "7"}
+		}
+	}
+}`
+
+var modifiedAt = time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+func TestNewPayload(t *testing.T) {
+	var detail PollModifiedDetail
+	if err := json.Unmarshal([]byte(pollModifiedDetail), &detail); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, version, err := getChanges(detail, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(changes))
+	}
+
+	payload, err := newPayload(version, changes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+
+	var envelope protocol.Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Type != protocol.TypePollArchived {
+		t.Errorf("expected type %s, got %s", protocol.TypePollArchived, envelope.Type)
+	}
+
+	if envelope.Sequence != 8 {
+		t.Errorf("expected sequence 8, got %d", envelope.Sequence)
+	}
+}
+
+func TestGetChangesExpired(t *testing.T) {
+	var detail PollModifiedDetail
+	if err := json.Unmarshal([]byte(pollModifiedDetail), &detail); err != nil {
+		t.Fatal(err)
+	}
+	detail.EventName = "REMOVE"
+
+	changes, version, err := getChanges(detail, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || changes[0].Type != protocol.TypePollExpired {
+		t.Fatalf("expected a single %s change, got %+v", protocol.TypePollExpired, changes)
+	}
+
+	if version != 8 {
+		t.Errorf("expected the expiry to follow version 7, got %d", version)
+	}
+
+	payload, err := newPayload(version, changes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkPollId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Gsi1SkUserId string `dynamodbav:"GSI1SK"`
+	Prompt       string `dynamodbav:"Prompt"`
+	CreatedAt    string `dynamodbav:"CreatedAt"`
+	Duration     int64  `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+
+	AllowWriteIns bool `dynamodbav:"AllowWriteIns"`
+
+	Quorum    int                 `dynamodbav:"Quorum"`
+	Threshold *decision.Threshold `dynamodbav:"Threshold"`
+	ClosedAt  string              `dynamodbav:"ClosedAt"`
+	Outcome   *decision.Outcome   `dynamodbav:"Outcome"`
+
+	TieBreak         string `dynamodbav:"TieBreak"`
+	TieBreakOptionId string `dynamodbav:"TieBreakOptionId"`
+}
+
+type DdbOption struct {
+	PkOptionId   string `dynamodbav:"PK"`
+	SkOptionId   string `dynamodbav:"SK"`
+	Gsi1PkPollId string `dynamodbav:"GSI1PK"`
+	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
+	Index        int    `dynamodbav:"Index"`
+	Text         string `dynamodbav:"Text"`
+	Description  string `dynamodbav:"Description"`
+	Url          string `dynamodbav:"Url"`
+	ImageUrl     string `dynamodbav:"ImageUrl"`
+	UpdatedAt    string `dynamodbav:"UpdatedAt"`
+	Votes        int64  `dynamodbav:"Votes"`
+	Ballots      *int64 `dynamodbav:"Ballots"`
+	Version      int64  `dynamodbav:"Version"`
+
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+type SnapshotRequestedDetail struct {
+	PollId string `json:"pollId"`
+}
+
+const (
+	RFC3339Milli              = "2006-01-02T15:04:05.999Z07:00"
+	ScheduledEventSource      = "aws.events"
+	ScheduledEventDetailType  = "Scheduled Event"
+	MaxConcurrentPublications = 10
+)
+
+var (
+	errPollNotFound = errors.New("poll not found")
+	errPollDeleted  = errors.New("poll deleted")
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+// getExpiresAt returns when the poll stops accepting votes
+func getExpiresAt(ddbPoll DdbPoll) (time.Time, error) {
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second), nil
+}
+
+// isClosing reports whether the poll expired and hasn't been closed yet, however long ago it expired, so
+// polls missed while the publisher wasn't running are still closed and announced
+func isClosing(ddbPoll DdbPoll, now time.Time) (bool, error) {
+	if ddbPoll.IsArchived || ddbPoll.ClosedAt != "" {
+		return false, nil
+	}
+
+	expiresAt, err := getExpiresAt(ddbPoll)
+	if err != nil {
+		return false, err
+	}
+
+	return !now.Before(expiresAt), nil
+}
+
+// DdbOpenPoll is a poll in the index of open polls, which only projects its keys
+type DdbOpenPoll struct {
+	PkPollId        string `dynamodbav:"PK"`
+	Gsi2SkExpiresAt int64  `dynamodbav:"GSI2SK"`
+}
+
+// splitOpenPolls splits the open polls into those that can still be voted on and those that expired
+func splitOpenPolls(ddbOpenPolls []DdbOpenPoll, now time.Time) ([]string, []string) {
+	var activePollIds, closingPollIds []string
+	for _, ddbOpenPoll := range ddbOpenPolls {
+		pollId := stripPrefix(ddbOpenPoll.PkPollId, "poll|")
+		if ddbOpenPoll.Gsi2SkExpiresAt > openpolls.SortKey(now) {
+			activePollIds = append(activePollIds, pollId)
+		} else {
+			closingPollIds = append(closingPollIds, pollId)
+		}
+	}
+
+	return activePollIds, closingPollIds
+}
+
+// getPollIds queries the index of open polls for the polls that can still be voted on, and for the polls
+// that expired and are yet to be closed. Archived, closed and deleted polls aren't in the index.
+func getPollIds(ctx context.Context, ddb *dynamodb.Client, now time.Time) ([]string, []string, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String(openpolls.IndexName),
+		KeyConditionExpression: aws.String("#open = :open"),
+		ExpressionAttributeNames: map[string]string{
+			"#open": "GSI2PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":open": &types.AttributeValueMemberS{
+				Value: openpolls.Key,
+			},
+		},
+	})
+
+	var activePollIds, closingPollIds []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var ddbOpenPolls []DdbOpenPoll
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &ddbOpenPolls); err != nil {
+			return nil, nil, err
+		}
+
+		active, closing := splitOpenPolls(ddbOpenPolls, now)
+		activePollIds = append(activePollIds, active...)
+		closingPollIds = append(closingPollIds, closing...)
+	}
+
+	return activePollIds, closingPollIds, nil
+}
+
+// getPoll reads the poll and the last sequence number handed out for it before its options, so the options
+// are at least as recent as the sequence the snapshot is taken at
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (DdbPoll, []DdbOption, int64, error) {
+	pollResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return DdbPoll{}, nil, 0, err
+	}
+	if pollResult.Item == nil {
+		return DdbPoll{}, nil, 0, errPollNotFound
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(pollResult.Item, &ddbPoll); err != nil {
+		return DdbPoll{}, nil, 0, err
+	}
+
+	// The deleted poll's topic retains its pollDeleted message instead
+	if ddbPoll.DeletedAt != "" {
+		return DdbPoll{}, nil, 0, errPollDeleted
+	}
+
+	sequence, err := pollsequence.Current(ctx, ddb, os.Getenv("SINGLE_TABLE_NAME"), pollId, ddbPoll.Version)
+	if err != nil {
+		return DdbPoll{}, nil, 0, err
+	}
+
+	optionsResult, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	if err != nil {
+		return DdbPoll{}, nil, 0, err
+	}
+
+	var ddbOptions []DdbOption
+	if err := attributevalue.UnmarshalListOfMaps(optionsResult.Items, &ddbOptions); err != nil {
+		return DdbPoll{}, nil, 0, err
+	}
+
+	return ddbPoll, ddbOptions, sequence, nil
+}
+
+func sortOptions(ddbOptions []DdbOption) {
+	sort.Slice(ddbOptions, func(i, j int) bool {
+		return ddbOptions[i].Index < ddbOptions[j].Index
+	})
+}
+
+func newSnapshot(ddbPoll DdbPoll, ddbOptions []DdbOption, sequence int64) protocol.PollSnapshotData {
+	sortOptions(ddbOptions)
+
+	options := make([]protocol.OptionSnapshot, 0, len(ddbOptions))
+	for _, ddbOption := range ddbOptions {
+		if !pollcreate.IsShown(ddbOption.WriteInStatus) {
+			continue
+		}
+
+		options = append(options, protocol.OptionSnapshot{
+			OptionId:    stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:        ddbOption.Text,
+			Description: ddbOption.Description,
+			Url:         ddbOption.Url,
+			ImageUrl:    ddbOption.ImageUrl,
+			UpdatedAt:   ddbOption.UpdatedAt,
+			Votes:       ddbOption.Votes,
+			Sequence:    ddbOption.Version,
+		})
+	}
+
+	snapshot := protocol.PollSnapshotData{
+		PollId:     stripPrefix(ddbPoll.PkPollId, "poll|"),
+		UserId:     stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
+		Prompt:     ddbPoll.Prompt,
+		Options:    options,
+		CreatedAt:  ddbPoll.CreatedAt,
+		Duration:   ddbPoll.Duration,
+		IsArchived: ddbPoll.IsArchived,
+		Sequence:   sequence,
+
+		AllowWriteIns: ddbPoll.AllowWriteIns,
+	}
+
+	if outcome := ddbPoll.Outcome; outcome != nil {
+		snapshot.Outcome = &protocol.Outcome{
+			Result:        outcome.Result,
+			Reason:        outcome.Reason,
+			OptionId:      outcome.OptionId,
+			TiedOptionIds: outcome.TiedOptionIds,
+			Ballots:       outcome.Ballots,
+			Votes:         outcome.Votes,
+		}
+	}
+
+	return snapshot
+}
+
+// getBallots returns how many votes the option got, counting each once. Options whose votes were counted
+// before votes were weighed don't have ballots, but each of their votes counted once.
+func getBallots(ddbOption DdbOption) int64 {
+	if ddbOption.Ballots == nil {
+		return ddbOption.Votes
+	}
+
+	return *ddbOption.Ballots
+}
+
+// newClose decides the outcome of the expired poll from the options shown to voters, and returns the poll
+// as closed along with its update, which takes it out of the index of open polls. The update moves the poll
+// to the sequence number taken for closing it, so the closed poll's snapshot is newer than any published
+// while it was open, and fails if the poll changed since it was read.
+func newClose(
+	ddbPoll DdbPoll,
+	ddbOptions []DdbOption,
+	sequence int64,
+	closedAt string,
+) (DdbPoll, *dynamodb.UpdateItemInput, error) {
+	sortOptions(ddbOptions)
+
+	var tallies []decision.Tally
+	for _, ddbOption := range ddbOptions {
+		if !pollcreate.IsShown(ddbOption.WriteInStatus) {
+			continue
+		}
+
+		reachedAt, err := time.Parse(RFC3339Milli, ddbOption.UpdatedAt)
+		if err != nil {
+			return DdbPoll{}, nil, err
+		}
+
+		tallies = append(tallies, decision.Tally{
+			OptionId:  stripPrefix(ddbOption.PkOptionId, "option|"),
+			Votes:     ddbOption.Votes,
+			Ballots:   getBallots(ddbOption),
+			ReachedAt: reachedAt,
+		})
+	}
+
+	outcome := decision.Decide(decision.Rules{
+		Quorum:           ddbPoll.Quorum,
+		Threshold:        ddbPoll.Threshold,
+		TieBreak:         ddbPoll.TieBreak,
+		TieBreakOptionId: ddbPoll.TieBreakOptionId,
+	}, tallies)
+
+	outcomeValue, err := attributevalue.Marshal(outcome)
+	if err != nil {
+		return DdbPoll{}, nil, err
+	}
+
+	closedPoll := ddbPoll
+	closedPoll.ClosedAt = closedAt
+	closedPoll.Outcome = &outcome
+	closedPoll.Version = sequence
+
+	return closedPoll, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+			"SK": &types.AttributeValueMemberS{Value: ddbPoll.SkPollId},
+		},
+		ConditionExpression: aws.String(
+			"attribute_exists(#pk) AND attribute_not_exists(#closedAt) AND attribute_not_exists(#deletedAt) AND (attribute_not_exists(#version) OR #version = :currentVersion)",
+		),
+		UpdateExpression: aws.String(
+			"SET #closedAt = :closedAt, #outcome = :outcome, #version = :version REMOVE #open, #expiresAt",
+		),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":        "PK",
+			"#open":      "GSI2PK",
+			"#expiresAt": "GSI2SK",
+			"#closedAt":  "ClosedAt",
+			"#outcome":   "Outcome",
+			"#deletedAt": "DeletedAt",
+			"#version":   "Version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":closedAt":       &types.AttributeValueMemberS{Value: closedAt},
+			":outcome":        outcomeValue,
+			":currentVersion": &types.AttributeValueMemberN{Value: strconv.FormatInt(ddbPoll.Version, 10)},
+			":version":        &types.AttributeValueMemberN{Value: strconv.FormatInt(closedPoll.Version, 10)},
+		},
+	}, nil
+}
+
+// newPayload builds the pollSnapshot message for the snapshot
+func newPayload(snapshot protocol.PollSnapshotData) ([]byte, error) {
+	envelope, err := protocol.NewEnvelope(protocol.TypePollSnapshot, snapshot.Sequence, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope)
+}
+
+// publish publishes the snapshot as the retained message of the poll's topic, replacing the previous one
+// so that new subscribers start from the latest state
+func publish(ctx context.Context, iot *iotdataplane.Client, snapshot protocol.PollSnapshotData) error {
+	payload, err := newPayload(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = iot.Publish(ctx, &iotdataplane.PublishInput{
+		Topic:       aws.String(fmt.Sprintf("poll/%s", snapshot.PollId)),
+		ContentType: aws.String("application/json"),
+		Payload:     payload,
+		Retain:      true,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Published snapshot of poll %s at sequence %d\n", snapshot.PollId, snapshot.Sequence)
+
+	return nil
+}
+
+func publishSnapshot(ctx context.Context, ddb *dynamodb.Client, iot *iotdataplane.Client, pollId string) error {
+	ddbPoll, ddbOptions, sequence, err := getPoll(ctx, ddb, pollId)
+	if err != nil {
+		return err
+	}
+
+	return publish(ctx, iot, newSnapshot(ddbPoll, ddbOptions, sequence))
+}
+
+// closePoll decides the outcome of the expired poll and publishes its final snapshot. Closing the poll
+// is announced as pollClosed by its modification. A poll that was extended, archived or modified since
+// it was queried is left for a later run.
+func closePoll(ctx context.Context, ddb *dynamodb.Client, iot *iotdataplane.Client, pollId string, now time.Time) error {
+	ddbPoll, ddbOptions, _, err := getPoll(ctx, ddb, pollId)
+	if err != nil {
+		return err
+	}
+
+	closing, err := isClosing(ddbPoll, now)
+	if err != nil {
+		return err
+	}
+	if !closing {
+		return nil
+	}
+
+	expiresAt, err := getExpiresAt(ddbPoll)
+	if err != nil {
+		return err
+	}
+
+	sequence, err := pollsequence.Next(ctx, ddb, os.Getenv("SINGLE_TABLE_NAME"), pollId, ddbPoll.Version)
+	if err != nil {
+		return err
+	}
+
+	closedPoll, input, err := newClose(ddbPoll, ddbOptions, sequence, expiresAt.UTC().Format(RFC3339Milli))
+	if err != nil {
+		return err
+	}
+
+	if _, err := ddb.UpdateItem(ctx, input); err != nil {
+		var conditionalCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalCheckFailed) {
+			log.Printf("Poll %s changed while it was being closed\n", pollId)
+			return nil
+		}
+
+		return err
+	}
+
+	log.Printf("Closed poll %s: %s\n", pollId, closedPoll.Outcome.Result)
+
+	return publish(ctx, iot, newSnapshot(closedPoll, ddbOptions, sequence))
+}
+
+// publishActiveSnapshots refreshes the retained snapshots of every active poll and closes the polls that
+// expired, a few at a time
+func publishActiveSnapshots(ctx context.Context, ddb *dynamodb.Client, iot *iotdataplane.Client) {
+	now := time.Now()
+
+	activePollIds, closingPollIds, err := getPollIds(ctx, ddb, now)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	log.Printf("Publishing snapshots of %d active polls and closing %d polls\n", len(activePollIds), len(closingPollIds))
+
+	semaphore := make(chan struct{}, MaxConcurrentPublications)
+	done := make(chan struct{})
+	run := func(publish func() error) {
+		semaphore <- struct{}{}
+		defer func() {
+			<-semaphore
+			done <- struct{}{}
+		}()
+
+		if err := publish(); err != nil {
+			log.Printf("Error: %s\n", err)
+		}
+	}
+
+	for _, pollId := range activePollIds {
+		go func(pollId string) {
+			run(func() error { return publishSnapshot(ctx, ddb, iot, pollId) })
+		}(pollId)
+	}
+	for _, pollId := range closingPollIds {
+		go func(pollId string) {
+			run(func() error { return closePoll(ctx, ddb, iot, pollId, now) })
+		}(pollId)
+	}
+
+	for i := 0; i < len(activePollIds)+len(closingPollIds); i++ {
+		<-done
+	}
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) {
+	log.Printf("Processing event: %s\n", event)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+	iot := iotdataplane.NewFromConfig(cfg)
+
+	if event.Source == ScheduledEventSource && event.DetailType == ScheduledEventDetailType {
+		publishActiveSnapshots(ctx, ddb, iot)
+		return
+	}
+
+	if event.Source == os.Getenv("SNAPSHOT_REQUESTED_SOURCE") && event.DetailType == os.Getenv("SNAPSHOT_REQUESTED_DETAIL_TYPE") {
+		var snapshotRequestedDetail SnapshotRequestedDetail
+		if err := json.Unmarshal(event.Detail, &snapshotRequestedDetail); err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		if err := publishSnapshot(ctx, ddb, iot, snapshotRequestedDetail.PollId); err != nil {
+			log.Printf("Error: %s\n", err)
+		}
+
+		return
+	}
+
+	log.Printf("Unknown event source or detail type: %s, %s\n", event.Source, event.DetailType)
+}
+
+func main() {
+	lambda.Start(handler)
+}
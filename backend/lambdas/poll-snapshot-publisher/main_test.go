@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol/protocoltest"
+)
+
+func TestNewPayload(t *testing.T) {
+	snapshot := newSnapshot(DdbPoll{
+		PkPollId:     "poll|poll123",
+		SkPollId:     "poll|poll123",
+		Gsi1PkUserId: "user|user123",
+		Gsi1SkUserId: "user|user123",
+		Prompt:       "Ship it?",
+		CreatedAt:    "2024-01-01T00:00:00.000Z",
+		Duration:     3600,
+		Version:      9,
+	}, []DdbOption{
+		{PkOptionId: "option|option2", Index: 1, Text: "No", UpdatedAt: "2024-01-01T00:01:00.000Z", Votes: 2, Version: 4},
+		{PkOptionId: "option|option1", Index: 0, Text: "Yes", UpdatedAt: "2024-01-01T00:02:00.000Z", Votes: 5, Version: 9},
+	}, 9)
+
+	if snapshot.Options[0].OptionId != "option1" {
+		t.Errorf("expected options ordered by index, got %s first", snapshot.Options[0].OptionId)
+	}
+
+	payload, err := newPayload(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+
+	var envelope protocol.Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Type != protocol.TypePollSnapshot {
+		t.Errorf("expected type %s, got %s", protocol.TypePollSnapshot, envelope.Type)
+	}
+
+	if envelope.Sequence != 9 {
+		t.Errorf("expected sequence 9, got %d", envelope.Sequence)
+	}
+}
+
+func TestSplitOpenPolls(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+	activePollIds, closingPollIds := splitOpenPolls([]DdbOpenPoll{
+		{PkPollId: "poll|open", Gsi2SkExpiresAt: openpolls.SortKey(now.Add(time.Minute))},
+		{PkPollId: "poll|expired", Gsi2SkExpiresAt: openpolls.SortKey(now.Add(-time.Minute))},
+		{PkPollId: "poll|expiring", Gsi2SkExpiresAt: openpolls.SortKey(now)},
+	}, now)
+
+	if !reflect.DeepEqual(activePollIds, []string{"open"}) {
+		t.Errorf("expected only the open poll to be active, got %v", activePollIds)
+	}
+	if !reflect.DeepEqual(closingPollIds, []string{"expired", "expiring"}) {
+		t.Errorf("expected the expired polls to be closed, got %v", closingPollIds)
+	}
+}
+
+func TestIsClosing(t *testing.T) {
+	now := time.Date(2024, 1, 1, 1, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		poll     DdbPoll
+		expected bool
+	}{
+		{"open", DdbPoll{CreatedAt: "2024-01-01T00:00:00.000Z", Duration: 7200}, false},
+		{"expired", DdbPoll{CreatedAt: "2024-01-01T00:00:00.000Z", Duration: 3600}, true},
+		{"closed", DdbPoll{CreatedAt: "2024-01-01T00:00:00.000Z", Duration: 3600, ClosedAt: "2024-01-01T01:00:00.000Z"}, false},
+		{"archived", DdbPoll{CreatedAt: "2024-01-01T00:00:00.000Z", Duration: 3600, IsArchived: true}, false},
+		{"expired long ago", DdbPoll{CreatedAt: "2023-12-01T00:00:00.000Z", Duration: 3600}, true},
+	}
+
+	for _, test := range tests {
+		closing, err := isClosing(test.poll, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if closing != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.name, test.expected, closing)
+		}
+	}
+}
+
+func TestNewClose(t *testing.T) {
+	ballots := int64(3)
+	ddbPoll := DdbPoll{
+		PkPollId:  "poll|poll123",
+		SkPollId:  "poll|poll123",
+		CreatedAt: "2024-01-01T00:00:00.000Z",
+		Duration:  3600,
+		Version:   9,
+		Quorum:    5,
+		Threshold: &decision.Threshold{Percentage: 60},
+	}
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option2", Index: 1, Text: "No", UpdatedAt: "2024-01-01T00:10:00.000Z", Votes: 2},
+		{PkOptionId: "option|option1", Index: 0, Text: "Yes", UpdatedAt: "2024-01-01T00:20:00.000Z", Votes: 6, Ballots: &ballots},
+		{
+			PkOptionId:    "option|option3",
+			Index:         2,
+			Text:          "Later",
+			UpdatedAt:     "2024-01-01T00:30:00.000Z",
+			Votes:         9,
+			WriteInStatus: pollcreate.WriteInStatusPending,
+		},
+	}
+
+	closedPoll, input, err := newClose(ddbPoll, ddbOptions, 10, "2024-01-01T01:00:00.000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pending write-in isn't shown, so its votes don't count
+	expected := decision.Outcome{Result: decision.ResultPassed, OptionId: "option1", Ballots: 5, Votes: 8}
+	if !reflect.DeepEqual(*closedPoll.Outcome, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *closedPoll.Outcome)
+	}
+	if closedPoll.Version != 10 || closedPoll.ClosedAt != "2024-01-01T01:00:00.000Z" {
+		t.Errorf("expected the poll closed at version 10, got %+v", closedPoll)
+	}
+	if version := input.ExpressionAttributeValues[":currentVersion"].(*types.AttributeValueMemberN).Value; version != "9" {
+		t.Errorf("expected the close to be conditioned on version 9, got %s", version)
+	}
+	if !strings.HasSuffix(*input.UpdateExpression, "REMOVE #open, #expiresAt") {
+		t.Errorf("expected the closed poll to be taken out of the open polls, got %s", *input.UpdateExpression)
+	}
+
+	payload, err := newPayload(newSnapshot(closedPoll, ddbOptions, closedPoll.Version))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+}
+
+func TestNewCloseBreaksTie(t *testing.T) {
+	ddbPoll := DdbPoll{
+		PkPollId:  "poll|poll123",
+		SkPollId:  "poll|poll123",
+		CreatedAt: "2024-01-01T00:00:00.000Z",
+		Duration:  3600,
+		TieBreak:  decision.TieBreakEarliest,
+	}
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option1", Index: 0, Text: "Yes", UpdatedAt: "2024-01-01T00:20:00.000Z", Votes: 4},
+		{PkOptionId: "option|option2", Index: 1, Text: "No", UpdatedAt: "2024-01-01T00:10:00.000Z", Votes: 4},
+	}
+
+	closedPoll, _, err := newClose(ddbPoll, ddbOptions, 1, "2024-01-01T01:00:00.000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The option that reached the lead first wins it
+	expected := decision.Outcome{
+		Result:        decision.ResultPassed,
+		OptionId:      "option2",
+		TiedOptionIds: []string{"option1", "option2"},
+		Ballots:       8,
+		Votes:         8,
+	}
+	if !reflect.DeepEqual(*closedPoll.Outcome, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *closedPoll.Outcome)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+func TestCanRemove(t *testing.T) {
+	tests := map[string]struct {
+		role     string
+		memberId string
+		expected bool
+	}{
+		"owner removes a member":    {role: pollaccess.RoleOwner, memberId: "user2", expected: true},
+		"co-owner removes a member": {role: pollaccess.RoleCoOwner, memberId: "user2"},
+		"co-owner leaves":           {role: pollaccess.RoleCoOwner, memberId: "user1", expected: true},
+		"viewer leaves":             {role: pollaccess.RoleViewer, memberId: "user1", expected: true},
+		"not member":                {role: "", memberId: "user1"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if can := canRemove(test.role, "user1", test.memberId); can != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, can)
+			}
+		})
+	}
+}
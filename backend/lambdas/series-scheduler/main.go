@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/recurrence"
+)
+
+type DdbSeries struct {
+	PkSeriesId   string   `dynamodbav:"PK"`
+	Gsi1PkUserId string   `dynamodbav:"GSI1PK"`
+	Schedule     string   `dynamodbav:"Schedule"`
+	TimeZone     string   `dynamodbav:"TimeZone"`
+	Prompt       string   `dynamodbav:"Prompt"`
+	Options      []string `dynamodbav:"Options"`
+	Duration     int      `dynamodbav:"Duration"`
+	NextRunAt    int64    `dynamodbav:"NextRunAt"`
+}
+
+// DdbOccurrence links a poll to the run of the series that created it. It's keyed by the run, so a run
+// can only be materialised once.
+type DdbOccurrence struct {
+	PkSeriesId     string `dynamodbav:"PK"`
+	SkOccurrenceAt string `dynamodbav:"SK"`
+	PollId         string `dynamodbav:"PollId"`
+	RunAt          string `dynamodbav:"RunAt"`
+	CreatedAt      string `dynamodbav:"CreatedAt"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+	return s
+}
+
+// newDueSeriesQuery queries the index of series by status for the active series whose next run is due. Only
+// series have both a Status and a NextRunAt, so nothing else is in the index.
+func newDueSeriesQuery(tableName string, now time.Time) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String(recurrence.DueIndexName),
+		KeyConditionExpression: aws.String("#status = :active AND #nextRunAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "Status",
+			"#nextRunAt": "NextRunAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":active": &types.AttributeValueMemberS{
+				Value: recurrence.StatusActive,
+			},
+			":now": &types.AttributeValueMemberN{
+				Value: fmt.Sprint(now.Unix()),
+			},
+		},
+	}
+}
+
+func getDueSeries(ctx context.Context, ddb *dynamodb.Client, now time.Time) ([]DdbSeries, error) {
+	paginator := dynamodb.NewQueryPaginator(ddb, newDueSeriesQuery(os.Getenv("SINGLE_TABLE_NAME"), now))
+
+	var dueSeries []DdbSeries
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var ddbSeries []DdbSeries
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &ddbSeries); err != nil {
+			return nil, err
+		}
+
+		dueSeries = append(dueSeries, ddbSeries...)
+	}
+
+	return dueSeries, nil
+}
+
+// newRunItems creates the series' next poll, records it as an occurrence and schedules the run after
+// now, so runs missed while the scheduler was behind are skipped rather than created all at once. A
+// series whose schedule never runs again is stopped.
+func newRunItems(
+	tableName string,
+	nanoIdOptions pollcreate.NanoIdOptions,
+	ddbSeries DdbSeries,
+	now time.Time,
+) (pollcreate.Poll, []types.TransactWriteItem, error) {
+	seriesId := stripPrefix(ddbSeries.PkSeriesId, "series|")
+
+	poll, transactItems, err := pollcreate.Prepare(
+		tableName,
+		nanoIdOptions,
+		pollcreate.Input{
+			UserId:   stripPrefix(ddbSeries.Gsi1PkUserId, "user|"),
+			Prompt:   ddbSeries.Prompt,
+			Options:  ddbSeries.Options,
+			Duration: ddbSeries.Duration,
+			SeriesId: seriesId,
+		},
+		now,
+	)
+	if err != nil {
+		return pollcreate.Poll{}, nil, err
+	}
+
+	nextRunAt, err := recurrence.Schedule{
+		Expression: ddbSeries.Schedule,
+		TimeZone:   ddbSeries.TimeZone,
+	}.Next(now)
+	if err != nil {
+		return pollcreate.Poll{}, nil, err
+	}
+
+	status := recurrence.StatusActive
+	if nextRunAt.IsZero() {
+		status = recurrence.StatusStopped
+		nextRunAt = time.Unix(ddbSeries.NextRunAt, 0)
+	}
+
+	currentTime := now.UTC().Format(RFC3339Milli)
+	runAt := time.Unix(ddbSeries.NextRunAt, 0).UTC().Format(RFC3339Milli)
+
+	occurrence, err := attributevalue.MarshalMap(DdbOccurrence{
+		PkSeriesId:     ddbSeries.PkSeriesId,
+		SkOccurrenceAt: fmt.Sprintf("occurrence|%s", runAt),
+		PollId:         poll.PollId,
+		RunAt:          runAt,
+		CreatedAt:      currentTime,
+	})
+	if err != nil {
+		return pollcreate.Poll{}, nil, err
+	}
+
+	transactItems = append(
+		transactItems,
+		types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:           aws.String(tableName),
+				Item:                occurrence,
+				ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+				ExpressionAttributeNames: map[string]string{
+					"#pk": "PK",
+				},
+			},
+		},
+		types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(tableName),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{
+						Value: ddbSeries.PkSeriesId,
+					},
+					"SK": &types.AttributeValueMemberS{
+						Value: ddbSeries.PkSeriesId,
+					},
+				},
+				UpdateExpression:    aws.String("SET #status = :status, #nextRunAt = :nextRunAt, #updatedAt = :updatedAt"),
+				ConditionExpression: aws.String("#status = :active AND #nextRunAt = :runAt"),
+				ExpressionAttributeNames: map[string]string{
+					"#status":    "Status",
+					"#nextRunAt": "NextRunAt",
+					"#updatedAt": "UpdatedAt",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":status": &types.AttributeValueMemberS{
+						Value: status,
+					},
+					":nextRunAt": &types.AttributeValueMemberN{
+						Value: fmt.Sprint(nextRunAt.Unix()),
+					},
+					":updatedAt": &types.AttributeValueMemberS{
+						Value: currentTime,
+					},
+					":active": &types.AttributeValueMemberS{
+						Value: recurrence.StatusActive,
+					},
+					":runAt": &types.AttributeValueMemberN{
+						Value: fmt.Sprint(ddbSeries.NextRunAt),
+					},
+				},
+			},
+		},
+	)
+
+	return poll, transactItems, nil
+}
+
+func runDueSeries(ctx context.Context, ddb *dynamodb.Client, nanoIdOptions pollcreate.NanoIdOptions, now time.Time) {
+	dueSeries, err := getDueSeries(ctx, ddb, now)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	for _, ddbSeries := range dueSeries {
+		poll, transactItems, err := newRunItems(os.Getenv("SINGLE_TABLE_NAME"), nanoIdOptions, ddbSeries, now)
+		if err != nil {
+			log.Printf("Error: %s: %s\n", ddbSeries.PkSeriesId, err)
+			continue
+		}
+
+		// The transaction is canceled if the series was paused, stopped or already run since it was read
+		if _, err := ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
+		}); err != nil {
+			log.Printf("Error: %s: %s\n", ddbSeries.PkSeriesId, err)
+			continue
+		}
+
+		log.Printf("Created poll %s for %s\n", poll.PollId, ddbSeries.PkSeriesId)
+	}
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) {
+	log.Printf("Processing event: %s\n", event)
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	runDueSeries(ctx, ddb, nanoIdOptions, time.Now())
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/recurrence"
+)
+
+var nanoIdOptions = pollcreate.NanoIdOptions{Alphabet: "abcdefghijklmnopqrstuvwxyz", Length: 12}
+
+func testSeries(schedule string) DdbSeries {
+	return DdbSeries{
+		PkSeriesId:   "series|series1",
+		Gsi1PkUserId: "user|user123",
+		Schedule:     schedule,
+		TimeZone:     "UTC",
+		Prompt:       "Standup?",
+		Options:      []string{"Yes", "No"},
+		Duration:     3600,
+		NextRunAt:    time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC).Unix(),
+	}
+}
+
+func TestNewRunItems(t *testing.T) {
+	// Two weeks of runs were missed
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	poll, transactItems, err := newRunItems("table", nanoIdOptions, testSeries("0 9 * * MON"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if poll.UserId != "user123" || len(transactItems) != 5 {
+		t.Fatalf("expected the poll, 2 options, the occurrence and the series, got %+v", transactItems)
+	}
+
+	var ddbOccurrence DdbOccurrence
+	if err := attributevalue.UnmarshalMap(transactItems[3].Put.Item, &ddbOccurrence); err != nil {
+		t.Fatal(err)
+	}
+	if ddbOccurrence.PkSeriesId != "series|series1" || ddbOccurrence.SkOccurrenceAt != "occurrence|2024-01-01T09:00:00Z" ||
+		ddbOccurrence.PollId != poll.PollId {
+		t.Errorf("expected the occurrence to link the run to the poll, got %+v", ddbOccurrence)
+	}
+
+	series := transactItems[4].Update
+	nextRunAt := series.ExpressionAttributeValues[":nextRunAt"].(*types.AttributeValueMemberN).Value
+	if expected := time.Date(2024, 1, 22, 9, 0, 0, 0, time.UTC).Unix(); nextRunAt != fmt.Sprint(expected) {
+		t.Errorf("expected the missed runs to be skipped to %d, got %s", expected, nextRunAt)
+	}
+	if runAt := series.ExpressionAttributeValues[":runAt"].(*types.AttributeValueMemberN).Value; runAt != fmt.Sprint(testSeries("").NextRunAt) {
+		t.Errorf("expected the update to be conditioned on the due run, got %s", runAt)
+	}
+}
+
+func TestNewRunItemsStopsFinishedSeries(t *testing.T) {
+	// 30 February never comes
+	_, transactItems, err := newRunItems("table", nanoIdOptions, testSeries("0 0 30 2 *"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := transactItems[len(transactItems)-1].Update.ExpressionAttributeValues[":status"].(*types.AttributeValueMemberS).Value
+	if status != recurrence.StatusStopped {
+		t.Errorf("expected the series to be stopped, got %s", status)
+	}
+}
+
+func TestNewDueSeriesQuery(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	query := newDueSeriesQuery("table", now)
+
+	if aws.ToString(query.IndexName) != recurrence.DueIndexName {
+		t.Errorf("expected the index of series by status to be queried, got %s", aws.ToString(query.IndexName))
+	}
+	if aws.ToString(query.KeyConditionExpression) != "#status = :active AND #nextRunAt <= :now" {
+		t.Errorf("unexpected key condition %s", aws.ToString(query.KeyConditionExpression))
+	}
+	if status := query.ExpressionAttributeValues[":active"].(*types.AttributeValueMemberS).Value; status != recurrence.StatusActive {
+		t.Errorf("expected only active series, got %s", status)
+	}
+	if due := query.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberN).Value; due != fmt.Sprint(now.Unix()) {
+		t.Errorf("expected the series due by now, got %s", due)
+	}
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+// Request names the backfill to run, and where a previous invocation stopped scanning the table
+type Request struct {
+	Backfill          string            `json:"backfill"`
+	ExclusiveStartKey map[string]string `json:"exclusiveStartKey,omitempty"`
+}
+
+// Response reports how many items were updated, and where to resume the backfill if the invocation ran out
+// of time before the whole table was scanned. It's invoked again with the same request and ExclusiveStartKey
+// until ExclusiveStartKey is empty.
+type Response struct {
+	Backfill          string            `json:"backfill"`
+	Updated           int               `json:"updated"`
+	ExclusiveStartKey map[string]string `json:"exclusiveStartKey,omitempty"`
+}
+
+// Backfill writes what items written before a change are missing. Scan finds the items, and Update writes a
+// page of them, returning how many it updated.
+type Backfill struct {
+	Scan   func() *dynamodb.ScanInput
+	Update func(ctx context.Context, ddb *dynamodb.Client, items []map[string]types.AttributeValue) (int, error)
+}
+
+type DdbPoll struct {
+	PkPollId  string `dynamodbav:"PK"`
+	SkPollId  string `dynamodbav:"SK"`
+	CreatedAt string `dynamodbav:"CreatedAt"`
+	Duration  int    `dynamodbav:"Duration"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+
+	// ResumeMargin is how long before the invocation times out that the backfill stops and returns where to
+	// resume it
+	ResumeMargin = 10 * time.Second
+)
+
+var Backfills = map[string]Backfill{
+	"open-polls": {
+		Scan:   openPollsScan,
+		Update: indexOpenPolls,
+	},
+	"memberships": {
+		Scan:   membershipsScan,
+		Update: indexMemberships,
+	},
+	"votes": {
+		Scan:   votesScan,
+		Update: indexVotes,
+	},
+}
+
+// openPollsScan finds the polls created before open polls were indexed that are still open, which the snapshot
+// publisher doesn't publish or close until they're indexed
+func openPollsScan() *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		FilterExpression: aws.String(
+			"begins_with(#pk, :poll) AND #pk = #sk AND attribute_not_exists(#open) AND " +
+				"attribute_not_exists(#closedAt) AND attribute_not_exists(#deletedAt) AND #isArchived = :isArchived",
+		),
+		ProjectionExpression: aws.String("#pk, #sk, #createdAt, #duration"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":         "PK",
+			"#sk":         "SK",
+			"#open":       "GSI2PK",
+			"#closedAt":   "ClosedAt",
+			"#deletedAt":  "DeletedAt",
+			"#isArchived": "IsArchived",
+			"#createdAt":  "CreatedAt",
+			"#duration":   "Duration",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll":       &types.AttributeValueMemberS{Value: "poll|"},
+			":isArchived": &types.AttributeValueMemberBOOL{Value: false},
+		},
+	}
+}
+
+// newOpenPollUpdate indexes the poll as open until it expires. It's conditioned on the poll still being open
+// and not yet indexed, since closing, archiving, deleting and extending the poll all keep the index themselves.
+func newOpenPollUpdate(ddbPoll DdbPoll) (*dynamodb.UpdateItemInput, error) {
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second)
+
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: ddbPoll.PkPollId},
+			"SK": &types.AttributeValueMemberS{Value: ddbPoll.SkPollId},
+		},
+		ConditionExpression: aws.String(
+			"attribute_exists(#pk) AND attribute_not_exists(#open) AND attribute_not_exists(#closedAt) AND " +
+				"attribute_not_exists(#deletedAt) AND #isArchived = :isArchived AND #duration = :duration",
+		),
+		UpdateExpression: aws.String("SET #open = :open, #expiresAt = :expiresAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":         "PK",
+			"#open":       "GSI2PK",
+			"#expiresAt":  "GSI2SK",
+			"#closedAt":   "ClosedAt",
+			"#deletedAt":  "DeletedAt",
+			"#isArchived": "IsArchived",
+			"#duration":   "Duration",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":open": &types.AttributeValueMemberS{Value: openpolls.Key},
+			":expiresAt": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(openpolls.SortKey(expiresAt), 10),
+			},
+			":isArchived": &types.AttributeValueMemberBOOL{Value: false},
+			":duration":   &types.AttributeValueMemberN{Value: strconv.Itoa(ddbPoll.Duration)},
+		},
+	}, nil
+}
+
+func indexOpenPolls(ctx context.Context, ddb *dynamodb.Client, items []map[string]types.AttributeValue) (int, error) {
+	var ddbPolls []DdbPoll
+	if err := attributevalue.UnmarshalListOfMaps(items, &ddbPolls); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, ddbPoll := range ddbPolls {
+		input, err := newOpenPollUpdate(ddbPoll)
+		if err != nil {
+			return updated, err
+		}
+
+		_, err = ddb.UpdateItem(ctx, input)
+
+		// The poll changed since it was scanned, and whatever changed it kept the index
+		var conditionalCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalCheckFailed) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+
+		updated++
+	}
+
+	return updated, nil
+}
+
+// membershipsScan finds the memberships added before they were indexed under their user, which exporting and
+// erasing the user's account don't find until they're indexed
+func membershipsScan() *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		TableName:            aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		FilterExpression:     aws.String("begins_with(#pk, :poll) AND begins_with(#sk, :member) AND attribute_not_exists(#user)"),
+		ProjectionExpression: aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":   "PK",
+			"#sk":   "SK",
+			"#user": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll":   &types.AttributeValueMemberS{Value: "poll|"},
+			":member": &types.AttributeValueMemberS{Value: pollaccess.MemberPrefix},
+		},
+	}
+}
+
+// newMembershipUpdate indexes the membership under its user, as adding the member does. It's conditioned on the
+// membership still existing, so a member removed since the scan isn't written back.
+func newMembershipUpdate(ddbMember pollaccess.DdbMember) *dynamodb.UpdateItemInput {
+	userId := strings.TrimPrefix(ddbMember.SkUserId, pollaccess.MemberPrefix)
+	pollId := strings.TrimPrefix(ddbMember.PkPollId, "poll|")
+
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: ddbMember.PkPollId},
+			"SK": &types.AttributeValueMemberS{Value: ddbMember.SkUserId},
+		},
+		ConditionExpression: aws.String("attribute_exists(#pk) AND attribute_not_exists(#user)"),
+		UpdateExpression:    aws.String("SET #user = :user, #poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":   "PK",
+			"#user": "GSI1PK",
+			"#poll": "GSI1SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user": &types.AttributeValueMemberS{Value: fmt.Sprintf("user|%s", userId)},
+			":poll": &types.AttributeValueMemberS{Value: pollaccess.MembershipKey(pollId)},
+		},
+	}
+}
+
+func indexMemberships(ctx context.Context, ddb *dynamodb.Client, items []map[string]types.AttributeValue) (int, error) {
+	var ddbMembers []pollaccess.DdbMember
+	if err := attributevalue.UnmarshalListOfMaps(items, &ddbMembers); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, ddbMember := range ddbMembers {
+		_, err := ddb.UpdateItem(ctx, newMembershipUpdate(ddbMember))
+
+		// The member was removed, or added again and indexed, since the scan
+		var conditionalCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalCheckFailed) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+
+		updated++
+	}
+
+	return updated, nil
+}
+
+type DdbVote struct {
+	PkVoterId string `dynamodbav:"PK"`
+	SkPollId  string `dynamodbav:"SK"`
+}
+
+// votesScan finds the votes cast before votes were indexed by their poll, which deleting the poll or force
+// editing its options doesn't find until they're indexed
+func votesScan() *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		TableName:            aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		FilterExpression:     aws.String("begins_with(#pk, :voter) AND begins_with(#sk, :poll) AND attribute_not_exists(#poll)"),
+		ProjectionExpression: aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":   "PK",
+			"#sk":   "SK",
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":voter": &types.AttributeValueMemberS{Value: "voter|"},
+			":poll":  &types.AttributeValueMemberS{Value: "poll|"},
+		},
+	}
+}
+
+// newVoteUpdate indexes the vote by its poll, as voting does. It's conditioned on the vote still existing, so a
+// vote removed since the scan isn't written back.
+func newVoteUpdate(ddbVote DdbVote) *dynamodb.UpdateItemInput {
+	pollId := strings.TrimPrefix(ddbVote.SkPollId, "poll|")
+
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: ddbVote.PkVoterId},
+			"SK": &types.AttributeValueMemberS{Value: ddbVote.SkPollId},
+		},
+		ConditionExpression: aws.String("attribute_exists(#pk) AND attribute_not_exists(#poll)"),
+		UpdateExpression:    aws.String("SET #poll = :poll, #voter = :voter"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":    "PK",
+			"#poll":  "GSI1PK",
+			"#voter": "GSI1SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll":  &types.AttributeValueMemberS{Value: fmt.Sprintf("votes|%s", pollId)},
+			":voter": &types.AttributeValueMemberS{Value: ddbVote.PkVoterId},
+		},
+	}
+}
+
+// isPollDeleted reports whether the poll was deleted, or purged by its time to live, so its votes are left for
+// nothing to find
+func isPollDeleted(ctx context.Context, ddb *dynamodb.Client, pk string) (bool, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: pk},
+		},
+		ProjectionExpression: aws.String("#pk, #deletedAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":        "PK",
+			"#deletedAt": "DeletedAt",
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, isDeleted := result.Item["DeletedAt"]
+	return result.Item == nil || isDeleted, nil
+}
+
+// indexVotes indexes the votes by their poll. The votes of polls that were deleted before their votes could be
+// found are deleted instead, as the poll deleter would have.
+func indexVotes(ctx context.Context, ddb *dynamodb.Client, items []map[string]types.AttributeValue) (int, error) {
+	var ddbVotes []DdbVote
+	if err := attributevalue.UnmarshalListOfMaps(items, &ddbVotes); err != nil {
+		return 0, err
+	}
+
+	deletedPolls := make(map[string]bool)
+	updated := 0
+	for _, ddbVote := range ddbVotes {
+		isDeleted, ok := deletedPolls[ddbVote.SkPollId]
+		if !ok {
+			var err error
+			if isDeleted, err = isPollDeleted(ctx, ddb, ddbVote.SkPollId); err != nil {
+				return updated, err
+			}
+			deletedPolls[ddbVote.SkPollId] = isDeleted
+		}
+
+		if isDeleted {
+			if _, err := ddb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+				Key: map[string]types.AttributeValue{
+					"PK": &types.AttributeValueMemberS{Value: ddbVote.PkVoterId},
+					"SK": &types.AttributeValueMemberS{Value: ddbVote.SkPollId},
+				},
+			}); err != nil {
+				return updated, err
+			}
+
+			updated++
+			continue
+		}
+
+		_, err := ddb.UpdateItem(ctx, newVoteUpdate(ddbVote))
+
+		// The vote was removed since the scan
+		var conditionalCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionalCheckFailed) {
+			continue
+		}
+		if err != nil {
+			return updated, err
+		}
+
+		updated++
+	}
+
+	return updated, nil
+}
+
+func fromStartKey(exclusiveStartKey map[string]string) map[string]types.AttributeValue {
+	if len(exclusiveStartKey) == 0 {
+		return nil
+	}
+
+	key := make(map[string]types.AttributeValue, len(exclusiveStartKey))
+	for name, value := range exclusiveStartKey {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+
+	return key
+}
+
+// toStartKey returns the table's keys, which are all strings, where the scan stopped
+func toStartKey(lastEvaluatedKey map[string]types.AttributeValue) (map[string]string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return nil, nil
+	}
+
+	key := make(map[string]string, len(lastEvaluatedKey))
+	for name, value := range lastEvaluatedKey {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, fmt.Errorf("key %s isn't a string", name)
+		}
+		key[name] = s.Value
+	}
+
+	return key, nil
+}
+
+// handler runs the requested backfill a page of the table at a time. Every update is conditioned on the item
+// still missing what's backfilled, so the backfill is safe to run again or alongside the lambdas writing the
+// table.
+func handler(ctx context.Context, request Request) (Response, error) {
+	log.Printf("Processing request: %+v\n", request)
+
+	backfill, ok := Backfills[request.Backfill]
+	if !ok {
+		return Response{}, fmt.Errorf("unknown backfill %q", request.Backfill)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	response := Response{Backfill: request.Backfill}
+
+	scan := backfill.Scan()
+	scan.ExclusiveStartKey = fromStartKey(request.ExclusiveStartKey)
+	for {
+		page, err := ddb.Scan(ctx, scan)
+		if err != nil {
+			return response, err
+		}
+
+		updated, err := backfill.Update(ctx, ddb, page.Items)
+		response.Updated += updated
+		if err != nil {
+			return response, err
+		}
+
+		if len(page.LastEvaluatedKey) == 0 {
+			log.Printf("Finished the %s backfill\n", request.Backfill)
+			return response, nil
+		}
+
+		if time.Until(deadline) < ResumeMargin {
+			response.ExclusiveStartKey, err = toStartKey(page.LastEvaluatedKey)
+			log.Printf("Stopping the %s backfill at %v\n", request.Backfill, response.ExclusiveStartKey)
+			return response, err
+		}
+
+		scan.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+func main() {
+	lambda.Start(handler)
+}
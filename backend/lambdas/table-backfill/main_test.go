@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+)
+
+func TestNewOpenPollUpdate(t *testing.T) {
+	input, err := newOpenPollUpdate(DdbPoll{
+		PkPollId:  "poll|poll123",
+		SkPollId:  "poll|poll123",
+		CreatedAt: "2024-01-01T00:00:00Z",
+		Duration:  3600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.ToString(input.UpdateExpression) != "SET #open = :open, #expiresAt = :expiresAt" {
+		t.Errorf("unexpected update %s", aws.ToString(input.UpdateExpression))
+	}
+	if open := input.ExpressionAttributeValues[":open"].(*types.AttributeValueMemberS).Value; open != "open" {
+		t.Errorf("expected the poll to be indexed as open, got %s", open)
+	}
+	if expiresAt := input.ExpressionAttributeValues[":expiresAt"].(*types.AttributeValueMemberN).Value; expiresAt != "1704070800000" {
+		t.Errorf("expected the poll to be indexed by when it expires, got %s", expiresAt)
+	}
+	if duration := input.ExpressionAttributeValues[":duration"].(*types.AttributeValueMemberN).Value; duration != "3600" {
+		t.Errorf("expected the update to be conditioned on the scanned duration, got %s", duration)
+	}
+
+	if _, err := newOpenPollUpdate(DdbPoll{CreatedAt: "yesterday"}); err == nil {
+		t.Error("expected an unparseable creation time to fail")
+	}
+}
+
+func TestNewMembershipUpdate(t *testing.T) {
+	input := newMembershipUpdate(pollaccess.DdbMember{PkPollId: "poll|poll123", SkUserId: "member|user2"})
+
+	if user := input.ExpressionAttributeValues[":user"].(*types.AttributeValueMemberS).Value; user != "user|user2" {
+		t.Errorf("expected the membership to be indexed under user|user2, got %s", user)
+	}
+	if poll := input.ExpressionAttributeValues[":poll"].(*types.AttributeValueMemberS).Value; poll != "member|poll123" {
+		t.Errorf("expected the membership to be sorted as member|poll123, got %s", poll)
+	}
+	if sk := input.Key["SK"].(*types.AttributeValueMemberS).Value; sk != "member|user2" {
+		t.Errorf("expected the membership's own key, got %s", sk)
+	}
+}
+
+func TestNewVoteUpdate(t *testing.T) {
+	input := newVoteUpdate(DdbVote{PkVoterId: "voter|user2", SkPollId: "poll|poll123"})
+
+	if poll := input.ExpressionAttributeValues[":poll"].(*types.AttributeValueMemberS).Value; poll != "votes|poll123" {
+		t.Errorf("expected the vote to be indexed under votes|poll123, got %s", poll)
+	}
+	if voter := input.ExpressionAttributeValues[":voter"].(*types.AttributeValueMemberS).Value; voter != "voter|user2" {
+		t.Errorf("expected the vote to be sorted by its voter, got %s", voter)
+	}
+	if pk := input.Key["PK"].(*types.AttributeValueMemberS).Value; pk != "voter|user2" {
+		t.Errorf("expected the vote's own key, got %s", pk)
+	}
+}
+
+func TestStartKey(t *testing.T) {
+	if fromStartKey(nil) != nil {
+		t.Error("expected a backfill without a start key to scan from the beginning")
+	}
+
+	startKey := map[string]string{"PK": "poll|poll123", "SK": "poll|poll123"}
+	key, err := toStartKey(fromStartKey(startKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(key, startKey) {
+		t.Errorf("expected %v, got %v", startKey, key)
+	}
+
+	if _, err := toStartKey(map[string]types.AttributeValue{"PK": &types.AttributeValueMemberN{Value: "1"}}); err == nil {
+		t.Error("expected a key that isn't a string to fail")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -18,6 +19,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollaccess"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
 )
 
 type Body struct {
@@ -33,6 +38,9 @@ type DdbPoll struct {
 	CreatedAt    string `dynamodbav:"CreatedAt"`
 	Duration     int    `dynamodbav:"Duration"`
 	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+
+	RetentionDays int `dynamodbav:"RetentionDays"`
 }
 
 type Error struct {
@@ -63,6 +71,59 @@ func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatew
 	return res
 }
 
+// newDurationUpdate sets the poll's duration, so it expires at expiresAt, at its next sequence number. Extending a
+// closed poll reopens it, so the outcome decided when it closed, and any tie its owner broke, no longer hold. The
+// poll is indexed as open until it expires, when the snapshot publisher closes it, unless it's archived, which keeps
+// it out of the index. A poll that's purged some days after it closes is purged that many days after it expires
+// instead, while an archived poll keeps the time to live it was archived with.
+func newDurationUpdate(
+	ddbPoll DdbPoll,
+	duration int,
+	expiresAt time.Time,
+	sequence int64,
+) (string, map[string]string, map[string]types.AttributeValue) {
+	expressionAttributeNames := map[string]string{
+		"#duration":         "Duration",
+		"#closedAt":         "ClosedAt",
+		"#outcome":          "Outcome",
+		"#tieBreakOptionId": "TieBreakOptionId",
+		"#version":          "Version",
+	}
+	expressionAttributeValues := map[string]types.AttributeValue{
+		":duration": &types.AttributeValueMemberN{
+			Value: strconv.Itoa(duration),
+		},
+		":version": &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(sequence, 10),
+		},
+	}
+
+	sets := []string{"#duration = :duration", "#version = :version"}
+	if !ddbPoll.IsArchived {
+		expressionAttributeNames["#open"] = "GSI2PK"
+		expressionAttributeNames["#expiresAt"] = "GSI2SK"
+		expressionAttributeValues[":open"] = &types.AttributeValueMemberS{
+			Value: openpolls.Key,
+		}
+		expressionAttributeValues[":expiresAt"] = &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(openpolls.SortKey(expiresAt), 10),
+		}
+		sets = append(sets, "#open = :open", "#expiresAt = :expiresAt")
+
+		if ddbPoll.RetentionDays > 0 {
+			expressionAttributeNames["#ttl"] = "Ttl"
+			expressionAttributeValues[":ttl"] = &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(pollcreate.CloseTtl(expiresAt, ddbPoll.RetentionDays), 10),
+			}
+			sets = append(sets, "#ttl = :ttl")
+		}
+	}
+
+	return "SET " + strings.Join(sets, ", ") + " REMOVE #closedAt, #outcome, #tieBreakOptionId",
+		expressionAttributeNames,
+		expressionAttributeValues
+}
+
 func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var requestBody Body
 	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
@@ -142,6 +203,34 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 		), nil
 	}
 
+	_, err = pollaccess.Authorize(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		request.PathParameters["pollId"],
+		ddbPoll.Gsi1PkUserId,
+		request.RequestContext.Authorizer["sub"].(string),
+		pollaccess.ActionManage,
+	)
+	if errors.Is(err, pollaccess.ErrForbidden) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusForbidden,
+				Body:       formatError("Forbidden", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
 	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
 	if err != nil {
 		return logAndReturn(
@@ -188,6 +277,36 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 	}
 	duration = int(newExpirationTime.Sub(createdAt).Seconds())
 
+	sequence, err := pollsequence.Next(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		request.PathParameters["pollId"],
+		ddbPoll.Version,
+	)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	updateExpression, expressionAttributeNames, expressionAttributeValues := newDurationUpdate(
+		ddbPoll,
+		duration,
+		newExpirationTime,
+		sequence,
+	)
+	expressionAttributeNames["#pk"] = "PK"
+	expressionAttributeNames["#deletedAt"] = "DeletedAt"
+	expressionAttributeNames["#isArchived"] = "IsArchived"
+	expressionAttributeValues[":isArchived"] = &types.AttributeValueMemberBOOL{
+		Value: ddbPoll.IsArchived,
+	}
+
 	input := &dynamodb.UpdateItemInput{
 		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
 		Key: map[string]types.AttributeValue{
@@ -198,21 +317,12 @@ func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events
 				Value: fmt.Sprintf("poll|%s", request.PathParameters["pollId"]),
 			},
 		},
-		ConditionExpression: aws.String("#userPk = :user AND #userSk = :user"),
-		UpdateExpression:    aws.String("SET #duration = :duration"),
-		ExpressionAttributeNames: map[string]string{
-			"#userPk":   "GSI1PK",
-			"#userSk":   "GSI1SK",
-			"#duration": "Duration",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":user": &types.AttributeValueMemberS{
-				Value: fmt.Sprintf("user|%s", request.RequestContext.Authorizer["sub"].(string)),
-			},
-			":duration": &types.AttributeValueMemberN{
-				Value: strconv.Itoa(duration),
-			},
-		},
+		ConditionExpression: aws.String(
+			"attribute_exists(#pk) AND attribute_not_exists(#deletedAt) AND #isArchived = :isArchived",
+		),
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
 	}
 
 	_, err = ddb.UpdateItem(ctx, input)
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestNewDurationUpdate(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		ddbPoll          DdbPoll
+		updateExpression string
+		ttl              string
+	}{
+		"open poll": {
+			ddbPoll:          DdbPoll{},
+			updateExpression: "SET #duration = :duration, #version = :version, #open = :open, #expiresAt = :expiresAt REMOVE #closedAt, #outcome, #tieBreakOptionId",
+		},
+		"poll purged after it closes": {
+			ddbPoll:          DdbPoll{RetentionDays: 7},
+			updateExpression: "SET #duration = :duration, #version = :version, #open = :open, #expiresAt = :expiresAt, #ttl = :ttl REMOVE #closedAt, #outcome, #tieBreakOptionId",
+			ttl:              "1704758400",
+		},
+		"archived poll": {
+			ddbPoll:          DdbPoll{IsArchived: true, RetentionDays: 7},
+			updateExpression: "SET #duration = :duration, #version = :version REMOVE #closedAt, #outcome, #tieBreakOptionId",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			updateExpression, expressionAttributeNames, expressionAttributeValues := newDurationUpdate(
+				test.ddbPoll,
+				86400,
+				expiresAt,
+				5,
+			)
+
+			if updateExpression != test.updateExpression {
+				t.Errorf("expected %s, got %s", test.updateExpression, updateExpression)
+			}
+			if test.ttl == "" {
+				if _, ok := expressionAttributeNames["#ttl"]; ok {
+					t.Error("expected the poll's time to live to be left alone")
+				}
+				return
+			}
+
+			// 7 days after the poll's new expiration
+			if ttl := expressionAttributeValues[":ttl"].(*types.AttributeValueMemberN).Value; ttl != test.ttl {
+				t.Errorf("expected the time to live %s, got %s", test.ttl, ttl)
+			}
+		})
+	}
+}
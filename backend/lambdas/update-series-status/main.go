@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/recurrence"
+)
+
+type RequestBody struct {
+	Status string `json:"status"`
+}
+
+type DdbSeries struct {
+	PkSeriesId   string   `dynamodbav:"PK"`
+	Gsi1PkUserId string   `dynamodbav:"GSI1PK"`
+	Schedule     string   `dynamodbav:"Schedule"`
+	TimeZone     string   `dynamodbav:"TimeZone"`
+	Prompt       string   `dynamodbav:"Prompt"`
+	Options      []string `dynamodbav:"Options"`
+	Duration     int      `dynamodbav:"Duration"`
+	Status       string   `dynamodbav:"Status"`
+	NextRunAt    int64    `dynamodbav:"NextRunAt"`
+	CreatedAt    string   `dynamodbav:"CreatedAt"`
+	UpdatedAt    string   `dynamodbav:"UpdatedAt"`
+}
+
+type Series struct {
+	SeriesId  string   `json:"seriesId"`
+	Schedule  string   `json:"schedule"`
+	TimeZone  string   `json:"timeZone"`
+	Prompt    string   `json:"prompt"`
+	Options   []string `json:"options"`
+	Duration  int      `json:"duration"`
+	Status    string   `json:"status"`
+	NextRunAt string   `json:"nextRunAt,omitempty"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+var (
+	errUnknownStatus = errors.New("unknown status")
+	errSeriesStopped = errors.New("series is stopped")
+	errNeverRuns     = errors.New("the schedule never runs")
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// getNextRunAt checks the series can move to the status and returns when it next runs. Stopping a series
+// is final, and resuming one schedules it from now, so the runs missed while it was paused are skipped.
+func getNextRunAt(ddbSeries DdbSeries, status string, now time.Time) (int64, error) {
+	if status != recurrence.StatusActive && status != recurrence.StatusPaused && status != recurrence.StatusStopped {
+		return 0, fmt.Errorf("%w: %q", errUnknownStatus, status)
+	}
+	if ddbSeries.Status == recurrence.StatusStopped {
+		return 0, errSeriesStopped
+	}
+	if status != recurrence.StatusActive || ddbSeries.Status == recurrence.StatusActive {
+		return ddbSeries.NextRunAt, nil
+	}
+
+	nextRunAt, err := recurrence.Schedule{
+		Expression: ddbSeries.Schedule,
+		TimeZone:   ddbSeries.TimeZone,
+	}.Next(now)
+	if err != nil {
+		return 0, err
+	}
+	if nextRunAt.IsZero() {
+		return 0, errNeverRuns
+	}
+
+	return nextRunAt.Unix(), nil
+}
+
+func newSeries(ddbSeries DdbSeries) Series {
+	series := Series{
+		SeriesId:  stripPrefix(ddbSeries.PkSeriesId, "series|"),
+		Schedule:  ddbSeries.Schedule,
+		TimeZone:  ddbSeries.TimeZone,
+		Prompt:    ddbSeries.Prompt,
+		Options:   ddbSeries.Options,
+		Duration:  ddbSeries.Duration,
+		Status:    ddbSeries.Status,
+		CreatedAt: ddbSeries.CreatedAt,
+		UpdatedAt: ddbSeries.UpdatedAt,
+	}
+	if ddbSeries.Status == recurrence.StatusActive {
+		series.NextRunAt = time.Unix(ddbSeries.NextRunAt, 0).UTC().Format(RFC3339Milli)
+	}
+
+	return series
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	now := time.Now()
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	seriesKey := fmt.Sprintf("series|%s", request.PathParameters["seriesId"])
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: seriesKey,
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: seriesKey,
+		},
+	}
+
+	seriesResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key:       key,
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbSeries DdbSeries
+	if err := attributevalue.UnmarshalMap(seriesResult.Item, &ddbSeries); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	// Another user's series is reported as not found, so series ids can't be probed
+	if seriesResult.Item == nil || ddbSeries.Gsi1PkUserId != fmt.Sprintf("user|%s", userId) {
+		err := errors.New("series not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+
+	nextRunAt, err := getNextRunAt(ddbSeries, requestBody.Status, now)
+	if errors.Is(err, errSeriesStopped) {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	// The scheduler may have run the series since it was read, so the update is conditioned on the run
+	// it read being the next one
+	updateResult, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #status = :status, #nextRunAt = :nextRunAt, #updatedAt = :updatedAt"),
+		ConditionExpression: aws.String("#status = :currentStatus AND #nextRunAt = :currentNextRunAt"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":    "Status",
+			"#nextRunAt": "NextRunAt",
+			"#updatedAt": "UpdatedAt",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{
+				Value: requestBody.Status,
+			},
+			":nextRunAt": &types.AttributeValueMemberN{
+				Value: fmt.Sprint(nextRunAt),
+			},
+			":updatedAt": &types.AttributeValueMemberS{
+				Value: now.UTC().Format(RFC3339Milli),
+			},
+			":currentStatus": &types.AttributeValueMemberS{
+				Value: ddbSeries.Status,
+			},
+			":currentNextRunAt": &types.AttributeValueMemberN{
+				Value: fmt.Sprint(ddbSeries.NextRunAt),
+			},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		err := errors.New("series was modified, try again")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       formatError("Conflict", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	if err = attributevalue.UnmarshalMap(updateResult.Attributes, &ddbSeries); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	series, err := json.Marshal(newSeries(ddbSeries))
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(series),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
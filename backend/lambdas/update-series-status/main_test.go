@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/recurrence"
+)
+
+func TestGetNextRunAt(t *testing.T) {
+	// Monday 2024-01-08 12:00 UTC, after the paused series missed its 09:00 run
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	missedRun := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC).Unix()
+	series := func(status string) DdbSeries {
+		return DdbSeries{Schedule: "0 9 * * MON", TimeZone: "UTC", Status: status, NextRunAt: missedRun}
+	}
+
+	tests := map[string]struct {
+		series   DdbSeries
+		status   string
+		expected int64
+		err      error
+	}{
+		"pause":          {series: series(recurrence.StatusActive), status: recurrence.StatusPaused, expected: missedRun},
+		"stop":           {series: series(recurrence.StatusPaused), status: recurrence.StatusStopped, expected: missedRun},
+		"resume":         {series: series(recurrence.StatusPaused), status: recurrence.StatusActive, expected: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC).Unix()},
+		"already active": {series: series(recurrence.StatusActive), status: recurrence.StatusActive, expected: missedRun},
+		"stopped":        {series: series(recurrence.StatusStopped), status: recurrence.StatusActive, err: errSeriesStopped},
+		"unknown":        {series: series(recurrence.StatusActive), status: "deleted", err: errUnknownStatus},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			nextRunAt, err := getNextRunAt(test.series, test.status, now)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if nextRunAt != test.expected {
+				t.Errorf("expected the next run at %d, got %d", test.expected, nextRunAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type RequestBody struct {
+	Name     string   `json:"name"`
+	Prompt   string   `json:"prompt"`
+	Options  []string `json:"options"`
+	Duration int      `json:"duration"`
+}
+
+type DdbTemplate struct {
+	PkTemplateId string   `dynamodbav:"PK"`
+	Name         string   `dynamodbav:"Name"`
+	Prompt       string   `dynamodbav:"Prompt"`
+	Options      []string `dynamodbav:"Options"`
+	Duration     int      `dynamodbav:"Duration"`
+	CreatedAt    string   `dynamodbav:"CreatedAt"`
+	UpdatedAt    string   `dynamodbav:"UpdatedAt"`
+}
+
+type Template struct {
+	TemplateId string   `json:"templateId"`
+	Name       string   `json:"name"`
+	Prompt     string   `json:"prompt"`
+	Options    []string `json:"options"`
+	Duration   int      `json:"duration"`
+	CreatedAt  string   `json:"createdAt"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Cause   string `json:"cause"`
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func formatError(msg string, err error) string {
+	responseBody, _ := json.Marshal(Error{
+		Message: msg,
+		Cause:   err.Error(),
+	})
+
+	return string(responseBody)
+}
+
+func logAndReturn(res events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	if err != nil {
+		log.Printf("Error: %s", err)
+	}
+
+	log.Printf("Response: %v", res)
+
+	return res
+}
+
+// newUpdateValues replaces the whole template, which can only be updated by its owner
+func newUpdateValues(userId string, requestBody RequestBody, currentTime string) (map[string]types.AttributeValue, error) {
+	options, err := attributevalue.Marshal(requestBody.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.AttributeValue{
+		":user": &types.AttributeValueMemberS{
+			Value: fmt.Sprintf("user|%s", userId),
+		},
+		":name": &types.AttributeValueMemberS{
+			Value: requestBody.Name,
+		},
+		":prompt": &types.AttributeValueMemberS{
+			Value: requestBody.Prompt,
+		},
+		":options": options,
+		":duration": &types.AttributeValueMemberN{
+			Value: fmt.Sprint(requestBody.Duration),
+		},
+		":updatedAt": &types.AttributeValueMemberS{
+			Value: currentTime,
+		},
+	}, nil
+}
+
+func handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	currentTime := time.Now().UTC().Format(RFC3339Milli)
+	userId := request.RequestContext.Authorizer["sub"].(string)
+
+	var requestBody RequestBody
+	if err := json.Unmarshal([]byte(request.Body), &requestBody); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       formatError("Bad request", err),
+			},
+			err,
+		), nil
+	}
+
+	values, err := newUpdateValues(userId, requestBody, currentTime)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	ddb := dynamodb.NewFromConfig(cfg)
+
+	templateKey := fmt.Sprintf("template|%s", request.PathParameters["templateId"])
+
+	// Another user's template is reported as not found, so template ids can't be probed
+	updateResult, err := ddb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: templateKey,
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: templateKey,
+			},
+		},
+		UpdateExpression: aws.String(
+			"SET #name = :name, #prompt = :prompt, #options = :options, #duration = :duration, #updatedAt = :updatedAt",
+		),
+		ConditionExpression: aws.String("#user = :user"),
+		ExpressionAttributeNames: map[string]string{
+			"#user":      "GSI1PK",
+			"#name":      "Name",
+			"#prompt":    "Prompt",
+			"#options":   "Options",
+			"#duration":  "Duration",
+			"#updatedAt": "UpdatedAt",
+		},
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		err := errors.New("template not found")
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       formatError("Not found", err),
+			},
+			err,
+		), nil
+	}
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	var ddbTemplate DdbTemplate
+	if err = attributevalue.UnmarshalMap(updateResult.Attributes, &ddbTemplate); err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	template, err := json.Marshal(Template{
+		TemplateId: stripPrefix(ddbTemplate.PkTemplateId, "template|"),
+		Name:       ddbTemplate.Name,
+		Prompt:     ddbTemplate.Prompt,
+		Options:    ddbTemplate.Options,
+		Duration:   ddbTemplate.Duration,
+		CreatedAt:  ddbTemplate.CreatedAt,
+		UpdatedAt:  ddbTemplate.UpdatedAt,
+	})
+	if err != nil {
+		return logAndReturn(
+			events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body:       formatError("Internal server error", err),
+			},
+			err,
+		), nil
+	}
+
+	return logAndReturn(
+		events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(template),
+		},
+		nil,
+	), nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestNewUpdateValues(t *testing.T) {
+	values, err := newUpdateValues(
+		"user123",
+		RequestBody{Name: "Lunch", Prompt: "Where to?", Options: []string{"Tacos", "Pho"}, Duration: 600},
+		"2024-01-01T00:00:00.000Z",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user := values[":user"].(*types.AttributeValueMemberS).Value; user != "user|user123" {
+		t.Errorf("expected the update to be conditioned on the owner, got %s", user)
+	}
+
+	options, ok := values[":options"].(*types.AttributeValueMemberL)
+	if !ok || len(options.Value) != 2 || options.Value[1].(*types.AttributeValueMemberS).Value != "Pho" {
+		t.Errorf("expected the options to be stored as a list in order, got %+v", values[":options"])
+	}
+
+	if duration := values[":duration"].(*types.AttributeValueMemberN).Value; duration != "600" {
+		t.Errorf("expected a duration of 600, got %s", duration)
+	}
+}
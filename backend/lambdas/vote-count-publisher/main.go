@@ -3,16 +3,27 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/declanlscott/pseudopoll/backend/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type VoteCountedDetail struct {
@@ -36,20 +47,56 @@ type VoteCountedDetail struct {
 			Votes struct {
 				N string `json:"N"`
 			} `json:"Votes"`
+			Version struct {
+				N string `json:"N"`
+			} `json:"Version"`
+			TraceParent struct {
+				S string `json:"S"`
+			} `json:"TraceParent"`
+			TraceState struct {
+				S string `json:"S"`
+			} `json:"TraceState"`
+			WriteInStatus struct {
+				S string `json:"S"`
+			} `json:"WriteInStatus"`
 		} `json:"NewImage"`
+		SequenceNumber string `json:"SequenceNumber"`
 	} `json:"dynamodb"`
 }
 
-type Payload struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+// VoteCountedBatch is a batch of records read from the DynamoDB stream in batch mode.
+// Each record has the same shape as the detail of the events the pipe forwards in single mode.
+type VoteCountedBatch struct {
+	Records []VoteCountedDetail `json:"Records"`
 }
 
-type VoteCountedPayloadData struct {
-	OptionId string `json:"optionId"`
-	PollId   string `json:"pollId"`
-	VotedAt  string `json:"updatedAt"`
-	Votes    int64  `json:"votes"`
+// pollBatch holds the latest count of each of a poll's options that changed in a batch
+type pollBatch struct {
+	pollId    string
+	options   map[string]VoteCountedDetail
+	versions  map[string]int64
+	sequences []int64
+	records   []string
+	links     []trace.Link
+}
+
+const (
+	PublishModeSingle     = "single"
+	PublishModeBatch      = "batch"
+	DefaultMaxPublishRate = 2.0
+
+	// PublishTimeout is the time left for the publish itself once the rate limiter lets it through
+	PublishTimeout = 2 * time.Second
+)
+
+// errRateLimited is returned when a topic can't be published to before the invocation times out
+var errRateLimited = errors.New("rate limited until after the deadline")
+
+// limiter spaces out the publishes to each topic in batch mode
+var limiter *rateLimiter
+
+type DdbOptionVersion struct {
+	Version int64 `dynamodbav:"Version"`
 }
 
 func stripPrefix(s string, prefix string) string {
@@ -60,7 +107,307 @@ func stripPrefix(s string, prefix string) string {
 	return s
 }
 
+// parseVersion returns the poll version stamped on the option by the vote, treating options counted before versioning as 0
+func parseVersion(voteCountedDetail VoteCountedDetail) (int64, error) {
+	if voteCountedDetail.DynamoDb.NewImage.Version.N == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(voteCountedDetail.DynamoDb.NewImage.Version.N, 10, 64)
+}
+
+// isStale reports whether the option has been counted again since the given version.
+// Events are delivered out of order, and the newer count is published by its own event.
+func isStale(ctx context.Context, ddb *dynamodb.Client, optionId string, version int64) (bool, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("option|%s", optionId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("option|%s", optionId),
+			},
+		},
+		ProjectionExpression: aws.String("#version"),
+		ExpressionAttributeNames: map[string]string{
+			"#version": "Version",
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var current DdbOptionVersion
+	if err := attributevalue.UnmarshalMap(result.Item, &current); err != nil {
+		return false, err
+	}
+
+	return current.Version > version, nil
+}
+
+// newPayload builds the voteCounted message for the option's new image, carrying the trace context of ctx
+func newPayload(ctx context.Context, voteCountedDetail VoteCountedDetail) ([]byte, error) {
+	votes, err := strconv.ParseInt(voteCountedDetail.DynamoDb.NewImage.Votes.N, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := parseVersion(voteCountedDetail)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := protocol.NewEnvelope(protocol.TypeVoteCounted, version, protocol.VoteCountedData{
+		OptionId:  stripPrefix(voteCountedDetail.DynamoDb.NewImage.OptionId.S, "option|"),
+		PollId:    stripPrefix(voteCountedDetail.DynamoDb.NewImage.PollId.S, "poll|"),
+		UpdatedAt: voteCountedDetail.DynamoDb.NewImage.UpdatedAt.S,
+		Votes:     votes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.TraceParent, envelope.TraceState = tracing.Inject(ctx)
+
+	return json.Marshal(envelope)
+}
+
+func getMaxPublishRate() (float64, error) {
+	maxPublishRate := os.Getenv("MAX_PUBLISH_RATE")
+	if maxPublishRate == "" {
+		return DefaultMaxPublishRate, nil
+	}
+
+	return strconv.ParseFloat(maxPublishRate, 64)
+}
+
+// rateLimiter allows at most maxRate publishes per second to each topic. Its state lives as long as
+// the execution environment, so the batching publisher runs with a reserved concurrency of 1.
+type rateLimiter struct {
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newRateLimiter(maxRate float64) *rateLimiter {
+	var interval time.Duration
+	if maxRate > 0 {
+		interval = time.Duration(float64(time.Second) / maxRate)
+	}
+
+	return &rateLimiter{
+		interval: interval,
+		next:     map[string]time.Time{},
+	}
+}
+
+// Wait blocks until the topic can be published to, then reserves the topic's next slot.
+// Waits that would run into the last PublishTimeout before the deadline of ctx return
+// errRateLimited straight away, so that a large batch is retried rather than timing out.
+func (l *rateLimiter) Wait(ctx context.Context, topic string) error {
+	now := time.Now()
+
+	if next, ok := l.next[topic]; ok && next.After(now) {
+		if deadline, ok := ctx.Deadline(); ok && next.After(deadline.Add(-PublishTimeout)) {
+			return errRateLimited
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		now = next
+	}
+
+	l.next[topic] = now.Add(l.interval)
+
+	return nil
+}
+
+// coalesce groups a batch of records by poll, keeping only the latest count of each option
+func coalesce(ctx context.Context, records []VoteCountedDetail) []*pollBatch {
+	batchesByPollId := map[string]*pollBatch{}
+	var batches []*pollBatch
+
+	for _, record := range records {
+		if !pollcreate.IsShown(record.DynamoDb.NewImage.WriteInStatus.S) {
+			continue
+		}
+
+		version, err := parseVersion(record)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			continue
+		}
+
+		pollId := stripPrefix(record.DynamoDb.NewImage.PollId.S, "poll|")
+		optionId := stripPrefix(record.DynamoDb.NewImage.OptionId.S, "option|")
+
+		batch, ok := batchesByPollId[pollId]
+		if !ok {
+			batch = &pollBatch{
+				pollId:   pollId,
+				options:  map[string]VoteCountedDetail{},
+				versions: map[string]int64{},
+			}
+			batchesByPollId[pollId] = batch
+			batches = append(batches, batch)
+		}
+
+		if latest, ok := batch.versions[optionId]; !ok || version > latest {
+			batch.options[optionId] = record
+			batch.versions[optionId] = version
+		}
+
+		batch.sequences = append(batch.sequences, version)
+		batch.records = append(batch.records, record.DynamoDb.SequenceNumber)
+		batch.links = append(batch.links, trace.LinkFromContext(tracing.Extract(
+			ctx,
+			record.DynamoDb.NewImage.TraceParent.S,
+			record.DynamoDb.NewImage.TraceState.S,
+		)))
+	}
+
+	return batches
+}
+
+// newVotesCountedPayload builds the votesCounted message for the options of a poll batch, carrying the trace context of ctx
+func newVotesCountedPayload(ctx context.Context, batch *pollBatch) ([]byte, error) {
+	data := protocol.VotesCountedData{
+		PollId:    batch.pollId,
+		Sequences: batch.sequences,
+	}
+
+	var sequence int64
+	for optionId, record := range batch.options {
+		votes, err := strconv.ParseInt(record.DynamoDb.NewImage.Votes.N, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		data.Options = append(data.Options, protocol.OptionVotes{
+			OptionId:  optionId,
+			UpdatedAt: record.DynamoDb.NewImage.UpdatedAt.S,
+			Votes:     votes,
+			Sequence:  batch.versions[optionId],
+		})
+
+		if batch.versions[optionId] > sequence {
+			sequence = batch.versions[optionId]
+		}
+	}
+
+	sort.Slice(data.Options, func(i, j int) bool {
+		return data.Options[i].Sequence < data.Options[j].Sequence
+	})
+	sort.Slice(data.Sequences, func(i, j int) bool {
+		return data.Sequences[i] < data.Sequences[j]
+	})
+
+	envelope, err := protocol.NewEnvelope(protocol.TypeVotesCounted, sequence, data)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.TraceParent, envelope.TraceState = tracing.Inject(ctx)
+
+	return json.Marshal(envelope)
+}
+
+// publishBatch publishes one votesCounted message for the poll batch, leaving out options counted again since
+func publishBatch(ctx context.Context, batch *pollBatch, ddb *dynamodb.Client, iot *iotdataplane.Client) error {
+	// A combined message continues many traces, so it is linked to the votes rather than parented by one
+	ctx, span := tracing.Tracer("vote-count-publisher").Start(
+		ctx,
+		"publish votesCounted",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithLinks(batch.links...),
+		trace.WithAttributes(
+			attribute.String("pseudopoll.poll_id", batch.pollId),
+			attribute.Int("pseudopoll.records", len(batch.records)),
+		),
+	)
+	defer span.End()
+
+	for optionId, version := range batch.versions {
+		stale, err := isStale(ctx, ddb, optionId, version)
+		if err != nil {
+			return err
+		}
+
+		if stale {
+			delete(batch.options, optionId)
+			delete(batch.versions, optionId)
+		}
+	}
+
+	if len(batch.options) == 0 {
+		log.Printf("Dropping stale vote counts for poll %s\n", batch.pollId)
+		return nil
+	}
+
+	payload, err := newVotesCountedPayload(ctx, batch)
+	if err != nil {
+		return err
+	}
+
+	topic := fmt.Sprintf("poll/%s", batch.pollId)
+	if err := limiter.Wait(ctx, topic); err != nil {
+		return err
+	}
+
+	_, err = iot.Publish(ctx, &iotdataplane.PublishInput{
+		Topic:       aws.String(topic),
+		ContentType: aws.String("application/json"),
+		Payload:     payload,
+	})
+
+	return err
+}
+
+// batchHandler publishes the vote counts read from the DynamoDB stream, coalesced per poll.
+// Records of polls that fail to publish are reported so that they are retried.
+func batchHandler(ctx context.Context, event VoteCountedBatch) (events.DynamoDBEventResponse, error) {
+	defer tracing.ForceFlush(ctx)
+
+	log.Printf("Processing %d records\n", len(event.Records))
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return events.DynamoDBEventResponse{}, err
+	}
+
+	tracing.InstrumentConfig(&cfg)
+
+	ddb := dynamodb.NewFromConfig(cfg)
+	iot := iotdataplane.NewFromConfig(cfg)
+
+	var response events.DynamoDBEventResponse
+	for _, batch := range coalesce(ctx, event.Records) {
+		if err := publishBatch(ctx, batch, ddb, iot); err != nil {
+			log.Printf("Error: %s\n", err)
+
+			for _, sequenceNumber := range batch.records {
+				response.BatchItemFailures = append(
+					response.BatchItemFailures,
+					events.DynamoDBBatchItemFailure{ItemIdentifier: sequenceNumber},
+				)
+			}
+		}
+	}
+
+	return response, nil
+}
+
 func handler(ctx context.Context, event events.CloudWatchEvent) {
+	defer tracing.ForceFlush(ctx)
+
 	log.Printf("Processing event: %s\n", event)
 
 	if event.Source != os.Getenv("SOURCE") || event.DetailType != os.Getenv("DETAIL_TYPE") {
@@ -74,6 +421,9 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		return
 	}
 
+	tracing.InstrumentConfig(&cfg)
+
+	ddb := dynamodb.NewFromConfig(cfg)
 	iot := iotdataplane.NewFromConfig(cfg)
 
 	var voteCountedDetail VoteCountedDetail
@@ -81,29 +431,55 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		log.Printf("Error: %s\n", err)
 		return
 	}
+
+	// Continue the trace of the vote that last updated the option
+	ctx, span := tracing.Tracer("vote-count-publisher").Start(
+		tracing.Extract(
+			ctx,
+			voteCountedDetail.DynamoDb.NewImage.TraceParent.S,
+			voteCountedDetail.DynamoDb.NewImage.TraceState.S,
+		),
+		"publish voteCounted",
+		trace.WithSpanKind(trace.SpanKindProducer),
+	)
+	defer span.End()
 	log.Printf(
 		"Vote counted: %s with %s votes\n",
 		voteCountedDetail.DynamoDb.NewImage.OptionId.S,
 		voteCountedDetail.DynamoDb.NewImage.Votes.N,
 	)
 
-	votes, err := strconv.ParseInt(voteCountedDetail.DynamoDb.NewImage.Votes.N, 10, 64)
+	pollId := stripPrefix(voteCountedDetail.DynamoDb.NewImage.PollId.S, "poll|")
+	span.SetAttributes(attribute.String("pseudopoll.poll_id", pollId))
+
+	// The votes of write-ins that are held for moderation aren't shown until they're approved
+	if !pollcreate.IsShown(voteCountedDetail.DynamoDb.NewImage.WriteInStatus.S) {
+		log.Printf("Dropping vote count of hidden write-in %s\n", voteCountedDetail.DynamoDb.NewImage.OptionId.S)
+		return
+	}
+
+	version, err := parseVersion(voteCountedDetail)
 	if err != nil {
 		log.Printf("Error: %s\n", err)
 		return
 	}
 
-	pollId := stripPrefix(voteCountedDetail.DynamoDb.NewImage.PollId.S, "poll|")
+	stale, err := isStale(
+		ctx,
+		ddb,
+		stripPrefix(voteCountedDetail.DynamoDb.NewImage.OptionId.S, "option|"),
+		version,
+	)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	if stale {
+		log.Printf("Dropping stale vote count for poll %s at version %d\n", pollId, version)
+		return
+	}
 
-	payload, err := json.Marshal(Payload{
-		Type: "voteCounted",
-		Data: VoteCountedPayloadData{
-			OptionId: stripPrefix(voteCountedDetail.DynamoDb.NewImage.OptionId.S, "option|"),
-			PollId:   pollId,
-			VotedAt:  voteCountedDetail.DynamoDb.NewImage.UpdatedAt.S,
-			Votes:    votes,
-		},
-	})
+	payload, err := newPayload(ctx, voteCountedDetail)
 	if err != nil {
 		log.Printf("Error: %s\n", err)
 		return
@@ -121,5 +497,23 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 }
 
 func main() {
-	lambda.Start(handler)
+	if _, err := tracing.NewTracerProvider(context.Background(), "pseudopoll-vote-count-publisher"); err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
+	switch publishMode := os.Getenv("PUBLISH_MODE"); publishMode {
+	case "", PublishModeSingle:
+		lambda.Start(handler)
+	case PublishModeBatch:
+		maxPublishRate, err := getMaxPublishRate()
+		if err != nil {
+			log.Fatalf("Error: %s\n", err)
+		}
+
+		limiter = newRateLimiter(maxPublishRate)
+
+		lambda.Start(batchHandler)
+	default:
+		log.Fatalf("Error: unsupported PUBLISH_MODE: %s\n", publishMode)
+	}
 }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol/protocoltest"
+)
+
+const voteCountedDetail = `{
+	"dynamodb": {
+		"NewImage": {
+			"PK": {"S": "option|option123"},
+			"SK": {"S": "option|option123"},
+			"GSI1PK": {"S": "poll|poll123"},
+			"GSI1SK": {"S": "poll|poll123"},
+			"Index": {"N": "0"},
+			"Text": {"S": "Yes"},
+			"UpdatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Votes": {"N": "3"},
+			"Version": {"N": "7"},
+			"TraceParent": {"S": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}
+		}
+	}
+}`
+
+func TestNewPayload(t *testing.T) {
+	var detail VoteCountedDetail
+	if err := json.Unmarshal([]byte(voteCountedDetail), &detail); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := newPayload(context.Background(), detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+
+	var envelope protocol.Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Sequence != 7 {
+		t.Errorf("expected sequence 7, got %d", envelope.Sequence)
+	}
+}
+
+func newRecord(pollId string, optionId string, votes int, version int) VoteCountedDetail {
+	var record VoteCountedDetail
+	record.DynamoDb.NewImage.PollId.S = "poll|" + pollId
+	record.DynamoDb.NewImage.OptionId.S = "option|" + optionId
+	record.DynamoDb.NewImage.UpdatedAt.S = "2024-01-01T00:00:00.000Z"
+	record.DynamoDb.NewImage.Votes.N = strconv.Itoa(votes)
+	record.DynamoDb.NewImage.Version.N = strconv.Itoa(version)
+	record.DynamoDb.SequenceNumber = strconv.Itoa(version)
+
+	return record
+}
+
+func TestCoalesce(t *testing.T) {
+	batches := coalesce(context.Background(), []VoteCountedDetail{
+		newRecord("poll123", "yes", 1, 1),
+		newRecord("poll456", "left", 1, 1),
+		newRecord("poll123", "yes", 3, 3),
+		newRecord("poll123", "no", 1, 2),
+		newRecord("poll123", "yes", 2, 2),
+	})
+
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 polls, got %d", len(batches))
+	}
+
+	batch := batches[0]
+	if batch.pollId != "poll123" {
+		t.Fatalf("expected poll123 first, got %s", batch.pollId)
+	}
+
+	if votes := batch.options["yes"].DynamoDb.NewImage.Votes.N; votes != "3" {
+		t.Errorf("expected the latest count of 3 for yes, got %s", votes)
+	}
+
+	if len(batch.options) != 2 || len(batch.records) != 4 || len(batch.sequences) != 4 {
+		t.Errorf(
+			"expected 2 options from 4 records, got %d options, %d records and %d sequences",
+			len(batch.options),
+			len(batch.records),
+			len(batch.sequences),
+		)
+	}
+
+	payload, err := newVotesCountedPayload(context.Background(), batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+
+	var envelope protocol.Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	if envelope.Type != protocol.TypeVotesCounted || envelope.Sequence != 3 {
+		t.Errorf("expected a votesCounted message at sequence 3, got %s at %d", envelope.Type, envelope.Sequence)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(20)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(ctx, "poll/poll123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := limiter.Wait(ctx, "poll/poll456"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three publishes to the same topic at 20 per second span two 50ms intervals, and other topics aren't held up
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 150*time.Millisecond {
+		t.Errorf("expected publishes to take 100ms, took %s", elapsed)
+	}
+}
+
+func TestRateLimiterDeadline(t *testing.T) {
+	limiter := newRateLimiter(0.1)
+
+	if err := limiter.Wait(context.Background(), "poll/poll123"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), PublishTimeout+time.Second)
+	defer cancel()
+
+	// The next publish to the topic is 10 seconds away, well past the deadline, so it isn't waited for
+	start := time.Now()
+	if err := limiter.Wait(ctx, "poll/poll123"); !errors.Is(err, errRateLimited) {
+		t.Errorf("expected the publish to be rate limited, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected the rate limited publish to return straight away, took %s", elapsed)
+	}
+
+	if err := limiter.Wait(ctx, "poll/poll456"); err != nil {
+		t.Errorf("expected other topics to be published to, got %s", err)
+	}
+}
+
+func TestCoalesceHiddenWriteIns(t *testing.T) {
+	pending := newRecord("poll123", "other", 2, 2)
+	pending.DynamoDb.NewImage.WriteInStatus.S = "pending"
+	approved := newRecord("poll123", "another", 1, 3)
+	approved.DynamoDb.NewImage.WriteInStatus.S = "approved"
+
+	batches := coalesce(context.Background(), []VoteCountedDetail{
+		newRecord("poll123", "yes", 1, 1),
+		pending,
+		approved,
+	})
+
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 poll, got %d", len(batches))
+	}
+	if _, ok := batches[0].options["other"]; ok {
+		t.Error("expected the votes of the pending write-in not to be published")
+	}
+	if _, ok := batches[0].options["another"]; !ok {
+		t.Error("expected the votes of the approved write-in to be published")
+	}
+}
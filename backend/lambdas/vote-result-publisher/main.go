@@ -12,6 +12,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/declanlscott/pseudopoll/backend/shared/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type VoteSucceededDetail struct {
@@ -29,33 +32,23 @@ type VoteSucceededDetail struct {
 			VoteId struct {
 				S string `json:"S"`
 			} `json:"VoteId"`
+			TraceParent struct {
+				S string `json:"S"`
+			} `json:"TraceParent"`
+			TraceState struct {
+				S string `json:"S"`
+			} `json:"TraceState"`
 		} `json:"NewImage"`
 	} `json:"dynamodb"`
 }
 
 type VoteFailedDetail struct {
-	RequestId string `json:"requestId"`
-	Error     string `json:"error"`
-	PollId    string `json:"pollId"`
-	OptionId  string `json:"optionId"`
-}
-
-type Payload struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
-}
-
-type VoteSucceededPayloadData struct {
-	VoterId  string `json:"voterId"`
-	PollId   string `json:"pollId"`
-	OptionId string `json:"optionId"`
-	VoteId   string `json:"voteId"`
-}
-
-type VoteFailedPayloadData struct {
-	Error    string `json:"error"`
-	PollId   string `json:"pollId"`
-	OptionId string `json:"optionId"`
+	RequestId   string `json:"requestId"`
+	Error       string `json:"error"`
+	PollId      string `json:"pollId"`
+	OptionId    string `json:"optionId"`
+	TraceParent string `json:"traceparent"`
+	TraceState  string `json:"tracestate"`
 }
 
 func stripPrefix(s string, prefix string) string {
@@ -66,7 +59,38 @@ func stripPrefix(s string, prefix string) string {
 	return s
 }
 
+// newPayload wraps data in a message of the given type, carrying the trace context of ctx
+func newPayload(ctx context.Context, messageType string, data interface{}) ([]byte, error) {
+	envelope, err := protocol.NewEnvelope(messageType, 0, data)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.TraceParent, envelope.TraceState = tracing.Inject(ctx)
+
+	return json.Marshal(envelope)
+}
+
+func newVoteSucceededPayload(ctx context.Context, voteSucceededDetail VoteSucceededDetail) ([]byte, error) {
+	return newPayload(ctx, protocol.TypeVoteSucceeded, protocol.VoteSucceededData{
+		VoterId:  stripPrefix(voteSucceededDetail.DynamoDb.NewImage.PkVoterId.S, "voter|"),
+		PollId:   stripPrefix(voteSucceededDetail.DynamoDb.NewImage.SkPollId.S, "poll|"),
+		OptionId: voteSucceededDetail.DynamoDb.NewImage.OptionId.S,
+		VoteId:   voteSucceededDetail.DynamoDb.NewImage.VoteId.S,
+	})
+}
+
+func newVoteFailedPayload(ctx context.Context, voteFailedDetail VoteFailedDetail) ([]byte, error) {
+	return newPayload(ctx, protocol.TypeVoteFailed, protocol.VoteFailedData{
+		Error:    voteFailedDetail.Error,
+		PollId:   voteFailedDetail.PollId,
+		OptionId: voteFailedDetail.OptionId,
+	})
+}
+
 func handler(ctx context.Context, event events.CloudWatchEvent) {
+	defer tracing.ForceFlush(ctx)
+
 	log.Printf("Processing event: %s\n", event)
 
 	cfg, err := config.LoadDefaultConfig(ctx)
@@ -74,6 +98,9 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		log.Printf("Error: %s\n", err)
 		return
 	}
+	tracing.InstrumentConfig(&cfg)
+
+	tracer := tracing.Tracer("vote-result-publisher")
 
 	iot := iotdataplane.NewFromConfig(cfg)
 
@@ -85,15 +112,18 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		}
 		log.Printf("Vote succeeded: %s\n", voteSucceededDetail.DynamoDb.NewImage.VoteId.S)
 
-		payload, err := json.Marshal(Payload{
-			Type: "voteSucceeded",
-			Data: VoteSucceededPayloadData{
-				VoterId:  stripPrefix(voteSucceededDetail.DynamoDb.NewImage.PkVoterId.S, "voter|"),
-				PollId:   stripPrefix(voteSucceededDetail.DynamoDb.NewImage.SkPollId.S, "poll|"),
-				OptionId: voteSucceededDetail.DynamoDb.NewImage.OptionId.S,
-				VoteId:   voteSucceededDetail.DynamoDb.NewImage.VoteId.S,
-			},
-		})
+		ctx, span := tracer.Start(
+			tracing.Extract(
+				ctx,
+				voteSucceededDetail.DynamoDb.NewImage.TraceParent.S,
+				voteSucceededDetail.DynamoDb.NewImage.TraceState.S,
+			),
+			"publish voteSucceeded",
+			trace.WithSpanKind(trace.SpanKindProducer),
+		)
+		defer span.End()
+
+		payload, err := newVoteSucceededPayload(ctx, voteSucceededDetail)
 		if err != nil {
 			log.Printf("Error: %s\n", err)
 			return
@@ -120,14 +150,14 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 		}
 		log.Printf("Vote failed: %s\n", voteFailedDetail.RequestId)
 
-		payload, err := json.Marshal(Payload{
-			Type: "voteFailed",
-			Data: VoteFailedPayloadData{
-				Error:    voteFailedDetail.Error,
-				PollId:   voteFailedDetail.PollId,
-				OptionId: voteFailedDetail.OptionId,
-			},
-		})
+		ctx, span := tracer.Start(
+			tracing.Extract(ctx, voteFailedDetail.TraceParent, voteFailedDetail.TraceState),
+			"publish voteFailed",
+			trace.WithSpanKind(trace.SpanKindProducer),
+		)
+		defer span.End()
+
+		payload, err := newVoteFailedPayload(ctx, voteFailedDetail)
 		if err != nil {
 			log.Printf("Error: %s\n", err)
 			return
@@ -151,5 +181,9 @@ func handler(ctx context.Context, event events.CloudWatchEvent) {
 }
 
 func main() {
+	if _, err := tracing.NewTracerProvider(context.Background(), "pseudopoll-vote-result-publisher"); err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
 	lambda.Start(handler)
 }
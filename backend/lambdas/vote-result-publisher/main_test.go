@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol/protocoltest"
+)
+
+const voteSucceededDetail = `{
+	"dynamodb": {
+		"NewImage": {
+			"PK": {"S": "voter|user123"},
+			"SK": {"S": "poll|poll123"},
+			"OptionId": {"S": "option123"},
+			"VoteId": {"S": "request123"}
+		}
+	}
+}`
+
+const voteFailedDetail = `{
+	"requestId": "request123",
+	"error": "poll is closed",
+	"pollId": "poll123",
+	"optionId": "option123",
+	"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+}`
+
+func TestNewVoteSucceededPayload(t *testing.T) {
+	var detail VoteSucceededDetail
+	if err := json.Unmarshal([]byte(voteSucceededDetail), &detail); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := newVoteSucceededPayload(context.Background(), detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+}
+
+func TestNewVoteFailedPayload(t *testing.T) {
+	var detail VoteFailedDetail
+	if err := json.Unmarshal([]byte(voteFailedDetail), &detail); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := newVoteFailedPayload(context.Background(), detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protocoltest.AssertValid(t, payload)
+}
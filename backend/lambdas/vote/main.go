@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -19,6 +21,13 @@ import (
 	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
 	ebTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollsequence"
+	"github.com/declanlscott/pseudopoll/backend/shared/tracing"
+	nanoid "github.com/matoous/go-nanoid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MessageBody struct {
@@ -28,6 +37,10 @@ type MessageBody struct {
 	UserIp           string `json:"userIp"`
 	RequestTimeEpoch string `json:"requestTimeEpoch"`
 	RequestId        string `json:"requestId"`
+	TraceParent      string `json:"traceparent"`
+	TraceState       string `json:"tracestate"`
+	WriteIn          string `json:"writeIn"`
+	Groups           string `json:"groups"`
 }
 
 type DdbPoll struct {
@@ -39,34 +52,122 @@ type DdbPoll struct {
 	CreatedAt    string `dynamodbav:"CreatedAt"`
 	Duration     int64  `dynamodbav:"Duration"`
 	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+	DeletedAt    string `dynamodbav:"DeletedAt"`
+
+	AllowWriteIns    bool                    `dynamodbav:"AllowWriteIns"`
+	ModerateWriteIns bool                    `dynamodbav:"ModerateWriteIns"`
+	VoteWeights      *pollcreate.VoteWeights `dynamodbav:"VoteWeights"`
+
+	// LastOptionIndex is missing on polls created before options were counted on the poll
+	LastOptionIndex *int `dynamodbav:"LastOptionIndex"`
+}
+
+type DdbOption struct {
+	PkOptionId    string `dynamodbav:"PK"`
+	Index         int    `dynamodbav:"Index"`
+	Text          string `dynamodbav:"Text"`
+	WriteInStatus string `dynamodbav:"WriteInStatus"`
+}
+
+// DdbWriteIn maps the text of a written in option to the option, under the option's poll
+type DdbWriteIn struct {
+	PkPollId    string `dynamodbav:"PK"`
+	SkWriteInId string `dynamodbav:"SK"`
+	OptionId    string `dynamodbav:"OptionId"`
 }
 
+// DdbAddedOption is recorded on the poll when an option is added to it, as it's shown to voters
+type DdbAddedOption struct {
+	OptionId string `dynamodbav:"OptionId"`
+	Text     string `dynamodbav:"Text"`
+	Votes    int    `dynamodbav:"Votes"`
+	AddedAt  string `dynamodbav:"AddedAt"`
+}
+
+// WriteIn is the option that a written in text is voted for as. Existing ones are already the poll's.
+type WriteIn struct {
+	OptionId string
+	Text     string
+	Index    int
+	Status   string
+	Existing bool
+}
+
+// DdbVote is indexed by its poll in GSI1, so a poll's votes can be found without a scan. Its weight is what
+// it added to its option's votes, and VotedAt the time its timeline buckets were picked by.
 type DdbVote struct {
-	PkVoterId string `dynamodbav:"PK"`
-	SkPollId  string `dynamodbav:"SK"`
-	OptionId  string `dynamodbav:"OptionId"`
-	VoteId    string `dynamodbav:"VoteId"`
+	PkVoterId     string `dynamodbav:"PK"`
+	SkPollId      string `dynamodbav:"SK"`
+	Gsi1PkPollId  string `dynamodbav:"GSI1PK"`
+	Gsi1SkVoterId string `dynamodbav:"GSI1SK"`
+	OptionId      string `dynamodbav:"OptionId"`
+	VoteId        string `dynamodbav:"VoteId"`
+	Weight        int    `dynamodbav:"Weight"`
+	VotedAt       string `dynamodbav:"VotedAt"`
+	TraceParent   string `dynamodbav:"TraceParent,omitempty"`
+	TraceState    string `dynamodbav:"TraceState,omitempty"`
+}
+
+// TimelineBucket is a width of the vote timeline's buckets. Each vote is counted in a bucket of each width.
+type TimelineBucket struct {
+	Name string
+	Size time.Duration
 }
 
 type VoteFailedDetail = struct {
-	RequestId string `json:"requestId"`
-	Error     string `json:"error"`
-	PollId    string `json:"pollId"`
-	OptionId  string `json:"optionId"`
+	RequestId   string `json:"requestId"`
+	Error       string `json:"error"`
+	PollId      string `json:"pollId"`
+	OptionId    string `json:"optionId"`
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
 }
 
 const (
-	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+	RFC3339Milli         = "2006-01-02T15:04:05.999Z07:00"
+	maxCountVoteAttempts = 5
+	maxTransactAttempts  = 8
+	transactBackoff      = 25 * time.Millisecond
+	maxTransactBackoff   = time.Second
+	DefaultMaxWriteIns   = 20
+)
+
+var (
+	errAlreadyVoted   = errors.New("voter has already voted on the poll")
+	errWriteInWritten = errors.New("the option was written in by another voter while counting the vote")
+	errIndexTaken     = errors.New("another option was written in at the option's index while counting the vote")
 )
 
+var timelineBuckets = []TimelineBucket{
+	{Name: "1m", Size: time.Minute},
+	{Name: "1h", Size: time.Hour},
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
 func handleFailure(ctx context.Context, err error, messageBody MessageBody, ebClient *eventbridge.Client) {
 	log.Printf("Error: %s\n", err)
 
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	traceParent, traceState := tracing.Inject(ctx)
+
 	detail := VoteFailedDetail{
-		RequestId: messageBody.RequestId,
-		Error:     err.Error(),
-		PollId:    messageBody.PollId,
-		OptionId:  messageBody.OptionId,
+		RequestId:   messageBody.RequestId,
+		Error:       err.Error(),
+		PollId:      messageBody.PollId,
+		OptionId:    messageBody.OptionId,
+		TraceParent: traceParent,
+		TraceState:  traceState,
 	}
 	detailJson, err := json.Marshal(detail)
 	if err != nil {
@@ -92,168 +193,911 @@ func handleFailure(ctx context.Context, err error, messageBody MessageBody, ebCl
 	}
 }
 
-func handler(ctx context.Context, event events.SQSEvent) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		log.Printf("Error: %s\n", err)
-		return
+// newTimelineUpdates counts the vote, with its weight, in the option's bucket of each width. The buckets share the poll's
+// partition, so the poll deleter deletes them with it, and they're sorted by when they start, so a bucket width's
+// timeline is a single query. They don't copy the poll's time to live, which changes with the poll's close time.
+func newTimelineUpdates(
+	tableName string,
+	pollId string,
+	optionId string,
+	weight int,
+	requestTime time.Time,
+) []ddbTypes.TransactWriteItem {
+	updateExpression := "ADD #votes :vote SET #optionId = :optionId, #bucketStart = :bucketStart"
+
+	transactItems := make([]ddbTypes.TransactWriteItem, 0, len(timelineBuckets))
+	for _, bucket := range timelineBuckets {
+		bucketStart := requestTime.UTC().Truncate(bucket.Size).Format(RFC3339Milli)
+
+		expressionAttributeNames := map[string]string{
+			"#votes":       "Votes",
+			"#optionId":    "OptionId",
+			"#bucketStart": "BucketStart",
+		}
+		expressionAttributeValues := map[string]ddbTypes.AttributeValue{
+			":vote": &ddbTypes.AttributeValueMemberN{
+				Value: strconv.Itoa(weight),
+			},
+			":optionId": &ddbTypes.AttributeValueMemberS{
+				Value: optionId,
+			},
+			":bucketStart": &ddbTypes.AttributeValueMemberS{
+				Value: bucketStart,
+			},
+		}
+
+		transactItems = append(transactItems, ddbTypes.TransactWriteItem{
+			Update: &ddbTypes.Update{
+				TableName: aws.String(tableName),
+				Key: map[string]ddbTypes.AttributeValue{
+					"PK": &ddbTypes.AttributeValueMemberS{
+						Value: fmt.Sprintf("poll|%s", pollId),
+					},
+					"SK": &ddbTypes.AttributeValueMemberS{
+						Value: fmt.Sprintf("timeline|%s|%s|%s", bucket.Name, bucketStart, optionId),
+					},
+				},
+				UpdateExpression:          aws.String(updateExpression),
+				ExpressionAttributeNames:  expressionAttributeNames,
+				ExpressionAttributeValues: expressionAttributeValues,
+			},
+		})
 	}
 
-	ddb := dynamodb.NewFromConfig(cfg)
-	ebClient := eventbridge.NewFromConfig(cfg)
+	return transactItems
+}
 
-	var messageBody MessageBody
+// getPoll reads the poll consistently, so a vote is never counted on a poll that was just closed or archived
+func getPoll(ctx context.Context, ddb *dynamodb.Client, pollId string) (DdbPoll, error) {
 	var ddbPoll DdbPoll
-	var voterId string
-	for _, record := range event.Records {
-		log.Printf("Processing message: %s\n", record.Body)
 
-		if err := json.Unmarshal([]byte(record.Body), &messageBody); err != nil {
-			log.Printf("Error: %s\n", err)
-			continue
-		}
+	getPoll, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return DdbPoll{}, err
+	}
 
-		if messageBody.UserId != "" {
-			voterId = messageBody.UserId
-		} else if messageBody.UserIp != "" {
-			voterId = messageBody.UserIp
-		} else {
-			handleFailure(
-				ctx,
-				errors.New("message must contain either userId or userIp"),
-				messageBody,
-				ebClient,
-			)
-			continue
-		}
+	err = attributevalue.UnmarshalMap(getPoll.Item, &ddbPoll)
+	if err != nil {
+		return DdbPoll{}, err
+	}
+
+	return ddbPoll, nil
+}
+
+// checkPoll returns why the poll can't be voted on at the request time, if it can't
+func checkPoll(ddbPoll DdbPoll, requestTime time.Time) error {
+	if ddbPoll.DeletedAt != "" {
+		return errors.New(fmt.Sprintf("poll %s was deleted", ddbPoll.PkPollId))
+	}
+
+	if ddbPoll.IsArchived {
+		return errors.New(fmt.Sprintf("poll %s is archived", ddbPoll.PkPollId))
+	}
+
+	createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	expirationTime := createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second)
+	if requestTime.After(expirationTime) {
+		return errors.New(fmt.Sprintf("poll %s has expired", ddbPoll.PkPollId))
+	}
+
+	return nil
+}
+
+// hasVoted reports whether the voter already voted on the poll. The vote's put is conditioned on it too, but
+// checking first keeps a repeated vote from taking a sequence number that's never published.
+func hasVoted(ctx context.Context, ddb *dynamodb.Client, pollId string, voterId string) (bool, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("voter|%s", voterId),
+			},
+			"SK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+		ProjectionExpression: aws.String("#pk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.Item != nil, nil
+}
+
+// newVotePut records the voter's vote for the option, which fails if they've already voted on the poll
+func newVotePut(
+	tableName string,
+	pollId string,
+	optionId string,
+	voterId string,
+	voteId string,
+	weight int,
+	requestTime time.Time,
+	traceParent string,
+	traceState string,
+) (ddbTypes.TransactWriteItem, error) {
+	item, err := attributevalue.MarshalMap(DdbVote{
+		PkVoterId:     fmt.Sprintf("voter|%s", voterId),
+		SkPollId:      fmt.Sprintf("poll|%s", pollId),
+		Gsi1PkPollId:  fmt.Sprintf("votes|%s", pollId),
+		Gsi1SkVoterId: fmt.Sprintf("voter|%s", voterId),
+		OptionId:      optionId,
+		VoteId:        voteId,
+		Weight:        weight,
+		VotedAt:       requestTime.UTC().Format(RFC3339Milli),
+		TraceParent:   traceParent,
+		TraceState:    traceState,
+	})
+	if err != nil {
+		return ddbTypes.TransactWriteItem{}, err
+	}
 
-		requestTimeEpoch, err := strconv.ParseInt(messageBody.RequestTimeEpoch, 10, 64)
+	return ddbTypes.TransactWriteItem{
+		Put: &ddbTypes.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(#voter) AND attribute_not_exists(#poll)"),
+			ExpressionAttributeNames: map[string]string{
+				"#voter": "PK",
+				"#poll":  "SK",
+			},
+		},
+	}, nil
+}
+
+// getWeight is how much the voter's vote counts for in the poll, by the voter or the groups that their token
+// puts them in. Voters that the poll weighs at 0 aren't eligible to vote in it. Anonymous voters don't have
+// a token, so they're never in a group.
+func getWeight(ddbPoll DdbPoll, messageBody MessageBody) (int, error) {
+	var groups []string
+	if messageBody.UserId != "" && messageBody.Groups != "" {
+		groups = strings.Split(messageBody.Groups, ",")
+	}
+
+	weight := ddbPoll.VoteWeights.Weight(messageBody.UserId, groups)
+	if weight < 1 {
+		return 0, fmt.Errorf("voter isn't eligible to vote in poll %s", ddbPoll.PkPollId)
+	}
+
+	return weight, nil
+}
+
+// newPollUpdate moves the poll to the sequence number of a write-in, which changes its options, so an edit of
+// the poll can't interleave with it. The written in option's index becomes the poll's last, on the condition
+// that no other write-in took it first. An approved option is recorded on the poll, so its stream record
+// announces the option. Counting a vote for an option the poll already has leaves the poll's item alone.
+func newPollUpdate(
+	tableName string,
+	ddbPoll DdbPoll,
+	sequence int64,
+	optionIndex int,
+	addedOption *DdbAddedOption,
+) (ddbTypes.TransactWriteItem, error) {
+	updateExpression := "SET #version = :version, #lastOptionIndex = :optionIndex"
+	expressionAttributeNames := map[string]string{
+		"#pk":              "PK",
+		"#version":         "Version",
+		"#lastOptionIndex": "LastOptionIndex",
+	}
+	expressionAttributeValues := map[string]ddbTypes.AttributeValue{
+		":version": &ddbTypes.AttributeValueMemberN{
+			Value: strconv.FormatInt(sequence, 10),
+		},
+		":optionIndex": &ddbTypes.AttributeValueMemberN{
+			Value: strconv.Itoa(optionIndex),
+		},
+	}
+	if addedOption != nil {
+		addedOptionValue, err := attributevalue.Marshal(addedOption)
 		if err != nil {
-			handleFailure(ctx, err, messageBody, ebClient)
-			continue
+			return ddbTypes.TransactWriteItem{}, err
 		}
-		requestTime := time.UnixMilli(requestTimeEpoch)
 
-		getPoll, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
-			TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		updateExpression += ", #addedOption = :addedOption"
+		expressionAttributeNames["#addedOption"] = "AddedOption"
+		expressionAttributeValues[":addedOption"] = addedOptionValue
+	}
+
+	return ddbTypes.TransactWriteItem{
+		Update: &ddbTypes.Update{
+			TableName: aws.String(tableName),
 			Key: map[string]ddbTypes.AttributeValue{
 				"PK": &ddbTypes.AttributeValueMemberS{
-					Value: fmt.Sprintf("poll|%s", messageBody.PollId),
+					Value: ddbPoll.PkPollId,
 				},
 				"SK": &ddbTypes.AttributeValueMemberS{
-					Value: fmt.Sprintf("poll|%s", messageBody.PollId),
+					Value: ddbPoll.PkPollId,
 				},
 			},
+			ConditionExpression: aws.String(
+				"attribute_exists(#pk) AND (attribute_not_exists(#lastOptionIndex) OR #lastOptionIndex < :optionIndex)",
+			),
+			UpdateExpression:          aws.String(updateExpression),
+			ExpressionAttributeNames:  expressionAttributeNames,
+			ExpressionAttributeValues: expressionAttributeValues,
+		},
+	}, nil
+}
+
+// isConditionFailed reports whether the transaction was canceled because the condition of one of the items at
+// the given indexes failed
+func isConditionFailed(err error, transactItems []ddbTypes.TransactWriteItem, indexes ...int) bool {
+	var transactionCanceled *ddbTypes.TransactionCanceledException
+	if !errors.As(err, &transactionCanceled) || len(transactionCanceled.CancellationReasons) != len(transactItems) {
+		return false
+	}
+
+	for _, index := range indexes {
+		if aws.ToString(transactionCanceled.CancellationReasons[index].Code) == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isConflict reports whether the transaction was canceled because another transaction was writing one of its
+// items, as concurrent votes for an option do
+func isConflict(err error) bool {
+	var transactionCanceled *ddbTypes.TransactionCanceledException
+	if !errors.As(err, &transactionCanceled) {
+		return false
+	}
+
+	for _, reason := range transactionCanceled.CancellationReasons {
+		if aws.ToString(reason.Code) == "TransactionConflict" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getBackoff is how long to wait before the attempt, with full jitter so that votes conflicting with each other
+// don't retry in lockstep
+func getBackoff(attempt int) time.Duration {
+	backoff := transactBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > maxTransactBackoff {
+		backoff = maxTransactBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// transactWriteItems writes the items in a single transaction, retrying it with backoff while it conflicts with
+// another one. The items don't depend on what was read before, so a retry writes them as they are.
+func transactWriteItems(ctx context.Context, ddb *dynamodb.Client, transactItems []ddbTypes.TransactWriteItem) error {
+	for attempt := 1; ; attempt++ {
+		_, err := ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
 		})
-		if err != nil {
-			handleFailure(ctx, err, messageBody, ebClient)
-			continue
+		if !isConflict(err) || attempt == maxTransactAttempts {
+			return err
+		}
+
+		backoff := getBackoff(attempt)
+		log.Printf("Retrying conflicting transaction in %s after attempt %d: %s\n", backoff, attempt, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
 		}
+	}
+}
+
+// countVote records the vote and increments the option's votes by its weight, its ballots and its timeline buckets
+// in a single transaction, stamping the option with the poll's next sequence number. Nothing in it depends on the
+// poll's version, so concurrent votes on the poll don't invalidate each other. Rejected write-ins can't be voted for.
+func countVote(
+	ctx context.Context,
+	messageBody MessageBody,
+	voterId string,
+	requestTime time.Time,
+	ddb *dynamodb.Client,
+) error {
+	ddbPoll, err := getPoll(ctx, ddb, messageBody.PollId)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPoll(ddbPoll, requestTime); err != nil {
+		return err
+	}
+
+	weight, err := getWeight(ddbPoll, messageBody)
+	if err != nil {
+		return err
+	}
+
+	voted, err := hasVoted(ctx, ddb, messageBody.PollId, voterId)
+	if err != nil {
+		return err
+	}
+	if voted {
+		return errAlreadyVoted
+	}
+
+	sequence, err := pollsequence.Next(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		messageBody.PollId,
+		ddbPoll.Version,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Both items carry the trace context so the stream consumers can continue the trace
+	traceParent, traceState := tracing.Inject(ctx)
+
+	votePut, err := newVotePut(
+		os.Getenv("SINGLE_TABLE_NAME"),
+		messageBody.PollId,
+		messageBody.OptionId,
+		voterId,
+		messageBody.RequestId,
+		weight,
+		requestTime,
+		traceParent,
+		traceState,
+	)
+	if err != nil {
+		return err
+	}
+
+	transactItems := []ddbTypes.TransactWriteItem{
+		votePut,
+		{
+			Update: &ddbTypes.Update{
+				TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+				Key: map[string]ddbTypes.AttributeValue{
+					"PK": &ddbTypes.AttributeValueMemberS{
+						Value: fmt.Sprintf("option|%s", messageBody.OptionId),
+					},
+					"SK": &ddbTypes.AttributeValueMemberS{
+						Value: fmt.Sprintf("option|%s", messageBody.OptionId),
+					},
+				},
+				ConditionExpression: aws.String(
+					"#poll = :poll AND (attribute_not_exists(#writeInStatus) OR #writeInStatus <> :rejected)",
+				),
+				// Options counted before votes were weighed have no ballots, when they had as many ballots as votes
+				UpdateExpression: aws.String(
+					"SET #votes = #votes + :vote, #ballots = if_not_exists(#ballots, #votes) + :ballot, " +
+						"#updatedAt = :updatedAt, #version = :version, #traceParent = :traceParent, #traceState = :traceState",
+				),
+				ExpressionAttributeNames: map[string]string{
+					"#poll":          "GSI1PK",
+					"#writeInStatus": "WriteInStatus",
+					"#votes":         "Votes",
+					"#ballots":       "Ballots",
+					"#updatedAt":     "UpdatedAt",
+					"#version":       "Version",
+					"#traceParent":   "TraceParent",
+					"#traceState":    "TraceState",
+				},
+				ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+					":poll": &ddbTypes.AttributeValueMemberS{
+						Value: fmt.Sprintf("poll|%s", messageBody.PollId),
+					},
+					":rejected": &ddbTypes.AttributeValueMemberS{
+						Value: pollcreate.WriteInStatusRejected,
+					},
+					":vote": &ddbTypes.AttributeValueMemberN{
+						Value: strconv.Itoa(weight),
+					},
+					":ballot": &ddbTypes.AttributeValueMemberN{
+						Value: "1",
+					},
+					":updatedAt": &ddbTypes.AttributeValueMemberS{
+						Value: requestTime.Format(RFC3339Milli),
+					},
+					":version": &ddbTypes.AttributeValueMemberN{
+						Value: strconv.FormatInt(sequence, 10),
+					},
+					":traceParent": &ddbTypes.AttributeValueMemberS{
+						Value: traceParent,
+					},
+					":traceState": &ddbTypes.AttributeValueMemberS{
+						Value: traceState,
+					},
+				},
+			},
+		},
+	}
+	transactItems = append(
+		transactItems,
+		newTimelineUpdates(
+			os.Getenv("SINGLE_TABLE_NAME"),
+			messageBody.PollId,
+			messageBody.OptionId,
+			weight,
+			requestTime,
+		)...,
+	)
+
+	err = transactWriteItems(ctx, ddb, transactItems)
+	if isConditionFailed(err, transactItems, 0) {
+		return errAlreadyVoted
+	}
+
+	return err
+}
+
+// getWriteInOptionId returns the option that the text was already written in as, or "" if it wasn't
+func getWriteInOptionId(ctx context.Context, ddb *dynamodb.Client, pollId string, text string) (string, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Key: map[string]ddbTypes.AttributeValue{
+			"PK": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &ddbTypes.AttributeValueMemberS{
+				Value: pollcreate.WriteInKey(text),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var ddbWriteIn DdbWriteIn
+	if err := attributevalue.UnmarshalMap(result.Item, &ddbWriteIn); err != nil {
+		return "", err
+	}
 
-		err = attributevalue.UnmarshalMap(getPoll.Item, &ddbPoll)
+	return ddbWriteIn.OptionId, nil
+}
+
+func getOptions(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbOption, error) {
+	var ddbOptions []DdbOption
+
+	paginator := dynamodb.NewQueryPaginator(ddb, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("#poll = :poll"),
+		ExpressionAttributeNames: map[string]string{
+			"#poll": "GSI1PK",
+		},
+		ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
+			":poll": &ddbTypes.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			handleFailure(ctx, err, messageBody, ebClient)
-			continue
+			return nil, err
 		}
 
-		if ddbPoll.IsArchived {
-			handleFailure(
-				ctx,
-				errors.New(fmt.Sprintf("poll %s is archived", ddbPoll.PkPollId)),
-				messageBody,
-				ebClient,
-			)
-			continue
+		var pageOptions []DdbOption
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageOptions); err != nil {
+			return nil, err
 		}
 
-		createdAt, err := time.Parse(RFC3339Milli, ddbPoll.CreatedAt)
-		if err != nil {
-			handleFailure(ctx, err, messageBody, ebClient)
+		ddbOptions = append(ddbOptions, pageOptions...)
+	}
+
+	return ddbOptions, nil
+}
+
+// newWriteIn matches the text against the poll's options, ignoring case, and returns the option it is
+// already, or the option it would be added as otherwise, after the poll's last option. Matching a rejected
+// write-in or going over the poll's write-ins is an error. Polls that don't count their options yet take the
+// index after their options'.
+func newWriteIn(ddbPoll DdbPoll, ddbOptions []DdbOption, text string, maxWriteIns int) (WriteIn, error) {
+	writeIn := WriteIn{
+		Text:   text,
+		Status: pollcreate.WriteInStatusApproved,
+	}
+	if ddbPoll.LastOptionIndex != nil {
+		writeIn.Index = *ddbPoll.LastOptionIndex + 1
+	}
+	if ddbPoll.ModerateWriteIns {
+		writeIn.Status = pollcreate.WriteInStatusPending
+	}
+
+	writeIns := 0
+	for _, ddbOption := range ddbOptions {
+		if !strings.HasPrefix(ddbOption.PkOptionId, "option|") {
 			continue
 		}
 
-		expirationTime := createdAt.Add(time.Duration(ddbPoll.Duration) * time.Second)
-		if requestTime.After(expirationTime) {
-			handleFailure(
-				ctx,
-				errors.New(fmt.Sprintf("poll %s has expired", ddbPoll.PkPollId)),
-				messageBody,
-				ebClient,
-			)
-			continue
+		if strings.EqualFold(strings.TrimSpace(ddbOption.Text), text) {
+			if ddbOption.WriteInStatus == pollcreate.WriteInStatusRejected {
+				return WriteIn{}, fmt.Errorf("option %q was rejected by the poll's owner", text)
+			}
+
+			return WriteIn{OptionId: stripPrefix(ddbOption.PkOptionId, "option|"), Existing: true}, nil
 		}
 
-		item, err := attributevalue.MarshalMap(DdbVote{
-			PkVoterId: fmt.Sprintf("voter|%s", voterId),
-			SkPollId:  fmt.Sprintf("poll|%s", messageBody.PollId),
-			OptionId:  messageBody.OptionId,
-			VoteId:    messageBody.RequestId,
-		})
-		if err != nil {
-			handleFailure(ctx, err, messageBody, ebClient)
-			continue
+		if ddbOption.WriteInStatus != "" {
+			writeIns++
+		}
+		if ddbOption.Index >= writeIn.Index {
+			writeIn.Index = ddbOption.Index + 1
 		}
+	}
 
-		_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
-			TransactItems: []ddbTypes.TransactWriteItem{
-				{
-					Put: &ddbTypes.Put{
-						TableName:           aws.String(os.Getenv("SINGLE_TABLE_NAME")),
-						Item:                item,
-						ConditionExpression: aws.String("attribute_not_exists(#voter) AND attribute_not_exists(#poll)"),
-						ExpressionAttributeNames: map[string]string{
-							"#voter": "PK",
-							"#poll":  "SK",
-						},
-					},
+	if writeIns >= maxWriteIns {
+		return WriteIn{}, fmt.Errorf("poll %s already has %d write-ins", ddbPoll.PkPollId, maxWriteIns)
+	}
+
+	return writeIn, nil
+}
+
+// newWriteInItems creates the written in option with the voter's vote already counted, and maps its text
+// to it, so the same text written in concurrently can't create a second option. Approved options are
+// recorded on the poll to be announced; pending ones wait for the owner.
+func newWriteInItems(
+	tableName string,
+	ddbPoll DdbPoll,
+	writeIn WriteIn,
+	votePut ddbTypes.TransactWriteItem,
+	weight int,
+	sequence int64,
+	requestTime time.Time,
+	traceParent string,
+	traceState string,
+) ([]ddbTypes.TransactWriteItem, error) {
+	updatedAt := requestTime.Format(RFC3339Milli)
+
+	optionItem, err := attributevalue.MarshalMap(pollcreate.DdbOption{
+		PkOptionId:    fmt.Sprintf("option|%s", writeIn.OptionId),
+		SkOptionId:    fmt.Sprintf("option|%s", writeIn.OptionId),
+		Gsi1PkPollId:  ddbPoll.PkPollId,
+		Gsi1SkPollId:  ddbPoll.PkPollId,
+		Index:         writeIn.Index,
+		Text:          writeIn.Text,
+		UpdatedAt:     updatedAt,
+		Votes:         weight,
+		Version:       sequence,
+		Ballots:       1,
+		WriteInStatus: writeIn.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	optionItem["TraceParent"] = &ddbTypes.AttributeValueMemberS{Value: traceParent}
+	optionItem["TraceState"] = &ddbTypes.AttributeValueMemberS{Value: traceState}
+
+	writeInItem, err := attributevalue.MarshalMap(DdbWriteIn{
+		PkPollId:    ddbPoll.PkPollId,
+		SkWriteInId: pollcreate.WriteInKey(writeIn.Text),
+		OptionId:    writeIn.OptionId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transactItems := []ddbTypes.TransactWriteItem{
+		votePut,
+		{
+			Put: &ddbTypes.Put{
+				TableName:           aws.String(tableName),
+				Item:                optionItem,
+				ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+				ExpressionAttributeNames: map[string]string{
+					"#pk": "PK",
 				},
-				{
-					Update: &ddbTypes.Update{
-						TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
-						Key: map[string]ddbTypes.AttributeValue{
-							"PK": &ddbTypes.AttributeValueMemberS{
-								Value: fmt.Sprintf("option|%s", messageBody.OptionId),
-							},
-							"SK": &ddbTypes.AttributeValueMemberS{
-								Value: fmt.Sprintf("option|%s", messageBody.OptionId),
-							},
-						},
-						ConditionExpression: aws.String("#poll = :poll"),
-						UpdateExpression:    aws.String("SET #votes = #votes + :vote, #updatedAt = :updatedAt"),
-						ExpressionAttributeNames: map[string]string{
-							"#poll":      "GSI1PK",
-							"#votes":     "Votes",
-							"#updatedAt": "UpdatedAt",
-						},
-						ExpressionAttributeValues: map[string]ddbTypes.AttributeValue{
-							":poll": &ddbTypes.AttributeValueMemberS{
-								Value: fmt.Sprintf("poll|%s", messageBody.PollId),
-							},
-							":vote": &ddbTypes.AttributeValueMemberN{
-								Value: "1",
-							},
-							":updatedAt": &ddbTypes.AttributeValueMemberS{
-								Value: requestTime.Format(RFC3339Milli),
-							},
-						},
-					},
+			},
+		},
+		{
+			Put: &ddbTypes.Put{
+				TableName:           aws.String(tableName),
+				Item:                writeInItem,
+				ConditionExpression: aws.String("attribute_not_exists(#sk)"),
+				ExpressionAttributeNames: map[string]string{
+					"#sk": "SK",
 				},
 			},
-		})
-		if err != nil {
-			handleFailure(ctx, err, messageBody, ebClient)
+		},
+	}
+
+	transactItems = append(
+		transactItems,
+		newTimelineUpdates(
+			tableName,
+			stripPrefix(ddbPoll.PkPollId, "poll|"),
+			writeIn.OptionId,
+			weight,
+			requestTime,
+		)...,
+	)
+
+	var addedOption *DdbAddedOption
+	if writeIn.Status == pollcreate.WriteInStatusApproved {
+		addedOption = &DdbAddedOption{
+			OptionId: writeIn.OptionId,
+			Text:     writeIn.Text,
+			Votes:    weight,
+			AddedAt:  updatedAt,
+		}
+	}
+
+	pollUpdate, err := newPollUpdate(tableName, ddbPoll, sequence, writeIn.Index, addedOption)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(transactItems, pollUpdate), nil
+}
+
+// countWriteIn votes for the option written in by the voter, creating it along with the vote unless the
+// poll already has it. errWriteInWritten means the same text was written in by another voter first, so
+// the vote goes to their option when it's retried. errIndexTaken means another text was written in first
+// at the same index, so the option is added after it when it's retried.
+func countWriteIn(
+	ctx context.Context,
+	messageBody MessageBody,
+	voterId string,
+	requestTime time.Time,
+	ddb *dynamodb.Client,
+) error {
+	ddbPoll, err := getPoll(ctx, ddb, messageBody.PollId)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPoll(ddbPoll, requestTime); err != nil {
+		return err
+	}
+
+	if !ddbPoll.AllowWriteIns {
+		return errors.New(fmt.Sprintf("poll %s doesn't allow write-ins", ddbPoll.PkPollId))
+	}
+
+	weight, err := getWeight(ddbPoll, messageBody)
+	if err != nil {
+		return err
+	}
+
+	limits, err := pollcreate.GetLimits()
+	if err != nil {
+		return err
+	}
+
+	text := strings.TrimSpace(messageBody.WriteIn)
+	if err := limits.ValidateOption(text); err != nil {
+		return err
+	}
+
+	optionId, err := getWriteInOptionId(ctx, ddb, messageBody.PollId, text)
+	if err != nil {
+		return err
+	}
+	if optionId != "" {
+		messageBody.OptionId = optionId
+		return countVote(ctx, messageBody, voterId, requestTime, ddb)
+	}
+
+	ddbOptions, err := getOptions(ctx, ddb, messageBody.PollId)
+	if err != nil {
+		return err
+	}
+
+	maxWriteIns, err := getMaxWriteIns()
+	if err != nil {
+		return err
+	}
+
+	writeIn, err := newWriteIn(ddbPoll, ddbOptions, text, maxWriteIns)
+	if err != nil {
+		return err
+	}
+	if writeIn.Existing {
+		messageBody.OptionId = writeIn.OptionId
+		return countVote(ctx, messageBody, voterId, requestTime, ddb)
+	}
+
+	nanoIdOptions, err := pollcreate.GetNanoIdOptions()
+	if err != nil {
+		return err
+	}
+
+	writeIn.OptionId, err = nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return err
+	}
+
+	voted, err := hasVoted(ctx, ddb, messageBody.PollId, voterId)
+	if err != nil {
+		return err
+	}
+	if voted {
+		return errAlreadyVoted
+	}
+
+	sequence, err := pollsequence.Next(
+		ctx,
+		ddb,
+		os.Getenv("SINGLE_TABLE_NAME"),
+		messageBody.PollId,
+		ddbPoll.Version,
+	)
+	if err != nil {
+		return err
+	}
+
+	traceParent, traceState := tracing.Inject(ctx)
+
+	votePut, err := newVotePut(
+		os.Getenv("SINGLE_TABLE_NAME"),
+		messageBody.PollId,
+		writeIn.OptionId,
+		voterId,
+		messageBody.RequestId,
+		weight,
+		requestTime,
+		traceParent,
+		traceState,
+	)
+	if err != nil {
+		return err
+	}
+
+	transactItems, err := newWriteInItems(
+		os.Getenv("SINGLE_TABLE_NAME"),
+		ddbPoll,
+		writeIn,
+		votePut,
+		weight,
+		sequence,
+		requestTime,
+		traceParent,
+		traceState,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = transactWriteItems(ctx, ddb, transactItems)
+	if isConditionFailed(err, transactItems, 0) {
+		return errAlreadyVoted
+	}
+	if isConditionFailed(err, transactItems, 2) {
+		return errWriteInWritten
+	}
+	if isConditionFailed(err, transactItems, len(transactItems)-1) {
+		return errIndexTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote in option %s (%s) on poll %s\n", writeIn.OptionId, writeIn.Status, messageBody.PollId)
+
+	return nil
+}
+
+func getMaxWriteIns() (int, error) {
+	maxWriteIns := os.Getenv("MAX_WRITE_INS")
+	if maxWriteIns == "" {
+		return DefaultMaxWriteIns, nil
+	}
+
+	return strconv.Atoi(maxWriteIns)
+}
+
+func vote(ctx context.Context, messageBody MessageBody, ddb *dynamodb.Client, ebClient *eventbridge.Client) {
+	var voterId string
+
+	if messageBody.UserId != "" {
+		voterId = messageBody.UserId
+	} else if messageBody.UserIp != "" {
+		voterId = messageBody.UserIp
+	} else {
+		handleFailure(
+			ctx,
+			errors.New("message must contain either userId or userIp"),
+			messageBody,
+			ebClient,
+		)
+		return
+	}
+
+	requestTimeEpoch, err := strconv.ParseInt(messageBody.RequestTimeEpoch, 10, 64)
+	if err != nil {
+		handleFailure(ctx, err, messageBody, ebClient)
+		return
+	}
+	requestTime := time.UnixMilli(requestTimeEpoch)
+
+	count := countVote
+	if messageBody.WriteIn != "" {
+		count = countWriteIn
+	}
+
+	// A write-in that loses the race to create its option is retried as a vote for the option that won, and one
+	// that loses the race for its index is retried after the option that won
+	for attempt := 1; ; attempt++ {
+		err = count(ctx, messageBody, voterId, requestTime, ddb)
+		if !(errors.Is(err, errWriteInWritten) || errors.Is(err, errIndexTaken)) || attempt == maxCountVoteAttempts {
+			break
+		}
+
+		log.Printf("Retrying vote on poll %s after attempt %d: %s\n", messageBody.PollId, attempt, err)
+	}
+	if err != nil {
+		handleFailure(ctx, err, messageBody, ebClient)
+		return
+	}
+
+	log.Printf(
+		"Successfully voted for option %s on poll %s by voter %s\n",
+		messageBody.OptionId,
+		messageBody.PollId,
+		voterId,
+	)
+}
+
+func handler(ctx context.Context, event events.SQSEvent) {
+	defer tracing.ForceFlush(ctx)
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+	tracing.InstrumentConfig(&cfg)
+
+	ddb := dynamodb.NewFromConfig(cfg)
+	ebClient := eventbridge.NewFromConfig(cfg)
+
+	tracer := tracing.Tracer("vote")
+
+	for _, record := range event.Records {
+		log.Printf("Processing message: %s\n", record.Body)
+
+		var messageBody MessageBody
+		if err := json.Unmarshal([]byte(record.Body), &messageBody); err != nil {
+			log.Printf("Error: %s\n", err)
 			continue
 		}
 
-		log.Printf(
-			"Successfully voted for option %s on poll %s by voter %s\n",
-			messageBody.OptionId,
-			messageBody.PollId,
-			voterId,
+		// Continue the trace started at the API Gateway integration, which carries its context in the message body
+		voteCtx, span := tracer.Start(
+			tracing.Extract(ctx, messageBody.TraceParent, messageBody.TraceState),
+			"vote",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("pseudopoll.poll_id", messageBody.PollId),
+				attribute.String("pseudopoll.option_id", messageBody.OptionId),
+				attribute.String("pseudopoll.request_id", messageBody.RequestId),
+			),
 		)
+		vote(voteCtx, messageBody, ddb, ebClient)
+		span.End()
 	}
 }
 
 func main() {
+	if _, err := tracing.NewTracerProvider(context.Background(), "pseudopoll-vote"); err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
 	lambda.Start(handler)
 }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	ddbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollcreate"
+)
+
+func TestNewTimelineUpdates(t *testing.T) {
+	requestTime := time.Date(2024, 1, 1, 9, 41, 27, 0, time.UTC)
+
+	transactItems := newTimelineUpdates("table", "poll123", "option1", 1, requestTime)
+	if len(transactItems) != len(timelineBuckets) {
+		t.Fatalf("expected a bucket of each width, got %d", len(transactItems))
+	}
+
+	expected := []string{
+		"timeline|1m|2024-01-01T09:41:00Z|option1",
+		"timeline|1h|2024-01-01T09:00:00Z|option1",
+	}
+	for index, transactItem := range transactItems {
+		update := transactItem.Update
+		if pk := update.Key["PK"].(*ddbTypes.AttributeValueMemberS).Value; pk != "poll|poll123" {
+			t.Errorf("expected the bucket to share the poll's partition, got %s", pk)
+		}
+		if sk := update.Key["SK"].(*ddbTypes.AttributeValueMemberS).Value; sk != expected[index] {
+			t.Errorf("expected %s, got %s", expected[index], sk)
+		}
+		if _, ok := update.ExpressionAttributeNames["#ttl"]; ok {
+			t.Errorf("expected the bucket to be deleted with the poll rather than expire, got %s", aws.ToString(update.UpdateExpression))
+		}
+	}
+
+	transactItems = newTimelineUpdates("table", "poll123", "option1", 3, requestTime)
+	if votes := transactItems[0].Update.ExpressionAttributeValues[":vote"].(*ddbTypes.AttributeValueMemberN).Value; votes != "3" {
+		t.Errorf("expected the bucket to count the vote's weight of 3, got %s", votes)
+	}
+}
+
+func TestGetWeight(t *testing.T) {
+	zero := 0
+	ddbPoll := DdbPoll{
+		PkPollId: "poll|poll123",
+		VoteWeights: &pollcreate.VoteWeights{
+			Users:   map[string]int{"user1": 4},
+			Groups:  map[string]int{"architects": 3},
+			Default: &zero,
+		},
+	}
+
+	tests := []struct {
+		messageBody MessageBody
+		expected    int
+	}{
+		{messageBody: MessageBody{UserId: "user1", Groups: "architects"}, expected: 4},
+		{messageBody: MessageBody{UserId: "user2", Groups: "engineers,architects"}, expected: 3},
+		{messageBody: MessageBody{UserId: "user3", Groups: "engineers"}},
+	}
+
+	for _, test := range tests {
+		weight, err := getWeight(ddbPoll, test.messageBody)
+		if test.expected == 0 && err == nil {
+			t.Errorf("expected %s not to be eligible, got a weight of %d", test.messageBody.UserId, weight)
+		}
+		if weight != test.expected {
+			t.Errorf("expected %s to vote with a weight of %d, got %d", test.messageBody.UserId, test.expected, weight)
+		}
+	}
+
+	if weight, err := getWeight(ddbPoll, MessageBody{UserIp: "127.0.0.1", Groups: "architects"}); err == nil {
+		t.Errorf("expected an anonymous voter not to be weighed by a group, got a weight of %d", weight)
+	}
+
+	if weight, err := getWeight(DdbPoll{}, MessageBody{UserIp: "127.0.0.1"}); err != nil || weight != 1 {
+		t.Errorf("expected an unweighted poll's votes to count once, got %d (%v)", weight, err)
+	}
+}
+
+func TestNewWriteIn(t *testing.T) {
+	ddbPoll := DdbPoll{PkPollId: "poll|poll123", AllowWriteIns: true}
+	ddbOptions := []DdbOption{
+		{PkOptionId: "option|option1", Index: 0, Text: "Tacos"},
+		{PkOptionId: "option|option2", Index: 1, Text: "Pho"},
+		{PkOptionId: "option|option3", Index: 2, Text: "Ramen", WriteInStatus: pollcreate.WriteInStatusRejected},
+	}
+
+	writeIn, err := newWriteIn(ddbPoll, ddbOptions, "PHO", DefaultMaxWriteIns)
+	if err != nil || !writeIn.Existing || writeIn.OptionId != "option2" {
+		t.Errorf("expected the text to match an option regardless of case, got %+v (%v)", writeIn, err)
+	}
+
+	if _, err := newWriteIn(ddbPoll, ddbOptions, "ramen", DefaultMaxWriteIns); err == nil {
+		t.Error("expected a rejected write-in not to be written in again")
+	}
+
+	writeIn, err = newWriteIn(ddbPoll, ddbOptions, "Burritos", DefaultMaxWriteIns)
+	if err != nil || writeIn.Existing || writeIn.Index != 3 || writeIn.Status != pollcreate.WriteInStatusApproved {
+		t.Errorf("expected a new approved option after the last one, got %+v (%v)", writeIn, err)
+	}
+
+	ddbPoll.ModerateWriteIns = true
+	if writeIn, _ := newWriteIn(ddbPoll, ddbOptions, "Burritos", DefaultMaxWriteIns); writeIn.Status != pollcreate.WriteInStatusPending {
+		t.Errorf("expected a moderated poll to hold the option, got %s", writeIn.Status)
+	}
+
+	if _, err := newWriteIn(ddbPoll, ddbOptions, "Burritos", 1); err == nil {
+		t.Error("expected the poll's write-ins to be limited")
+	}
+
+	// An option written in since the options were read is counted on the poll
+	lastOptionIndex := 3
+	ddbPoll.LastOptionIndex = &lastOptionIndex
+	if writeIn, _ := newWriteIn(ddbPoll, ddbOptions, "Burritos", DefaultMaxWriteIns); writeIn.Index != 4 {
+		t.Errorf("expected the option to be added after the poll's last option, got %d", writeIn.Index)
+	}
+}
+
+func TestNewWriteInItems(t *testing.T) {
+	ddbPoll := DdbPoll{PkPollId: "poll|poll123", Version: 4}
+	writeIn := WriteIn{OptionId: "option4", Text: "Burritos", Index: 3, Status: pollcreate.WriteInStatusApproved}
+	requestTime := time.Date(2024, 1, 1, 9, 41, 27, 0, time.UTC)
+
+	votePut, err := newVotePut("table", "poll123", "option4", "user123", "request1", 1, requestTime, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if votedAt := votePut.Put.Item["VotedAt"].(*ddbTypes.AttributeValueMemberS).Value; votedAt != "2024-01-01T09:41:27Z" {
+		t.Errorf("expected the vote to record when it was cast, got %s", votedAt)
+	}
+
+	transactItems, err := newWriteInItems("table", ddbPoll, writeIn, votePut, 1, 9, requestTime, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactItems) != 4+len(timelineBuckets) {
+		t.Fatalf("expected the vote, option, write-in, timeline and poll items, got %d", len(transactItems))
+	}
+
+	var ddbOption pollcreate.DdbOption
+	if err := attributevalue.UnmarshalMap(transactItems[1].Put.Item, &ddbOption); err != nil {
+		t.Fatal(err)
+	}
+	if ddbOption.Gsi1PkPollId != "poll|poll123" || ddbOption.Votes != 1 || ddbOption.Version != 9 || ddbOption.Index != 3 {
+		t.Errorf("expected the option to be created with its first vote, got %+v", ddbOption)
+	}
+
+	var ddbWriteIn DdbWriteIn
+	if err := attributevalue.UnmarshalMap(transactItems[2].Put.Item, &ddbWriteIn); err != nil {
+		t.Fatal(err)
+	}
+	if ddbWriteIn.PkPollId != "poll|poll123" || ddbWriteIn.SkWriteInId != "writein|burritos" || ddbWriteIn.OptionId != "option4" {
+		t.Errorf("expected the text to be mapped to the option, got %+v", ddbWriteIn)
+	}
+
+	poll := transactItems[len(transactItems)-1].Update
+	if _, ok := poll.ExpressionAttributeValues[":addedOption"]; !ok {
+		t.Errorf("expected an approved option to be recorded on the poll, got %s", aws.ToString(poll.UpdateExpression))
+	}
+	if version := poll.ExpressionAttributeValues[":version"].(*ddbTypes.AttributeValueMemberN).Value; version != "9" {
+		t.Errorf("expected the poll to be stamped with the sequence number, got %s", version)
+	}
+	if optionIndex := poll.ExpressionAttributeValues[":optionIndex"].(*ddbTypes.AttributeValueMemberN).Value; optionIndex != "3" {
+		t.Errorf("expected the option's index to become the poll's last, got %s", optionIndex)
+	}
+	if condition := aws.ToString(poll.ConditionExpression); condition != "attribute_exists(#pk) AND (attribute_not_exists(#lastOptionIndex) OR #lastOptionIndex < :optionIndex)" {
+		t.Errorf("expected the poll's update to depend on the index being free rather than its version, got %s", condition)
+	}
+
+	writeIn.Status = pollcreate.WriteInStatusPending
+	transactItems, err = newWriteInItems("table", ddbPoll, writeIn, votePut, 1, 9, requestTime, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := transactItems[len(transactItems)-1].Update.ExpressionAttributeValues[":addedOption"]; ok {
+		t.Error("expected a pending option not to be announced")
+	}
+}
+
+func TestIsConflict(t *testing.T) {
+	canceled := func(codes ...string) error {
+		reasons := make([]ddbTypes.CancellationReason, 0, len(codes))
+		for _, code := range codes {
+			reasons = append(reasons, ddbTypes.CancellationReason{Code: aws.String(code)})
+		}
+
+		return &ddbTypes.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"conflict":         {err: canceled("None", "TransactionConflict", "None"), expected: true},
+		"condition failed": {err: canceled("ConditionalCheckFailed", "None", "None")},
+		"other error":      {err: errors.New("throttled")},
+		"no error":         {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if conflict := isConflict(test.err); conflict != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, conflict)
+			}
+		})
+	}
+}
+
+func TestGetBackoff(t *testing.T) {
+	for attempt := 1; attempt <= maxTransactAttempts+10; attempt++ {
+		limit := transactBackoff << (attempt - 1)
+		if limit <= 0 || limit > maxTransactBackoff {
+			limit = maxTransactBackoff
+		}
+
+		for i := 0; i < 20; i++ {
+			if backoff := getBackoff(attempt); backoff < 0 || backoff >= limit {
+				t.Errorf("attempt %d: expected a backoff under %s, got %s", attempt, limit, backoff)
+			}
+		}
+	}
+}
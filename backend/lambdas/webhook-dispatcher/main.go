@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/pollchange"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+type OptionImage struct {
+	OptionId struct {
+		S string `json:"S"`
+	} `json:"PK"`
+	PollId struct {
+		S string `json:"S"`
+	} `json:"GSI1PK"`
+	UpdatedAt struct {
+		S string `json:"S"`
+	} `json:"UpdatedAt"`
+	Votes struct {
+		N string `json:"N"`
+	} `json:"Votes"`
+	Version struct {
+		N string `json:"N"`
+	} `json:"Version"`
+}
+
+type StreamDetail struct {
+	EventName string `json:"eventName"`
+	DynamoDb  struct {
+		Keys struct {
+			PK struct {
+				S string `json:"S"`
+			} `json:"PK"`
+		} `json:"Keys"`
+		NewImage json.RawMessage `json:"NewImage"`
+		OldImage json.RawMessage `json:"OldImage"`
+	} `json:"dynamodb"`
+}
+
+type VoteFailedDetail struct {
+	Error    string `json:"error"`
+	PollId   string `json:"pollId"`
+	OptionId string `json:"optionId"`
+}
+
+type DdbWebhook struct {
+	PkPollId    string   `dynamodbav:"PK"`
+	SkWebhookId string   `dynamodbav:"SK"`
+	Url         string   `dynamodbav:"Url"`
+	Secret      string   `dynamodbav:"Secret"`
+	Events      []string `dynamodbav:"Events"`
+}
+
+type DdbDelivery struct {
+	PkWebhookId  string `dynamodbav:"PK"`
+	SkDeliveryId string `dynamodbav:"SK"`
+	PollId       string `dynamodbav:"PollId"`
+	DeliveryId   string `dynamodbav:"DeliveryId"`
+	Event        string `dynamodbav:"Event"`
+	MessageId    string `dynamodbav:"MessageId"`
+	Attempts     int    `dynamodbav:"Attempts"`
+	StatusCode   int    `dynamodbav:"StatusCode,omitempty"`
+	Succeeded    bool   `dynamodbav:"Succeeded"`
+	Error        string `dynamodbav:"Error,omitempty"`
+	DeliveredAt  string `dynamodbav:"DeliveredAt"`
+}
+
+// Message is a protocol envelope ready to be delivered to the webhooks of its poll
+type Message struct {
+	PollId   string
+	Envelope protocol.Envelope
+}
+
+type Delivery struct {
+	Attempts   int
+	StatusCode int
+	Err        error
+}
+
+const (
+	RFC3339Milli               = "2006-01-02T15:04:05.999Z07:00"
+	DefaultMaxDeliveryAttempts = 5
+	BaseRetryDelay             = time.Second
+	MaxRetryDelay              = 16 * time.Second
+	DeliveryTimeout            = 5 * time.Second
+	SignatureHeader            = "X-PseudoPoll-Signature"
+	EventHeader                = "X-PseudoPoll-Event"
+	DeliveryHeader             = "X-PseudoPoll-Delivery"
+)
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func getMaxDeliveryAttempts() (int, error) {
+	maxDeliveryAttempts := os.Getenv("MAX_DELIVERY_ATTEMPTS")
+	if maxDeliveryAttempts == "" {
+		return DefaultMaxDeliveryAttempts, nil
+	}
+
+	return strconv.Atoi(maxDeliveryAttempts)
+}
+
+func newId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// newStreamMessages maps a change to the table onto the messages it's announced by; counted votes
+// and user-visible poll modifications or expiries are the only changes delivered to webhooks
+func newStreamMessages(detail StreamDetail, modifiedAt time.Time) ([]Message, error) {
+	if detail.EventName == "REMOVE" && strings.HasPrefix(detail.DynamoDb.Keys.PK.S, "poll|") {
+		var oldImage pollchange.Image
+		if err := json.Unmarshal(detail.DynamoDb.OldImage, &oldImage); err != nil {
+			return nil, err
+		}
+
+		// The expiry follows the poll's last modification
+		version, err := pollchange.Version(oldImage)
+		if err != nil {
+			return nil, err
+		}
+
+		pollId := stripPrefix(detail.DynamoDb.Keys.PK.S, "poll|")
+		messages := make([]Message, 0, 1)
+		for _, change := range pollchange.Expired(oldImage, modifiedAt) {
+			envelope, err := protocol.NewEnvelope(change.Type, version+1, change.Data)
+			if err != nil {
+				return nil, err
+			}
+
+			messages = append(messages, Message{PollId: pollId, Envelope: envelope})
+		}
+
+		return messages, nil
+	}
+
+	if detail.EventName != "MODIFY" {
+		return nil, nil
+	}
+
+	switch {
+	case strings.HasPrefix(detail.DynamoDb.Keys.PK.S, "option|"):
+		var newImage, oldImage OptionImage
+		if err := json.Unmarshal(detail.DynamoDb.NewImage, &newImage); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(detail.DynamoDb.OldImage, &oldImage); err != nil {
+			return nil, err
+		}
+
+		if newImage.Votes.N == oldImage.Votes.N {
+			return nil, nil
+		}
+
+		votes, err := strconv.ParseInt(newImage.Votes.N, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		var version int64
+		if newImage.Version.N != "" {
+			if version, err = strconv.ParseInt(newImage.Version.N, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+
+		pollId := stripPrefix(newImage.PollId.S, "poll|")
+		envelope, err := protocol.NewEnvelope(protocol.TypeVoteCounted, version, protocol.VoteCountedData{
+			OptionId:  stripPrefix(newImage.OptionId.S, "option|"),
+			PollId:    pollId,
+			UpdatedAt: newImage.UpdatedAt.S,
+			Votes:     votes,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return []Message{{PollId: pollId, Envelope: envelope}}, nil
+	case strings.HasPrefix(detail.DynamoDb.Keys.PK.S, "poll|"):
+		var newImage, oldImage pollchange.Image
+		if err := json.Unmarshal(detail.DynamoDb.NewImage, &newImage); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(detail.DynamoDb.OldImage, &oldImage); err != nil {
+			return nil, err
+		}
+
+		changes, err := pollchange.Diff(newImage, oldImage, modifiedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		version, err := pollchange.Version(newImage)
+		if err != nil {
+			return nil, err
+		}
+
+		pollId := stripPrefix(newImage.PollId.S, "poll|")
+		messages := make([]Message, 0, len(changes))
+		for _, change := range changes {
+			envelope, err := protocol.NewEnvelope(change.Type, version, change.Data)
+			if err != nil {
+				return nil, err
+			}
+
+			messages = append(messages, Message{PollId: pollId, Envelope: envelope})
+		}
+
+		return messages, nil
+	default:
+		return nil, nil
+	}
+}
+
+func newVoteFailedMessage(detail VoteFailedDetail) (Message, error) {
+	envelope, err := protocol.NewEnvelope(protocol.TypeVoteFailed, 0, protocol.VoteFailedData{
+		Error:    detail.Error,
+		PollId:   detail.PollId,
+		OptionId: detail.OptionId,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{PollId: detail.PollId, Envelope: envelope}, nil
+}
+
+// sign returns the signature of a delivery, an HMAC-SHA256 of the timestamp and body keyed by the
+// webhook's secret. Receivers recompute it, and reject old timestamps to prevent replays.
+func sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// isRetryable reports whether a failed attempt might succeed later; other client errors won't
+func isRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// backoff returns how long to wait before the given retry, doubling with each one up to maxDelay
+func backoff(retry int, baseDelay time.Duration, maxDelay time.Duration) time.Duration {
+	delay := baseDelay << (retry - 1)
+	if delay <= 0 || delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+// Deliverer posts signed payloads to webhook URLs, retrying failed attempts with exponential backoff
+type Deliverer struct {
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func (d *Deliverer) attempt(ctx context.Context, ddbWebhook DdbWebhook, deliveryId string, event string, body []byte) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, ddbWebhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "PseudoPoll-Webhooks")
+	request.Header.Set(EventHeader, event)
+	request.Header.Set(DeliveryHeader, deliveryId)
+	request.Header.Set(SignatureHeader, sign(ddbWebhook.Secret, time.Now().Unix(), body))
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook responded with %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// Deliver posts the body to the webhook until it succeeds, fails with a client error, or runs out of attempts.
+// Every attempt is signed with a fresh timestamp.
+func (d *Deliverer) Deliver(ctx context.Context, ddbWebhook DdbWebhook, deliveryId string, event string, body []byte) Delivery {
+	var delivery Delivery
+
+	for delivery.Attempts < d.MaxAttempts {
+		if delivery.Attempts > 0 {
+			timer := time.NewTimer(backoff(delivery.Attempts, d.BaseDelay, d.MaxDelay))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				delivery.Err = ctx.Err()
+				return delivery
+			case <-timer.C:
+			}
+		}
+
+		delivery.Attempts++
+		delivery.StatusCode, delivery.Err = d.attempt(ctx, ddbWebhook, deliveryId, event, body)
+		if delivery.Err == nil || (delivery.StatusCode != 0 && !isRetryable(delivery.StatusCode)) {
+			return delivery
+		}
+	}
+
+	return delivery
+}
+
+func getWebhooks(ctx context.Context, ddb *dynamodb.Client, pollId string) ([]DdbWebhook, error) {
+	result, err := ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		KeyConditionExpression: aws.String("#pk = :poll AND begins_with(#sk, :webhook)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "PK",
+			"#sk": "SK",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":poll": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			":webhook": &types.AttributeValueMemberS{
+				Value: "webhook|",
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ddbWebhooks []DdbWebhook
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &ddbWebhooks); err != nil {
+		return nil, err
+	}
+
+	return ddbWebhooks, nil
+}
+
+// logDelivery records the outcome of a delivery, so poll owners can see what their endpoints received
+func logDelivery(ctx context.Context, ddb *dynamodb.Client, ddbWebhook DdbWebhook, deliveryId string, message Message, delivery Delivery) error {
+	deliveredAt := time.Now().UTC().Format(RFC3339Milli)
+
+	ddbDelivery := DdbDelivery{
+		PkWebhookId:  ddbWebhook.SkWebhookId,
+		SkDeliveryId: fmt.Sprintf("delivery|%s|%s", deliveredAt, deliveryId),
+		PollId:       message.PollId,
+		DeliveryId:   deliveryId,
+		Event:        message.Envelope.Type,
+		MessageId:    message.Envelope.Id,
+		Attempts:     delivery.Attempts,
+		StatusCode:   delivery.StatusCode,
+		Succeeded:    delivery.Err == nil,
+		DeliveredAt:  deliveredAt,
+	}
+	if delivery.Err != nil {
+		ddbDelivery.Error = delivery.Err.Error()
+	}
+
+	item, err := attributevalue.MarshalMap(ddbDelivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("SINGLE_TABLE_NAME")),
+		Item:      item,
+	})
+
+	return err
+}
+
+// dispatch delivers each message to every webhook of its poll that subscribes to the message's type,
+// concurrently so that one slow endpoint doesn't hold up the others
+func dispatch(ctx context.Context, ddb *dynamodb.Client, deliverer *Deliverer, messages []Message) {
+	var wg sync.WaitGroup
+
+	for _, message := range messages {
+		ddbWebhooks, err := getWebhooks(ctx, ddb, message.PollId)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			continue
+		}
+
+		body, err := json.Marshal(message.Envelope)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			continue
+		}
+
+		for _, ddbWebhook := range ddbWebhooks {
+			if !slices.Contains(ddbWebhook.Events, message.Envelope.Type) {
+				continue
+			}
+
+			wg.Add(1)
+			go func(ddbWebhook DdbWebhook, message Message) {
+				defer wg.Done()
+
+				deliveryId, err := newId()
+				if err != nil {
+					log.Printf("Error: %s\n", err)
+					return
+				}
+
+				delivery := deliverer.Deliver(ctx, ddbWebhook, deliveryId, message.Envelope.Type, body)
+				if delivery.Err != nil {
+					log.Printf("Delivery %s to webhook %s failed after %d attempts: %s\n", deliveryId, ddbWebhook.SkWebhookId, delivery.Attempts, delivery.Err)
+				}
+
+				if err := logDelivery(ctx, ddb, ddbWebhook, deliveryId, message, delivery); err != nil {
+					log.Printf("Error: %s\n", err)
+				}
+			}(ddbWebhook, message)
+		}
+	}
+
+	wg.Wait()
+}
+
+func handler(ctx context.Context, event events.CloudWatchEvent) {
+	log.Printf("Processing event: %s\n", event)
+
+	var messages []Message
+	switch {
+	case event.Source == os.Getenv("DDB_STREAM_SOURCE") && event.DetailType == os.Getenv("DDB_STREAM_DETAIL_TYPE"):
+		var streamDetail StreamDetail
+		if err := json.Unmarshal(event.Detail, &streamDetail); err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		// The event is put on the bus after the change was written, like for the poll modification publisher
+		streamMessages, err := newStreamMessages(streamDetail, event.Time)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		messages = streamMessages
+	case event.Source == os.Getenv("VOTE_FAILED_SOURCE") && event.DetailType == os.Getenv("VOTE_FAILED_DETAIL_TYPE"):
+		var voteFailedDetail VoteFailedDetail
+		if err := json.Unmarshal(event.Detail, &voteFailedDetail); err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		message, err := newVoteFailedMessage(voteFailedDetail)
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+			return
+		}
+
+		messages = []Message{message}
+	default:
+		log.Printf("Unknown event source or detail type: %s, %s\n", event.Source, event.DetailType)
+		return
+	}
+
+	if len(messages) == 0 {
+		log.Printf("Nothing to deliver\n")
+		return
+	}
+
+	maxDeliveryAttempts, err := getMaxDeliveryAttempts()
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Printf("Error: %s\n", err)
+		return
+	}
+
+	dispatch(ctx, dynamodb.NewFromConfig(cfg), &Deliverer{
+		Client:      &http.Client{Timeout: DeliveryTimeout},
+		MaxAttempts: maxDeliveryAttempts,
+		BaseDelay:   BaseRetryDelay,
+		MaxDelay:    MaxRetryDelay,
+	}, messages)
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol/protocoltest"
+)
+
+const optionModifiedDetail = `{
+	"eventName": "MODIFY",
+	"dynamodb": {
+		"Keys": {
+			"PK": {"S": "option|option123"},
+			"SK": {"S": "option|option123"}
+		},
+		"NewImage": {
+			"PK": {"S": "option|option123"},
+			"GSI1PK": {"S": "poll|poll123"},
+			"UpdatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Votes": {"N": "3"},
+			"Version": {"N": "7"}
+		},
+		"OldImage": {
+			"PK": {"S": "option|option123"},
+			"GSI1PK": {"S": "poll|poll123"},
+			"UpdatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Votes": {"N": "2"},
+			"Version": {"N": "6"}
+		}
+	}
+}`
+
+const pollModifiedDetail = `{
+	"eventName": "MODIFY",
+	"dynamodb": {
+		"Keys": {
+			"PK": {"S": "poll|poll123"},
+			"SK": {"S": "poll|poll123"}
+		},
+		"NewImage": {
+			"PK": {"S": "poll|poll123"},
+			"GSI1PK": {"S": "user|user123"},
+			"Prompt": {"S": "Ship it?"},
+			"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Duration": {"N": "60"},
+			"IsArchived": {"BOOL": false},
+			"Version": {"N": "8"}
+		},
+		"OldImage": {
+			"PK": {"S": "poll|poll123"},
+			"GSI1PK": {"S": "user|user123"},
+			"Prompt": {"S": "Ship it?"},
+			"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Duration": {"N": "3600"},
+			"IsArchived": {"BOOL": false},
+			"Version": {"N": "7"}
+		}
+	}
+}`
+
+const pollExpiredDetail = `{
+	"eventName": "REMOVE",
+	"dynamodb": {
+		"Keys": {
+			"PK": {"S": "poll|poll123"},
+			"SK": {"S": "poll|poll123"}
+		},
+		"OldImage": {
+			"PK": {"S": "poll|poll123"},
+			"GSI1PK": {"S": "user|user123"},
+			"Prompt": {"S": "Ship it?"},
+			"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+			"Duration": {"N": "3600"},
+			"IsArchived": {"BOOL": true},
+			"Ttl": {"N": "1706659200"},
+			"Version": {"N": "9"}
+		}
+	}
+}`
+
+func TestNewStreamMessages(t *testing.T) {
+	tests := map[string]struct {
+		detail   string
+		expected string
+		sequence int64
+	}{
+		"vote counted":      {optionModifiedDetail, protocol.TypeVoteCounted, 7},
+		"poll closed early": {pollModifiedDetail, protocol.TypePollClosedEarly, 8},
+		"poll expired":      {pollExpiredDetail, protocol.TypePollExpired, 10},
+	}
+
+	for name, test := range tests {
+		var detail StreamDetail
+		if err := json.Unmarshal([]byte(test.detail), &detail); err != nil {
+			t.Fatal(err)
+		}
+
+		messages, err := newStreamMessages(detail, time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(messages) != 1 {
+			t.Fatalf("%s: expected 1 message, got %d", name, len(messages))
+		}
+
+		if messages[0].PollId != "poll123" || messages[0].Envelope.Type != test.expected || messages[0].Envelope.Sequence != test.sequence {
+			t.Errorf("%s: expected %s at %d for poll123, got %s at %d for %s", name, test.expected, test.sequence, messages[0].Envelope.Type, messages[0].Envelope.Sequence, messages[0].PollId)
+		}
+
+		payload, err := json.Marshal(messages[0].Envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		protocoltest.AssertValid(t, payload)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"pollEdited"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	expected := fmt.Sprintf("t=1700000000,v1=%s", hex.EncodeToString(mac.Sum(nil)))
+
+	if signature := sign("whsec_test", 1700000000, body); signature != expected {
+		t.Errorf("expected %s, got %s", expected, signature)
+	}
+}
+
+// newReceiver starts a local HTTP receiver that verifies each delivery's signature and
+// responds with the given status codes in turn, repeating the last one
+func newReceiver(t *testing.T, secret string, statusCodes ...int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(requests.Add(1)) - 1
+		if i >= len(statusCodes) {
+			i = len(statusCodes) - 1
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		var timestamp int64
+		if _, err := fmt.Sscanf(strings.Split(r.Header.Get(SignatureHeader), ",")[0], "t=%d", &timestamp); err != nil {
+			t.Error(err)
+		}
+		if r.Header.Get(SignatureHeader) != sign(secret, timestamp, body) {
+			t.Errorf("unexpected signature %s", r.Header.Get(SignatureHeader))
+		}
+		if r.Header.Get(DeliveryHeader) != "delivery123" {
+			t.Errorf("unexpected delivery ID %s", r.Header.Get(DeliveryHeader))
+		}
+
+		w.WriteHeader(statusCodes[i])
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestDeliver(t *testing.T) {
+	tests := map[string]struct {
+		statusCodes []int
+		attempts    int
+		succeeded   bool
+	}{
+		"succeeds":                {[]int{http.StatusOK}, 1, true},
+		"retries server errors":   {[]int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusNoContent}, 3, true},
+		"retries rate limits":     {[]int{http.StatusTooManyRequests, http.StatusOK}, 2, true},
+		"gives up":                {[]int{http.StatusInternalServerError}, 4, false},
+		"doesn't retry rejection": {[]int{http.StatusBadRequest}, 1, false},
+	}
+
+	for name, test := range tests {
+		server, requests := newReceiver(t, "whsec_test", test.statusCodes...)
+
+		deliverer := &Deliverer{
+			Client:      server.Client(),
+			MaxAttempts: 4,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    4 * time.Millisecond,
+		}
+
+		delivery := deliverer.Deliver(context.Background(), DdbWebhook{
+			Url:    server.URL,
+			Secret: "whsec_test",
+		}, "delivery123", protocol.TypePollEdited, []byte(`{"type":"pollEdited"}`))
+
+		if delivery.Attempts != test.attempts || int(requests.Load()) != test.attempts {
+			t.Errorf("%s: expected %d attempts, got %d and %d requests", name, test.attempts, delivery.Attempts, requests.Load())
+		}
+
+		if (delivery.Err == nil) != test.succeeded {
+			t.Errorf("%s: expected succeeded to be %t, got %v", name, test.succeeded, delivery.Err)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 16 * time.Second}
+
+	for i, delay := range expected {
+		if actual := backoff(i+1, BaseRetryDelay, MaxRetryDelay); actual != delay {
+			t.Errorf("retry %d: expected %s, got %s", i+1, delay, actual)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package chat integrates polls with a Slack-style chat workspace: it verifies signed requests, parses
+// slash commands, renders polls as block messages with vote buttons and posts them with the Web API.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Poll is what a chat message shows of a poll.
+type Poll struct {
+	PollId   string
+	Prompt   string
+	Options  []Option
+	IsClosed bool
+}
+
+type Option struct {
+	OptionId string
+	Text     string
+	Votes    int
+}
+
+// Message identifies a posted message so it can be updated.
+type Message struct {
+	Channel string `json:"channel"`
+	Ts      string `json:"ts"`
+}
+
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type Element struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text,omitempty"`
+	ActionId string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// Block is a layout block. The elements of an actions block are Elements and those of a context block
+// are Texts.
+type Block struct {
+	Type     string        `json:"type"`
+	BlockId  string        `json:"block_id,omitempty"`
+	Text     *Text         `json:"text,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// InteractionPayload is the part of an interactive message callback that voting needs.
+type InteractionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		Id string `json:"id"`
+	} `json:"user"`
+	Team struct {
+		Id string `json:"id"`
+	} `json:"team"`
+	Actions []struct {
+		ActionId string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// Vote is a vote cast by pressing one of a poll message's buttons.
+type Vote struct {
+	PollId   string
+	OptionId string
+}
+
+// Client calls the chat Web API with a bot token.
+type Client struct {
+	Token      string
+	HTTPClient *http.Client
+	BaseURL    string
+}
+
+const (
+	SignatureHeader  = "X-Slack-Signature"
+	TimestampHeader  = "X-Slack-Request-Timestamp"
+	SignatureVersion = "v0"
+
+	// MaxRequestAge bounds how old a signed request may be, so captured requests can't be replayed
+	MaxRequestAge = 5 * time.Minute
+
+	DefaultBaseURL = "https://slack.com/api"
+
+	VoteActionId = "vote"
+
+	// The user IDs of chat users are namespaced so they can't collide with the subjects of the API's tokens
+	userIdPrefix = "chat:"
+)
+
+var (
+	ErrStaleRequest     = errors.New("request timestamp is too old")
+	ErrInvalidSignature = errors.New("request signature is invalid")
+)
+
+// Verify checks a request's signature, which is the HMAC-SHA256 of "v0:<timestamp>:<body>" keyed by
+// the signing secret.
+func Verify(signingSecret string, timestamp string, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > MaxRequestAge || age < -MaxRequestAge {
+		return ErrStaleRequest
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(sign(signingSecret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func sign(signingSecret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(fmt.Sprintf("%s:%s:", SignatureVersion, timestamp)))
+	mac.Write(body)
+
+	return fmt.Sprintf("%s=%s", SignatureVersion, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// ParseArgs splits a slash command's text into its arguments. Arguments are separated by spaces and
+// may be double quoted to contain them; the curly quotes that chat clients substitute are accepted too.
+func ParseArgs(text string) ([]string, error) {
+	text = strings.NewReplacer("“", `"`, "”", `"`).Replace(text)
+
+	var args []string
+	var current strings.Builder
+	inArg, inQuotes := false, false
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			if inQuotes {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			} else if inArg {
+				return nil, errors.New("quotes must surround a whole argument")
+			}
+			inQuotes = !inQuotes
+		case r == ' ' && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inQuotes {
+		return nil, errors.New("a quoted argument is missing its closing quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// UserId returns the ID that a chat user owns polls and votes as.
+func UserId(teamId string, userId string) string {
+	return fmt.Sprintf("%s%s:%s", userIdPrefix, teamId, userId)
+}
+
+// Votes returns the votes cast by an interaction, ignoring its other actions.
+func (payload InteractionPayload) Votes() []Vote {
+	var votes []Vote
+	for _, action := range payload.Actions {
+		if action.ActionId != VoteActionId {
+			continue
+		}
+
+		pollId, optionId, found := strings.Cut(action.Value, "|")
+		if !found || pollId == "" || optionId == "" {
+			continue
+		}
+
+		votes = append(votes, Vote{PollId: pollId, OptionId: optionId})
+	}
+
+	return votes
+}
+
+// Summary is the plain text of a poll message, shown in notifications.
+func Summary(poll Poll) string {
+	return fmt.Sprintf("Poll: %s", poll.Prompt)
+}
+
+// Blocks renders a poll as a prompt, each option with its votes, and a vote button per option while the
+// poll is open.
+func Blocks(poll Poll) []Block {
+	blocks := []Block{
+		{
+			Type:    "section",
+			BlockId: fmt.Sprintf("poll|%s", poll.PollId),
+			Text:    &Text{Type: "mrkdwn", Text: fmt.Sprintf("*%s*", escape(poll.Prompt))},
+		},
+	}
+
+	total := 0
+	for _, option := range poll.Options {
+		total += option.Votes
+	}
+
+	var lines []string
+	for _, option := range poll.Options {
+		lines = append(lines, fmt.Sprintf("%s  `%s`", escape(option.Text), countVotes(option.Votes)))
+	}
+	blocks = append(blocks, Block{
+		Type: "section",
+		Text: &Text{Type: "mrkdwn", Text: strings.Join(lines, "\n")},
+	})
+
+	if !poll.IsClosed {
+		var buttons []interface{}
+		for _, option := range poll.Options {
+			buttons = append(buttons, Element{
+				Type:     "button",
+				Text:     &Text{Type: "plain_text", Text: option.Text},
+				ActionId: VoteActionId,
+				Value:    fmt.Sprintf("%s|%s", poll.PollId, option.OptionId),
+			})
+		}
+		blocks = append(blocks, Block{Type: "actions", Elements: buttons})
+	}
+
+	status := fmt.Sprintf("%s in total", countVotes(total))
+	if poll.IsClosed {
+		status = fmt.Sprintf("Closed with %s in total", countVotes(total))
+	}
+	blocks = append(blocks, Block{
+		Type:     "context",
+		Elements: []interface{}{Text{Type: "mrkdwn", Text: status}},
+	})
+
+	return blocks
+}
+
+func countVotes(votes int) string {
+	if votes == 1 {
+		return "1 vote"
+	}
+
+	return fmt.Sprintf("%d votes", votes)
+}
+
+// escape escapes the characters that mrkdwn gives a meaning to.
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func (client Client) call(ctx context.Context, method string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	baseURL := client.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	httpRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("%s/%s", baseURL, method),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json; charset=utf-8")
+	httpRequest.Header.Set("Authorization", fmt.Sprintf("Bearer %s", client.Token))
+
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	httpResponse, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", method, httpResponse.StatusCode)
+	}
+
+	// The Web API reports failures in the body of a 200 response
+	var result struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	raw := json.RawMessage{}
+	if err := json.NewDecoder(httpResponse.Body).Decode(&raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return err
+	}
+	if !result.Ok {
+		return fmt.Errorf("%s failed: %s", method, result.Error)
+	}
+
+	if response != nil {
+		return json.Unmarshal(raw, response)
+	}
+
+	return nil
+}
+
+// PostMessage posts a poll message to a channel.
+func (client Client) PostMessage(ctx context.Context, channel string, poll Poll) (Message, error) {
+	var message Message
+	err := client.call(ctx, "chat.postMessage", map[string]interface{}{
+		"channel": channel,
+		"text":    Summary(poll),
+		"blocks":  Blocks(poll),
+	}, &message)
+
+	return message, err
+}
+
+// UpdateMessage replaces a posted poll message with the poll's current state.
+func (client Client) UpdateMessage(ctx context.Context, message Message, poll Poll) error {
+	return client.call(ctx, "chat.update", map[string]interface{}{
+		"channel": message.Channel,
+		"ts":      message.Ts,
+		"text":    Summary(poll),
+		"blocks":  Blocks(poll),
+	}, nil)
+}
@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte("command=%2Fpoll&text=%22Ship+it%3F%22+Yes+No")
+	signature := sign("secret", timestamp, body)
+
+	tests := map[string]struct {
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		expected  error
+	}{
+		"valid":           {secret: "secret", timestamp: timestamp, signature: signature, body: body},
+		"wrong secret":    {secret: "other", timestamp: timestamp, signature: signature, body: body, expected: ErrInvalidSignature},
+		"tampered body":   {secret: "secret", timestamp: timestamp, signature: signature, body: []byte("command=%2Fpoll"), expected: ErrInvalidSignature},
+		"missing":         {secret: "secret", timestamp: timestamp, signature: "", body: body, expected: ErrInvalidSignature},
+		"bad timestamp":   {secret: "secret", timestamp: "yesterday", signature: signature, body: body, expected: ErrInvalidSignature},
+		"stale timestamp": {secret: "secret", timestamp: strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10), signature: signature, body: body, expected: ErrStaleRequest},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(test.secret, test.timestamp, test.signature, test.body, now)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+		err      bool
+	}{
+		{text: `"Ship it?" "Yes" "Not yet"`, expected: []string{"Ship it?", "Yes", "Not yet"}},
+		{text: `“Ship it?” Yes No`, expected: []string{"Ship it?", "Yes", "No"}},
+		{text: `  Lunch   "Tacos"  `, expected: []string{"Lunch", "Tacos"}},
+		{text: ``, expected: nil},
+		{text: `"Ship it? Yes`, err: true},
+		{text: `Ship"it"`, err: true},
+	}
+
+	for _, test := range tests {
+		args, err := ParseArgs(test.text)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.text, test.expected, args)
+		}
+	}
+}
+
+func TestVotes(t *testing.T) {
+	var payload InteractionPayload
+	err := json.Unmarshal([]byte(`{
+		"type": "block_actions",
+		"user": {"id": "U1"},
+		"team": {"id": "T1"},
+		"actions": [
+			{"action_id": "vote", "value": "poll123|option1"},
+			{"action_id": "other", "value": "poll123|option2"},
+			{"action_id": "vote", "value": "malformed"}
+		]
+	}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Vote{{PollId: "poll123", OptionId: "option1"}}
+	if votes := payload.Votes(); !reflect.DeepEqual(votes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, votes)
+	}
+	if userId := UserId(payload.Team.Id, payload.User.Id); userId != "chat:T1:U1" {
+		t.Errorf("unexpected user ID %s", userId)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	poll := Poll{
+		PollId: "poll123",
+		Prompt: "Ship <it>?",
+		Options: []Option{
+			{OptionId: "option1", Text: "Yes", Votes: 1},
+			{OptionId: "option2", Text: "No", Votes: 2},
+		},
+	}
+
+	blocks := Blocks(poll)
+	if len(blocks) != 4 || blocks[2].Type != "actions" {
+		t.Fatalf("expected a vote button block for an open poll, got %+v", blocks)
+	}
+	if blocks[0].Text.Text != "*Ship &lt;it&gt;?*" {
+		t.Errorf("expected the prompt to be escaped, got %s", blocks[0].Text.Text)
+	}
+	if blocks[1].Text.Text != "Yes  `1 vote`\nNo  `2 votes`" {
+		t.Errorf("unexpected option counts %q", blocks[1].Text.Text)
+	}
+	button := blocks[2].Elements[1].(Element)
+	if button.ActionId != VoteActionId || button.Value != "poll123|option2" {
+		t.Errorf("unexpected button %+v", button)
+	}
+
+	poll.IsClosed = true
+	blocks = Blocks(poll)
+	if len(blocks) != 3 {
+		t.Fatalf("expected no vote buttons for a closed poll, got %+v", blocks)
+	}
+	if status := blocks[2].Elements[0].(Text).Text; status != "Closed with 3 votes in total" {
+		t.Errorf("unexpected status %q", status)
+	}
+}
+
+func TestClient(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, request)
+
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			w.Write([]byte(`{"ok": true, "channel": "C1", "ts": "1700000000.000100"}`))
+		default:
+			w.Write([]byte(`{"ok": false, "error": "message_not_found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := Client{Token: "xoxb-token", HTTPClient: server.Client(), BaseURL: server.URL}
+	poll := Poll{PollId: "poll123", Prompt: "Ship it?", Options: []Option{{OptionId: "option1", Text: "Yes"}}}
+
+	message, err := client.PostMessage(context.Background(), "C1", poll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message != (Message{Channel: "C1", Ts: "1700000000.000100"}) {
+		t.Errorf("unexpected message %+v", message)
+	}
+	if requests[0]["channel"] != "C1" || requests[0]["text"] != "Poll: Ship it?" {
+		t.Errorf("unexpected request %+v", requests[0])
+	}
+
+	if err := client.UpdateMessage(context.Background(), message, poll); err == nil {
+		t.Error("expected the API's error to be returned")
+	}
+	if requests[1]["ts"] != message.Ts {
+		t.Errorf("expected the message to be updated, got %+v", requests[1])
+	}
+}
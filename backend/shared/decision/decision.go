@@ -0,0 +1,281 @@
+// Package decision evaluates a closed poll's quorum, threshold and tie-break against its options' counts,
+// and ranks its options, so the poll's outcome and winner are decided the same way wherever they're shown.
+package decision
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	ResultPassed   = "passed"
+	ResultFailed   = "failed"
+	ResultNoQuorum = "noQuorum"
+	ResultTie      = "tie"
+)
+
+// Tie-break policies decide which of the options that share the lead wins. With none, the tie stands and
+// the poll has no winner; with earliest, the option that reached the lead first wins; with owner, the
+// poll's owner picks one of them once the poll closes, and the tie stands until they do.
+const (
+	TieBreakNone     = "none"
+	TieBreakEarliest = "earliest"
+	TieBreakOwner    = "owner"
+)
+
+// Rules are what a poll needs to pass. Quorum is the number of ballots that must be cast, each voter's
+// counting once whatever their weight. Without a threshold, the leading option passes by plurality.
+// TieBreakOptionId is the option the owner picked, under the owner policy.
+type Rules struct {
+	Quorum    int
+	Threshold *Threshold
+
+	TieBreak         string
+	TieBreakOptionId string
+}
+
+// Threshold is what the leading option needs to pass: either a percentage of the votes, or a number of
+// votes. Both count votes by their weight.
+type Threshold struct {
+	Percentage int `json:"percentage,omitempty" dynamodbav:"Percentage,omitempty"`
+	Votes      int `json:"votes,omitempty" dynamodbav:"Votes,omitempty"`
+}
+
+// Tally is an option's count. ReachedAt is when its last vote was counted, so when it reached its votes.
+type Tally struct {
+	OptionId  string
+	Votes     int64
+	Ballots   int64
+	ReachedAt time.Time
+}
+
+// Standing is where an option places. Options with the same votes share a rank, and the next rank skips
+// the places they take, so two options tied first are followed by the third.
+type Standing struct {
+	OptionId   string
+	Rank       int
+	Percentage float64
+	IsWinner   bool
+	IsTied     bool
+}
+
+// Standings are the poll's options in the order of their tallies, with the option that won it
+type Standings struct {
+	Options          []Standing
+	TotalVotes       int64
+	WinningOptionIds []string
+}
+
+// Outcome is the decision of a closed poll. OptionId is the leading option, whether it passed or failed,
+// and TiedOptionIds the options that share the lead. A tie that was broken has both.
+type Outcome struct {
+	Result        string   `json:"result" dynamodbav:"Result"`
+	Reason        string   `json:"reason,omitempty" dynamodbav:"Reason,omitempty"`
+	OptionId      string   `json:"optionId,omitempty" dynamodbav:"OptionId,omitempty"`
+	TiedOptionIds []string `json:"tiedOptionIds,omitempty" dynamodbav:"TiedOptionIds,omitempty"`
+	Ballots       int64    `json:"ballots" dynamodbav:"Ballots"`
+	Votes         int64    `json:"votes" dynamodbav:"Votes"`
+}
+
+var (
+	ErrInvalidRules    = errors.New("invalid rules")
+	ErrInvalidTieBreak = errors.New("invalid tie-break")
+)
+
+// Validate checks that the quorum isn't negative, and that the threshold is either a percentage from 1 to
+// 100 or a positive number of votes.
+func (rules Rules) Validate() error {
+	if rules.Quorum < 0 {
+		return fmt.Errorf("%w: quorum can't be negative", ErrInvalidRules)
+	}
+
+	threshold := rules.Threshold
+	if threshold == nil {
+		return nil
+	}
+
+	if (threshold.Percentage == 0) == (threshold.Votes == 0) {
+		return fmt.Errorf("%w: threshold must be either a percentage or a number of votes", ErrInvalidRules)
+	}
+	if threshold.Percentage < 0 || threshold.Percentage > 100 {
+		return fmt.Errorf("%w: threshold percentage must be between 1 and 100", ErrInvalidRules)
+	}
+	if threshold.Votes < 0 {
+		return fmt.Errorf("%w: threshold votes must be greater than 0", ErrInvalidRules)
+	}
+
+	return nil
+}
+
+// Decide evaluates the rules against the tallies. The quorum is checked first, then whether an option
+// leads on its own or the tie-break picks one, and then whether the leading option meets the threshold.
+// A poll without votes fails.
+func Decide(rules Rules, tallies []Tally) Outcome {
+	var outcome Outcome
+	for _, tally := range tallies {
+		outcome.Ballots += tally.Ballots
+		outcome.Votes += tally.Votes
+	}
+
+	if outcome.Ballots < int64(rules.Quorum) {
+		outcome.Result = ResultNoQuorum
+		outcome.Reason = fmt.Sprintf("%d of the %d ballots needed for a quorum were cast", outcome.Ballots, rules.Quorum)
+		return outcome
+	}
+
+	if outcome.Votes == 0 {
+		outcome.Result = ResultFailed
+		outcome.Reason = "no votes were cast"
+		return outcome
+	}
+
+	leading := Leading(tallies)
+	outcome.OptionId = leading[0]
+	if len(leading) > 1 {
+		outcome.TiedOptionIds = leading
+
+		winner := breakTie(tallies, leading, rules.TieBreak, rules.TieBreakOptionId)
+		if winner == nil {
+			outcome.Result = ResultTie
+			outcome.OptionId = ""
+			return outcome
+		}
+
+		outcome.OptionId = winner.OptionId
+	}
+
+	var votes int64
+	for _, tally := range tallies {
+		if tally.OptionId == outcome.OptionId {
+			votes = tally.Votes
+		}
+	}
+
+	threshold := rules.Threshold
+	switch {
+	case threshold == nil:
+		outcome.Result = ResultPassed
+	case threshold.Percentage > 0 && votes*100 < int64(threshold.Percentage)*outcome.Votes:
+		outcome.Result = ResultFailed
+		outcome.Reason = fmt.Sprintf(
+			"the leading option got %d%% of the votes, short of the %d%% threshold",
+			votes*100/outcome.Votes,
+			threshold.Percentage,
+		)
+	case threshold.Votes > 0 && votes < int64(threshold.Votes):
+		outcome.Result = ResultFailed
+		outcome.Reason = fmt.Sprintf(
+			"the leading option got %d votes, short of the threshold of %d",
+			votes,
+			threshold.Votes,
+		)
+	default:
+		outcome.Result = ResultPassed
+	}
+
+	return outcome
+}
+
+// Leading returns the ids of the options with the most votes, in the order of the tallies
+func Leading(tallies []Tally) []string {
+	var most int64
+	for _, tally := range tallies {
+		if tally.Votes > most {
+			most = tally.Votes
+		}
+	}
+
+	var leading []string
+	for _, tally := range tallies {
+		if tally.Votes == most {
+			leading = append(leading, tally.OptionId)
+		}
+	}
+
+	return leading
+}
+
+// ValidateTieBreak checks the policy is known, where an empty policy is none
+func ValidateTieBreak(tieBreak string) error {
+	switch tieBreak {
+	case "", TieBreakNone, TieBreakEarliest, TieBreakOwner:
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown policy %q", ErrInvalidTieBreak, tieBreak)
+}
+
+// Rank places the options by their votes. The winner is the option the poll passed with, so a poll that's
+// open, failed, missed its quorum or has a tie that wasn't broken has none.
+func Rank(tallies []Tally, outcome *Outcome) Standings {
+	standings := Standings{Options: make([]Standing, 0, len(tallies))}
+	for _, tally := range tallies {
+		standings.TotalVotes += tally.Votes
+	}
+
+	counts := make(map[int64]int)
+	for _, tally := range tallies {
+		counts[tally.Votes]++
+	}
+
+	var winningOptionId string
+	if outcome != nil && outcome.Result == ResultPassed {
+		winningOptionId = outcome.OptionId
+	}
+
+	for _, tally := range tallies {
+		standing := Standing{
+			OptionId: tally.OptionId,
+			Rank:     1,
+			IsWinner: winningOptionId != "" && tally.OptionId == winningOptionId,
+			IsTied:   counts[tally.Votes] > 1,
+		}
+		for _, other := range tallies {
+			if other.Votes > tally.Votes {
+				standing.Rank++
+			}
+		}
+		if standings.TotalVotes > 0 {
+			standing.Percentage = math.Round(float64(tally.Votes)*1000/float64(standings.TotalVotes)) / 10
+		}
+		if standing.IsWinner {
+			standings.WinningOptionIds = append(standings.WinningOptionIds, tally.OptionId)
+		}
+
+		standings.Options = append(standings.Options, standing)
+	}
+
+	return standings
+}
+
+// breakTie picks the winner among the leading options by the policy, or returns nil if the tie stands
+func breakTie(tallies []Tally, leading []string, tieBreak string, tieBreakOptionId string) *Tally {
+	var tied []Tally
+	for _, tally := range tallies {
+		for _, optionId := range leading {
+			if tally.OptionId == optionId {
+				tied = append(tied, tally)
+			}
+		}
+	}
+
+	switch tieBreak {
+	case TieBreakEarliest:
+		sort.SliceStable(tied, func(i, j int) bool {
+			return tied[i].ReachedAt.Before(tied[j].ReachedAt)
+		})
+
+		return &tied[0]
+	case TieBreakOwner:
+		for index := range tied {
+			if tied[index].OptionId == tieBreakOptionId {
+				return &tied[index]
+			}
+		}
+	}
+
+	return nil
+}
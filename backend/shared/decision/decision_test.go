@@ -0,0 +1,261 @@
+package decision
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	tallies := []Tally{
+		{OptionId: "yes", Votes: 7, Ballots: 7},
+		{OptionId: "no", Votes: 3, Ballots: 3},
+	}
+
+	reached := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tied := []Tally{
+		{OptionId: "a", Votes: 4, Ballots: 4, ReachedAt: reached.Add(time.Minute)},
+		{OptionId: "b", Votes: 4, Ballots: 2, ReachedAt: reached},
+		{OptionId: "c", Votes: 1, Ballots: 1},
+	}
+	tiedOptionIds := []string{"a", "b"}
+
+	tests := map[string]struct {
+		rules    Rules
+		tallies  []Tally
+		expected Outcome
+	}{
+		"plurality": {
+			Rules{},
+			tallies,
+			Outcome{Result: ResultPassed, OptionId: "yes", Ballots: 10, Votes: 10},
+		},
+		"percentage met": {
+			Rules{Quorum: 10, Threshold: &Threshold{Percentage: 60}},
+			tallies,
+			Outcome{Result: ResultPassed, OptionId: "yes", Ballots: 10, Votes: 10},
+		},
+		"percentage met exactly": {
+			Rules{Threshold: &Threshold{Percentage: 70}},
+			tallies,
+			Outcome{Result: ResultPassed, OptionId: "yes", Ballots: 10, Votes: 10},
+		},
+		"percentage missed": {
+			Rules{Threshold: &Threshold{Percentage: 75}},
+			tallies,
+			Outcome{
+				Result:   ResultFailed,
+				Reason:   "the leading option got 70% of the votes, short of the 75% threshold",
+				OptionId: "yes",
+				Ballots:  10,
+				Votes:    10,
+			},
+		},
+		"votes missed": {
+			Rules{Threshold: &Threshold{Votes: 8}},
+			tallies,
+			Outcome{
+				Result:   ResultFailed,
+				Reason:   "the leading option got 7 votes, short of the threshold of 8",
+				OptionId: "yes",
+				Ballots:  10,
+				Votes:    10,
+			},
+		},
+		"no quorum": {
+			Rules{Quorum: 11, Threshold: &Threshold{Percentage: 60}},
+			tallies,
+			Outcome{
+				Result:  ResultNoQuorum,
+				Reason:  "10 of the 11 ballots needed for a quorum were cast",
+				Ballots: 10,
+				Votes:   10,
+			},
+		},
+		"quorum counts ballots": {
+			Rules{Quorum: 3},
+			[]Tally{{OptionId: "yes", Votes: 10, Ballots: 2}, {OptionId: "no", Votes: 0, Ballots: 0}},
+			Outcome{
+				Result:  ResultNoQuorum,
+				Reason:  "2 of the 3 ballots needed for a quorum were cast",
+				Ballots: 2,
+				Votes:   10,
+			},
+		},
+		"tie": {
+			Rules{Threshold: &Threshold{Percentage: 50}},
+			[]Tally{{OptionId: "a", Votes: 4, Ballots: 4}, {OptionId: "b", Votes: 4, Ballots: 2}, {OptionId: "c", Votes: 1, Ballots: 1}},
+			Outcome{Result: ResultTie, TiedOptionIds: []string{"a", "b"}, Ballots: 7, Votes: 9},
+		},
+		"no votes": {
+			Rules{},
+			[]Tally{{OptionId: "yes"}, {OptionId: "no"}},
+			Outcome{Result: ResultFailed, Reason: "no votes were cast"},
+		},
+		"tie broken by earliest": {
+			Rules{TieBreak: TieBreakEarliest},
+			tied,
+			Outcome{Result: ResultPassed, OptionId: "b", TiedOptionIds: tiedOptionIds, Ballots: 7, Votes: 9},
+		},
+		"tie awaiting the owner": {
+			Rules{TieBreak: TieBreakOwner},
+			tied,
+			Outcome{Result: ResultTie, TiedOptionIds: tiedOptionIds, Ballots: 7, Votes: 9},
+		},
+		"tie broken by the owner": {
+			Rules{TieBreak: TieBreakOwner, TieBreakOptionId: "a"},
+			tied,
+			Outcome{Result: ResultPassed, OptionId: "a", TiedOptionIds: tiedOptionIds, Ballots: 7, Votes: 9},
+		},
+		"owner picked an option out of the tie": {
+			Rules{TieBreak: TieBreakOwner, TieBreakOptionId: "c"},
+			tied,
+			Outcome{Result: ResultTie, TiedOptionIds: tiedOptionIds, Ballots: 7, Votes: 9},
+		},
+		"broken tie short of the threshold": {
+			Rules{Threshold: &Threshold{Percentage: 50}, TieBreak: TieBreakEarliest},
+			tied,
+			Outcome{
+				Result:        ResultFailed,
+				Reason:        "the leading option got 44% of the votes, short of the 50% threshold",
+				OptionId:      "b",
+				TiedOptionIds: tiedOptionIds,
+				Ballots:       7,
+				Votes:         9,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			outcome := Decide(test.rules, test.tallies)
+			if !reflect.DeepEqual(outcome, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, outcome)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		rules Rules
+		err   error
+	}{
+		"none":             {Rules{}, nil},
+		"percentage":       {Rules{Quorum: 10, Threshold: &Threshold{Percentage: 60}}, nil},
+		"votes":            {Rules{Threshold: &Threshold{Votes: 5}}, nil},
+		"negative quorum":  {Rules{Quorum: -1}, ErrInvalidRules},
+		"empty threshold":  {Rules{Threshold: &Threshold{}}, ErrInvalidRules},
+		"both":             {Rules{Threshold: &Threshold{Percentage: 60, Votes: 5}}, ErrInvalidRules},
+		"over 100 percent": {Rules{Threshold: &Threshold{Percentage: 101}}, ErrInvalidRules},
+		"negative votes":   {Rules{Threshold: &Threshold{Votes: -1}}, ErrInvalidRules},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := test.rules.Validate(); !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	tallies := []Tally{
+		{OptionId: "a", Votes: 3},
+		{OptionId: "b", Votes: 1},
+		{OptionId: "c", Votes: 3},
+		{OptionId: "d", Votes: 0},
+	}
+
+	standings := Rank(tallies, &Outcome{Result: ResultPassed, OptionId: "c", TiedOptionIds: []string{"a", "c"}})
+
+	expected := []Standing{
+		{OptionId: "a", Rank: 1, Percentage: 42.9, IsTied: true},
+		{OptionId: "b", Rank: 3, Percentage: 14.3},
+		{OptionId: "c", Rank: 1, Percentage: 42.9, IsWinner: true, IsTied: true},
+		{OptionId: "d", Rank: 4, Percentage: 0},
+	}
+	if !reflect.DeepEqual(standings.Options, expected) {
+		t.Errorf("expected %+v, got %+v", expected, standings.Options)
+	}
+	if standings.TotalVotes != 7 {
+		t.Errorf("expected 7 votes in total, got %d", standings.TotalVotes)
+	}
+
+	tests := map[string]struct {
+		outcome  *Outcome
+		expected []string
+	}{
+		"open":     {nil, nil},
+		"passed":   {&Outcome{Result: ResultPassed, OptionId: "a"}, []string{"a"}},
+		"failed":   {&Outcome{Result: ResultFailed, OptionId: "a"}, nil},
+		"tie":      {&Outcome{Result: ResultTie, TiedOptionIds: []string{"a", "c"}}, nil},
+		"noQuorum": {&Outcome{Result: ResultNoQuorum}, nil},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			standings := Rank(tallies, test.outcome)
+			if !reflect.DeepEqual(standings.WinningOptionIds, test.expected) {
+				t.Errorf("expected winners %v, got %v", test.expected, standings.WinningOptionIds)
+			}
+		})
+	}
+}
+
+// The winners shown in the standings are the ones the poll was decided with, whatever the policy
+func TestRankAgreesWithDecide(t *testing.T) {
+	reached := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tallies := []Tally{
+		{OptionId: "a", Votes: 3, Ballots: 3, ReachedAt: reached.Add(time.Minute)},
+		{OptionId: "b", Votes: 3, Ballots: 3, ReachedAt: reached},
+		{OptionId: "c", Votes: 1, Ballots: 1},
+	}
+
+	for _, rules := range []Rules{
+		{TieBreak: TieBreakNone},
+		{TieBreak: TieBreakEarliest},
+		{TieBreak: TieBreakOwner},
+		{TieBreak: TieBreakOwner, TieBreakOptionId: "a"},
+		{TieBreak: TieBreakEarliest, Threshold: &Threshold{Percentage: 50}},
+	} {
+		outcome := Decide(rules, tallies)
+		standings := Rank(tallies, &outcome)
+
+		var expected []string
+		if outcome.Result == ResultPassed {
+			expected = []string{outcome.OptionId}
+		}
+		if !reflect.DeepEqual(standings.WinningOptionIds, expected) {
+			t.Errorf("%+v: expected winners %v for outcome %+v, got %v", rules, expected, outcome, standings.WinningOptionIds)
+		}
+	}
+}
+
+func TestRankWithoutVotes(t *testing.T) {
+	outcome := Decide(Rules{}, []Tally{{OptionId: "a"}, {OptionId: "b"}})
+	standings := Rank([]Tally{{OptionId: "a"}, {OptionId: "b"}}, &outcome)
+
+	if len(standings.WinningOptionIds) != 0 {
+		t.Errorf("expected no winners without votes, got %v", standings.WinningOptionIds)
+	}
+	for _, standing := range standings.Options {
+		if standing.Rank != 1 || !standing.IsTied || standing.IsWinner {
+			t.Errorf("expected options without votes to tie without winning, got %+v", standing)
+		}
+	}
+}
+
+func TestValidateTieBreak(t *testing.T) {
+	for _, tieBreak := range []string{"", TieBreakNone, TieBreakEarliest, TieBreakOwner} {
+		if err := ValidateTieBreak(tieBreak); err != nil {
+			t.Errorf("expected %q to be valid, got %v", tieBreak, err)
+		}
+	}
+
+	if err := ValidateTieBreak("coinFlip"); !errors.Is(err, ErrInvalidTieBreak) {
+		t.Errorf("expected %v, got %v", ErrInvalidTieBreak, err)
+	}
+}
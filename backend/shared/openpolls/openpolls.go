@@ -0,0 +1,19 @@
+// Package openpolls indexes the polls that are yet to close by when they expire, so the polls to publish
+// and close are queried without scanning the table. A poll is indexed when it's created, extended or
+// restored, and taken out of the index once it's closed, archived or deleted.
+package openpolls
+
+import "time"
+
+const (
+	// IndexName is the sparse index that only open polls are written to, under GSI2PK and GSI2SK
+	IndexName = "GSI2"
+
+	// Key is the partition key of every open poll in the index
+	Key = "open"
+)
+
+// SortKey is the poll's sort key in the index, when it expires in milliseconds since the epoch
+func SortKey(expiresAt time.Time) int64 {
+	return expiresAt.UnixMilli()
+}
@@ -0,0 +1,15 @@
+package openpolls
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortKey(t *testing.T) {
+	earlier := SortKey(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := SortKey(time.Date(2024, 1, 1, 0, 0, 0, int(500*time.Millisecond), time.UTC))
+
+	if earlier != 1704067200000 || later != 1704067200500 {
+		t.Errorf("expected expiries in milliseconds, got %d and %d", earlier, later)
+	}
+}
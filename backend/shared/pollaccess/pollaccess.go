@@ -0,0 +1,162 @@
+// Package pollaccess decides what a user may do with a poll. The poll's creator owns it, and can add other
+// users to it as co-owners, who manage it alongside them, or as viewers, who see it as its owner does.
+package pollaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	RoleOwner   = "owner"
+	RoleCoOwner = "coOwner"
+	RoleViewer  = "viewer"
+)
+
+// Actions are what a role allows. Viewing shows an archived poll and what only its owner is otherwise shown,
+// managing changes the poll, and deleting it or changing its members is left to its owner.
+const (
+	ActionView          = "view"
+	ActionManage        = "manage"
+	ActionDelete        = "delete"
+	ActionManageMembers = "manageMembers"
+)
+
+// MemberPrefix prefixes the sort key of a poll's membership items, which share the poll's partition. Memberships
+// are also indexed under their user in GSI1, sorted by the same prefix and the poll's id, so they're found with
+// the user's other items.
+const MemberPrefix = "member|"
+
+var actionsByRole = map[string][]string{
+	RoleOwner:   {ActionView, ActionManage, ActionDelete, ActionManageMembers},
+	RoleCoOwner: {ActionView, ActionManage},
+	RoleViewer:  {ActionView},
+}
+
+var (
+	ErrForbidden   = errors.New("user isn't allowed to do this with the poll")
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// DdbMember is a user's membership of a poll
+type DdbMember struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkUserId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
+	Role         string `dynamodbav:"Role"`
+	AddedAt      string `dynamodbav:"AddedAt"`
+	UpdatedAt    string `dynamodbav:"UpdatedAt,omitempty"`
+}
+
+// ItemGetter is the part of the DynamoDB client that GetRole uses.
+type ItemGetter interface {
+	GetItem(
+		ctx context.Context,
+		params *dynamodb.GetItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.GetItemOutput, error)
+}
+
+// MemberKey returns the sort key of the user's membership of a poll
+func MemberKey(userId string) string {
+	return fmt.Sprintf("%s%s", MemberPrefix, userId)
+}
+
+// MembershipKey returns the GSI1 sort key of the user's membership of a poll
+func MembershipKey(pollId string) string {
+	return fmt.Sprintf("%s%s", MemberPrefix, pollId)
+}
+
+// ValidateRole checks the role can be given to a member. A poll has a single owner, its creator.
+func ValidateRole(role string) error {
+	if role != RoleCoOwner && role != RoleViewer {
+		return fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+
+	return nil
+}
+
+// Can reports whether the role allows the action, where an empty role is a user who isn't a member
+func Can(role string, action string) bool {
+	for _, allowed := range actionsByRole[role] {
+		if allowed == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetRole returns the user's role on the poll, given the poll's GSI1PK, or an empty role if they're
+// neither its owner nor a member. Memberships are read consistently, so a removed member loses their role
+// straight away.
+func GetRole(
+	ctx context.Context,
+	ddb ItemGetter,
+	tableName string,
+	pollId string,
+	pollOwner string,
+	userId string,
+) (string, error) {
+	if userId == "" {
+		return "", nil
+	}
+	if pollOwner == fmt.Sprintf("user|%s", userId) {
+		return RoleOwner, nil
+	}
+
+	memberResult, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{
+				Value: fmt.Sprintf("poll|%s", pollId),
+			},
+			"SK": &types.AttributeValueMemberS{
+				Value: MemberKey(userId),
+			},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if memberResult.Item == nil {
+		return "", nil
+	}
+
+	var ddbMember DdbMember
+	if err := attributevalue.UnmarshalMap(memberResult.Item, &ddbMember); err != nil {
+		return "", err
+	}
+
+	return ddbMember.Role, nil
+}
+
+// Authorize returns the user's role on the poll if it allows the action, or ErrForbidden with the role,
+// which is empty if the user isn't a member, so callers can hide the poll from users who aren't.
+func Authorize(
+	ctx context.Context,
+	ddb ItemGetter,
+	tableName string,
+	pollId string,
+	pollOwner string,
+	userId string,
+	action string,
+) (string, error) {
+	role, err := GetRole(ctx, ddb, tableName, pollId, pollOwner, userId)
+	if err != nil {
+		return "", err
+	}
+	if !Can(role, action) {
+		return role, fmt.Errorf("%w: %s", ErrForbidden, action)
+	}
+
+	return role, nil
+}
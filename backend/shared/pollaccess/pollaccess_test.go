@@ -0,0 +1,166 @@
+package pollaccess
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type fakeGetter struct {
+	members map[string]string
+	gets    int
+}
+
+func (g *fakeGetter) GetItem(
+	ctx context.Context,
+	params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.GetItemOutput, error) {
+	g.gets++
+
+	sk := params.Key["SK"].(*types.AttributeValueMemberS).Value
+	role, ok := g.members[sk]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"PK":   params.Key["PK"],
+			"SK":   params.Key["SK"],
+			"Role": &types.AttributeValueMemberS{Value: role},
+		},
+	}, nil
+}
+
+func TestCan(t *testing.T) {
+	tests := map[string]struct {
+		role    string
+		allowed []string
+	}{
+		"owner":      {role: RoleOwner, allowed: []string{ActionView, ActionManage, ActionDelete, ActionManageMembers}},
+		"co-owner":   {role: RoleCoOwner, allowed: []string{ActionView, ActionManage}},
+		"viewer":     {role: RoleViewer, allowed: []string{ActionView}},
+		"not member": {role: ""},
+		"unknown":    {role: "admin"},
+	}
+
+	actions := []string{ActionView, ActionManage, ActionDelete, ActionManageMembers}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, action := range actions {
+				expected := false
+				for _, allowed := range test.allowed {
+					if allowed == action {
+						expected = true
+					}
+				}
+
+				if can := Can(test.role, action); can != expected {
+					t.Errorf("%s: expected %t, got %t", action, expected, can)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRole(t *testing.T) {
+	tests := map[string]struct {
+		role string
+		err  error
+	}{
+		"co-owner": {role: RoleCoOwner},
+		"viewer":   {role: RoleViewer},
+		"owner":    {role: RoleOwner, err: ErrInvalidRole},
+		"empty":    {role: "", err: ErrInvalidRole},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateRole(test.role); !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	getter := &fakeGetter{
+		members: map[string]string{
+			MemberKey("user2"): RoleCoOwner,
+			MemberKey("user3"): RoleViewer,
+		},
+	}
+
+	tests := map[string]struct {
+		userId string
+		action string
+		role   string
+		err    error
+		gets   int
+	}{
+		"owner":             {userId: "user1", action: ActionDelete, role: RoleOwner},
+		"co-owner manages":  {userId: "user2", action: ActionManage, role: RoleCoOwner, gets: 1},
+		"co-owner deletes":  {userId: "user2", action: ActionDelete, role: RoleCoOwner, err: ErrForbidden, gets: 1},
+		"viewer views":      {userId: "user3", action: ActionView, role: RoleViewer, gets: 1},
+		"viewer manages":    {userId: "user3", action: ActionManage, role: RoleViewer, err: ErrForbidden, gets: 1},
+		"not member":        {userId: "user4", action: ActionView, err: ErrForbidden, gets: 1},
+		"not authenticated": {userId: "", action: ActionView, err: ErrForbidden},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			getter.gets = 0
+
+			role, err := Authorize(context.Background(), getter, "table", "poll1", "user|user1", test.userId, test.action)
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+			if role != test.role {
+				t.Errorf("expected role %q, got %q", test.role, role)
+			}
+			if getter.gets != test.gets {
+				t.Errorf("expected %d reads, got %d", test.gets, getter.gets)
+			}
+		})
+	}
+}
+
+func TestGetRoleReadsMembership(t *testing.T) {
+	var input *dynamodb.GetItemInput
+	getter := getterFunc(func(params *dynamodb.GetItemInput) {
+		input = params
+	})
+
+	if _, err := GetRole(context.Background(), getter, "table", "poll1", "user|user1", "user2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.ToString(input.TableName) != "table" {
+		t.Errorf("expected table, got %s", aws.ToString(input.TableName))
+	}
+	if pk := input.Key["PK"].(*types.AttributeValueMemberS).Value; pk != "poll|poll1" {
+		t.Errorf("expected poll|poll1, got %s", pk)
+	}
+	if sk := input.Key["SK"].(*types.AttributeValueMemberS).Value; sk != "member|user2" {
+		t.Errorf("expected member|user2, got %s", sk)
+	}
+	if !aws.ToBool(input.ConsistentRead) {
+		t.Error("expected a consistent read")
+	}
+}
+
+type getterFunc func(params *dynamodb.GetItemInput)
+
+func (f getterFunc) GetItem(
+	ctx context.Context,
+	params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.GetItemOutput, error) {
+	f(params)
+	return &dynamodb.GetItemOutput{}, nil
+}
@@ -0,0 +1,342 @@
+// Package pollchange derives the user-visible changes of a poll from the old and new images of its
+// DynamoDB stream record.
+package pollchange
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+// Image is a poll item as it appears in a DynamoDB stream record.
+type Image struct {
+	PollId struct {
+		S string `json:"S"`
+	} `json:"PK"`
+	UserId struct {
+		S string `json:"S"`
+	} `json:"GSI1PK"`
+	Prompt struct {
+		S string `json:"S"`
+	} `json:"Prompt"`
+	CreatedAt struct {
+		S string `json:"S"`
+	} `json:"CreatedAt"`
+	Duration struct {
+		N string `json:"N"`
+	} `json:"Duration"`
+	IsArchived struct {
+		BOOL bool `json:"BOOL"`
+	} `json:"IsArchived"`
+	Version struct {
+		N string `json:"N"`
+	} `json:"Version"`
+	EditedAt struct {
+		S string `json:"S"`
+	} `json:"EditedAt"`
+	EditedOptions struct {
+		L []struct {
+			M struct {
+				OptionId struct {
+					S string `json:"S"`
+				} `json:"OptionId"`
+				Text struct {
+					S string `json:"S"`
+				} `json:"Text"`
+			} `json:"M"`
+		} `json:"L"`
+	} `json:"EditedOptions"`
+	DeletedAt struct {
+		S string `json:"S"`
+	} `json:"DeletedAt"`
+	AddedOption struct {
+		M struct {
+			OptionId struct {
+				S string `json:"S"`
+			} `json:"OptionId"`
+			Text struct {
+				S string `json:"S"`
+			} `json:"Text"`
+			Votes struct {
+				N string `json:"N"`
+			} `json:"Votes"`
+			AddedAt struct {
+				S string `json:"S"`
+			} `json:"AddedAt"`
+		} `json:"M"`
+	} `json:"AddedOption"`
+	ClosedAt struct {
+		S string `json:"S"`
+	} `json:"ClosedAt"`
+	TieBreakOptionId struct {
+		S string `json:"S"`
+	} `json:"TieBreakOptionId"`
+	Outcome struct {
+		M struct {
+			Result struct {
+				S string `json:"S"`
+			} `json:"Result"`
+			Reason struct {
+				S string `json:"S"`
+			} `json:"Reason"`
+			OptionId struct {
+				S string `json:"S"`
+			} `json:"OptionId"`
+			TiedOptionIds struct {
+				L []struct {
+					S string `json:"S"`
+				} `json:"L"`
+			} `json:"TiedOptionIds"`
+			Ballots struct {
+				N string `json:"N"`
+			} `json:"Ballots"`
+			Votes struct {
+				N string `json:"N"`
+			} `json:"Votes"`
+		} `json:"M"`
+	} `json:"Outcome"`
+}
+
+// Change is a user-visible change to a poll, with the type and data of the message that announces it.
+type Change struct {
+	Type string
+	Data interface{}
+}
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+// Version returns the poll's version in the image, treating polls modified before versioning as 0.
+func Version(image Image) (int64, error) {
+	if image.Version.N == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(image.Version.N, 10, 64)
+}
+
+// ExpiresAt returns when the poll stops accepting votes according to the image.
+func ExpiresAt(image Image) (time.Time, error) {
+	createdAt, err := time.Parse(protocol.RFC3339Milli, image.CreatedAt.S)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	duration, err := strconv.ParseInt(image.Duration.N, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return createdAt.Add(time.Duration(duration) * time.Second), nil
+}
+
+// Diff compares the poll's old and new images and returns a change for each user-visible difference.
+// Changes to the version alone, which write-ins that aren't shown make, return no changes. A poll whose
+// new expiry is no later than modifiedAt was closed early by the modification. Deleting a poll is its
+// only change, and the poll's modifications while it is deleted return no changes.
+func Diff(newImage Image, oldImage Image, modifiedAt time.Time) ([]Change, error) {
+	pollId := stripPrefix(newImage.PollId.S, "poll|")
+
+	if newImage.DeletedAt.S != "" {
+		if oldImage.DeletedAt.S != "" {
+			return nil, nil
+		}
+
+		return []Change{
+			{
+				Type: protocol.TypePollDeleted,
+				Data: protocol.PollDeletedData{
+					PollId:    pollId,
+					DeletedAt: newImage.DeletedAt.S,
+				},
+			},
+		}, nil
+	}
+
+	var changes []Change
+
+	if newImage.IsArchived.BOOL != oldImage.IsArchived.BOOL {
+		change := Change{
+			Type: protocol.TypePollArchived,
+			Data: protocol.PollArchivalData{
+				PollId:     pollId,
+				IsArchived: newImage.IsArchived.BOOL,
+			},
+		}
+		if !newImage.IsArchived.BOOL {
+			change.Type = protocol.TypePollUnarchived
+		}
+
+		changes = append(changes, change)
+	}
+
+	if newImage.Duration.N != oldImage.Duration.N || newImage.CreatedAt.S != oldImage.CreatedAt.S {
+		newExpiresAt, err := ExpiresAt(newImage)
+		if err != nil {
+			return nil, err
+		}
+
+		previousExpiresAt, err := ExpiresAt(oldImage)
+		if err != nil {
+			return nil, err
+		}
+
+		duration, err := strconv.ParseInt(newImage.Duration.N, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		change := Change{
+			Data: protocol.PollExpiryData{
+				PollId:            pollId,
+				Duration:          duration,
+				PreviousExpiresAt: previousExpiresAt.UTC().Format(protocol.RFC3339Milli),
+				ExpiresAt:         newExpiresAt.UTC().Format(protocol.RFC3339Milli),
+			},
+		}
+
+		switch {
+		case newExpiresAt.After(previousExpiresAt):
+			change.Type = protocol.TypePollExtended
+		case !newExpiresAt.After(modifiedAt):
+			change.Type = protocol.TypePollClosedEarly
+		default:
+			change.Type = protocol.TypePollShortened
+		}
+
+		if !newExpiresAt.Equal(previousExpiresAt) {
+			changes = append(changes, change)
+		}
+	}
+
+	if newImage.Prompt.S != oldImage.Prompt.S || newImage.EditedAt.S != oldImage.EditedAt.S {
+		data := protocol.PollEditedData{
+			PollId:   pollId,
+			Prompt:   newImage.Prompt.S,
+			EditedAt: newImage.EditedAt.S,
+		}
+
+		// Edits record the options in their new order on the poll, so the stream carries them
+		for _, option := range newImage.EditedOptions.L {
+			data.Options = append(data.Options, protocol.EditedOption{
+				OptionId: option.M.OptionId.S,
+				Text:     option.M.Text.S,
+			})
+		}
+
+		changes = append(changes, Change{
+			Type: protocol.TypePollEdited,
+			Data: data,
+		})
+	}
+
+	// Like edits, written in options are recorded on the poll as they're shown to voters
+	addedOption := newImage.AddedOption.M
+	if addedOption.OptionId.S != "" && addedOption.OptionId.S != oldImage.AddedOption.M.OptionId.S {
+		votes, err := strconv.ParseInt(addedOption.Votes.N, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, Change{
+			Type: protocol.TypeOptionAdded,
+			Data: protocol.OptionAddedData{
+				PollId:   pollId,
+				OptionId: addedOption.OptionId.S,
+				Text:     addedOption.Text.S,
+				Votes:    votes,
+				AddedAt:  addedOption.AddedAt.S,
+			},
+		})
+	}
+
+	// The poll is closed once its outcome is decided, which reopening it clears
+	if newImage.ClosedAt.S != "" && newImage.ClosedAt.S != oldImage.ClosedAt.S {
+		outcome, err := newOutcome(newImage)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, Change{
+			Type: protocol.TypePollClosed,
+			Data: protocol.PollClosedData{
+				PollId:   pollId,
+				ClosedAt: newImage.ClosedAt.S,
+				Outcome:  outcome,
+			},
+		})
+	}
+
+	// The owner's pick decides the closed poll's tie, so it's announced with the poll's new outcome
+	tieBreakOptionId := newImage.TieBreakOptionId.S
+	if tieBreakOptionId != "" && tieBreakOptionId != oldImage.TieBreakOptionId.S {
+		outcome, err := newOutcome(newImage)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, Change{
+			Type: protocol.TypeTieBroken,
+			Data: protocol.TieBrokenData{
+				PollId:   pollId,
+				OptionId: tieBreakOptionId,
+				Outcome:  outcome,
+			},
+		})
+	}
+
+	return changes, nil
+}
+
+func newOutcome(image Image) (protocol.Outcome, error) {
+	outcome := image.Outcome.M
+
+	ballots, err := strconv.ParseInt(outcome.Ballots.N, 10, 64)
+	if err != nil {
+		return protocol.Outcome{}, err
+	}
+
+	votes, err := strconv.ParseInt(outcome.Votes.N, 10, 64)
+	if err != nil {
+		return protocol.Outcome{}, err
+	}
+
+	var tiedOptionIds []string
+	for _, optionId := range outcome.TiedOptionIds.L {
+		tiedOptionIds = append(tiedOptionIds, optionId.S)
+	}
+
+	return protocol.Outcome{
+		Result:        outcome.Result.S,
+		Reason:        outcome.Reason.S,
+		OptionId:      outcome.OptionId.S,
+		TiedOptionIds: tiedOptionIds,
+		Ballots:       ballots,
+		Votes:         votes,
+	}, nil
+}
+
+// Expired returns the change for the poll's removal by its time to live, given its image before the
+// removal. Polls that were deleted by their owner already announced their deletion, so their removal
+// returns no changes.
+func Expired(oldImage Image, expiredAt time.Time) []Change {
+	if oldImage.DeletedAt.S != "" {
+		return nil
+	}
+
+	return []Change{
+		{
+			Type: protocol.TypePollExpired,
+			Data: protocol.PollExpiredData{
+				PollId:    stripPrefix(oldImage.PollId.S, "poll|"),
+				ExpiredAt: expiredAt.UTC().Format(protocol.RFC3339Milli),
+			},
+		},
+	}
+}
@@ -0,0 +1,356 @@
+package pollchange
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+const newImage = `{
+	"PK": {"S": "poll|poll123"},
+	"SK": {"S": "poll|poll123"},
+	"GSI1PK": {"S": "user|user123"},
+	"GSI1SK": {"S": "user|user123"},
+	"Prompt": {"S": "Ship it?"},
+	"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+	"Duration": {"N": "3600"},
+	"IsArchived": {"BOOL": true},
+	"Version": {"N": "8"}
+}`
+
+const oldImage = `{
+	"PK": {"S": "poll|poll123"},
+	"SK": {"S": "poll|poll123"},
+	"GSI1PK": {"S": "user|user123"},
+	"GSI1SK": {"S": "user|user123"},
+	"Prompt": {"S": "Ship it?"},
+	"CreatedAt": {"S": "2024-01-01T00:00:00.000Z"},
+	"Duration": {"N": "3600"},
+	"IsArchived": {"BOOL": false},
+	"Version": {"N": "7"}
+}`
+
+var modifiedAt = time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+func images(t *testing.T) (Image, Image) {
+	var newPoll, oldPoll Image
+	if err := json.Unmarshal([]byte(newImage), &newPoll); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(oldImage), &oldPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	return newPoll, oldPoll
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(newPoll *Image, oldPoll *Image)
+		expected []string
+	}{
+		{
+			name: "version only",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				oldPoll.IsArchived.BOOL = true
+			},
+			expected: nil,
+		},
+		{
+			name:     "archived",
+			modify:   func(newPoll *Image, oldPoll *Image) {},
+			expected: []string{protocol.TypePollArchived},
+		},
+		{
+			name: "unarchived",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				newPoll.IsArchived.BOOL = false
+				oldPoll.IsArchived.BOOL = true
+			},
+			expected: []string{protocol.TypePollUnarchived},
+		},
+		{
+			name: "extended",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				oldPoll.IsArchived.BOOL = true
+				newPoll.Duration.N = "7200"
+			},
+			expected: []string{protocol.TypePollExtended},
+		},
+		{
+			name: "shortened",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				oldPoll.IsArchived.BOOL = true
+				newPoll.Duration.N = "2400"
+			},
+			expected: []string{protocol.TypePollShortened},
+		},
+		{
+			name: "closed early",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				oldPoll.IsArchived.BOOL = true
+				newPoll.Duration.N = "1800"
+			},
+			expected: []string{protocol.TypePollClosedEarly},
+		},
+		{
+			name: "edited and archived",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				newPoll.Prompt.S = "Ship it today?"
+			},
+			expected: []string{protocol.TypePollArchived, protocol.TypePollEdited},
+		},
+		{
+			name: "deleted",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				newPoll.DeletedAt.S = "2024-01-01T00:30:00.000Z"
+			},
+			expected: []string{protocol.TypePollDeleted},
+		},
+		{
+			name: "emptied after deletion",
+			modify: func(newPoll *Image, oldPoll *Image) {
+				newPoll.DeletedAt.S = "2024-01-01T00:30:00.000Z"
+				oldPoll.DeletedAt.S = "2024-01-01T00:30:00.000Z"
+				newPoll.Prompt.S = ""
+			},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		newPoll, oldPoll := images(t)
+		test.modify(&newPoll, &oldPoll)
+
+		changes, err := Diff(newPoll, oldPoll, modifiedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var types []string
+		for _, change := range changes {
+			types = append(types, change.Type)
+		}
+
+		if !reflect.DeepEqual(types, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, types)
+		}
+	}
+}
+
+func TestDiffExpiresAt(t *testing.T) {
+	newPoll, oldPoll := images(t)
+	oldPoll.IsArchived.BOOL = true
+	newPoll.Duration.N = "7200"
+
+	changes, err := Diff(newPoll, oldPoll, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := changes[0].Data.(protocol.PollExpiryData)
+	if data.PreviousExpiresAt != "2024-01-01T01:00:00Z" || data.ExpiresAt != "2024-01-01T02:00:00Z" {
+		t.Errorf("expected expiry to move from 01:00 to 02:00, got %s to %s", data.PreviousExpiresAt, data.ExpiresAt)
+	}
+}
+
+func TestDiffEditedOptions(t *testing.T) {
+	newPoll, oldPoll := images(t)
+	oldPoll.IsArchived.BOOL = true
+
+	edited := `{
+		"EditedAt": {"S": "2024-01-01T00:30:00.000Z"},
+		"EditedOptions": {"L": [
+			{"M": {"OptionId": {"S": "option2"}, "Text": {"S": "Not yet"}}},
+			{"M": {"OptionId": {"S": "option1"}, "Text": {"S": "Yes"}}}
+		]}
+	}`
+	if err := json.Unmarshal([]byte(edited), &newPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(newPoll, oldPoll, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != protocol.TypePollEdited {
+		t.Fatalf("expected an edit with the unchanged prompt, got %+v", changes)
+	}
+
+	expected := protocol.PollEditedData{
+		PollId:   "poll123",
+		Prompt:   "Ship it?",
+		EditedAt: "2024-01-01T00:30:00.000Z",
+		Options: []protocol.EditedOption{
+			{OptionId: "option2", Text: "Not yet"},
+			{OptionId: "option1", Text: "Yes"},
+		},
+	}
+	if data := changes[0].Data.(protocol.PollEditedData); !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %+v, got %+v", expected, data)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	_, oldPoll := images(t)
+
+	changes := Expired(oldPoll, modifiedAt)
+	if len(changes) != 1 || changes[0].Type != protocol.TypePollExpired {
+		t.Fatalf("expected the poll to expire, got %+v", changes)
+	}
+
+	expected := protocol.PollExpiredData{PollId: "poll123", ExpiredAt: "2024-01-01T00:30:00Z"}
+	if data := changes[0].Data.(protocol.PollExpiredData); data != expected {
+		t.Errorf("expected %+v, got %+v", expected, data)
+	}
+
+	oldPoll.DeletedAt.S = "2024-01-01T00:10:00.000Z"
+	if changes := Expired(oldPoll, modifiedAt); changes != nil {
+		t.Errorf("expected a deleted poll's removal to have no changes, got %+v", changes)
+	}
+}
+
+func TestDiffAddedOption(t *testing.T) {
+	newPoll, oldPoll := images(t)
+	oldPoll.IsArchived.BOOL = newPoll.IsArchived.BOOL
+
+	added := `{
+		"AddedOption": {"M": {
+			"OptionId": {"S": "option3"},
+			"Text": {"S": "Later"},
+			"Votes": {"N": "1"},
+			"AddedAt": {"S": "2024-01-01T00:30:00.000Z"}
+		}}
+	}`
+	if err := json.Unmarshal([]byte(added), &newPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(newPoll, oldPoll, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != protocol.TypeOptionAdded {
+		t.Fatalf("expected the written in option to be added, got %+v", changes)
+	}
+
+	expected := protocol.OptionAddedData{
+		PollId:   "poll123",
+		OptionId: "option3",
+		Text:     "Later",
+		Votes:    1,
+		AddedAt:  "2024-01-01T00:30:00.000Z",
+	}
+	if data := changes[0].Data.(protocol.OptionAddedData); !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %+v, got %+v", expected, data)
+	}
+
+	// Later votes leave the added option on the poll, which isn't announced again
+	if changes, err := Diff(newPoll, newPoll, modifiedAt); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes once the option was added, got %+v (%v)", changes, err)
+	}
+}
+
+func TestDiffClosed(t *testing.T) {
+	newPoll, oldPoll := images(t)
+	oldPoll.IsArchived.BOOL = newPoll.IsArchived.BOOL
+
+	closed := `{
+		"ClosedAt": {"S": "2024-01-01T01:00:00.000Z"},
+		"Outcome": {"M": {
+			"Result": {"S": "tie"},
+			"TiedOptionIds": {"L": [{"S": "option1"}, {"S": "option2"}]},
+			"Ballots": {"N": "4"},
+			"Votes": {"N": "6"}
+		}}
+	}`
+	if err := json.Unmarshal([]byte(closed), &newPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(newPoll, oldPoll, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != protocol.TypePollClosed {
+		t.Fatalf("expected the poll to close, got %+v", changes)
+	}
+
+	expected := protocol.PollClosedData{
+		PollId:   "poll123",
+		ClosedAt: "2024-01-01T01:00:00.000Z",
+		Outcome: protocol.Outcome{
+			Result:        "tie",
+			TiedOptionIds: []string{"option1", "option2"},
+			Ballots:       4,
+			Votes:         6,
+		},
+	}
+	if data := changes[0].Data.(protocol.PollClosedData); !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %+v, got %+v", expected, data)
+	}
+
+	if changes, err := Diff(newPoll, newPoll, modifiedAt); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes once the poll was closed, got %+v (%v)", changes, err)
+	}
+}
+
+func TestDiffTieBroken(t *testing.T) {
+	newPoll, oldPoll := images(t)
+	oldPoll.IsArchived.BOOL = newPoll.IsArchived.BOOL
+
+	closed := `{
+		"ClosedAt": {"S": "2024-01-01T01:00:00.000Z"},
+		"Outcome": {"M": {
+			"Result": {"S": "tie"},
+			"TiedOptionIds": {"L": [{"S": "option1"}, {"S": "option2"}]},
+			"Ballots": {"N": "4"},
+			"Votes": {"N": "6"}
+		}}
+	}`
+	if err := json.Unmarshal([]byte(closed), &oldPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := `{
+		"ClosedAt": {"S": "2024-01-01T01:00:00.000Z"},
+		"TieBreakOptionId": {"S": "option2"},
+		"Outcome": {"M": {
+			"Result": {"S": "passed"},
+			"OptionId": {"S": "option2"},
+			"TiedOptionIds": {"L": [{"S": "option1"}, {"S": "option2"}]},
+			"Ballots": {"N": "4"},
+			"Votes": {"N": "6"}
+		}}
+	}`
+	if err := json.Unmarshal([]byte(broken), &newPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Diff(newPoll, oldPoll, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != protocol.TypeTieBroken {
+		t.Fatalf("expected the tie to be broken, got %+v", changes)
+	}
+
+	expected := protocol.TieBrokenData{
+		PollId:   "poll123",
+		OptionId: "option2",
+		Outcome: protocol.Outcome{
+			Result:        "passed",
+			OptionId:      "option2",
+			TiedOptionIds: []string{"option1", "option2"},
+			Ballots:       4,
+			Votes:         6,
+		},
+	}
+	if data := changes[0].Data.(protocol.TieBrokenData); !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %+v, got %+v", expected, data)
+	}
+}
@@ -0,0 +1,673 @@
+// Package pollcreate creates a poll and its options in the single table, so every entry point that
+// creates polls writes the same items.
+package pollcreate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+type NanoIdOptions struct {
+	Alphabet string
+	Length   int
+}
+
+// Limits bound what a poll may contain. The API validates requests against the same limits with its
+// models, but polls created elsewhere have to be validated with Validate.
+type Limits struct {
+	PromptMinLength int
+	PromptMaxLength int
+	OptionMinLength int
+	OptionMaxLength int
+	MinOptions      int
+	MaxOptions      int
+	MinDuration     int
+	MaxDuration     int
+
+	// DescriptionMaxLength and UrlMaxLength bound an option's content, and ImageMaxSize its image in bytes
+	DescriptionMaxLength int
+	UrlMaxLength         int
+	ImageMaxSize         int
+
+	// MaxVoteWeight bounds each weight of a poll's vote weights, and MaxVoteWeights how many voters and
+	// groups they may list
+	MaxVoteWeight  int
+	MaxVoteWeights int
+}
+
+// OptionContent is what an option can show besides its text, all of which is optional. ImageUrl is where
+// the option's uploaded image is served from.
+type OptionContent struct {
+	Description string
+	Url         string
+	ImageUrl    string
+}
+
+// VoteWeights weigh the votes of a poll's voters, either by voter or by a group that the voter's token
+// puts them in. Voters in several listed groups vote with the greatest of their weights, and voters that
+// aren't listed vote with Default, which is 1 when it's unset; a default of 0 only lets listed voters vote.
+type VoteWeights struct {
+	Users   map[string]int `json:"users,omitempty" dynamodbav:"Users,omitempty"`
+	Groups  map[string]int `json:"groups,omitempty" dynamodbav:"Groups,omitempty"`
+	Default *int           `json:"default,omitempty" dynamodbav:"Default,omitempty"`
+}
+
+// Input is what the creator of a poll chooses. RetentionDays is how many days after it closes that the
+// poll is purged, where 0 keeps it, and SeriesId links an occurrence of a recurring poll to its series.
+// OptionContents holds the content of the option with the same index, so options past its end have none.
+// AllowWriteIns lets voters add options by voting for them, which ModerateWriteIns holds for the owner.
+// VoteWeights weighs the votes, which are all counted once when it's nil.
+type Input struct {
+	UserId           string
+	Prompt           string
+	Options          []string
+	OptionContents   []OptionContent
+	Duration         int
+	RetentionDays    int
+	SeriesId         string
+	AllowWriteIns    bool
+	ModerateWriteIns bool
+	VoteWeights      *VoteWeights
+	Quorum           int
+	Threshold        *decision.Threshold
+	TieBreak         string
+}
+
+type DdbPoll struct {
+	PkPollId     string `dynamodbav:"PK"`
+	SkPollId     string `dynamodbav:"SK"`
+	Gsi1PkUserId string `dynamodbav:"GSI1PK"`
+	Gsi1SkUserId string `dynamodbav:"GSI1SK"`
+	Prompt       string `dynamodbav:"Prompt"`
+	CreatedAt    string `dynamodbav:"CreatedAt"`
+	Duration     int    `dynamodbav:"Duration"`
+	IsArchived   bool   `dynamodbav:"IsArchived"`
+	Version      int64  `dynamodbav:"Version"`
+	Ttl          int64  `dynamodbav:"Ttl,omitempty"`
+	SeriesId     string `dynamodbav:"SeriesId,omitempty"`
+
+	// RetentionDays is how many days after it closes that the poll is purged, so its time to live can be
+	// counted again when its close time changes
+	RetentionDays int `dynamodbav:"RetentionDays,omitempty"`
+
+	// LastOptionIndex is the index of the poll's last option, which an option written in takes the next of
+	LastOptionIndex int `dynamodbav:"LastOptionIndex"`
+
+	// The poll is open until it's closed, so it's created in the index of open polls
+	Gsi2PkOpen      string `dynamodbav:"GSI2PK"`
+	Gsi2SkExpiresAt int64  `dynamodbav:"GSI2SK"`
+
+	AllowWriteIns    bool         `dynamodbav:"AllowWriteIns,omitempty"`
+	ModerateWriteIns bool         `dynamodbav:"ModerateWriteIns,omitempty"`
+	VoteWeights      *VoteWeights `dynamodbav:"VoteWeights,omitempty"`
+
+	// Quorum and Threshold are the rules that decide the poll's outcome when it closes
+	Quorum    int                 `dynamodbav:"Quorum,omitempty"`
+	Threshold *decision.Threshold `dynamodbav:"Threshold,omitempty"`
+
+	// TieBreak decides which of the options that share the lead wins
+	TieBreak string `dynamodbav:"TieBreak,omitempty"`
+}
+
+type DdbOption struct {
+	PkOptionId   string `dynamodbav:"PK"`
+	SkOptionId   string `dynamodbav:"SK"`
+	Gsi1PkPollId string `dynamodbav:"GSI1PK"`
+	Gsi1SkPollId string `dynamodbav:"GSI1SK"`
+	Index        int    `dynamodbav:"Index"`
+	Text         string `dynamodbav:"Text"`
+	Description  string `dynamodbav:"Description,omitempty"`
+	Url          string `dynamodbav:"Url,omitempty"`
+	ImageUrl     string `dynamodbav:"ImageUrl,omitempty"`
+	UpdatedAt    string `dynamodbav:"UpdatedAt"`
+	Votes        int    `dynamodbav:"Votes"`
+	Version      int64  `dynamodbav:"Version"`
+
+	// Ballots counts the votes for the option once each, while Votes sums their weights
+	Ballots int `dynamodbav:"Ballots"`
+
+	// WriteInStatus is only set on options that voters wrote in
+	WriteInStatus string `dynamodbav:"WriteInStatus,omitempty"`
+}
+
+type Poll struct {
+	PollId           string       `json:"pollId"`
+	UserId           string       `json:"userId"`
+	Prompt           string       `json:"prompt"`
+	Options          []Option     `json:"options"`
+	CreatedAt        string       `json:"createdAt"`
+	Duration         int          `json:"duration"`
+	IsArchived       bool         `json:"isArchived"`
+	AllowWriteIns    bool         `json:"allowWriteIns"`
+	ModerateWriteIns bool         `json:"moderateWriteIns"`
+	VoteWeights      *VoteWeights `json:"voteWeights,omitempty"`
+
+	Quorum    int                 `json:"quorum,omitempty"`
+	Threshold *decision.Threshold `json:"threshold,omitempty"`
+	TieBreak  string              `json:"tieBreak,omitempty"`
+}
+
+type Option struct {
+	OptionId    string `json:"optionId"`
+	Text        string `json:"text"`
+	Description string `json:"description,omitempty"`
+	Url         string `json:"url,omitempty"`
+	ImageUrl    string `json:"imageUrl,omitempty"`
+	UpdatedAt   string `json:"updatedAt"`
+	Votes       int    `json:"votes"`
+	Ballots     int    `json:"ballots"`
+	IsMyVote    bool   `json:"isMyVote"`
+}
+
+// TransactWriter is the part of the DynamoDB client that Create uses.
+type TransactWriter interface {
+	TransactWriteItems(
+		ctx context.Context,
+		params *dynamodb.TransactWriteItemsInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+const (
+	RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+)
+
+// Statuses of written in options. Only approved ones are shown to anyone but the poll's owner.
+const (
+	WriteInStatusPending  = "pending"
+	WriteInStatusApproved = "approved"
+	WriteInStatusRejected = "rejected"
+)
+
+// IsShown reports whether an option with the write-in status is shown to voters. Options that weren't
+// written in have no status.
+func IsShown(writeInStatus string) bool {
+	return writeInStatus == "" || writeInStatus == WriteInStatusApproved
+}
+
+// DefaultLimits match the defaults of the API's models.
+var DefaultLimits = Limits{
+	PromptMinLength: 1,
+	PromptMaxLength: 280,
+	OptionMinLength: 1,
+	OptionMaxLength: 35,
+	MinOptions:      2,
+	MaxOptions:      10,
+	MinDuration:     60,
+	MaxDuration:     604800,
+
+	DescriptionMaxLength: 280,
+	UrlMaxLength:         2048,
+	ImageMaxSize:         5242880,
+
+	MaxVoteWeight:  100,
+	MaxVoteWeights: 100,
+}
+
+// ImageContentTypes are the content types that an option's image may be uploaded with.
+var ImageContentTypes = []string{"image/gif", "image/jpeg", "image/png", "image/webp"}
+
+var ErrInvalidDuration = errors.New("duration must be greater than 0")
+
+func stripPrefix(s string, prefix string) string {
+	if len(s) > len(prefix) && s[0:len(prefix)] == prefix {
+		return s[len(prefix):]
+	}
+
+	return s
+}
+
+func GetNanoIdOptions() (NanoIdOptions, error) {
+	alphabet := os.Getenv("NANOID_ALPHABET")
+	length, err := strconv.Atoi(os.Getenv("NANOID_LENGTH"))
+	if err != nil {
+		return NanoIdOptions{}, err
+	}
+	if !(length > 2 && length < 36) {
+		return NanoIdOptions{}, errors.New("NANOID_LENGTH must be between 2 and 36")
+	}
+	return NanoIdOptions{
+		Alphabet: alphabet,
+		Length:   length,
+	}, nil
+}
+
+// GetLimits reads the limits from the environment, falling back to DefaultLimits for the unset ones.
+func GetLimits() (Limits, error) {
+	limits := DefaultLimits
+
+	for name, limit := range map[string]*int{
+		"PROMPT_MIN_LENGTH": &limits.PromptMinLength,
+		"PROMPT_MAX_LENGTH": &limits.PromptMaxLength,
+		"OPTION_MIN_LENGTH": &limits.OptionMinLength,
+		"OPTION_MAX_LENGTH": &limits.OptionMaxLength,
+		"MIN_OPTIONS":       &limits.MinOptions,
+		"MAX_OPTIONS":       &limits.MaxOptions,
+		"MIN_DURATION":      &limits.MinDuration,
+		"MAX_DURATION":      &limits.MaxDuration,
+
+		"DESCRIPTION_MAX_LENGTH": &limits.DescriptionMaxLength,
+		"URL_MAX_LENGTH":         &limits.UrlMaxLength,
+		"IMAGE_MAX_SIZE":         &limits.ImageMaxSize,
+
+		"MAX_VOTE_WEIGHT":  &limits.MaxVoteWeight,
+		"MAX_VOTE_WEIGHTS": &limits.MaxVoteWeights,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return Limits{}, fmt.Errorf("%s must be an integer: %w", name, err)
+		}
+
+		*limit = parsed
+	}
+
+	return limits, nil
+}
+
+// Validate checks the input against the limits, returning an error that can be shown to its creator.
+func (limits Limits) Validate(input Input) error {
+	if err := limits.ValidatePrompt(input.Prompt); err != nil {
+		return err
+	}
+
+	if err := limits.ValidateOptions(input.Options); err != nil {
+		return err
+	}
+
+	if len(input.OptionContents) > len(input.Options) {
+		return errors.New("there is more option content than there are options")
+	}
+
+	if err := limits.ValidateOptionContents(input.OptionContents); err != nil {
+		return err
+	}
+
+	if err := limits.ValidateVoteWeights(input.VoteWeights); err != nil {
+		return err
+	}
+
+	if err := (decision.Rules{Quorum: input.Quorum, Threshold: input.Threshold}).Validate(); err != nil {
+		return err
+	}
+
+	if err := decision.ValidateTieBreak(input.TieBreak); err != nil {
+		return err
+	}
+
+	if input.Duration < limits.MinDuration || input.Duration > limits.MaxDuration {
+		return fmt.Errorf(
+			"the duration must be between %d and %d seconds",
+			limits.MinDuration,
+			limits.MaxDuration,
+		)
+	}
+
+	return nil
+}
+
+func (limits Limits) ValidatePrompt(prompt string) error {
+	promptLength := utf8.RuneCountInString(prompt)
+	if promptLength < limits.PromptMinLength || promptLength > limits.PromptMaxLength {
+		return fmt.Errorf(
+			"the prompt must be between %d and %d characters",
+			limits.PromptMinLength,
+			limits.PromptMaxLength,
+		)
+	}
+
+	return nil
+}
+
+// ValidateOptions checks the texts of a poll's options, which must also be unique.
+func (limits Limits) ValidateOptions(options []string) error {
+	if len(options) < limits.MinOptions || len(options) > limits.MaxOptions {
+		return fmt.Errorf("a poll must have between %d and %d options", limits.MinOptions, limits.MaxOptions)
+	}
+
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		if err := limits.ValidateOption(option); err != nil {
+			return err
+		}
+
+		if seen[option] {
+			return fmt.Errorf("option %q is repeated", option)
+		}
+		seen[option] = true
+	}
+
+	return nil
+}
+
+func (limits Limits) ValidateOption(option string) error {
+	optionLength := utf8.RuneCountInString(option)
+	if optionLength < limits.OptionMinLength || optionLength > limits.OptionMaxLength {
+		return fmt.Errorf(
+			"each option must be between %d and %d characters",
+			limits.OptionMinLength,
+			limits.OptionMaxLength,
+		)
+	}
+
+	return nil
+}
+
+// CloseTtl is the time to live of a poll that's purged retentionDays after it closes at closesAt
+func CloseTtl(closesAt time.Time, retentionDays int) int64 {
+	return closesAt.AddDate(0, 0, retentionDays).Unix()
+}
+
+// WriteInKey is the sort key, under its poll, of the item that maps a written in option's text to the
+// option. Texts that differ only in case or surrounding space have the same key, so they're one option.
+func WriteInKey(text string) string {
+	return fmt.Sprintf("writein|%s", strings.ToLower(strings.TrimSpace(text)))
+}
+
+// ValidateOptionContents checks the descriptions and links of a poll's options. Links must be absolute
+// HTTP or HTTPS URLs, so they can't run scripts when they're followed.
+func (limits Limits) ValidateOptionContents(optionContents []OptionContent) error {
+	for _, optionContent := range optionContents {
+		if utf8.RuneCountInString(optionContent.Description) > limits.DescriptionMaxLength {
+			return fmt.Errorf("each option's description must be at most %d characters", limits.DescriptionMaxLength)
+		}
+
+		if optionContent.Url == "" {
+			continue
+		}
+
+		if len(optionContent.Url) > limits.UrlMaxLength {
+			return fmt.Errorf("each option's URL must be at most %d characters", limits.UrlMaxLength)
+		}
+
+		parsed, err := url.Parse(optionContent.Url)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("the URL %q isn't an absolute HTTP or HTTPS URL", optionContent.Url)
+		}
+	}
+
+	return nil
+}
+
+// ValidateVoteWeights checks that every weight is between 0 and the maximum weight. Groups are passed to
+// the vote queue as a comma-separated list, so their names can't contain commas.
+func (limits Limits) ValidateVoteWeights(weights *VoteWeights) error {
+	if weights == nil {
+		return nil
+	}
+
+	if len(weights.Users)+len(weights.Groups) > limits.MaxVoteWeights {
+		return fmt.Errorf("vote weights can list at most %d voters and groups", limits.MaxVoteWeights)
+	}
+
+	checkWeight := func(weight int) error {
+		if weight < 0 || weight > limits.MaxVoteWeight {
+			return fmt.Errorf("each vote weight must be between 0 and %d", limits.MaxVoteWeight)
+		}
+
+		return nil
+	}
+
+	for userId, weight := range weights.Users {
+		if userId == "" {
+			return errors.New("vote weights can't be given to an empty user ID")
+		}
+		if err := checkWeight(weight); err != nil {
+			return err
+		}
+	}
+
+	for group, weight := range weights.Groups {
+		if group == "" || strings.Contains(group, ",") {
+			return fmt.Errorf("the group %q must be non-empty and can't contain commas", group)
+		}
+		if err := checkWeight(weight); err != nil {
+			return err
+		}
+	}
+
+	if weights.Default != nil {
+		return checkWeight(*weights.Default)
+	}
+
+	return nil
+}
+
+// Weight is how much the vote of the voter, in the groups, counts for. A voter's own weight takes
+// precedence over the weights of their groups.
+func (weights *VoteWeights) Weight(userId string, groups []string) int {
+	if weights == nil {
+		return 1
+	}
+
+	if weight, ok := weights.Users[userId]; ok && userId != "" {
+		return weight
+	}
+
+	weight, inGroup := 0, false
+	for _, group := range groups {
+		if groupWeight, ok := weights.Groups[group]; ok && (!inGroup || groupWeight > weight) {
+			weight, inGroup = groupWeight, true
+		}
+	}
+	if inGroup {
+		return weight
+	}
+
+	if weights.Default != nil {
+		return *weights.Default
+	}
+
+	return 1
+}
+
+// ValidateImage checks the content type and size in bytes that an option's image is uploaded with.
+func (limits Limits) ValidateImage(contentType string, size int64) error {
+	allowed := false
+	for _, imageContentType := range ImageContentTypes {
+		if contentType == imageContentType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("the image's content type must be one of %v", ImageContentTypes)
+	}
+
+	if size < 1 || size > int64(limits.ImageMaxSize) {
+		return fmt.Errorf("the image must be between 1 and %d bytes", limits.ImageMaxSize)
+	}
+
+	return nil
+}
+
+// ImageKey returns the key of an image the user uploads for an option. It's prefixed with the user's id, so an
+// image one user uploaded can't be given to another user's options.
+func ImageKey(userId string, imageId string) string {
+	return fmt.Sprintf("%s/%s", userId, imageId)
+}
+
+// ValidateImageKey checks the image key was issued to the user for an image of their own
+func ValidateImageKey(userId string, imageKey string) error {
+	imageId, ok := strings.CutPrefix(imageKey, fmt.Sprintf("%s/", userId))
+	if !ok || imageId == "" || strings.Contains(imageId, "/") {
+		return fmt.Errorf("image %s wasn't uploaded by the user", imageKey)
+	}
+
+	return nil
+}
+
+// Create writes the poll and its options in a single transaction and returns the created poll.
+func Create(
+	ctx context.Context,
+	ddb TransactWriter,
+	tableName string,
+	nanoIdOptions NanoIdOptions,
+	input Input,
+	now time.Time,
+) (Poll, error) {
+	poll, transactItems, err := Prepare(tableName, nanoIdOptions, input, now)
+	if err != nil {
+		return Poll{}, err
+	}
+
+	_, err = ddb.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: transactItems,
+	})
+	if err != nil {
+		return Poll{}, err
+	}
+
+	return poll, nil
+}
+
+// Prepare builds the poll and the items that create it without writing them, so they can be written in
+// the same transaction as other items.
+func Prepare(
+	tableName string,
+	nanoIdOptions NanoIdOptions,
+	input Input,
+	now time.Time,
+) (Poll, []types.TransactWriteItem, error) {
+	if input.Duration < 1 {
+		return Poll{}, nil, ErrInvalidDuration
+	}
+
+	currentTime := now.UTC().Format(RFC3339Milli)
+
+	pollId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+	if err != nil {
+		return Poll{}, nil, err
+	}
+
+	ddbPoll := DdbPoll{
+		PkPollId:     fmt.Sprintf("poll|%s", pollId),
+		SkPollId:     fmt.Sprintf("poll|%s", pollId),
+		Gsi1PkUserId: fmt.Sprintf("user|%s", input.UserId),
+		Gsi1SkUserId: fmt.Sprintf("user|%s", input.UserId),
+		Prompt:       input.Prompt,
+		CreatedAt:    currentTime,
+		Duration:     input.Duration,
+		IsArchived:   false,
+		SeriesId:     input.SeriesId,
+
+		LastOptionIndex: len(input.Options) - 1,
+
+		Gsi2PkOpen:      openpolls.Key,
+		Gsi2SkExpiresAt: openpolls.SortKey(now.Add(time.Duration(input.Duration) * time.Second)),
+
+		AllowWriteIns:    input.AllowWriteIns,
+		ModerateWriteIns: input.AllowWriteIns && input.ModerateWriteIns,
+		VoteWeights:      input.VoteWeights,
+
+		Quorum:    input.Quorum,
+		Threshold: input.Threshold,
+		TieBreak:  input.TieBreak,
+	}
+	if input.RetentionDays > 0 {
+		ddbPoll.RetentionDays = input.RetentionDays
+		ddbPoll.Ttl = CloseTtl(now.Add(time.Duration(input.Duration)*time.Second), input.RetentionDays)
+	}
+
+	item, err := attributevalue.MarshalMap(ddbPoll)
+	if err != nil {
+		return Poll{}, nil, err
+	}
+
+	transactItems := []types.TransactWriteItem{
+		{
+			Put: &types.Put{
+				TableName: aws.String(tableName),
+				Item:      item,
+			},
+		},
+	}
+
+	options := make([]Option, 0, len(input.Options))
+	for index, text := range input.Options {
+		optionId, err := nanoid.Generate(nanoIdOptions.Alphabet, nanoIdOptions.Length)
+		if err != nil {
+			return Poll{}, nil, err
+		}
+
+		var optionContent OptionContent
+		if index < len(input.OptionContents) {
+			optionContent = input.OptionContents[index]
+		}
+
+		ddbOption := DdbOption{
+			PkOptionId:   fmt.Sprintf("option|%s", optionId),
+			SkOptionId:   fmt.Sprintf("option|%s", optionId),
+			Gsi1PkPollId: fmt.Sprintf("poll|%s", pollId),
+			Gsi1SkPollId: fmt.Sprintf("poll|%s", pollId),
+			Index:        index,
+			Text:         text,
+			Description:  optionContent.Description,
+			Url:          optionContent.Url,
+			ImageUrl:     optionContent.ImageUrl,
+			UpdatedAt:    currentTime,
+			Votes:        0,
+		}
+
+		item, err := attributevalue.MarshalMap(ddbOption)
+		if err != nil {
+			return Poll{}, nil, err
+		}
+
+		options = append(options, Option{
+			OptionId:    stripPrefix(ddbOption.PkOptionId, "option|"),
+			Text:        ddbOption.Text,
+			Description: ddbOption.Description,
+			Url:         ddbOption.Url,
+			ImageUrl:    ddbOption.ImageUrl,
+			UpdatedAt:   ddbOption.UpdatedAt,
+			Votes:       ddbOption.Votes,
+			Ballots:     ddbOption.Ballots,
+			IsMyVote:    false,
+		})
+
+		transactItems = append(transactItems, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(tableName),
+				Item:      item,
+			},
+		})
+	}
+
+	return Poll{
+		PollId:     stripPrefix(ddbPoll.PkPollId, "poll|"),
+		UserId:     stripPrefix(ddbPoll.Gsi1PkUserId, "user|"),
+		Prompt:     ddbPoll.Prompt,
+		Options:    options,
+		CreatedAt:  ddbPoll.CreatedAt,
+		Duration:   ddbPoll.Duration,
+		IsArchived: ddbPoll.IsArchived,
+
+		AllowWriteIns:    ddbPoll.AllowWriteIns,
+		ModerateWriteIns: ddbPoll.ModerateWriteIns,
+		VoteWeights:      ddbPoll.VoteWeights,
+
+		Quorum:    ddbPoll.Quorum,
+		Threshold: ddbPoll.Threshold,
+		TieBreak:  ddbPoll.TieBreak,
+	}, transactItems, nil
+}
@@ -0,0 +1,433 @@
+package pollcreate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/declanlscott/pseudopoll/backend/shared/decision"
+	"github.com/declanlscott/pseudopoll/backend/shared/openpolls"
+)
+
+type fakeWriter struct {
+	input *dynamodb.TransactWriteItemsInput
+	err   error
+}
+
+func (w *fakeWriter) TransactWriteItems(
+	ctx context.Context,
+	params *dynamodb.TransactWriteItemsInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.TransactWriteItemsOutput, error) {
+	w.input = params
+	return &dynamodb.TransactWriteItemsOutput{}, w.err
+}
+
+var nanoIdOptions = NanoIdOptions{Alphabet: "abcdefghijklmnopqrstuvwxyz", Length: 12}
+
+func TestValidate(t *testing.T) {
+	valid := Input{Prompt: "Ship it?", Options: []string{"Yes", "No"}, Duration: 3600}
+
+	tests := map[string]struct {
+		modify func(input *Input)
+		valid  bool
+	}{
+		"valid":             {modify: func(input *Input) {}, valid: true},
+		"empty prompt":      {modify: func(input *Input) { input.Prompt = "" }},
+		"long prompt":       {modify: func(input *Input) { input.Prompt = strings.Repeat("a", 281) }},
+		"multibyte prompt":  {modify: func(input *Input) { input.Prompt = strings.Repeat("é", 280) }, valid: true},
+		"one option":        {modify: func(input *Input) { input.Options = []string{"Yes"} }},
+		"too many options":  {modify: func(input *Input) { input.Options = strings.Split("abcdefghijk", "") }},
+		"empty option":      {modify: func(input *Input) { input.Options = []string{"Yes", ""} }},
+		"repeated option":   {modify: func(input *Input) { input.Options = []string{"Yes", "Yes"} }},
+		"short duration":    {modify: func(input *Input) { input.Duration = 59 }},
+		"long duration":     {modify: func(input *Input) { input.Duration = 604801 }},
+		"shortest duration": {modify: func(input *Input) { input.Duration = 60 }, valid: true},
+		"option content": {modify: func(input *Input) {
+			input.OptionContents = []OptionContent{{Description: "Ship today", Url: "https://example.com/rfc/1"}}
+		}, valid: true},
+		"long description": {modify: func(input *Input) {
+			input.OptionContents = []OptionContent{{Description: strings.Repeat("a", 281)}}
+		}},
+		"relative url": {modify: func(input *Input) { input.OptionContents = []OptionContent{{Url: "/rfc/1"}} }},
+		"script url":   {modify: func(input *Input) { input.OptionContents = []OptionContent{{Url: "javascript:alert(1)"}} }},
+		"extra content": {modify: func(input *Input) {
+			input.OptionContents = []OptionContent{{}, {}, {Description: "No option"}}
+		}},
+		"rules": {modify: func(input *Input) {
+			input.Quorum = 10
+			input.Threshold = &decision.Threshold{Percentage: 60}
+		}, valid: true},
+		"invalid threshold": {modify: func(input *Input) { input.Threshold = &decision.Threshold{Percentage: 60, Votes: 6} }},
+		"tie-break":         {modify: func(input *Input) { input.TieBreak = decision.TieBreakOwner }, valid: true},
+		"unknown tie-break": {modify: func(input *Input) { input.TieBreak = "coinFlip" }},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := valid
+			input.Options = append([]string(nil), valid.Options...)
+			test.modify(&input)
+
+			err := DefaultLimits.Validate(input)
+			if test.valid && err != nil {
+				t.Errorf("expected input to be valid, got %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected input to be invalid")
+			}
+		})
+	}
+}
+
+func TestGetLimits(t *testing.T) {
+	t.Setenv("MAX_OPTIONS", "4")
+
+	limits, err := GetLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := DefaultLimits
+	expected.MaxOptions = 4
+	if limits != expected {
+		t.Errorf("expected %+v, got %+v", expected, limits)
+	}
+
+	t.Setenv("MIN_DURATION", "soon")
+	if _, err := GetLimits(); err == nil {
+		t.Error("expected an error for a non-integer limit")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	writer := &fakeWriter{}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	poll, err := Create(
+		context.Background(),
+		writer,
+		"table",
+		nanoIdOptions,
+		Input{UserId: "user123", Prompt: "Ship it?", Options: []string{"Yes", "No"}, Duration: 3600},
+		now,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(poll.PollId) != nanoIdOptions.Length || poll.UserId != "user123" || poll.CreatedAt != "2024-01-01T00:00:00Z" {
+		t.Errorf("unexpected poll %+v", poll)
+	}
+	if len(poll.Options) != 2 || poll.Options[0].Text != "Yes" || poll.Options[1].Text != "No" {
+		t.Errorf("unexpected options %+v", poll.Options)
+	}
+
+	items := writer.input.TransactItems
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(items))
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(items[0].Put.Item, &ddbPoll); err != nil {
+		t.Fatal(err)
+	}
+	if ddbPoll.PkPollId != "poll|"+poll.PollId || ddbPoll.Gsi1PkUserId != "user|user123" {
+		t.Errorf("unexpected poll item %+v", ddbPoll)
+	}
+	if _, ok := items[0].Put.Item["Ttl"]; ok {
+		t.Errorf("expected a poll without retention to be kept, got %+v", items[0].Put.Item)
+	}
+
+	for index, item := range items[1:] {
+		var ddbOption DdbOption
+		if err := attributevalue.UnmarshalMap(item.Put.Item, &ddbOption); err != nil {
+			t.Fatal(err)
+		}
+		if ddbOption.PkOptionId != "option|"+poll.Options[index].OptionId ||
+			ddbOption.Gsi1PkPollId != "poll|"+poll.PollId ||
+			ddbOption.Index != index {
+			t.Errorf("unexpected option item %+v", ddbOption)
+		}
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	tests := []struct {
+		contentType string
+		size        int64
+		valid       bool
+	}{
+		{contentType: "image/png", size: 1024, valid: true},
+		{contentType: "image/webp", size: 5242880, valid: true},
+		{contentType: "image/svg+xml", size: 1024},
+		{contentType: "text/html", size: 1024},
+		{contentType: "image/png", size: 0},
+		{contentType: "image/png", size: 5242881},
+	}
+
+	for _, test := range tests {
+		err := DefaultLimits.ValidateImage(test.contentType, test.size)
+		if test.valid && err != nil {
+			t.Errorf("%s of %d bytes: expected it to be valid, got %s", test.contentType, test.size, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s of %d bytes: expected it to be invalid", test.contentType, test.size)
+		}
+	}
+}
+
+func TestValidateImageKey(t *testing.T) {
+	if key := ImageKey("user1", "image1"); key != "user1/image1" {
+		t.Errorf("expected the image key to be prefixed with the user's id, got %s", key)
+	}
+
+	tests := []struct {
+		imageKey string
+		valid    bool
+	}{
+		{imageKey: "user1/image1", valid: true},
+		{imageKey: "user2/image1"},
+		{imageKey: "user10/image1"},
+		{imageKey: "image1"},
+		{imageKey: "user1/"},
+		{imageKey: "user1/../user2/image1"},
+	}
+
+	for _, test := range tests {
+		err := ValidateImageKey("user1", test.imageKey)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected it to be valid, got %s", test.imageKey, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected it to be invalid", test.imageKey)
+		}
+	}
+}
+
+func TestCreateOptionContents(t *testing.T) {
+	writer := &fakeWriter{}
+
+	poll, err := Create(
+		context.Background(),
+		writer,
+		"table",
+		nanoIdOptions,
+		Input{
+			UserId:   "user123",
+			Prompt:   "Which mockup?",
+			Options:  []string{"A", "B"},
+			Duration: 3600,
+			OptionContents: []OptionContent{
+				{Description: "Dark theme", Url: "https://example.com/a", ImageUrl: "https://images.example.com/a"},
+			},
+		},
+		time.Now(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if poll.Options[0].Description != "Dark theme" || poll.Options[0].ImageUrl != "https://images.example.com/a" {
+		t.Errorf("expected the first option to have content, got %+v", poll.Options[0])
+	}
+
+	item := writer.input.TransactItems[2].Put.Item
+	for _, name := range []string{"Description", "Url", "ImageUrl"} {
+		if _, ok := item[name]; ok {
+			t.Errorf("expected an option without content not to store %s, got %+v", name, item)
+		}
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	input := Input{UserId: "user123", Prompt: "Ship it?", Options: []string{"Yes", "No"}, Duration: 0}
+
+	if _, err := Create(context.Background(), &fakeWriter{}, "table", nanoIdOptions, input, time.Now()); !errors.Is(err, ErrInvalidDuration) {
+		t.Errorf("expected ErrInvalidDuration, got %v", err)
+	}
+
+	input.Duration = 60
+	writeErr := errors.New("throttled")
+	if _, err := Create(context.Background(), &fakeWriter{err: writeErr}, "table", nanoIdOptions, input, time.Now()); !errors.Is(err, writeErr) {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
+
+func TestCreateRetention(t *testing.T) {
+	writer := &fakeWriter{}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := Create(
+		context.Background(),
+		writer,
+		"table",
+		nanoIdOptions,
+		Input{UserId: "user123", Prompt: "Ship it?", Options: []string{"Yes", "No"}, Duration: 3600, RetentionDays: 7},
+		now,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(writer.input.TransactItems[0].Put.Item, &ddbPoll); err != nil {
+		t.Fatal(err)
+	}
+
+	// 7 days after the poll closes at 01:00
+	if expected := time.Date(2024, 1, 8, 1, 0, 0, 0, time.UTC).Unix(); ddbPoll.Ttl != expected {
+		t.Errorf("expected the time to live %d, got %d", expected, ddbPoll.Ttl)
+	}
+	if ddbPoll.RetentionDays != 7 {
+		t.Errorf("expected the poll to keep its retention of 7 days, got %d", ddbPoll.RetentionDays)
+	}
+	if ddbPoll.LastOptionIndex != 1 {
+		t.Errorf("expected the poll's last option to be at index 1, got %d", ddbPoll.LastOptionIndex)
+	}
+}
+
+func TestPrepareSeries(t *testing.T) {
+	poll, transactItems, err := Prepare(
+		"table",
+		nanoIdOptions,
+		Input{UserId: "user123", Prompt: "Standup?", Options: []string{"Yes", "No"}, Duration: 3600, SeriesId: "series1"},
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactItems) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(transactItems))
+	}
+
+	var ddbPoll DdbPoll
+	if err := attributevalue.UnmarshalMap(transactItems[0].Put.Item, &ddbPoll); err != nil {
+		t.Fatal(err)
+	}
+	if ddbPoll.PkPollId != "poll|"+poll.PollId || ddbPoll.SeriesId != "series1" {
+		t.Errorf("expected the poll to be linked to its series, got %+v", ddbPoll)
+	}
+}
+
+func TestPrepareIndexesOpenPoll(t *testing.T) {
+	_, transactItems, err := Prepare(
+		"table",
+		nanoIdOptions,
+		Input{UserId: "user123", Prompt: "Standup?", Options: []string{"Yes", "No"}, Duration: 3600},
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := transactItems[0].Put.Item
+	if pk := item["GSI2PK"].(*types.AttributeValueMemberS).Value; pk != openpolls.Key {
+		t.Errorf("expected the poll to be indexed as open, got %s", pk)
+	}
+	if sk := item["GSI2SK"].(*types.AttributeValueMemberN).Value; sk != "1704070800000" {
+		t.Errorf("expected the poll to be indexed by when it expires, got %s", sk)
+	}
+}
+
+func TestPrepareWriteIns(t *testing.T) {
+	tests := []struct {
+		allowWriteIns    bool
+		moderateWriteIns bool
+		expected         bool
+	}{
+		{allowWriteIns: true, moderateWriteIns: true, expected: true},
+		{allowWriteIns: false, moderateWriteIns: true, expected: false},
+	}
+
+	for _, test := range tests {
+		_, transactItems, err := Prepare(
+			"table",
+			nanoIdOptions,
+			Input{
+				UserId:           "user123",
+				Prompt:           "Lunch?",
+				Options:          []string{"Tacos", "Pho"},
+				Duration:         3600,
+				AllowWriteIns:    test.allowWriteIns,
+				ModerateWriteIns: test.moderateWriteIns,
+			},
+			time.Now(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ddbPoll DdbPoll
+		if err := attributevalue.UnmarshalMap(transactItems[0].Put.Item, &ddbPoll); err != nil {
+			t.Fatal(err)
+		}
+		if ddbPoll.AllowWriteIns != test.allowWriteIns || ddbPoll.ModerateWriteIns != test.expected {
+			t.Errorf("expected write-ins to be moderated only when they're allowed, got %+v", ddbPoll)
+		}
+	}
+}
+
+func TestWriteInKey(t *testing.T) {
+	if WriteInKey("  Burritos ") != WriteInKey("burritos") || WriteInKey("Burritos") != "writein|burritos" {
+		t.Errorf("expected texts that differ in case and space to share a key, got %s", WriteInKey("  Burritos "))
+	}
+	if WriteInKey("Burritos") == WriteInKey("Burrito") {
+		t.Error("expected different texts to have different keys")
+	}
+}
+
+func TestVoteWeight(t *testing.T) {
+	zero := 0
+	weights := &VoteWeights{
+		Users:  map[string]int{"cto": 5, "intern": 0},
+		Groups: map[string]int{"architects": 3, "engineers": 2},
+	}
+
+	tests := map[string]struct {
+		weights  *VoteWeights
+		userId   string
+		groups   []string
+		expected int
+	}{
+		"unweighted":        {weights: nil, userId: "cto", expected: 1},
+		"voter":             {weights: weights, userId: "cto", groups: []string{"engineers"}, expected: 5},
+		"voter of 0":        {weights: weights, userId: "intern", groups: []string{"engineers"}, expected: 0},
+		"greatest group":    {weights: weights, userId: "dev", groups: []string{"engineers", "architects"}, expected: 3},
+		"unlisted":          {weights: weights, userId: "dev", groups: []string{"sales"}, expected: 1},
+		"unlisted excluded": {weights: &VoteWeights{Groups: weights.Groups, Default: &zero}, userId: "dev", expected: 0},
+	}
+
+	for name, test := range tests {
+		if weight := test.weights.Weight(test.userId, test.groups); weight != test.expected {
+			t.Errorf("%s: expected a weight of %d, got %d", name, test.expected, weight)
+		}
+	}
+}
+
+func TestValidateVoteWeights(t *testing.T) {
+	negative := -1
+
+	tests := map[string]*VoteWeights{
+		"too heavy":      {Users: map[string]int{"cto": DefaultLimits.MaxVoteWeight + 1}},
+		"negative":       {Default: &negative},
+		"comma in group": {Groups: map[string]int{"a,b": 2}},
+		"empty user":     {Users: map[string]int{"": 2}},
+	}
+
+	for name, weights := range tests {
+		if err := DefaultLimits.ValidateVoteWeights(weights); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if err := DefaultLimits.ValidateVoteWeights(&VoteWeights{Groups: map[string]int{"architects": 3}}); err != nil {
+		t.Errorf("expected the weights to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package pollsequence hands out the sequence numbers that order a poll's live updates. Votes and changes
+// to the poll take their numbers from one counter item per poll, so counting a vote never writes the poll's
+// own item, whose stream records announce changes to the poll.
+package pollsequence
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SequenceKey is the sort key of a poll's counter item, which shares the poll's partition
+const SequenceKey = "sequence"
+
+// DdbSequence is the last sequence number handed out for a poll
+type DdbSequence struct {
+	Sequence int64 `dynamodbav:"Sequence"`
+}
+
+// ItemUpdater is the part of the DynamoDB client that Next uses.
+type ItemUpdater interface {
+	UpdateItem(
+		ctx context.Context,
+		params *dynamodb.UpdateItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.UpdateItemOutput, error)
+}
+
+// ItemGetter is the part of the DynamoDB client that Current uses.
+type ItemGetter interface {
+	GetItem(
+		ctx context.Context,
+		params *dynamodb.GetItemInput,
+		optFns ...func(*dynamodb.Options),
+	) (*dynamodb.GetItemOutput, error)
+}
+
+// Key returns the key of the poll's counter item
+func Key(pollId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{
+			Value: fmt.Sprintf("poll|%s", pollId),
+		},
+		"SK": &types.AttributeValueMemberS{
+			Value: SequenceKey,
+		},
+	}
+}
+
+// NewNextInput increments the poll's counter unconditionally, so concurrent callers never fail, and returns the
+// new value. Polls sequenced before the counter existed carry their last number as the poll's version, the base.
+func NewNextInput(tableName string, pollId string, base int64) *dynamodb.UpdateItemInput {
+	return &dynamodb.UpdateItemInput{
+		TableName:        aws.String(tableName),
+		Key:              Key(pollId),
+		UpdateExpression: aws.String("SET #sequence = if_not_exists(#sequence, :base) + :one"),
+		ExpressionAttributeNames: map[string]string{
+			"#sequence": "Sequence",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":base": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(base, 10),
+			},
+			":one": &types.AttributeValueMemberN{
+				Value: "1",
+			},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+}
+
+// Next hands out the poll's next sequence number. A number whose write then fails is never published, which
+// subscribers see as a gap and resync from a snapshot, so callers should take one once their checks pass.
+func Next(ctx context.Context, ddb ItemUpdater, tableName string, pollId string, base int64) (int64, error) {
+	result, err := ddb.UpdateItem(ctx, NewNextInput(tableName, pollId, base))
+	if err != nil {
+		return 0, err
+	}
+
+	var ddbSequence DdbSequence
+	if err := attributevalue.UnmarshalMap(result.Attributes, &ddbSequence); err != nil {
+		return 0, err
+	}
+
+	return ddbSequence.Sequence, nil
+}
+
+// Current returns the last sequence number handed out for the poll, which a snapshot of the poll is taken at
+func Current(ctx context.Context, ddb ItemGetter, tableName string, pollId string, base int64) (int64, error) {
+	result, err := ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            Key(pollId),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var ddbSequence DdbSequence
+	if err := attributevalue.UnmarshalMap(result.Item, &ddbSequence); err != nil {
+		return 0, err
+	}
+
+	if ddbSequence.Sequence < base {
+		return base, nil
+	}
+
+	return ddbSequence.Sequence, nil
+}
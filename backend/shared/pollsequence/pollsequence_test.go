@@ -0,0 +1,154 @@
+package pollsequence
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeCounters applies the counter update the way DynamoDB does, atomically per item
+type fakeCounters struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+func (c *fakeCounters) UpdateItem(
+	ctx context.Context,
+	params *dynamodb.UpdateItemInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.UpdateItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pk := params.Key["PK"].(*types.AttributeValueMemberS).Value
+	sequence, ok := c.counters[pk]
+	if !ok {
+		base, err := strconv.ParseInt(params.ExpressionAttributeValues[":base"].(*types.AttributeValueMemberN).Value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		sequence = base
+	}
+	sequence++
+	c.counters[pk] = sequence
+
+	return &dynamodb.UpdateItemOutput{
+		Attributes: map[string]types.AttributeValue{
+			"Sequence": &types.AttributeValueMemberN{Value: strconv.FormatInt(sequence, 10)},
+		},
+	}, nil
+}
+
+func (c *fakeCounters) GetItem(
+	ctx context.Context,
+	params *dynamodb.GetItemInput,
+	optFns ...func(*dynamodb.Options),
+) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sequence, ok := c.counters[params.Key["PK"].(*types.AttributeValueMemberS).Value]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"Sequence": &types.AttributeValueMemberN{Value: strconv.FormatInt(sequence, 10)},
+		},
+	}, nil
+}
+
+func TestNewNextInput(t *testing.T) {
+	input := NewNextInput("table", "poll1", 7)
+
+	if sk := input.Key["SK"].(*types.AttributeValueMemberS).Value; sk != SequenceKey {
+		t.Errorf("expected %s, got %s", SequenceKey, sk)
+	}
+	if input.ConditionExpression != nil {
+		t.Errorf("expected no condition, got %s", aws.ToString(input.ConditionExpression))
+	}
+	if base := input.ExpressionAttributeValues[":base"].(*types.AttributeValueMemberN).Value; base != "7" {
+		t.Errorf("expected base 7, got %s", base)
+	}
+}
+
+func TestNextContinuesFromBase(t *testing.T) {
+	counters := &fakeCounters{counters: map[string]int64{}}
+
+	for _, expected := range []int64{8, 9, 10} {
+		sequence, err := Next(context.Background(), counters, "table", "poll1", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sequence != expected {
+			t.Errorf("expected %d, got %d", expected, sequence)
+		}
+	}
+}
+
+func TestNextIsUniqueUnderConcurrency(t *testing.T) {
+	counters := &fakeCounters{counters: map[string]int64{}}
+
+	const callers = 50
+	sequences := make(chan int64, callers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sequence, err := Next(context.Background(), counters, "table", "poll1", 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			sequences <- sequence
+		}()
+	}
+	wg.Wait()
+	close(sequences)
+
+	seen := make(map[int64]bool)
+	for sequence := range sequences {
+		if seen[sequence] {
+			t.Errorf("sequence %d handed out twice", sequence)
+		}
+		seen[sequence] = true
+	}
+	if len(seen) != callers {
+		t.Errorf("expected %d sequences, got %d", callers, len(seen))
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	counters := &fakeCounters{counters: map[string]int64{"poll|poll2": 12}}
+
+	tests := map[string]struct {
+		pollId   string
+		base     int64
+		expected int64
+	}{
+		"no counter yet":   {pollId: "poll1", base: 4, expected: 4},
+		"counter":          {pollId: "poll2", base: 4, expected: 12},
+		"base ahead of it": {pollId: "poll2", base: 15, expected: 15},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sequence, err := Current(context.Background(), counters, "table", test.pollId, test.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sequence != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, sequence)
+			}
+		})
+	}
+}
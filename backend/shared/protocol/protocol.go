@@ -0,0 +1,240 @@
+// Package protocol defines the messages published to clients over MQTT.
+//
+// Every message is wrapped in a versioned Envelope. The JSON Schema in schema.json and the
+// frontend's types/protocol/generated.ts are generated from these types; run `go generate ./...`
+// after changing them.
+package protocol
+
+//go:generate go run ./schemagen -o schema.json -ts ../../../frontend/types/protocol/generated.ts
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"time"
+)
+
+// Schema is the generated JSON Schema that every published message must validate against.
+//
+//go:embed schema.json
+var Schema []byte
+
+// Version is bumped whenever a change to the envelope or a message's data isn't backwards compatible.
+const Version = 1
+
+const RFC3339Milli = "2006-01-02T15:04:05.999Z07:00"
+
+// Message types published to poll/{pollId}
+const (
+	TypeVoteCounted     = "voteCounted"
+	TypeVotesCounted    = "votesCounted"
+	TypePollArchived    = "pollArchived"
+	TypePollUnarchived  = "pollUnarchived"
+	TypePollExtended    = "pollExtended"
+	TypePollShortened   = "pollShortened"
+	TypePollClosedEarly = "pollClosedEarly"
+	TypePollEdited      = "pollEdited"
+	TypePollSnapshot    = "pollSnapshot"
+	TypePollDeleted     = "pollDeleted"
+	TypePollExpired     = "pollExpired"
+	TypeOptionAdded     = "optionAdded"
+	TypePollClosed      = "pollClosed"
+	TypeTieBroken       = "tieBroken"
+)
+
+// Message types published to vote/{requestId}
+const (
+	TypeVoteSucceeded = "voteSucceeded"
+	TypeVoteFailed    = "voteFailed"
+)
+
+// Envelope wraps every message published to clients.
+type Envelope struct {
+	Version     int         `json:"version" jsonschema:"description=Protocol version of the message"`
+	Type        string      `json:"type" jsonschema:"description=Discriminates the type of data"`
+	Id          string      `json:"id" jsonschema:"description=Unique ID of the message"`
+	EmittedAt   string      `json:"emittedAt" jsonschema:"format=date-time,description=When the message was published"`
+	Sequence    int64       `json:"sequence" jsonschema:"minimum=0,description=Position of the message within its topic; 0 if the message isn't ordered"`
+	Data        interface{} `json:"data"`
+	TraceParent string      `json:"traceparent,omitempty" jsonschema:"description=W3C trace context of the span that published the message"`
+	TraceState  string      `json:"tracestate,omitempty"`
+}
+
+type VoteCountedData struct {
+	OptionId  string `json:"optionId"`
+	PollId    string `json:"pollId"`
+	UpdatedAt string `json:"updatedAt"`
+	Votes     int64  `json:"votes" jsonschema:"minimum=0"`
+}
+
+// VotesCountedData coalesces the latest vote counts of the options that changed in a batch of votes.
+type VotesCountedData struct {
+	PollId    string        `json:"pollId"`
+	Options   []OptionVotes `json:"options" jsonschema:"minItems=1"`
+	Sequences []int64       `json:"sequences" jsonschema:"description=Every sequence number the message accounts for including the counts it supersedes"`
+}
+
+type OptionVotes struct {
+	OptionId  string `json:"optionId"`
+	UpdatedAt string `json:"updatedAt"`
+	Votes     int64  `json:"votes" jsonschema:"minimum=0"`
+	Sequence  int64  `json:"sequence" jsonschema:"minimum=0,description=Sequence number of the option's latest vote"`
+}
+
+// PollArchivalData is published as pollArchived or pollUnarchived when the poll's owner archives or restores it.
+type PollArchivalData struct {
+	PollId     string `json:"pollId"`
+	IsArchived bool   `json:"isArchived"`
+}
+
+// PollExpiryData is published as pollExtended, pollShortened or pollClosedEarly when the poll's duration
+// changes. A poll is closed early when its new expiry has already passed.
+type PollExpiryData struct {
+	PollId            string `json:"pollId"`
+	Duration          int64  `json:"duration" jsonschema:"description=New duration of the poll in seconds"`
+	PreviousExpiresAt string `json:"previousExpiresAt" jsonschema:"format=date-time,description=When the poll expired before the change"`
+	ExpiresAt         string `json:"expiresAt" jsonschema:"format=date-time,description=When the poll expires after the change"`
+}
+
+// PollEditedData is published as pollEdited when the poll's text changes. Edits of its options carry the
+// poll's options in their new order; they have no votes since edits leave every option without any.
+type PollEditedData struct {
+	PollId   string         `json:"pollId"`
+	Prompt   string         `json:"prompt"`
+	Options  []EditedOption `json:"options,omitempty"`
+	EditedAt string         `json:"editedAt,omitempty" jsonschema:"description=When the poll was edited"`
+}
+
+type EditedOption struct {
+	OptionId string `json:"optionId"`
+	Text     string `json:"text"`
+}
+
+// OptionAddedData is published as optionAdded when a voter writes in an option, or when the poll's owner
+// approves one that was held for moderation. Its votes include the vote of the voter who wrote it in.
+type OptionAddedData struct {
+	PollId   string `json:"pollId"`
+	OptionId string `json:"optionId"`
+	Text     string `json:"text"`
+	Votes    int64  `json:"votes" jsonschema:"minimum=0"`
+	AddedAt  string `json:"addedAt" jsonschema:"format=date-time,description=When the option was written in or approved"`
+}
+
+// PollClosedData is published as pollClosed once the poll's expiry passes and its outcome is decided.
+// A poll that is extended afterwards reopens, and is decided again when it closes.
+type PollClosedData struct {
+	PollId   string  `json:"pollId"`
+	ClosedAt string  `json:"closedAt" jsonschema:"format=date-time,description=When the poll closed"`
+	Outcome  Outcome `json:"outcome"`
+}
+
+// TieBrokenData is published as tieBroken when the owner of a closed poll picks the winner of its tie. Its
+// outcome is the poll's new one, which passed with the picked option unless it was short of the threshold.
+type TieBrokenData struct {
+	PollId   string  `json:"pollId"`
+	OptionId string  `json:"optionId" jsonschema:"description=The option the owner picked"`
+	Outcome  Outcome `json:"outcome"`
+}
+
+// Outcome is how the poll's quorum, threshold and tie-break decided it. OptionId is the leading option,
+// whether it passed or failed, and a tie that was broken has both it and the options that shared the lead.
+type Outcome struct {
+	Result        string   `json:"result" jsonschema:"enum=passed,enum=failed,enum=noQuorum,enum=tie"`
+	Reason        string   `json:"reason,omitempty" jsonschema:"description=Why the poll failed or didn't reach its quorum"`
+	OptionId      string   `json:"optionId,omitempty"`
+	TiedOptionIds []string `json:"tiedOptionIds,omitempty" jsonschema:"description=The options that share the lead in a tie"`
+	Ballots       int64    `json:"ballots" jsonschema:"minimum=0,description=Number of votes cast each counted once"`
+	Votes         int64    `json:"votes" jsonschema:"minimum=0,description=Number of votes cast each counted by its weight"`
+}
+
+// PollDeletedData is published as pollDeleted when the poll's owner deletes it. It is retained in place of
+// the poll's snapshot, so subscribers should disconnect from the poll's topic once they receive it.
+type PollDeletedData struct {
+	PollId    string `json:"pollId"`
+	DeletedAt string `json:"deletedAt" jsonschema:"format=date-time,description=When the poll was deleted"`
+}
+
+// PollExpiredData is published as pollExpired when the poll is purged by the retention policy, some time
+// after it was archived or closed. Like pollDeleted, it is retained in place of the poll's snapshot.
+type PollExpiredData struct {
+	PollId    string `json:"pollId"`
+	ExpiredAt string `json:"expiredAt" jsonschema:"format=date-time,description=When the poll was purged"`
+}
+
+// PollSnapshotData is the full state of a poll as of a sequence number. It is published as a retained
+// message, so subscribers receive the latest one as soon as they subscribe.
+type PollSnapshotData struct {
+	PollId     string           `json:"pollId"`
+	UserId     string           `json:"userId"`
+	Prompt     string           `json:"prompt"`
+	Options    []OptionSnapshot `json:"options"`
+	CreatedAt  string           `json:"createdAt"`
+	Duration   int64            `json:"duration"`
+	IsArchived bool             `json:"isArchived"`
+	Sequence   int64            `json:"sequence" jsonschema:"minimum=0,description=Sequence number of the poll as of the snapshot"`
+
+	AllowWriteIns bool `json:"allowWriteIns,omitempty" jsonschema:"description=Whether voters can write in options of their own"`
+
+	Outcome *Outcome `json:"outcome,omitempty" jsonschema:"description=How the poll was decided once it closed"`
+}
+
+type OptionSnapshot struct {
+	OptionId    string `json:"optionId"`
+	Text        string `json:"text"`
+	Description string `json:"description,omitempty"`
+	Url         string `json:"url,omitempty" jsonschema:"format=uri"`
+	ImageUrl    string `json:"imageUrl,omitempty" jsonschema:"format=uri"`
+	UpdatedAt   string `json:"updatedAt"`
+	Votes       int64  `json:"votes" jsonschema:"minimum=0"`
+	Sequence    int64  `json:"sequence" jsonschema:"minimum=0,description=Sequence number of the option's latest vote"`
+}
+
+type VoteSucceededData struct {
+	VoterId  string `json:"voterId"`
+	PollId   string `json:"pollId"`
+	OptionId string `json:"optionId"`
+	VoteId   string `json:"voteId"`
+}
+
+type VoteFailedData struct {
+	Error    string `json:"error"`
+	PollId   string `json:"pollId"`
+	OptionId string `json:"optionId"`
+}
+
+// DataTypes maps each message type to the type of its data; the schema is generated from it.
+var DataTypes = map[string]interface{}{
+	TypeVoteCounted:     VoteCountedData{},
+	TypeVotesCounted:    VotesCountedData{},
+	TypePollArchived:    PollArchivalData{},
+	TypePollUnarchived:  PollArchivalData{},
+	TypePollExtended:    PollExpiryData{},
+	TypePollShortened:   PollExpiryData{},
+	TypePollClosedEarly: PollExpiryData{},
+	TypePollEdited:      PollEditedData{},
+	TypePollSnapshot:    PollSnapshotData{},
+	TypePollDeleted:     PollDeletedData{},
+	TypePollExpired:     PollExpiredData{},
+	TypeOptionAdded:     OptionAddedData{},
+	TypePollClosed:      PollClosedData{},
+	TypeTieBroken:       TieBrokenData{},
+	TypeVoteSucceeded:   VoteSucceededData{},
+	TypeVoteFailed:      VoteFailedData{},
+}
+
+// NewEnvelope wraps data in an envelope of the given type, stamped with a new ID and the current time.
+func NewEnvelope(messageType string, sequence int64, data interface{}) (Envelope, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Version:   Version,
+		Type:      messageType,
+		Id:        hex.EncodeToString(id),
+		EmittedAt: time.Now().UTC().Format(RFC3339Milli),
+		Sequence:  sequence,
+		Data:      data,
+	}, nil
+}
@@ -0,0 +1,49 @@
+// Package protocoltest validates published messages against the protocol's JSON Schema in tests.
+package protocoltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const schemaUrl = "schema.json"
+
+func compile() (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+
+	if err := compiler.AddResource(schemaUrl, bytes.NewReader(protocol.Schema)); err != nil {
+		return nil, err
+	}
+
+	return compiler.Compile(schemaUrl)
+}
+
+// Validate returns an error if payload isn't a message that matches the protocol's schema.
+func Validate(payload []byte) error {
+	schema, err := compile()
+	if err != nil {
+		return err
+	}
+
+	var message interface{}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+
+	return schema.Validate(message)
+}
+
+// AssertValid fails the test if payload isn't a message that matches the protocol's schema.
+func AssertValid(t *testing.T, payload []byte) {
+	t.Helper()
+
+	if err := Validate(payload); err != nil {
+		t.Errorf("payload doesn't match the protocol schema: %s\n%s", err, payload)
+	}
+}
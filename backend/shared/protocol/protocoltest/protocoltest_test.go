@@ -0,0 +1,42 @@
+package protocoltest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+func TestValidate(t *testing.T) {
+	envelope, err := protocol.NewEnvelope(protocol.TypeVoteFailed, 0, protocol.VoteFailedData{
+		Error:    "poll is closed",
+		PollId:   "poll123",
+		OptionId: "option123",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertValid(t, payload)
+
+	tests := map[string]string{
+		"legacy payload":    `{"type": "voteFailed", "data": {"error": "poll is closed", "pollId": "poll123", "optionId": "option123"}}`,
+		"unknown type":      `{"version": 1, "type": "voteLost", "id": "1", "emittedAt": "2024-01-01T00:00:00Z", "sequence": 0, "data": {}}`,
+		"mismatched data":   `{"version": 1, "type": "voteCounted", "id": "1", "emittedAt": "2024-01-01T00:00:00Z", "sequence": 0, "data": {"error": "poll is closed", "pollId": "poll123", "optionId": "option123"}}`,
+		"future version":    `{"version": 2, "type": "voteFailed", "id": "1", "emittedAt": "2024-01-01T00:00:00Z", "sequence": 0, "data": {"error": "poll is closed", "pollId": "poll123", "optionId": "option123"}}`,
+		"invalid emittedAt": `{"version": 1, "type": "voteFailed", "id": "1", "emittedAt": "yesterday", "sequence": 0, "data": {"error": "poll is closed", "pollId": "poll123", "optionId": "option123"}}`,
+	}
+
+	for name, payload := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := Validate([]byte(payload)); err == nil {
+				t.Error("expected payload to be invalid")
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Command schemagen generates the JSON Schema for the messages defined in the protocol package,
+// and the matching TypeScript types for the frontend.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+	"github.com/invopop/jsonschema"
+)
+
+func reflect(v interface{}) *jsonschema.Schema {
+	reflector := jsonschema.Reflector{
+		Anonymous:      true,
+		ExpandedStruct: true,
+	}
+
+	schema := reflector.Reflect(v)
+	schema.Version = ""
+
+	return schema
+}
+
+func messageTypes() []string {
+	messageTypes := make([]string, 0, len(protocol.DataTypes))
+	for messageType := range protocol.DataTypes {
+		messageTypes = append(messageTypes, messageType)
+	}
+	sort.Strings(messageTypes)
+
+	return messageTypes
+}
+
+func typeName(messageType string) string {
+	return strings.ToUpper(messageType[:1]) + messageType[1:]
+}
+
+func buildSchema() *jsonschema.Schema {
+	schema := &jsonschema.Schema{
+		Version:     jsonschema.Version,
+		Title:       "PseudoPoll real-time message",
+		Description: "Generated from the protocol package by schemagen; do not edit.",
+		Definitions: jsonschema.Definitions{},
+	}
+
+	for _, messageType := range messageTypes() {
+		name := typeName(messageType)
+
+		data := reflect(protocol.DataTypes[messageType])
+
+		// Nested types are referenced from the top level, alongside the messages
+		for definition, nested := range data.Definitions {
+			schema.Definitions[definition] = nested
+		}
+		data.Definitions = nil
+
+		schema.Definitions[name+"Data"] = data
+
+		message := reflect(&protocol.Envelope{})
+		message.Title = name + "Message"
+		message.Properties.Set("version", &jsonschema.Schema{Const: protocol.Version})
+		message.Properties.Set("type", &jsonschema.Schema{Const: messageType})
+		message.Properties.Set("data", &jsonschema.Schema{Ref: "#/$defs/" + name + "Data"})
+
+		schema.Definitions[name+"Message"] = message
+		schema.OneOf = append(schema.OneOf, &jsonschema.Schema{Ref: "#/$defs/" + name + "Message"})
+	}
+
+	return schema
+}
+
+// generate builds a schema that accepts an envelope of any message type, with each type's data validated
+// against the schema of its Go type
+func generate() ([]byte, error) {
+	schema := buildSchema()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func tsType(schema *jsonschema.Schema) string {
+	if schema.Ref != "" {
+		return strings.TrimPrefix(schema.Ref, "#/$defs/")
+	}
+
+	if schema.Const != nil {
+		constant, _ := json.Marshal(schema.Const)
+		return string(constant)
+	}
+
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		return tsType(schema.Items) + "[]"
+	case "object":
+		var required = map[string]bool{}
+		for _, property := range schema.Required {
+			required[property] = true
+		}
+
+		var fields strings.Builder
+		fields.WriteString("{\n")
+		for pair := schema.Properties.Oldest(); pair != nil; pair = pair.Next() {
+			if pair.Value.Description != "" {
+				fmt.Fprintf(&fields, "  /** %s */\n", pair.Value.Description)
+			}
+
+			optional := "?"
+			if required[pair.Key] {
+				optional = ""
+			}
+
+			fmt.Fprintf(&fields, "  %s%s: %s;\n", pair.Key, optional, tsType(pair.Value))
+		}
+		fields.WriteString("}")
+
+		return fields.String()
+	default:
+		return "unknown"
+	}
+}
+
+// generateTypeScript declares a type for each definition in the schema, and a union of every message
+func generateTypeScript() []byte {
+	schema := buildSchema()
+
+	var buf bytes.Buffer
+	buf.WriteString("/**\n * Generated from the protocol package by schemagen; do not edit.\n */\n\n")
+	fmt.Fprintf(&buf, "export const PROTOCOL_VERSION = %d;\n", protocol.Version)
+
+	var messages []string
+	declared := map[string]bool{}
+	for _, messageType := range messageTypes() {
+		name := typeName(messageType)
+
+		fmt.Fprintf(&buf, "\nexport type %sData = %s;\n", name, tsType(schema.Definitions[name+"Data"]))
+		fmt.Fprintf(&buf, "\nexport type %sMessage = %s;\n", name, tsType(schema.Definitions[name+"Message"]))
+
+		messages = append(messages, name+"Message")
+		declared[name+"Data"], declared[name+"Message"] = true, true
+	}
+
+	var nested []string
+	for definition := range schema.Definitions {
+		if !declared[definition] {
+			nested = append(nested, definition)
+		}
+	}
+	sort.Strings(nested)
+
+	for _, definition := range nested {
+		fmt.Fprintf(&buf, "\nexport type %s = %s;\n", definition, tsType(schema.Definitions[definition]))
+	}
+
+	fmt.Fprintf(&buf, "\nexport type Message =\n  | %s;\n", strings.Join(messages, "\n  | "))
+
+	return buf.Bytes()
+}
+
+func main() {
+	output := flag.String("o", "schema.json", "file to write the schema to")
+	typeScriptOutput := flag.String("ts", "", "file to write the TypeScript types to")
+	flag.Parse()
+
+	schema, err := generate()
+	if err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
+	if err := os.WriteFile(*output, schema, 0644); err != nil {
+		log.Fatalf("Error: %s\n", err)
+	}
+
+	if *typeScriptOutput != "" {
+		if err := os.WriteFile(*typeScriptOutput, generateTypeScript(), 0644); err != nil {
+			log.Fatalf("Error: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/declanlscott/pseudopoll/backend/shared/protocol"
+)
+
+func TestSchemaIsUpToDate(t *testing.T) {
+	schema, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(schema, protocol.Schema) {
+		t.Error("schema.json is out of date; run `go generate ./...` in the protocol package")
+	}
+}
+
+func TestTypeScriptIsUpToDate(t *testing.T) {
+	typeScript, err := os.ReadFile("../../../../frontend/types/protocol/generated.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(generateTypeScript(), typeScript) {
+		t.Error("frontend/types/protocol/generated.ts is out of date; run `go generate ./...` in the protocol package")
+	}
+}
@@ -0,0 +1,66 @@
+// Package recurrence schedules the occurrences of recurring polls from a cron expression evaluated in a
+// time zone, so the series endpoints and the scheduler agree on when a series runs next.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	StatusActive  = "active"
+	StatusPaused  = "paused"
+	StatusStopped = "stopped"
+
+	// DueIndexName is the index of series by their Status and NextRunAt, so the scheduler queries the active
+	// series that are due rather than scanning the table
+	DueIndexName = "GSI3"
+)
+
+// Schedule is when a series runs: a standard five field cron expression, or a descriptor like @weekly,
+// in an IANA time zone, so "0 9 * * MON" stays at 09:00 local time across daylight saving changes.
+type Schedule struct {
+	Expression string
+	TimeZone   string
+}
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Parse validates the schedule. The time zone is given separately, so the expression can't set its own.
+func (schedule Schedule) Parse() (cron.Schedule, *time.Location, error) {
+	if strings.Contains(schedule.Expression, "TZ=") {
+		return nil, nil, fmt.Errorf("%w: the time zone can't be set in the expression", ErrInvalidSchedule)
+	}
+
+	location, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidSchedule, schedule.TimeZone)
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Expression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+
+	return cronSchedule, location, nil
+}
+
+// Next returns the first run of the schedule after the given time, or the zero time if it never runs.
+func (schedule Schedule) Next(after time.Time) (time.Time, error) {
+	cronSchedule, location, err := schedule.Parse()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := cronSchedule.Next(after.In(location))
+	if next.IsZero() {
+		return time.Time{}, nil
+	}
+
+	return next.UTC(), nil
+}
@@ -0,0 +1,66 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	tests := map[string]struct {
+		schedule Schedule
+		after    time.Time
+		expected time.Time
+	}{
+		"weekly in UTC": {
+			Schedule{Expression: "0 9 * * MON", TimeZone: "UTC"},
+			time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+		},
+		"at the run itself": {
+			Schedule{Expression: "0 9 * * MON", TimeZone: "UTC"},
+			time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+		},
+		"local time before daylight saving": {
+			Schedule{Expression: "0 9 * * *", TimeZone: "America/New_York"},
+			time.Date(2024, 3, 9, 15, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC),
+		},
+		"local time after daylight saving": {
+			Schedule{Expression: "0 9 * * *", TimeZone: "America/New_York"},
+			time.Date(2024, 3, 10, 14, 0, 0, 0, time.UTC),
+			time.Date(2024, 3, 11, 13, 0, 0, 0, time.UTC),
+		},
+		"descriptor": {
+			Schedule{Expression: "@daily", TimeZone: "UTC"},
+			time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC),
+			time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, test := range tests {
+		next, err := test.schedule.Next(test.after)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !next.Equal(test.expected) {
+			t.Errorf("%s: expected %s, got %s", name, test.expected, next)
+		}
+	}
+}
+
+func TestParseRejectsInvalidSchedules(t *testing.T) {
+	schedules := []Schedule{
+		{Expression: "0 9 * * MON", TimeZone: "Mars/Olympus_Mons"},
+		{Expression: "0 9 * *", TimeZone: "UTC"},
+		{Expression: "CRON_TZ=Asia/Tokyo 0 9 * * MON", TimeZone: "UTC"},
+	}
+
+	for _, schedule := range schedules {
+		if _, _, err := schedule.Parse(); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("expected %+v to be invalid, got %v", schedule, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	Issuer   = "pseudopoll"
+	Audience = "pseudopoll-iot"
+)
+
+// idPattern matches poll ids and vote request ids. It leaves out the characters that MQTT topic filters
+// (+, #, /) and IoT policies (*, ?) treat as wildcards or separators, which would widen a token's topics.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// ValidateIds checks the poll id and the optional vote request id can only name a single topic each.
+func ValidateIds(pollId string, requestId string) error {
+	if pollId == "" {
+		return errors.New("pollId must not be empty")
+	}
+	if !idPattern.MatchString(pollId) {
+		return fmt.Errorf("invalid pollId: %q", pollId)
+	}
+	if requestId != "" && !idPattern.MatchString(requestId) {
+		return fmt.Errorf("invalid requestId: %q", requestId)
+	}
+
+	return nil
+}
+
+// Claims grant read-only access to a poll's topic and, optionally, one vote request's topic
+type Claims struct {
+	PollId    string `json:"pollId"`
+	RequestId string `json:"requestId,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// PollTopic returns the MQTT topic that a poll's updates are published to.
+func PollTopic(pollId string) string {
+	return fmt.Sprintf("poll/%s", pollId)
+}
+
+// VoteTopic returns the MQTT topic that a vote request's result is published to.
+func VoteTopic(requestId string) string {
+	return fmt.Sprintf("vote/%s", requestId)
+}
+
+// Topics returns the topics the claims grant access to.
+func (c Claims) Topics() []string {
+	topics := []string{PollTopic(c.PollId)}
+	if c.RequestId != "" {
+		topics = append(topics, VoteTopic(c.RequestId))
+	}
+
+	return topics
+}
+
+// Sign mints a subscription token for the poll and optional vote request that expires after ttl.
+func Sign(secret []byte, pollId string, requestId string, ttl time.Duration) (string, time.Time, error) {
+	if len(secret) == 0 {
+		return "", time.Time{}, errors.New("subscription token secret not set")
+	}
+	if err := ValidateIds(pollId, requestId); err != nil {
+		return "", time.Time{}, err
+	}
+
+	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(ttl)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		PollId:    pollId,
+		RequestId: requestId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verify checks the token's signature, issuer, audience and expiry, and returns its claims.
+func Verify(secret []byte, tokenString string) (*Claims, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("subscription token secret not set")
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		&claims,
+		func(token *jwt.Token) (interface{}, error) {
+			return secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateIds(claims.PollId, claims.RequestId); err != nil {
+		return nil, fmt.Errorf("subscription token has invalid claims: %w", err)
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,112 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var secret = []byte("test-secret")
+
+func TestSignVerify(t *testing.T) {
+	token, expiresAt, err := Sign(secret, "poll123", "request123", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expiresAt) > time.Minute {
+		t.Errorf("expected token to expire within a minute, got %s", expiresAt)
+	}
+
+	claims, err := Verify(secret, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics := claims.Topics()
+	if len(topics) != 2 || topics[0] != "poll/poll123" || topics[1] != "vote/request123" {
+		t.Errorf("unexpected topics %v", topics)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	expired, _, err := Sign(secret, "poll123", "", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, _, err := Sign([]byte("other-secret"), "poll123", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{
+		PollId: "poll123",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"expired":  expired,
+		"forged":   forged,
+		"unsigned": unsigned,
+		"garbage":  "not-a-token",
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Verify(secret, token); err == nil {
+				t.Error("expected token to be rejected")
+			}
+		})
+	}
+}
+
+func TestSignRejectsWildcardIds(t *testing.T) {
+	tests := map[string]struct {
+		pollId    string
+		requestId string
+	}{
+		"empty pollId":          {pollId: ""},
+		"wildcard pollId":       {pollId: "*"},
+		"wildcard requestId":    {pollId: "poll123", requestId: "*"},
+		"multi-level filter":    {pollId: "poll123", requestId: "#"},
+		"single-level filter":   {pollId: "poll123", requestId: "+"},
+		"single character":      {pollId: "poll123", requestId: "request?"},
+		"nested topic":          {pollId: "poll123/..", requestId: "request123"},
+		"topic level separator": {pollId: "poll123", requestId: "request123/#"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := Sign(secret, test.pollId, test.requestId, time.Minute); err == nil {
+				t.Error("expected the ids to be rejected")
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsWildcardIds(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		PollId:    "poll123",
+		RequestId: "*",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(secret, token); err == nil {
+		t.Error("expected a token with a wildcard requestId to be rejected")
+	}
+}
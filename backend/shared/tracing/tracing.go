@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TraceParentKey = "traceparent"
+	TraceStateKey  = "tracestate"
+)
+
+var (
+	propagator     = propagation.TraceContext{}
+	tracerProvider *sdktrace.TracerProvider
+)
+
+// NewTracerProvider registers a global tracer provider for the given service.
+//
+// Spans aren't exported unless OTEL_TRACES_EXPORTER is set. Set it to "otlp" to
+// export over OTLP/HTTP (configured through the standard OTEL_EXPORTER_OTLP_*
+// environment variables) or "console" to write spans to stdout for local runs.
+func NewTracerProvider(ctx context.Context, serviceName string) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exporterOpts, err := exporterOptions(ctx, os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, exporterOpts...)
+
+	tracerProvider = sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagator)
+
+	return tracerProvider, nil
+}
+
+// exporterOptions returns the tracer provider options that export spans with the
+// named exporter. An empty name disables export, the same as "none".
+func exporterOptions(ctx context.Context, exporter string) ([]sdktrace.TracerProviderOption, error) {
+	switch exporter {
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []sdktrace.TracerProviderOption{sdktrace.WithBatcher(exp)}, nil
+	case "console":
+		exp, err := stdouttrace.New()
+		if err != nil {
+			return nil, err
+		}
+		return []sdktrace.TracerProviderOption{sdktrace.WithSyncer(exp)}, nil
+	case "", "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER: %s", exporter)
+	}
+}
+
+// ForceFlush exports any spans still buffered by the registered tracer provider.
+// Lambda freezes the execution environment between invocations, so handlers
+// should call this before returning.
+func ForceFlush(ctx context.Context) {
+	if tracerProvider == nil {
+		return
+	}
+
+	if err := tracerProvider.ForceFlush(ctx); err != nil {
+		log.Printf("Error: %s\n", err)
+	}
+}
+
+// InstrumentConfig adds tracing middleware to every AWS client created from cfg.
+func InstrumentConfig(cfg *aws.Config) {
+	otelaws.AppendMiddlewares(&cfg.APIOptions)
+}
+
+// Tracer returns a named tracer from the global tracer provider.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Extract returns a copy of ctx carrying the remote span context described by
+// the W3C traceparent and tracestate values. Empty or malformed values leave ctx unchanged.
+func Extract(ctx context.Context, traceParent string, traceState string) context.Context {
+	return propagator.Extract(ctx, propagation.MapCarrier{
+		TraceParentKey: traceParent,
+		TraceStateKey:  traceState,
+	})
+}
+
+// Inject returns the W3C traceparent and tracestate values for the span in ctx.
+func Inject(ctx context.Context) (string, string) {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	return carrier.Get(TraceParentKey), carrier.Get(TraceStateKey)
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	traceParent, traceState := Inject(ctx)
+	if traceParent == "" {
+		t.Fatal("expected traceparent to be injected")
+	}
+
+	remote := trace.SpanContextFromContext(Extract(context.Background(), traceParent, traceState))
+	if !remote.IsRemote() {
+		t.Error("expected extracted span context to be remote")
+	}
+	if remote.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("expected trace ID %s, got %s", span.SpanContext().TraceID(), remote.TraceID())
+	}
+	if remote.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected span ID %s, got %s", span.SpanContext().SpanID(), remote.SpanID())
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+	ctx := Extract(context.Background(), "", "")
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("expected no span context for empty traceparent")
+	}
+}
+
+func TestExporterOptions(t *testing.T) {
+	ctx := context.Background()
+
+	for _, exporter := range []string{"", "none"} {
+		opts, err := exporterOptions(ctx, exporter)
+		if err != nil {
+			t.Fatalf("exporter %q: %s", exporter, err)
+		}
+		if len(opts) != 0 {
+			t.Errorf("expected exporter %q to disable export, got %d options", exporter, len(opts))
+		}
+	}
+
+	for _, exporter := range []string{"otlp", "console"} {
+		opts, err := exporterOptions(ctx, exporter)
+		if err != nil {
+			t.Fatalf("exporter %q: %s", exporter, err)
+		}
+		if len(opts) != 1 {
+			t.Errorf("expected exporter %q to register one exporter, got %d options", exporter, len(opts))
+		}
+	}
+
+	if _, err := exporterOptions(ctx, "jaeger"); err == nil {
+		t.Error("expected an unsupported exporter to return an error")
+	}
+}
+
+func TestNewTracerProviderUnsetExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+
+	tp, err := NewTracerProvider(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Shutdown(context.Background())
+}
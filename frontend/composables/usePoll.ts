@@ -50,7 +50,7 @@ export default function ({ pollId }: { pollId: Poll["pollId"] }) {
     calculateTime();
     timer = setInterval(calculateTime, 1000);
 
-    $mqtt.subscribe(`poll/${pollId}`);
+    $mqtt.subscribe({ pollId });
   });
 
   onBeforeUnmount(() => {
@@ -59,7 +59,7 @@ export default function ({ pollId }: { pollId: Poll["pollId"] }) {
       timer = null;
     }
 
-    $mqtt.unsubscribe(`poll/${pollId}`);
+    $mqtt.unsubscribe();
   });
 
   const totalVotes = computed(() => {
@@ -57,9 +57,7 @@ export default function ({ pollId }: { pollId: Poll["pollId"] }) {
       }
     },
     onSuccess: async ({ requestId }) => {
-      await $mqtt.subscribeAsync(`vote/${requestId}`, {
-        qos: 1,
-      });
+      await $mqtt.subscribe({ pollId, requestId });
     },
   });
 
@@ -2,7 +2,20 @@
 import { createId } from "@paralleldrive/cuid2";
 import mqtt from "mqtt";
 
-import type { Payload, Poll } from "~/types";
+import { PROTOCOL_VERSION } from "~/types/protocol/generated";
+
+import type { MqttClient } from "mqtt";
+import type {
+  Payload,
+  Poll,
+  PollSnapshot,
+  SubscriptionToken,
+} from "~/types";
+
+type Subscription = {
+  pollId: Poll["pollId"];
+  requestId?: string;
+};
 
 export default defineNuxtPlugin(() => {
   const queryClient = useQueryClient();
@@ -18,145 +31,435 @@ export default defineNuxtPlugin(() => {
     customAuthorizerName,
   );
 
-  const client = mqtt.connect(brokerUrl.toString(), {
-    clientId,
-    reconnectPeriod: 0,
-  });
+  let activeClient: MqttClient | null = null;
+  let subscription: Subscription | null = null;
+  let sequences: ReturnType<typeof createSequenceTracker> | null = null;
+
+  // Each connection is authorized for a single poll and, after voting, its vote request,
+  // so following a different poll or vote means reconnecting with a new token
+  async function subscribe(nextSubscription: Subscription) {
+    if (subscription?.pollId !== nextSubscription.pollId) {
+      sequences?.dispose();
+      sequences = createSequenceTracker({
+        onGap: () => resync(nextSubscription.pollId),
+      });
+    }
+
+    subscription = nextSubscription;
+
+    const { token, topics } = await $fetch<SubscriptionToken>(
+      `/api/polls/${nextSubscription.pollId}/subscription-token`,
+      {
+        query: nextSubscription.requestId
+          ? { requestId: nextSubscription.requestId }
+          : {},
+      },
+    );
+
+    if (subscription !== nextSubscription) {
+      return;
+    }
+
+    activeClient?.end(true);
+    activeClient = connect(token);
+
+    await activeClient.subscribeAsync(topics, { qos: 1 });
+
+    // Updates published before the subscription took effect were missed
+    await resync(nextSubscription.pollId);
+  }
+
+  function unsubscribe() {
+    subscription = null;
+
+    sequences?.dispose();
+    sequences = null;
+
+    activeClient?.end();
+    activeClient = null;
+  }
+
+  // Replaces anything older than the poll's snapshot and sets the baseline for detecting missed updates
+  async function resync(pollId: Poll["pollId"]) {
+    const snapshot = await $fetch<PollSnapshot>(
+      `/api/polls/${pollId}/snapshot`,
+    ).catch((error) => {
+      log("Failed to fetch poll snapshot", error);
+      return null;
+    });
+
+    if (!snapshot) {
+      return;
+    }
+
+    applySnapshot(snapshot);
+  }
 
-  client.on("connect", () => {
-    log("MQTT client connected");
-  });
+  // Snapshots also arrive as retained pollSnapshot messages, so a new subscriber starts
+  // from the latest one published and the poll catches up periodically while it is open
+  function applySnapshot(snapshot: PollSnapshot) {
+    const tracker = sequences;
+    if (!tracker || subscription?.pollId !== snapshot.pollId) {
+      return;
+    }
 
-  client.on("error", (error) => {
-    log("MQTT client error", error);
-  });
+    const { queryKey } = poll({ pollId: snapshot.pollId });
+
+    queryClient.setQueryData<Poll>(queryKey, (poll) => {
+      if (!poll) {
+        return undefined;
+      }
+
+      const { prompt } = tracker.isNewerPoll("text", snapshot.sequence)
+        ? snapshot
+        : poll;
+      const { duration } = tracker.isNewerPoll("expiry", snapshot.sequence)
+        ? snapshot
+        : poll;
+      const { isArchived } = tracker.isNewerPoll(
+        "archival",
+        snapshot.sequence,
+      )
+        ? snapshot
+        : poll;
+
+      const options = poll.options.map((option) => {
+        const snapshotOption = snapshot.options.find(
+          ({ optionId }) => optionId === option.optionId,
+        );
+
+        if (
+          !snapshotOption ||
+          !tracker.isNewerOption(option.optionId, snapshotOption.sequence)
+        ) {
+          return option;
+        }
 
-  client.on("message", (fullTopic, payloadBuffer) => {
-    log("message topic", undefined, fullTopic);
+        return {
+          ...option,
+          votes: snapshotOption.votes,
+          updatedAt: snapshotOption.updatedAt,
+        };
+      });
 
-    const payload = JSON.parse(
-      new TextDecoder("utf8").decode(new Uint8Array(payloadBuffer)),
-    ) as Payload;
-    log("message payload", undefined, payload);
+      return { ...poll, prompt, duration, isArchived, options };
+    });
 
-    const [topic, topicId] = fullTopic.split("/");
-    switch (topic) {
-      case "poll":
-        if (payload.type === "voteCounted") {
-          const { queryKey } = poll({ pollId: payload.data.pollId });
+    tracker.resync(snapshot.sequence);
+  }
 
-          queryClient.setQueryData<Poll>(queryKey, (poll) => {
-            if (!poll) {
-              return undefined;
+  function connect(token: string) {
+    const client = mqtt.connect(brokerUrl.toString(), {
+      clientId,
+      username: token,
+      reconnectPeriod: 0,
+    });
+
+    client.on("connect", () => {
+      log("MQTT client connected");
+    });
+
+    client.on("error", (error) => {
+      log("MQTT client error", error);
+    });
+
+    client.on("message", (fullTopic, payloadBuffer) => {
+      log("message topic", undefined, fullTopic);
+
+      const payload = JSON.parse(
+        new TextDecoder("utf8").decode(new Uint8Array(payloadBuffer)),
+      ) as Payload;
+      log("message payload", undefined, payload);
+
+      if (payload.version !== PROTOCOL_VERSION) {
+        log("Unsupported protocol version", undefined, payload.version);
+        return;
+      }
+
+      const [topic, topicId] = fullTopic.split("/");
+      switch (topic) {
+        case "poll":
+          sequences?.receive(payload.sequence);
+
+          if (payload.type === "voteCounted") {
+            if (
+              !sequences?.isNewerOption(
+                payload.data.optionId,
+                payload.sequence,
+              )
+            ) {
+              log("Dropping stale vote count", undefined, payload.sequence);
+              break;
             }
 
-            const lastUpdatedAt = poll.options.reduce(
-              (lastUpdatedAt, option) => {
-                if (option.updatedAt > lastUpdatedAt) {
-                  return option.updatedAt;
+            const { queryKey } = poll({ pollId: payload.data.pollId });
+
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
+
+              const options = poll.options.map((option) => {
+                if (option.optionId === payload.data.optionId) {
+                  return {
+                    ...option,
+                    votes: payload.data.votes,
+                    updatedAt: payload.data.updatedAt,
+                  };
                 }
 
-                return lastUpdatedAt;
-              },
-              poll.createdAt,
+                return option;
+              });
+
+              return {
+                ...poll,
+                options,
+              };
+            });
+          }
+
+          if (payload.type === "votesCounted") {
+            // The counts a combined message supersedes are accounted for by it
+            payload.data.sequences.forEach((sequence) =>
+              sequences?.receive(sequence),
+            );
+
+            const counts = payload.data.options.filter(
+              ({ optionId, sequence }) =>
+                sequences?.isNewerOption(optionId, sequence),
             );
 
-            if (lastUpdatedAt > payload.data.updatedAt) {
-              return poll;
+            if (!counts.length) {
+              log("Dropping stale vote counts", undefined, payload.sequence);
+              break;
             }
 
-            const options = poll.options.map((option) => {
-              if (option.optionId === payload.data.optionId) {
+            const { queryKey } = poll({ pollId: payload.data.pollId });
+
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
+
+              const options = poll.options.map((option) => {
+                const count = counts.find(
+                  ({ optionId }) => optionId === option.optionId,
+                );
+
+                if (!count) {
+                  return option;
+                }
+
                 return {
                   ...option,
-                  votes: payload.data.votes,
-                  updatedAt: payload.data.updatedAt,
+                  votes: count.votes,
+                  updatedAt: count.updatedAt,
                 };
-              }
+              });
 
-              return option;
+              return {
+                ...poll,
+                options,
+              };
             });
+          }
+
+          if (payload.type === "pollSnapshot") {
+            applySnapshot(payload.data);
+          }
+
+          if (
+            payload.type === "pollArchived" ||
+            payload.type === "pollUnarchived"
+          ) {
+            if (!sequences?.isNewerPoll("archival", payload.sequence)) {
+              log("Dropping stale poll archival", undefined, payload.sequence);
+              break;
+            }
 
-            return {
-              ...poll,
-              options,
-            };
-          });
-        }
+            const { queryKey } = poll({ pollId: payload.data.pollId });
 
-        if (payload.type === "pollModified") {
-          const { queryKey } = poll({ pollId: payload.data.pollId });
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
 
-          queryClient.setQueryData<Poll>(queryKey, (poll) => {
-            if (!poll) {
-              return undefined;
+              return { ...poll, isArchived: payload.data.isArchived };
+            });
+          }
+
+          if (
+            payload.type === "pollExtended" ||
+            payload.type === "pollShortened" ||
+            payload.type === "pollClosedEarly"
+          ) {
+            if (!sequences?.isNewerPoll("expiry", payload.sequence)) {
+              log("Dropping stale poll expiry", undefined, payload.sequence);
+              break;
             }
 
-            return { ...poll, ...payload.data };
-          });
-        }
+            const { queryKey } = poll({ pollId: payload.data.pollId });
+
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
+
+              return { ...poll, duration: payload.data.duration };
+            });
+          }
 
-        break;
-      case "vote":
-        if (payload.type === "voteSucceeded" || payload.type === "voteFailed") {
-          const isSuccessful = payload.type === "voteSucceeded";
+          // The poll's outcome is decided once it closes, or once its owner breaks its tie, so the poll is
+          // refetched along with it
+          if (payload.type === "pollClosed" || payload.type === "tieBroken") {
+            const { queryKey } = poll({ pollId: payload.data.pollId });
 
-          const { queryKey } = poll({ pollId: payload.data.pollId });
+            queryClient.invalidateQueries({ queryKey });
+          }
 
-          queryClient.setQueryData<Poll>(queryKey, (poll) => {
-            if (!poll) {
-              return undefined;
+          // The deleted or expired poll's topic retains this message, so it's also received when subscribing
+          if (payload.type === "pollDeleted" || payload.type === "pollExpired") {
+            unsubscribe();
+
+            const { queryKey } = poll({ pollId: payload.data.pollId });
+
+            queryClient.invalidateQueries({ queryKey });
+
+            break;
+          }
+
+          if (payload.type === "pollEdited") {
+            if (!sequences?.isNewerPoll("text", payload.sequence)) {
+              log("Dropping stale poll edit", undefined, payload.sequence);
+              break;
             }
 
-            return {
-              ...poll,
-              options: poll.options.map((option) => {
-                if (option.optionId === payload.data.optionId) {
+            const { queryKey } = poll({ pollId: payload.data.pollId });
+
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
+
+              const { options, editedAt } = payload.data;
+              if (!options) {
+                return { ...poll, prompt: payload.data.prompt };
+              }
+
+              // Editing a poll's options resets its votes
+              return {
+                ...poll,
+                prompt: payload.data.prompt,
+                options: options.map(({ optionId, text }) => {
+                  const option = poll.options.find(
+                    (option) => option.optionId === optionId,
+                  );
+
                   return {
-                    ...option,
-                    votes: isSuccessful ? option.votes : option.votes - 1,
-                    isMyVote: isSuccessful,
+                    optionId,
+                    text,
+                    updatedAt: editedAt ?? option?.updatedAt ?? poll.createdAt,
+                    votes: 0,
+                    isMyVote: false,
                   };
-                }
+                }),
+              };
+            });
+          }
 
-                return option;
-              }),
-            };
-          });
+          // A voter wrote in an option, or its owner approved one that was held for moderation
+          if (payload.type === "optionAdded") {
+            const { queryKey } = poll({ pollId: payload.data.pollId });
 
-          client.unsubscribe(`vote/${topicId}`);
-        }
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
 
-        break;
-      default:
-        log("Unknown topic", undefined, topic);
-    }
-  });
+              const { optionId, text, votes, addedAt } = payload.data;
+              if (poll.options.some((option) => option.optionId === optionId)) {
+                return poll;
+              }
 
-  client.on("disconnect", () => {
-    log("MQTT client disconnected");
-  });
+              return {
+                ...poll,
+                options: [
+                  ...poll.options,
+                  { optionId, text, updatedAt: addedAt, votes, isMyVote: false },
+                ],
+              };
+            });
+          }
 
-  client.on("reconnect", () => {
-    log("MQTT client reconnecting");
-  });
+          break;
+        case "vote":
+          if (payload.type === "voteSucceeded" || payload.type === "voteFailed") {
+            const isSuccessful = payload.type === "voteSucceeded";
 
-  client.on("close", () => {
-    log("MQTT client closed");
-    client.reconnect();
-  });
+            const { queryKey } = poll({ pollId: payload.data.pollId });
 
-  client.on("end", () => {
-    log("MQTT client ended");
-  });
+            queryClient.setQueryData<Poll>(queryKey, (poll) => {
+              if (!poll) {
+                return undefined;
+              }
 
-  client.on("offline", () => {
-    log("MQTT client offline");
-  });
+              return {
+                ...poll,
+                options: poll.options.map((option) => {
+                  if (option.optionId === payload.data.optionId) {
+                    return {
+                      ...option,
+                      votes: isSuccessful ? option.votes : option.votes - 1,
+                      isMyVote: isSuccessful,
+                    };
+                  }
+
+                  return option;
+                }),
+              };
+            });
+
+            client.unsubscribe(`vote/${topicId}`);
+          }
+
+          break;
+        default:
+          log("Unknown topic", undefined, topic);
+      }
+    });
+
+    client.on("disconnect", () => {
+      log("MQTT client disconnected");
+    });
+
+    client.on("reconnect", () => {
+      log("MQTT client reconnecting");
+    });
+
+    client.on("close", () => {
+      log("MQTT client closed");
+
+      // The token used to connect is short-lived, so reconnect with a new one
+      if (subscription && client === activeClient) {
+        const { pollId, requestId } = subscription;
+        setTimeout(() => subscribe({ pollId, requestId }), 1000);
+      }
+    });
+
+    client.on("end", () => {
+      log("MQTT client ended");
+    });
+
+    client.on("offline", () => {
+      log("MQTT client offline");
+    });
+
+    return client;
+  }
 
   return {
     provide: {
-      mqtt: client,
+      mqtt: { subscribe, unsubscribe },
     },
   };
 });
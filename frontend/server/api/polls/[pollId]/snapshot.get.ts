@@ -0,0 +1,35 @@
+import { safeParse } from "valibot";
+
+import type { PollSnapshot } from "~/types";
+
+export default defineEventHandler(async (event) => {
+  const config = useRuntimeConfig();
+  const routerParams = await getValidatedRouterParams(event, (params) =>
+    safeParse(pollParamsSchema(config.public), params),
+  );
+
+  if (!routerParams.success) {
+    throw createError({
+      statusCode: 400,
+      message: routerParams.issues.map((issue) => issue.message).join(". "),
+    });
+  }
+
+  const session = await getServerAuthSession(event);
+  const { pollId } = routerParams.output;
+
+  return await $fetch<PollSnapshot>(
+    session ? `/polls/${pollId}/snapshot` : `/public/polls/${pollId}/snapshot`,
+    {
+      baseURL: config.api.baseUrl,
+      headers: session
+        ? { Authorization: `Bearer ${session.user.idToken}` }
+        : {},
+    },
+  ).catch((error) => {
+    throw createError({
+      statusCode: error.statusCode ?? 500,
+      message: "An unknown error occurred while fetching the poll snapshot.",
+    });
+  });
+});
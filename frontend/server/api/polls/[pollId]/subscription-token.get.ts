@@ -0,0 +1,49 @@
+import { object, optional, safeParse, string } from "valibot";
+
+import type { SubscriptionToken } from "~/types";
+
+export default defineEventHandler(async (event) => {
+  const config = useRuntimeConfig();
+  const routerParams = await getValidatedRouterParams(event, (params) =>
+    safeParse(pollParamsSchema(config.public), params),
+  );
+
+  if (!routerParams.success) {
+    throw createError({
+      statusCode: 400,
+      message: routerParams.issues.map((issue) => issue.message).join(". "),
+    });
+  }
+
+  const query = await getValidatedQuery(event, (query) =>
+    safeParse(object({ requestId: optional(string()) }), query),
+  );
+
+  if (!query.success) {
+    throw createError({
+      statusCode: 400,
+      message: query.issues.map((issue) => issue.message).join(". "),
+    });
+  }
+
+  const session = await getServerAuthSession(event);
+  const { pollId } = routerParams.output;
+
+  return await $fetch<SubscriptionToken>(
+    session
+      ? `/polls/${pollId}/subscription-token`
+      : `/public/polls/${pollId}/subscription-token`,
+    {
+      baseURL: config.api.baseUrl,
+      query: query.output,
+      headers: session
+        ? { Authorization: `Bearer ${session.user.idToken}` }
+        : {},
+    },
+  ).catch((error) => {
+    throw createError({
+      statusCode: error.statusCode ?? 500,
+      message: "An unknown error occurred while subscribing to the poll.",
+    });
+  });
+});
@@ -1,34 +1,20 @@
 import type { components } from "~/types/openapi/generated";
+import type { Message, PollSnapshotData } from "~/types/protocol/generated";
 
 export type Poll = components["schemas"]["Poll"];
 
-type VoteCountedPayloadData = {
-  optionId: Poll["options"][number]["optionId"];
-  pollId: Poll["pollId"];
-  updatedAt: Poll["options"][number]["updatedAt"];
-  votes: Poll["options"][number]["votes"];
-};
-type PollModifiedPayloadData = Omit<Poll, "options">;
-type PollTopicPayload =
-  | { type: "voteCounted"; data: VoteCountedPayloadData }
-  | { type: "pollModified"; data: PollModifiedPayloadData };
+// Messages published over MQTT, generated from the backend's protocol package
+export type Payload = Message;
 
-type VoteSucceededPayloadData = {
-  voterId: string;
-  pollId: Poll["pollId"];
-  optionId: Poll["options"][number]["optionId"];
-  voteId: string;
-};
-type VoteFailedPayloadData = {
-  error: string;
-  pollId: Poll["pollId"];
-  optionId: Poll["options"][number]["optionId"];
+// Short-lived token that authorizes an MQTT connection to a poll's topics
+export type SubscriptionToken = {
+  token: string;
+  expiresAt: string;
+  topics: string[];
 };
-type VoteTopicPayload =
-  | { type: "voteSucceeded"; data: VoteSucceededPayloadData }
-  | { type: "voteFailed"; data: VoteFailedPayloadData };
 
-export type Payload = PollTopicPayload | VoteTopicPayload;
+// State of a poll as of a sequence number, to resync from after missing live updates
+export type PollSnapshot = PollSnapshotData;
 
 export type Feature = {
   title: string;
@@ -0,0 +1,94 @@
+// Tracks the sequence numbers of a poll's live updates, which can arrive out of order.
+// Updates older than what has already been applied are stale, and a sequence number that
+// is still missing after `gapTimeout` means an update was lost and the poll needs a resync.
+type PollField = "archival" | "expiry" | "text";
+
+export default function ({
+  onGap,
+  gapTimeout = 1000,
+}: {
+  onGap: () => void;
+  gapTimeout?: number;
+}) {
+  let contiguous: number | null = null;
+  const pending = new Set<number>();
+
+  const pollSequences = new Map<PollField, number>();
+  const optionSequences = new Map<string, number>();
+
+  let gapTimer: ReturnType<typeof setTimeout> | null = null;
+
+  function advance() {
+    if (contiguous === null) {
+      return;
+    }
+
+    while (pending.has(contiguous + 1)) {
+      contiguous++;
+    }
+
+    for (const sequence of pending) {
+      if (sequence <= contiguous) {
+        pending.delete(sequence);
+      }
+    }
+
+    if (!pending.size) {
+      clearGapTimer();
+      return;
+    }
+
+    if (!gapTimer) {
+      gapTimer = setTimeout(() => {
+        gapTimer = null;
+        onGap();
+      }, gapTimeout);
+    }
+  }
+
+  function clearGapTimer() {
+    if (gapTimer) {
+      clearTimeout(gapTimer);
+      gapTimer = null;
+    }
+  }
+
+  return {
+    // Records a received update. Gaps are only detected once a snapshot has set the baseline.
+    receive(sequence: number) {
+      if (contiguous !== null && sequence <= contiguous) {
+        return;
+      }
+
+      pending.add(sequence);
+      advance();
+    },
+    // Whether an update to a poll field is newer than the last one applied, recording it if so.
+    // Fields are tracked separately because one modification can publish a message for each.
+    isNewerPoll(field: PollField, sequence: number) {
+      if (sequence <= (pollSequences.get(field) ?? -1)) {
+        return false;
+      }
+
+      pollSequences.set(field, sequence);
+      return true;
+    },
+    // Whether an option update is newer than the last one applied, recording it if so
+    isNewerOption(optionId: string, sequence: number) {
+      if (sequence <= (optionSequences.get(optionId) ?? -1)) {
+        return false;
+      }
+
+      optionSequences.set(optionId, sequence);
+      return true;
+    },
+    // Sets the baseline from a snapshot, which includes every update up to its sequence
+    resync(sequence: number) {
+      contiguous = Math.max(contiguous ?? sequence, sequence);
+      advance();
+    },
+    dispose() {
+      clearGapTimer();
+    },
+  };
+}
@@ -53,16 +53,61 @@ locals {
   resources_hash = sha1(jsonencode([
     aws_api_gateway_model.poll,
     aws_api_gateway_model.create_poll,
+    aws_api_gateway_model.edit_poll,
     aws_api_gateway_model.archive_poll,
     aws_api_gateway_model.update_poll_duration,
     aws_api_gateway_model.vote_accepted,
     aws_api_gateway_model.my_polls,
+    aws_api_gateway_model.subscription_token,
+    aws_api_gateway_model.poll_snapshot,
+    aws_api_gateway_model.poll_snapshot_requested,
+    aws_api_gateway_model.poll_timeline,
+    aws_api_gateway_model.write_in_vote,
+    aws_api_gateway_model.moderate_write_in,
+    aws_api_gateway_model.write_in,
+    aws_api_gateway_model.break_tie,
+    aws_api_gateway_model.tie_break,
+    aws_api_gateway_model.add_poll_member,
+    aws_api_gateway_model.poll_member,
+    aws_api_gateway_model.create_option_image_upload,
+    aws_api_gateway_model.option_image_upload,
+    aws_api_gateway_model.create_api_key,
+    aws_api_gateway_model.api_key,
+    aws_api_gateway_model.create_webhook,
+    aws_api_gateway_model.webhook,
+    aws_api_gateway_model.webhooks,
+    aws_api_gateway_model.webhook_deliveries,
+    aws_api_gateway_model.account_export,
+    aws_api_gateway_model.erasure,
+    aws_api_gateway_model.save_template,
+    aws_api_gateway_model.template,
+    aws_api_gateway_model.templates,
+    aws_api_gateway_model.create_series,
+    aws_api_gateway_model.update_series_status,
+    aws_api_gateway_model.series,
+    aws_api_gateway_model.series_results,
     aws_api_gateway_model.error,
   ]))
-  ddb_stream_pipe_event_source      = "pseudopoll.ddb-stream"
-  ddb_stream_pipe_event_detail_type = "DdbStreamEvent"
-  vote_failed_source                = "pseudopoll.vote-queue"
-  vote_failed_detail_type           = "VoteFailed"
+  ddb_stream_pipe_event_source        = "pseudopoll.ddb-stream"
+  ddb_stream_pipe_event_detail_type   = "DdbStreamEvent"
+  vote_failed_source                  = "pseudopoll.vote-queue"
+  vote_failed_detail_type             = "VoteFailed"
+  poll_snapshot_requested_source      = "pseudopoll.poll-manager"
+  poll_snapshot_requested_detail_type = "PollSnapshotRequested"
+  poll_deletion_requested_source      = "pseudopoll.poll-manager"
+  poll_deletion_requested_detail_type = "PollDeletionRequested"
+  otel_environment_variables = {
+    OTEL_TRACES_EXPORTER        = var.otel_traces_exporter
+    OTEL_EXPORTER_OTLP_ENDPOINT = var.otel_exporter_otlp_endpoint
+    OTEL_EXPORTER_OTLP_HEADERS  = var.otel_exporter_otlp_headers
+  }
+}
+
+check "otel_exporter_otlp_endpoint" {
+  assert {
+    condition     = var.otel_traces_exporter != "otlp" || var.otel_exporter_otlp_endpoint != ""
+    error_message = "otel_exporter_otlp_endpoint must be set when otel_traces_exporter is otlp, as nothing listens on localhost in Lambda"
+  }
 }
 
 module "domain" {
@@ -86,7 +131,13 @@ module "rest_api" {
   redeployment_trigger_hashes = concat([
     module.api_authorizer.resources_hash,
     module.poll_manager_microservice.resources_hash,
+    module.api_key_manager_microservice.resources_hash,
     module.vote_queue_microservice.resources_hash,
+    module.webhook_manager_microservice.resources_hash,
+    module.chat_integration_microservice.resources_hash,
+    module.account_manager_microservice.resources_hash,
+    module.template_manager_microservice.resources_hash,
+    module.series_manager_microservice.resources_hash,
     local.resources_hash,
   ])
 }
@@ -105,6 +156,9 @@ module "api_authorizer" {
   jwks_uri                  = var.jwks_uri
   audience                  = var.google_client_id
   token_issuer              = var.token_issuer
+  trusted_issuers           = var.trusted_issuers
+  single_table_name         = aws_dynamodb_table.single_table.name
+  single_table_arn          = aws_dynamodb_table.single_table.arn
   lambda_logging_policy_arn = module.lambda_logging.policy_arn
 }
 
@@ -137,6 +191,57 @@ resource "aws_api_gateway_model" "create_poll" {
       maxOptions      = var.max_options
       minDuration     = var.min_duration
       maxDuration     = var.max_duration
+      nanoIdLength    = var.nanoid_length
+
+      descriptionMaxLength = var.option_description_max_length
+      urlMaxLength         = var.option_url_max_length
+
+      maxVoteWeight  = var.max_vote_weight
+      maxVoteWeights = var.max_vote_weights
+    }
+  )
+}
+
+resource "aws_api_gateway_model" "create_option_image_upload" {
+  rest_api_id  = module.rest_api.id
+  name         = "CreateOptionImageUpload"
+  description  = "Create option image upload schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/create-option-image-upload.json",
+    { imageMaxSize = var.option_image_max_size }
+  )
+}
+
+resource "aws_api_gateway_model" "option_image_upload" {
+  rest_api_id  = module.rest_api.id
+  name         = "OptionImageUpload"
+  description  = "Option image upload schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/option-image-upload.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "edit_poll" {
+  rest_api_id  = module.rest_api.id
+  name         = "EditPoll"
+  description  = "Edit poll schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/edit-poll.json",
+    {
+      nanoIdLength    = var.nanoid_length
+      promptMinLength = var.prompt_min_length
+      promptMaxLength = var.prompt_max_length
+      optionMinLength = var.option_min_length
+      optionMaxLength = var.option_max_length
+      minOptions      = var.min_options
+      maxOptions      = var.max_options
     }
   )
 }
@@ -180,6 +285,318 @@ resource "aws_api_gateway_model" "my_polls" {
   )
 }
 
+resource "aws_api_gateway_model" "subscription_token" {
+  rest_api_id  = module.rest_api.id
+  name         = "SubscriptionToken"
+  description  = "Subscription token schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/subscription-token.json", {})
+}
+
+resource "aws_api_gateway_model" "poll_snapshot" {
+  rest_api_id  = module.rest_api.id
+  name         = "PollSnapshot"
+  description  = "Poll snapshot schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/poll-snapshot.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "poll_snapshot_requested" {
+  rest_api_id  = module.rest_api.id
+  name         = "PollSnapshotRequested"
+  description  = "Poll snapshot requested schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/poll-snapshot-requested.json", {})
+}
+
+resource "aws_api_gateway_model" "poll_timeline" {
+  rest_api_id  = module.rest_api.id
+  name         = "PollTimeline"
+  description  = "Poll timeline schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/poll-timeline.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "write_in_vote" {
+  rest_api_id  = module.rest_api.id
+  name         = "WriteInVote"
+  description  = "Write-in vote schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/write-in-vote.json",
+    {
+      optionMinLength = var.option_min_length
+      optionMaxLength = var.option_max_length
+    }
+  )
+}
+
+resource "aws_api_gateway_model" "moderate_write_in" {
+  rest_api_id  = module.rest_api.id
+  name         = "ModerateWriteIn"
+  description  = "Moderate write-in schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/moderate-write-in.json", {})
+}
+
+resource "aws_api_gateway_model" "write_in" {
+  rest_api_id  = module.rest_api.id
+  name         = "WriteIn"
+  description  = "Write-in schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/write-in.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "break_tie" {
+  rest_api_id  = module.rest_api.id
+  name         = "BreakTie"
+  description  = "Break tie schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/break-tie.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "tie_break" {
+  rest_api_id  = module.rest_api.id
+  name         = "TieBreak"
+  description  = "Tie-break schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/tie-break.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "add_poll_member" {
+  rest_api_id  = module.rest_api.id
+  name         = "AddPollMember"
+  description  = "Add poll member schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/add-poll-member.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "poll_member" {
+  rest_api_id  = module.rest_api.id
+  name         = "PollMember"
+  description  = "Poll member schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/poll-member.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "create_api_key" {
+  rest_api_id  = module.rest_api.id
+  name         = "CreateApiKey"
+  description  = "Create API key schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/create-api-key.json", {})
+}
+
+resource "aws_api_gateway_model" "api_key" {
+  rest_api_id  = module.rest_api.id
+  name         = "ApiKey"
+  description  = "API key schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/api-key.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "create_webhook" {
+  rest_api_id  = module.rest_api.id
+  name         = "CreateWebhook"
+  description  = "Create webhook schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/create-webhook.json", {})
+}
+
+resource "aws_api_gateway_model" "webhook" {
+  rest_api_id  = module.rest_api.id
+  name         = "Webhook"
+  description  = "Webhook schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/webhook.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "webhooks" {
+  rest_api_id  = module.rest_api.id
+  name         = "Webhooks"
+  description  = "Webhooks schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/webhooks.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "webhook_deliveries" {
+  rest_api_id  = module.rest_api.id
+  name         = "WebhookDeliveries"
+  description  = "Webhook deliveries schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/webhook-deliveries.json", {})
+}
+
+resource "aws_api_gateway_model" "account_export" {
+  rest_api_id  = module.rest_api.id
+  name         = "AccountExport"
+  description  = "Account export schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/account-export.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "erasure" {
+  rest_api_id  = module.rest_api.id
+  name         = "Erasure"
+  description  = "Erasure schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/erasure.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "save_template" {
+  rest_api_id  = module.rest_api.id
+  name         = "SaveTemplate"
+  description  = "Save template schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/save-template.json",
+    {
+      promptMinLength = var.prompt_min_length
+      promptMaxLength = var.prompt_max_length
+      optionMinLength = var.option_min_length
+      optionMaxLength = var.option_max_length
+      minOptions      = var.min_options
+      maxOptions      = var.max_options
+      minDuration     = var.min_duration
+      maxDuration     = var.max_duration
+    }
+  )
+}
+
+resource "aws_api_gateway_model" "template" {
+  rest_api_id  = module.rest_api.id
+  name         = "Template"
+  description  = "Template schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/template.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "templates" {
+  rest_api_id  = module.rest_api.id
+  name         = "Templates"
+  description  = "Templates schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/templates.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "create_series" {
+  rest_api_id  = module.rest_api.id
+  name         = "CreateSeries"
+  description  = "Create series schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/create-series.json",
+    {
+      promptMinLength = var.prompt_min_length
+      promptMaxLength = var.prompt_max_length
+      optionMinLength = var.option_min_length
+      optionMaxLength = var.option_max_length
+      minOptions      = var.min_options
+      maxOptions      = var.max_options
+      minDuration     = var.min_duration
+      maxDuration     = var.max_duration
+    }
+  )
+}
+
+resource "aws_api_gateway_model" "update_series_status" {
+  rest_api_id  = module.rest_api.id
+  name         = "UpdateSeriesStatus"
+  description  = "Update series status schema"
+  content_type = "application/json"
+
+  schema = templatefile("./modules/templates/models/update-series-status.json", {})
+}
+
+resource "aws_api_gateway_model" "series" {
+  rest_api_id  = module.rest_api.id
+  name         = "Series"
+  description  = "Series schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/series.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
+resource "aws_api_gateway_model" "series_results" {
+  rest_api_id  = module.rest_api.id
+  name         = "SeriesResults"
+  description  = "Series results schema"
+  content_type = "application/json"
+
+  schema = templatefile(
+    "./modules/templates/models/series-results.json",
+    { nanoIdLength = var.nanoid_length }
+  )
+}
+
 resource "aws_api_gateway_model" "error" {
   rest_api_id  = module.rest_api.id
   name         = "Error"
@@ -203,6 +620,23 @@ resource "aws_dynamodb_table" "single_table" {
     projection_type = "ALL"
   }
 
+  # Sparse index of the polls that are still open, sorted by when they expire, which the snapshot publisher queries
+  global_secondary_index {
+    name            = "GSI2"
+    hash_key        = "GSI2PK"
+    range_key       = "GSI2SK"
+    projection_type = "KEYS_ONLY"
+  }
+
+  # Index of series by their status and when they run next, which the series scheduler queries for the active
+  # series that are due
+  global_secondary_index {
+    name            = "GSI3"
+    hash_key        = "Status"
+    range_key       = "NextRunAt"
+    projection_type = "ALL"
+  }
+
   attribute {
     name = "PK"
     type = "S"
@@ -223,6 +657,32 @@ resource "aws_dynamodb_table" "single_table" {
     type = "S"
   }
 
+  attribute {
+    name = "GSI2PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI2SK"
+    type = "N"
+  }
+
+  attribute {
+    name = "Status"
+    type = "S"
+  }
+
+  attribute {
+    name = "NextRunAt"
+    type = "N"
+  }
+
+  # Archived and chat polls are purged by their time to live, and the rest of their items by the poll deleter
+  ttl {
+    attribute_name = "Ttl"
+    enabled        = true
+  }
+
   stream_enabled   = true
   stream_view_type = "NEW_AND_OLD_IMAGES"
 }
@@ -231,6 +691,8 @@ module "choreography" {
   source         = "./modules/choreography"
   ddb_stream_arn = aws_dynamodb_table.single_table.stream_arn
 
+  vote_count_publish_mode = var.vote_count_publish_mode
+
   vote_result_publisher_lambda_function_name = module.publisher_microservice.vote_result_publisher_lambda_function_name
   vote_result_publisher_lambda_arn           = module.publisher_microservice.vote_result_publisher_lambda_arn
 
@@ -240,6 +702,27 @@ module "choreography" {
   poll_modification_publisher_lambda_function_name = module.publisher_microservice.poll_modification_publisher_lambda_function_name
   poll_modification_publisher_lambda_arn           = module.publisher_microservice.poll_modification_publisher_lambda_arn
 
+  poll_snapshot_publisher_lambda_function_name = module.publisher_microservice.poll_snapshot_publisher_lambda_function_name
+  poll_snapshot_publisher_lambda_arn           = module.publisher_microservice.poll_snapshot_publisher_lambda_arn
+  poll_snapshot_schedule_expression            = var.poll_snapshot_schedule_expression
+  poll_snapshot_requested_source               = local.poll_snapshot_requested_source
+  poll_snapshot_requested_detail_type          = local.poll_snapshot_requested_detail_type
+
+  webhook_dispatcher_lambda_function_name = module.webhook_manager_microservice.webhook_dispatcher_lambda_function_name
+  webhook_dispatcher_lambda_arn           = module.webhook_manager_microservice.webhook_dispatcher_lambda_arn
+
+  chat_message_updater_lambda_function_name = module.chat_integration_microservice.chat_message_updater_lambda_function_name
+  chat_message_updater_lambda_arn           = module.chat_integration_microservice.chat_message_updater_lambda_arn
+
+  poll_deleter_lambda_function_name   = module.poll_manager_microservice.poll_deleter_lambda_function_name
+  poll_deleter_lambda_arn             = module.poll_manager_microservice.poll_deleter_lambda_arn
+  poll_deletion_requested_source      = local.poll_deletion_requested_source
+  poll_deletion_requested_detail_type = local.poll_deletion_requested_detail_type
+
+  series_scheduler_lambda_function_name = module.series_manager_microservice.series_scheduler_lambda_function_name
+  series_scheduler_lambda_arn           = module.series_manager_microservice.series_scheduler_lambda_arn
+  series_schedule_expression            = var.series_schedule_expression
+
   ddb_stream_pipe_event_source      = local.ddb_stream_pipe_event_source
   ddb_stream_pipe_event_detail_type = local.ddb_stream_pipe_event_detail_type
   vote_failed_source                = local.vote_failed_source
@@ -247,15 +730,131 @@ module "choreography" {
 }
 
 module "poll_manager_microservice" {
-  source                          = "./modules/microservices/poll-manager"
+  source                                = "./modules/microservices/poll-manager"
+  rest_api_id                           = module.rest_api.id
+  rest_api_execution_arn                = module.rest_api.execution_arn
+  stage_name                            = module.rest_api.stage_name
+  poll_model_name                       = aws_api_gateway_model.poll.name
+  create_poll_model_name                = aws_api_gateway_model.create_poll.name
+  edit_poll_model_name                  = aws_api_gateway_model.edit_poll.name
+  archive_poll_model_name               = aws_api_gateway_model.archive_poll.name
+  update_poll_duration_model_name       = aws_api_gateway_model.update_poll_duration.name
+  my_polls_model_name                   = aws_api_gateway_model.my_polls.name
+  subscription_token_model_name         = aws_api_gateway_model.subscription_token.name
+  poll_snapshot_model_name              = aws_api_gateway_model.poll_snapshot.name
+  poll_timeline_model_name              = aws_api_gateway_model.poll_timeline.name
+  moderate_write_in_model_name          = aws_api_gateway_model.moderate_write_in.name
+  write_in_model_name                   = aws_api_gateway_model.write_in.name
+  break_tie_model_name                  = aws_api_gateway_model.break_tie.name
+  tie_break_model_name                  = aws_api_gateway_model.tie_break.name
+  add_poll_member_model_name            = aws_api_gateway_model.add_poll_member.name
+  poll_member_model_name                = aws_api_gateway_model.poll_member.name
+  create_option_image_upload_model_name = aws_api_gateway_model.create_option_image_upload.name
+  option_image_upload_model_name        = aws_api_gateway_model.option_image_upload.name
+  poll_snapshot_requested_model_name    = aws_api_gateway_model.poll_snapshot_requested.name
+  error_model_name                      = aws_api_gateway_model.error.name
+  parent_id                             = module.rest_api.root_resource_id
+  custom_authorizer_id                  = module.api_authorizer.id
+  single_table_name                     = aws_dynamodb_table.single_table.name
+  single_table_arn                      = aws_dynamodb_table.single_table.arn
+  nanoid_alphabet                       = var.nanoid_alphabet
+  nanoid_length                         = var.nanoid_length
+  lambda_logging_policy_arn             = module.lambda_logging.policy_arn
+  subscription_token_secret             = var.subscription_token_secret
+  subscription_token_ttl                = var.subscription_token_ttl
+  api_role_name                         = module.api_gateway_iam.role_name
+  api_role_arn                          = module.api_gateway_iam.role_arn
+  region                                = local.region
+  event_bus_name                        = module.choreography.event_bus_name
+  event_bus_arn                         = module.choreography.event_bus_arn
+  poll_snapshot_requested_source        = local.poll_snapshot_requested_source
+  poll_snapshot_requested_detail_type   = local.poll_snapshot_requested_detail_type
+  poll_deletion_requested_source        = local.poll_deletion_requested_source
+  poll_deletion_requested_detail_type   = local.poll_deletion_requested_detail_type
+  ddb_stream_pipe_event_source          = local.ddb_stream_pipe_event_source
+  ddb_stream_pipe_event_detail_type     = local.ddb_stream_pipe_event_detail_type
+  archived_poll_retention_days          = var.archived_poll_retention_days
+  prompt_min_length                     = var.prompt_min_length
+  prompt_max_length                     = var.prompt_max_length
+  option_min_length                     = var.option_min_length
+  option_max_length                     = var.option_max_length
+  min_options                           = var.min_options
+  max_options                           = var.max_options
+  min_duration                          = var.min_duration
+  max_duration                          = var.max_duration
+  option_description_max_length         = var.option_description_max_length
+  option_url_max_length                 = var.option_url_max_length
+  option_image_max_size                 = var.option_image_max_size
+  max_vote_weight                       = var.max_vote_weight
+  max_vote_weights                      = var.max_vote_weights
+}
+
+module "api_key_manager_microservice" {
+  source                    = "./modules/microservices/api-key-manager"
+  rest_api_id               = module.rest_api.id
+  rest_api_execution_arn    = module.rest_api.execution_arn
+  stage_name                = module.rest_api.stage_name
+  create_api_key_model_name = aws_api_gateway_model.create_api_key.name
+  api_key_model_name        = aws_api_gateway_model.api_key.name
+  error_model_name          = aws_api_gateway_model.error.name
+  parent_id                 = module.rest_api.root_resource_id
+  custom_authorizer_id      = module.api_authorizer.id
+  single_table_name         = aws_dynamodb_table.single_table.name
+  single_table_arn          = aws_dynamodb_table.single_table.arn
+  nanoid_alphabet           = var.nanoid_alphabet
+  nanoid_length             = var.nanoid_length
+  lambda_logging_policy_arn = module.lambda_logging.policy_arn
+}
+
+module "account_manager_microservice" {
+  source                              = "./modules/microservices/account-manager"
+  rest_api_id                         = module.rest_api.id
+  rest_api_execution_arn              = module.rest_api.execution_arn
+  stage_name                          = module.rest_api.stage_name
+  account_export_model_name           = aws_api_gateway_model.account_export.name
+  erasure_model_name                  = aws_api_gateway_model.erasure.name
+  error_model_name                    = aws_api_gateway_model.error.name
+  parent_id                           = module.rest_api.root_resource_id
+  custom_authorizer_id                = module.api_authorizer.id
+  single_table_name                   = aws_dynamodb_table.single_table.name
+  single_table_arn                    = aws_dynamodb_table.single_table.arn
+  nanoid_alphabet                     = var.nanoid_alphabet
+  nanoid_length                       = var.nanoid_length
+  lambda_logging_policy_arn           = module.lambda_logging.policy_arn
+  event_bus_name                      = module.choreography.event_bus_name
+  event_bus_arn                       = module.choreography.event_bus_arn
+  poll_deletion_requested_source      = local.poll_deletion_requested_source
+  poll_deletion_requested_detail_type = local.poll_deletion_requested_detail_type
+  vote_erasure_policy                 = var.vote_erasure_policy
+}
+
+module "template_manager_microservice" {
+  source                    = "./modules/microservices/template-manager"
+  rest_api_id               = module.rest_api.id
+  rest_api_execution_arn    = module.rest_api.execution_arn
+  stage_name                = module.rest_api.stage_name
+  save_template_model_name  = aws_api_gateway_model.save_template.name
+  template_model_name       = aws_api_gateway_model.template.name
+  templates_model_name      = aws_api_gateway_model.templates.name
+  error_model_name          = aws_api_gateway_model.error.name
+  parent_id                 = module.rest_api.root_resource_id
+  custom_authorizer_id      = module.api_authorizer.id
+  single_table_name         = aws_dynamodb_table.single_table.name
+  single_table_arn          = aws_dynamodb_table.single_table.arn
+  nanoid_alphabet           = var.nanoid_alphabet
+  nanoid_length             = var.nanoid_length
+  lambda_logging_policy_arn = module.lambda_logging.policy_arn
+}
+
+module "series_manager_microservice" {
+  source                          = "./modules/microservices/series-manager"
   rest_api_id                     = module.rest_api.id
   rest_api_execution_arn          = module.rest_api.execution_arn
   stage_name                      = module.rest_api.stage_name
-  poll_model_name                 = aws_api_gateway_model.poll.name
-  create_poll_model_name          = aws_api_gateway_model.create_poll.name
-  archive_poll_model_name         = aws_api_gateway_model.archive_poll.name
-  update_poll_duration_model_name = aws_api_gateway_model.update_poll_duration.name
-  my_polls_model_name             = aws_api_gateway_model.my_polls.name
+  create_series_model_name        = aws_api_gateway_model.create_series.name
+  update_series_status_model_name = aws_api_gateway_model.update_series_status.name
+  series_model_name               = aws_api_gateway_model.series.name
+  series_results_model_name       = aws_api_gateway_model.series_results.name
   error_model_name                = aws_api_gateway_model.error.name
   parent_id                       = module.rest_api.root_resource_id
   custom_authorizer_id            = module.api_authorizer.id
@@ -263,25 +862,93 @@ module "poll_manager_microservice" {
   single_table_arn                = aws_dynamodb_table.single_table.arn
   nanoid_alphabet                 = var.nanoid_alphabet
   nanoid_length                   = var.nanoid_length
+  prompt_min_length               = var.prompt_min_length
+  prompt_max_length               = var.prompt_max_length
+  option_min_length               = var.option_min_length
+  option_max_length               = var.option_max_length
+  min_options                     = var.min_options
+  max_options                     = var.max_options
+  min_duration                    = var.min_duration
+  max_duration                    = var.max_duration
   lambda_logging_policy_arn       = module.lambda_logging.policy_arn
 }
 
 module "vote_queue_microservice" {
-  source                    = "./modules/microservices/vote-queue"
-  api_role_name             = module.api_gateway_iam.role_name
-  api_role_arn              = module.api_gateway_iam.role_arn
-  rest_api_id               = module.rest_api.id
-  vote_accepted_model_name  = aws_api_gateway_model.vote_accepted.name
-  error_model_name          = aws_api_gateway_model.error.name
-  poll_resource_id          = module.poll_manager_microservice.poll_resource_id
-  custom_authorizer_id      = module.api_authorizer.id
-  public_poll_resource_id   = module.poll_manager_microservice.public_poll_resource_id
-  lambda_logging_policy_arn = module.lambda_logging.policy_arn
-  region                    = local.region
-  single_table_name         = aws_dynamodb_table.single_table.name
-  single_table_arn          = aws_dynamodb_table.single_table.arn
-  event_bus_name            = module.choreography.event_bus_name
-  event_bus_arn             = module.choreography.event_bus_arn
+  source                     = "./modules/microservices/vote-queue"
+  api_role_name              = module.api_gateway_iam.role_name
+  api_role_arn               = module.api_gateway_iam.role_arn
+  rest_api_id                = module.rest_api.id
+  vote_accepted_model_name   = aws_api_gateway_model.vote_accepted.name
+  write_in_vote_model_name   = aws_api_gateway_model.write_in_vote.name
+  error_model_name           = aws_api_gateway_model.error.name
+  poll_resource_id           = module.poll_manager_microservice.poll_resource_id
+  custom_authorizer_id       = module.api_authorizer.id
+  write_ins_resource_id      = module.poll_manager_microservice.write_ins_resource_id
+  public_poll_resource_id    = module.poll_manager_microservice.public_poll_resource_id
+  lambda_logging_policy_arn  = module.lambda_logging.policy_arn
+  region                     = local.region
+  single_table_name          = aws_dynamodb_table.single_table.name
+  single_table_arn           = aws_dynamodb_table.single_table.arn
+  event_bus_name             = module.choreography.event_bus_name
+  event_bus_arn              = module.choreography.event_bus_arn
+  nanoid_alphabet            = var.nanoid_alphabet
+  nanoid_length              = var.nanoid_length
+  max_write_ins              = var.max_write_ins
+  otel_environment_variables = local.otel_environment_variables
+}
+
+module "webhook_manager_microservice" {
+  source                            = "./modules/microservices/webhook-manager"
+  rest_api_id                       = module.rest_api.id
+  rest_api_execution_arn            = module.rest_api.execution_arn
+  stage_name                        = module.rest_api.stage_name
+  create_webhook_model_name         = aws_api_gateway_model.create_webhook.name
+  webhook_model_name                = aws_api_gateway_model.webhook.name
+  webhooks_model_name               = aws_api_gateway_model.webhooks.name
+  webhook_deliveries_model_name     = aws_api_gateway_model.webhook_deliveries.name
+  error_model_name                  = aws_api_gateway_model.error.name
+  poll_resource_id                  = module.poll_manager_microservice.poll_resource_id
+  custom_authorizer_id              = module.api_authorizer.id
+  single_table_name                 = aws_dynamodb_table.single_table.name
+  single_table_arn                  = aws_dynamodb_table.single_table.arn
+  nanoid_alphabet                   = var.nanoid_alphabet
+  nanoid_length                     = var.nanoid_length
+  lambda_logging_policy_arn         = module.lambda_logging.policy_arn
+  ddb_stream_pipe_event_source      = local.ddb_stream_pipe_event_source
+  ddb_stream_pipe_event_detail_type = local.ddb_stream_pipe_event_detail_type
+  vote_failed_source                = local.vote_failed_source
+  vote_failed_detail_type           = local.vote_failed_detail_type
+  max_delivery_attempts             = var.webhook_max_delivery_attempts
+}
+
+module "chat_integration_microservice" {
+  source                            = "./modules/microservices/chat-integration"
+  rest_api_id                       = module.rest_api.id
+  rest_api_execution_arn            = module.rest_api.execution_arn
+  stage_name                        = module.rest_api.stage_name
+  error_model_name                  = aws_api_gateway_model.error.name
+  parent_id                         = module.rest_api.root_resource_id
+  single_table_name                 = aws_dynamodb_table.single_table.name
+  single_table_arn                  = aws_dynamodb_table.single_table.arn
+  nanoid_alphabet                   = var.nanoid_alphabet
+  nanoid_length                     = var.nanoid_length
+  lambda_logging_policy_arn         = module.lambda_logging.policy_arn
+  vote_queue_url                    = module.vote_queue_microservice.vote_queue_url
+  vote_queue_arn                    = module.vote_queue_microservice.vote_queue_arn
+  chat_signing_secret               = var.chat_signing_secret
+  chat_bot_token                    = var.chat_bot_token
+  chat_poll_duration                = var.chat_poll_duration
+  chat_poll_retention_days          = var.chat_poll_retention_days
+  prompt_min_length                 = var.prompt_min_length
+  prompt_max_length                 = var.prompt_max_length
+  option_min_length                 = var.option_min_length
+  option_max_length                 = var.option_max_length
+  min_options                       = var.min_options
+  max_options                       = var.max_options
+  min_duration                      = var.min_duration
+  max_duration                      = var.max_duration
+  ddb_stream_pipe_event_source      = local.ddb_stream_pipe_event_source
+  ddb_stream_pipe_event_detail_type = local.ddb_stream_pipe_event_detail_type
 }
 
 module "publisher_microservice" {
@@ -291,8 +958,20 @@ module "publisher_microservice" {
   ddb_stream_pipe_event_detail_type = local.ddb_stream_pipe_event_detail_type
   vote_failed_source                = local.vote_failed_source
   vote_failed_detail_type           = local.vote_failed_detail_type
+  single_table_name                 = aws_dynamodb_table.single_table.name
+  single_table_arn                  = aws_dynamodb_table.single_table.arn
+  ddb_stream_arn                    = aws_dynamodb_table.single_table.stream_arn
   region                            = local.region
   iot_custom_authorizer_name        = var.iot_custom_authorizer_name
+  subscription_token_secret         = var.subscription_token_secret
+  otel_environment_variables        = local.otel_environment_variables
+
+  poll_snapshot_requested_source      = local.poll_snapshot_requested_source
+  poll_snapshot_requested_detail_type = local.poll_snapshot_requested_detail_type
+
+  vote_count_publish_mode               = var.vote_count_publish_mode
+  vote_count_max_publish_rate           = var.vote_count_max_publish_rate
+  vote_count_batching_window_in_seconds = var.vote_count_batching_window_in_seconds
 }
 
 data "aws_iot_endpoint" "iot" {
@@ -40,3 +40,25 @@ resource "aws_iam_role_policy_attachment" "logging" {
   role       = module.lambda_role.role_name
   policy_arn = var.lambda_logging_policy_arn
 }
+
+data "aws_iam_policy_document" "lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "lambda_ddb" {
+  name        = "pseudopoll-authorizer-lambda-ddb"
+  description = "IAM policy for authorizer lambda to look up API keys in DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "lambda_ddb" {
+  role       = module.lambda_role.role_name
+  policy_arn = aws_iam_policy.lambda_ddb.arn
+}
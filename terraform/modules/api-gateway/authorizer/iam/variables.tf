@@ -3,6 +3,11 @@ variable "authorizer_lambda_arn" {
   type        = string
 }
 
+variable "single_table_arn" {
+  description = "ARN of the single table"
+  type        = string
+}
+
 variable "lambda_logging_policy_arn" {
   description = "ARN of the lambda logging policy"
   type        = string
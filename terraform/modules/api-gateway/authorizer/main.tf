@@ -6,15 +6,34 @@ module "authorizer_lambda" {
   archive_output_path = var.archive_output_path
 
   environment_variables = {
-    JWKS_URI     = var.jwks_uri
-    AUDIENCE     = var.audience
-    TOKEN_ISSUER = var.token_issuer
+    TRUSTED_ISSUERS = jsonencode(concat(
+      [
+        {
+          issuer   = var.token_issuer
+          jwksUri  = var.jwks_uri
+          audience = var.audience
+        }
+      ],
+      [
+        for trusted_issuer in var.trusted_issuers : {
+          issuer   = trusted_issuer.issuer
+          jwksUri  = trusted_issuer.jwks_uri
+          audience = trusted_issuer.audience
+        }
+      ]
+    ))
+    JWKS_REFRESH_INTERVAL   = var.jwks_refresh_interval
+    JWKS_REFRESH_RATE_LIMIT = var.jwks_refresh_rate_limit
+    SINGLE_TABLE_NAME       = var.single_table_name
+    DEFAULT_ROLES           = join(",", var.default_roles)
+    GROUPS_CLAIM            = var.groups_claim
   }
 }
 
 module "authorizer_iam" {
   source                    = "./iam"
   authorizer_lambda_arn     = module.authorizer_lambda.arn
+  single_table_arn          = var.single_table_arn
   lambda_logging_policy_arn = var.lambda_logging_policy_arn
 }
 
@@ -23,5 +42,5 @@ resource "aws_api_gateway_authorizer" "authorizer" {
   rest_api_id                      = var.rest_api_id
   authorizer_uri                   = module.authorizer_lambda.invoke_arn
   authorizer_credentials           = module.authorizer_iam.invocation_role_arn
-  authorizer_result_ttl_in_seconds = 0
+  authorizer_result_ttl_in_seconds = var.result_ttl_in_seconds
 }
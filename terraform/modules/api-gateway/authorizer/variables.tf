@@ -27,6 +27,18 @@ variable "jwks_uri" {
   type = string
 }
 
+variable "jwks_refresh_interval" {
+  description = "How often the cached JWKS is refreshed in the background, as a Go duration string"
+  type        = string
+  default     = "1h"
+}
+
+variable "jwks_refresh_rate_limit" {
+  description = "Minimum time between JWKS refreshes triggered by unknown key IDs, as a Go duration string"
+  type        = string
+  default     = "5m"
+}
+
 variable "audience" {
   type      = string
   sensitive = true
@@ -36,6 +48,44 @@ variable "token_issuer" {
   type = string
 }
 
+variable "trusted_issuers" {
+  description = "Additional token issuers to trust, e.g. for bots and CI jobs, each with its own JWKS and audience"
+  type = list(object({
+    issuer   = string
+    jwks_uri = string
+    audience = string
+  }))
+  default = []
+}
+
+variable "default_roles" {
+  description = "Roles granted to principals whose token or API key doesn't carry any (viewer, voter, creator or admin)"
+  type        = list(string)
+  default     = ["creator"]
+}
+
+variable "groups_claim" {
+  description = "Claim of the token that lists the groups its principal's votes are weighed by"
+  type        = string
+  default     = "groups"
+}
+
+variable "result_ttl_in_seconds" {
+  description = "How long API Gateway caches the authorizer's policy, which covers every route the principal may call"
+  type        = number
+  default     = 300
+}
+
+variable "single_table_name" {
+  description = "Name of the single table holding hashed API keys"
+  type        = string
+}
+
+variable "single_table_arn" {
+  description = "ARN of the single table holding hashed API keys"
+  type        = string
+}
+
 variable "lambda_logging_policy_arn" {
   description = "ARN of the Lambda logging policy"
   type        = string
@@ -197,7 +197,7 @@ resource "aws_cloudwatch_event_target" "vote_succeeded" {
 
 resource "aws_cloudwatch_event_rule" "vote_failed" {
   name           = "pseudopoll-vote-failed-event-rule"
-  description    = "A rule that matches failed vote events from the vote lambda and sends them to the publisher microservice"
+  description    = "A rule that matches failed vote events from the vote lambda and sends them to the publisher microservice and webhook dispatcher"
   event_bus_name = aws_cloudwatch_event_bus.event_bus.name
 
   event_pattern = jsonencode({
@@ -222,9 +222,25 @@ resource "aws_cloudwatch_event_target" "vote_failed" {
   arn            = var.vote_result_publisher_lambda_arn
 }
 
+resource "aws_lambda_permission" "vote_failed_webhook" {
+  statement_id  = "PseudoPollAllowWebhookDispatcherLambdaExecutionFromVoteFailedEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.webhook_dispatcher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.vote_failed.arn
+}
+
+resource "aws_cloudwatch_event_target" "vote_failed_webhook" {
+  rule           = aws_cloudwatch_event_rule.vote_failed.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-vote-failed-event-rule-webhook-target"
+  arn            = var.webhook_dispatcher_lambda_arn
+}
+
 resource "aws_cloudwatch_event_rule" "vote_counted" {
   name           = "pseudopoll-vote-counted-event-rule"
-  description    = "A rule that matches vote counted events from the dynamodb stream pipe and sends them to the publisher microservice"
+  description    = "A rule that matches vote counted events from the dynamodb stream pipe and sends them to the publisher microservice, webhook dispatcher and chat message updater"
   event_bus_name = aws_cloudwatch_event_bus.event_bus.name
 
   event_pattern = jsonencode({
@@ -246,7 +262,10 @@ resource "aws_cloudwatch_event_rule" "vote_counted" {
   })
 }
 
+# In batch mode the vote count publisher reads the stream itself
 resource "aws_lambda_permission" "vote_counted" {
+  count = var.vote_count_publish_mode == "batch" ? 0 : 1
+
   statement_id  = "PseudoPollAllowVotePublisherLambdaExecutionFromVoteCountedEventRule"
   action        = "lambda:InvokeFunction"
   function_name = var.vote_count_publisher_lambda_function_name
@@ -256,15 +275,49 @@ resource "aws_lambda_permission" "vote_counted" {
 }
 
 resource "aws_cloudwatch_event_target" "vote_counted" {
+  count = var.vote_count_publish_mode == "batch" ? 0 : 1
+
   rule           = aws_cloudwatch_event_rule.vote_counted.name
   event_bus_name = aws_cloudwatch_event_bus.event_bus.name
   target_id      = "pseudopoll-vote-counted-event-rule-target"
   arn            = var.vote_count_publisher_lambda_arn
 }
 
+resource "aws_lambda_permission" "vote_counted_webhook" {
+  statement_id  = "PseudoPollAllowWebhookDispatcherLambdaExecutionFromVoteCountedEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.webhook_dispatcher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.vote_counted.arn
+}
+
+resource "aws_cloudwatch_event_target" "vote_counted_webhook" {
+  rule           = aws_cloudwatch_event_rule.vote_counted.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-vote-counted-event-rule-webhook-target"
+  arn            = var.webhook_dispatcher_lambda_arn
+}
+
+resource "aws_lambda_permission" "vote_counted_chat" {
+  statement_id  = "PseudoPollAllowChatMessageUpdaterLambdaExecutionFromVoteCountedEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.chat_message_updater_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.vote_counted.arn
+}
+
+resource "aws_cloudwatch_event_target" "vote_counted_chat" {
+  rule           = aws_cloudwatch_event_rule.vote_counted.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-vote-counted-event-rule-chat-target"
+  arn            = var.chat_message_updater_lambda_arn
+}
+
 resource "aws_cloudwatch_event_rule" "poll_modified" {
   name           = "pseudopoll-poll-modified-event-rule"
-  description    = "A rule that matches poll modified events from the dynamodb stream pipe and sends them to the publisher microservice"
+  description    = "A rule that matches poll modified events from the dynamodb stream pipe and sends them to the publisher microservice and webhook dispatcher"
   event_bus_name = aws_cloudwatch_event_bus.event_bus.name
 
   event_pattern = jsonencode({
@@ -301,3 +354,192 @@ resource "aws_cloudwatch_event_target" "poll_modified" {
   target_id      = "pseudopoll-poll-modified-event-rule-target"
   arn            = var.poll_modification_publisher_lambda_arn
 }
+
+resource "aws_lambda_permission" "poll_modified_webhook" {
+  statement_id  = "PseudoPollAllowWebhookDispatcherLambdaExecutionFromPollModifiedEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.webhook_dispatcher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_modified.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_modified_webhook" {
+  rule           = aws_cloudwatch_event_rule.poll_modified.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-poll-modified-event-rule-webhook-target"
+  arn            = var.webhook_dispatcher_lambda_arn
+}
+
+resource "aws_cloudwatch_event_rule" "poll_snapshot_requested" {
+  name           = "pseudopoll-poll-snapshot-requested-event-rule"
+  description    = "A rule that matches poll snapshot requested events from the poll manager and sends them to the publisher microservice"
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+
+  event_pattern = jsonencode({
+    source      = [{ equals-ignore-case = var.poll_snapshot_requested_source }]
+    detail-type = [{ equals-ignore-case = var.poll_snapshot_requested_detail_type }]
+  })
+}
+
+resource "aws_lambda_permission" "poll_snapshot_requested" {
+  statement_id  = "PseudoPollAllowPollSnapshotPublisherLambdaExecutionFromPollSnapshotRequestedEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.poll_snapshot_publisher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_snapshot_requested.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_snapshot_requested" {
+  rule           = aws_cloudwatch_event_rule.poll_snapshot_requested.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-poll-snapshot-requested-event-rule-target"
+  arn            = var.poll_snapshot_publisher_lambda_arn
+}
+
+resource "aws_cloudwatch_event_rule" "poll_deletion_requested" {
+  name           = "pseudopoll-poll-deletion-requested-event-rule"
+  description    = "A rule that matches poll deletion requested events from the poll manager and sends them to the poll deleter"
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+
+  event_pattern = jsonencode({
+    source      = [{ equals-ignore-case = var.poll_deletion_requested_source }]
+    detail-type = [{ equals-ignore-case = var.poll_deletion_requested_detail_type }]
+  })
+}
+
+resource "aws_lambda_permission" "poll_deletion_requested" {
+  statement_id  = "PseudoPollAllowPollDeleterLambdaExecutionFromPollDeletionRequestedEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.poll_deleter_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_deletion_requested.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_deletion_requested" {
+  rule           = aws_cloudwatch_event_rule.poll_deletion_requested.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-poll-deletion-requested-event-rule-target"
+  arn            = var.poll_deleter_lambda_arn
+}
+
+resource "aws_cloudwatch_event_rule" "poll_expired" {
+  name           = "pseudopoll-poll-expired-event-rule"
+  description    = "A rule that matches polls removed by their time to live from the dynamodb stream pipe and sends them to the publisher microservice, webhook dispatcher and poll deleter"
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+
+  event_pattern = jsonencode({
+    source      = [{ equals-ignore-case = var.ddb_stream_pipe_event_source }]
+    detail-type = [{ equals-ignore-case = var.ddb_stream_pipe_event_detail_type }]
+    detail = {
+      eventName = [{ equals-ignore-case = "REMOVE" }]
+      userIdentity = {
+        type        = ["Service"]
+        principalId = ["dynamodb.amazonaws.com"]
+      }
+      dynamodb = {
+        Keys = {
+          PK = {
+            S = [{ prefix = "poll|" }]
+          }
+          SK = {
+            S = [{ prefix = "poll|" }]
+          }
+        }
+      }
+    }
+  })
+}
+
+resource "aws_lambda_permission" "poll_expired" {
+  statement_id  = "PseudoPollAllowPollModificationPublisherLambdaExecutionFromPollExpiredEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.poll_modification_publisher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_expired.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_expired" {
+  rule           = aws_cloudwatch_event_rule.poll_expired.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-poll-expired-event-rule-target"
+  arn            = var.poll_modification_publisher_lambda_arn
+}
+
+resource "aws_lambda_permission" "poll_expired_webhook" {
+  statement_id  = "PseudoPollAllowWebhookDispatcherLambdaExecutionFromPollExpiredEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.webhook_dispatcher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_expired.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_expired_webhook" {
+  rule           = aws_cloudwatch_event_rule.poll_expired.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-poll-expired-event-rule-webhook-target"
+  arn            = var.webhook_dispatcher_lambda_arn
+}
+
+resource "aws_lambda_permission" "poll_expired_deleter" {
+  statement_id  = "PseudoPollAllowPollDeleterLambdaExecutionFromPollExpiredEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.poll_deleter_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_expired.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_expired_deleter" {
+  rule           = aws_cloudwatch_event_rule.poll_expired.name
+  event_bus_name = aws_cloudwatch_event_bus.event_bus.name
+  target_id      = "pseudopoll-poll-expired-event-rule-deleter-target"
+  arn            = var.poll_deleter_lambda_arn
+}
+
+# Scheduled rules can only be created on the default event bus
+resource "aws_cloudwatch_event_rule" "poll_snapshot_schedule" {
+  name                = "pseudopoll-poll-snapshot-schedule-event-rule"
+  description         = "A rule that periodically republishes the retained snapshots of active polls"
+  schedule_expression = var.poll_snapshot_schedule_expression
+}
+
+resource "aws_lambda_permission" "poll_snapshot_schedule" {
+  statement_id  = "PseudoPollAllowPollSnapshotPublisherLambdaExecutionFromPollSnapshotScheduleEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.poll_snapshot_publisher_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.poll_snapshot_schedule.arn
+}
+
+resource "aws_cloudwatch_event_target" "poll_snapshot_schedule" {
+  rule      = aws_cloudwatch_event_rule.poll_snapshot_schedule.name
+  target_id = "pseudopoll-poll-snapshot-schedule-event-rule-target"
+  arn       = var.poll_snapshot_publisher_lambda_arn
+}
+
+resource "aws_cloudwatch_event_rule" "series_schedule" {
+  name                = "pseudopoll-series-schedule-event-rule"
+  description         = "A rule that periodically creates the polls of recurring poll series that are due"
+  schedule_expression = var.series_schedule_expression
+}
+
+resource "aws_lambda_permission" "series_schedule" {
+  statement_id  = "PseudoPollAllowSeriesSchedulerLambdaExecutionFromSeriesScheduleEventRule"
+  action        = "lambda:InvokeFunction"
+  function_name = var.series_scheduler_lambda_function_name
+  principal     = "events.amazonaws.com"
+
+  source_arn = aws_cloudwatch_event_rule.series_schedule.arn
+}
+
+resource "aws_cloudwatch_event_target" "series_schedule" {
+  rule      = aws_cloudwatch_event_rule.series_schedule.name
+  target_id = "pseudopoll-series-schedule-event-rule-target"
+  arn       = var.series_scheduler_lambda_arn
+}
@@ -3,6 +3,11 @@ variable "ddb_stream_arn" {
   type        = string
 }
 
+variable "vote_count_publish_mode" {
+  description = "How vote counts are published; in batch mode the vote count publisher reads the stream itself"
+  type        = string
+}
+
 variable "vote_result_publisher_lambda_function_name" {
   description = "Function name of the vote result publisher lambda"
   type        = string
@@ -33,6 +38,31 @@ variable "poll_modification_publisher_lambda_arn" {
   type        = string
 }
 
+variable "poll_snapshot_publisher_lambda_function_name" {
+  description = "Function name of the poll snapshot publisher lambda"
+  type        = string
+}
+
+variable "poll_snapshot_publisher_lambda_arn" {
+  description = "ARN of the poll snapshot publisher lambda"
+  type        = string
+}
+
+variable "poll_snapshot_schedule_expression" {
+  description = "How often the retained snapshots of active polls are republished"
+  type        = string
+}
+
+variable "poll_snapshot_requested_source" {
+  description = "The source name of the poll snapshot requested event"
+  type        = string
+}
+
+variable "poll_snapshot_requested_detail_type" {
+  description = "The detail type of the poll snapshot requested event"
+  type        = string
+}
+
 variable "ddb_stream_pipe_event_source" {
   description = "The source name of the DynamoDB stream pipe"
   type        = string
@@ -52,3 +82,58 @@ variable "vote_failed_detail_type" {
   description = "The detail type of the vote failed event"
   type        = string
 }
+
+variable "webhook_dispatcher_lambda_function_name" {
+  description = "Name of the webhook dispatcher lambda function"
+  type        = string
+}
+
+variable "webhook_dispatcher_lambda_arn" {
+  description = "ARN of the webhook dispatcher lambda function"
+  type        = string
+}
+
+variable "chat_message_updater_lambda_function_name" {
+  description = "Name of the chat message updater lambda function"
+  type        = string
+}
+
+variable "chat_message_updater_lambda_arn" {
+  description = "ARN of the chat message updater lambda function"
+  type        = string
+}
+
+variable "poll_deleter_lambda_function_name" {
+  description = "Name of the poll deleter lambda function"
+  type        = string
+}
+
+variable "poll_deleter_lambda_arn" {
+  description = "ARN of the poll deleter lambda function"
+  type        = string
+}
+
+variable "poll_deletion_requested_source" {
+  description = "The source name of the poll deletion requested event"
+  type        = string
+}
+
+variable "poll_deletion_requested_detail_type" {
+  description = "The detail type of the poll deletion requested event"
+  type        = string
+}
+
+variable "series_scheduler_lambda_function_name" {
+  description = "Name of the series scheduler lambda function"
+  type        = string
+}
+
+variable "series_scheduler_lambda_arn" {
+  description = "ARN of the series scheduler lambda function"
+  type        = string
+}
+
+variable "series_schedule_expression" {
+  description = "How often recurring poll series are checked for runs that are due"
+  type        = string
+}
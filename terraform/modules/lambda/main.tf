@@ -12,6 +12,9 @@ resource "aws_lambda_function" "function" {
   handler          = "bootstrap"
   runtime          = "provided.al2"
   architectures    = ["arm64"]
+  timeout          = var.timeout
+
+  reserved_concurrent_executions = var.reserved_concurrent_executions
 
   environment {
     variables = var.environment_variables
@@ -23,3 +23,15 @@ variable "environment_variables" {
   type        = map(string)
   default     = null
 }
+
+variable "reserved_concurrent_executions" {
+  description = "Amount of reserved concurrent executions for the function; -1 removes any concurrency limitation"
+  type        = number
+  default     = -1
+}
+
+variable "timeout" {
+  description = "Amount of time the function has to run in seconds"
+  type        = number
+  default     = 3
+}
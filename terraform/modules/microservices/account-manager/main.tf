@@ -0,0 +1,270 @@
+resource "aws_api_gateway_resource" "me" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.parent_id
+  path_part   = "me"
+}
+
+resource "aws_api_gateway_resource" "export" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.me.id
+  path_part   = "export"
+}
+
+resource "aws_api_gateway_method" "export_account" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.export.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "export_account" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.me.path_part}/${aws_api_gateway_resource.export.path_part}/${aws_api_gateway_method.export_account.http_method}"
+
+  # The response body holds everything stored about the user, so it is kept out of the execution logs
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = false
+  }
+}
+
+resource "aws_api_gateway_integration" "export_account" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.export.id
+  http_method             = aws_api_gateway_method.export_account.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.export_account_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "export_account_api_lambda" {
+  statement_id  = "PseudoPollAllowExportAccountLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.export_account_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.export_account.http_method}${aws_api_gateway_resource.export.path}"
+}
+
+module "export_account_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-export-account-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "export_account_logging" {
+  role       = module.export_account_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "export_account_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "export_account_lambda_ddb" {
+  name        = "pseudopoll-export-account-lambda-ddb"
+  description = "IAM policy for export account lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.export_account_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "export_account_lambda_ddb" {
+  role       = module.export_account_lambda_role.role_name
+  policy_arn = aws_iam_policy.export_account_lambda_ddb.arn
+}
+
+module "export_account_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-export-account"
+  role_arn            = module.export_account_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/export-account/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/export-account/bin/export-account.zip"
+  timeout             = 29
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "export_account_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.export.id
+  http_method = aws_api_gateway_method.export_account.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.account_export_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "export_account_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.export.id
+  http_method = aws_api_gateway_method.export_account.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "erase_account" {
+  rest_api_id = var.rest_api_id
+  http_method = "DELETE"
+  resource_id = aws_api_gateway_resource.me.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "erase_account" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.me.path_part}/${aws_api_gateway_method.erase_account.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "erase_account" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.me.id
+  http_method             = aws_api_gateway_method.erase_account.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.erase_account_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "erase_account_api_lambda" {
+  statement_id  = "PseudoPollAllowEraseAccountLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.erase_account_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.erase_account.http_method}${aws_api_gateway_resource.me.path}"
+}
+
+module "erase_account_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-erase-account-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "erase_account_logging" {
+  role       = module.erase_account_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "erase_account_lambda_ddb_events" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:PutItem",
+      "dynamodb:UpdateItem",
+      "dynamodb:DeleteItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = ["events:PutEvents"]
+
+    resources = [var.event_bus_arn]
+  }
+}
+
+resource "aws_iam_policy" "erase_account_lambda_ddb_events" {
+  name        = "pseudopoll-erase-account-lambda-ddb-events"
+  description = "IAM policy for erase account lambda to read from and write to DynamoDB and send events to the event bus"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.erase_account_lambda_ddb_events.json
+}
+
+resource "aws_iam_role_policy_attachment" "erase_account_lambda_ddb_events" {
+  role       = module.erase_account_lambda_role.role_name
+  policy_arn = aws_iam_policy.erase_account_lambda_ddb_events.arn
+}
+
+module "erase_account_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-erase-account"
+  role_arn            = module.erase_account_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/erase-account/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/erase-account/bin/erase-account.zip"
+
+  # API Gateway gives up on the integration after 29 seconds, so the erasure stops in time to answer
+  timeout = 29
+
+  environment_variables = {
+    SINGLE_TABLE_NAME              = var.single_table_name
+    NANOID_ALPHABET                = var.nanoid_alphabet
+    NANOID_LENGTH                  = "${var.nanoid_length}"
+    VOTE_ERASURE_POLICY            = var.vote_erasure_policy
+    EVENT_BUS_NAME                 = var.event_bus_name
+    DELETION_REQUESTED_SOURCE      = var.poll_deletion_requested_source
+    DELETION_REQUESTED_DETAIL_TYPE = var.poll_deletion_requested_detail_type
+  }
+}
+
+resource "aws_api_gateway_method_response" "erase_account_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.me.id
+  http_method = aws_api_gateway_method.erase_account.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.erasure_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "erase_account_accepted" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.me.id
+  http_method = aws_api_gateway_method.erase_account.http_method
+  status_code = "202"
+
+  response_models = {
+    "application/json" = var.erasure_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "erase_account_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.me.id
+  http_method = aws_api_gateway_method.erase_account.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
@@ -0,0 +1,15 @@
+output "resources_hash" {
+  value = sha1(jsonencode([
+    aws_api_gateway_resource.me,
+    aws_api_gateway_resource.export,
+    aws_api_gateway_method.export_account,
+    aws_api_gateway_integration.export_account,
+    aws_api_gateway_method_response.export_account_ok,
+    aws_api_gateway_method_response.export_account_internal_server_error,
+    aws_api_gateway_method.erase_account,
+    aws_api_gateway_integration.erase_account,
+    aws_api_gateway_method_response.erase_account_ok,
+    aws_api_gateway_method_response.erase_account_accepted,
+    aws_api_gateway_method_response.erase_account_internal_server_error,
+  ]))
+}
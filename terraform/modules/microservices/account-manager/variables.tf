@@ -0,0 +1,89 @@
+variable "rest_api_id" {
+  description = "ID of the associated REST API"
+  type        = string
+}
+
+variable "rest_api_execution_arn" {
+  description = "Execution ARN of the associated REST API"
+  type        = string
+}
+
+variable "stage_name" {
+  description = "Name of the associated stage"
+  type        = string
+}
+
+variable "account_export_model_name" {
+  description = "Name of the account export model"
+  type        = string
+}
+
+variable "erasure_model_name" {
+  description = "Name of the erasure model"
+  type        = string
+}
+
+variable "error_model_name" {
+  description = "Name of the error model"
+  type        = string
+}
+
+variable "parent_id" {
+  description = "ID of the parent API resource"
+  type        = string
+}
+
+variable "custom_authorizer_id" {
+  description = "Custom authorizer id"
+  type        = string
+}
+
+variable "single_table_name" {
+  description = "Name of the single table"
+  type        = string
+}
+
+variable "single_table_arn" {
+  description = "ARN of the single table"
+  type        = string
+}
+
+variable "nanoid_alphabet" {
+  description = "Alphabet used for nanoid generation"
+  type        = string
+}
+
+variable "nanoid_length" {
+  description = "Length of the nanoid"
+  type        = number
+}
+
+variable "lambda_logging_policy_arn" {
+  description = "ARN of the Lambda logging policy"
+  type        = string
+}
+
+variable "event_bus_name" {
+  description = "Name of the event bus that poll deletion requests are sent to"
+  type        = string
+}
+
+variable "event_bus_arn" {
+  description = "ARN of the event bus that poll deletion requests are sent to"
+  type        = string
+}
+
+variable "poll_deletion_requested_source" {
+  description = "The source name of the poll deletion requested event"
+  type        = string
+}
+
+variable "poll_deletion_requested_detail_type" {
+  description = "The detail type of the poll deletion requested event"
+  type        = string
+}
+
+variable "vote_erasure_policy" {
+  description = "How an erased user's votes on other polls are handled"
+  type        = string
+}
@@ -0,0 +1,276 @@
+resource "aws_api_gateway_resource" "api_keys" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.parent_id
+  path_part   = "api-keys"
+}
+
+resource "aws_api_gateway_resource" "api_key" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.api_keys.id
+  path_part   = "{apiKeyId}"
+}
+
+resource "aws_api_gateway_request_validator" "create_api_key" {
+  name                  = "create-api-key-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "create_api_key" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.api_keys.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.create_api_key.id
+  request_models = {
+    "application/json" = var.create_api_key_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "create_api_key" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.api_keys.path_part}/${aws_api_gateway_method.create_api_key.http_method}"
+
+  # The response body holds the plaintext key, so it is kept out of the execution logs
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = false
+  }
+}
+
+resource "aws_api_gateway_integration" "create_api_key" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.api_keys.id
+  http_method             = aws_api_gateway_method.create_api_key.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_api_key_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "create_api_key_api_lambda" {
+  statement_id  = "PseudoPollAllowCreateApiKeyLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_api_key_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.create_api_key.http_method}${aws_api_gateway_resource.api_keys.path}"
+}
+
+module "create_api_key_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-create-api-key-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "create_api_key_logging" {
+  role       = module.create_api_key_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "create_api_key_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:PutItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "create_api_key_lambda_ddb" {
+  name        = "pseudopoll-create-api-key-lambda-ddb"
+  description = "IAM policy for create API key lambda to write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.create_api_key_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "create_api_key_lambda_ddb" {
+  role       = module.create_api_key_lambda_role.role_name
+  policy_arn = aws_iam_policy.create_api_key_lambda_ddb.arn
+}
+
+module "create_api_key_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-create-api-key"
+  role_arn            = module.create_api_key_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/create-api-key/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/create-api-key/bin/create-api-key.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_api_key_created" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_keys.id
+  http_method = aws_api_gateway_method.create_api_key.http_method
+  status_code = "201"
+
+  response_models = {
+    "application/json" = var.api_key_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_api_key_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_keys.id
+  http_method = aws_api_gateway_method.create_api_key.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_api_key_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_keys.id
+  http_method = aws_api_gateway_method.create_api_key.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "delete_api_key" {
+  rest_api_id = var.rest_api_id
+  http_method = "DELETE"
+  resource_id = aws_api_gateway_resource.api_key.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.apiKeyId" = true
+  }
+}
+
+resource "aws_api_gateway_method_settings" "delete_api_key" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.api_key.path_part}/${aws_api_gateway_method.delete_api_key.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "delete_api_key" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.api_key.id
+  http_method             = aws_api_gateway_method.delete_api_key.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.delete_api_key_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "delete_api_key_api_lambda" {
+  statement_id  = "PseudoPollAllowDeleteApiKeyLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.delete_api_key_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.delete_api_key.http_method}${aws_api_gateway_resource.api_key.path}"
+}
+
+module "delete_api_key_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-delete-api-key-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "delete_api_key_logging" {
+  role       = module.delete_api_key_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "delete_api_key_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = ["${var.single_table_arn}/index/GSI1"]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:DeleteItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "delete_api_key_lambda_ddb" {
+  name        = "pseudopoll-delete-api-key-lambda-ddb"
+  description = "IAM policy for delete API key lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.delete_api_key_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "delete_api_key_lambda_ddb" {
+  role       = module.delete_api_key_lambda_role.role_name
+  policy_arn = aws_iam_policy.delete_api_key_lambda_ddb.arn
+}
+
+module "delete_api_key_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-delete-api-key"
+  role_arn            = module.delete_api_key_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/delete-api-key/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/delete-api-key/bin/delete-api-key.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_api_key_no_content" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_key.id
+  http_method = aws_api_gateway_method.delete_api_key.http_method
+  status_code = "204"
+}
+
+resource "aws_api_gateway_method_response" "delete_api_key_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_key.id
+  http_method = aws_api_gateway_method.delete_api_key.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_api_key_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_key.id
+  http_method = aws_api_gateway_method.delete_api_key.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_api_key_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.api_key.id
+  http_method = aws_api_gateway_method.delete_api_key.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
@@ -0,0 +1,18 @@
+output "resources_hash" {
+  value = sha1(jsonencode([
+    aws_api_gateway_resource.api_keys,
+    aws_api_gateway_resource.api_key,
+    aws_api_gateway_request_validator.create_api_key,
+    aws_api_gateway_method.create_api_key,
+    aws_api_gateway_integration.create_api_key,
+    aws_api_gateway_method_response.create_api_key_created,
+    aws_api_gateway_method_response.create_api_key_bad_request,
+    aws_api_gateway_method_response.create_api_key_internal_server_error,
+    aws_api_gateway_method.delete_api_key,
+    aws_api_gateway_integration.delete_api_key,
+    aws_api_gateway_method_response.delete_api_key_no_content,
+    aws_api_gateway_method_response.delete_api_key_bad_request,
+    aws_api_gateway_method_response.delete_api_key_not_found,
+    aws_api_gateway_method_response.delete_api_key_internal_server_error,
+  ]))
+}
@@ -0,0 +1,254 @@
+resource "aws_api_gateway_resource" "chat" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.parent_id
+  path_part   = "chat"
+}
+
+resource "aws_api_gateway_resource" "chat_commands" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.chat.id
+  path_part   = "commands"
+}
+
+resource "aws_api_gateway_resource" "chat_interactions" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.chat.id
+  path_part   = "interactions"
+}
+
+# Requests from the chat workspace are authorized by verifying their signature in the lambda
+resource "aws_api_gateway_method" "chat_command" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.chat_commands.id
+
+  authorization = "NONE"
+}
+
+resource "aws_api_gateway_method_settings" "chat_command" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.chat.path_part}/${aws_api_gateway_resource.chat_commands.path_part}/${aws_api_gateway_method.chat_command.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "chat_command" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.chat_commands.id
+  http_method             = aws_api_gateway_method.chat_command.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.chat_integration_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "chat_command_api_lambda" {
+  statement_id  = "PseudoPollAllowChatIntegrationLambdaExecutionFromChatCommandApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.chat_integration_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.chat_command.http_method}${aws_api_gateway_resource.chat_commands.path}"
+}
+
+resource "aws_api_gateway_method_response" "chat_command_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.chat_commands.id
+  http_method = aws_api_gateway_method.chat_command.http_method
+  status_code = "200"
+}
+
+resource "aws_api_gateway_method_response" "chat_command_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.chat_commands.id
+  http_method = aws_api_gateway_method.chat_command.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+# Requests from the chat workspace are authorized by verifying their signature in the lambda
+resource "aws_api_gateway_method" "chat_interaction" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.chat_interactions.id
+
+  authorization = "NONE"
+}
+
+resource "aws_api_gateway_method_settings" "chat_interaction" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.chat.path_part}/${aws_api_gateway_resource.chat_interactions.path_part}/${aws_api_gateway_method.chat_interaction.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "chat_interaction" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.chat_interactions.id
+  http_method             = aws_api_gateway_method.chat_interaction.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.chat_integration_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "chat_interaction_api_lambda" {
+  statement_id  = "PseudoPollAllowChatIntegrationLambdaExecutionFromChatInteractionApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.chat_integration_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.chat_interaction.http_method}${aws_api_gateway_resource.chat_interactions.path}"
+}
+
+resource "aws_api_gateway_method_response" "chat_interaction_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.chat_interactions.id
+  http_method = aws_api_gateway_method.chat_interaction.http_method
+  status_code = "200"
+}
+
+resource "aws_api_gateway_method_response" "chat_interaction_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.chat_interactions.id
+  http_method = aws_api_gateway_method.chat_interaction.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+module "chat_integration_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-chat-integration-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "chat_integration_logging" {
+  role       = module.chat_integration_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "chat_integration_lambda_ddb_sqs" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:TransactWriteItems",
+      "dynamodb:PutItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = ["sqs:SendMessage"]
+
+    resources = [var.vote_queue_arn]
+  }
+}
+
+resource "aws_iam_policy" "chat_integration_lambda_ddb_sqs" {
+  name        = "pseudopoll-chat-integration-lambda-ddb-sqs"
+  description = "IAM policy for chat integration lambda to create polls in DynamoDB and send votes to the vote queue"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.chat_integration_lambda_ddb_sqs.json
+}
+
+resource "aws_iam_role_policy_attachment" "chat_integration_lambda_ddb_sqs" {
+  role       = module.chat_integration_lambda_role.role_name
+  policy_arn = aws_iam_policy.chat_integration_lambda_ddb_sqs.arn
+}
+
+module "chat_integration_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-chat-integration"
+  role_arn            = module.chat_integration_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/chat-integration/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/chat-integration/bin/chat-integration.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME        = var.single_table_name
+    NANOID_ALPHABET          = var.nanoid_alphabet
+    NANOID_LENGTH            = "${var.nanoid_length}"
+    VOTE_QUEUE_URL           = var.vote_queue_url
+    CHAT_SIGNING_SECRET      = var.chat_signing_secret
+    CHAT_BOT_TOKEN           = var.chat_bot_token
+    CHAT_POLL_DURATION       = "${var.chat_poll_duration}"
+    CHAT_POLL_RETENTION_DAYS = "${var.chat_poll_retention_days}"
+    PROMPT_MIN_LENGTH        = "${var.prompt_min_length}"
+    PROMPT_MAX_LENGTH        = "${var.prompt_max_length}"
+    OPTION_MIN_LENGTH        = "${var.option_min_length}"
+    OPTION_MAX_LENGTH        = "${var.option_max_length}"
+    MIN_OPTIONS              = "${var.min_options}"
+    MAX_OPTIONS              = "${var.max_options}"
+    MIN_DURATION             = "${var.min_duration}"
+    MAX_DURATION             = "${var.max_duration}"
+  }
+}
+
+module "chat_message_updater_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-chat-message-updater-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "chat_message_updater_logging" {
+  role       = module.chat_message_updater_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "chat_message_updater_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1"
+    ]
+  }
+}
+
+resource "aws_iam_policy" "chat_message_updater_lambda_ddb" {
+  name        = "pseudopoll-chat-message-updater-lambda-ddb"
+  description = "IAM policy for chat message updater lambda to read polls and their chat messages from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.chat_message_updater_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "chat_message_updater_lambda_ddb" {
+  role       = module.chat_message_updater_lambda_role.role_name
+  policy_arn = aws_iam_policy.chat_message_updater_lambda_ddb.arn
+}
+
+module "chat_message_updater_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-chat-message-updater"
+  role_arn            = module.chat_message_updater_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/chat-message-updater/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/chat-message-updater/bin/chat-message-updater.zip"
+
+  environment_variables = {
+    DDB_STREAM_SOURCE      = var.ddb_stream_pipe_event_source
+    DDB_STREAM_DETAIL_TYPE = var.ddb_stream_pipe_event_detail_type
+    SINGLE_TABLE_NAME      = var.single_table_name
+    CHAT_BOT_TOKEN         = var.chat_bot_token
+  }
+}
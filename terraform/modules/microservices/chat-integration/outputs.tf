@@ -0,0 +1,23 @@
+output "resources_hash" {
+  value = sha1(jsonencode([
+    aws_api_gateway_resource.chat,
+    aws_api_gateway_resource.chat_commands,
+    aws_api_gateway_resource.chat_interactions,
+    aws_api_gateway_method.chat_command,
+    aws_api_gateway_integration.chat_command,
+    aws_api_gateway_method_response.chat_command_ok,
+    aws_api_gateway_method_response.chat_command_unauthorized,
+    aws_api_gateway_method.chat_interaction,
+    aws_api_gateway_integration.chat_interaction,
+    aws_api_gateway_method_response.chat_interaction_ok,
+    aws_api_gateway_method_response.chat_interaction_unauthorized,
+  ]))
+}
+
+output "chat_message_updater_lambda_function_name" {
+  value = module.chat_message_updater_lambda.function_name
+}
+
+output "chat_message_updater_lambda_arn" {
+  value = module.chat_message_updater_lambda.arn
+}
@@ -0,0 +1,131 @@
+variable "rest_api_id" {
+  description = "ID of the associated REST API"
+  type        = string
+}
+
+variable "rest_api_execution_arn" {
+  description = "Execution ARN of the associated REST API"
+  type        = string
+}
+
+variable "stage_name" {
+  description = "Name of the associated stage"
+  type        = string
+}
+
+variable "error_model_name" {
+  description = "Name of the error model"
+  type        = string
+}
+
+variable "parent_id" {
+  description = "ID of the parent API resource"
+  type        = string
+}
+
+variable "single_table_name" {
+  description = "Name of the single table"
+  type        = string
+}
+
+variable "single_table_arn" {
+  description = "ARN of the single table"
+  type        = string
+}
+
+variable "nanoid_alphabet" {
+  description = "Alphabet used for nanoid generation"
+  type        = string
+}
+
+variable "nanoid_length" {
+  description = "Length of the nanoid"
+  type        = number
+}
+
+variable "lambda_logging_policy_arn" {
+  description = "ARN of the Lambda logging policy"
+  type        = string
+}
+
+variable "vote_queue_url" {
+  description = "URL of the vote queue"
+  type        = string
+}
+
+variable "vote_queue_arn" {
+  description = "ARN of the vote queue"
+  type        = string
+}
+
+variable "chat_signing_secret" {
+  description = "Secret that the chat workspace signs its requests with"
+  type        = string
+  sensitive   = true
+}
+
+variable "chat_bot_token" {
+  description = "Bot token used to post and update poll messages in the chat workspace"
+  type        = string
+  sensitive   = true
+}
+
+variable "chat_poll_duration" {
+  description = "Duration in seconds of the polls created from chat"
+  type        = number
+}
+
+variable "chat_poll_retention_days" {
+  description = "Days the polls created from chat are kept after they close"
+  type        = number
+}
+
+variable "prompt_min_length" {
+  description = "Minimum length of the prompt"
+  type        = number
+}
+
+variable "prompt_max_length" {
+  description = "Maximum length of the prompt"
+  type        = number
+}
+
+variable "option_min_length" {
+  description = "Minimum length of the option"
+  type        = number
+}
+
+variable "option_max_length" {
+  description = "Maximum length of the option"
+  type        = number
+}
+
+variable "min_options" {
+  description = "Minimum number of options"
+  type        = number
+}
+
+variable "max_options" {
+  description = "Maximum number of options"
+  type        = number
+}
+
+variable "min_duration" {
+  description = "Minimum duration of the poll"
+  type        = number
+}
+
+variable "max_duration" {
+  description = "Maximum duration of the poll"
+  type        = number
+}
+
+variable "ddb_stream_pipe_event_source" {
+  description = "The source name of the DynamoDB stream pipe"
+  type        = string
+}
+
+variable "ddb_stream_pipe_event_detail_type" {
+  description = "The detail type of the DynamoDB stream pipe"
+  type        = string
+}
@@ -0,0 +1,25 @@
+data "aws_iam_policy_document" "api_poll_snapshot_requested" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "events:PutEvents",
+    ]
+
+    resources = [
+      var.event_bus_arn,
+    ]
+  }
+}
+
+resource "aws_iam_policy" "api_poll_snapshot_requested" {
+  name        = "pseudopoll-api-poll-snapshot-requested"
+  path        = "/"
+  description = "IAM policy for API Gateway to request poll snapshots on the event bus"
+  policy      = data.aws_iam_policy_document.api_poll_snapshot_requested.json
+}
+
+resource "aws_iam_role_policy_attachment" "api_poll_snapshot_requested" {
+  role       = var.api_role_name
+  policy_arn = aws_iam_policy.api_poll_snapshot_requested.arn
+}
@@ -0,0 +1,9 @@
+variable "event_bus_arn" {
+  description = "ARN of the event bus"
+  type        = string
+}
+
+variable "api_role_name" {
+  description = "Name of the API role"
+  type        = string
+}
@@ -10,6 +10,12 @@ resource "aws_api_gateway_resource" "poll" {
   path_part   = "{pollId}"
 }
 
+resource "aws_api_gateway_resource" "option_images" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.parent_id
+  path_part   = "option-images"
+}
+
 resource "aws_api_gateway_resource" "public" {
   rest_api_id = var.rest_api_id
   parent_id   = var.parent_id
@@ -40,6 +46,72 @@ resource "aws_api_gateway_resource" "duration" {
   path_part   = "duration"
 }
 
+resource "aws_api_gateway_resource" "subscription_token" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "subscription-token"
+}
+
+resource "aws_api_gateway_resource" "public_subscription_token" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.public_poll.id
+  path_part   = "subscription-token"
+}
+
+resource "aws_api_gateway_resource" "poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "snapshot"
+}
+
+resource "aws_api_gateway_resource" "public_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.public_poll.id
+  path_part   = "snapshot"
+}
+
+resource "aws_api_gateway_resource" "clone" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "clone"
+}
+
+resource "aws_api_gateway_resource" "timeline" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "timeline"
+}
+
+resource "aws_api_gateway_resource" "write_ins" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "write-ins"
+}
+
+resource "aws_api_gateway_resource" "write_in" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.write_ins.id
+  path_part   = "{optionId}"
+}
+
+resource "aws_api_gateway_resource" "tie_break" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "tie-break"
+}
+
+resource "aws_api_gateway_resource" "members" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.poll.id
+  path_part   = "members"
+}
+
+resource "aws_api_gateway_resource" "member" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.members.id
+  path_part   = "{userId}"
+}
+
 resource "aws_api_gateway_request_validator" "create_poll" {
   name                  = "create-poll-validator"
   rest_api_id           = var.rest_api_id
@@ -105,17 +177,35 @@ data "aws_iam_policy_document" "create_poll_lambda_ddb" {
     effect = "Allow"
 
     actions = [
+      "dynamodb:GetItem",
       "dynamodb:TransactWriteItems",
       "dynamodb:PutItem"
     ]
 
     resources = [var.single_table_arn]
   }
+
+  statement {
+    effect = "Allow"
+
+    actions = ["s3:GetObject"]
+
+    resources = ["${aws_s3_bucket.option_images.arn}/options/*"]
+  }
+
+  # Without it, S3 reports an image that hasn't been uploaded as forbidden rather than not found
+  statement {
+    effect = "Allow"
+
+    actions = ["s3:ListBucket"]
+
+    resources = [aws_s3_bucket.option_images.arn]
+  }
 }
 
 resource "aws_iam_policy" "create_poll_lambda_ddb" {
   name        = "pseudopoll-create-poll-lambda-ddb"
-  description = "IAM policy for create poll lambda to write to DynamoDB"
+  description = "IAM policy for create poll lambda to read templates from and write to DynamoDB, and check option images in S3"
   path        = "/"
   policy      = data.aws_iam_policy_document.create_poll_lambda_ddb.json
 }
@@ -133,9 +223,16 @@ module "create_poll_lambda" {
   archive_output_path = "${path.module}/../../../../backend/lambdas/create-poll/bin/create-poll.zip"
 
   environment_variables = {
-    SINGLE_TABLE_NAME = var.single_table_name
-    NANOID_ALPHABET   = var.nanoid_alphabet
-    NANOID_LENGTH     = "${var.nanoid_length}"
+    SINGLE_TABLE_NAME         = var.single_table_name
+    NANOID_ALPHABET           = var.nanoid_alphabet
+    NANOID_LENGTH             = "${var.nanoid_length}"
+    OPTION_IMAGES_BUCKET_NAME = aws_s3_bucket.option_images.id
+    OPTION_IMAGES_URL         = "https://${aws_s3_bucket.option_images.bucket_regional_domain_name}"
+    DESCRIPTION_MAX_LENGTH    = "${var.option_description_max_length}"
+    URL_MAX_LENGTH            = "${var.option_url_max_length}"
+    IMAGE_MAX_SIZE            = "${var.option_image_max_size}"
+    MAX_VOTE_WEIGHT           = "${var.max_vote_weight}"
+    MAX_VOTE_WEIGHTS          = "${var.max_vote_weights}"
   }
 }
 
@@ -161,6 +258,17 @@ resource "aws_api_gateway_method_response" "create_poll_bad_request" {
   }
 }
 
+resource "aws_api_gateway_method_response" "create_poll_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.polls.id
+  http_method = aws_api_gateway_method.create_poll.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
 resource "aws_api_gateway_method_response" "create_poll_internal_server_error" {
   rest_api_id = var.rest_api_id
   resource_id = aws_api_gateway_resource.polls.id
@@ -172,31 +280,31 @@ resource "aws_api_gateway_method_response" "create_poll_internal_server_error" {
   }
 }
 
-resource "aws_api_gateway_request_validator" "archive_poll" {
-  name                  = "archive-poll-validator"
+resource "aws_api_gateway_request_validator" "edit_poll" {
+  name                  = "edit-poll-validator"
   rest_api_id           = var.rest_api_id
   validate_request_body = true
 }
 
-resource "aws_api_gateway_method" "archive_poll" {
+resource "aws_api_gateway_method" "edit_poll" {
   rest_api_id = var.rest_api_id
   http_method = "PATCH"
-  resource_id = aws_api_gateway_resource.archive.id
+  resource_id = aws_api_gateway_resource.poll.id
 
   authorization = "CUSTOM"
   authorizer_id = var.custom_authorizer_id
 
-  request_validator_id = aws_api_gateway_request_validator.archive_poll.id
+  request_validator_id = aws_api_gateway_request_validator.edit_poll.id
 
   request_models = {
-    "application/json" = var.archive_poll_model_name
+    "application/json" = var.edit_poll_model_name
   }
 }
 
-resource "aws_api_gateway_method_settings" "archive_poll" {
+resource "aws_api_gateway_method_settings" "edit_poll" {
   rest_api_id = var.rest_api_id
   stage_name  = var.stage_name
-  method_path = "${aws_api_gateway_resource.archive.path_part}/${aws_api_gateway_method.archive_poll.http_method}"
+  method_path = "${aws_api_gateway_resource.poll.path_part}/${aws_api_gateway_method.edit_poll.http_method}"
 
   settings {
     logging_level      = "INFO"
@@ -205,77 +313,100 @@ resource "aws_api_gateway_method_settings" "archive_poll" {
   }
 }
 
-resource "aws_api_gateway_integration" "archive_poll" {
+resource "aws_api_gateway_integration" "edit_poll" {
   rest_api_id             = var.rest_api_id
-  resource_id             = aws_api_gateway_resource.archive.id
-  http_method             = aws_api_gateway_method.archive_poll.http_method
+  resource_id             = aws_api_gateway_resource.poll.id
+  http_method             = aws_api_gateway_method.edit_poll.http_method
   integration_http_method = "POST"
   type                    = "AWS_PROXY"
-  uri                     = module.archive_poll_lambda.invoke_arn
+  uri                     = module.edit_poll_lambda.invoke_arn
 }
 
-resource "aws_lambda_permission" "archive_poll_api_lambda" {
-  statement_id  = "PseudoPollAllowArchivePollLambdaExecutionFromApiGateway"
+resource "aws_lambda_permission" "edit_poll_api_lambda" {
+  statement_id  = "PseudoPollAllowEditPollLambdaExecutionFromApiGateway"
   action        = "lambda:InvokeFunction"
-  function_name = module.archive_poll_lambda.function_name
+  function_name = module.edit_poll_lambda.function_name
   principal     = "apigateway.amazonaws.com"
 
-  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.archive_poll.http_method}${aws_api_gateway_resource.archive.path}"
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.edit_poll.http_method}${aws_api_gateway_resource.poll.path}"
 }
 
-module "archive_poll_lambda_role" {
+module "edit_poll_lambda_role" {
   source    = "../../lambda/iam"
-  role_name = "pseudopoll-archive-poll-lambda-role"
+  role_name = "pseudopoll-edit-poll-lambda-role"
 }
 
-resource "aws_iam_role_policy_attachment" "archive_poll_logging" {
-  role       = module.archive_poll_lambda_role.role_name
+resource "aws_iam_role_policy_attachment" "edit_poll_logging" {
+  role       = module.edit_poll_lambda_role.role_name
   policy_arn = var.lambda_logging_policy_arn
 }
 
-data "aws_iam_policy_document" "archive_poll_lambda_ddb" {
+data "aws_iam_policy_document" "edit_poll_lambda_ddb" {
   statement {
     effect = "Allow"
 
-    actions = ["dynamodb:UpdateItem"]
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+      "dynamodb:UpdateItem",
+      "dynamodb:TransactWriteItems",
+      "dynamodb:BatchWriteItem",
+    ]
 
-    resources = [var.single_table_arn]
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
   }
 }
 
-resource "aws_iam_policy" "archive_poll_lambda_ddb" {
-  name        = "pseudopoll-archive-poll-lambda-ddb"
-  description = "IAM policy for archive poll lambda to write to DynamoDB"
+resource "aws_iam_policy" "edit_poll_lambda_ddb" {
+  name        = "pseudopoll-edit-poll-lambda-ddb"
+  description = "IAM policy for edit poll lambda to read and write to DynamoDB"
   path        = "/"
-  policy      = data.aws_iam_policy_document.archive_poll_lambda_ddb.json
+  policy      = data.aws_iam_policy_document.edit_poll_lambda_ddb.json
 }
 
-resource "aws_iam_role_policy_attachment" "archive_poll_lambda_ddb" {
-  role       = module.archive_poll_lambda_role.role_name
-  policy_arn = aws_iam_policy.archive_poll_lambda_ddb.arn
+resource "aws_iam_role_policy_attachment" "edit_poll_lambda_ddb" {
+  role       = module.edit_poll_lambda_role.role_name
+  policy_arn = aws_iam_policy.edit_poll_lambda_ddb.arn
 }
 
-module "archive_poll_lambda" {
+module "edit_poll_lambda" {
   source              = "../../lambda"
-  function_name       = "pseudopoll-archive-poll"
-  role_arn            = module.archive_poll_lambda_role.role_arn
-  archive_source_file = "${path.module}/../../../../backend/lambdas/archive-poll/bin/bootstrap"
-  archive_output_path = "${path.module}/../../../../backend/lambdas/archive-poll/bin/archive-poll.zip"
+  function_name       = "pseudopoll-edit-poll"
+  role_arn            = module.edit_poll_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/edit-poll/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/edit-poll/bin/edit-poll.zip"
 
-  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+    PROMPT_MIN_LENGTH = "${var.prompt_min_length}"
+    PROMPT_MAX_LENGTH = "${var.prompt_max_length}"
+    OPTION_MIN_LENGTH = "${var.option_min_length}"
+    OPTION_MAX_LENGTH = "${var.option_max_length}"
+    MIN_OPTIONS       = "${var.min_options}"
+    MAX_OPTIONS       = "${var.max_options}"
+  }
 }
 
-resource "aws_api_gateway_method_response" "archive_poll_ok" {
+resource "aws_api_gateway_method_response" "edit_poll_ok" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.archive.id
-  http_method = aws_api_gateway_method.archive_poll.http_method
-  status_code = "204"
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.edit_poll.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.poll_model_name
+  }
 }
 
-resource "aws_api_gateway_method_response" "archive_poll_bad_request" {
+resource "aws_api_gateway_method_response" "edit_poll_bad_request" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.archive.id
-  http_method = aws_api_gateway_method.archive_poll.http_method
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.edit_poll.http_method
   status_code = "400"
 
   response_models = {
@@ -283,10 +414,43 @@ resource "aws_api_gateway_method_response" "archive_poll_bad_request" {
   }
 }
 
-resource "aws_api_gateway_method_response" "archive_poll_internal_server_error" {
+resource "aws_api_gateway_method_response" "edit_poll_forbidden" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.archive.id
-  http_method = aws_api_gateway_method.archive_poll.http_method
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.edit_poll.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "edit_poll_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.edit_poll.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "edit_poll_conflict" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.edit_poll.http_method
+  status_code = "409"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "edit_poll_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.edit_poll.http_method
   status_code = "500"
 
   response_models = {
@@ -294,19 +458,19 @@ resource "aws_api_gateway_method_response" "archive_poll_internal_server_error"
   }
 }
 
-resource "aws_api_gateway_method" "get_poll" {
+resource "aws_api_gateway_method" "delete_poll" {
   rest_api_id = var.rest_api_id
-  http_method = "GET"
+  http_method = "DELETE"
   resource_id = aws_api_gateway_resource.poll.id
 
   authorization = "CUSTOM"
   authorizer_id = var.custom_authorizer_id
 }
 
-resource "aws_api_gateway_method_settings" "get_poll" {
+resource "aws_api_gateway_method_settings" "delete_poll" {
   rest_api_id = var.rest_api_id
   stage_name  = var.stage_name
-  method_path = "${aws_api_gateway_resource.poll.path_part}/${aws_api_gateway_method.get_poll.http_method}"
+  method_path = "${aws_api_gateway_resource.poll.path_part}/${aws_api_gateway_method.delete_poll.http_method}"
 
   settings {
     logging_level      = "INFO"
@@ -315,87 +479,93 @@ resource "aws_api_gateway_method_settings" "get_poll" {
   }
 }
 
-resource "aws_api_gateway_integration" "get_poll" {
+resource "aws_api_gateway_integration" "delete_poll" {
   rest_api_id             = var.rest_api_id
   resource_id             = aws_api_gateway_resource.poll.id
-  http_method             = aws_api_gateway_method.get_poll.http_method
+  http_method             = aws_api_gateway_method.delete_poll.http_method
   integration_http_method = "POST"
   type                    = "AWS_PROXY"
-  uri                     = module.get_poll_lambda.invoke_arn
+  uri                     = module.delete_poll_lambda.invoke_arn
 }
 
-resource "aws_lambda_permission" "get_poll_api_lambda" {
-  statement_id  = "PseudoPollAllowGetPollLambdaExecutionFromApiGateway"
+resource "aws_lambda_permission" "delete_poll_api_lambda" {
+  statement_id  = "PseudoPollAllowDeletePollLambdaExecutionFromApiGateway"
   action        = "lambda:InvokeFunction"
-  function_name = module.get_poll_lambda.function_name
+  function_name = module.delete_poll_lambda.function_name
   principal     = "apigateway.amazonaws.com"
 
-  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.get_poll.http_method}${aws_api_gateway_resource.poll.path}"
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.delete_poll.http_method}${aws_api_gateway_resource.poll.path}"
 }
 
-module "get_poll_lambda_role" {
+module "delete_poll_lambda_role" {
   source    = "../../lambda/iam"
-  role_name = "pseudopoll-get-poll-lambda-role"
+  role_name = "pseudopoll-delete-poll-lambda-role"
 }
 
-resource "aws_iam_role_policy_attachment" "get_poll_logging" {
-  role       = module.get_poll_lambda_role.role_name
+resource "aws_iam_role_policy_attachment" "delete_poll_logging" {
+  role       = module.delete_poll_lambda_role.role_name
   policy_arn = var.lambda_logging_policy_arn
 }
 
-data "aws_iam_policy_document" "get_poll_lambda_ddb" {
+data "aws_iam_policy_document" "delete_poll_lambda_ddb_events" {
   statement {
     effect = "Allow"
 
     actions = [
       "dynamodb:GetItem",
-      "dynamodb:Query",
+      "dynamodb:UpdateItem"
     ]
 
-    resources = [
-      var.single_table_arn,
-      "${var.single_table_arn}/index/GSI1"
-    ]
+    resources = [var.single_table_arn]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = ["events:PutEvents"]
+
+    resources = [var.event_bus_arn]
   }
 }
 
-resource "aws_iam_policy" "get_poll_lambda_ddb" {
-  name        = "pseudopoll-get-poll-lambda-ddb"
-  description = "IAM policy for get poll lambda to read from DynamoDB"
+resource "aws_iam_policy" "delete_poll_lambda_ddb_events" {
+  name        = "pseudopoll-delete-poll-lambda-ddb-events"
+  description = "IAM policy for delete poll lambda to write to DynamoDB and send events to the event bus"
   path        = "/"
-  policy      = data.aws_iam_policy_document.get_poll_lambda_ddb.json
+  policy      = data.aws_iam_policy_document.delete_poll_lambda_ddb_events.json
 }
 
-resource "aws_iam_role_policy_attachment" "get_poll_lambda_ddb" {
-  role       = module.get_poll_lambda_role.role_name
-  policy_arn = aws_iam_policy.get_poll_lambda_ddb.arn
+resource "aws_iam_role_policy_attachment" "delete_poll_lambda_ddb_events" {
+  role       = module.delete_poll_lambda_role.role_name
+  policy_arn = aws_iam_policy.delete_poll_lambda_ddb_events.arn
 }
 
-module "get_poll_lambda" {
+module "delete_poll_lambda" {
   source              = "../../lambda"
-  function_name       = "pseudopoll-get-poll"
-  role_arn            = module.get_poll_lambda_role.role_arn
-  archive_source_file = "${path.module}/../../../../backend/lambdas/get-poll/bin/bootstrap"
-  archive_output_path = "${path.module}/../../../../backend/lambdas/get-poll/bin/get-poll.zip"
+  function_name       = "pseudopoll-delete-poll"
+  role_arn            = module.delete_poll_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/delete-poll/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/delete-poll/bin/delete-poll.zip"
 
-  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+  environment_variables = {
+    SINGLE_TABLE_NAME              = var.single_table_name
+    EVENT_BUS_NAME                 = var.event_bus_name
+    DELETION_REQUESTED_SOURCE      = var.poll_deletion_requested_source
+    DELETION_REQUESTED_DETAIL_TYPE = var.poll_deletion_requested_detail_type
+  }
 }
 
-resource "aws_api_gateway_method_response" "get_poll_ok" {
+resource "aws_api_gateway_method_response" "delete_poll_accepted" {
   rest_api_id = var.rest_api_id
   resource_id = aws_api_gateway_resource.poll.id
-  http_method = aws_api_gateway_method.get_poll.http_method
-  status_code = "200"
-
-  response_models = {
-    "application/json" = var.poll_model_name
-  }
+  http_method = aws_api_gateway_method.delete_poll.http_method
+  status_code = "202"
 }
 
-resource "aws_api_gateway_method_response" "get_poll_forbidden" {
+resource "aws_api_gateway_method_response" "delete_poll_forbidden" {
   rest_api_id = var.rest_api_id
   resource_id = aws_api_gateway_resource.poll.id
-  http_method = aws_api_gateway_method.get_poll.http_method
+  http_method = aws_api_gateway_method.delete_poll.http_method
   status_code = "403"
 
   response_models = {
@@ -403,128 +573,2206 @@ resource "aws_api_gateway_method_response" "get_poll_forbidden" {
   }
 }
 
-resource "aws_api_gateway_method_response" "get_poll_internal_server_error" {
+resource "aws_api_gateway_method_response" "delete_poll_not_found" {
   rest_api_id = var.rest_api_id
   resource_id = aws_api_gateway_resource.poll.id
-  http_method = aws_api_gateway_method.get_poll.http_method
-  status_code = "500"
+  http_method = aws_api_gateway_method.delete_poll.http_method
+  status_code = "404"
 
   response_models = {
     "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_request_validator" "update_poll_duration" {
-  name                  = "update-poll-duration-validator"
-  rest_api_id           = var.rest_api_id
-  validate_request_body = true
-}
-
-resource "aws_api_gateway_method" "update_poll_duration" {
+resource "aws_api_gateway_method_response" "delete_poll_gone" {
   rest_api_id = var.rest_api_id
-  http_method = "PATCH"
-  resource_id = aws_api_gateway_resource.duration.id
-
-  authorization = "CUSTOM"
-  authorizer_id = var.custom_authorizer_id
-
-  request_validator_id = aws_api_gateway_request_validator.update_poll_duration.id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.delete_poll.http_method
+  status_code = "410"
 
-  request_models = {
-    "application/json" = var.update_poll_duration_model_name
+  response_models = {
+    "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_method_settings" "update_poll_duration" {
+resource "aws_api_gateway_method_response" "delete_poll_internal_server_error" {
   rest_api_id = var.rest_api_id
-  stage_name  = var.stage_name
-  method_path = "${aws_api_gateway_resource.duration.path_part}/${aws_api_gateway_method.update_poll_duration.http_method}"
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.delete_poll.http_method
+  status_code = "500"
 
-  settings {
-    logging_level      = "INFO"
-    metrics_enabled    = true
-    data_trace_enabled = true
+  response_models = {
+    "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_integration" "update_poll_duration" {
-  rest_api_id             = var.rest_api_id
-  resource_id             = aws_api_gateway_resource.duration.id
-  http_method             = aws_api_gateway_method.update_poll_duration.http_method
-  integration_http_method = "POST"
-  type                    = "AWS_PROXY"
+module "poll_deleter_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-poll-deleter-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "poll_deleter_logging" {
+  role       = module.poll_deleter_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "poll_deleter_lambda_ddb_events" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem",
+      "dynamodb:Query",
+      "dynamodb:BatchWriteItem"
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = ["events:PutEvents"]
+
+    resources = [var.event_bus_arn]
+  }
+}
+
+resource "aws_iam_policy" "poll_deleter_lambda_ddb_events" {
+  name        = "pseudopoll-poll-deleter-lambda-ddb-events"
+  description = "IAM policy for poll deleter lambda to delete from DynamoDB and send events to the event bus"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.poll_deleter_lambda_ddb_events.json
+}
+
+resource "aws_iam_role_policy_attachment" "poll_deleter_lambda_ddb_events" {
+  role       = module.poll_deleter_lambda_role.role_name
+  policy_arn = aws_iam_policy.poll_deleter_lambda_ddb_events.arn
+}
+
+module "poll_deleter_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-poll-deleter"
+  role_arn            = module.poll_deleter_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/poll-deleter/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/poll-deleter/bin/poll-deleter.zip"
+  timeout             = 60
+
+  environment_variables = {
+    SINGLE_TABLE_NAME              = var.single_table_name
+    EVENT_BUS_NAME                 = var.event_bus_name
+    DELETION_REQUESTED_SOURCE      = var.poll_deletion_requested_source
+    DELETION_REQUESTED_DETAIL_TYPE = var.poll_deletion_requested_detail_type
+    DDB_STREAM_SOURCE              = var.ddb_stream_pipe_event_source
+    DDB_STREAM_DETAIL_TYPE         = var.ddb_stream_pipe_event_detail_type
+  }
+}
+
+module "table_backfill_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-table-backfill-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "table_backfill_logging" {
+  role       = module.table_backfill_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "table_backfill_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:Scan",
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem",
+      "dynamodb:DeleteItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "table_backfill_lambda_ddb" {
+  name        = "pseudopoll-table-backfill-lambda-ddb"
+  description = "IAM policy for table backfill lambda to scan, update and delete items in DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.table_backfill_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "table_backfill_lambda_ddb" {
+  role       = module.table_backfill_lambda_role.role_name
+  policy_arn = aws_iam_policy.table_backfill_lambda_ddb.arn
+}
+
+# Invoked by hand after a change to how items are written, and again with the exclusiveStartKey it returns
+# until it returns none
+module "table_backfill_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-table-backfill"
+  role_arn            = module.table_backfill_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/table-backfill/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/table-backfill/bin/table-backfill.zip"
+  timeout             = 900
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "archive_poll" {
+  name                  = "archive-poll-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "archive_poll" {
+  rest_api_id = var.rest_api_id
+  http_method = "PATCH"
+  resource_id = aws_api_gateway_resource.archive.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.archive_poll.id
+
+  request_models = {
+    "application/json" = var.archive_poll_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "archive_poll" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.archive.path_part}/${aws_api_gateway_method.archive_poll.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "archive_poll" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.archive.id
+  http_method             = aws_api_gateway_method.archive_poll.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.archive_poll_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "archive_poll_api_lambda" {
+  statement_id  = "PseudoPollAllowArchivePollLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.archive_poll_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.archive_poll.http_method}${aws_api_gateway_resource.archive.path}"
+}
+
+module "archive_poll_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-archive-poll-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "archive_poll_logging" {
+  role       = module.archive_poll_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "archive_poll_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "archive_poll_lambda_ddb" {
+  name        = "pseudopoll-archive-poll-lambda-ddb"
+  description = "IAM policy for archive poll lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.archive_poll_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "archive_poll_lambda_ddb" {
+  role       = module.archive_poll_lambda_role.role_name
+  policy_arn = aws_iam_policy.archive_poll_lambda_ddb.arn
+}
+
+module "archive_poll_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-archive-poll"
+  role_arn            = module.archive_poll_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/archive-poll/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/archive-poll/bin/archive-poll.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME            = var.single_table_name
+    ARCHIVED_POLL_RETENTION_DAYS = "${var.archived_poll_retention_days}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "archive_poll_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.archive.id
+  http_method = aws_api_gateway_method.archive_poll.http_method
+  status_code = "204"
+}
+
+resource "aws_api_gateway_method_response" "archive_poll_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.archive.id
+  http_method = aws_api_gateway_method.archive_poll.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "archive_poll_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.archive.id
+  http_method = aws_api_gateway_method.archive_poll.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "archive_poll_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.archive.id
+  http_method = aws_api_gateway_method.archive_poll.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "archive_poll_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.archive.id
+  http_method = aws_api_gateway_method.archive_poll.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "get_poll" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.poll.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "get_poll" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.poll.path_part}/${aws_api_gateway_method.get_poll.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "get_poll" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.poll.id
+  http_method             = aws_api_gateway_method.get_poll.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.get_poll_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "get_poll_api_lambda" {
+  statement_id  = "PseudoPollAllowGetPollLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.get_poll_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.get_poll.http_method}${aws_api_gateway_resource.poll.path}"
+}
+
+module "get_poll_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-get-poll-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "get_poll_logging" {
+  role       = module.get_poll_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "get_poll_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1"
+    ]
+  }
+}
+
+resource "aws_iam_policy" "get_poll_lambda_ddb" {
+  name        = "pseudopoll-get-poll-lambda-ddb"
+  description = "IAM policy for get poll lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.get_poll_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "get_poll_lambda_ddb" {
+  role       = module.get_poll_lambda_role.role_name
+  policy_arn = aws_iam_policy.get_poll_lambda_ddb.arn
+}
+
+module "get_poll_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-get-poll"
+  role_arn            = module.get_poll_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/get-poll/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/get-poll/bin/get-poll.zip"
+
+  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.get_poll.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.poll_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.get_poll.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.get_poll.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll.id
+  http_method = aws_api_gateway_method.get_poll.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "update_poll_duration" {
+  name                  = "update-poll-duration-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "update_poll_duration" {
+  rest_api_id = var.rest_api_id
+  http_method = "PATCH"
+  resource_id = aws_api_gateway_resource.duration.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.update_poll_duration.id
+
+  request_models = {
+    "application/json" = var.update_poll_duration_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "update_poll_duration" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.duration.path_part}/${aws_api_gateway_method.update_poll_duration.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "update_poll_duration" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.duration.id
+  http_method             = aws_api_gateway_method.update_poll_duration.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
   uri                     = module.update_poll_duration_lambda.invoke_arn
 }
 
-resource "aws_lambda_permission" "update_poll_duration_api_lambda" {
-  statement_id  = "PseudoPollAllowUpdatePollDurationLambdaExecutionFromApiGateway"
+resource "aws_lambda_permission" "update_poll_duration_api_lambda" {
+  statement_id  = "PseudoPollAllowUpdatePollDurationLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.update_poll_duration_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.update_poll_duration.http_method}${aws_api_gateway_resource.duration.path}"
+}
+
+module "update_poll_duration_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-update-poll-duration-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "update_poll_duration_logging" {
+  role       = module.update_poll_duration_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "update_poll_duration_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "update_poll_duration_lambda_ddb" {
+  name        = "pseudopoll-update-poll-duration-lambda-ddb"
+  description = "IAM policy for update poll duration lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.update_poll_duration_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "update_poll_duration_lambda_ddb" {
+  role       = module.update_poll_duration_lambda_role.role_name
+  policy_arn = aws_iam_policy.update_poll_duration_lambda_ddb.arn
+}
+
+module "update_poll_duration_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-update-poll-duration"
+  role_arn            = module.update_poll_duration_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/update-poll-duration/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/update-poll-duration/bin/update-poll-duration.zip"
+
+  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+}
+
+resource "aws_api_gateway_method_response" "update_poll_duration_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.duration.id
+  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.update_poll_duration_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_poll_duration_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.duration.id
+  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_poll_duration_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.duration.id
+  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_poll_duration_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.duration.id
+  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_poll_duration_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.duration.id
+  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "my_polls" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.polls.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "my_polls" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.polls.path_part}/${aws_api_gateway_method.my_polls.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "my_polls" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.polls.id
+  http_method             = aws_api_gateway_method.my_polls.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.my_polls_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "my_polls_api_lambda" {
+  statement_id  = "PseudoPollAllowMyPollsLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.my_polls_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.my_polls.http_method}${aws_api_gateway_resource.polls.path}"
+}
+
+module "my_polls_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-my-polls-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "my_polls_logging" {
+  role       = module.my_polls_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "my_polls_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:Query",
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1"
+    ]
+  }
+}
+
+resource "aws_iam_policy" "my_polls_lambda_ddb" {
+  name        = "pseudopoll-my-polls-lambda-ddb"
+  description = "IAM policy for my polls lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.my_polls_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "my_polls_lambda_ddb" {
+  role       = module.my_polls_lambda_role.role_name
+  policy_arn = aws_iam_policy.my_polls_lambda_ddb.arn
+}
+
+module "my_polls_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-my-polls"
+  role_arn            = module.my_polls_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/my-polls/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/my-polls/bin/my-polls.zip"
+
+  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+}
+
+resource "aws_api_gateway_method_response" "my_polls_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.polls.id
+  http_method = aws_api_gateway_method.my_polls.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.my_polls_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "my_polls_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.polls.id
+  http_method = aws_api_gateway_method.my_polls.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "my_polls_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.polls.id
+  http_method = aws_api_gateway_method.my_polls.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "public_get_poll" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.public_poll.id
+
+  authorization = "NONE"
+}
+
+resource "aws_api_gateway_method_settings" "public_get_poll" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.public_poll.path_part}/${aws_api_gateway_method.public_get_poll.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "public_get_poll" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.public_poll.id
+  http_method             = aws_api_gateway_method.public_get_poll.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.get_poll_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "public_get_poll_api_lambda" {
+  statement_id  = "PseudoPollAllowPublicGetPollLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.get_poll_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.public_get_poll.http_method}${aws_api_gateway_resource.public_poll.path}"
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll.id
+  http_method = aws_api_gateway_method.public_get_poll.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.poll_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll.id
+  http_method = aws_api_gateway_method.public_get_poll.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll.id
+  http_method = aws_api_gateway_method.public_get_poll.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll.id
+  http_method = aws_api_gateway_method.public_get_poll.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll.id
+  http_method = aws_api_gateway_method.public_get_poll.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "create_subscription_token" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.subscription_token.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.querystring.requestId" = false
+  }
+}
+
+resource "aws_api_gateway_method_settings" "create_subscription_token" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.subscription_token.path_part}/${aws_api_gateway_method.create_subscription_token.http_method}"
+
+  # The response body holds the subscription token, so it is kept out of the execution logs
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = false
+  }
+}
+
+resource "aws_api_gateway_integration" "create_subscription_token" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.subscription_token.id
+  http_method             = aws_api_gateway_method.create_subscription_token.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_subscription_token_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "create_subscription_token_api_lambda" {
+  statement_id  = "PseudoPollAllowCreateSubscriptionTokenLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_subscription_token_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.create_subscription_token.http_method}${aws_api_gateway_resource.subscription_token.path}"
+}
+
+module "create_subscription_token_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-create-subscription-token-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "create_subscription_token_logging" {
+  role       = module.create_subscription_token_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "create_subscription_token_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "create_subscription_token_lambda_ddb" {
+  name        = "pseudopoll-create-subscription-token-lambda-ddb"
+  description = "IAM policy for create subscription token lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.create_subscription_token_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "create_subscription_token_lambda_ddb" {
+  role       = module.create_subscription_token_lambda_role.role_name
+  policy_arn = aws_iam_policy.create_subscription_token_lambda_ddb.arn
+}
+
+module "create_subscription_token_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-create-subscription-token"
+  role_arn            = module.create_subscription_token_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/create-subscription-token/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/create-subscription-token/bin/create-subscription-token.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME         = var.single_table_name
+    SUBSCRIPTION_TOKEN_SECRET = var.subscription_token_secret
+    SUBSCRIPTION_TOKEN_TTL    = var.subscription_token_ttl
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_subscription_token_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.subscription_token.id
+  http_method = aws_api_gateway_method.create_subscription_token.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.subscription_token_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_subscription_token_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.subscription_token.id
+  http_method = aws_api_gateway_method.create_subscription_token.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_subscription_token_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.subscription_token.id
+  http_method = aws_api_gateway_method.create_subscription_token.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_subscription_token_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.subscription_token.id
+  http_method = aws_api_gateway_method.create_subscription_token.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_subscription_token_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.subscription_token.id
+  http_method = aws_api_gateway_method.create_subscription_token.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_subscription_token_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.subscription_token.id
+  http_method = aws_api_gateway_method.create_subscription_token.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "public_create_subscription_token" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.public_subscription_token.id
+
+  authorization = "NONE"
+
+  request_parameters = {
+    "method.request.querystring.requestId" = false
+  }
+}
+
+resource "aws_api_gateway_method_settings" "public_create_subscription_token" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.public_subscription_token.path_part}/${aws_api_gateway_method.public_create_subscription_token.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = false
+  }
+}
+
+resource "aws_api_gateway_integration" "public_create_subscription_token" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.public_subscription_token.id
+  http_method             = aws_api_gateway_method.public_create_subscription_token.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_subscription_token_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "public_create_subscription_token_api_lambda" {
+  statement_id  = "PseudoPollAllowPublicCreateSubscriptionTokenLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_subscription_token_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.public_create_subscription_token.http_method}${aws_api_gateway_resource.public_subscription_token.path}"
+}
+
+resource "aws_api_gateway_method_response" "public_create_subscription_token_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_subscription_token.id
+  http_method = aws_api_gateway_method.public_create_subscription_token.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.subscription_token_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_create_subscription_token_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_subscription_token.id
+  http_method = aws_api_gateway_method.public_create_subscription_token.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_create_subscription_token_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_subscription_token.id
+  http_method = aws_api_gateway_method.public_create_subscription_token.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_create_subscription_token_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_subscription_token.id
+  http_method = aws_api_gateway_method.public_create_subscription_token.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_create_subscription_token_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_subscription_token.id
+  http_method = aws_api_gateway_method.public_create_subscription_token.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "get_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "get_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.poll_snapshot.path_part}/${aws_api_gateway_method.get_poll_snapshot.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "get_poll_snapshot" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.poll_snapshot.id
+  http_method             = aws_api_gateway_method.get_poll_snapshot.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.get_poll_snapshot_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "get_poll_snapshot_api_lambda" {
+  statement_id  = "PseudoPollAllowGetPollSnapshotLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.get_poll_snapshot_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.get_poll_snapshot.http_method}${aws_api_gateway_resource.poll_snapshot.path}"
+}
+
+module "get_poll_snapshot_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-get-poll-snapshot-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "get_poll_snapshot_logging" {
+  role       = module.get_poll_snapshot_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "get_poll_snapshot_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "get_poll_snapshot_lambda_ddb" {
+  name        = "pseudopoll-get-poll-snapshot-lambda-ddb"
+  description = "IAM policy for get poll snapshot lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.get_poll_snapshot_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "get_poll_snapshot_lambda_ddb" {
+  role       = module.get_poll_snapshot_lambda_role.role_name
+  policy_arn = aws_iam_policy.get_poll_snapshot_lambda_ddb.arn
+}
+
+module "get_poll_snapshot_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-get-poll-snapshot"
+  role_arn            = module.get_poll_snapshot_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/get-poll-snapshot/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/get-poll-snapshot/bin/get-poll-snapshot.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_snapshot_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.get_poll_snapshot.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.poll_snapshot_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_snapshot_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.get_poll_snapshot.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_snapshot_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.get_poll_snapshot.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_snapshot_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.get_poll_snapshot.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_snapshot_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.get_poll_snapshot.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_snapshot_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.get_poll_snapshot.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "public_get_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+
+  authorization = "NONE"
+}
+
+resource "aws_api_gateway_method_settings" "public_get_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.public_poll_snapshot.path_part}/${aws_api_gateway_method.public_get_poll_snapshot.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "public_get_poll_snapshot" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method             = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.get_poll_snapshot_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "public_get_poll_snapshot_api_lambda" {
+  statement_id  = "PseudoPollAllowPublicGetPollSnapshotLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.get_poll_snapshot_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.public_get_poll_snapshot.http_method}${aws_api_gateway_resource.public_poll_snapshot.path}"
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_snapshot_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.poll_snapshot_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_snapshot_unauthorized" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  status_code = "401"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_snapshot_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_snapshot_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_snapshot_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "public_get_poll_snapshot_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.public_poll_snapshot.id
+  http_method = aws_api_gateway_method.public_get_poll_snapshot.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "post_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "post_poll_snapshot" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.poll_snapshot.path_part}/${aws_api_gateway_method.post_poll_snapshot.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+# Requests a pollSnapshot message by putting an event on the bus for the poll snapshot publisher
+resource "aws_api_gateway_integration" "post_poll_snapshot" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.poll_snapshot.id
+  http_method             = aws_api_gateway_method.post_poll_snapshot.http_method
+  integration_http_method = "POST"
+  type                    = "AWS"
+  passthrough_behavior    = "NEVER"
+  credentials             = var.api_role_arn
+  uri                     = "arn:aws:apigateway:${var.region}:events:action/PutEvents"
+
+  request_parameters = {
+    "integration.request.header.X-Amz-Target" = "'AWSEvents.PutEvents'"
+    "integration.request.header.Content-Type" = "'application/x-amz-json-1.1'"
+  }
+
+  request_templates = {
+    "application/json" = templatefile(
+      "${path.module}/../../templates/mappings/requests/poll-snapshot-requested.vm",
+      {
+        eventBusName = var.event_bus_name
+        source       = var.poll_snapshot_requested_source
+        detailType   = var.poll_snapshot_requested_detail_type
+      }
+    )
+  }
+}
+
+resource "aws_api_gateway_integration_response" "post_poll_snapshot_accepted" {
+  rest_api_id       = var.rest_api_id
+  resource_id       = aws_api_gateway_resource.poll_snapshot.id
+  http_method       = aws_api_gateway_method.post_poll_snapshot.http_method
+  status_code       = aws_api_gateway_method_response.post_poll_snapshot_accepted.status_code
+  selection_pattern = "^2[0-9][0-9]"
+
+  response_templates = {
+    "application/json" = templatefile("${path.module}/../../templates/mappings/responses/poll-snapshot-requested.vm", {})
+  }
+}
+
+module "api_poll_snapshot_requested_iam" {
+  source        = "./iam"
+  api_role_name = var.api_role_name
+  event_bus_arn = var.event_bus_arn
+}
+
+resource "aws_api_gateway_method_response" "post_poll_snapshot_accepted" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.poll_snapshot.id
+  http_method = aws_api_gateway_method.post_poll_snapshot.http_method
+  status_code = "202"
+
+  response_models = {
+    "application/json" = var.poll_snapshot_requested_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "clone_poll" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.clone.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.pollId" = true
+  }
+}
+
+resource "aws_api_gateway_method_settings" "clone_poll" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.clone.path_part}/${aws_api_gateway_method.clone_poll.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "clone_poll" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.clone.id
+  http_method             = aws_api_gateway_method.clone_poll.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.clone_poll_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "clone_poll_api_lambda" {
+  statement_id  = "PseudoPollAllowClonePollLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.clone_poll_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.clone_poll.http_method}${aws_api_gateway_resource.clone.path}"
+}
+
+module "clone_poll_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-clone-poll-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "clone_poll_logging" {
+  role       = module.clone_poll_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "clone_poll_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = ["${var.single_table_arn}/index/GSI1"]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:TransactWriteItems",
+      "dynamodb:PutItem"
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "clone_poll_lambda_ddb" {
+  name        = "pseudopoll-clone-poll-lambda-ddb"
+  description = "IAM policy for clone poll lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.clone_poll_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "clone_poll_lambda_ddb" {
+  role       = module.clone_poll_lambda_role.role_name
+  policy_arn = aws_iam_policy.clone_poll_lambda_ddb.arn
+}
+
+module "clone_poll_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-clone-poll"
+  role_arn            = module.clone_poll_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/clone-poll/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/clone-poll/bin/clone-poll.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+    PROMPT_MIN_LENGTH = "${var.prompt_min_length}"
+    PROMPT_MAX_LENGTH = "${var.prompt_max_length}"
+    OPTION_MIN_LENGTH = "${var.option_min_length}"
+    OPTION_MAX_LENGTH = "${var.option_max_length}"
+    MIN_OPTIONS       = "${var.min_options}"
+    MAX_OPTIONS       = "${var.max_options}"
+    MIN_DURATION      = "${var.min_duration}"
+    MAX_DURATION      = "${var.max_duration}"
+    MAX_VOTE_WEIGHT   = "${var.max_vote_weight}"
+    MAX_VOTE_WEIGHTS  = "${var.max_vote_weights}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "clone_poll_created" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.clone.id
+  http_method = aws_api_gateway_method.clone_poll.http_method
+  status_code = "201"
+
+  response_models = {
+    "application/json" = var.poll_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "clone_poll_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.clone.id
+  http_method = aws_api_gateway_method.clone_poll.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "clone_poll_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.clone.id
+  http_method = aws_api_gateway_method.clone_poll.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "clone_poll_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.clone.id
+  http_method = aws_api_gateway_method.clone_poll.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "clone_poll_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.clone.id
+  http_method = aws_api_gateway_method.clone_poll.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "clone_poll_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.clone.id
+  http_method = aws_api_gateway_method.clone_poll.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "get_poll_timeline" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.timeline.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.pollId"        = true
+    "method.request.querystring.bucket" = false
+  }
+}
+
+resource "aws_api_gateway_method_settings" "get_poll_timeline" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.timeline.path_part}/${aws_api_gateway_method.get_poll_timeline.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "get_poll_timeline" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.timeline.id
+  http_method             = aws_api_gateway_method.get_poll_timeline.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.get_poll_timeline_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "get_poll_timeline_api_lambda" {
+  statement_id  = "PseudoPollAllowGetPollTimelineLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.get_poll_timeline_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.get_poll_timeline.http_method}${aws_api_gateway_resource.timeline.path}"
+}
+
+module "get_poll_timeline_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-get-poll-timeline-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "get_poll_timeline_logging" {
+  role       = module.get_poll_timeline_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "get_poll_timeline_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "get_poll_timeline_lambda_ddb" {
+  name        = "pseudopoll-get-poll-timeline-lambda-ddb"
+  description = "IAM policy for get poll timeline lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.get_poll_timeline_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "get_poll_timeline_lambda_ddb" {
+  role       = module.get_poll_timeline_lambda_role.role_name
+  policy_arn = aws_iam_policy.get_poll_timeline_lambda_ddb.arn
+}
+
+module "get_poll_timeline_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-get-poll-timeline"
+  role_arn            = module.get_poll_timeline_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/get-poll-timeline/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/get-poll-timeline/bin/get-poll-timeline.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_timeline_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.timeline.id
+  http_method = aws_api_gateway_method.get_poll_timeline.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.poll_timeline_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_timeline_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.timeline.id
+  http_method = aws_api_gateway_method.get_poll_timeline.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_timeline_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.timeline.id
+  http_method = aws_api_gateway_method.get_poll_timeline.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_timeline_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.timeline.id
+  http_method = aws_api_gateway_method.get_poll_timeline.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_timeline_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.timeline.id
+  http_method = aws_api_gateway_method.get_poll_timeline.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_poll_timeline_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.timeline.id
+  http_method = aws_api_gateway_method.get_poll_timeline.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+# Option images are uploaded straight to S3 with presigned URLs, and served publicly once their poll shows them
+resource "aws_s3_bucket" "option_images" {
+  bucket_prefix = "pseudopoll-option-images-"
+}
+
+resource "aws_s3_bucket_public_access_block" "option_images" {
+  bucket = aws_s3_bucket.option_images.id
+
+  block_public_acls       = true
+  ignore_public_acls      = true
+  block_public_policy     = false
+  restrict_public_buckets = false
+}
+
+data "aws_iam_policy_document" "option_images_public_read" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "*"
+      identifiers = ["*"]
+    }
+
+    actions = ["s3:GetObject"]
+
+    resources = ["${aws_s3_bucket.option_images.arn}/options/*"]
+  }
+}
+
+resource "aws_s3_bucket_policy" "option_images_public_read" {
+  bucket = aws_s3_bucket.option_images.id
+  policy = data.aws_iam_policy_document.option_images_public_read.json
+
+  depends_on = [aws_s3_bucket_public_access_block.option_images]
+}
+
+resource "aws_s3_bucket_cors_configuration" "option_images" {
+  bucket = aws_s3_bucket.option_images.id
+
+  cors_rule {
+    allowed_headers = ["Content-Type"]
+    allowed_methods = ["GET", "PUT"]
+    allowed_origins = ["*"]
+    max_age_seconds = 3000
+  }
+}
+
+resource "aws_api_gateway_request_validator" "create_option_image_upload" {
+  name                  = "create-option-image-upload-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "create_option_image_upload" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.option_images.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.create_option_image_upload.id
+  request_models = {
+    "application/json" = var.create_option_image_upload_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "create_option_image_upload" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.option_images.path_part}/${aws_api_gateway_method.create_option_image_upload.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "create_option_image_upload" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.option_images.id
+  http_method             = aws_api_gateway_method.create_option_image_upload.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_option_image_upload_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "create_option_image_upload_api_lambda" {
+  statement_id  = "PseudoPollAllowCreateOptionImageUploadLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_option_image_upload_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.create_option_image_upload.http_method}${aws_api_gateway_resource.option_images.path}"
+}
+
+module "create_option_image_upload_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-create-option-image-upload-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "create_option_image_upload_logging" {
+  role       = module.create_option_image_upload_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+# Presigned URLs act with the permissions of the role that signed them
+data "aws_iam_policy_document" "create_option_image_upload_lambda_s3" {
+  statement {
+    effect = "Allow"
+
+    actions = ["s3:PutObject"]
+
+    resources = ["${aws_s3_bucket.option_images.arn}/options/*"]
+  }
+}
+
+resource "aws_iam_policy" "create_option_image_upload_lambda_s3" {
+  name        = "pseudopoll-create-option-image-upload-lambda-s3"
+  description = "IAM policy for create option image upload lambda to sign uploads to S3"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.create_option_image_upload_lambda_s3.json
+}
+
+resource "aws_iam_role_policy_attachment" "create_option_image_upload_lambda_s3" {
+  role       = module.create_option_image_upload_lambda_role.role_name
+  policy_arn = aws_iam_policy.create_option_image_upload_lambda_s3.arn
+}
+
+module "create_option_image_upload_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-create-option-image-upload"
+  role_arn            = module.create_option_image_upload_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/create-option-image-upload/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/create-option-image-upload/bin/create-option-image-upload.zip"
+
+  environment_variables = {
+    OPTION_IMAGES_BUCKET_NAME = aws_s3_bucket.option_images.id
+    NANOID_ALPHABET           = var.nanoid_alphabet
+    NANOID_LENGTH             = "${var.nanoid_length}"
+    IMAGE_MAX_SIZE            = "${var.option_image_max_size}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_option_image_upload_created" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.option_images.id
+  http_method = aws_api_gateway_method.create_option_image_upload.http_method
+  status_code = "201"
+
+  response_models = {
+    "application/json" = var.option_image_upload_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_option_image_upload_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.option_images.id
+  http_method = aws_api_gateway_method.create_option_image_upload.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_option_image_upload_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.option_images.id
+  http_method = aws_api_gateway_method.create_option_image_upload.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "moderate_write_in" {
+  name                  = "moderate-write-in-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "moderate_write_in" {
+  rest_api_id = var.rest_api_id
+  http_method = "PATCH"
+  resource_id = aws_api_gateway_resource.write_in.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.pollId"   = true
+    "method.request.path.optionId" = true
+  }
+
+  request_validator_id = aws_api_gateway_request_validator.moderate_write_in.id
+  request_models = {
+    "application/json" = var.moderate_write_in_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "moderate_write_in" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.write_in.path_part}/${aws_api_gateway_method.moderate_write_in.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "moderate_write_in" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.write_in.id
+  http_method             = aws_api_gateway_method.moderate_write_in.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.moderate_write_in_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "moderate_write_in_api_lambda" {
+  statement_id  = "PseudoPollAllowModerateWriteInLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.moderate_write_in_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.moderate_write_in.http_method}${aws_api_gateway_resource.write_in.path}"
+}
+
+module "moderate_write_in_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-moderate-write-in-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "moderate_write_in_logging" {
+  role       = module.moderate_write_in_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "moderate_write_in_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem",
+      "dynamodb:TransactWriteItems",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "moderate_write_in_lambda_ddb" {
+  name        = "pseudopoll-moderate-write-in-lambda-ddb"
+  description = "IAM policy for moderate write-in lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.moderate_write_in_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "moderate_write_in_lambda_ddb" {
+  role       = module.moderate_write_in_lambda_role.role_name
+  policy_arn = aws_iam_policy.moderate_write_in_lambda_ddb.arn
+}
+
+module "moderate_write_in_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-moderate-write-in"
+  role_arn            = module.moderate_write_in_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/moderate-write-in/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/moderate-write-in/bin/moderate-write-in.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.write_in_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_conflict" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "409"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "moderate_write_in_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.write_in.id
+  http_method = aws_api_gateway_method.moderate_write_in.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "break_tie" {
+  name                  = "break-tie-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "break_tie" {
+  rest_api_id = var.rest_api_id
+  http_method = "PUT"
+  resource_id = aws_api_gateway_resource.tie_break.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.pollId" = true
+  }
+
+  request_validator_id = aws_api_gateway_request_validator.break_tie.id
+  request_models = {
+    "application/json" = var.break_tie_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "break_tie" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.tie_break.path_part}/${aws_api_gateway_method.break_tie.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "break_tie" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.tie_break.id
+  http_method             = aws_api_gateway_method.break_tie.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.break_tie_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "break_tie_api_lambda" {
+  statement_id  = "PseudoPollAllowBreakTieLambdaExecutionFromApiGateway"
   action        = "lambda:InvokeFunction"
-  function_name = module.update_poll_duration_lambda.function_name
+  function_name = module.break_tie_lambda.function_name
   principal     = "apigateway.amazonaws.com"
 
-  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.update_poll_duration.http_method}${aws_api_gateway_resource.duration.path}"
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.break_tie.http_method}${aws_api_gateway_resource.tie_break.path}"
 }
 
-module "update_poll_duration_lambda_role" {
+module "break_tie_lambda_role" {
   source    = "../../lambda/iam"
-  role_name = "pseudopoll-update-poll-duration-lambda-role"
+  role_name = "pseudopoll-break-tie-lambda-role"
 }
 
-resource "aws_iam_role_policy_attachment" "update_poll_duration_logging" {
-  role       = module.update_poll_duration_lambda_role.role_name
+resource "aws_iam_role_policy_attachment" "break_tie_logging" {
+  role       = module.break_tie_lambda_role.role_name
   policy_arn = var.lambda_logging_policy_arn
 }
 
-data "aws_iam_policy_document" "update_poll_duration_lambda_ddb" {
+data "aws_iam_policy_document" "break_tie_lambda_ddb" {
   statement {
     effect = "Allow"
 
     actions = [
       "dynamodb:GetItem",
+      "dynamodb:Query",
       "dynamodb:UpdateItem",
     ]
 
-    resources = [var.single_table_arn]
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+    ]
   }
 }
 
-resource "aws_iam_policy" "update_poll_duration_lambda_ddb" {
-  name        = "pseudopoll-update-poll-duration-lambda-ddb"
-  description = "IAM policy for update poll duration lambda to read from and write to DynamoDB"
+resource "aws_iam_policy" "break_tie_lambda_ddb" {
+  name        = "pseudopoll-break-tie-lambda-ddb"
+  description = "IAM policy for break tie lambda to read from and write to DynamoDB"
   path        = "/"
-  policy      = data.aws_iam_policy_document.update_poll_duration_lambda_ddb.json
+  policy      = data.aws_iam_policy_document.break_tie_lambda_ddb.json
 }
 
-resource "aws_iam_role_policy_attachment" "update_poll_duration_lambda_ddb" {
-  role       = module.update_poll_duration_lambda_role.role_name
-  policy_arn = aws_iam_policy.update_poll_duration_lambda_ddb.arn
+resource "aws_iam_role_policy_attachment" "break_tie_lambda_ddb" {
+  role       = module.break_tie_lambda_role.role_name
+  policy_arn = aws_iam_policy.break_tie_lambda_ddb.arn
 }
 
-module "update_poll_duration_lambda" {
+module "break_tie_lambda" {
   source              = "../../lambda"
-  function_name       = "pseudopoll-update-poll-duration"
-  role_arn            = module.update_poll_duration_lambda_role.role_arn
-  archive_source_file = "${path.module}/../../../../backend/lambdas/update-poll-duration/bin/bootstrap"
-  archive_output_path = "${path.module}/../../../../backend/lambdas/update-poll-duration/bin/update-poll-duration.zip"
+  function_name       = "pseudopoll-break-tie"
+  role_arn            = module.break_tie_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/break-tie/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/break-tie/bin/break-tie.zip"
 
-  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
 }
 
-resource "aws_api_gateway_method_response" "update_poll_duration_ok" {
+resource "aws_api_gateway_method_response" "break_tie_ok" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.duration.id
-  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
   status_code = "200"
 
   response_models = {
-    "application/json" = var.update_poll_duration_model_name
+    "application/json" = var.tie_break_model_name
   }
 }
 
-resource "aws_api_gateway_method_response" "update_poll_duration_bad_request" {
+resource "aws_api_gateway_method_response" "break_tie_bad_request" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.duration.id
-  http_method = aws_api_gateway_method.update_poll_duration.http_method
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
   status_code = "400"
 
   response_models = {
@@ -532,41 +2780,90 @@ resource "aws_api_gateway_method_response" "update_poll_duration_bad_request" {
   }
 }
 
-resource "aws_api_gateway_method_response" "update_poll_duration_internal_server_error" {
+resource "aws_api_gateway_method_response" "break_tie_not_found" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.duration.id
-  http_method = aws_api_gateway_method.update_poll_duration.http_method
-  status_code = "500"
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
+  status_code = "404"
 
   response_models = {
     "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_method_response" "update_poll_duration_not_found" {
+resource "aws_api_gateway_method_response" "break_tie_gone" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.duration.id
-  http_method = aws_api_gateway_method.update_poll_duration.http_method
-  status_code = "404"
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
+  status_code = "410"
 
   response_models = {
     "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_method" "my_polls" {
+resource "aws_api_gateway_method_response" "break_tie_conflict" {
   rest_api_id = var.rest_api_id
-  http_method = "GET"
-  resource_id = aws_api_gateway_resource.polls.id
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
+  status_code = "409"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "break_tie_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "break_tie_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.tie_break.id
+  http_method = aws_api_gateway_method.break_tie.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "add_poll_member" {
+  name                  = "add-poll-member-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "add_poll_member" {
+  rest_api_id = var.rest_api_id
+  http_method = "PUT"
+  resource_id = aws_api_gateway_resource.member.id
 
   authorization = "CUSTOM"
   authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.pollId" = true
+    "method.request.path.userId" = true
+  }
+
+  request_validator_id = aws_api_gateway_request_validator.add_poll_member.id
+  request_models = {
+    "application/json" = var.add_poll_member_model_name
+  }
 }
 
-resource "aws_api_gateway_method_settings" "my_polls" {
+resource "aws_api_gateway_method_settings" "add_poll_member" {
   rest_api_id = var.rest_api_id
   stage_name  = var.stage_name
-  method_path = "${aws_api_gateway_resource.polls.path_part}/${aws_api_gateway_method.my_polls.http_method}"
+  method_path = "${aws_api_gateway_resource.member.path_part}/${aws_api_gateway_method.add_poll_member.http_method}"
 
   settings {
     logging_level      = "INFO"
@@ -575,86 +2872,97 @@ resource "aws_api_gateway_method_settings" "my_polls" {
   }
 }
 
-resource "aws_api_gateway_integration" "my_polls" {
+resource "aws_api_gateway_integration" "add_poll_member" {
   rest_api_id             = var.rest_api_id
-  resource_id             = aws_api_gateway_resource.polls.id
-  http_method             = aws_api_gateway_method.my_polls.http_method
+  resource_id             = aws_api_gateway_resource.member.id
+  http_method             = aws_api_gateway_method.add_poll_member.http_method
   integration_http_method = "POST"
   type                    = "AWS_PROXY"
-  uri                     = module.my_polls_lambda.invoke_arn
+  uri                     = module.add_poll_member_lambda.invoke_arn
 }
 
-resource "aws_lambda_permission" "my_polls_api_lambda" {
-  statement_id  = "PseudoPollAllowMyPollsLambdaExecutionFromApiGateway"
+resource "aws_lambda_permission" "add_poll_member_api_lambda" {
+  statement_id  = "PseudoPollAllowAddPollMemberLambdaExecutionFromApiGateway"
   action        = "lambda:InvokeFunction"
-  function_name = module.my_polls_lambda.function_name
+  function_name = module.add_poll_member_lambda.function_name
   principal     = "apigateway.amazonaws.com"
 
-  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.my_polls.http_method}${aws_api_gateway_resource.polls.path}"
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.add_poll_member.http_method}${aws_api_gateway_resource.member.path}"
 }
 
-module "my_polls_lambda_role" {
+module "add_poll_member_lambda_role" {
   source    = "../../lambda/iam"
-  role_name = "pseudopoll-my-polls-lambda-role"
+  role_name = "pseudopoll-add-poll-member-lambda-role"
 }
 
-resource "aws_iam_role_policy_attachment" "my_polls_logging" {
-  role       = module.my_polls_lambda_role.role_name
+resource "aws_iam_role_policy_attachment" "add_poll_member_logging" {
+  role       = module.add_poll_member_lambda_role.role_name
   policy_arn = var.lambda_logging_policy_arn
 }
 
-data "aws_iam_policy_document" "my_polls_lambda_ddb" {
+data "aws_iam_policy_document" "add_poll_member_lambda_ddb" {
   statement {
     effect = "Allow"
 
     actions = [
-      "dynamodb:Query",
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem",
     ]
 
-    resources = [
-      var.single_table_arn,
-      "${var.single_table_arn}/index/GSI1"
-    ]
+    resources = [var.single_table_arn]
   }
 }
 
-resource "aws_iam_policy" "my_polls_lambda_ddb" {
-  name        = "pseudopoll-my-polls-lambda-ddb"
-  description = "IAM policy for my polls lambda to read from DynamoDB"
+resource "aws_iam_policy" "add_poll_member_lambda_ddb" {
+  name        = "pseudopoll-add-poll-member-lambda-ddb"
+  description = "IAM policy for add poll member lambda to read from and write to DynamoDB"
   path        = "/"
-  policy      = data.aws_iam_policy_document.my_polls_lambda_ddb.json
+  policy      = data.aws_iam_policy_document.add_poll_member_lambda_ddb.json
 }
 
-resource "aws_iam_role_policy_attachment" "my_polls_lambda_ddb" {
-  role       = module.my_polls_lambda_role.role_name
-  policy_arn = aws_iam_policy.my_polls_lambda_ddb.arn
+resource "aws_iam_role_policy_attachment" "add_poll_member_lambda_ddb" {
+  role       = module.add_poll_member_lambda_role.role_name
+  policy_arn = aws_iam_policy.add_poll_member_lambda_ddb.arn
 }
 
-module "my_polls_lambda" {
+module "add_poll_member_lambda" {
   source              = "../../lambda"
-  function_name       = "pseudopoll-my-polls"
-  role_arn            = module.my_polls_lambda_role.role_arn
-  archive_source_file = "${path.module}/../../../../backend/lambdas/my-polls/bin/bootstrap"
-  archive_output_path = "${path.module}/../../../../backend/lambdas/my-polls/bin/my-polls.zip"
+  function_name       = "pseudopoll-add-poll-member"
+  role_arn            = module.add_poll_member_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/add-poll-member/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/add-poll-member/bin/add-poll-member.zip"
 
-  environment_variables = { SINGLE_TABLE_NAME = var.single_table_name }
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
 }
 
-resource "aws_api_gateway_method_response" "my_polls_ok" {
+resource "aws_api_gateway_method_response" "add_poll_member_ok" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.polls.id
-  http_method = aws_api_gateway_method.my_polls.http_method
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.add_poll_member.http_method
   status_code = "200"
 
   response_models = {
-    "application/json" = var.my_polls_model_name
+    "application/json" = var.poll_member_model_name
   }
 }
 
-resource "aws_api_gateway_method_response" "my_polls_forbidden" {
+resource "aws_api_gateway_method_response" "add_poll_member_bad_request" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.polls.id
-  http_method = aws_api_gateway_method.my_polls.http_method
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.add_poll_member.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "add_poll_member_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.add_poll_member.http_method
   status_code = "403"
 
   response_models = {
@@ -662,10 +2970,32 @@ resource "aws_api_gateway_method_response" "my_polls_forbidden" {
   }
 }
 
-resource "aws_api_gateway_method_response" "my_polls_internal_server_error" {
+resource "aws_api_gateway_method_response" "add_poll_member_not_found" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.polls.id
-  http_method = aws_api_gateway_method.my_polls.http_method
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.add_poll_member.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "add_poll_member_gone" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.add_poll_member.http_method
+  status_code = "410"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "add_poll_member_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.add_poll_member.http_method
   status_code = "500"
 
   response_models = {
@@ -673,18 +3003,24 @@ resource "aws_api_gateway_method_response" "my_polls_internal_server_error" {
   }
 }
 
-resource "aws_api_gateway_method" "public_get_poll" {
+resource "aws_api_gateway_method" "remove_poll_member" {
   rest_api_id = var.rest_api_id
-  http_method = "GET"
-  resource_id = aws_api_gateway_resource.public_poll.id
+  http_method = "DELETE"
+  resource_id = aws_api_gateway_resource.member.id
 
-  authorization = "NONE"
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.pollId" = true
+    "method.request.path.userId" = true
+  }
 }
 
-resource "aws_api_gateway_method_settings" "public_get_poll" {
+resource "aws_api_gateway_method_settings" "remove_poll_member" {
   rest_api_id = var.rest_api_id
   stage_name  = var.stage_name
-  method_path = "${aws_api_gateway_resource.public_poll.path_part}/${aws_api_gateway_method.public_get_poll.http_method}"
+  method_path = "${aws_api_gateway_resource.member.path_part}/${aws_api_gateway_method.remove_poll_member.http_method}"
 
   settings {
     logging_level      = "INFO"
@@ -693,61 +3029,104 @@ resource "aws_api_gateway_method_settings" "public_get_poll" {
   }
 }
 
-resource "aws_api_gateway_integration" "public_get_poll" {
+resource "aws_api_gateway_integration" "remove_poll_member" {
   rest_api_id             = var.rest_api_id
-  resource_id             = aws_api_gateway_resource.public_poll.id
-  http_method             = aws_api_gateway_method.public_get_poll.http_method
+  resource_id             = aws_api_gateway_resource.member.id
+  http_method             = aws_api_gateway_method.remove_poll_member.http_method
   integration_http_method = "POST"
   type                    = "AWS_PROXY"
-  uri                     = module.get_poll_lambda.invoke_arn
+  uri                     = module.remove_poll_member_lambda.invoke_arn
 }
 
-resource "aws_lambda_permission" "public_get_poll_api_lambda" {
-  statement_id  = "PseudoPollAllowPublicGetPollLambdaExecutionFromApiGateway"
+resource "aws_lambda_permission" "remove_poll_member_api_lambda" {
+  statement_id  = "PseudoPollAllowRemovePollMemberLambdaExecutionFromApiGateway"
   action        = "lambda:InvokeFunction"
-  function_name = module.get_poll_lambda.function_name
+  function_name = module.remove_poll_member_lambda.function_name
   principal     = "apigateway.amazonaws.com"
 
-  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.public_get_poll.http_method}${aws_api_gateway_resource.public_poll.path}"
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.remove_poll_member.http_method}${aws_api_gateway_resource.member.path}"
 }
 
-resource "aws_api_gateway_method_response" "public_get_poll_ok" {
-  rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.public_poll.id
-  http_method = aws_api_gateway_method.public_get_poll.http_method
-  status_code = "200"
+module "remove_poll_member_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-remove-poll-member-lambda-role"
+}
 
-  response_models = {
-    "application/json" = var.poll_model_name
+resource "aws_iam_role_policy_attachment" "remove_poll_member_logging" {
+  role       = module.remove_poll_member_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "remove_poll_member_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:DeleteItem",
+    ]
+
+    resources = [var.single_table_arn]
   }
 }
 
-resource "aws_api_gateway_method_response" "public_get_poll_unauthorized" {
+resource "aws_iam_policy" "remove_poll_member_lambda_ddb" {
+  name        = "pseudopoll-remove-poll-member-lambda-ddb"
+  description = "IAM policy for remove poll member lambda to read from and delete from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.remove_poll_member_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "remove_poll_member_lambda_ddb" {
+  role       = module.remove_poll_member_lambda_role.role_name
+  policy_arn = aws_iam_policy.remove_poll_member_lambda_ddb.arn
+}
+
+module "remove_poll_member_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-remove-poll-member"
+  role_arn            = module.remove_poll_member_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/remove-poll-member/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/remove-poll-member/bin/remove-poll-member.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "remove_poll_member_no_content" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.public_poll.id
-  http_method = aws_api_gateway_method.public_get_poll.http_method
-  status_code = "401"
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.remove_poll_member.http_method
+  status_code = "204"
+}
+
+resource "aws_api_gateway_method_response" "remove_poll_member_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.remove_poll_member.http_method
+  status_code = "403"
 
   response_models = {
     "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_method_response" "public_get_poll_forbidden" {
+resource "aws_api_gateway_method_response" "remove_poll_member_not_found" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.public_poll.id
-  http_method = aws_api_gateway_method.public_get_poll.http_method
-  status_code = "403"
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.remove_poll_member.http_method
+  status_code = "404"
 
   response_models = {
     "application/json" = var.error_model_name
   }
 }
 
-resource "aws_api_gateway_method_response" "public_get_poll_internal_server_error" {
+resource "aws_api_gateway_method_response" "remove_poll_member_internal_server_error" {
   rest_api_id = var.rest_api_id
-  resource_id = aws_api_gateway_resource.public_poll.id
-  http_method = aws_api_gateway_method.public_get_poll.http_method
+  resource_id = aws_api_gateway_resource.member.id
+  http_method = aws_api_gateway_method.remove_poll_member.http_method
   status_code = "500"
 
   response_models = {
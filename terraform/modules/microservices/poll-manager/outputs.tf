@@ -7,28 +7,58 @@ output "resources_hash" {
     aws_api_gateway_resource.public_poll,
     aws_api_gateway_resource.archive,
     aws_api_gateway_resource.duration,
+    aws_api_gateway_resource.subscription_token,
+    aws_api_gateway_resource.public_subscription_token,
+    aws_api_gateway_resource.poll_snapshot,
+    aws_api_gateway_resource.public_poll_snapshot,
+    aws_api_gateway_resource.clone,
+    aws_api_gateway_resource.timeline,
+    aws_api_gateway_resource.write_ins,
+    aws_api_gateway_resource.write_in,
+    aws_api_gateway_resource.option_images,
     aws_api_gateway_request_validator.create_poll,
     aws_api_gateway_method.create_poll,
     aws_api_gateway_integration.create_poll,
     aws_api_gateway_method_response.create_poll_created,
     aws_api_gateway_method_response.create_poll_bad_request,
+    aws_api_gateway_method_response.create_poll_not_found,
     aws_api_gateway_method_response.create_poll_internal_server_error,
+    aws_api_gateway_request_validator.edit_poll,
+    aws_api_gateway_method.edit_poll,
+    aws_api_gateway_integration.edit_poll,
+    aws_api_gateway_method_response.edit_poll_ok,
+    aws_api_gateway_method_response.edit_poll_bad_request,
+    aws_api_gateway_method_response.edit_poll_forbidden,
+    aws_api_gateway_method_response.edit_poll_not_found,
+    aws_api_gateway_method_response.edit_poll_conflict,
+    aws_api_gateway_method_response.edit_poll_internal_server_error,
+    aws_api_gateway_method.delete_poll,
+    aws_api_gateway_integration.delete_poll,
+    aws_api_gateway_method_response.delete_poll_accepted,
+    aws_api_gateway_method_response.delete_poll_forbidden,
+    aws_api_gateway_method_response.delete_poll_not_found,
+    aws_api_gateway_method_response.delete_poll_gone,
+    aws_api_gateway_method_response.delete_poll_internal_server_error,
     aws_api_gateway_request_validator.archive_poll,
     aws_api_gateway_method.archive_poll,
     aws_api_gateway_integration.archive_poll,
     aws_api_gateway_method_response.archive_poll_ok,
     aws_api_gateway_method_response.archive_poll_bad_request,
+    aws_api_gateway_method_response.archive_poll_forbidden,
+    aws_api_gateway_method_response.archive_poll_not_found,
     aws_api_gateway_method_response.archive_poll_internal_server_error,
     aws_api_gateway_method.get_poll,
     aws_api_gateway_integration.get_poll,
     aws_api_gateway_method_response.get_poll_ok,
     aws_api_gateway_method_response.get_poll_forbidden,
+    aws_api_gateway_method_response.get_poll_gone,
     aws_api_gateway_method_response.get_poll_internal_server_error,
     aws_api_gateway_request_validator.update_poll_duration,
     aws_api_gateway_method.update_poll_duration,
     aws_api_gateway_integration.update_poll_duration,
     aws_api_gateway_method_response.update_poll_duration_ok,
     aws_api_gateway_method_response.update_poll_duration_bad_request,
+    aws_api_gateway_method_response.update_poll_duration_forbidden,
     aws_api_gateway_method_response.update_poll_duration_internal_server_error,
     aws_api_gateway_method_response.update_poll_duration_not_found,
     aws_api_gateway_method.my_polls,
@@ -41,7 +71,102 @@ output "resources_hash" {
     aws_api_gateway_method_response.public_get_poll_ok,
     aws_api_gateway_method_response.public_get_poll_unauthorized,
     aws_api_gateway_method_response.public_get_poll_forbidden,
+    aws_api_gateway_method_response.public_get_poll_gone,
     aws_api_gateway_method_response.public_get_poll_internal_server_error,
+    aws_api_gateway_method.create_subscription_token,
+    aws_api_gateway_integration.create_subscription_token,
+    aws_api_gateway_method_response.create_subscription_token_ok,
+    aws_api_gateway_method_response.create_subscription_token_bad_request,
+    aws_api_gateway_method_response.create_subscription_token_unauthorized,
+    aws_api_gateway_method_response.create_subscription_token_forbidden,
+    aws_api_gateway_method_response.create_subscription_token_not_found,
+    aws_api_gateway_method_response.create_subscription_token_internal_server_error,
+    aws_api_gateway_method.public_create_subscription_token,
+    aws_api_gateway_integration.public_create_subscription_token,
+    aws_api_gateway_method_response.public_create_subscription_token_ok,
+    aws_api_gateway_method_response.public_create_subscription_token_unauthorized,
+    aws_api_gateway_method_response.public_create_subscription_token_forbidden,
+    aws_api_gateway_method_response.public_create_subscription_token_not_found,
+    aws_api_gateway_method_response.public_create_subscription_token_internal_server_error,
+    aws_api_gateway_method.get_poll_snapshot,
+    aws_api_gateway_integration.get_poll_snapshot,
+    aws_api_gateway_method_response.get_poll_snapshot_ok,
+    aws_api_gateway_method_response.get_poll_snapshot_unauthorized,
+    aws_api_gateway_method_response.get_poll_snapshot_forbidden,
+    aws_api_gateway_method_response.get_poll_snapshot_not_found,
+    aws_api_gateway_method_response.get_poll_snapshot_gone,
+    aws_api_gateway_method_response.get_poll_snapshot_internal_server_error,
+    aws_api_gateway_method.public_get_poll_snapshot,
+    aws_api_gateway_integration.public_get_poll_snapshot,
+    aws_api_gateway_method_response.public_get_poll_snapshot_ok,
+    aws_api_gateway_method_response.public_get_poll_snapshot_unauthorized,
+    aws_api_gateway_method_response.public_get_poll_snapshot_forbidden,
+    aws_api_gateway_method_response.public_get_poll_snapshot_not_found,
+    aws_api_gateway_method_response.public_get_poll_snapshot_gone,
+    aws_api_gateway_method_response.public_get_poll_snapshot_internal_server_error,
+    aws_api_gateway_method.post_poll_snapshot,
+    aws_api_gateway_integration.post_poll_snapshot,
+    aws_api_gateway_integration_response.post_poll_snapshot_accepted,
+    aws_api_gateway_method_response.post_poll_snapshot_accepted,
+    aws_api_gateway_method.clone_poll,
+    aws_api_gateway_integration.clone_poll,
+    aws_api_gateway_method_response.clone_poll_created,
+    aws_api_gateway_method_response.clone_poll_bad_request,
+    aws_api_gateway_method_response.clone_poll_forbidden,
+    aws_api_gateway_method_response.clone_poll_not_found,
+    aws_api_gateway_method_response.clone_poll_gone,
+    aws_api_gateway_method_response.clone_poll_internal_server_error,
+    aws_api_gateway_method.get_poll_timeline,
+    aws_api_gateway_integration.get_poll_timeline,
+    aws_api_gateway_method_response.get_poll_timeline_ok,
+    aws_api_gateway_method_response.get_poll_timeline_bad_request,
+    aws_api_gateway_method_response.get_poll_timeline_forbidden,
+    aws_api_gateway_method_response.get_poll_timeline_not_found,
+    aws_api_gateway_method_response.get_poll_timeline_gone,
+    aws_api_gateway_method_response.get_poll_timeline_internal_server_error,
+    aws_api_gateway_method.create_option_image_upload,
+    aws_api_gateway_integration.create_option_image_upload,
+    aws_api_gateway_method_response.create_option_image_upload_created,
+    aws_api_gateway_method_response.create_option_image_upload_bad_request,
+    aws_api_gateway_method_response.create_option_image_upload_internal_server_error,
+    aws_api_gateway_request_validator.moderate_write_in,
+    aws_api_gateway_method.moderate_write_in,
+    aws_api_gateway_integration.moderate_write_in,
+    aws_api_gateway_method_response.moderate_write_in_ok,
+    aws_api_gateway_method_response.moderate_write_in_bad_request,
+    aws_api_gateway_method_response.moderate_write_in_not_found,
+    aws_api_gateway_method_response.moderate_write_in_gone,
+    aws_api_gateway_method_response.moderate_write_in_conflict,
+    aws_api_gateway_method_response.moderate_write_in_forbidden,
+    aws_api_gateway_method_response.moderate_write_in_internal_server_error,
+    aws_api_gateway_resource.tie_break,
+    aws_api_gateway_request_validator.break_tie,
+    aws_api_gateway_method.break_tie,
+    aws_api_gateway_integration.break_tie,
+    aws_api_gateway_method_response.break_tie_ok,
+    aws_api_gateway_method_response.break_tie_bad_request,
+    aws_api_gateway_method_response.break_tie_not_found,
+    aws_api_gateway_method_response.break_tie_gone,
+    aws_api_gateway_method_response.break_tie_conflict,
+    aws_api_gateway_method_response.break_tie_forbidden,
+    aws_api_gateway_method_response.break_tie_internal_server_error,
+    aws_api_gateway_resource.members,
+    aws_api_gateway_resource.member,
+    aws_api_gateway_request_validator.add_poll_member,
+    aws_api_gateway_method.add_poll_member,
+    aws_api_gateway_integration.add_poll_member,
+    aws_api_gateway_method_response.add_poll_member_ok,
+    aws_api_gateway_method_response.add_poll_member_bad_request,
+    aws_api_gateway_method_response.add_poll_member_forbidden,
+    aws_api_gateway_method_response.add_poll_member_not_found,
+    aws_api_gateway_method_response.add_poll_member_gone,
+    aws_api_gateway_method_response.add_poll_member_internal_server_error,
+    aws_api_gateway_method.remove_poll_member,
+    aws_api_gateway_integration.remove_poll_member,
+    aws_api_gateway_method_response.remove_poll_member_no_content,
+    aws_api_gateway_method_response.remove_poll_member_forbidden,
+    aws_api_gateway_method_response.remove_poll_member_not_found,
+    aws_api_gateway_method_response.remove_poll_member_internal_server_error,
   ]))
 }
 
@@ -49,6 +174,18 @@ output "poll_resource_id" {
   value = aws_api_gateway_resource.poll.id
 }
 
+output "write_ins_resource_id" {
+  value = aws_api_gateway_resource.write_ins.id
+}
+
 output "public_poll_resource_id" {
   value = aws_api_gateway_resource.public_poll.id
 }
+
+output "poll_deleter_lambda_function_name" {
+  value = module.poll_deleter_lambda.function_name
+}
+
+output "poll_deleter_lambda_arn" {
+  value = module.poll_deleter_lambda.arn
+}
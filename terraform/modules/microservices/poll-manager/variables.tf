@@ -23,6 +23,11 @@ variable "create_poll_model_name" {
   type        = string
 }
 
+variable "edit_poll_model_name" {
+  description = "Name of the edit poll model"
+  type        = string
+}
+
 variable "archive_poll_model_name" {
   description = "Name of the archive poll model"
   type        = string
@@ -38,6 +43,61 @@ variable "my_polls_model_name" {
   type        = string
 }
 
+variable "subscription_token_model_name" {
+  description = "Name of the subscription token model"
+  type        = string
+}
+
+variable "poll_snapshot_model_name" {
+  description = "Name of the poll snapshot model"
+  type        = string
+}
+
+variable "poll_timeline_model_name" {
+  description = "Name of the poll timeline model"
+  type        = string
+}
+
+variable "moderate_write_in_model_name" {
+  description = "Name of the moderate write-in model"
+  type        = string
+}
+
+variable "write_in_model_name" {
+  description = "Name of the write-in model"
+  type        = string
+}
+
+variable "break_tie_model_name" {
+  description = "Name of the break tie model"
+  type        = string
+}
+
+variable "tie_break_model_name" {
+  description = "Name of the tie-break model"
+  type        = string
+}
+
+variable "add_poll_member_model_name" {
+  description = "Name of the add poll member model"
+  type        = string
+}
+
+variable "poll_member_model_name" {
+  description = "Name of the poll member model"
+  type        = string
+}
+
+variable "create_option_image_upload_model_name" {
+  description = "Name of the create option image upload model"
+  type        = string
+}
+
+variable "option_image_upload_model_name" {
+  description = "Name of the option image upload model"
+  type        = string
+}
+
 variable "error_model_name" {
   description = "Name of the error model"
   type        = string
@@ -77,3 +137,144 @@ variable "lambda_logging_policy_arn" {
   description = "ARN of the Lambda logging policy"
   type        = string
 }
+
+variable "subscription_token_secret" {
+  description = "Secret used to sign the tokens that authorize MQTT subscriptions"
+  type        = string
+  sensitive   = true
+}
+
+variable "subscription_token_ttl" {
+  description = "How long a subscription token can be used to connect, as a Go duration string"
+  type        = string
+}
+
+variable "poll_snapshot_requested_model_name" {
+  description = "Name of the poll snapshot requested model"
+  type        = string
+}
+
+variable "api_role_name" {
+  description = "Name of the API role"
+  type        = string
+}
+
+variable "api_role_arn" {
+  description = "ARN of the API role"
+  type        = string
+}
+
+variable "region" {
+  description = "The region of the AWS account"
+  type        = string
+}
+
+variable "event_bus_name" {
+  description = "Name of the event bus that poll snapshot requests are sent to"
+  type        = string
+}
+
+variable "event_bus_arn" {
+  description = "ARN of the event bus that poll snapshot requests are sent to"
+  type        = string
+}
+
+variable "poll_snapshot_requested_source" {
+  description = "The source name of the poll snapshot requested event"
+  type        = string
+}
+
+variable "poll_snapshot_requested_detail_type" {
+  description = "The detail type of the poll snapshot requested event"
+  type        = string
+}
+
+variable "prompt_min_length" {
+  description = "Minimum length of the prompt"
+  type        = number
+}
+
+variable "prompt_max_length" {
+  description = "Maximum length of the prompt"
+  type        = number
+}
+
+variable "option_min_length" {
+  description = "Minimum length of the option"
+  type        = number
+}
+
+variable "option_max_length" {
+  description = "Maximum length of the option"
+  type        = number
+}
+
+variable "min_options" {
+  description = "Minimum number of options"
+  type        = number
+}
+
+variable "max_options" {
+  description = "Maximum number of options"
+  type        = number
+}
+
+variable "min_duration" {
+  description = "Minimum duration of the poll"
+  type        = number
+}
+
+variable "max_duration" {
+  description = "Maximum duration of the poll"
+  type        = number
+}
+
+variable "max_vote_weight" {
+  description = "Maximum weight of a voter's or group's votes"
+  type        = number
+}
+
+variable "max_vote_weights" {
+  description = "Maximum number of voters and groups that a poll's votes can be weighed by"
+  type        = number
+}
+
+variable "option_description_max_length" {
+  description = "Maximum length of an option's description"
+  type        = number
+}
+
+variable "option_url_max_length" {
+  description = "Maximum length of an option's URL"
+  type        = number
+}
+
+variable "option_image_max_size" {
+  description = "Maximum size of an option's image in bytes"
+  type        = number
+}
+
+variable "poll_deletion_requested_source" {
+  description = "The source name of the poll deletion requested event"
+  type        = string
+}
+
+variable "poll_deletion_requested_detail_type" {
+  description = "The detail type of the poll deletion requested event"
+  type        = string
+}
+
+variable "ddb_stream_pipe_event_source" {
+  description = "The source name of the DynamoDB stream pipe"
+  type        = string
+}
+
+variable "ddb_stream_pipe_event_detail_type" {
+  description = "The detail type of the DynamoDB stream pipe"
+  type        = string
+}
+
+variable "archived_poll_retention_days" {
+  description = "Days archived polls are kept before they expire"
+  type        = number
+}
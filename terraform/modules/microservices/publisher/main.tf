@@ -6,7 +6,8 @@ data "aws_iam_policy_document" "lambda_iot_publish" {
 
     actions = [
       "iot:Connect",
-      "iot:Publish"
+      "iot:Publish",
+      "iot:RetainPublish"
     ]
 
     resources = [
@@ -21,6 +22,22 @@ resource "aws_iam_policy" "lambda_iot_publish" {
   policy      = data.aws_iam_policy_document.lambda_iot_publish.json
 }
 
+data "aws_iam_policy_document" "lambda_ddb_read" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "lambda_ddb_read" {
+  name        = "pseudopoll-publisher-lambda-ddb-read-policy"
+  description = "A policy that allows a publisher lambda to check for newer versions of an item before publishing it"
+  policy      = data.aws_iam_policy_document.lambda_ddb_read.json
+}
+
 module "vote_result_publisher_lambda_role" {
   source    = "../../lambda/iam"
   role_name = "pseudopoll-vote-result-publisher-lambda-role"
@@ -43,12 +60,13 @@ module "vote_result_publisher_lambda" {
   archive_source_file = "${path.module}/../../../../backend/lambdas/vote-result-publisher/bin/bootstrap"
   archive_output_path = "${path.module}/../../../../backend/lambdas/vote-result-publisher/bin/vote-result-publisher.zip"
 
-  environment_variables = {
+  environment_variables = merge(var.otel_environment_variables, {
     VOTE_SUCCEEDED_SOURCE      = var.ddb_stream_pipe_event_source
     VOTE_SUCCEEDED_DETAIL_TYPE = var.ddb_stream_pipe_event_detail_type
     VOTE_FAILED_SOURCE         = var.vote_failed_source
     VOTE_FAILED_DETAIL_TYPE    = var.vote_failed_detail_type
-  }
+    OTEL_SERVICE_NAME          = "pseudopoll-vote-result-publisher"
+  })
 }
 
 module "vote_count_publisher_lambda_role" {
@@ -66,6 +84,41 @@ resource "aws_iam_role_policy_attachment" "vote_count_publisher_iot" {
   policy_arn = aws_iam_policy.lambda_iot_publish.arn
 }
 
+resource "aws_iam_role_policy_attachment" "vote_count_publisher_ddb" {
+  role       = module.vote_count_publisher_lambda_role.role_name
+  policy_arn = aws_iam_policy.lambda_ddb_read.arn
+}
+
+data "aws_iam_policy_document" "vote_count_publisher_ddb_stream" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:DescribeStream",
+      "dynamodb:GetRecords",
+      "dynamodb:GetShardIterator",
+      "dynamodb:ListStreams",
+    ]
+
+    resources = [var.ddb_stream_arn]
+  }
+}
+
+resource "aws_iam_policy" "vote_count_publisher_ddb_stream" {
+  count = var.vote_count_publish_mode == "batch" ? 1 : 0
+
+  name        = "pseudopoll-vote-count-publisher-ddb-stream-policy"
+  description = "A policy that allows the vote count publisher lambda to read batches of counted votes from the DynamoDB stream"
+  policy      = data.aws_iam_policy_document.vote_count_publisher_ddb_stream.json
+}
+
+resource "aws_iam_role_policy_attachment" "vote_count_publisher_ddb_stream" {
+  count = var.vote_count_publish_mode == "batch" ? 1 : 0
+
+  role       = module.vote_count_publisher_lambda_role.role_name
+  policy_arn = aws_iam_policy.vote_count_publisher_ddb_stream[0].arn
+}
+
 module "vote_count_publisher_lambda" {
   source              = "../../lambda"
   function_name       = "pseudopoll-vote-count-publisher"
@@ -73,10 +126,49 @@ module "vote_count_publisher_lambda" {
   archive_source_file = "${path.module}/../../../../backend/lambdas/vote-count-publisher/bin/bootstrap"
   archive_output_path = "${path.module}/../../../../backend/lambdas/vote-count-publisher/bin/vote-count-publisher.zip"
 
-  environment_variables = {
-    SOURCE      = var.ddb_stream_pipe_event_source
-    DETAIL_TYPE = var.ddb_stream_pipe_event_detail_type
+  environment_variables = merge(var.otel_environment_variables, {
+    SOURCE            = var.ddb_stream_pipe_event_source
+    DETAIL_TYPE       = var.ddb_stream_pipe_event_detail_type
+    SINGLE_TABLE_NAME = var.single_table_name
+    PUBLISH_MODE      = var.vote_count_publish_mode
+    MAX_PUBLISH_RATE  = tostring(var.vote_count_max_publish_rate)
+    OTEL_SERVICE_NAME = "pseudopoll-vote-count-publisher"
+  })
+
+  # The per-topic rate limit is kept in memory, so a single instance publishes every batch
+  reserved_concurrent_executions = var.vote_count_publish_mode == "batch" ? 1 : -1
+}
+
+# In batch mode, counted votes are read straight from the stream instead of through the event bus
+resource "aws_lambda_event_source_mapping" "vote_count_publisher" {
+  count = var.vote_count_publish_mode == "batch" ? 1 : 0
+
+  event_source_arn                   = var.ddb_stream_arn
+  function_name                      = module.vote_count_publisher_lambda.function_name
+  starting_position                  = "LATEST"
+  batch_size                         = 100
+  maximum_batching_window_in_seconds = var.vote_count_batching_window_in_seconds
+  function_response_types            = ["ReportBatchItemFailures"]
+
+  filter_criteria {
+    filter {
+      pattern = jsonencode({
+        eventName = ["MODIFY"]
+        dynamodb = {
+          Keys = {
+            PK = {
+              S = [{ prefix = "option|" }]
+            }
+            SK = {
+              S = [{ prefix = "option|" }]
+            }
+          }
+        }
+      })
+    }
   }
+
+  depends_on = [aws_iam_role_policy_attachment.vote_count_publisher_ddb_stream]
 }
 
 module "poll_modification_publisher_lambda_role" {
@@ -94,6 +186,11 @@ resource "aws_iam_role_policy_attachment" "poll_modification_publisher_iot" {
   policy_arn = aws_iam_policy.lambda_iot_publish.arn
 }
 
+resource "aws_iam_role_policy_attachment" "poll_modification_publisher_ddb" {
+  role       = module.poll_modification_publisher_lambda_role.role_name
+  policy_arn = aws_iam_policy.lambda_ddb_read.arn
+}
+
 module "poll_modification_publisher_lambda" {
   source              = "../../lambda"
   function_name       = "pseudopoll-poll-modification-publisher"
@@ -102,8 +199,67 @@ module "poll_modification_publisher_lambda" {
   archive_output_path = "${path.module}/../../../../backend/lambdas/poll-modification-publisher/bin/poll-modification-publisher.zip"
 
   environment_variables = {
-    SOURCE      = var.ddb_stream_pipe_event_source
-    DETAIL_TYPE = var.ddb_stream_pipe_event_detail_type
+    SOURCE            = var.ddb_stream_pipe_event_source
+    DETAIL_TYPE       = var.ddb_stream_pipe_event_detail_type
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+module "poll_snapshot_publisher_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-poll-snapshot-publisher-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "poll_snapshot_publisher_logging" {
+  role       = module.poll_snapshot_publisher_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+resource "aws_iam_role_policy_attachment" "poll_snapshot_publisher_iot" {
+  role       = module.poll_snapshot_publisher_lambda_role.role_name
+  policy_arn = aws_iam_policy.lambda_iot_publish.arn
+}
+
+data "aws_iam_policy_document" "poll_snapshot_publisher_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+      "dynamodb:UpdateItem",
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI1",
+      "${var.single_table_arn}/index/GSI2",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "poll_snapshot_publisher_ddb" {
+  name        = "pseudopoll-poll-snapshot-publisher-ddb-policy"
+  description = "A policy that allows the poll snapshot publisher lambda to find active polls, read their snapshots and close expired polls"
+  policy      = data.aws_iam_policy_document.poll_snapshot_publisher_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "poll_snapshot_publisher_ddb" {
+  role       = module.poll_snapshot_publisher_lambda_role.role_name
+  policy_arn = aws_iam_policy.poll_snapshot_publisher_ddb.arn
+}
+
+module "poll_snapshot_publisher_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-poll-snapshot-publisher"
+  role_arn            = module.poll_snapshot_publisher_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/poll-snapshot-publisher/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/poll-snapshot-publisher/bin/poll-snapshot-publisher.zip"
+
+  environment_variables = {
+    SNAPSHOT_REQUESTED_SOURCE      = var.poll_snapshot_requested_source
+    SNAPSHOT_REQUESTED_DETAIL_TYPE = var.poll_snapshot_requested_detail_type
+    SINGLE_TABLE_NAME              = var.single_table_name
   }
 }
 
@@ -120,7 +276,8 @@ module "iot_authorizer_lambda" {
   archive_output_path = "${path.module}/../../../../backend/lambdas/iot-authorizer/bin/iot-authorizer.zip"
 
   environment_variables = {
-    AWS_ACCOUNT_ID = data.aws_caller_identity.main.account_id
+    AWS_ACCOUNT_ID            = data.aws_caller_identity.main.account_id
+    SUBSCRIPTION_TOKEN_SECRET = var.subscription_token_secret
   }
 }
 
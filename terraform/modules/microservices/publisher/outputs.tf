@@ -21,3 +21,11 @@ output "poll_modification_publisher_lambda_function_name" {
 output "poll_modification_publisher_lambda_arn" {
   value = module.poll_modification_publisher_lambda.arn
 }
+
+output "poll_snapshot_publisher_lambda_function_name" {
+  value = module.poll_snapshot_publisher_lambda.function_name
+}
+
+output "poll_snapshot_publisher_lambda_arn" {
+  value = module.poll_snapshot_publisher_lambda.arn
+}
@@ -23,6 +23,46 @@ variable "vote_failed_detail_type" {
   type        = string
 }
 
+variable "poll_snapshot_requested_source" {
+  description = "The source name of the poll snapshot requested event"
+  type        = string
+}
+
+variable "poll_snapshot_requested_detail_type" {
+  description = "The detail type of the poll snapshot requested event"
+  type        = string
+}
+
+variable "single_table_name" {
+  description = "Name of the single table"
+  type        = string
+}
+
+variable "single_table_arn" {
+  description = "ARN of the single table"
+  type        = string
+}
+
+variable "ddb_stream_arn" {
+  description = "ARN of the DynamoDB stream that the vote count publisher reads from in batch mode"
+  type        = string
+}
+
+variable "vote_count_publish_mode" {
+  description = "How vote counts are published (single or batch)"
+  type        = string
+}
+
+variable "vote_count_max_publish_rate" {
+  description = "Maximum number of vote count messages published per second to each poll's topic in batch mode"
+  type        = number
+}
+
+variable "vote_count_batching_window_in_seconds" {
+  description = "How long to gather counted votes before publishing them in batch mode"
+  type        = number
+}
+
 variable "region" {
   description = "The region of the AWS account"
   type        = string
@@ -32,3 +72,15 @@ variable "iot_custom_authorizer_name" {
   description = "The name of the IoT custom authorizer"
   type        = string
 }
+
+variable "subscription_token_secret" {
+  description = "Secret used to verify the tokens that authorize MQTT subscriptions"
+  type        = string
+  sensitive   = true
+}
+
+variable "otel_environment_variables" {
+  description = "OpenTelemetry exporter environment variables shared by the traced lambdas"
+  type        = map(string)
+  sensitive   = true
+}
@@ -0,0 +1,495 @@
+resource "aws_api_gateway_resource" "series" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.parent_id
+  path_part   = "series"
+}
+
+resource "aws_api_gateway_resource" "one_series" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.series.id
+  path_part   = "{seriesId}"
+}
+
+resource "aws_api_gateway_resource" "series_results" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.one_series.id
+  path_part   = "results"
+}
+
+resource "aws_api_gateway_request_validator" "create_series" {
+  name                  = "create-series-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "create_series" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.series.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.create_series.id
+  request_models = {
+    "application/json" = var.create_series_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "create_series" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.series.path_part}/${aws_api_gateway_method.create_series.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "create_series" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.series.id
+  http_method             = aws_api_gateway_method.create_series.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_series_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "create_series_api_lambda" {
+  statement_id  = "PseudoPollAllowCreateSeriesLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_series_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.create_series.http_method}${aws_api_gateway_resource.series.path}"
+}
+
+module "create_series_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-create-series-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "create_series_logging" {
+  role       = module.create_series_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "create_series_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:PutItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "create_series_lambda_ddb" {
+  name        = "pseudopoll-create-series-lambda-ddb"
+  description = "IAM policy for create series lambda to write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.create_series_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "create_series_lambda_ddb" {
+  role       = module.create_series_lambda_role.role_name
+  policy_arn = aws_iam_policy.create_series_lambda_ddb.arn
+}
+
+module "create_series_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-create-series"
+  role_arn            = module.create_series_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/create-series/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/create-series/bin/create-series.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+    PROMPT_MIN_LENGTH = "${var.prompt_min_length}"
+    PROMPT_MAX_LENGTH = "${var.prompt_max_length}"
+    OPTION_MIN_LENGTH = "${var.option_min_length}"
+    OPTION_MAX_LENGTH = "${var.option_max_length}"
+    MIN_OPTIONS       = "${var.min_options}"
+    MAX_OPTIONS       = "${var.max_options}"
+    MIN_DURATION      = "${var.min_duration}"
+    MAX_DURATION      = "${var.max_duration}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_series_created" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.series.id
+  http_method = aws_api_gateway_method.create_series.http_method
+  status_code = "201"
+
+  response_models = {
+    "application/json" = var.series_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_series_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.series.id
+  http_method = aws_api_gateway_method.create_series.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_series_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.series.id
+  http_method = aws_api_gateway_method.create_series.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "update_series_status" {
+  name                  = "update-series-status-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "update_series_status" {
+  rest_api_id = var.rest_api_id
+  http_method = "PATCH"
+  resource_id = aws_api_gateway_resource.one_series.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.seriesId" = true
+  }
+
+  request_validator_id = aws_api_gateway_request_validator.update_series_status.id
+  request_models = {
+    "application/json" = var.update_series_status_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "update_series_status" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.one_series.path_part}/${aws_api_gateway_method.update_series_status.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "update_series_status" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.one_series.id
+  http_method             = aws_api_gateway_method.update_series_status.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.update_series_status_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "update_series_status_api_lambda" {
+  statement_id  = "PseudoPollAllowUpdateSeriesStatusLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.update_series_status_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.update_series_status.http_method}${aws_api_gateway_resource.one_series.path}"
+}
+
+module "update_series_status_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-update-series-status-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "update_series_status_logging" {
+  role       = module.update_series_status_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "update_series_status_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:UpdateItem"
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "update_series_status_lambda_ddb" {
+  name        = "pseudopoll-update-series-status-lambda-ddb"
+  description = "IAM policy for update series status lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.update_series_status_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "update_series_status_lambda_ddb" {
+  role       = module.update_series_status_lambda_role.role_name
+  policy_arn = aws_iam_policy.update_series_status_lambda_ddb.arn
+}
+
+module "update_series_status_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-update-series-status"
+  role_arn            = module.update_series_status_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/update-series-status/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/update-series-status/bin/update-series-status.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_series_status_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.one_series.id
+  http_method = aws_api_gateway_method.update_series_status.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.series_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_series_status_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.one_series.id
+  http_method = aws_api_gateway_method.update_series_status.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_series_status_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.one_series.id
+  http_method = aws_api_gateway_method.update_series_status.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_series_status_conflict" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.one_series.id
+  http_method = aws_api_gateway_method.update_series_status.http_method
+  status_code = "409"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_series_status_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.one_series.id
+  http_method = aws_api_gateway_method.update_series_status.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "get_series_results" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.series_results.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.seriesId" = true
+  }
+}
+
+resource "aws_api_gateway_method_settings" "get_series_results" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.series_results.path_part}/${aws_api_gateway_method.get_series_results.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "get_series_results" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.series_results.id
+  http_method             = aws_api_gateway_method.get_series_results.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.get_series_results_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "get_series_results_api_lambda" {
+  statement_id  = "PseudoPollAllowGetSeriesResultsLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.get_series_results_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.get_series_results.http_method}${aws_api_gateway_resource.series_results.path}"
+}
+
+module "get_series_results_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-get-series-results-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "get_series_results_logging" {
+  role       = module.get_series_results_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "get_series_results_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = ["${var.single_table_arn}/index/GSI1"]
+  }
+
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query"
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "get_series_results_lambda_ddb" {
+  name        = "pseudopoll-get-series-results-lambda-ddb"
+  description = "IAM policy for get series results lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.get_series_results_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "get_series_results_lambda_ddb" {
+  role       = module.get_series_results_lambda_role.role_name
+  policy_arn = aws_iam_policy.get_series_results_lambda_ddb.arn
+}
+
+module "get_series_results_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-get-series-results"
+  role_arn            = module.get_series_results_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/get-series-results/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/get-series-results/bin/get-series-results.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_series_results_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.series_results.id
+  http_method = aws_api_gateway_method.get_series_results.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.series_results_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_series_results_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.series_results.id
+  http_method = aws_api_gateway_method.get_series_results.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "get_series_results_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.series_results.id
+  http_method = aws_api_gateway_method.get_series_results.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+module "series_scheduler_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-series-scheduler-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "series_scheduler_logging" {
+  role       = module.series_scheduler_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "series_scheduler_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:Query",
+      "dynamodb:TransactWriteItems",
+      "dynamodb:PutItem",
+      "dynamodb:UpdateItem"
+    ]
+
+    resources = [
+      var.single_table_arn,
+      "${var.single_table_arn}/index/GSI3",
+    ]
+  }
+}
+
+resource "aws_iam_policy" "series_scheduler_lambda_ddb" {
+  name        = "pseudopoll-series-scheduler-lambda-ddb"
+  description = "IAM policy for series scheduler lambda to read from and write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.series_scheduler_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "series_scheduler_lambda_ddb" {
+  role       = module.series_scheduler_lambda_role.role_name
+  policy_arn = aws_iam_policy.series_scheduler_lambda_ddb.arn
+}
+
+module "series_scheduler_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-series-scheduler"
+  role_arn            = module.series_scheduler_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/series-scheduler/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/series-scheduler/bin/series-scheduler.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+  }
+}
@@ -0,0 +1,34 @@
+output "series_scheduler_lambda_function_name" {
+  value = module.series_scheduler_lambda.function_name
+}
+
+output "series_scheduler_lambda_arn" {
+  value = module.series_scheduler_lambda.arn
+}
+
+output "resources_hash" {
+  value = sha1(jsonencode([
+    aws_api_gateway_resource.series,
+    aws_api_gateway_resource.one_series,
+    aws_api_gateway_resource.series_results,
+    aws_api_gateway_request_validator.create_series,
+    aws_api_gateway_method.create_series,
+    aws_api_gateway_integration.create_series,
+    aws_api_gateway_method_response.create_series_created,
+    aws_api_gateway_method_response.create_series_bad_request,
+    aws_api_gateway_method_response.create_series_internal_server_error,
+    aws_api_gateway_request_validator.update_series_status,
+    aws_api_gateway_method.update_series_status,
+    aws_api_gateway_integration.update_series_status,
+    aws_api_gateway_method_response.update_series_status_ok,
+    aws_api_gateway_method_response.update_series_status_bad_request,
+    aws_api_gateway_method_response.update_series_status_not_found,
+    aws_api_gateway_method_response.update_series_status_conflict,
+    aws_api_gateway_method_response.update_series_status_internal_server_error,
+    aws_api_gateway_method.get_series_results,
+    aws_api_gateway_integration.get_series_results,
+    aws_api_gateway_method_response.get_series_results_ok,
+    aws_api_gateway_method_response.get_series_results_not_found,
+    aws_api_gateway_method_response.get_series_results_internal_server_error,
+  ]))
+}
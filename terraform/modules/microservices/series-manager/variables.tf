@@ -0,0 +1,114 @@
+variable "rest_api_id" {
+  description = "ID of the associated REST API"
+  type        = string
+}
+
+variable "rest_api_execution_arn" {
+  description = "Execution ARN of the associated REST API"
+  type        = string
+}
+
+variable "stage_name" {
+  description = "Name of the associated stage"
+  type        = string
+}
+
+variable "create_series_model_name" {
+  description = "Name of the create series model"
+  type        = string
+}
+
+variable "update_series_status_model_name" {
+  description = "Name of the update series status model"
+  type        = string
+}
+
+variable "series_model_name" {
+  description = "Name of the series model"
+  type        = string
+}
+
+variable "series_results_model_name" {
+  description = "Name of the series results model"
+  type        = string
+}
+
+variable "error_model_name" {
+  description = "Name of the error model"
+  type        = string
+}
+
+variable "parent_id" {
+  description = "ID of the parent API resource"
+  type        = string
+}
+
+variable "custom_authorizer_id" {
+  description = "Custom authorizer id"
+  type        = string
+}
+
+variable "single_table_name" {
+  description = "Name of the single table"
+  type        = string
+}
+
+variable "single_table_arn" {
+  description = "ARN of the single table"
+  type        = string
+}
+
+variable "nanoid_alphabet" {
+  description = "Alphabet used for nanoid generation"
+  type        = string
+}
+
+variable "nanoid_length" {
+  description = "Length of the nanoid"
+  type        = number
+}
+
+variable "prompt_min_length" {
+  description = "Minimum length of the prompt"
+  type        = number
+}
+
+variable "prompt_max_length" {
+  description = "Maximum length of the prompt"
+  type        = number
+}
+
+variable "option_min_length" {
+  description = "Minimum length of the option"
+  type        = number
+}
+
+variable "option_max_length" {
+  description = "Maximum length of the option"
+  type        = number
+}
+
+variable "min_options" {
+  description = "Minimum number of options"
+  type        = number
+}
+
+variable "max_options" {
+  description = "Maximum number of options"
+  type        = number
+}
+
+variable "min_duration" {
+  description = "Minimum duration of the poll"
+  type        = number
+}
+
+variable "max_duration" {
+  description = "Maximum duration of the poll"
+  type        = number
+}
+
+variable "lambda_logging_policy_arn" {
+  description = "ARN of the Lambda logging policy"
+  type        = string
+}
@@ -0,0 +1,503 @@
+resource "aws_api_gateway_resource" "templates" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.parent_id
+  path_part   = "templates"
+}
+
+resource "aws_api_gateway_resource" "template" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.templates.id
+  path_part   = "{templateId}"
+}
+
+resource "aws_api_gateway_request_validator" "create_template" {
+  name                  = "create-template-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "create_template" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.templates.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.create_template.id
+  request_models = {
+    "application/json" = var.save_template_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "create_template" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.templates.path_part}/${aws_api_gateway_method.create_template.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "create_template" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.templates.id
+  http_method             = aws_api_gateway_method.create_template.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_template_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "create_template_api_lambda" {
+  statement_id  = "PseudoPollAllowCreateTemplateLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_template_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.create_template.http_method}${aws_api_gateway_resource.templates.path}"
+}
+
+module "create_template_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-create-template-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "create_template_logging" {
+  role       = module.create_template_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "create_template_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:PutItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "create_template_lambda_ddb" {
+  name        = "pseudopoll-create-template-lambda-ddb"
+  description = "IAM policy for create template lambda to write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.create_template_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "create_template_lambda_ddb" {
+  role       = module.create_template_lambda_role.role_name
+  policy_arn = aws_iam_policy.create_template_lambda_ddb.arn
+}
+
+module "create_template_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-create-template"
+  role_arn            = module.create_template_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/create-template/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/create-template/bin/create-template.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_template_created" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.templates.id
+  http_method = aws_api_gateway_method.create_template.http_method
+  status_code = "201"
+
+  response_models = {
+    "application/json" = var.template_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_template_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.templates.id
+  http_method = aws_api_gateway_method.create_template.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_template_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.templates.id
+  http_method = aws_api_gateway_method.create_template.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "list_templates" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.templates.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "list_templates" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.templates.path_part}/${aws_api_gateway_method.list_templates.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "list_templates" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.templates.id
+  http_method             = aws_api_gateway_method.list_templates.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.list_templates_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "list_templates_api_lambda" {
+  statement_id  = "PseudoPollAllowListTemplatesLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.list_templates_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.list_templates.http_method}${aws_api_gateway_resource.templates.path}"
+}
+
+module "list_templates_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-list-templates-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "list_templates_logging" {
+  role       = module.list_templates_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "list_templates_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = ["${var.single_table_arn}/index/GSI1"]
+  }
+}
+
+resource "aws_iam_policy" "list_templates_lambda_ddb" {
+  name        = "pseudopoll-list-templates-lambda-ddb"
+  description = "IAM policy for list templates lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.list_templates_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "list_templates_lambda_ddb" {
+  role       = module.list_templates_lambda_role.role_name
+  policy_arn = aws_iam_policy.list_templates_lambda_ddb.arn
+}
+
+module "list_templates_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-list-templates"
+  role_arn            = module.list_templates_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/list-templates/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/list-templates/bin/list-templates.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_templates_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.templates.id
+  http_method = aws_api_gateway_method.list_templates.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.templates_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_templates_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.templates.id
+  http_method = aws_api_gateway_method.list_templates.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_request_validator" "update_template" {
+  name                  = "update-template-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "update_template" {
+  rest_api_id = var.rest_api_id
+  http_method = "PUT"
+  resource_id = aws_api_gateway_resource.template.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.templateId" = true
+  }
+
+  request_validator_id = aws_api_gateway_request_validator.update_template.id
+  request_models = {
+    "application/json" = var.save_template_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "update_template" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.template.path_part}/${aws_api_gateway_method.update_template.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "update_template" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.template.id
+  http_method             = aws_api_gateway_method.update_template.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.update_template_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "update_template_api_lambda" {
+  statement_id  = "PseudoPollAllowUpdateTemplateLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.update_template_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.update_template.http_method}${aws_api_gateway_resource.template.path}"
+}
+
+module "update_template_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-update-template-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "update_template_logging" {
+  role       = module.update_template_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "update_template_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:UpdateItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "update_template_lambda_ddb" {
+  name        = "pseudopoll-update-template-lambda-ddb"
+  description = "IAM policy for update template lambda to write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.update_template_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "update_template_lambda_ddb" {
+  role       = module.update_template_lambda_role.role_name
+  policy_arn = aws_iam_policy.update_template_lambda_ddb.arn
+}
+
+module "update_template_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-update-template"
+  role_arn            = module.update_template_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/update-template/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/update-template/bin/update-template.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_template_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.update_template.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.template_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_template_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.update_template.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_template_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.update_template.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "update_template_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.update_template.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "delete_template" {
+  rest_api_id = var.rest_api_id
+  http_method = "DELETE"
+  resource_id = aws_api_gateway_resource.template.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.path.templateId" = true
+  }
+}
+
+resource "aws_api_gateway_method_settings" "delete_template" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.template.path_part}/${aws_api_gateway_method.delete_template.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "delete_template" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.template.id
+  http_method             = aws_api_gateway_method.delete_template.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.delete_template_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "delete_template_api_lambda" {
+  statement_id  = "PseudoPollAllowDeleteTemplateLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.delete_template_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.delete_template.http_method}${aws_api_gateway_resource.template.path}"
+}
+
+module "delete_template_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-delete-template-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "delete_template_logging" {
+  role       = module.delete_template_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "delete_template_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:DeleteItem"]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "delete_template_lambda_ddb" {
+  name        = "pseudopoll-delete-template-lambda-ddb"
+  description = "IAM policy for delete template lambda to write to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.delete_template_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "delete_template_lambda_ddb" {
+  role       = module.delete_template_lambda_role.role_name
+  policy_arn = aws_iam_policy.delete_template_lambda_ddb.arn
+}
+
+module "delete_template_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-delete-template"
+  role_arn            = module.delete_template_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/delete-template/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/delete-template/bin/delete-template.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_template_no_content" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.delete_template.http_method
+  status_code = "204"
+}
+
+resource "aws_api_gateway_method_response" "delete_template_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.delete_template.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_template_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.template.id
+  http_method = aws_api_gateway_method.delete_template.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
@@ -0,0 +1,28 @@
+output "resources_hash" {
+  value = sha1(jsonencode([
+    aws_api_gateway_resource.templates,
+    aws_api_gateway_resource.template,
+    aws_api_gateway_request_validator.create_template,
+    aws_api_gateway_method.create_template,
+    aws_api_gateway_integration.create_template,
+    aws_api_gateway_method_response.create_template_created,
+    aws_api_gateway_method_response.create_template_bad_request,
+    aws_api_gateway_method_response.create_template_internal_server_error,
+    aws_api_gateway_method.list_templates,
+    aws_api_gateway_integration.list_templates,
+    aws_api_gateway_method_response.list_templates_ok,
+    aws_api_gateway_method_response.list_templates_internal_server_error,
+    aws_api_gateway_request_validator.update_template,
+    aws_api_gateway_method.update_template,
+    aws_api_gateway_integration.update_template,
+    aws_api_gateway_method_response.update_template_ok,
+    aws_api_gateway_method_response.update_template_bad_request,
+    aws_api_gateway_method_response.update_template_not_found,
+    aws_api_gateway_method_response.update_template_internal_server_error,
+    aws_api_gateway_method.delete_template,
+    aws_api_gateway_integration.delete_template,
+    aws_api_gateway_method_response.delete_template_no_content,
+    aws_api_gateway_method_response.delete_template_not_found,
+    aws_api_gateway_method_response.delete_template_internal_server_error,
+  ]))
+}
@@ -11,6 +11,11 @@ resource "aws_api_gateway_method" "post" {
 
   authorization = "CUSTOM"
   authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.header.traceparent" = false
+    "method.request.header.tracestate"  = false
+  }
 }
 
 resource "aws_api_gateway_integration" "vote" {
@@ -55,6 +60,86 @@ resource "aws_api_gateway_method_response" "post_accepted" {
   }
 }
 
+resource "aws_api_gateway_request_validator" "write_in" {
+  name                  = "write-in-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+# A write-in is a vote for an option that the voter names, which is added to the poll if it isn't one of its
+# options yet, so it's queued the same way as a vote
+resource "aws_api_gateway_method" "write_in" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = var.write_ins_resource_id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.header.traceparent" = false
+    "method.request.header.tracestate"  = false
+  }
+
+  request_validator_id = aws_api_gateway_request_validator.write_in.id
+  request_models = {
+    "application/json" = var.write_in_vote_model_name
+  }
+}
+
+resource "aws_api_gateway_integration" "write_in" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = var.write_ins_resource_id
+  http_method             = aws_api_gateway_method.write_in.http_method
+  integration_http_method = "POST"
+  type                    = "AWS"
+  passthrough_behavior    = "NEVER"
+  credentials             = var.api_role_arn
+  uri                     = "arn:aws:apigateway:${var.region}:sqs:path/${aws_sqs_queue.vote_queue.name}"
+
+  request_parameters = {
+    "integration.request.header.Content-Type" = "'application/x-www-form-urlencoded'"
+  }
+
+  request_templates = {
+    "application/json" = templatefile("${path.module}/../../templates/mappings/requests/vote.vm", {})
+  }
+}
+
+resource "aws_api_gateway_integration_response" "write_in_accepted" {
+  rest_api_id       = var.rest_api_id
+  resource_id       = var.write_ins_resource_id
+  http_method       = aws_api_gateway_method.write_in.http_method
+  status_code       = aws_api_gateway_method_response.write_in_accepted.status_code
+  selection_pattern = "^2[0-9][0-9]"
+
+  response_templates = {
+    "application/json" = templatefile("${path.module}/../../templates/mappings/responses/vote.vm", {})
+  }
+}
+
+resource "aws_api_gateway_method_response" "write_in_accepted" {
+  rest_api_id = var.rest_api_id
+  resource_id = var.write_ins_resource_id
+  http_method = aws_api_gateway_method.write_in.http_method
+  status_code = "202"
+
+  response_models = {
+    "application/json" = var.vote_accepted_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "write_in_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = var.write_ins_resource_id
+  http_method = aws_api_gateway_method.write_in.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
 resource "aws_api_gateway_resource" "public_option" {
   rest_api_id = var.rest_api_id
   parent_id   = var.public_poll_resource_id
@@ -69,7 +154,9 @@ resource "aws_api_gateway_method" "public_post" {
   authorization = "NONE"
 
   request_parameters = {
-    "method.request.header.x-user-ip" = true
+    "method.request.header.x-user-ip"   = true
+    "method.request.header.traceparent" = false
+    "method.request.header.tracestate"  = false
   }
 }
 
@@ -178,6 +265,14 @@ data "aws_iam_policy_document" "vote_sqs_dynamodb_events" {
     resources = [var.single_table_arn]
   }
 
+  statement {
+    effect = "Allow"
+
+    actions = ["dynamodb:Query"]
+
+    resources = ["${var.single_table_arn}/index/GSI1"]
+  }
+
   statement {
     effect = "Allow"
 
@@ -206,8 +301,12 @@ module "vote_lambda" {
   archive_source_file = "${path.module}/../../../../backend/lambdas/vote/bin/bootstrap"
   archive_output_path = "${path.module}/../../../../backend/lambdas/vote/bin/vote.zip"
 
-  environment_variables = {
+  environment_variables = merge(var.otel_environment_variables, {
     SINGLE_TABLE_NAME = var.single_table_name
     EVENT_BUS_NAME    = var.event_bus_name
-  }
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+    MAX_WRITE_INS     = "${var.max_write_ins}"
+    OTEL_SERVICE_NAME = "pseudopoll-vote"
+  })
 }
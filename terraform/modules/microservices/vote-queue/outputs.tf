@@ -5,6 +5,12 @@ output "resources_hash" {
     aws_api_gateway_integration.vote,
     aws_api_gateway_integration_response.vote_accepted,
     aws_api_gateway_method_response.post_accepted,
+    aws_api_gateway_request_validator.write_in,
+    aws_api_gateway_method.write_in,
+    aws_api_gateway_integration.write_in,
+    aws_api_gateway_integration_response.write_in_accepted,
+    aws_api_gateway_method_response.write_in_accepted,
+    aws_api_gateway_method_response.write_in_bad_request,
     aws_api_gateway_resource.public_option,
     aws_api_gateway_method.public_post,
     aws_api_gateway_integration.public_vote,
@@ -12,3 +18,11 @@ output "resources_hash" {
     aws_api_gateway_method_response.public_post_accepted,
   ]))
 }
+
+output "vote_queue_url" {
+  value = aws_sqs_queue.vote_queue.url
+}
+
+output "vote_queue_arn" {
+  value = aws_sqs_queue.vote_queue.arn
+}
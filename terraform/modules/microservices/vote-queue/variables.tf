@@ -18,6 +18,11 @@ variable "vote_accepted_model_name" {
   type        = string
 }
 
+variable "write_in_vote_model_name" {
+  description = "Name of the write-in vote model"
+  type        = string
+}
+
 variable "error_model_name" {
   description = "Name of the error model"
   type        = string
@@ -33,6 +38,11 @@ variable "custom_authorizer_id" {
   type        = string
 }
 
+variable "write_ins_resource_id" {
+  description = "ID of the poll's write-ins resource"
+  type        = string
+}
+
 variable "public_poll_resource_id" {
   description = "ID of the public poll resource"
   type        = string
@@ -67,3 +77,24 @@ variable "event_bus_arn" {
   description = "ARN of the event bus"
   type        = string
 }
+
+variable "nanoid_alphabet" {
+  description = "Alphabet used for nanoid generation"
+  type        = string
+}
+
+variable "nanoid_length" {
+  description = "Length of the nanoid"
+  type        = number
+}
+
+variable "max_write_ins" {
+  description = "Maximum number of options that voters can write in to a poll"
+  type        = number
+}
+
+variable "otel_environment_variables" {
+  description = "OpenTelemetry exporter environment variables shared by the traced lambdas"
+  type        = map(string)
+  sensitive   = true
+}
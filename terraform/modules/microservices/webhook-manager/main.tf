@@ -0,0 +1,628 @@
+resource "aws_api_gateway_resource" "webhooks" {
+  rest_api_id = var.rest_api_id
+  parent_id   = var.poll_resource_id
+  path_part   = "webhooks"
+}
+
+resource "aws_api_gateway_resource" "webhook" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.webhooks.id
+  path_part   = "{webhookId}"
+}
+
+resource "aws_api_gateway_resource" "webhook_deliveries" {
+  rest_api_id = var.rest_api_id
+  parent_id   = aws_api_gateway_resource.webhook.id
+  path_part   = "deliveries"
+}
+
+resource "aws_api_gateway_request_validator" "create_webhook" {
+  name                  = "create-webhook-validator"
+  rest_api_id           = var.rest_api_id
+  validate_request_body = true
+}
+
+resource "aws_api_gateway_method" "create_webhook" {
+  rest_api_id = var.rest_api_id
+  http_method = "POST"
+  resource_id = aws_api_gateway_resource.webhooks.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_validator_id = aws_api_gateway_request_validator.create_webhook.id
+  request_models = {
+    "application/json" = var.create_webhook_model_name
+  }
+}
+
+resource "aws_api_gateway_method_settings" "create_webhook" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.webhooks.path_part}/${aws_api_gateway_method.create_webhook.http_method}"
+
+  # The response body holds the webhook's secret, so it is kept out of the execution logs
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = false
+  }
+}
+
+resource "aws_api_gateway_integration" "create_webhook" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.webhooks.id
+  http_method             = aws_api_gateway_method.create_webhook.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.create_webhook_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "create_webhook_api_lambda" {
+  statement_id  = "PseudoPollAllowCreateWebhookLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.create_webhook_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.create_webhook.http_method}${aws_api_gateway_resource.webhooks.path}"
+}
+
+module "create_webhook_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-create-webhook-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "create_webhook_logging" {
+  role       = module.create_webhook_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "create_webhook_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:PutItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "create_webhook_lambda_ddb" {
+  name        = "pseudopoll-create-webhook-lambda-ddb"
+  description = "IAM policy for create webhook lambda to read polls from and write webhooks to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.create_webhook_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "create_webhook_lambda_ddb" {
+  role       = module.create_webhook_lambda_role.role_name
+  policy_arn = aws_iam_policy.create_webhook_lambda_ddb.arn
+}
+
+module "create_webhook_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-create-webhook"
+  role_arn            = module.create_webhook_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/create-webhook/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/create-webhook/bin/create-webhook.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+    NANOID_ALPHABET   = var.nanoid_alphabet
+    NANOID_LENGTH     = "${var.nanoid_length}"
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_webhook_created" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.create_webhook.http_method
+  status_code = "201"
+
+  response_models = {
+    "application/json" = var.webhook_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_webhook_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.create_webhook.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_webhook_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.create_webhook.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_webhook_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.create_webhook.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "create_webhook_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.create_webhook.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "list_webhooks" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.webhooks.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "list_webhooks" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.webhooks.path_part}/${aws_api_gateway_method.list_webhooks.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "list_webhooks" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.webhooks.id
+  http_method             = aws_api_gateway_method.list_webhooks.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.list_webhooks_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "list_webhooks_api_lambda" {
+  statement_id  = "PseudoPollAllowListWebhooksLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.list_webhooks_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.list_webhooks.http_method}${aws_api_gateway_resource.webhooks.path}"
+}
+
+module "list_webhooks_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-list-webhooks-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "list_webhooks_logging" {
+  role       = module.list_webhooks_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "list_webhooks_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "list_webhooks_lambda_ddb" {
+  name        = "pseudopoll-list-webhooks-lambda-ddb"
+  description = "IAM policy for list webhooks lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.list_webhooks_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "list_webhooks_lambda_ddb" {
+  role       = module.list_webhooks_lambda_role.role_name
+  policy_arn = aws_iam_policy.list_webhooks_lambda_ddb.arn
+}
+
+module "list_webhooks_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-list-webhooks"
+  role_arn            = module.list_webhooks_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/list-webhooks/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/list-webhooks/bin/list-webhooks.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhooks_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.list_webhooks.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.webhooks_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhooks_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.list_webhooks.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhooks_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.list_webhooks.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhooks_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhooks.id
+  http_method = aws_api_gateway_method.list_webhooks.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "delete_webhook" {
+  rest_api_id = var.rest_api_id
+  http_method = "DELETE"
+  resource_id = aws_api_gateway_resource.webhook.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+}
+
+resource "aws_api_gateway_method_settings" "delete_webhook" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.webhook.path_part}/${aws_api_gateway_method.delete_webhook.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "delete_webhook" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.webhook.id
+  http_method             = aws_api_gateway_method.delete_webhook.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.delete_webhook_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "delete_webhook_api_lambda" {
+  statement_id  = "PseudoPollAllowDeleteWebhookLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.delete_webhook_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.delete_webhook.http_method}${aws_api_gateway_resource.webhook.path}"
+}
+
+module "delete_webhook_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-delete-webhook-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "delete_webhook_logging" {
+  role       = module.delete_webhook_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "delete_webhook_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:DeleteItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "delete_webhook_lambda_ddb" {
+  name        = "pseudopoll-delete-webhook-lambda-ddb"
+  description = "IAM policy for delete webhook lambda to delete webhooks from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.delete_webhook_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "delete_webhook_lambda_ddb" {
+  role       = module.delete_webhook_lambda_role.role_name
+  policy_arn = aws_iam_policy.delete_webhook_lambda_ddb.arn
+}
+
+module "delete_webhook_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-delete-webhook"
+  role_arn            = module.delete_webhook_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/delete-webhook/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/delete-webhook/bin/delete-webhook.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_webhook_no_content" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook.id
+  http_method = aws_api_gateway_method.delete_webhook.http_method
+  status_code = "204"
+}
+
+resource "aws_api_gateway_method_response" "delete_webhook_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook.id
+  http_method = aws_api_gateway_method.delete_webhook.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_webhook_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook.id
+  http_method = aws_api_gateway_method.delete_webhook.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "delete_webhook_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook.id
+  http_method = aws_api_gateway_method.delete_webhook.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method" "list_webhook_deliveries" {
+  rest_api_id = var.rest_api_id
+  http_method = "GET"
+  resource_id = aws_api_gateway_resource.webhook_deliveries.id
+
+  authorization = "CUSTOM"
+  authorizer_id = var.custom_authorizer_id
+
+  request_parameters = {
+    "method.request.querystring.limit" = false
+  }
+}
+
+resource "aws_api_gateway_method_settings" "list_webhook_deliveries" {
+  rest_api_id = var.rest_api_id
+  stage_name  = var.stage_name
+  method_path = "${aws_api_gateway_resource.webhook_deliveries.path_part}/${aws_api_gateway_method.list_webhook_deliveries.http_method}"
+
+  settings {
+    logging_level      = "INFO"
+    metrics_enabled    = true
+    data_trace_enabled = true
+  }
+}
+
+resource "aws_api_gateway_integration" "list_webhook_deliveries" {
+  rest_api_id             = var.rest_api_id
+  resource_id             = aws_api_gateway_resource.webhook_deliveries.id
+  http_method             = aws_api_gateway_method.list_webhook_deliveries.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = module.list_webhook_deliveries_lambda.invoke_arn
+}
+
+resource "aws_lambda_permission" "list_webhook_deliveries_api_lambda" {
+  statement_id  = "PseudoPollAllowListWebhookDeliveriesLambdaExecutionFromApiGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = module.list_webhook_deliveries_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+
+  source_arn = "${var.rest_api_execution_arn}/*/${aws_api_gateway_method.list_webhook_deliveries.http_method}${aws_api_gateway_resource.webhook_deliveries.path}"
+}
+
+module "list_webhook_deliveries_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-list-webhook-deliveries-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "list_webhook_deliveries_logging" {
+  role       = module.list_webhook_deliveries_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "list_webhook_deliveries_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:GetItem",
+      "dynamodb:Query",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "list_webhook_deliveries_lambda_ddb" {
+  name        = "pseudopoll-list-webhook-deliveries-lambda-ddb"
+  description = "IAM policy for list webhook deliveries lambda to read from DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.list_webhook_deliveries_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "list_webhook_deliveries_lambda_ddb" {
+  role       = module.list_webhook_deliveries_lambda_role.role_name
+  policy_arn = aws_iam_policy.list_webhook_deliveries_lambda_ddb.arn
+}
+
+module "list_webhook_deliveries_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-list-webhook-deliveries"
+  role_arn            = module.list_webhook_deliveries_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/list-webhook-deliveries/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/list-webhook-deliveries/bin/list-webhook-deliveries.zip"
+
+  environment_variables = {
+    SINGLE_TABLE_NAME = var.single_table_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhook_deliveries_ok" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook_deliveries.id
+  http_method = aws_api_gateway_method.list_webhook_deliveries.http_method
+  status_code = "200"
+
+  response_models = {
+    "application/json" = var.webhook_deliveries_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhook_deliveries_bad_request" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook_deliveries.id
+  http_method = aws_api_gateway_method.list_webhook_deliveries.http_method
+  status_code = "400"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhook_deliveries_forbidden" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook_deliveries.id
+  http_method = aws_api_gateway_method.list_webhook_deliveries.http_method
+  status_code = "403"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhook_deliveries_not_found" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook_deliveries.id
+  http_method = aws_api_gateway_method.list_webhook_deliveries.http_method
+  status_code = "404"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+resource "aws_api_gateway_method_response" "list_webhook_deliveries_internal_server_error" {
+  rest_api_id = var.rest_api_id
+  resource_id = aws_api_gateway_resource.webhook_deliveries.id
+  http_method = aws_api_gateway_method.list_webhook_deliveries.http_method
+  status_code = "500"
+
+  response_models = {
+    "application/json" = var.error_model_name
+  }
+}
+
+module "webhook_dispatcher_lambda_role" {
+  source    = "../../lambda/iam"
+  role_name = "pseudopoll-webhook-dispatcher-lambda-role"
+}
+
+resource "aws_iam_role_policy_attachment" "webhook_dispatcher_logging" {
+  role       = module.webhook_dispatcher_lambda_role.role_name
+  policy_arn = var.lambda_logging_policy_arn
+}
+
+data "aws_iam_policy_document" "webhook_dispatcher_lambda_ddb" {
+  statement {
+    effect = "Allow"
+
+    actions = [
+      "dynamodb:Query",
+      "dynamodb:PutItem",
+    ]
+
+    resources = [var.single_table_arn]
+  }
+}
+
+resource "aws_iam_policy" "webhook_dispatcher_lambda_ddb" {
+  name        = "pseudopoll-webhook-dispatcher-lambda-ddb"
+  description = "IAM policy for webhook dispatcher lambda to read webhooks from and log deliveries to DynamoDB"
+  path        = "/"
+  policy      = data.aws_iam_policy_document.webhook_dispatcher_lambda_ddb.json
+}
+
+resource "aws_iam_role_policy_attachment" "webhook_dispatcher_lambda_ddb" {
+  role       = module.webhook_dispatcher_lambda_role.role_name
+  policy_arn = aws_iam_policy.webhook_dispatcher_lambda_ddb.arn
+}
+
+module "webhook_dispatcher_lambda" {
+  source              = "../../lambda"
+  function_name       = "pseudopoll-webhook-dispatcher"
+  role_arn            = module.webhook_dispatcher_lambda_role.role_arn
+  archive_source_file = "${path.module}/../../../../backend/lambdas/webhook-dispatcher/bin/bootstrap"
+  archive_output_path = "${path.module}/../../../../backend/lambdas/webhook-dispatcher/bin/webhook-dispatcher.zip"
+
+  # Failed deliveries are retried with backoff within the invocation
+  timeout = 60
+
+  environment_variables = {
+    DDB_STREAM_SOURCE       = var.ddb_stream_pipe_event_source
+    DDB_STREAM_DETAIL_TYPE  = var.ddb_stream_pipe_event_detail_type
+    VOTE_FAILED_SOURCE      = var.vote_failed_source
+    VOTE_FAILED_DETAIL_TYPE = var.vote_failed_detail_type
+    MAX_DELIVERY_ATTEMPTS   = tostring(var.max_delivery_attempts)
+    SINGLE_TABLE_NAME       = var.single_table_name
+  }
+}
@@ -0,0 +1,42 @@
+output "resources_hash" {
+  value = sha1(jsonencode([
+    aws_api_gateway_resource.webhooks,
+    aws_api_gateway_resource.webhook,
+    aws_api_gateway_resource.webhook_deliveries,
+    aws_api_gateway_request_validator.create_webhook,
+    aws_api_gateway_method.create_webhook,
+    aws_api_gateway_integration.create_webhook,
+    aws_api_gateway_method_response.create_webhook_created,
+    aws_api_gateway_method_response.create_webhook_bad_request,
+    aws_api_gateway_method_response.create_webhook_forbidden,
+    aws_api_gateway_method_response.create_webhook_not_found,
+    aws_api_gateway_method_response.create_webhook_internal_server_error,
+    aws_api_gateway_method.list_webhooks,
+    aws_api_gateway_integration.list_webhooks,
+    aws_api_gateway_method_response.list_webhooks_ok,
+    aws_api_gateway_method_response.list_webhooks_forbidden,
+    aws_api_gateway_method_response.list_webhooks_not_found,
+    aws_api_gateway_method_response.list_webhooks_internal_server_error,
+    aws_api_gateway_method.delete_webhook,
+    aws_api_gateway_integration.delete_webhook,
+    aws_api_gateway_method_response.delete_webhook_no_content,
+    aws_api_gateway_method_response.delete_webhook_forbidden,
+    aws_api_gateway_method_response.delete_webhook_not_found,
+    aws_api_gateway_method_response.delete_webhook_internal_server_error,
+    aws_api_gateway_method.list_webhook_deliveries,
+    aws_api_gateway_integration.list_webhook_deliveries,
+    aws_api_gateway_method_response.list_webhook_deliveries_ok,
+    aws_api_gateway_method_response.list_webhook_deliveries_bad_request,
+    aws_api_gateway_method_response.list_webhook_deliveries_forbidden,
+    aws_api_gateway_method_response.list_webhook_deliveries_not_found,
+    aws_api_gateway_method_response.list_webhook_deliveries_internal_server_error,
+  ]))
+}
+
+output "webhook_dispatcher_lambda_function_name" {
+  value = module.webhook_dispatcher_lambda.function_name
+}
+
+output "webhook_dispatcher_lambda_arn" {
+  value = module.webhook_dispatcher_lambda.arn
+}
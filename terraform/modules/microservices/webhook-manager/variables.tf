@@ -0,0 +1,99 @@
+variable "rest_api_id" {
+  description = "ID of the associated REST API"
+  type        = string
+}
+
+variable "rest_api_execution_arn" {
+  description = "Execution ARN of the associated REST API"
+  type        = string
+}
+
+variable "stage_name" {
+  description = "Name of the associated stage"
+  type        = string
+}
+
+variable "create_webhook_model_name" {
+  description = "Name of the create webhook model"
+  type        = string
+}
+
+variable "webhook_model_name" {
+  description = "Name of the webhook model"
+  type        = string
+}
+
+variable "webhooks_model_name" {
+  description = "Name of the webhooks model"
+  type        = string
+}
+
+variable "webhook_deliveries_model_name" {
+  description = "Name of the webhook deliveries model"
+  type        = string
+}
+
+variable "error_model_name" {
+  description = "Name of the error model"
+  type        = string
+}
+
+variable "poll_resource_id" {
+  description = "ID of the poll API resource"
+  type        = string
+}
+
+variable "custom_authorizer_id" {
+  description = "Custom authorizer id"
+  type        = string
+}
+
+variable "single_table_name" {
+  description = "Name of the single table"
+  type        = string
+}
+
+variable "single_table_arn" {
+  description = "ARN of the single table"
+  type        = string
+}
+
+variable "nanoid_alphabet" {
+  description = "Alphabet used for nanoid generation"
+  type        = string
+}
+
+variable "nanoid_length" {
+  description = "Length of the nanoid"
+  type        = number
+}
+
+variable "lambda_logging_policy_arn" {
+  description = "ARN of the Lambda logging policy"
+  type        = string
+}
+
+variable "ddb_stream_pipe_event_source" {
+  description = "The source name of the DynamoDB stream pipe"
+  type        = string
+}
+
+variable "ddb_stream_pipe_event_detail_type" {
+  description = "The detail type of the DynamoDB stream pipe"
+  type        = string
+}
+
+variable "vote_failed_source" {
+  description = "The source name of the vote failed event"
+  type        = string
+}
+
+variable "vote_failed_detail_type" {
+  description = "The detail type of the vote failed event"
+  type        = string
+}
+
+variable "max_delivery_attempts" {
+  description = "How many times a webhook delivery is attempted before it's logged as failed"
+  type        = number
+}
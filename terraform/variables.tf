@@ -61,6 +61,16 @@ variable "token_issuer" {
   default = "https://accounts.google.com"
 }
 
+variable "trusted_issuers" {
+  description = "Token issuers trusted in addition to Google, e.g. for bots and CI jobs"
+  type = list(object({
+    issuer   = string
+    jwks_uri = string
+    audience = string
+  }))
+  default = []
+}
+
 variable "nanoid_alphabet" {
   type        = string
   description = "Alphabet used for nanoid generation"
@@ -121,8 +131,172 @@ variable "max_duration" {
   default     = 604800
 }
 
+variable "option_description_max_length" {
+  type        = number
+  description = "Maximum length of an option's description"
+  default     = 280
+}
+
+variable "option_url_max_length" {
+  type        = number
+  description = "Maximum length of an option's URL"
+  default     = 2048
+}
+
+variable "option_image_max_size" {
+  type        = number
+  description = "Maximum size of an option's image in bytes"
+  default     = 5242880
+}
+
+variable "max_write_ins" {
+  type        = number
+  description = "Maximum number of options that voters can write in to a poll"
+  default     = 20
+}
+
+variable "max_vote_weight" {
+  type        = number
+  description = "Maximum weight of a voter's or group's votes"
+  default     = 100
+}
+
+variable "max_vote_weights" {
+  type        = number
+  description = "Maximum number of voters and groups that a poll's votes can be weighed by"
+  default     = 100
+}
+
 variable "iot_custom_authorizer_name" {
   description = "The name of the IoT custom authorizer"
   type        = string
   default     = "pseudopoll-iot-authorizer"
 }
+
+variable "subscription_token_secret" {
+  description = "Secret used to sign the tokens that authorize MQTT subscriptions"
+  type        = string
+  sensitive   = true
+}
+
+variable "subscription_token_ttl" {
+  description = "How long a subscription token can be used to connect to IoT Core, as a Go duration string"
+  type        = string
+  default     = "5m"
+}
+
+variable "otel_traces_exporter" {
+  description = "OpenTelemetry traces exporter used by the lambdas (otlp, console or none); otlp needs otel_exporter_otlp_endpoint"
+  type        = string
+  default     = "none"
+
+  validation {
+    condition     = contains(["otlp", "console", "none"], var.otel_traces_exporter)
+    error_message = "otel_traces_exporter must be otlp, console or none"
+  }
+}
+
+variable "otel_exporter_otlp_endpoint" {
+  description = "OTLP/HTTP endpoint that the lambdas export traces to, e.g. a collector reachable from the lambdas"
+  type        = string
+  default     = ""
+}
+
+variable "otel_exporter_otlp_headers" {
+  description = "Headers sent with OTLP exports, e.g. for collector authentication"
+  type        = string
+  default     = ""
+  sensitive   = true
+}
+
+variable "vote_count_publish_mode" {
+  description = "How vote counts are published: single publishes each counted vote, batch coalesces a batch of counted votes into one message per poll"
+  type        = string
+  default     = "single"
+
+  validation {
+    condition     = contains(["single", "batch"], var.vote_count_publish_mode)
+    error_message = "vote_count_publish_mode must be single or batch"
+  }
+}
+
+variable "vote_count_max_publish_rate" {
+  description = "Maximum number of vote count messages published per second to each poll's topic in batch mode"
+  type        = number
+  default     = 2
+}
+
+variable "vote_count_batching_window_in_seconds" {
+  description = "How long to gather counted votes before publishing them in batch mode"
+  type        = number
+  default     = 1
+}
+
+variable "poll_snapshot_schedule_expression" {
+  description = "How often the retained snapshots of active polls are republished"
+  type        = string
+  default     = "rate(1 minute)"
+}
+
+variable "series_schedule_expression" {
+  description = "How often recurring poll series are checked for runs that are due"
+  type        = string
+  default     = "rate(1 minute)"
+}
+
+variable "webhook_max_delivery_attempts" {
+  description = "How many times a webhook delivery is attempted before it's logged as failed"
+  type        = number
+  default     = 5
+}
+
+variable "chat_signing_secret" {
+  description = "Secret that the chat workspace signs its slash command and interactive message requests with"
+  type        = string
+  sensitive   = true
+}
+
+variable "chat_bot_token" {
+  description = "Bot token used to post and update poll messages in the chat workspace"
+  type        = string
+  sensitive   = true
+}
+
+variable "chat_poll_duration" {
+  description = "Duration in seconds of the polls created with the chat slash command"
+  type        = number
+  default     = 86400
+}
+
+variable "chat_poll_retention_days" {
+  description = "Days the polls created with the chat slash command are kept after they close, before they expire; 0 keeps them"
+  type        = number
+  default     = 7
+
+  validation {
+    condition     = var.chat_poll_retention_days >= 0
+    error_message = "chat_poll_retention_days must not be negative"
+  }
+}
+
+variable "archived_poll_retention_days" {
+  description = "Days archived polls are kept before they expire; 0 keeps them"
+  type        = number
+  default     = 30
+
+  validation {
+    condition     = var.archived_poll_retention_days >= 0
+    error_message = "archived_poll_retention_days must not be negative"
+  }
+}
+
+variable "vote_erasure_policy" {
+  description = "How an erased user's votes on other users' polls are handled: remove takes them off the counts, anonymize keeps them counted under an anonymous id"
+  type        = string
+  default     = "remove"
+
+  validation {
+    condition     = contains(["remove", "anonymize"], var.vote_erasure_policy)
+    error_message = "vote_erasure_policy must be remove or anonymize"
+  }
+}